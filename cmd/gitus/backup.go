@@ -0,0 +1,300 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/ssh"
+	"github.com/GitusCodeForge/Gitus/routes"
+)
+
+// gitus backup [archive]
+// gitus restore [archive]
+// see docs/backup.org.
+
+const BACKUP_FORMAT_VERSION = 1
+
+// the layout of the archive (a gzipped tarball):
+//
+//     manifest.json          always the first entry.
+//     config.json            the config file.
+//     database.jsonl         neutral database dump (forge mode).
+//     receipt.jsonl          receipts, one per line (forge mode).
+//     authorized_keys.json   the managed authorized_keys entries (forge mode).
+//     repository/{path}/meta/...     hooks, config, etc. of the bare repo.
+//     repository/{path}/repo.bundle  all the refs; absent for empty repos.
+//     snippet/...            everything under SnippetRoot (forge mode).
+//     lfs/...                the git lfs objects (forge mode).
+const (
+	BACKUP_MANIFEST = "manifest.json"
+	BACKUP_CONFIG = "config.json"
+	BACKUP_DATABASE = "database.jsonl"
+	BACKUP_RECEIPT = "receipt.jsonl"
+	BACKUP_AUTHORIZED_KEYS = "authorized_keys.json"
+	BACKUP_REPOSITORY_PREFIX = "repository/"
+	BACKUP_SNIPPET_PREFIX = "snippet/"
+	BACKUP_LFS_PREFIX = "lfs/"
+	BACKUP_BUNDLE_NAME = "repo.bundle"
+	BACKUP_META_DIR = "meta/"
+)
+
+type BackupManifest struct {
+	FormatVersion int `json:"formatVersion"`
+	DumpFormatVersion int `json:"dumpFormatVersion"`
+	Timestamp int64 `json:"timestamp"`
+	OperationMode string `json:"operationMode"`
+	DatabaseType string `json:"databaseType"`
+	SchemaVersion int `json:"schemaVersion"`
+	// relative to GitRoot.
+	RepositoryList []string `json:"repositoryList"`
+}
+
+// these are either rebuilt by the bundle or not worth keeping.
+var backupSkippedRepoEntry = map[string]bool{
+	"objects": true,
+	"refs": true,
+	"logs": true,
+	"packed-refs": true,
+	"FETCH_HEAD": true,
+	"ORIG_HEAD": true,
+}
+
+func isBareRepository(p string) bool {
+	for _, k := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(path.Join(p, k)); err != nil { return false }
+	}
+	return true
+}
+
+func findAllRepository(gitRoot string) ([]string, error) {
+	res := make([]string, 0)
+	err := filepath.WalkDir(gitRoot, func(p string, d fs.DirEntry, err error) error {
+		if err != nil { return err }
+		if !d.IsDir() { return nil }
+		if !isBareRepository(p) { return nil }
+		rel, err := filepath.Rel(gitRoot, p)
+		if err != nil { return err }
+		res = append(res, filepath.ToSlash(rel))
+		return filepath.SkipDir
+	})
+	if os.IsNotExist(err) { return res, nil }
+	return res, err
+}
+
+type backupWriter struct {
+	tw *tar.Writer
+}
+
+func (bw *backupWriter) addBytes(name string, b []byte) error {
+	err := bw.tw.WriteHeader(&tar.Header{
+		Name: name,
+		Mode: 0644,
+		Size: int64(len(b)),
+		ModTime: time.Now(),
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil { return err }
+	_, err = bw.tw.Write(b)
+	return err
+}
+
+func (bw *backupWriter) addFile(name string, p string) error {
+	f, err := os.Open(p)
+	if err != nil { return err }
+	defer f.Close()
+	s, err := f.Stat()
+	if err != nil { return err }
+	err = bw.tw.WriteHeader(&tar.Header{
+		Name: name,
+		Mode: int64(s.Mode().Perm()),
+		Size: s.Size(),
+		ModTime: s.ModTime(),
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil { return err }
+	_, err = io.Copy(bw.tw, f)
+	return err
+}
+
+// adds every regular file under `root` with the prefix `prefix`.
+// `skip` is checked against the top-level entries.
+func (bw *backupWriter) addDirectory(prefix string, root string, skip map[string]bool) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil { return err }
+		rel, err := filepath.Rel(root, p)
+		if err != nil { return err }
+		if rel == "." { return nil }
+		rel = filepath.ToSlash(rel)
+		if skip != nil && skip[strings.SplitN(rel, "/", 2)[0]] {
+			if d.IsDir() { return filepath.SkipDir }
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() { return nil }
+		if strings.HasSuffix(rel, ".lock") { return nil }
+		return bw.addFile(prefix + rel, p)
+	})
+}
+
+// the content is written to a temporary file first since the size has
+// to be known before writing the tar header.
+func (bw *backupWriter) addFromTemp(name string, f func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp("", "gitus-backup-*")
+	if err != nil { return err }
+	defer os.Remove(tmp.Name())
+	err = f(tmp)
+	tmp.Close()
+	if err != nil { return err }
+	return bw.addFile(name, tmp.Name())
+}
+
+func (bw *backupWriter) addRepository(rel string, p string) error {
+	prefix := BACKUP_REPOSITORY_PREFIX + rel + "/"
+	err := bw.addDirectory(prefix + BACKUP_META_DIR, p, backupSkippedRepoEntry)
+	if err != nil { return err }
+	// git refuses to create empty bundles.
+	cmd := exec.Command("git", "for-each-ref", "--count=1")
+	cmd.Dir = p
+	out, err := cmd.Output()
+	if err != nil { return fmt.Errorf("Failed to list refs of %s: %w", rel, err) }
+	if len(bytes.TrimSpace(out)) <= 0 { return nil }
+	// a bundle is a consistent snapshot of the refs even when pushes
+	// happen during the backup.
+	tmp, err := os.CreateTemp("", "gitus-backup-*.bundle")
+	if err != nil { return err }
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	cmd = exec.Command("git", "bundle", "create", "--quiet", tmp.Name(), "--all")
+	cmd.Dir = p
+	out, err = cmd.CombinedOutput()
+	if err != nil { return fmt.Errorf("Failed to create bundle for %s: %s", rel, string(out)) }
+	return bw.addFile(prefix + BACKUP_BUNDLE_NAME, tmp.Name())
+}
+
+func writeBackup(ctx *routes.RouterContext, w io.Writer) error {
+	cfg := ctx.Config
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	bw := &backupWriter{ tw: tw }
+	isForge := cfg.OperationMode == gitus.OP_MODE_FORGE
+
+	repoList, err := findAllRepository(cfg.GitRoot)
+	if err != nil { return fmt.Errorf("Failed to find repositories: %w", err) }
+	manifest := &BackupManifest{
+		FormatVersion: BACKUP_FORMAT_VERSION,
+		Timestamp: time.Now().Unix(),
+		OperationMode: cfg.OperationMode,
+		RepositoryList: repoList,
+	}
+	if isForge {
+		manifest.DumpFormatVersion = db.DUMP_FORMAT_VERSION
+		manifest.DatabaseType = cfg.Database.Type
+		manifest.SchemaVersion, err = ctx.DatabaseInterface.GetSchemaVersion()
+		if err != nil { return err }
+	}
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil { return err }
+	if err = bw.addBytes(BACKUP_MANIFEST, b); err != nil { return err }
+	if err = bw.addFile(BACKUP_CONFIG, cfg.FilePath); err != nil { return err }
+
+	if isForge {
+		fmt.Printf("Exporting database...\n")
+		err = bw.addFromTemp(BACKUP_DATABASE, func(w io.Writer) error {
+			dw := db.NewDumpWriter(w)
+			err := ctx.DatabaseInterface.ExportDump(dw)
+			if err != nil { return err }
+			for _, t := range db.DumpTableList {
+				if dw.Count[t.Name] > 0 { fmt.Printf("  %s: %d\n", t.Name, dw.Count[t.Name]) }
+			}
+			return nil
+		})
+		if err != nil { return fmt.Errorf("Failed to export database: %w", err) }
+
+		receiptList, err := ctx.ReceiptSystem.ExportAllReceipt()
+		if err != nil { return fmt.Errorf("Failed to export receipts: %w", err) }
+		var rb bytes.Buffer
+		enc := json.NewEncoder(&rb)
+		for _, r := range receiptList {
+			if err = enc.Encode(r); err != nil { return err }
+		}
+		if err = bw.addBytes(BACKUP_RECEIPT, rb.Bytes()); err != nil { return err }
+
+		keyctx, err := ssh.ToContext(cfg)
+		if err != nil { return fmt.Errorf("Failed to read authorized_keys: %w", err) }
+		b, err := json.Marshal(keyctx.Managed)
+		if err != nil { return err }
+		if err = bw.addBytes(BACKUP_AUTHORIZED_KEYS, b); err != nil { return err }
+	}
+
+	for _, rel := range repoList {
+		fmt.Printf("Backing up repository %s...\n", rel)
+		err = bw.addRepository(rel, path.Join(cfg.GitRoot, rel))
+		if err != nil { return err }
+	}
+
+	if isForge && len(cfg.SnippetRoot) > 0 {
+		if _, err := os.Stat(cfg.SnippetRoot); err == nil {
+			fmt.Printf("Backing up snippets...\n")
+			err = bw.addDirectory(BACKUP_SNIPPET_PREFIX, cfg.SnippetRoot, nil)
+			if err != nil { return fmt.Errorf("Failed to back up snippets: %w", err) }
+		}
+	}
+
+	if isForge && len(cfg.ProperLFSRoot()) > 0 {
+		if _, err := os.Stat(cfg.ProperLFSRoot()); err == nil {
+			fmt.Printf("Backing up Git LFS objects...\n")
+			err = bw.addDirectory(BACKUP_LFS_PREFIX, cfg.ProperLFSRoot(), nil)
+			if err != nil { return fmt.Errorf("Failed to back up Git LFS objects: %w", err) }
+		}
+	}
+
+	if err = tw.Close(); err != nil { return err }
+	return gw.Close()
+}
+
+func HandleBackup(ctx *routes.RouterContext, args []string) int {
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "Usage: gitus -config [config] backup [archive]\n")
+		return 1
+	}
+	target := args[0]
+	if _, err := os.Stat(target); err == nil {
+		fmt.Fprintf(os.Stderr, "%s already exists.\n", target)
+		return 1
+	}
+	// written to a temporary file first so that a failed backup
+	// doesn't leave a truncated archive behind.
+	tmpPath := target + ".partial"
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create archive: %s\n", err.Error())
+		return 1
+	}
+	err = writeBackup(ctx, f)
+	if err == nil { err = f.Sync() }
+	f.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		fmt.Fprintf(os.Stderr, "Backup failed: %s\n", err.Error())
+		return 1
+	}
+	if err = os.Rename(tmpPath, target); err != nil {
+		os.Remove(tmpPath)
+		fmt.Fprintf(os.Stderr, "Backup failed: %s\n", err.Error())
+		return 1
+	}
+	fmt.Printf("Backup written to %s.\n", target)
+	return 0
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	dbinit "github.com/GitusCodeForge/Gitus/pkg/gitus/db/init"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/receipt"
+	rsinit "github.com/GitusCodeForge/Gitus/pkg/gitus/receipt/init"
+)
+
+// gitus db-convert --from-config a.json --to-config b.json
+// copies the main database & the receipt system between backends.
+// see docs/db-convert.org.
+
+func sameDatabase(a *gitus.GitusConfig, b *gitus.GitusConfig) bool {
+	if a.Database.Type != b.Database.Type { return false }
+	if a.Database.TablePrefix != b.Database.TablePrefix { return false }
+	switch a.Database.Type {
+	case "sqlite": return a.ProperDatabasePath() == b.ProperDatabasePath()
+	case "postgres": return a.Database.URL == b.Database.URL && a.Database.DatabaseName == b.Database.DatabaseName
+	}
+	return false
+}
+
+func sameReceiptSystem(a *gitus.GitusConfig, b *gitus.GitusConfig) bool {
+	if a.ReceiptSystem.Type != b.ReceiptSystem.Type { return false }
+	if a.ReceiptSystem.TablePrefix != b.ReceiptSystem.TablePrefix { return false }
+	switch a.ReceiptSystem.Type {
+	case "sqlite": return a.ProperReceiptSystemPath() == b.ProperReceiptSystemPath()
+	case "postgres": return a.ReceiptSystem.URL == b.ReceiptSystem.URL && a.ReceiptSystem.DatabaseName == b.ReceiptSystem.DatabaseName
+	}
+	return false
+}
+
+func convertDatabase(from db.GitusDatabaseInterface, to db.GitusDatabaseInterface) error {
+	err := db.CheckSchemaVersion(from)
+	if err != nil { return fmt.Errorf("Source database: %w", err) }
+	v, err := to.GetSchemaVersion()
+	if err != nil { return err }
+	if v > 0 { return errors.New("The target database is already installed; converting requires an empty database") }
+	if err = to.InstallTables(); err != nil { return fmt.Errorf("Failed to install tables: %w", err) }
+
+	// the dump is streamed from one to the other; the source is read
+	// within one snapshot, the target written within one transaction.
+	pr, pw := io.Pipe()
+	fromDw := db.NewDumpWriter(pw)
+	go func() {
+		pw.CloseWithError(from.ExportDump(fromDw))
+	}()
+	err = to.ImportDump(db.NewDumpReader(pr))
+	pr.CloseWithError(err)
+	if err != nil { return err }
+
+	// verify by counting what's in the target.
+	toDw := db.NewDumpWriter(io.Discard)
+	if err = to.ExportDump(toDw); err != nil { return fmt.Errorf("Failed to verify target database: %w", err) }
+	mismatch := false
+	for _, t := range db.DumpTableList {
+		a, b := fromDw.Count[t.Name], toDw.Count[t.Name]
+		mark := "ok"
+		if a != b { mark = "MISMATCH"; mismatch = true }
+		fmt.Printf("  %-20s %8d %8d  %s\n", t.Name, a, b, mark)
+	}
+	if mismatch { return errors.New("Row counts don't match") }
+	return nil
+}
+
+func convertReceiptSystem(from receipt.GitusReceiptSystemInterface, to receipt.GitusReceiptSystemInterface) error {
+	b, err := to.IsReceiptSystemUsable()
+	if err != nil { return err }
+	if !b {
+		if err = to.Install(); err != nil { return fmt.Errorf("Failed to install receipt system: %w", err) }
+	}
+	existing, err := to.ExportAllReceipt()
+	if err != nil { return err }
+	if len(existing) > 0 { return errors.New("The target receipt system is not empty") }
+	l, err := from.ExportAllReceipt()
+	if err != nil { return err }
+	for _, r := range l {
+		if err = to.ImportReceipt(r); err != nil { return err }
+	}
+	res, err := to.ExportAllReceipt()
+	if err != nil { return fmt.Errorf("Failed to verify target receipt system: %w", err) }
+	fmt.Printf("  %-20s %8d %8d  ", "receipt", len(l), len(res))
+	if len(l) != len(res) {
+		fmt.Printf("MISMATCH\n")
+		return errors.New("Receipt counts don't match")
+	}
+	fmt.Printf("ok\n")
+	return nil
+}
+
+// runs before the config file is loaded since it works with two of
+// them. `resolve` resolves the config paths the same way as -config.
+func HandleDBConvert(args []string, resolve func(string) string) int {
+	argparse := flag.NewFlagSet("gitus db-convert", flag.ContinueOnError)
+	fromArg := argparse.String("from-config", "", "The config file of the instance to copy from.")
+	toArg := argparse.String("to-config", "", "The config file specifying the databases to copy to.")
+	skipReceipt := argparse.Bool("skip-receipt", false, "Don't copy the receipt system.")
+	if err := argparse.Parse(args); err != nil { return 1 }
+	if len(*fromArg) <= 0 || len(*toArg) <= 0 {
+		argparse.Usage()
+		return 1
+	}
+	fromCfg, err := gitus.LoadConfigFile(resolve(*fromArg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load %s: %s\n", *fromArg, err.Error())
+		return 1
+	}
+	toCfg, err := gitus.LoadConfigFile(resolve(*toArg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load %s: %s\n", *toArg, err.Error())
+		return 1
+	}
+	if sameDatabase(fromCfg, toCfg) {
+		fmt.Fprintf(os.Stderr, "The two configs refer to the same database.\n")
+		return 1
+	}
+
+	from, err := dbinit.InitializeDatabase(fromCfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load source database: %s\n", err.Error())
+		return 1
+	}
+	defer from.Dispose()
+	to, err := dbinit.InitializeDatabase(toCfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load target database: %s\n", err.Error())
+		return 1
+	}
+	defer to.Dispose()
+	fmt.Printf("Copying database (%s -> %s)...\n", fromCfg.Database.Type, toCfg.Database.Type)
+	fmt.Printf("  %-20s %8s %8s\n", "table", "source", "target")
+	if err = convertDatabase(from, to); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to copy database: %s\n", err.Error())
+		return 1
+	}
+
+	if !*skipReceipt && !sameReceiptSystem(fromCfg, toCfg) {
+		fromRs, err := rsinit.InitializeReceiptSystem(fromCfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load source receipt system: %s\n", err.Error())
+			return 1
+		}
+		defer fromRs.Dispose()
+		toRs, err := rsinit.InitializeReceiptSystem(toCfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load target receipt system: %s\n", err.Error())
+			return 1
+		}
+		defer toRs.Dispose()
+		fmt.Printf("Copying receipt system (%s -> %s)...\n", fromCfg.ReceiptSystem.Type, toCfg.ReceiptSystem.Type)
+		if err = convertReceiptSystem(fromRs, toRs); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to copy receipt system: %s\n", err.Error())
+			return 1
+		}
+	}
+	fmt.Printf("Done. Start Gitus with %s to use the new database.\n", *toArg)
+	return 0
+}
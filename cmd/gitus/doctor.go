@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	dbinit "github.com/GitusCodeForge/Gitus/pkg/gitus/db/init"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/health"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/mail"
+	rsinit "github.com/GitusCodeForge/Gitus/pkg/gitus/receipt/init"
+	ssinit "github.com/GitusCodeForge/Gitus/pkg/gitus/session/init"
+)
+
+// gitus doctor
+// runs the same checks as /readyz plus the ones that are too slow
+// for it. unlike the web server, failing to initialize a component
+// is reported instead of stopping right away. see docs/health.org.
+func HandleDoctor(cfg *gitus.GitusConfig, args []string) int {
+	t := &health.Target{ Config: cfg }
+	initFailure := make(map[string]string, 0)
+	if cfg.IsInForgeMode() {
+		dbif, err := dbinit.InitializeDatabase(cfg)
+		if err != nil {
+			initFailure["database"] = err.Error()
+		} else {
+			t.Database = dbif
+			defer dbif.Dispose()
+		}
+		ssif, err := ssinit.InitializeDatabase(cfg)
+		if err != nil {
+			initFailure["sessionStore"] = err.Error()
+		} else {
+			t.SessionStore = ssif
+			defer ssif.Dispose()
+		}
+		rs, err := rsinit.InitializeReceiptSystem(cfg)
+		if err != nil {
+			initFailure["receiptSystem"] = err.Error()
+		} else {
+			t.ReceiptSystem = rs
+			defer rs.Dispose()
+		}
+		if cfg.Mailer.Type != "" {
+			ml, err := mail.InitializeMailer(cfg)
+			if err != nil {
+				initFailure["mailer"] = err.Error()
+			} else {
+				t.Mailer = ml
+			}
+		}
+	}
+
+	l := health.Check(t)
+	for _, c := range l {
+		if msg, ok := initFailure[c.Name]; ok {
+			c.Message = fmt.Sprintf("failed to initialize: %s", msg)
+		}
+	}
+	l = append(l, checkSchemaVersion(t), checkGitUser(cfg), health.CheckGitBinary(), health.CheckOwnership(cfg))
+	report := health.NewReport(t, l)
+
+	for _, c := range report.Component {
+		if c.Message == "" {
+			fmt.Printf("%-8s %s\n", c.Status, c.Name)
+		} else {
+			fmt.Printf("%-8s %s: %s\n", c.Status, c.Name, c.Message)
+		}
+	}
+	if report.Status != health.STATUS_OK {
+		fmt.Fprintf(os.Stderr, "Some of the checks failed.\n")
+		return 1
+	}
+	return 0
+}
+
+func checkSchemaVersion(t *health.Target) *health.ComponentStatus {
+	res := &health.ComponentStatus{ Name: "schema", Status: health.STATUS_OK }
+	if t.Database == nil {
+		res.Status = health.STATUS_SKIPPED
+		res.Message = "database not available"
+		return res
+	}
+	if err := db.CheckSchemaVersion(t.Database); err != nil {
+		res.Status = health.STATUS_FAIL
+		res.Message = err.Error()
+	}
+	return res
+}
+
+func checkGitUser(cfg *gitus.GitusConfig) *health.ComponentStatus {
+	res := &health.ComponentStatus{ Name: "gitUser", Status: health.STATUS_OK, Message: cfg.GitUser }
+	if _, err := user.Lookup(cfg.GitUser); err != nil {
+		res.Status = health.STATUS_FAIL
+		res.Message = err.Error()
+	}
+	return res
+}
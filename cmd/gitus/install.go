@@ -80,6 +80,8 @@ func forgeModeGitusReadyCheck(ctx routes.RouterContext) (bool, error) {
 		b, err := dbif.IsDatabaseUsable()
 		if err != nil { return b, err }
 		if !b { return false, errors.New("Database not usable") }
+		err = db.CheckSchemaVersion(dbif)
+		if err != nil { return false, err }
 		_, err = os.ReadDir(cfg.GitRoot)
 		if err != nil {
 			if os.IsNotExist(err) { return false, errors.New("Git root does not exist") }
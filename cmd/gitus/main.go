@@ -2,30 +2,43 @@ package main
 
 import (
 	gocontext "context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"os/user"
 	"path"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/GitusCodeForge/Gitus/pkg/gitus"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/confirm_code"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/drain"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/maintenance"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/handover"
+	gitlog "github.com/GitusCodeForge/Gitus/pkg/gitus/log"
 	dbinit "github.com/GitusCodeForge/Gitus/pkg/gitus/db/init"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/mail"
 	rsinit "github.com/GitusCodeForge/Gitus/pkg/gitus/receipt/init"
 	ssinit "github.com/GitusCodeForge/Gitus/pkg/gitus/session/init"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/ssh"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/sshserver"
 	"github.com/GitusCodeForge/Gitus/pkg/gitlib"
 	"github.com/GitusCodeForge/Gitus/routes"
 	"github.com/GitusCodeForge/Gitus/routes/controller"
 	"github.com/GitusCodeForge/Gitus/templates"
 )
 
+// how long to wait for the new process started by SIGUSR2 to be ready
+// before giving up on it.
+const REEXEC_READY_TIMEOUT = 60 * time.Second
+
 func main() {
 	argparse := flag.NewFlagSet("gitus", flag.ContinueOnError)
 	argparse.Usage = func() {
@@ -38,15 +51,16 @@ func main() {
 
 	// attempt to resolve config file path.
 	// if the provided path is relative, resolve it against os.Executable.
-	configPath := *configArg
 	root, err := os.Executable()
 	if err != nil {
 		fmt.Printf("Failed to resolve absolute path for config file: %s\n", err.Error())
 		os.Exit(1)
 	}
-	if !path.IsAbs(configPath) {
-		configPath = path.Join(path.Dir(root), configPath)
+	resolveConfigPath := func(p string) string {
+		if path.IsAbs(p) { return p }
+		return path.Join(path.Dir(root), p)
 	}
+	configPath := resolveConfigPath(*configArg)
 
 	// check if init. if init, we start web installer or generate
 	// config. if we *don't* use the web installer, we don't perform
@@ -69,6 +83,17 @@ func main() {
 	}
 
 	mainCall := argparse.Args()
+
+	// restoring might happen on a fresh machine where there isn't a
+	// config file yet, so it's handled before loading the config.
+	if len(mainCall) > 0 && mainCall[0] == "restore" {
+		os.Exit(HandleRestore(configPath, mainCall[1:]))
+	}
+	// db-convert works with two config files.
+	if len(mainCall) > 0 && mainCall[0] == "db-convert" {
+		os.Exit(HandleDBConvert(mainCall[1:], resolveConfigPath))
+	}
+
 	// NOTE THAT certain activities does not need parts of Gitus
 	// (e.g. "ssh" and "webhooks" does not require a working mailer
 	// or session store). We've decided they should not report
@@ -79,10 +104,13 @@ func main() {
 	isWebHooks := containsCommand && mainCall[0] == "web-hooks"
 	isUpdateTrigger := containsCommand && mainCall[0] == "update-trigger"
 	isResetAdmin := containsCommand && mainCall[0] == "reset-admin"
-	dbifNeeded := isWebServer || (containsCommand && (isSsh || isWebHooks || isUpdateTrigger || isResetAdmin))
+	isMigrate := containsCommand && mainCall[0] == "migrate"
+	isBackup := containsCommand && mainCall[0] == "backup"
+	isQuota := containsCommand && mainCall[0] == "quota"
+	dbifNeeded := isWebServer || (containsCommand && (isSsh || isWebHooks || isUpdateTrigger || isResetAdmin || isMigrate || isBackup || isQuota))
 	ssifNeeded := isWebServer
 	keyctxNeeded := isWebServer || (containsCommand && isSsh)
-	rsifNeeded := isWebServer
+	rsifNeeded := isWebServer || isBackup
 	mailerNeeded := isWebServer
 	ccmNeeded := isWebServer
 
@@ -102,7 +130,37 @@ func main() {
 		}
 		os.Exit(1)
 	}
-	
+
+	// the commands run by sshd & the git hooks have their stdout &
+	// stderr connected to the git client. see docs/logging.org.
+	isSubprocess := isSsh || isWebHooks || isUpdateTrigger || (containsCommand && mainCall[0] == "host-mode") || (isQuota && len(mainCall) > 1 && mainCall[1] == "pre-receive")
+	// `gitus ssh` is where a push starts, so the request id that
+	// follows it through git & the hooks is assigned here.
+	if isSsh && !gitlog.IsValidRequestID(os.Getenv(gitlog.RequestIDEnv)) {
+		os.Setenv(gitlog.RequestIDEnv, gitlog.NewRequestID())
+	}
+	logComponent := "web"
+	if containsCommand { logComponent = mainCall[0] }
+	if isSubprocess {
+		err = gitlog.SetupSubprocess(config, logComponent)
+	} else {
+		err = gitlog.Setup(config, logComponent)
+	}
+	if err != nil {
+		if isSsh {
+			fmt.Print(gitlib.ToPktLine(fmt.Sprintf("ERR failed to set up logging: %s\n", err.Error())))
+		} else {
+			fmt.Fprintf(os.Stderr, "Failed to set up logging: %s\n", err.Error())
+		}
+		os.Exit(1)
+	}
+
+	// the doctor reports the components it fails to initialize
+	// instead of stopping, so it does its own initialization.
+	if containsCommand && mainCall[0] == "doctor" {
+		os.Exit(HandleDoctor(config, mainCall[1:]))
+	}
+
 	masterTemplate := templates.LoadTemplate()
 	context := routes.RouterContext{
 		Config: config,
@@ -129,7 +187,10 @@ func main() {
 			context.SessionInterface = ssif
 		}
 
-		if keyctxNeeded {
+		// with the built-in ssh server the keys are checked against
+		// the database directly, so authorized_keys is left alone; a
+		// nil key managing context does nothing.
+		if keyctxNeeded && !(isWebServer && config.SSHServer.Enable) {
 			keyctx, err := ssh.ToContext(config)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Failed to create key managing context: %s\n", err.Error())
@@ -169,9 +230,20 @@ func main() {
 			context.ConfirmCodeManager = ccm
 		}
 
+		// migrating must be done before the ready check since the
+		// check refuses to go on with an outdated schema.
+		if isMigrate {
+			os.Exit(HandleMigrate(&context, mainCall[1:]))
+		}
+
 		ok, err := forgeModeGitusReadyCheck(context)
 		if !ok {
 			fmt.Fprintf(os.Stderr, "Gitus Ready Check failed: %s\n", err.Error())
+			// an installed database with a mismatched schema should
+			// not trigger the installer.
+			if errors.Is(err, db.ErrSchemaTooOld) || errors.Is(err, db.ErrSchemaTooNew) {
+				os.Exit(1)
+			}
 			// NOTE(2026.2.14): deprecation of cli installer
 			// InstallGitus(context)
 			WebInstaller()
@@ -210,6 +282,15 @@ func main() {
 			}
 			HandleSSHLogin(&context, mainCall[1], mainCall[2])
 			return
+		case "migrate":
+			fmt.Fprintf(os.Stderr, "`gitus migrate` is only available in forge mode.\n")
+			os.Exit(1)
+		case "backup":
+			os.Exit(HandleBackup(&context, mainCall[1:]))
+		case "quota":
+			os.Exit(HandleQuota(&context, mainCall[1:]))
+		case "ssh-server":
+			os.Exit(HandleSSHServer(context.Config, mainCall[1:]))
 		case "no-login":
 			fmt.Println(context.Config.NoInteractiveShellMessage)
 			return
@@ -261,19 +342,79 @@ func main() {
 		WriteTimeout: 60 * time.Second,
 		IdleTimeout: 120 * time.Second,
 	}
+	newConn := newNewConnTracker()
+	server.ConnState = newConn.hook
 
 	context.RateLimiter = routes.NewRateLimiter(config)
 	
+	var metricsServer *http.Server
+	if config.Metrics.Enable {
+		if config.OperationMode != gitus.OP_MODE_FORGE {
+			log.Printf("Metrics is only available in forge mode.\n")
+		} else {
+			metricsServer = setupMetrics(&context)
+		}
+	}
+
+	reloader, err := routes.NewConfigReloader(&context)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to set up config reloading: %s\n", err.Error())
+		os.Exit(1)
+	}
+	context.ConfigReloader = reloader
+	go reloader.Watch()
+
+	// see docs/repo-maintenance.org.
+	if config.OperationMode == gitus.OP_MODE_FORGE {
+		context.Maintenance = maintenance.NewScheduler(config, context.DatabaseInterface)
+		context.Maintenance.Start()
+	}
+
 	controller.InitializeRoute(&context)
 
+	var sshServer *sshserver.Server
+	if config.SSHServer.Enable {
+		if config.OperationMode != gitus.OP_MODE_FORGE {
+			log.Printf("The built-in SSH server is only available in forge mode.\n")
+		} else {
+			sshServer, err = sshserver.NewServer(config, context.DatabaseInterface)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to start built-in SSH server: %s\n", err.Error())
+				os.Exit(1)
+			}
+			sshListener, err := handover.Listen(handover.LISTENER_SSH, fmt.Sprintf("%s:%d", config.SSHServer.BindAddress, config.SSHServer.BindPort))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to start built-in SSH server: %s\n", err.Error())
+				os.Exit(1)
+			}
+			go func() {
+				log.Printf("Start serving SSH at %s:%d\n", config.SSHServer.BindAddress, config.SSHServer.BindPort)
+				err := sshServer.Serve(sshListener)
+				if err != sshserver.ErrServerClosed {
+					log.Fatalf("SSH server error: %v", err)
+				}
+			}()
+		}
+	}
+
+	// the listener could be passed from systemd or the previous
+	// process; see docs/shutdown.org.
+	listener, err := handover.Listen(handover.LISTENER_WEB, server.Addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to listen at %s: %s\n", server.Addr, err.Error())
+		os.Exit(1)
+	}
 	go func() {
 		log.Printf("Start serving at %s:%d\n", config.BindAddress, config.BindPort)
-		err := server.ListenAndServe()
-		if err != http.ErrServerClosed {
+		err := server.Serve(listener)
+		// the listener is closed before `server.Shutdown` when
+		// shutting down; see shutdown.go.
+		if err != http.ErrServerClosed && !errors.Is(err, net.ErrClosed) {
 			log.Fatalf("HTTP server error: %v", err)
 		}
 		log.Println("Stopped serving new connections.")
 	}()
+	handover.NotifyReady()
 
 	// apparently go kills absolutely everything when main returns -
 	// all the goroutines and things would be just gone and not even
@@ -288,15 +429,76 @@ func main() {
 	// we would still have a chance to wrap things up.
 	// this is also used for the webinstaller since it's also a http
 	// server as well.
+	// SIGHUP reloads the config (see docs/config-reload.org).
+	// SIGUSR2 starts a new process with the listeners passed to it
+	// before shutting down this one. if the new process fails to
+	// start we keep on serving.
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR2)
+	handedOver := false
+waitSignal:
+	for {
+		switch <-sigChan {
+		case syscall.SIGHUP:
+			log.Println("Received SIGHUP; reloading config...")
+			context.ConfigReloader.Reload()
+		case syscall.SIGUSR2:
+			log.Println("Received SIGUSR2; starting a new process...")
+			p, err := handover.Reexec(REEXEC_READY_TIMEOUT)
+			if err != nil {
+				log.Printf("Failed to hand over to a new process: %s\n", err.Error())
+				continue
+			}
+			log.Printf("New process %d is ready.\n", p.Pid)
+			handedOver = true
+			break waitSignal
+		default:
+			break waitSignal
+		}
+	}
+	signal.Stop(sigChan)
+	if context.Maintenance != nil { context.Maintenance.Stop() }
 
-	shutdownCtx, shutdownRelease := gocontext.WithTimeout(gocontext.Background(), 10*time.Second)
+	shutdownTimeout := config.ProperShutdownTimeout()
+	log.Printf("Shutting down; waiting for in-flight requests for at most %s...\n", shutdownTimeout)
+	shutdownCtx, shutdownRelease := gocontext.WithTimeout(gocontext.Background(), shutdownTimeout)
 	defer shutdownRelease()
 
-	if err := server.Shutdown(shutdownCtx); err != nil {
-		log.Fatalf("HTTP shutdown err: %v", err.Error())
+	// the servers stop accepting new connections at once & are
+	// shut down in parallel so that they share the same deadline.
+	var shutdownWg sync.WaitGroup
+	shutdownWg.Add(1)
+	go func() {
+		defer shutdownWg.Done()
+		listener.Close()
+		newConn.wait(shutdownCtx)
+		if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Printf("HTTP shutdown err: %v", err.Error())
+			server.Close()
+		}
+	}()
+	if sshServer != nil {
+		shutdownWg.Add(1)
+		go func() {
+			defer shutdownWg.Done()
+			if err := sshServer.Shutdown(shutdownCtx); err != nil {
+				log.Printf("SSH shutdown err: %v", err.Error())
+			}
+		}()
+	}
+	if metricsServer != nil {
+		shutdownWg.Add(1)
+		go func() {
+			defer shutdownWg.Done()
+			if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+				log.Printf("Metrics server shutdown err: %v", err.Error())
+				metricsServer.Close()
+			}
+		}()
+	}
+	shutdownWg.Wait()
+	if err := drain.WaitAll(shutdownCtx); err != nil {
+		log.Printf("Background tasks not finished in time; remaining git subprocesses killed.\n")
 	}
 
 	if context.DatabaseInterface != nil {
@@ -315,7 +517,7 @@ func main() {
 		}
 	}
 
-	if context.Config.IsInHostMode() {
+	if context.Config.IsInHostMode() && !handedOver {
 		os.RemoveAll(path.Join(gitUser.HomeDir, "gitus.sock"))
 	}
 	
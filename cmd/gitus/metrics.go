@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/handover"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/metrics"
+	"github.com/GitusCodeForge/Gitus/routes"
+)
+
+// wraps the database & session store so that their errors are
+// counted, and sets up the `/metrics` endpoint. returns the separate
+// metrics server if there's one; the caller is responsible for
+// shutting it down. see docs/metrics.org.
+func setupMetrics(ctx *routes.RouterContext) *http.Server {
+	cfg := ctx.Config.Metrics
+	if ctx.DatabaseInterface != nil {
+		ctx.DatabaseInterface = metrics.HookDatabaseInterface(ctx.DatabaseInterface)
+		metrics.RegisterDatabaseGauge(ctx.DatabaseInterface)
+	}
+	if ctx.SessionInterface != nil {
+		ctx.SessionInterface = metrics.HookSessionStore(ctx.SessionInterface)
+	}
+	handler := metrics.Handler(cfg.BearerToken)
+	if cfg.BindPort == 0 {
+		http.Handle("GET /metrics", handler)
+		return nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", handler)
+	server := &http.Server{
+		Addr: fmt.Sprintf("%s:%d", cfg.BindAddress, cfg.BindPort),
+		Handler: mux,
+		ReadTimeout: 30 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+	l, err := handover.Listen(handover.LISTENER_METRICS, server.Addr)
+	if err != nil {
+		log.Fatalf("Metrics server error: %v", err)
+	}
+	go func() {
+		log.Printf("Start serving metrics at %s:%d\n", cfg.BindAddress, cfg.BindPort)
+		err := server.Serve(l)
+		if err != http.ErrServerClosed {
+			log.Fatalf("Metrics server error: %v", err)
+		}
+	}()
+	return server
+}
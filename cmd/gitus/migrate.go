@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	"github.com/GitusCodeForge/Gitus/routes"
+)
+
+// gitus migrate [status|up|dry-run]
+// see docs/migration.org.
+func HandleMigrate(ctx *routes.RouterContext, args []string) int {
+	if ctx.Config.OperationMode != gitus.OP_MODE_FORGE {
+		fmt.Fprintf(os.Stderr, "Configuration not in forge mode; there's no database to migrate.\n")
+		return 1
+	}
+	dbif := ctx.DatabaseInterface
+	command := "status"
+	if len(args) > 0 { command = args[0] }
+	v, err := dbif.GetSchemaVersion()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to retrieve schema version: %s\n", err.Error())
+		return 1
+	}
+	if v <= 0 {
+		fmt.Fprintf(os.Stderr, "The database is not installed. Please run `gitus install` first.\n")
+		return 1
+	}
+	migrationList := dbif.GetMigrationList()
+	latest := db.LatestSchemaVersion(migrationList)
+	pending, err := db.GetPendingMigration(dbif)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to retrieve pending migrations: %s\n", err.Error())
+		return 1
+	}
+	switch command {
+	case "status":
+		fmt.Printf("Database schema version: %d\n", v)
+		fmt.Printf("Latest schema version: %d\n", latest)
+		for _, m := range migrationList {
+			mark := "applied"
+			if m.Version > v { mark = "pending" }
+			fmt.Printf("  %4d  %-8s %s\n", m.Version, mark, m.Description)
+		}
+		if v > latest {
+			fmt.Printf("The database has been migrated by a newer version of Gitus. Please upgrade Gitus.\n")
+		}
+	case "dry-run":
+		if len(pending) <= 0 {
+			fmt.Printf("Database is up to date (version %d).\n", v)
+			return 0
+		}
+		for _, m := range pending {
+			fmt.Printf("-- migration %d: %s\n", m.Version, m.Description)
+			for _, stmt := range m.Statement {
+				fmt.Printf("%s;\n", strings.TrimSpace(stmt))
+			}
+			fmt.Println()
+		}
+	case "up":
+		if v > latest {
+			fmt.Fprintf(os.Stderr, "The database (version %d) is newer than what this version of Gitus supports (version %d). Please upgrade Gitus.\n", v, latest)
+			return 1
+		}
+		if len(pending) <= 0 {
+			fmt.Printf("Database is up to date (version %d).\n", v)
+			return 0
+		}
+		applied, err := dbif.Migrate()
+		for _, m := range applied {
+			fmt.Printf("Applied migration %d: %s\n", m.Version, m.Description)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			return 1
+		}
+		fmt.Printf("Database is now at version %d.\n", latest)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command for `gitus migrate`: %s. (should be one of status, up & dry-run)\n", command)
+		return 1
+	}
+	return 0
+}
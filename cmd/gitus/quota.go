@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/quota"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/ssh"
+	"github.com/GitusCodeForge/Gitus/routes"
+)
+
+// gitus quota [refresh|pre-receive {repo}]
+// see docs/quota.org.
+func HandleQuota(ctx *routes.RouterContext, args []string) int {
+	if ctx.Config.OperationMode != gitus.OP_MODE_FORGE {
+		fmt.Fprintf(os.Stderr, "Quotas are only available in forge mode.\n")
+		return 1
+	}
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "Usage: gitus quota [refresh|pre-receive {repo}]\n")
+		return 1
+	}
+	switch args[0] {
+	case "refresh":
+		return handleQuotaRefresh(ctx)
+	case "pre-receive":
+		if len(args) < 2 {
+			fmt.Fprintf(os.Stderr, "Error format for `gitus quota pre-receive`.\n")
+			return 1
+		}
+		return handleQuotaPreReceive(ctx, args[1])
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command for `gitus quota`: %s\n", args[0])
+	return 1
+}
+
+// measures all the repositories. the sizes are otherwise only updated
+// after pushes & maintenance runs, so this is meant to be run after
+// quotas are enabled on an existing instance.
+func handleQuotaRefresh(ctx *routes.RouterContext) int {
+	var pageNum int64 = 0
+	const pageSize = 100
+	failed := false
+	for {
+		repoList, err := ctx.DatabaseInterface.GetAllRepositories(pageNum, pageSize)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to retrieve repositories: %s\n", err.Error())
+			return 1
+		}
+		for _, repo := range repoList {
+			size, err := quota.RefreshRepositorySize(ctx.Config, ctx.DatabaseInterface, repo.Namespace, repo.Name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to measure %s: %s\n", repo.FullName(), err.Error())
+				failed = true
+				continue
+			}
+			fmt.Printf("%s\t%s\n", repo.FullName(), quota.FormatSize(size))
+		}
+		if len(repoList) < pageSize { break }
+		pageNum += 1
+	}
+	if failed { return 1 }
+	return 0
+}
+
+// run by the pre-receive hook installed by quota.PrepareReceivePack.
+// the objects of the push are in the quarantine directory (which is
+// inside the repository) at this point, so the size of the repository
+// w/ the push can be measured before the refs are updated.
+func handleQuotaPreReceive(ctx *routes.RouterContext, repoFullName string) int {
+	cfg := ctx.Config
+	dbif := ctx.DatabaseInterface
+	// deleting refs never takes more space.
+	onlyDeletion := true
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		s := strings.Fields(scanner.Text())
+		if len(s) < 3 { continue }
+		if strings.Trim(s[1], "0") != "" { onlyDeletion = false }
+	}
+	if onlyDeletion { return 0 }
+	if p := os.Getenv("GIT_QUARANTINE_PATH"); p != "" {
+		incoming, err := quota.MeasureDirectory(p)
+		if err == nil && incoming <= 0 { return 0 }
+	}
+	ns, name, err := ssh.ParseRepositoryPath(cfg, repoFullName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "*** Failed to check storage quota: %s\n", err.Error())
+		return 1
+	}
+	repo, err := dbif.GetRepositoryByName(ns, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "*** Failed to check storage quota: %s\n", err.Error())
+		return 1
+	}
+	newSize, err := quota.MeasureDirectory(path.Join(cfg.GitRoot, ns, name))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "*** Failed to check storage quota: %s\n", err.Error())
+		return 1
+	}
+	oldSize, err := dbif.GetRepositorySize(ns, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "*** Failed to check storage quota: %s\n", err.Error())
+		return 1
+	}
+	err = quota.Check(cfg, dbif, ns, repo.Owner, &model.QuotaUsage{
+		RepositorySize: newSize - oldSize,
+	})
+	if err != nil {
+		var qe *quota.ErrQuotaExceeded
+		if errors.As(err, &qe) {
+			slog.Info("quota: push rejected", "repository", repo.FullName(), "reason", err.Error())
+			fmt.Fprintf(os.Stderr, "*** %s\n", err.Error())
+		} else {
+			fmt.Fprintf(os.Stderr, "*** Failed to check storage quota: %s\n", err.Error())
+		}
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	dbinit "github.com/GitusCodeForge/Gitus/pkg/gitus/db/init"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/receipt"
+	rsinit "github.com/GitusCodeForge/Gitus/pkg/gitus/receipt/init"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/ssh"
+)
+
+// see backup.go & docs/backup.org.
+
+var ErrInvalidBackup = errors.New("Invalid backup archive")
+
+type restorer struct {
+	config *gitus.GitusConfig
+	manifest *BackupManifest
+	dbif db.GitusDatabaseInterface
+	rsif receipt.GitusReceiptSystemInterface
+	// the repositories that have been initialized.
+	initialized map[string]bool
+}
+
+func writeRestoredFile(root string, rel string, mode int64, r io.Reader) error {
+	p := path.Join(root, rel)
+	if !db.IsSubDir(root, p) { return db.ErrInvalidLocation }
+	err := os.MkdirAll(path.Dir(p), os.ModeDir|0755)
+	if err != nil { return err }
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(mode).Perm())
+	if err != nil { return err }
+	defer f.Close()
+	_, err = io.Copy(f, r)
+	return err
+}
+
+// finds the repository an archive entry belongs to. returns the
+// repository path & the path of the entry within it.
+func (rs *restorer) findRepository(name string) (string, string) {
+	s := strings.TrimPrefix(name, BACKUP_REPOSITORY_PREFIX)
+	for _, k := range rs.manifest.RepositoryList {
+		if strings.HasPrefix(s, k + "/") { return k, strings.TrimPrefix(s, k + "/") }
+	}
+	return "", ""
+}
+
+func (rs *restorer) initRepository(rel string) (string, error) {
+	p := path.Join(rs.config.GitRoot, rel)
+	if !db.IsSubDir(rs.config.GitRoot, p) { return "", db.ErrInvalidLocation }
+	if rs.initialized[rel] { return p, nil }
+	if l, err := os.ReadDir(p); err == nil && len(l) > 0 {
+		return "", fmt.Errorf("%s already exists and is not empty", p)
+	}
+	err := os.MkdirAll(p, os.ModeDir|0755)
+	if err != nil { return "", err }
+	cmd := exec.Command("git", "init", "--bare", "--quiet")
+	cmd.Dir = p
+	out, err := cmd.CombinedOutput()
+	if err != nil { return "", fmt.Errorf("Failed to initialize %s: %s", rel, string(out)) }
+	rs.initialized[rel] = true
+	fmt.Printf("Restoring repository %s...\n", rel)
+	return p, nil
+}
+
+func (rs *restorer) restoreRepositoryEntry(h *tar.Header, r io.Reader) error {
+	rel, sub := rs.findRepository(h.Name)
+	if len(rel) <= 0 { return fmt.Errorf("%w: unexpected entry %s", ErrInvalidBackup, h.Name) }
+	p, err := rs.initRepository(rel)
+	if err != nil { return err }
+	if strings.HasPrefix(sub, BACKUP_META_DIR) {
+		return writeRestoredFile(p, strings.TrimPrefix(sub, BACKUP_META_DIR), h.Mode, r)
+	}
+	if sub != BACKUP_BUNDLE_NAME { return fmt.Errorf("%w: unexpected entry %s", ErrInvalidBackup, h.Name) }
+	tmp, err := os.CreateTemp("", "gitus-restore-*.bundle")
+	if err != nil { return err }
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, r)
+	tmp.Close()
+	if err != nil { return err }
+	cmd := exec.Command("git", "fetch", "--quiet", "--update-head-ok", tmp.Name(), "+refs/*:refs/*")
+	cmd.Dir = p
+	out, err := cmd.CombinedOutput()
+	if err != nil { return fmt.Errorf("Failed to fetch from bundle for %s: %s", rel, string(out)) }
+	return nil
+}
+
+func (rs *restorer) restoreEntry(h *tar.Header, r io.Reader) error {
+	if h.Typeflag != tar.TypeReg { return nil }
+	switch {
+	case h.Name == BACKUP_DATABASE:
+		if rs.dbif == nil { return fmt.Errorf("%w: unexpected database dump", ErrInvalidBackup) }
+		fmt.Printf("Importing database...\n")
+		return rs.dbif.ImportDump(db.NewDumpReader(r))
+	case h.Name == BACKUP_RECEIPT:
+		if rs.rsif == nil { return fmt.Errorf("%w: unexpected receipts", ErrInvalidBackup) }
+		dec := json.NewDecoder(r)
+		for {
+			robj := new(receipt.Receipt)
+			err := dec.Decode(robj)
+			if err == io.EOF { return nil }
+			if err != nil { return err }
+			if err = rs.rsif.ImportReceipt(robj); err != nil { return err }
+		}
+	case h.Name == BACKUP_AUTHORIZED_KEYS:
+		managed := make(map[string]map[string]string, 0)
+		if err := json.NewDecoder(r).Decode(&managed); err != nil { return err }
+		keyctx, err := ssh.ToContext(rs.config)
+		if err != nil { return err }
+		for username, pack := range managed {
+			for keyname, key := range pack {
+				keyctx.AddAuthorizedKey(username, keyname, key)
+			}
+		}
+		fmt.Printf("Restoring authorized_keys...\n")
+		return keyctx.Sync()
+	case strings.HasPrefix(h.Name, BACKUP_REPOSITORY_PREFIX):
+		return rs.restoreRepositoryEntry(h, r)
+	case strings.HasPrefix(h.Name, BACKUP_SNIPPET_PREFIX):
+		if len(rs.config.SnippetRoot) <= 0 { return nil }
+		return writeRestoredFile(rs.config.SnippetRoot, strings.TrimPrefix(h.Name, BACKUP_SNIPPET_PREFIX), h.Mode, r)
+	case strings.HasPrefix(h.Name, BACKUP_LFS_PREFIX):
+		if len(rs.config.ProperLFSRoot()) <= 0 { return nil }
+		return writeRestoredFile(rs.config.ProperLFSRoot(), strings.TrimPrefix(h.Name, BACKUP_LFS_PREFIX), h.Mode, r)
+	}
+	return fmt.Errorf("%w: unexpected entry %s", ErrInvalidBackup, h.Name)
+}
+
+// the database & the receipt system must be empty; the tables are
+// installed here.
+func (rs *restorer) prepareForgeMode() error {
+	dbif, err := dbinit.InitializeDatabase(rs.config)
+	if err != nil { return fmt.Errorf("Failed to load database: %w", err) }
+	rs.dbif = dbif
+	v, err := dbif.GetSchemaVersion()
+	if err != nil { return err }
+	if v > 0 { return errors.New("The database is already installed; restoring requires an empty database") }
+	latest := db.LatestSchemaVersion(dbif.GetMigrationList())
+	if rs.manifest.SchemaVersion > latest || rs.manifest.DumpFormatVersion > db.DUMP_FORMAT_VERSION {
+		return errors.New("The backup is made by a newer version of Gitus; please upgrade Gitus")
+	}
+	if err = dbif.InstallTables(); err != nil { return fmt.Errorf("Failed to install tables: %w", err) }
+	rsif, err := rsinit.InitializeReceiptSystem(rs.config)
+	if err != nil { return fmt.Errorf("Failed to load receipt system: %w", err) }
+	rs.rsif = rsif
+	b, err := rsif.IsReceiptSystemUsable()
+	if err != nil { return err }
+	if !b {
+		if err = rsif.Install(); err != nil { return fmt.Errorf("Failed to install receipt system: %w", err) }
+	}
+	return nil
+}
+
+func (rs *restorer) dispose() {
+	if rs.dbif != nil { rs.dbif.Dispose() }
+	if rs.rsif != nil { rs.rsif.Dispose() }
+}
+
+func restoreBackup(configPath string, f io.Reader) error {
+	gr, err := gzip.NewReader(f)
+	if err != nil { return err }
+	tr := tar.NewReader(gr)
+
+	h, err := tr.Next()
+	if err != nil || h.Name != BACKUP_MANIFEST { return ErrInvalidBackup }
+	manifest := new(BackupManifest)
+	if err = json.NewDecoder(tr).Decode(manifest); err != nil { return ErrInvalidBackup }
+	if manifest.FormatVersion > BACKUP_FORMAT_VERSION {
+		return errors.New("The backup is made by a newer version of Gitus; please upgrade Gitus")
+	}
+
+	// the config in the backup is only used when there isn't one
+	// already, so that the restored instance can be set up
+	// differently, e.g. with another database.
+	h, err = tr.Next()
+	if err != nil || h.Name != BACKUP_CONFIG { return ErrInvalidBackup }
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		if err = writeRestoredFile(path.Dir(configPath), path.Base(configPath), 0600, tr); err != nil { return err }
+		fmt.Printf("Configuration file restored to %s.\n", configPath)
+	} else {
+		fmt.Printf("Using the existing configuration file at %s.\n", configPath)
+	}
+	cfg, err := gitus.LoadConfigFile(configPath)
+	if err != nil { return fmt.Errorf("Failed to load configuration file: %w", err) }
+	if cfg.OperationMode != manifest.OperationMode {
+		return fmt.Errorf("The backup is made in %s mode but the configuration is in %s mode", manifest.OperationMode, cfg.OperationMode)
+	}
+
+	rs := &restorer{
+		config: cfg,
+		manifest: manifest,
+		initialized: make(map[string]bool, 0),
+	}
+	defer rs.dispose()
+	if cfg.OperationMode == gitus.OP_MODE_FORGE {
+		if err = rs.prepareForgeMode(); err != nil { return err }
+	}
+	if err = os.MkdirAll(cfg.GitRoot, os.ModeDir|0755); err != nil { return err }
+	for {
+		h, err := tr.Next()
+		if err == io.EOF { break }
+		if err != nil { return err }
+		if err = rs.restoreEntry(h, tr); err != nil { return err }
+	}
+	for _, rel := range manifest.RepositoryList {
+		if !rs.initialized[rel] {
+			return fmt.Errorf("%w: repository %s is missing", ErrInvalidBackup, rel)
+		}
+	}
+	return nil
+}
+
+// runs before the config file is loaded since there might not be one
+// on a fresh machine.
+func HandleRestore(configPath string, args []string) int {
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "Usage: gitus -config [config] restore [archive]\n")
+		return 1
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open archive: %s\n", err.Error())
+		return 1
+	}
+	defer f.Close()
+	err = restoreBackup(configPath, f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Restore failed: %s\n", err.Error())
+		return 1
+	}
+	fmt.Printf("Restore complete.\n")
+	return 0
+}
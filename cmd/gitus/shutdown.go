@@ -0,0 +1,60 @@
+package main
+
+import (
+	gocontext "context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// `http.Server.Shutdown` drops the connections that are accepted but
+// haven't sent their first request yet without responding, which
+// shows up as failed requests during a handover. to avoid this the
+// listener is closed first & we wait for these connections to become
+// active before calling Shutdown. see docs/shutdown.org.
+
+// the same as what `http.Server.Shutdown` uses for considering a new
+// connection idle.
+const NEW_CONN_GRACE_PERIOD = 5 * time.Second
+
+type newConnTracker struct {
+	lock sync.Mutex
+	conn map[net.Conn]bool
+}
+
+func newNewConnTracker() *newConnTracker {
+	return &newConnTracker{ conn: make(map[net.Conn]bool) }
+}
+
+// should be used as `http.Server.ConnState`.
+func (t *newConnTracker) hook(c net.Conn, state http.ConnState) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if state == http.StateNew {
+		t.conn[c] = true
+	} else {
+		delete(t.conn, c)
+	}
+}
+
+func (t *newConnTracker) count() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return len(t.conn)
+}
+
+// waits until there's no new connection left, `ctx` is done or
+// NEW_CONN_GRACE_PERIOD has passed.
+func (t *newConnTracker) wait(ctx gocontext.Context) {
+	deadline := time.Now().Add(NEW_CONN_GRACE_PERIOD)
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for t.count() > 0 && time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
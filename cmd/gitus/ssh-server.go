@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/sshserver"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// gitus ssh-server fingerprint
+// gitus ssh-server rotate-host-key [path]
+// see docs/ssh-server.org.
+
+func printHostKeyFingerprint(cfg *gitus.GitusConfig) int {
+	for _, p := range cfg.ProperSSHServerHostKeyPath() {
+		signer, err := sshserver.LoadHostKey(p)
+		if os.IsNotExist(err) {
+			fmt.Printf("%s: (not generated yet)\n", p)
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load %s: %s\n", p, err.Error())
+			return 1
+		}
+		fmt.Printf("%s: %s %s\n", p, signer.PublicKey().Type(), gossh.FingerprintSHA256(signer.PublicKey()))
+	}
+	return 0
+}
+
+func rotateHostKey(cfg *gitus.GitusConfig, args []string) int {
+	pathList := cfg.ProperSSHServerHostKeyPath()
+	if len(args) > 0 {
+		// only the configured keys can be rotated since the others
+		// aren't used by the server anyway.
+		found := false
+		for _, p := range pathList {
+			if p == args[0] || p == resolveHostKeyArg(cfg, args[0]) {
+				pathList = []string{p}
+				found = true
+				break
+			}
+		}
+		if !found {
+			fmt.Fprintf(os.Stderr, "%s is not a configured host key.\n", args[0])
+			return 1
+		}
+	}
+	for _, p := range pathList {
+		old, signer, err := sshserver.RotateHostKey(p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to rotate %s: %s\n", p, err.Error())
+			return 1
+		}
+		fmt.Printf("%s:\n", p)
+		if old != nil {
+			fmt.Printf("  old: %s %s (kept at %s.old)\n", old.PublicKey().Type(), gossh.FingerprintSHA256(old.PublicKey()), p)
+		}
+		fmt.Printf("  new: %s %s\n", signer.PublicKey().Type(), gossh.FingerprintSHA256(signer.PublicKey()))
+	}
+	fmt.Printf("The running server picks up the new keys with the next connection.\n")
+	return 0
+}
+
+// host key paths given on the command line are resolved the same way
+// as the ones in the config.
+func resolveHostKeyArg(cfg *gitus.GitusConfig, p string) string {
+	if path.IsAbs(p) { return p }
+	return path.Join(path.Dir(cfg.FilePath), p)
+}
+
+func HandleSSHServer(cfg *gitus.GitusConfig, args []string) int {
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "Usage: gitus -config [config] ssh-server [fingerprint|rotate-host-key] [key-path]\n")
+		return 1
+	}
+	switch args[0] {
+	case "fingerprint":
+		return printHostKeyFingerprint(cfg)
+	case "rotate-host-key":
+		return rotateHostKey(cfg, args[1:])
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command for `gitus ssh-server`: %s\n", args[0])
+	return 1
+}
@@ -1,14 +1,17 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path"
-	"strings"
 
-	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/lfs"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/pullrequest"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/quota"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/ssh"
 	"github.com/GitusCodeForge/Gitus/pkg/gitlib"
 	"github.com/GitusCodeForge/Gitus/pkg/shellparse"
@@ -22,39 +25,14 @@ func printGitError(s string) {
 }
 
 func parseTargetRepositoryName(ctx *routes.RouterContext, relPath string) (string, string) {
-	if relPath[0] == '~' || relPath[0] == '/' {
-		relPath = relPath[1:]
-	}
-	relPathSegment := strings.SplitN(relPath, "/", 2)
-	namespaceName := ""
-	repositoryName := ""
-	if ctx.Config.UseNamespace {
-		if len(relPathSegment) <= 1 {
-			relPathSegment = strings.SplitN(relPath, ":", 2)
-			if len(relPathSegment) <= 1 {
-				printGitError("Invalid repository path specification.")
-				os.Exit(1)
-			}
-			namespaceName = relPathSegment[0]
-			repositoryName = relPathSegment[1]
-		}
-		namespaceName = relPathSegment[0]
-		repositoryName = relPathSegment[1]
-	} else {
-		if len(relPathSegment) > 1 {
-			printGitError("Invalid repository path specification.")
-			os.Exit(1)
-		}
-		namespaceName = ""
-		repositoryName = relPathSegment[0]
+	namespaceName, repositoryName, err := ssh.ParseRepositoryPath(ctx.Config, relPath)
+	if err != nil {
+		printGitError(err.Error())
+		os.Exit(1)
 	}
 	return namespaceName, repositoryName
 }
 
-func isValidGitSSHCommand(s string) bool {
-	return (s == "git-upload-pack" || s == "git-receive-pack" || s == "git-upload-archive")
-}
-
 func handleSSHHostMode(ctx *routes.RouterContext, username string, keyname string) {
 	if ctx.SSHKeyManagingContext == nil {
 		sshCtx, err := ssh.ToContext(ctx.Config)
@@ -72,10 +50,15 @@ func handleSSHHostMode(ctx *routes.RouterContext, username string, keyname strin
 	}
 	// need to have a guard here or else normal users might get to
 	// execute commands as the git user due to incorrect acl config.
-	if !isValidGitSSHCommand(parsedOrigCmd[0]) {
+	if !ssh.IsValidGitSSHCommand(parsedOrigCmd[0]) {
 		printGitError("Invalid SSH command")
 		os.Exit(1)
 	}
+	// git lfs is only available in forge mode.
+	if parsedOrigCmd[0] == lfs.SSH_AUTHENTICATE_COMMAND {
+		printGitError(ssh.ErrLFSNotEnabled.Error())
+		os.Exit(1)
+	}
 	isPushingToRemote := parsedOrigCmd[0] == "git-receive-pack"
 	
 	relPath := parsedOrigCmd[len(parsedOrigCmd)-1]
@@ -138,13 +121,8 @@ func HandleSSHLogin(ctx *routes.RouterContext, username string, keyname string)
 		handleSSHHostMode(ctx, username, keyname)
 		return
 	}
-	if ctx.Config.IsInBrowseOnlyMode() {
-		printGitError("This instance of Gitus is in Browse-Only Mode which does not allow Git over SSH.")
-		os.Exit(1)
-	}
-	if ctx.Config.GlobalVisibility != gitus.GLOBAL_VISIBILITY_PUBLIC &&
-		ctx.Config.GlobalVisibility != gitus.GLOBAL_VISIBILITY_PRIVATE {
-		printGitError("This instance of Gitus is currently unavailable.")
+	if err := ssh.CheckGitSSHAvailable(ctx.Config); err != nil {
+		printGitError(err.Error())
 		os.Exit(1)
 	}
 	m, err := ctx.DatabaseInterface.GetAuthKeyByName(username, keyname)
@@ -157,114 +135,40 @@ func HandleSSHLogin(ctx *routes.RouterContext, username string, keyname string)
 		printGitError(fmt.Sprintf("Integrity check failed:\n auth: %s\nkt: %s", authorizedKey, m.KeyText))
 		os.Exit(1)
 	}
-	origCmd := os.Getenv("SSH_ORIGINAL_COMMAND")
-	// one might be tempted to think that one can just pass SSH_ORIGINAL_COMMAND
-	// to exec.Command, but things don't work that way...
-	parsedOrigCmd := shellparse.ParseShellCommand(origCmd)
-	if len(parsedOrigCmd) <= 0 {
-		printGitError("Invalid SSH command")
-		os.Exit(1)
-	}
-	// need to have a guard here or else normal users might get to
-	// execute commands as the git user due to incorrect acl config.
-	if !isValidGitSSHCommand(parsedOrigCmd[0]) {
-		printGitError("Invalid SSH command")
-		os.Exit(1)
-	}
-	
-	isPushingToRemote := parsedOrigCmd[0] == "git-receive-pack"
-	relPath := parsedOrigCmd[len(parsedOrigCmd)-1]
-	namespaceName, repositoryName := parseTargetRepositoryName(ctx, relPath)
-
-	// check acl.
-	r, err := ctx.DatabaseInterface.GetRepositoryByName(namespaceName, repositoryName)
-	if err != nil {
-		printGitError(fmt.Sprintf("Failed while reading ACL: %s.", err.Error()))
-		os.Exit(1)
-	}
-	if r.Status == model.REPO_ARCHIVED && isPushingToRemote {
-		printGitError(fmt.Sprintf("The repository %s/%s is ARCHIVED; no push to remote is allowed. ", namespaceName, repositoryName))
-		os.Exit(1)
-	}
-	ns, err := ctx.DatabaseInterface.GetNamespaceByName(namespaceName)
+	gitCmd, err := ssh.ResolveGitCommand(ctx.Config, ctx.DatabaseInterface, username, os.Getenv("SSH_ORIGINAL_COMMAND"))
 	if err != nil {
-		printGitError(fmt.Sprintf("Failed while reading namespace: %s.", err.Error()))
+		slog.Warn("ssh: command rejected", "user", username, "key", keyname, "error", err)
+		printGitError(err.Error())
 		os.Exit(1)
 	}
-	// public visibility + public repo + clone: yes
-	// public visibility + public repo + push: ns push / repo push
-	// public visibility + internal repo + clone: user
-	// public visibility + internal repo + push: ns push / repo push
-	// public visibility + limited repo + clone: ns any / repo any
-	// public visibility + limited repo + push: ns push / repo push
-	// public visibility + private repo + clone: repo any
-	// public visibility + private repo + push: repo push
-	// private visibility + public/internal repo + clone: user
-	// private visibility + public/internal repo + push: ns push / repo push
-	// private visibility + limited repo + clone: ns any / repo any
-	// private visibility + limited repo + push: ns push / repo push
-	// private visibility + private repo + clone: repo any
-	// private visibility + private repo + push: repo push
-	// shutdown visibility + any repo + clone/push: reject
-	// maintenance visibility + any repo + clone/push: reject
-	
-	// user check is to check if the key is used by any user.
-	// when we reach here we are in public/private visibility and the repo
-	// is not archived (i.e. push is allowed).
-	isPublicRepo := r.Status == model.REPO_NORMAL_PUBLIC
-	isInternalRepo := r.Status == model.REPO_INTERNAL
-	isLimitedRepo := r.Status == model.REPO_LIMITED
-	isPrivateRepo := r.Status == model.REPO_NORMAL_PRIVATE
-	nsACLCheck := ns.ACL.GetUserPrivilege(ctx.LoginInfo.UserName)
-	isNSOwner := ns.Owner == username
-	isRepoOwner := r.Owner == username
-	isNSAny := isNSOwner || (nsACLCheck != nil)
-	isNSPush := isNSOwner || (isNSAny && nsACLCheck.PushToRepository)
-	repoACLCheck := r.AccessControlList.GetUserPrivilege(ctx.LoginInfo.UserName)
-	isRepoAny := isRepoOwner || (repoACLCheck != nil)
-	isRepoPush := isRepoOwner || (isRepoAny && repoACLCheck.PushToRepository)
-	if isPushingToRemote && (isPublicRepo || isInternalRepo || isLimitedRepo) {
-		if !isNSPush && !isRepoPush {
-			printGitError("Not enough permission.")
-			os.Exit(1)
-		}
-	}
-	if !isPushingToRemote && isLimitedRepo {
-		if !isNSAny && !isRepoAny {
-			printGitError("Not enough permission.")
-			os.Exit(1)
-		}
-	}
-	if isPushingToRemote && isPrivateRepo {
-		if !isRepoPush {
-			printGitError("Not enough permission.")
-			os.Exit(1)
-		}
-	}
-	if !isPushingToRemote && isPrivateRepo {
-		if !isRepoAny {
-			printGitError("Not enough permission.")
+	if gitCmd.LFSOperation != "" {
+		b, err := ssh.ResolveLFSAuthentication(ctx.Config, username, gitCmd)
+		if err != nil {
+			printGitError(err.Error())
 			os.Exit(1)
 		}
+		slog.Info("ssh: git lfs authenticated", "user", username, "key", keyname, "operation", gitCmd.LFSOperation, "repository", gitCmd.Repository.FullName())
+		fmt.Println(string(b))
+		os.Exit(0)
 	}
-
-	// see also:
-	//     https://git-scm.com/docs/git-receive-pack
-	//     https://git-scm.com/docs/git-upload-pack
-	//     https://git-scm.com/docs/git-upload-archive
-	// all commands have the git dir path at the end of the call, so we resolve it
-	// with ctx.Config.
-	realGitPath := path.Join(ctx.Config.GitRoot, r.Namespace, r.Name)
-	parsedOrigCmd[len(parsedOrigCmd)-1] = realGitPath
-	cmdobj := exec.Command(parsedOrigCmd[0], parsedOrigCmd[1:]...)
-
+	slog.Info("ssh: running git command", "user", username, "key", keyname, "service", gitCmd.Command[0], "repository", gitCmd.Repository.FullName())
+	// the request id set up in main is inherited by git & the hooks.
+	cmdobj := exec.Command(gitCmd.Command[0], gitCmd.Command[1:]...)
+	cmdobj.Env = append(os.Environ(), gitCmd.Env...)
 	cmdobj.Stdout = os.Stdout
 	cmdobj.Stdin = os.Stdin
 	cmdobj.Stderr = os.Stderr
 	err = cmdobj.Run()
 	if err != nil {
+		slog.Error("ssh: git command failed", "user", username, "service", gitCmd.Command[0], "error", err)
 		printGitError(err.Error())
 	}
+	if gitCmd.IsPush {
+		_, err = quota.RefreshRepositorySize(ctx.Config, ctx.DatabaseInterface, gitCmd.Repository.Namespace, gitCmd.Repository.Name)
+		if err != nil { slog.Warn("ssh: failed to measure repository", "repository", gitCmd.Repository.FullName(), "error", err) }
+		// git & the hooks inherit the request id from the environment.
+		err = pullrequest.SyncProviderBranch(context.Background(), ctx.Config, ctx.DatabaseInterface, gitCmd.Repository.Namespace, gitCmd.Repository.Name, username)
+		if err != nil { slog.Warn("ssh: failed to update pull requests", "repository", gitCmd.Repository.FullName(), "error", err) }
+	}
 	os.Exit(0)
 }
-
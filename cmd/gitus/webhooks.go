@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"math/big"
 	"net"
 	"net/http"
@@ -98,6 +99,22 @@ func resolveRepoSSHURL(ctx *routes.RouterContext, repo *model.Repository) string
 	return fmt.Sprintf("%s%s", gitSshHostName, sshfn)
 }
 
+// failed deliveries are recorded in the webhook log since there
+// wouldn't be any result report for them. (see docs/metrics.org.)
+func reportWebhookDeliveryFailure(ctx *routes.RouterContext, repo *model.Repository, reqUuid string, reportUuid string, msg string) {
+	slog.Warn("webhook: delivery failed", "repository", repo.FullName(), "webhook_id", reqUuid, "error", msg)
+	printGitError(msg)
+	ctx.DatabaseInterface.UpdateWebhookResult(reqUuid, &model.WebhookResult{
+		UUID: reqUuid,
+		ReportUUID: reportUuid,
+		RepoNamespace: repo.Namespace,
+		RepoName: repo.Name,
+		Status: model.WEBHOOK_RESULT_FAILURE,
+		Message: msg,
+		Timestamp: time.Now().Unix(),
+	})
+}
+
 // NOTE THAT even if any error happens at this part we still need to
 // let the whole program return a success exit code. we can retrigger
 // failed cicd later, but whatever pushed to the depot should be accepted
@@ -276,25 +293,26 @@ func HandleWebHook(ctx *routes.RouterContext, repoFullName string, refFullName s
 	case "json":
 		payloadJson, err := json.Marshal(payload)
 		if err != nil {
-			printGitError(fmt.Sprintf("Failed to serialize webhook to json: %s", err))
+			reportWebhookDeliveryFailure(ctx, repo, reqUuid.String(), reportUuid.String(), fmt.Sprintf("Failed to serialize webhook to json: %s", err))
 			return
 		}
 		rd := bytes.NewReader(payloadJson)
 		req, err = http.NewRequest("POST", repo.WebHookConfig.TargetURL, rd)
 	default:
-		printGitError(fmt.Sprintf("Unsupported webhook payload type: %s", repo.WebHookConfig.PayloadType))
+		reportWebhookDeliveryFailure(ctx, repo, reqUuid.String(), reportUuid.String(), fmt.Sprintf("Unsupported webhook payload type: %s", repo.WebHookConfig.PayloadType))
 		return
 	}
 	req.Header.Add("Authentication", fmt.Sprintf("Bearer webhook-jwt-%s", tokenStr))
 	req.Header.Add("Content-Type", "application/json")
 	resp, err := c.Do(req)
 	if err != nil {
-		printGitError(fmt.Sprintf("Failed while sending HTTP POST request: %s", err))
+		reportWebhookDeliveryFailure(ctx, repo, reqUuid.String(), reportUuid.String(), fmt.Sprintf("Failed while sending HTTP POST request: %s", err))
 		return
 	}
 	if !strings.HasPrefix(resp.Status, "2") {
-		printGitError(fmt.Sprintf("Errorneous HTTP response: %s", resp.Status))
+		reportWebhookDeliveryFailure(ctx, repo, reqUuid.String(), reportUuid.String(), fmt.Sprintf("Errorneous HTTP response: %s", resp.Status))
 		return
 	}
+	slog.Info("webhook: delivered", "repository", repo.FullName(), "webhook_id", reqUuid.String(), "ref", refFullName, "status", resp.Status)
 }
 
@@ -0,0 +1,158 @@
+//go:build ignore
+
+package main
+
+/*
+   error-hook-generating utility. reads an interface definition from a
+   go source file and generates a wrapper type which embeds the
+   interface and calls a hook function whenever a method returns a
+   non-nil error as its last result. currently used for counting
+   database & session store errors for the metrics endpoint (see
+   docs/metrics.org).
+
+   usage:
+
+       go run ./devtools/generate-error-hook.go [interface-name] [source-file] [output-file]
+
+   (the interface name comes first since `go run` would otherwise take
+   the source file as one of the files to run.)
+
+   the wrapper is named `ErrorHooked{interface-name}` and is put in
+   the same package as the interface. methods that don't return an
+   error are not generated since the embedded interface already
+   provides them.
+*/
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"strings"
+)
+
+func typeString(fset *token.FileSet, e ast.Expr) string {
+	var b bytes.Buffer
+	printer.Fprint(&b, fset, e)
+	return b.String()
+}
+
+func isError(e ast.Expr) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == "error"
+}
+
+func main() {
+	if len(os.Args) < 4 {
+		fmt.Fprintf(os.Stderr, "Usage: generate-error-hook [interface-name] [source-file] [output-file]\n")
+		os.Exit(1)
+	}
+	ifName := os.Args[1]
+	srcPath := os.Args[2]
+	outPath := os.Args[3]
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, srcPath, nil, parser.ParseComments)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse %s: %s\n", srcPath, err.Error())
+		os.Exit(1)
+	}
+	var iface *ast.InterfaceType
+	ast.Inspect(f, func(n ast.Node) bool {
+		ts, ok := n.(*ast.TypeSpec)
+		if !ok || ts.Name.Name != ifName { return true }
+		iface, _ = ts.Type.(*ast.InterfaceType)
+		return false
+	})
+	if iface == nil {
+		fmt.Fprintf(os.Stderr, "Interface %s not found in %s\n", ifName, srcPath)
+		os.Exit(1)
+	}
+
+	wrapperName := "ErrorHooked" + ifName
+	var body bytes.Buffer
+	usedImport := make(map[string]bool, 0)
+	markImport := func(e ast.Expr) {
+		ast.Inspect(e, func(n ast.Node) bool {
+			if se, ok := n.(*ast.SelectorExpr); ok {
+				if id, ok := se.X.(*ast.Ident); ok { usedImport[id.Name] = true }
+			}
+			return true
+		})
+	}
+	for _, m := range iface.Methods.List {
+		ft, ok := m.Type.(*ast.FuncType)
+		if !ok || len(m.Names) <= 0 { continue }
+		if ft.Results == nil || len(ft.Results.List) <= 0 { continue }
+		lastResult := ft.Results.List[len(ft.Results.List)-1]
+		if !isError(lastResult.Type) { continue }
+		name := m.Names[0].Name
+		paramList := make([]string, 0)
+		argList := make([]string, 0)
+		i := 0
+		for _, p := range ft.Params.List {
+			markImport(p.Type)
+			t := typeString(fset, p.Type)
+			n := len(p.Names)
+			if n <= 0 { n = 1 }
+			for range n {
+				a := fmt.Sprintf("a%d", i)
+				paramList = append(paramList, fmt.Sprintf("%s %s", a, t))
+				if _, ok := p.Type.(*ast.Ellipsis); ok { a = a + "..." }
+				argList = append(argList, a)
+				i += 1
+			}
+		}
+		resultTypeList := make([]string, 0)
+		resultList := make([]string, 0)
+		j := 0
+		for _, r := range ft.Results.List {
+			markImport(r.Type)
+			t := typeString(fset, r.Type)
+			n := len(r.Names)
+			if n <= 0 { n = 1 }
+			for range n {
+				resultTypeList = append(resultTypeList, t)
+				resultList = append(resultList, fmt.Sprintf("r%d", j))
+				j += 1
+			}
+		}
+		resultType := strings.Join(resultTypeList, ", ")
+		if len(resultTypeList) > 1 { resultType = "(" + resultType + ")" }
+		errName := resultList[len(resultList)-1]
+		fmt.Fprintf(&body, "\nfunc (h *%s) %s(%s) %s {\n", wrapperName, name, strings.Join(paramList, ", "), resultType)
+		fmt.Fprintf(&body, "\t%s := h.%s.%s(%s)\n", strings.Join(resultList, ", "), ifName, name, strings.Join(argList, ", "))
+		fmt.Fprintf(&body, "\tif %s != nil { h.Hook(\"%s\", %s) }\n", errName, name, errName)
+		fmt.Fprintf(&body, "\treturn %s\n}\n", strings.Join(resultList, ", "))
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// generated by devtools/generate-error-hook.go. DO NOT EDIT\n\n")
+	fmt.Fprintf(&out, "package %s\n\n", f.Name.Name)
+	importList := make([]string, 0)
+	for _, imp := range f.Imports {
+		p := strings.Trim(imp.Path.Value, "\"")
+		n := p[strings.LastIndex(p, "/")+1:]
+		if imp.Name != nil { n = imp.Name.Name }
+		if !usedImport[n] { continue }
+		if imp.Name != nil {
+			importList = append(importList, fmt.Sprintf("\t%s %s\n", imp.Name.Name, imp.Path.Value))
+		} else {
+			importList = append(importList, fmt.Sprintf("\t%s\n", imp.Path.Value))
+		}
+	}
+	if len(importList) > 0 {
+		fmt.Fprintf(&out, "import (\n%s)\n\n", strings.Join(importList, ""))
+	}
+	fmt.Fprintf(&out, "// wraps a %s & calls `Hook` with the name of the method\n", ifName)
+	fmt.Fprintf(&out, "// whenever a method returns a non-nil error.\n")
+	fmt.Fprintf(&out, "type %s struct {\n\t%s\n\tHook func(method string, err error)\n}\n", wrapperName, ifName)
+	out.Write(body.Bytes())
+	err = os.WriteFile(outPath, out.Bytes(), 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write %s: %s\n", outPath, err.Error())
+		os.Exit(1)
+	}
+}
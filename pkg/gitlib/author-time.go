@@ -6,6 +6,7 @@ import (
 	"log"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -15,12 +16,14 @@ type AuthorTime struct {
 	Time time.Time
 }
 
+// parses a timezone offset like "+0800" into seconds east of UTC, as
+// used by time.FixedZone.
 func parseTimezoneOffset(s string) (int, error) {
 	if s == "Z" { return 0, nil }
 	if len(s) != 5 { return 0, errors.New("Invalid timezone offset string") }
 	hour := ((int(s[1]) - int('0')) * 10) + (int(s[2]) - int('0'))
 	minute := ((int(s[3]) - int('0')) * 10) + (int(s[4]) - int('0'))
-	total := hour * 60 + minute
+	total := (hour * 60 + minute) * 60
 	if s[0] == '-' { total = -total }
 	return total, nil
 }
@@ -37,7 +40,8 @@ func parseAuthorTime(s string) AuthorTime {
 	if len(matchres) <= 0 {
 		log.Fatalf("Cannot parse author-time: %s\n", s)
 	}
-	res.AuthorName = string(matchres[1])
+	// the name is followed by a space.
+	res.AuthorName = strings.TrimSpace(string(matchres[1]))
 	res.AuthorEmail = string(matchres[2])
 	timeStampString := string(matchres[3])
 	timezoneOffsetString := string(matchres[4])
@@ -54,7 +58,7 @@ func parseAuthorTime(s string) AuthorTime {
 func (at *AuthorTime) String() string {
 	_, i := at.Time.Zone()
 	// i is "seconds east of UTC"...
-	positive := i > 0
+	positive := i >= 0
 	if i < 0 { i = -i }
 	totalMinutes := i / 60
 	hours := totalMinutes / 60
@@ -3,7 +3,7 @@ package gitlib
 import (
 	"bytes"
 	"fmt"
-	"os/exec"
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -17,6 +17,12 @@ type PorcelainBlameCommitInfo struct {
 	CommitterInfo AuthorTime
 	Summary string
 	Filename string
+	// the commit (and the path of the file within that commit) that
+	// git thinks the lines come from before this commit. empty when
+	// this commit is a boundary commit, i.e. the lines are there since
+	// the very beginning.
+	PreviousCommitId string
+	PreviousFilename string
 	OtherHeader map[string]string
 }
 
@@ -29,6 +35,63 @@ type PorcelainBlameLine struct {
 type PorcelainBlame struct {
 	CommitInfo map[string]*PorcelainBlameCommitInfo
 	LineList [][]*PorcelainBlameLine
+	oldestTime time.Time
+	newestTime time.Time
+}
+
+const BLAME_AGE_LEVEL_COUNT = 10
+
+// returns the relative age of the commit among all the commits that
+// appears in the blame, from 0 (the newest) to
+// BLAME_AGE_LEVEL_COUNT-1 (the oldest). used for shading.
+func (pb *PorcelainBlame) AgeLevel(commitId string) int {
+	ci, ok := pb.CommitInfo[commitId]
+	if !ok { return BLAME_AGE_LEVEL_COUNT - 1 }
+	span := pb.newestTime.Sub(pb.oldestTime)
+	if span <= 0 { return 0 }
+	age := pb.newestTime.Sub(ci.AuthorInfo.Time)
+	res := int(int64(age) * BLAME_AGE_LEVEL_COUNT / int64(span))
+	if res >= BLAME_AGE_LEVEL_COUNT { res = BLAME_AGE_LEVEL_COUNT - 1 }
+	if res < 0 { res = 0 }
+	return res
+}
+
+type BlameOption struct {
+	// detect lines moved or copied within the same file. (`-M`)
+	DetectMove bool
+	// detect lines moved or copied from other files that were
+	// modified in the same commit. (`-C`)
+	DetectCopy bool
+	// the content of an ignore-revs file, e.g. `.git-blame-ignore-revs`;
+	// the format is the same as the one required by
+	// `--ignore-revs-file`, i.e. one full commit id per line with `#`
+	// starting a comment. commits listed here are skipped when
+	// assigning blame. empty if nothing is to be ignored.
+	IgnoreRevs string
+}
+
+// the name of the file in the root of a repository that lists the
+// commits that should be ignored by blame. this is the same as the
+// one used by GitHub & GitLab.
+const BLAME_IGNORE_REVS_FILE = ".git-blame-ignore-revs"
+
+// read the `.git-blame-ignore-revs` file at the root of the tree of
+// commit `c`. returns empty string if there isn't one.
+func (gr *LocalGitRepository) ReadBlameIgnoreRevs(c *CommitObject) (string, error) {
+	tobj, err := gr.ReadObject(c.TreeObjId)
+	if err != nil { return "", err }
+	t, ok := tobj.(*TreeObject)
+	if !ok { return "", nil }
+	for _, item := range t.ObjectList {
+		if item.Name != BLAME_IGNORE_REVS_FILE { continue }
+		if item.Mode != TREE_NORMAL_FILE && item.Mode != TREE_EXECUTABLE_FILE { return "", nil }
+		obj, err := gr.ReadObject(item.Hash)
+		if err != nil { return "", err }
+		b, ok := obj.(*BlobObject)
+		if !ok { return "", nil }
+		return string(b.Data), nil
+	}
+	return "", nil
 }
 
 func parsePorcelainBlameHeaderLine(s string) (string, int, int, int) {
@@ -98,6 +161,13 @@ func parsePorcelainBlameLine(ci map[string]*PorcelainBlameCommitInfo, b *trueLin
 	commitInfo.CommitId = commitId
 	commitInfo.Summary = m["summary"]
 	commitInfo.Filename = m["filename"]
+	commitInfo.OtherHeader = m
+	// "previous" has the format of "{commitId} {filename}".
+	if prev, ok := m["previous"]; ok {
+		p := strings.SplitN(prev, " ", 2)
+		commitInfo.PreviousCommitId = p[0]
+		if len(p) >= 2 { commitInfo.PreviousFilename = p[1] }
+	}
 	authorName := m["author"]
 	authorEmail := m["author-mail"][1:len(m["author-mail"])-1]
 	authorTimestamp, _ := strconv.ParseInt(m["author-time"], 10, 64)
@@ -111,7 +181,7 @@ func parsePorcelainBlameLine(ci map[string]*PorcelainBlameCommitInfo, b *trueLin
 		Time: authorT,
 	}
 	committerName := m["committer"]
-	committerEmail := m["committer-email"]
+	committerEmail := strings.Trim(m["committer-mail"], "<>")
 	committerTimestamp, _ := strconv.ParseInt(m["committer-time"], 10, 64)
 	committerTZ, _ := parseTimezoneOffset(m["committer-tz"])
 	committerT := time.Unix(committerTimestamp, 0).UTC().In(
@@ -152,15 +222,60 @@ func parsePorcelainBlame(b *trueLineReader) (*PorcelainBlame, error) {
 		if err != nil { break }
 		l = append(l, line)
 	}
-	return &PorcelainBlame{
+	res := &PorcelainBlame{
 		CommitInfo: ci,
 		LineList: l,
-	}, nil
+	}
+	first := true
+	for _, v := range ci {
+		t := v.AuthorInfo.Time
+		if first || t.Before(res.oldestTime) { res.oldestTime = t }
+		if first || t.After(res.newestTime) { res.newestTime = t }
+		first = false
+	}
+	return res, nil
 }
 
 func (gr *LocalGitRepository) Blame(c *CommitObject, p string) (*PorcelainBlame, error) {
-	cmd := exec.Command("git", "blame", "--porcelain", c.Id, "--", p)
-	cmd.Dir = gr.GitDirectoryPath
+	return gr.BlameWithOption(c, p, nil)
+}
+
+// git-blame dies on any malformed line in an ignore-revs file; since
+// the file comes from the repository itself we only keep the lines
+// that are valid full commit ids.
+func sanitizeIgnoreRevs(s string) string {
+	res := new(strings.Builder)
+	for line := range strings.SplitSeq(s, "\n") {
+		if i := strings.IndexByte(line, '#'); i != -1 { line = line[:i] }
+		line = strings.TrimSpace(line)
+		if !IsValidId(line) { continue }
+		res.WriteString(line)
+		res.WriteString("\n")
+	}
+	return res.String()
+}
+
+func (gr *LocalGitRepository) BlameWithOption(c *CommitObject, p string, opt *BlameOption) (*PorcelainBlame, error) {
+	args := []string{"blame", "--porcelain"}
+	if opt != nil {
+		if opt.DetectMove { args = append(args, "-M") }
+		if opt.DetectCopy { args = append(args, "-C") }
+		ignoreRevs := sanitizeIgnoreRevs(opt.IgnoreRevs)
+		if len(ignoreRevs) > 0 {
+			// git-blame only takes ignore-revs from a file, and commit ids
+			// that aren't in the repository are silently skipped, which is
+			// what we want here.
+			f, err := os.CreateTemp("", "gitus-blame-ignore-revs-")
+			if err != nil { return nil, err }
+			defer os.Remove(f.Name())
+			_, err = f.WriteString(ignoreRevs)
+			f.Close()
+			if err != nil { return nil, err }
+			args = append(args, "--ignore-revs-file", f.Name())
+		}
+	}
+	args = append(args, c.Id, "--", p)
+	cmd := gr.gitCommand(args...)
 	stdoutBuf := new(bytes.Buffer)
 	cmd.Stdout = stdoutBuf
 	stderrBuf := new(bytes.Buffer)
@@ -49,11 +49,17 @@ func (c *CommitObject) RenderAsString() string {
 	fmt.Fprintf(res, "author %s\n", c.AuthorInfo.String())
 	fmt.Fprintf(res, "committer %s\n", c.CommitterInfo.String())
 	if len(c.Signature) > 0 {
-		fmt.Fprintf(res, "gpgsig %s\n\n", c.Signature)
+		fmt.Fprintf(res, "gpgsig %s\n", c.Signature)
 	}
-	fmt.Fprintf(res, "%s\n", c.CommitMessage)
+	// the message is separated from the header by an empty line and
+	// always ends with a newline.
+	res.WriteString("\n")
+	res.WriteString(c.CommitMessage)
+	if !strings.HasSuffix(c.CommitMessage, "\n") { res.WriteString("\n") }
+	// the co-authors parsed from the message are already in it.
 	for _, v := range c.CoAuthorInfo {
-		fmt.Fprintf(res, "Co-Authored-By: %s\n", v.String())
+		if hasCoAuthoredBy(c.CommitMessage, v) { continue }
+		fmt.Fprintf(res, "Co-Authored-By: %s <%s>\n", v.AuthorName, v.AuthorEmail)
 	}
 	return res.String()
 }
@@ -89,6 +95,14 @@ func (rgo RawGitObject) readAsCommitObjectNoDeflate() (*CommitObject, error) {
 
 var reCoAuthoredBy = regexp.MustCompile(`^\s*[cC]o-[aA]uthored-[bB]y:\s*([^<>]+)\s*<([^>]*)>\s*$`)
 
+func hasCoAuthoredBy(message string, at AuthorTime) bool {
+	for line := range strings.SplitSeq(message, "\n") {
+		r := reCoAuthoredBy.FindStringSubmatch(strings.TrimSpace(line))
+		if len(r) > 0 && strings.TrimSpace(r[1]) == at.AuthorName && r[2] == at.AuthorEmail { return true }
+	}
+	return false
+}
+
 func parseCommitObject(objid string, f io.Reader) (*CommitObject, error) {
 	sourceBytes, err := io.ReadAll(f)
 	if err != nil { return nil, err }
@@ -131,7 +145,7 @@ func parseCommitObject(objid string, f io.Reader) (*CommitObject, error) {
 		r := reCoAuthoredBy.FindStringSubmatch(strings.TrimSpace(line))
 		if len(r) <= 0 { continue }
 		res.CoAuthorInfo = append(res.CoAuthorInfo, AuthorTime{
-			AuthorName: r[1],
+			AuthorName: strings.TrimSpace(r[1]),
 			AuthorEmail: r[2],
 			Time: res.AuthorInfo.Time,
 		})
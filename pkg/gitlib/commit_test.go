@@ -0,0 +1,120 @@
+package gitlib
+
+import (
+	"testing"
+	"time"
+)
+
+const testSignature = `-----BEGIN PGP SIGNATURE-----
+
+ iHUEABYKAB0WIQTestTestTestTestTestTestTestTestBQJlVDkAAAoJEHRlc3R0
+ ZXN0dGVzdAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA
+ =TEST
+ -----END PGP SIGNATURE-----`
+
+// the commits written by git are rendered back byte for byte.
+func TestCommitObjectRenderAsString(t *testing.T) {
+	dir := newTestRepository(t)
+	gr := NewLocalGitRepository(dir)
+	treeId := runGit(t, dir, "", "mktree")
+	parentId := runGit(t, dir, "", "commit-tree", treeId, "-m", "parent")
+	caseList := []struct {
+		name string
+		data string
+	}{
+		{
+			"unsigned",
+			"tree " + treeId + "\n" +
+				"parent " + parentId + "\n" +
+				"author A U Thor <author@example.com> 1700000000 +0000\n" +
+				"committer C O Mitter <committer@example.com> 1700000100 +0000\n" +
+				"\n" +
+				"subject\n\nbody\n",
+		},
+		{
+			"signed",
+			"tree " + treeId + "\n" +
+				"parent " + parentId + "\n" +
+				"author A U Thor <author@example.com> 1700000000 +0000\n" +
+				"committer C O Mitter <committer@example.com> 1700000100 +0000\n" +
+				"gpgsig " + testSignature + "\n" +
+				"\n" +
+				"subject\n",
+		},
+		{
+			"root commit",
+			"tree " + treeId + "\n" +
+				"author A U Thor <author@example.com> 1700000000 +0000\n" +
+				"committer C O Mitter <committer@example.com> 1700000100 +0000\n" +
+				"\n" +
+				"subject\n",
+		},
+		{
+			"merge commit",
+			"tree " + treeId + "\n" +
+				"parent " + parentId + "\n" +
+				"parent " + parentId + "\n" +
+				"author A U Thor <author@example.com> 1700000000 +0000\n" +
+				"committer C O Mitter <committer@example.com> 1700000100 +0000\n" +
+				"\n" +
+				"merge\n",
+		},
+		{
+			"time zone",
+			"tree " + treeId + "\n" +
+				"author A U Thor <author@example.com> 1700000000 +0800\n" +
+				"committer C O Mitter <committer@example.com> 1700000100 -0130\n" +
+				"\n" +
+				"subject\n",
+		},
+		{
+			"co-author",
+			"tree " + treeId + "\n" +
+				"author A U Thor <author@example.com> 1700000000 +0000\n" +
+				"committer C O Mitter <committer@example.com> 1700000100 +0000\n" +
+				"\n" +
+				"subject\n\nCo-Authored-By: Co Author <co@example.com>\n",
+		},
+	}
+	for _, c := range caseList {
+		t.Run(c.name, func(t *testing.T) {
+			id := runGit(t, dir, c.data, "hash-object", "-t", "commit", "-w", "--stdin")
+			data, err := runGitRaw(dir, "", "cat-file", "commit", id)
+			if err != nil { t.Fatal(err) }
+			if data != c.data { t.Fatalf("git cat-file commit:\n%q\nwant:\n%q", data, c.data) }
+			cobj, err := gr.readCommit(id)
+			if err != nil { t.Fatal(err) }
+			if got := cobj.RenderAsString(); got != data {
+				t.Errorf("got:\n%q\nwant (git cat-file commit):\n%q", got, data)
+			}
+		})
+	}
+	runGit(t, dir, "", "fsck", "--strict", "--no-dangling")
+}
+
+// the commits created by gitlib (e.g. when merging) are valid to git.
+func TestCommitObjectRenderAsStringNew(t *testing.T) {
+	dir := newTestRepository(t)
+	gr := NewLocalGitRepository(dir)
+	treeId := runGit(t, dir, "", "mktree")
+	at := AuthorTime{ AuthorName: "A U Thor", AuthorEmail: "author@example.com", Time: time.Unix(1700000000, 0).In(time.FixedZone("UTC+0800", 8 * 3600)) }
+	cobj := &CommitObject{
+		TreeObjId: treeId,
+		AuthorInfo: at,
+		CoAuthorInfo: []AuthorTime{ { AuthorName: "Co Author", AuthorEmail: "co@example.com", Time: at.Time } },
+		CommitterInfo: at,
+		CommitMessage: "subject",
+	}
+	id, err := gr.WriteLooseObject(COMMIT, []byte(cobj.RenderAsString()))
+	if err != nil { t.Fatal(err) }
+	want := "tree " + treeId + "\n" +
+		"author A U Thor <author@example.com> 1700000000 +0800\n" +
+		"committer A U Thor <author@example.com> 1700000000 +0800\n" +
+		"\n" +
+		"subject\n" +
+		"Co-Authored-By: Co Author <co@example.com>\n"
+	data, err := runGitRaw(dir, "", "cat-file", "commit", id)
+	if err != nil { t.Fatal(err) }
+	if data != want { t.Errorf("got:\n%q\nwant:\n%q", data, want) }
+	runGit(t, dir, "", "fsck", "--strict", "--no-dangling", id)
+}
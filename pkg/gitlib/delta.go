@@ -2,6 +2,7 @@ package gitlib
 
 import (
 	"bytes"
+	"errors"
 	"io"
 )
 
@@ -147,3 +148,155 @@ func parseDeltaCommandList(b []byte) ([]DeltaCommand, error) {
 	return res, nil
 }
 
+
+// the other direction: building the delta that turns `base` into
+// `target`. the base is indexed by the hash of each 16-byte block;
+// at each position in the target the longest match among the blocks
+// w/ the same hash is copied, and everything else is inserted as is.
+
+const (
+	deltaBlockSize = 16
+	// only the first few blocks w/ the same hash are kept; files w/
+	// lots of repetition would be slow to index otherwise.
+	deltaMaxBlockCandidate = 64
+	// copy commands can have a size of up to 3 bytes but git caps it
+	// at 64KiB for the sake of older versions.
+	deltaMaxCopySize = 0x10000
+	deltaMaxInsertSize = 0x7f
+)
+
+func appendDeltaVarint(b []byte, v int) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v & 0x7f) | 0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func deltaBlockHash(b []byte) uint64 {
+	var h uint64 = 14695981039346656037
+	for _, c := range b[:deltaBlockSize] {
+		h ^= uint64(c)
+		h *= 1099511628211
+	}
+	return h
+}
+
+func appendDeltaInsert(b []byte, data []byte) []byte {
+	for len(data) > 0 {
+		n := min(len(data), deltaMaxInsertSize)
+		b = append(b, byte(n))
+		b = append(b, data[:n]...)
+		data = data[n:]
+	}
+	return b
+}
+
+func appendDeltaCopy(b []byte, offset int, size int) []byte {
+	for size > 0 {
+		n := min(size, deltaMaxCopySize)
+		h := len(b)
+		b = append(b, 0x80)
+		for i := range 4 {
+			c := byte(offset >> (8 * i))
+			if c != 0 {
+				b[h] |= 1 << i
+				b = append(b, c)
+			}
+		}
+		// a size of 0x10000 is written as 0.
+		sz := n
+		if sz == deltaMaxCopySize { sz = 0 }
+		for i := range 3 {
+			c := byte(sz >> (8 * i))
+			if c != 0 {
+				b[h] |= 1 << (4 + i)
+				b = append(b, c)
+			}
+		}
+		offset += n
+		size -= n
+	}
+	return b
+}
+
+// returns the delta (incl. the base size & the target size in front)
+// or nil if it wouldn't be smaller than `maxSize`. a `maxSize` of 0
+// means no limit.
+func EncodeDelta(base []byte, target []byte, maxSize int) []byte {
+	res := make([]byte, 0, 64)
+	res = appendDeltaVarint(res, len(base))
+	res = appendDeltaVarint(res, len(target))
+	index := make(map[uint64][]int)
+	for i := 0; i + deltaBlockSize <= len(base); i += deltaBlockSize {
+		h := deltaBlockHash(base[i:])
+		if len(index[h]) < deltaMaxBlockCandidate { index[h] = append(index[h], i) }
+	}
+	// the start of the bytes that are not copied yet.
+	pending := 0
+	j := 0
+	for j + deltaBlockSize <= len(target) {
+		bestOffset := -1
+		bestSize := 0
+		for _, i := range index[deltaBlockHash(target[j:])] {
+			n := 0
+			for i + n < len(base) && j + n < len(target) && base[i+n] == target[j+n] { n += 1 }
+			if n > bestSize {
+				bestOffset = i
+				bestSize = n
+			}
+		}
+		if bestSize < deltaBlockSize {
+			j += 1
+			continue
+		}
+		// the match might also extend backwards into the bytes that
+		// would be inserted otherwise.
+		for bestOffset > 0 && j > pending && base[bestOffset-1] == target[j-1] {
+			bestOffset -= 1
+			j -= 1
+			bestSize += 1
+		}
+		res = appendDeltaInsert(res, target[pending:j])
+		res = appendDeltaCopy(res, bestOffset, bestSize)
+		j += bestSize
+		pending = j
+		if maxSize > 0 && len(res) >= maxSize { return nil }
+	}
+	res = appendDeltaInsert(res, target[pending:])
+	if maxSize > 0 && len(res) >= maxSize { return nil }
+	return res
+}
+
+// applies a delta made by EncodeDelta (or git) to `base`.
+func ApplyDelta(base []byte, delta []byte) ([]byte, error) {
+	i := 0
+	readVarint := func() (int, error) {
+		res := 0
+		shift := 0
+		for {
+			if i >= len(delta) { return 0, io.ErrUnexpectedEOF }
+			c := delta[i]
+			i += 1
+			res |= int(c & 0x7f) << shift
+			shift += 7
+			if c & 0x80 == 0 { return res, nil }
+		}
+	}
+	baseSize, err := readVarint()
+	if err != nil { return nil, err }
+	if baseSize != len(base) { return nil, errors.New("Delta base size mismatch") }
+	targetSize, err := readVarint()
+	if err != nil { return nil, err }
+	commandList, err := parseDeltaCommandList(delta[i:])
+	if err != nil { return nil, err }
+	res := make([]byte, 0, targetSize)
+	for _, v := range commandList {
+		if c, ok := v.(DeltaCopyCommand); ok && c.offset + c.size > int64(len(base)) {
+			return nil, errors.New("Delta copy out of range")
+		}
+		res = append(res, v.Execute(base)...)
+	}
+	if len(res) != targetSize { return nil, errors.New("Delta target size mismatch") }
+	return res, nil
+}
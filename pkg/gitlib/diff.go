@@ -1,13 +1,8 @@
 package gitlib
 
 import (
-	"bytes"
 	"errors"
-	"io"
-	"os/exec"
-	"regexp"
-	"strconv"
-	"strings"
+	"fmt"
 )
 
 // NOTE: diff is good candidate for caching. they take resources to
@@ -72,178 +67,119 @@ type Diff struct {
 	ItemList []*DiffItem `json:"item"`
 }
 
-// shhhh....... (finger across lips)
-var reGitDiffHeader = regexp.MustCompile(`(((?:(?:old|new|deleted file|new file) mode)|(?:copy (?:from|to))|(?:rename (?:from|to))|(?:dis)?similarity index) (.*))|(?:index ([^.]+)\.\.([^.]+) (.*))`)
-func parseGitDiffHeaderItem(s string) *DiffItemHeaderItem {
-	matchres := reGitDiffHeader.FindStringSubmatch(s)
-	if len(matchres) <= 0 { return nil }
-	if len(matchres[1]) > 0 {
-		var cmdType uint8
-		switch matchres[1] {
-		case "old mode": cmdType = DIFF_OLD_MODE
-		case "new mode": cmdType = DIFF_NEW_MODE
-		case "deleted file mode": cmdType = DIFF_DELETED_FILE_MODE
-		case "new file mode": cmdType = DIFF_NEW_FILE_MODE
-		case "copy from": cmdType = DIFF_COPY_FROM
-		case "copy to": cmdType = DIFF_COPY_TO
-		case "rename from": cmdType = DIFF_RENAME_FROM
-		case "rename to": cmdType = DIFF_RENAME_TO
-		case "similarity index": cmdType = DIFF_SIMILARITY_INDEX
-		case "dissimilarity index": cmdType = DIFF_DISSIMILARITY_INDEX
+
+// returned by the git executable when the repository belongs to a
+// different user. GetDiff doesn't call git anymore but the error is
+// still reported by the things that do.
+var ErrDubiousOwnership = errors.New("dubious ownership")
+
+const diffNullObjectId = "0000000"
+
+func abbrevObjectId(id string) string {
+	if len(id) <= 0 { return diffNullObjectId }
+	if len(id) > 7 { return id[:7] }
+	return id
+}
+
+func formatTreeMode(m int) string {
+	return fmt.Sprintf("%06d", m)
+}
+
+// the lines a submodule shows up as in a diff; same as git.
+func submoduleDiffLines(mode int, id string) []string {
+	if len(id) <= 0 { return nil }
+	if mode != TREE_SUBMODULE { return nil }
+	return []string{ fmt.Sprintf("Subproject commit %s\n", id) }
+}
+
+func (gr LocalGitRepository) diffItemFromTreeChange(tc *TreeChange) (*DiffItem, error) {
+	headerList := make([]*DiffItemHeaderItem, 0)
+	header := func(t uint8, args ...string) {
+		headerList = append(headerList, &DiffItemHeaderItem{ Type: t, Args: args })
+	}
+	res := &DiffItem{ File1: "/dev/null", File2: "/dev/null" }
+	switch tc.Type {
+	case TREE_CHANGE_ADD:
+		res.File2 = "b/" + tc.NewPath
+		header(DIFF_NEW_FILE_MODE, formatTreeMode(tc.NewMode))
+	case TREE_CHANGE_DELETE:
+		res.File1 = "a/" + tc.OldPath
+		header(DIFF_DELETED_FILE_MODE, formatTreeMode(tc.OldMode))
+	case TREE_CHANGE_MODIFY, TREE_CHANGE_RENAME:
+		res.File1 = "a/" + tc.OldPath
+		res.File2 = "b/" + tc.NewPath
+		if tc.OldMode != tc.NewMode {
+			header(DIFF_OLD_MODE, formatTreeMode(tc.OldMode))
+			header(DIFF_NEW_MODE, formatTreeMode(tc.NewMode))
 		}
-		return &DiffItemHeaderItem{
-			Type: cmdType,
-			Args: matchres[2:3],
+		if tc.Type == TREE_CHANGE_RENAME {
+			header(DIFF_SIMILARITY_INDEX, fmt.Sprintf("%d%%", tc.Similarity))
+			header(DIFF_RENAME_FROM, tc.OldPath)
+			header(DIFF_RENAME_TO, tc.NewPath)
 		}
+	}
+	if tc.OldId == tc.NewId {
+		res.Header = headerList
+		res.PatchList = make([]*DiffItemPatch, 0)
+		return res, nil
+	}
+	if tc.Type != TREE_CHANGE_ADD && tc.Type != TREE_CHANGE_DELETE && tc.OldMode == tc.NewMode {
+		header(DIFF_INDEX, abbrevObjectId(tc.OldId), abbrevObjectId(tc.NewId), formatTreeMode(tc.OldMode))
+	} else {
+		header(DIFF_INDEX, abbrevObjectId(tc.OldId), abbrevObjectId(tc.NewId))
+	}
+	res.Header = headerList
+	res.PatchList = make([]*DiffItemPatch, 0)
+	var a, b []string
+	if tc.OldMode == TREE_SUBMODULE || tc.NewMode == TREE_SUBMODULE {
+		a = submoduleDiffLines(tc.OldMode, tc.OldId)
+		b = submoduleDiffLines(tc.NewMode, tc.NewId)
 	} else {
-		return &DiffItemHeaderItem{
-			Type: DIFF_INDEX,
-			Args: matchres[3:],
+		var aData, bData []byte
+		var err error
+		if len(tc.OldId) > 0 {
+			aData, err = gr.readBlobData(tc.OldId)
+			if err != nil { return nil, err }
+		}
+		if len(tc.NewId) > 0 {
+			bData, err = gr.readBlobData(tc.NewId)
+			if err != nil { return nil, err }
 		}
+		// git shows "Binary files differ" w/o any patch.
+		if isBinaryContent(aData) || isBinaryContent(bData) { return res, nil }
+		a = splitLines(aData)
+		b = splitLines(bData)
 	}
+	res.PatchList = makePatchList(a, b, diffLines(a, b), DIFF_CONTEXT_LINE_COUNT)
+	return res, nil
 }
 
-
-var reGitDiffItemFile1Header = regexp.MustCompile("--- (.*)")
-var reGitDiffItemFile2Header = regexp.MustCompile(`\+\+\+ (.*)`)
-var reGitDiffItemLineHeader = regexp.MustCompile(`@@ -(\d+),(\d+) \+(\d+),(\d+) @@(.*)`)
-
-func parseGitDiff(br *bytes.Buffer) (*Diff, error) {
-	tlr := newTrueLineReader(br)
-	l, err := tlr.readLine()
+// returns the diff between a commit and its first parent. root
+// commits are compared against the empty tree, so everything in them
+// shows up as added.
+func (gr LocalGitRepository) GetDiff(commitId string) (*Diff, error) {
+	gobj, err := gr.ReadObject(commitId)
 	if err != nil { return nil, err }
-	// NOTE: if there is indeed a diff, the first line would be the
-	// commit's id. we can safely start to read the diffs of files
-	// w/o unreading the line we've just read.
-	commitHash := strings.TrimSpace(l)
-	itemList := make([]*DiffItem, 0)
-	for {
-		l, err = tlr.readLine()
-		if errors.Is(err, io.EOF) { break }
+	cobj, ok := gobj.(*CommitObject)
+	if !ok { return nil, errors.New("Not a commit object") }
+	parentTreeId := ""
+	if len(cobj.ParentIdList) > 0 {
+		pobj, err := gr.ReadObject(cobj.ParentIdList[0])
 		if err != nil { return nil, err }
-		// NOTE: we skip the "diff --git" line.
-		// parse header
-		headerItemList := make([]*DiffItemHeaderItem, 0)
-		for {
-			l, err = tlr.readLine()
-			if errors.Is(err, io.EOF) { break }
-			if err != nil { return nil, err }
-			p := parseGitDiffHeaderItem(l)
-			if p == nil {
-				tlr.unreadLine(l)
-				break
-			}
-			headerItemList = append(headerItemList, p)
-		}
-
-		// parse file headers
-		l, err = tlr.readLine()
-		if errors.Is(err, io.EOF) { break }
-		if err != nil { return nil, err }
-		matchres := reGitDiffItemFile1Header.FindStringSubmatch(l)
-		if len(matchres) <= 0 { return nil, ErrInvalidFormat }
-		file1 := matchres[1]
-		l, err = tlr.readLine()
-		if errors.Is(err, io.EOF) { break }
+		pcobj, ok := pobj.(*CommitObject)
+		if !ok { return nil, errors.New("Not a commit object") }
+		parentTreeId = pcobj.TreeObjId
+	}
+	changeList, err := gr.DiffTree(parentTreeId, cobj.TreeObjId, true)
+	if err != nil { return nil, err }
+	itemList := make([]*DiffItem, 0, len(changeList))
+	for _, v := range changeList {
+		item, err := gr.diffItemFromTreeChange(v)
 		if err != nil { return nil, err }
-		matchres = reGitDiffItemFile2Header.FindStringSubmatch(l)
-		if len(matchres) <= 0 { return nil, ErrInvalidFormat }
-		file2 := matchres[1]
-
-		// parse patch
-		patchList := make([]*DiffItemPatch, 0)
-		for {
-			l, err = tlr.readLine()
-			if errors.Is(err, io.EOF) { break }
-			if err != nil { return nil, err }
-			matchres := reGitDiffItemLineHeader.FindStringSubmatch(l)
-			if len(matchres) <= 0 {
-				tlr.unreadLine(l)
-				break
-			}
-			lStartStr := matchres[1]
-			lLineCountStr := matchres[2]
-			rStartStr := matchres[3]
-			rLineCountStr := matchres[4]
-			lStart, _ := strconv.ParseInt(lStartStr, 10, 64)
-			lLineCount, _ := strconv.ParseInt(lLineCountStr, 10, 64)
-			rStart, _ := strconv.ParseInt(rStartStr, 10, 64)
-			rLineCount, _ := strconv.ParseInt(rLineCountStr, 10, 64)
-			context := matchres[5]
-			pLines := make([]AnnotatedLine, 0)
-			f1LineNumberCounter := lStart
-			f2LineNumberCounter := rStart
-			for {
-				l, err = tlr.readLine()
-				if errors.Is(err, io.EOF) { break }
-				if err != nil { return nil, err }
-				if l[0] != ' ' && l[0] != '-' && l[0] != '+' {
-					tlr.unreadLine(l)
-					break
-				}
-				lineContent := l[1:]
-				var lineType uint8
-				switch l[0] {
-				case ' ':
-					lineType = SAME
-					f1LineNumberCounter += 1
-					f2LineNumberCounter += 1
-				case '+':
-					lineType = APPEND
-					f2LineNumberCounter += 1
-				case '-':
-					lineType = DELETE
-					f1LineNumberCounter += 1
-				}
-				pLines = append(pLines, AnnotatedLine{
-					Type: lineType,
-					F1LineNum: f1LineNumberCounter,
-					F2LineNum: f2LineNumberCounter,
-					Line: lineContent,
-				})
-			}
-			patchList = append(patchList, &DiffItemPatch{
-				LStart: lStart,
-				LLineCount: lLineCount,
-				RStart: rStart,
-				RLineCount: rLineCount,
-				ContextLine: context,
-				LineList: pLines,
-			})
-		}
-		itemList = append(itemList, &DiffItem{
-			File1: file1,
-			File2: file2,
-			Header: headerItemList,
-			PatchList: patchList,
-		})
+		itemList = append(itemList, item)
 	}
 	return &Diff{
-		CommitHash: commitHash,
+		CommitHash: cobj.Id,
 		ItemList: itemList,
 	}, nil
 }
-
-// returns the diff by invoking the "git diff" command.
-// i should probably implement my own diff and rely as little on the
-// git executable as possible at some point.
-var ErrDubiousOwnership = errors.New("dubious ownership")
-func (gr LocalGitRepository) GetDiff(commitId string) (*Diff, error) {
-	cmd := exec.Command("git", "diff-tree", commitId, "-p")
-	cmd.Dir = gr.GitDirectoryPath
-	stderrBuf := new(bytes.Buffer)
-	cmd.Stderr = stderrBuf
-	stdoutBuf := new(bytes.Buffer)
-	cmd.Stdout = stdoutBuf
-	err := cmd.Run()
-	if err != nil {
-		if strings.Contains(stderrBuf.String(), "dubious ownership") {
-			return nil, ErrDubiousOwnership
-		}
-		return nil, err
-	}
-	diff, err := parseGitDiff(stdoutBuf)
-	return diff, nil
-}
-
-
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -55,8 +54,7 @@ func (gr *LocalGitRepository) AddFileToRepoString(
 	} else {
 		fromStr = fmt.Sprintf("from refs/heads/%s^0\n", branchName)
 	}
-	cmd := exec.Command("git", "fast-import", "--date-format=now", "--quiet")
-	cmd.Dir = gr.GitDirectoryPath
+	cmd := gr.gitCommand("fast-import", "--date-format=now", "--quiet")
 	stdoutBuff := new(bytes.Buffer)
 	cmd.Stdout = stdoutBuff
 	stderrBuf := new(bytes.Buffer)
@@ -138,8 +136,7 @@ func (gr *LocalGitRepository) AddFileToRepoReader(
 	} else {
 		fromStr = fmt.Sprintf("from refs/heads/%s^0\n", branchName)
 	}
-	cmd := exec.Command("git", "fast-import", "--date-format=now", "--quiet")
-	cmd.Dir = gr.GitDirectoryPath
+	cmd := gr.gitCommand("fast-import", "--date-format=now", "--quiet")
 	stdoutBuff := new(bytes.Buffer)
 	cmd.Stdout = stdoutBuff
 	stderrBuf := new(bytes.Buffer)
@@ -227,8 +224,7 @@ func (gr *LocalGitRepository) AddMultipleFileToRepoString(
 	} else {
 		fromStr = fmt.Sprintf("from refs/heads/%s^0\n", branchName)
 	}
-	cmd := exec.Command("git", "fast-import", "--date-format=now", "--quiet")
-	cmd.Dir = gr.GitDirectoryPath
+	cmd := gr.gitCommand("fast-import", "--date-format=now", "--quiet")
 	stdoutBuff := new(bytes.Buffer)
 	cmd.Stdout = stdoutBuff
 	stderrBuf := new(bytes.Buffer)
@@ -0,0 +1,67 @@
+package gitlib
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+)
+
+// the tests compare the results w/ the ones of the git executable &
+// are skipped w/o it.
+
+func requireGit(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git executable not found")
+	}
+}
+
+// runs git in `dir` w/ a fixed identity & w/o the user's config; the
+// output is trimmed.
+func runGit(t *testing.T, dir string, stdin string, arg ...string) string {
+	t.Helper()
+	res, err := runGitRaw(dir, stdin, arg...)
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(arg, " "), err, res)
+	}
+	return strings.TrimSpace(res)
+}
+
+// same as runGit but w/ the output untouched & the error returned,
+// for the commands that exit w/ 1 on e.g. a conflict.
+func runGitRaw(dir string, stdin string, arg ...string) (string, error) {
+	return runGitWithEnv(dir, nil, stdin, arg...)
+}
+
+func runGitWithEnv(dir string, env []string, stdin string, arg ...string) (string, error) {
+	cmd := exec.Command("git", arg...)
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Env = append(os.Environ(),
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_CONFIG_GLOBAL=/dev/null",
+		"GIT_AUTHOR_NAME=Test",
+		"GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_AUTHOR_DATE=1700000000 +0000",
+		"GIT_COMMITTER_NAME=Test",
+		"GIT_COMMITTER_EMAIL=test@example.com",
+		"GIT_COMMITTER_DATE=1700000000 +0000",
+	)
+	cmd.Env = append(cmd.Env, env...)
+	var out, errOut bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+	err := cmd.Run()
+	if err != nil { err = fmt.Errorf("%w: %s", err, errOut.String()) }
+	return out.String(), err
+}
+
+func writeTestFile(t *testing.T, p string, data string) {
+	t.Helper()
+	if err := os.MkdirAll(path.Dir(p), 0755); err != nil { t.Fatal(err) }
+	if err := os.WriteFile(p, []byte(data), 0644); err != nil { t.Fatal(err) }
+}
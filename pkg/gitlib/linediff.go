@@ -0,0 +1,1212 @@
+package gitlib
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// line-based diff & three-way merge, used by GetDiff and the merge
+// of trees so that they don't need the git executable. the diff is
+// the O(ND) algorithm from "An O(ND) Difference Algorithm and Its
+// Variations" by Eugene W. Myers in the same linear-space variant &
+// w/ the same heuristics as git's xdiff, so that the results are the
+// same as git's.
+
+// the number of context lines around each patch, same as git's
+// default.
+const DIFF_CONTEXT_LINE_COUNT = 3
+
+// git checks the first 8000 bytes for NUL to decide whether a file
+// is binary.
+const binaryCheckSize = 8000
+
+func isBinaryContent(data []byte) bool {
+	if len(data) > binaryCheckSize { data = data[:binaryCheckSize] }
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// splits `data` into lines, each with its "\n" (if there is one), so
+// that joining the result gives back `data`.
+func splitLines(data []byte) []string {
+	res := make([]string, 0)
+	s := string(data)
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			res = append(res, s)
+			break
+		}
+		res = append(res, s[:i+1])
+		s = s[i+1:]
+	}
+	return res
+}
+
+type lineEdit struct {
+	// SAME, DELETE or APPEND.
+	Type uint8
+	// the index of the line in the old file; only for SAME & DELETE.
+	AIndex int
+	// the index of the line in the new file; only for SAME & APPEND.
+	BIndex int
+}
+
+// returns the edit script that turns `a` into `b`, in the same way as
+// `git diff` w/ the default options.
+func diffLines(a []string, b []string) []lineEdit {
+	return diffLinesWith(a, b, diffOption{ indentHeuristic: true })
+}
+
+type diffOption struct {
+	// slide the groups of changes by the indent heuristic; the diffs
+	// for merging don't use it, same as git.
+	indentHeuristic bool
+	// the histogram diff instead of the Myers diff.
+	histogram bool
+}
+
+func diffLinesWith(a []string, b []string, opt diffOption) []lineEdit {
+	// lines are compared as ints from here on, numbered in the order
+	// they first appear (same as git's xdiff, which matters for the
+	// histogram diff).
+	lineId := make(map[string]int)
+	intern := func(l []string) []int {
+		res := make([]int, len(l))
+		for i, s := range l {
+			id, ok := lineId[s]
+			if !ok {
+				id = len(lineId)
+				lineId[s] = id
+			}
+			res[i] = id
+		}
+		return res
+	}
+	sa := &diffSide{ line: a, id: intern(a), changed: make([]bool, len(a) + 2) }
+	sb := &diffSide{ line: b, id: intern(b), changed: make([]bool, len(b) + 2) }
+	if opt.histogram {
+		histogramDiff(sa, sb, len(lineId), 1, len(a), 1, len(b))
+	} else {
+		diffRecord(sa, sb, len(lineId))
+	}
+	sa.compact(sb, opt.indentHeuristic)
+	sb.compact(sa, opt.indentHeuristic)
+	return editScript(sa, sb)
+}
+
+// the diff below is a port of `xdl_do_diff` from git's xdiff, so that
+// the changes are lined up the same way as git does when there's more
+// than one shortest edit script (e.g. w/ a lot of repeated lines).
+
+const (
+	// the lines that appear more often than this in the other file
+	// (or the "square root" of the length of this file if smaller)
+	// can be left out of the search.
+	diffMaxEqualLimit = 1024
+	diffSimilarScanWindow = 100
+	diffKeepDiscardedRun = 4
+	// the search takes the furthest reaching path it has found once
+	// the edit cost gets to this (or the "square root" of the number
+	// of diagonals if larger).
+	diffMinMaxCost = 256
+	diffHeuristicMinCost = 256
+	diffSnakeCount = 20
+	diffHeuristicFactor = 4
+)
+
+// the same integer "square root" as git's `xdl_bogosqrt`.
+func bogoSqrt(n int) int {
+	i := 1
+	for ; n > 0; n >>= 2 { i <<= 1 }
+	return i
+}
+
+// marks the changed lines of both sides. `idCount` is the number of
+// different lines.
+func diffRecord(sa *diffSide, sb *diffSide, idCount int) {
+	na := len(sa.id)
+	nb := len(sb.id)
+	// the common prefix & suffix are not part of the search.
+	start := 0
+	for start < min(na, nb) && sa.id[start] == sb.id[start] { start += 1 }
+	suffix := 0
+	for suffix < min(na, nb) - start && sa.id[na-1-suffix] == sb.id[nb-1-suffix] {
+		suffix += 1
+	}
+	countA := make([]int, idCount)
+	for _, id := range sa.id { countA[id] += 1 }
+	countB := make([]int, idCount)
+	for _, id := range sb.id { countB[id] += 1 }
+	s := &diffSearch{ sa: sa, sb: sb }
+	s.a, s.aIndex = sa.searchedLine(start, na - suffix - 1, countB)
+	s.b, s.bIndex = sb.searchedLine(start, nb - suffix - 1, countA)
+	diagonalCount := len(s.a) + len(s.b) + 3
+	s.forward = make([]int, diagonalCount)
+	s.backward = make([]int, diagonalCount)
+	s.offset = len(s.b) + 1
+	s.maxCost = max(bogoSqrt(diagonalCount), diffMinMaxCost)
+	s.compare(0, len(s.a), 0, len(s.b), false)
+}
+
+// returns the ids & the indices of the lines in [start, end] that
+// take part in the search. the other ones are marked changed: the
+// lines that aren't in the other file at all, and the lines that are
+// in it many times in the middle of the former (`xdl_cleanup_records`).
+func (s *diffSide) searchedLine(start int, end int, otherCount []int) ([]int, []int) {
+	limit := min(bogoSqrt(len(s.id)), diffMaxEqualLimit)
+	// 0: not in the other file; 1: in it; 2: in it many times.
+	kind := make([]uint8, len(s.id))
+	for i := start; i <= end; i++ {
+		n := otherCount[s.id[i]]
+		switch {
+		case n == 0: kind[i] = 0
+		case n >= limit: kind[i] = 2
+		default: kind[i] = 1
+		}
+	}
+	id := make([]int, 0, end - start + 1)
+	index := make([]int, 0, end - start + 1)
+	for i := start; i <= end; i++ {
+		if kind[i] == 1 || (kind[i] == 2 && !shouldDiscard(kind, i, start, end)) {
+			id = append(id, s.id[i])
+			index = append(index, i)
+		} else {
+			s.setChanged(i, true)
+		}
+	}
+	return id, index
+}
+
+// whether the line `i` that's in the other file many times is in the
+// middle of a run of lines that are mostly not in the other file
+// (`xdl_clean_mmatch`).
+func shouldDiscard(kind []uint8, i int, start int, end int) bool {
+	start = max(start, i - diffSimilarScanWindow)
+	end = min(end, i + diffSimilarScanWindow)
+	noneBefore, manyBefore := 0, 1
+	for r := 1; i - r >= start; r++ {
+		if kind[i-r] == 0 {
+			noneBefore += 1
+		} else if kind[i-r] == 2 {
+			manyBefore += 1
+		} else {
+			break
+		}
+	}
+	if noneBefore == 0 { return false }
+	noneAfter, manyAfter := 0, 1
+	for r := 1; i + r <= end; r++ {
+		if kind[i+r] == 0 {
+			noneAfter += 1
+		} else if kind[i+r] == 2 {
+			manyAfter += 1
+		} else {
+			break
+		}
+	}
+	if noneAfter == 0 { return false }
+	many := manyBefore + manyAfter
+	return many * diffKeepDiscardedRun < many + noneBefore + noneAfter
+}
+
+type diffSearch struct {
+	sa *diffSide
+	sb *diffSide
+	// the ids of the searched lines & their indices in `sa`/`sb`.
+	a []int
+	aIndex []int
+	b []int
+	bIndex []int
+	// the furthest reaching paths on each diagonal; the diagonal `d`
+	// is at `d + offset`.
+	forward []int
+	backward []int
+	offset int
+	maxCost int
+}
+
+// where the search is split; `minLow`/`minHigh` tells whether the
+// part before/after it needs the minimal edit script.
+type diffSplit struct {
+	i1 int
+	i2 int
+	minLow bool
+	minHigh bool
+}
+
+// marks the changed lines of a[off1:lim1] & b[off2:lim2] by splitting
+// the boxes in the middle of the shortest edit script recursively
+// (`xdl_recs_cmp`).
+func (s *diffSearch) compare(off1 int, lim1 int, off2 int, lim2 int, needMin bool) {
+	for off1 < lim1 && off2 < lim2 && s.a[off1] == s.b[off2] { off1 += 1; off2 += 1 }
+	for off1 < lim1 && off2 < lim2 && s.a[lim1-1] == s.b[lim2-1] { lim1 -= 1; lim2 -= 1 }
+	if off1 == lim1 {
+		for i := off2; i < lim2; i++ { s.sb.setChanged(s.bIndex[i], true) }
+		return
+	}
+	if off2 == lim2 {
+		for i := off1; i < lim1; i++ { s.sa.setChanged(s.aIndex[i], true) }
+		return
+	}
+	spl := s.split(off1, lim1, off2, lim2, needMin)
+	s.compare(off1, spl.i1, off2, spl.i2, spl.minLow)
+	s.compare(spl.i1, lim1, spl.i2, lim2, spl.minHigh)
+}
+
+// finds the middle snake of the box, or a good enough split if the
+// edit cost gets too high (`xdl_split`).
+func (s *diffSearch) split(off1 int, lim1 int, off2 int, lim2 int, needMin bool) diffSplit {
+	ha1 := s.a
+	ha2 := s.b
+	kf := s.forward
+	kb := s.backward
+	o := s.offset
+	dmin := off1 - lim2
+	dmax := lim1 - off2
+	fmid := off1 - off2
+	bmid := lim1 - lim2
+	odd := (fmid - bmid) & 1 != 0
+	fmin, fmax := fmid, fmid
+	bmin, bmax := bmid, bmid
+	kf[o+fmid] = off1
+	kb[o+bmid] = lim1
+	for ec := 1; ; ec++ {
+		gotSnake := false
+		// extend the diagonals by one; the ones just outside are set
+		// so that they're never picked.
+		if fmin > dmin { fmin -= 1; kf[o+fmin-1] = -1 } else { fmin += 1 }
+		if fmax < dmax { fmax += 1; kf[o+fmax+1] = -1 } else { fmax -= 1 }
+		for d := fmax; d >= fmin; d -= 2 {
+			var i1 int
+			if kf[o+d-1] >= kf[o+d+1] { i1 = kf[o+d-1] + 1 } else { i1 = kf[o+d+1] }
+			prev1 := i1
+			i2 := i1 - d
+			for i1 < lim1 && i2 < lim2 && ha1[i1] == ha2[i2] { i1 += 1; i2 += 1 }
+			if i1 - prev1 > diffSnakeCount { gotSnake = true }
+			kf[o+d] = i1
+			if odd && bmin <= d && d <= bmax && kb[o+d] <= i1 {
+				return diffSplit{ i1: i1, i2: i2, minLow: true, minHigh: true }
+			}
+		}
+		if bmin > dmin { bmin -= 1; kb[o+bmin-1] = math.MaxInt } else { bmin += 1 }
+		if bmax < dmax { bmax += 1; kb[o+bmax+1] = math.MaxInt } else { bmax -= 1 }
+		for d := bmax; d >= bmin; d -= 2 {
+			var i1 int
+			if kb[o+d-1] < kb[o+d+1] { i1 = kb[o+d-1] } else { i1 = kb[o+d+1] - 1 }
+			prev1 := i1
+			i2 := i1 - d
+			for i1 > off1 && i2 > off2 && ha1[i1-1] == ha2[i2-1] { i1 -= 1; i2 -= 1 }
+			if prev1 - i1 > diffSnakeCount { gotSnake = true }
+			kb[o+d] = i1
+			if !odd && fmin <= d && d <= fmax && i1 <= kf[o+d] {
+				return diffSplit{ i1: i1, i2: i2, minLow: true, minHigh: true }
+			}
+		}
+		if needMin { continue }
+		// w/ a high enough cost, a path that has got far w/o going
+		// too far from the middle diagonal & ends w/ a long enough
+		// snake is good enough.
+		if gotSnake && ec > diffHeuristicMinCost {
+			best := 0
+			var res diffSplit
+			for d := fmax; d >= fmin; d -= 2 {
+				dd := d - fmid
+				if dd < 0 { dd = -dd }
+				i1 := kf[o+d]
+				i2 := i1 - d
+				v := (i1 - off1) + (i2 - off2) - dd
+				if v > diffHeuristicFactor * ec && v > best &&
+					off1 + diffSnakeCount <= i1 && i1 < lim1 &&
+					off2 + diffSnakeCount <= i2 && i2 < lim2 {
+					for k := 1; ha1[i1-k] == ha2[i2-k]; k++ {
+						if k == diffSnakeCount {
+							best = v
+							res = diffSplit{ i1: i1, i2: i2, minLow: true }
+							break
+						}
+					}
+				}
+			}
+			if best > 0 { return res }
+			for d := bmax; d >= bmin; d -= 2 {
+				dd := d - bmid
+				if dd < 0 { dd = -dd }
+				i1 := kb[o+d]
+				i2 := i1 - d
+				v := (lim1 - i1) + (lim2 - i2) - dd
+				if v > diffHeuristicFactor * ec && v > best &&
+					off1 < i1 && i1 <= lim1 - diffSnakeCount &&
+					off2 < i2 && i2 <= lim2 - diffSnakeCount {
+					for k := 0; ha1[i1+k] == ha2[i2+k]; k++ {
+						if k == diffSnakeCount - 1 {
+							best = v
+							res = diffSplit{ i1: i1, i2: i2, minHigh: true }
+							break
+						}
+					}
+				}
+			}
+			if best > 0 { return res }
+		}
+		// too expensive; take the furthest reaching path so far.
+		if ec >= s.maxCost {
+			fbest, fbest1 := -1, -1
+			for d := fmax; d >= fmin; d -= 2 {
+				i1 := min(kf[o+d], lim1)
+				i2 := i1 - d
+				if lim2 < i2 { i1 = lim2 + d; i2 = lim2 }
+				if fbest < i1 + i2 { fbest = i1 + i2; fbest1 = i1 }
+			}
+			bbest, bbest1 := math.MaxInt, math.MaxInt
+			for d := bmax; d >= bmin; d -= 2 {
+				i1 := max(off1, kb[o+d])
+				i2 := i1 - d
+				if i2 < off2 { i1 = off2 + d; i2 = off2 }
+				if i1 + i2 < bbest { bbest = i1 + i2; bbest1 = i1 }
+			}
+			if (lim1 + lim2) - bbest < fbest - (off1 + off2) {
+				return diffSplit{ i1: fbest1, i2: fbest - fbest1, minLow: true }
+			}
+			return diffSplit{ i1: bbest1, i2: bbest - bbest1, minHigh: true }
+		}
+	}
+}
+
+// the histogram diff, a port of `xdl_do_histogram_diff` from git's
+// xdiff, which is what git uses for merging files. it takes the
+// longest common run of lines that are the least common in the first
+// file, & then diffs the parts before & after it the same way. the
+// lines are numbered from 1 here, same as in git.
+
+const (
+	histogramMaxChainLength = 64
+	histogramHashPrime = 0x9e370001
+)
+
+type histogramRecord struct {
+	ptr int
+	cnt int
+	next *histogramRecord
+}
+
+type histogramIndex struct {
+	sa *diffSide
+	sb *diffSide
+	// the chains of records of the same hash.
+	record []*histogramRecord
+	tableBit uint
+	// the record of each line & the next line that's the same, or 0;
+	// the line `l` is at `l - ptrShift`.
+	lineMap []*histogramRecord
+	nextPtr []int
+	ptrShift int
+	cnt int
+	hasCommon bool
+}
+
+// a common run of lines [begin1, end1] & [begin2, end2].
+type histogramRegion struct {
+	begin1 int
+	end1 int
+	begin2 int
+	end2 int
+}
+
+func (h *histogramIndex) tableHash(id int) int {
+	return int((uint64(id) * histogramHashPrime) >> (64 - h.tableBit))
+}
+
+func (h *histogramIndex) same(l1 int, l2 int) bool {
+	return h.sa.id[l1-1] == h.sb.id[l2-1]
+}
+
+// adds the lines of the first file to the index; fails if a chain of
+// different lines w/ the same hash gets too long.
+func (h *histogramIndex) scan(line1 int, count1 int) bool {
+	for ptr := line1 + count1 - 1; line1 <= ptr; ptr-- {
+		id := h.sa.id[ptr-1]
+		tableIndex := h.tableHash(id)
+		chainLength := 0
+		found := false
+		for rec := h.record[tableIndex]; rec != nil; rec = rec.next {
+			if h.sa.id[rec.ptr-1] == id {
+				h.nextPtr[ptr-h.ptrShift] = rec.ptr
+				rec.ptr = ptr
+				rec.cnt += 1
+				h.lineMap[ptr-h.ptrShift] = rec
+				found = true
+				break
+			}
+			chainLength += 1
+		}
+		if found { continue }
+		if chainLength == histogramMaxChainLength { return false }
+		rec := &histogramRecord{ ptr: ptr, cnt: 1, next: h.record[tableIndex] }
+		h.record[tableIndex] = rec
+		h.lineMap[ptr-h.ptrShift] = rec
+	}
+	return true
+}
+
+// tries the runs that go through line `bPtr` of the second file;
+// returns the next line of the second file to try.
+func (h *histogramIndex) tryLcs(lcs *histogramRegion, bPtr int, line1 int, count1 int, line2 int, count2 int) int {
+	bNext := bPtr + 1
+	end1 := line1 + count1 - 1
+	end2 := line2 + count2 - 1
+	for rec := h.record[h.tableHash(h.sb.id[bPtr-1])]; rec != nil; rec = rec.next {
+		if rec.cnt > h.cnt {
+			if !h.hasCommon { h.hasCommon = h.same(rec.ptr, bPtr) }
+			continue
+		}
+		as := rec.ptr
+		if !h.same(as, bPtr) { continue }
+		h.hasCommon = true
+		for {
+			np := h.nextPtr[as-h.ptrShift]
+			bs := bPtr
+			ae := as
+			be := bs
+			rc := rec.cnt
+			for line1 < as && line2 < bs && h.same(as - 1, bs - 1) {
+				as -= 1
+				bs -= 1
+				if 1 < rc { rc = min(rc, h.lineMap[as-h.ptrShift].cnt) }
+			}
+			for ae < end1 && be < end2 && h.same(ae + 1, be + 1) {
+				ae += 1
+				be += 1
+				if 1 < rc { rc = min(rc, h.lineMap[ae-h.ptrShift].cnt) }
+			}
+			if bNext <= be { bNext = be + 1 }
+			if lcs.end1 - lcs.begin1 < ae - as || rc < h.cnt {
+				*lcs = histogramRegion{ begin1: as, end1: ae, begin2: bs, end2: be }
+				h.cnt = rc
+			}
+			if np == 0 { break }
+			for np != 0 && np <= ae { np = h.nextPtr[np-h.ptrShift] }
+			if np == 0 { break }
+			as = np
+		}
+	}
+	return bNext
+}
+
+// returns the longest common run of the least common lines, and
+// whether the Myers diff should be used instead (when there's no such
+// run that's rare enough).
+func findHistogramLcs(sa *diffSide, sb *diffSide, line1 int, count1 int, line2 int, count2 int) (histogramRegion, bool) {
+	h := &histogramIndex{
+		sa: sa,
+		sb: sb,
+		lineMap: make([]*histogramRecord, count1),
+		nextPtr: make([]int, count1),
+		ptrShift: line1,
+	}
+	h.tableBit = 1
+	for v := 2; v < count1; v <<= 1 { h.tableBit += 1 }
+	h.record = make([]*histogramRecord, 1 << h.tableBit)
+	lcs := histogramRegion{}
+	if !h.scan(line1, count1) { return lcs, true }
+	h.cnt = histogramMaxChainLength + 1
+	for bPtr := line2; bPtr <= line2 + count2 - 1; {
+		bPtr = h.tryLcs(&lcs, bPtr, line1, count1, line2, count2)
+	}
+	return lcs, h.hasCommon && histogramMaxChainLength < h.cnt
+}
+
+func histogramDiff(sa *diffSide, sb *diffSide, idCount int, line1 int, count1 int, line2 int, count2 int) {
+	for {
+		if count1 <= 0 && count2 <= 0 { return }
+		if count1 <= 0 {
+			for i := range count2 { sb.setChanged(line2 - 1 + i, true) }
+			return
+		}
+		if count2 <= 0 {
+			for i := range count1 { sa.setChanged(line1 - 1 + i, true) }
+			return
+		}
+		lcs, fallBack := findHistogramLcs(sa, sb, line1, count1, line2, count2)
+		if fallBack {
+			// the Myers diff of just these lines.
+			subA := &diffSide{ line: sa.line[line1-1:line1-1+count1], id: sa.id[line1-1:line1-1+count1], changed: make([]bool, count1 + 2) }
+			subB := &diffSide{ line: sb.line[line2-1:line2-1+count2], id: sb.id[line2-1:line2-1+count2], changed: make([]bool, count2 + 2) }
+			diffRecord(subA, subB, idCount)
+			copy(sa.changed[line1:line1+count1], subA.changed[1:count1+1])
+			copy(sb.changed[line2:line2+count2], subB.changed[1:count2+1])
+			return
+		}
+		if lcs.begin1 == 0 && lcs.begin2 == 0 {
+			for i := range count1 { sa.setChanged(line1 - 1 + i, true) }
+			for i := range count2 { sb.setChanged(line2 - 1 + i, true) }
+			return
+		}
+		histogramDiff(sa, sb, idCount, line1, lcs.begin1 - line1, line2, lcs.begin2 - line2)
+		count1 = line1 + count1 - 1 - lcs.end1
+		line1 = lcs.end1 + 1
+		count2 = line2 + count2 - 1 - lcs.end2
+		line2 = lcs.end2 + 1
+	}
+}
+
+// git shows the nearest line before the patch that starts with a
+// letter, `_` or `$` (most likely the beginning of a function) in the
+// patch header. the line is truncated to 80 bytes.
+func findPatchContextLine(a []string, before int) string {
+	for i := before - 1; i >= 0; i-- {
+		l := a[i]
+		if len(l) <= 0 { continue }
+		c := l[0]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || c == '_' || c == '$' {
+			if len(l) > 80 { l = l[:80] }
+			return " " + strings.TrimRight(l, " \t\r\n")
+		}
+	}
+	return ""
+}
+
+// groups the edit script into patches with `context` lines of
+// context around the changes, in the same way as a unified diff.
+func makePatchList(a []string, b []string, edits []lineEdit, context int) []*DiffItemPatch {
+	res := make([]*DiffItemPatch, 0)
+	// the number of lines of a & b before each edit.
+	aPos := make([]int, len(edits) + 1)
+	bPos := make([]int, len(edits) + 1)
+	for i, e := range edits {
+		aPos[i+1] = aPos[i]
+		bPos[i+1] = bPos[i]
+		if e.Type != APPEND { aPos[i+1] += 1 }
+		if e.Type != DELETE { bPos[i+1] += 1 }
+	}
+	i := 0
+	for i < len(edits) {
+		if edits[i].Type == SAME { i += 1; continue }
+		start := max(i - context, 0)
+		// extend the patch as long as the next change is close enough
+		// for the context lines to overlap.
+		end := i
+		for {
+			for end < len(edits) && edits[end].Type != SAME { end += 1 }
+			next := end
+			for next < len(edits) && edits[next].Type == SAME { next += 1 }
+			if next >= len(edits) || next - end > 2 * context {
+				end = min(end + context, len(edits))
+				break
+			}
+			end = next
+		}
+		lineList := make([]AnnotatedLine, 0, end - start)
+		for j := start; j < end; j++ {
+			e := edits[j]
+			l := AnnotatedLine{ Type: e.Type, F1LineNum: int64(aPos[j]), F2LineNum: int64(bPos[j]) }
+			switch e.Type {
+			case SAME:
+				l.F1LineNum += 1
+				l.F2LineNum += 1
+				l.Line = strings.TrimSuffix(a[e.AIndex], "\n")
+			case DELETE:
+				l.F1LineNum += 1
+				l.Line = strings.TrimSuffix(a[e.AIndex], "\n")
+			case APPEND:
+				l.F2LineNum += 1
+				l.Line = strings.TrimSuffix(b[e.BIndex], "\n")
+			}
+			lineList = append(lineList, l)
+		}
+		// an empty range starts at the line before it, as in
+		// `@@ -0,0 +1,3 @@`.
+		lCount := aPos[end] - aPos[start]
+		rCount := bPos[end] - bPos[start]
+		lStart := aPos[start]
+		if lCount > 0 { lStart += 1 }
+		rStart := bPos[start]
+		if rCount > 0 { rStart += 1 }
+		res = append(res, &DiffItemPatch{
+			LStart: int64(lStart),
+			LLineCount: int64(lCount),
+			RStart: int64(rStart),
+			RLineCount: int64(rCount),
+			ContextLine: findPatchContextLine(a, aPos[start]),
+			LineList: lineList,
+		})
+		i = end
+	}
+	return res
+}
+
+func sameLines(a []string, b []string) bool {
+	if len(a) != len(b) { return false }
+	for i := range a {
+		if a[i] != b[i] { return false }
+	}
+	return true
+}
+
+// conflict markers always start at the beginning of a line.
+func appendConflictSide(res []string, l []string) []string {
+	for _, s := range l {
+		if !strings.HasSuffix(s, "\n") { s += "\n" }
+		res = append(res, s)
+	}
+	return res
+}
+
+// a part of the result of a three-way merge of lines. a clean chunk
+// only has `Line`; a conflicted one has the lines of the three
+// versions instead.
+type MergeChunk struct {
+	Conflict bool
+	Line []string
+	Base []string
+	Ours []string
+	Theirs []string
+}
+
+// a group of changed lines: `count1` lines at `index1` of the old
+// file are replaced w/ `count2` lines at `index2` of the new file.
+type lineChange struct {
+	index1 int
+	count1 int
+	index2 int
+	count2 int
+}
+
+func lineChangeList(edits []lineEdit) []lineChange {
+	res := make([]lineChange, 0)
+	i, j := 0, 0
+	inChange := false
+	for _, e := range edits {
+		if e.Type == SAME {
+			i = e.AIndex + 1
+			j = e.BIndex + 1
+			inChange = false
+			continue
+		}
+		if !inChange {
+			res = append(res, lineChange{ index1: i, index2: j })
+			inChange = true
+		}
+		c := &res[len(res)-1]
+		if e.Type == DELETE { c.count1 += 1; i += 1 }
+		if e.Type == APPEND { c.count2 += 1; j += 1 }
+	}
+	return res
+}
+
+const (
+	mergeRegionConflict = iota
+	mergeRegionOurs
+	mergeRegionTheirs
+	// a conflict where both sides turned out to be the same.
+	mergeRegionResolved
+)
+
+// a part of the merge: `count0` lines at `index0` of base, `count1`
+// lines at `index1` of ours & `count2` lines at `index2` of theirs.
+type mergeRegion struct {
+	mode int
+	index0 int
+	count0 int
+	index1 int
+	count1 int
+	index2 int
+	count2 int
+}
+
+type lineMergeOption struct {
+	// the diff used for both sides & for refining the conflicts.
+	diff diffOption
+	// also join the conflicts that only have lines w/o letters or
+	// digits in between.
+	joinNonAlnum bool
+}
+
+// how `git merge` & `git merge-tree` (i.e. merge-ort) merge files.
+var mergeOrtOption = lineMergeOption{ diff: diffOption{ histogram: true } }
+
+// how `git merge-file` merges files.
+var mergeFileOption = lineMergeOption{ joinNonAlnum: true }
+
+// three-way merge of lines, in the same way as `git merge` does so
+// that the result (esp. whether & where there are conflicts) is the
+// same as git's. see mergeLineChunksWith.
+func MergeLineChunks(base []string, ours []string, theirs []string) []MergeChunk {
+	return mergeLineChunksWith(base, ours, theirs, mergeOrtOption)
+}
+
+// this is a port of `xdl_do_merge` from git's xdiff w/ the "zealous"
+// level: the changes of both sides that overlap or touch each other
+// are a conflict unless they're the same; the lines that are the same
+// on both sides of a conflict are taken out of it; and the conflicts
+// w/ at most 3 lines in between are joined.
+//
+// when a conflict is split up by taking the common lines out, the
+// lines of base are only kept on the first part.
+func mergeLineChunksWith(base []string, ours []string, theirs []string, opt lineMergeOption) []MergeChunk {
+	s1 := lineChangeList(diffLinesWith(base, ours, opt.diff))
+	s2 := lineChangeList(diffLinesWith(base, theirs, opt.diff))
+	regionList := make([]mergeRegion, 0)
+	appendRegion := func(r mergeRegion) {
+		if len(regionList) > 0 {
+			m := &regionList[len(regionList)-1]
+			if r.index1 <= m.index1 + m.count1 || r.index2 <= m.index2 + m.count2 {
+				if m.mode != r.mode { m.mode = mergeRegionConflict }
+				m.count0 = max(m.index0 + m.count0, r.index0 + r.count0) - m.index0
+				m.count1 = r.index1 + r.count1 - m.index1
+				m.count2 = r.index2 + r.count2 - m.index2
+				return
+			}
+		}
+		regionList = append(regionList, r)
+	}
+	for len(s1) > 0 && len(s2) > 0 {
+		x1 := s1[0]
+		x2 := s2[0]
+		if x1.index1 + x1.count1 < x2.index1 {
+			appendRegion(mergeRegion{
+				mode: mergeRegionOurs,
+				index0: x1.index1, count0: x1.count1,
+				index1: x1.index2, count1: x1.count2,
+				index2: x2.index2 - x2.index1 + x1.index1, count2: x1.count1,
+			})
+			s1 = s1[1:]
+			continue
+		}
+		if x2.index1 + x2.count1 < x1.index1 {
+			appendRegion(mergeRegion{
+				mode: mergeRegionTheirs,
+				index0: x2.index1, count0: x2.count1,
+				index1: x1.index2 - x1.index1 + x2.index1, count1: x2.count1,
+				index2: x2.index2, count2: x2.count2,
+			})
+			s2 = s2[1:]
+			continue
+		}
+		if x1.index1 != x2.index1 || x1.count1 != x2.count1 ||
+			!sameLines(ours[x1.index2:x1.index2+x1.count2], theirs[x2.index2:x2.index2+x2.count2]) {
+			// the conflict covers both changes & the lines of base
+			// between them.
+			i0 := min(x1.index1, x2.index1)
+			end0 := max(x1.index1 + x1.count1, x2.index1 + x2.count1)
+			ffo1 := x1.index1 - i0
+			ffo2 := x2.index1 - i0
+			off1 := end0 - x1.index1 - x1.count1
+			off2 := end0 - x2.index1 - x2.count1
+			appendRegion(mergeRegion{
+				mode: mergeRegionConflict,
+				index0: i0, count0: end0 - i0,
+				index1: x1.index2 - ffo1, count1: x1.count2 + ffo1 + off1,
+				index2: x2.index2 - ffo2, count2: x2.count2 + ffo2 + off2,
+			})
+		}
+		// the change w/ the smaller end goes first; the other one can
+		// still overlap the next change of this side.
+		end1 := x1.index1 + x1.count1
+		end2 := x2.index1 + x2.count1
+		if end1 <= end2 { s1 = s1[1:] }
+		if end2 <= end1 { s2 = s2[1:] }
+	}
+	for _, x1 := range s1 {
+		appendRegion(mergeRegion{
+			mode: mergeRegionOurs,
+			index0: x1.index1, count0: x1.count1,
+			index1: x1.index2, count1: x1.count2,
+			index2: x1.index1 + len(theirs) - len(base), count2: x1.count1,
+		})
+	}
+	for _, x2 := range s2 {
+		appendRegion(mergeRegion{
+			mode: mergeRegionTheirs,
+			index0: x2.index1, count0: x2.count1,
+			index1: x2.index1 + len(ours) - len(base), count1: x2.count1,
+			index2: x2.index2, count2: x2.count2,
+		})
+	}
+	regionList = refineConflict(regionList, ours, theirs, opt.diff)
+	regionList = joinConflict(regionList, ours, opt.joinNonAlnum)
+
+	res := make([]MergeChunk, 0)
+	clean := func(l []string) {
+		if len(l) <= 0 { return }
+		if len(res) > 0 && !res[len(res)-1].Conflict {
+			res[len(res)-1].Line = append(res[len(res)-1].Line, l...)
+			return
+		}
+		res = append(res, MergeChunk{ Line: slices.Clone(l) })
+	}
+	i := 0
+	for _, m := range regionList {
+		if m.mode == mergeRegionResolved { continue }
+		clean(ours[i:m.index1])
+		switch m.mode {
+		case mergeRegionConflict:
+			res = append(res, MergeChunk{
+				Conflict: true,
+				Base: slices.Clone(base[m.index0:m.index0+m.count0]),
+				Ours: slices.Clone(ours[m.index1:m.index1+m.count1]),
+				Theirs: slices.Clone(theirs[m.index2:m.index2+m.count2]),
+			})
+		case mergeRegionOurs:
+			clean(ours[m.index1:m.index1+m.count1])
+		case mergeRegionTheirs:
+			clean(theirs[m.index2:m.index2+m.count2])
+		}
+		i = m.index1 + m.count1
+	}
+	clean(ours[i:])
+	return res
+}
+
+// whether the line has an ASCII letter or digit.
+func hasAlnum(l string) bool {
+	return strings.ContainsFunc(l, func(r rune) bool {
+		return ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9')
+	})
+}
+
+// splits each conflict into the parts where the two sides are
+// different; the lines in between are the same on both sides & thus
+// don't conflict (`xdl_refine_conflicts`).
+func refineConflict(regionList []mergeRegion, ours []string, theirs []string, opt diffOption) []mergeRegion {
+	res := make([]mergeRegion, 0, len(regionList))
+	for _, m := range regionList {
+		if m.mode != mergeRegionConflict || m.count1 <= 0 || m.count2 <= 0 {
+			res = append(res, m)
+			continue
+		}
+		o := ours[m.index1:m.index1+m.count1]
+		t := theirs[m.index2:m.index2+m.count2]
+		changeList := lineChangeList(diffLinesWith(o, t, opt))
+		if len(changeList) <= 0 {
+			m.mode = mergeRegionResolved
+			res = append(res, m)
+			continue
+		}
+		for i, c := range changeList {
+			r := mergeRegion{
+				mode: mergeRegionConflict,
+				index0: m.index0 + m.count0,
+				index1: m.index1 + c.index1, count1: c.count1,
+				index2: m.index2 + c.index2, count2: c.count2,
+			}
+			if i == 0 { r.index0 = m.index0; r.count0 = m.count0 }
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// joins the conflicts that are at most 3 lines apart, since it's
+// easier to resolve them as one (`xdl_simplify_non_conflicts`).
+func joinConflict(regionList []mergeRegion, ours []string, joinNonAlnum bool) []mergeRegion {
+	res := make([]mergeRegion, 0, len(regionList))
+	for _, m := range regionList {
+		if len(res) > 0 {
+			p := &res[len(res)-1]
+			between := ours[p.index1+p.count1:m.index1]
+			if p.mode == mergeRegionConflict && m.mode == mergeRegionConflict &&
+				(len(between) <= 3 || (joinNonAlnum && !slices.ContainsFunc(between, hasAlnum))) {
+				p.count0 = max(p.index0 + p.count0, m.index0 + m.count0) - p.index0
+				p.count1 = m.index1 + m.count1 - p.index1
+				p.count2 = m.index2 + m.count2 - p.index2
+				continue
+			}
+		}
+		res = append(res, m)
+	}
+	return res
+}
+
+// renders the chunks w/ the conflicting parts marked in the same way
+// as git does, i.e. `<<<<<<< {oursLabel}`, `=======` & `>>>>>>>
+// {theirsLabel}`.
+func RenderMergeChunk(chunkList []MergeChunk, oursLabel string, theirsLabel string) []string {
+	res := make([]string, 0)
+	for _, c := range chunkList {
+		if !c.Conflict {
+			res = append(res, c.Line...)
+			continue
+		}
+		res = append(res, fmt.Sprintf("<<<<<<< %s\n", oursLabel))
+		res = appendConflictSide(res, c.Ours)
+		res = append(res, "=======\n")
+		res = appendConflictSide(res, c.Theirs)
+		res = append(res, fmt.Sprintf(">>>>>>> %s\n", theirsLabel))
+	}
+	return res
+}
+
+// three-way merge of lines w/ the conflicts marked. returns the merged
+// lines & whether there is a conflict.
+func mergeLines(base []string, ours []string, theirs []string, oursLabel string, theirsLabel string) ([]string, bool) {
+	chunkList := MergeLineChunks(base, ours, theirs)
+	conflict := slices.ContainsFunc(chunkList, func(c MergeChunk) bool { return c.Conflict })
+	return RenderMergeChunk(chunkList, oursLabel, theirsLabel), conflict
+}
+
+// the edit script from the diff is not always the most readable one:
+// a group of added (or deleted) lines can often be "slid" up or down
+// w/o changing the result, e.g. an added function can either be
+// shown as `+}\n+\n+func f() {\n...` or `+func f() {\n...+}\n+\n`.
+// the functions below pick the same position as git does, i.e. they
+// are a port of `xdl_change_compact` and the "indent heuristic" from
+// git's xdiff.
+
+// the changed lines of one side. `changed` has one extra element on
+// both ends that's always false, so that a group never runs past the
+// beginning or the end.
+type diffSide struct {
+	line []string
+	id []int
+	changed []bool
+}
+
+func (s *diffSide) isChanged(i int) bool { return s.changed[i+1] }
+func (s *diffSide) setChanged(i int, v bool) { s.changed[i+1] = v }
+
+// a group of consecutive changed lines [start, end); can be empty.
+type diffGroup struct {
+	start int
+	end int
+}
+
+func (s *diffSide) groupInit() diffGroup {
+	g := diffGroup{}
+	for s.isChanged(g.end) { g.end += 1 }
+	return g
+}
+
+func (s *diffSide) groupNext(g *diffGroup) bool {
+	if g.end == len(s.line) { return false }
+	g.start = g.end + 1
+	g.end = g.start
+	for s.isChanged(g.end) { g.end += 1 }
+	return true
+}
+
+func (s *diffSide) groupPrevious(g *diffGroup) bool {
+	if g.start == 0 { return false }
+	g.end = g.start - 1
+	g.start = g.end
+	for s.isChanged(g.start - 1) { g.start -= 1 }
+	return true
+}
+
+func (s *diffSide) groupSlideDown(g *diffGroup) bool {
+	if g.end < len(s.line) && s.id[g.start] == s.id[g.end] {
+		s.setChanged(g.start, false)
+		s.setChanged(g.end, true)
+		g.start += 1
+		g.end += 1
+		for s.isChanged(g.end) { g.end += 1 }
+		return true
+	}
+	return false
+}
+
+func (s *diffSide) groupSlideUp(g *diffGroup) bool {
+	if g.start > 0 && s.id[g.start-1] == s.id[g.end-1] {
+		g.start -= 1
+		g.end -= 1
+		s.setChanged(g.start, true)
+		s.setChanged(g.end, false)
+		for s.isChanged(g.start - 1) { g.start -= 1 }
+		return true
+	}
+	return false
+}
+
+const (
+	indentHeuristicMaxIndent = 200
+	indentHeuristicMaxBlank = 20
+	indentHeuristicMaxSliding = 100
+	indentHeuristicStartOfFilePenalty = 1
+	indentHeuristicEndOfFilePenalty = 21
+	indentHeuristicTotalBlankWeight = -30
+	indentHeuristicPostBlankWeight = 6
+	indentHeuristicRelativeIndentPenalty = -4
+	indentHeuristicRelativeIndentWithBlankPenalty = 10
+	indentHeuristicRelativeOutdentPenalty = 24
+	indentHeuristicRelativeOutdentWithBlankPenalty = 17
+	indentHeuristicRelativeDedentPenalty = 23
+	indentHeuristicRelativeDedentWithBlankPenalty = 17
+	indentHeuristicIndentWeight = 60
+)
+
+// the indent of a line w/ tabs counted as up to 8 spaces; -1 if the
+// line is blank.
+func lineIndent(l string) int {
+	res := 0
+	for i := 0; i < len(l); i++ {
+		switch l[i] {
+		case ' ': res += 1
+		case '\t': res += 8 - res % 8
+		case '\n', '\r', '\v', '\f':
+		default: return res
+		}
+		if res >= indentHeuristicMaxIndent { return indentHeuristicMaxIndent }
+	}
+	return -1
+}
+
+type splitMeasurement struct {
+	endOfFile bool
+	indent int
+	preBlank int
+	preIndent int
+	postBlank int
+	postIndent int
+}
+
+type splitScore struct {
+	effectiveIndent int
+	penalty int
+}
+
+// measures the split right before line `split`.
+func (s *diffSide) measureSplit(split int) splitMeasurement {
+	m := splitMeasurement{ indent: -1, preIndent: -1, postIndent: -1 }
+	if split >= len(s.line) {
+		m.endOfFile = true
+	} else {
+		m.indent = lineIndent(s.line[split])
+	}
+	for i := split - 1; i >= 0; i-- {
+		m.preIndent = lineIndent(s.line[i])
+		if m.preIndent != -1 { break }
+		m.preBlank += 1
+		if m.preBlank == indentHeuristicMaxBlank { m.preIndent = 0; break }
+	}
+	for i := split + 1; i < len(s.line); i++ {
+		m.postIndent = lineIndent(s.line[i])
+		if m.postIndent != -1 { break }
+		m.postBlank += 1
+		if m.postBlank == indentHeuristicMaxBlank { m.postIndent = 0; break }
+	}
+	return m
+}
+
+func (score *splitScore) add(m splitMeasurement) {
+	if m.preIndent == -1 && m.preBlank == 0 { score.penalty += indentHeuristicStartOfFilePenalty }
+	if m.endOfFile { score.penalty += indentHeuristicEndOfFilePenalty }
+	postBlank := 0
+	if m.indent == -1 { postBlank = 1 + m.postBlank }
+	totalBlank := m.preBlank + postBlank
+	score.penalty += indentHeuristicTotalBlankWeight * totalBlank
+	score.penalty += indentHeuristicPostBlankWeight * postBlank
+	indent := m.indent
+	if indent == -1 { indent = m.postIndent }
+	anyBlank := totalBlank != 0
+	score.effectiveIndent += indent
+	switch {
+	case indent == -1 || m.preIndent == -1 || indent == m.preIndent:
+	case indent > m.preIndent:
+		if anyBlank {
+			score.penalty += indentHeuristicRelativeIndentWithBlankPenalty
+		} else {
+			score.penalty += indentHeuristicRelativeIndentPenalty
+		}
+	case m.postIndent != -1 && m.postIndent > indent:
+		if anyBlank {
+			score.penalty += indentHeuristicRelativeOutdentWithBlankPenalty
+		} else {
+			score.penalty += indentHeuristicRelativeOutdentPenalty
+		}
+	default:
+		if anyBlank {
+			score.penalty += indentHeuristicRelativeDedentWithBlankPenalty
+		} else {
+			score.penalty += indentHeuristicRelativeDedentPenalty
+		}
+	}
+}
+
+func (score splitScore) compare(other splitScore) int {
+	cmpIndent := 0
+	if score.effectiveIndent > other.effectiveIndent { cmpIndent = 1 }
+	if score.effectiveIndent < other.effectiveIndent { cmpIndent = -1 }
+	return indentHeuristicIndentWeight * cmpIndent + (score.penalty - other.penalty)
+}
+
+// slides the groups of changed lines of `s`; `o` is the other side
+// and is kept in sync. w/o the indent heuristic a group that can't be
+// lined up w/ the other side stays as far down as possible.
+func (s *diffSide) compact(o *diffSide, indentHeuristic bool) {
+	g := s.groupInit()
+	og := o.groupInit()
+	for {
+		if g.end != g.start {
+			var groupSize, earliestEnd int
+			endMatchingOther := -1
+			// shift the group up & then down as far as possible,
+			// merging it w/ the groups it runs into.
+			for {
+				groupSize = g.end - g.start
+				endMatchingOther = -1
+				for s.groupSlideUp(&g) { o.groupPrevious(&og) }
+				earliestEnd = g.end
+				if og.end > og.start { endMatchingOther = g.end }
+				for s.groupSlideDown(&g) {
+					o.groupNext(&og)
+					if og.end > og.start { endMatchingOther = g.end }
+				}
+				if groupSize == g.end - g.start { break }
+			}
+			if g.end == earliestEnd {
+				// can't be shifted at all.
+			} else if endMatchingOther != -1 {
+				// line up w/ the last group of changes on the other
+				// side it can be aligned with.
+				for og.end == og.start {
+					s.groupSlideUp(&g)
+					o.groupPrevious(&og)
+				}
+			} else if indentHeuristic {
+				bestShift := -1
+				var bestScore splitScore
+				shift := max(earliestEnd, g.end - groupSize - 1, g.end - indentHeuristicMaxSliding)
+				for ; shift <= g.end; shift++ {
+					score := splitScore{}
+					score.add(s.measureSplit(shift))
+					score.add(s.measureSplit(shift - groupSize))
+					if bestShift == -1 || score.compare(bestScore) <= 0 {
+						bestScore = score
+						bestShift = shift
+					}
+				}
+				for g.end > bestShift {
+					s.groupSlideUp(&g)
+					o.groupPrevious(&og)
+				}
+			}
+		}
+		if !s.groupNext(&g) { break }
+		o.groupNext(&og)
+	}
+}
+
+// the edit script of the changed lines of both sides.
+func editScript(sa *diffSide, sb *diffSide) []lineEdit {
+	a := sa.line
+	b := sb.line
+	res := make([]lineEdit, 0, len(a) + len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && sa.isChanged(i):
+			res = append(res, lineEdit{ Type: DELETE, AIndex: i })
+			i += 1
+		case j < len(b) && sb.isChanged(j):
+			res = append(res, lineEdit{ Type: APPEND, BIndex: j })
+			j += 1
+		default:
+			res = append(res, lineEdit{ Type: SAME, AIndex: i, BIndex: j })
+			i += 1
+			j += 1
+		}
+	}
+	return res
+}
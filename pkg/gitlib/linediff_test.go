@@ -0,0 +1,228 @@
+package gitlib
+
+import (
+	"math/rand"
+	"path"
+	"strings"
+	"testing"
+)
+
+// a few lines that repeat a lot, so that there are many ways to line
+// the changes up.
+var testLinePool = []string{
+	"\tfoo\n", "\tbar\n", "foo\n", "}\n", "\n", "x\n", "\t\tbaz\n", "y\n",
+}
+
+func randomLines(r *rand.Rand, pool []string, n int) []string {
+	res := make([]string, 0, n)
+	for range n { res = append(res, pool[r.Intn(len(pool))]) }
+	return res
+}
+
+// deletes, replaces & adds lines at random.
+func mutateLines(r *rand.Rand, pool []string, l []string) []string {
+	res := make([]string, 0, len(l))
+	for _, s := range l {
+		switch r.Intn(6) {
+		case 0:
+		case 1: res = append(res, pool[r.Intn(len(pool))])
+		case 2: res = append(res, s, pool[r.Intn(len(pool))])
+		default: res = append(res, s)
+		}
+	}
+	return res
+}
+
+// removes the newline at the end of the file at random.
+func maybeNoNewlineAtEnd(r *rand.Rand, l []string) []string {
+	if len(l) > 0 && len(l[len(l)-1]) > 1 && r.Intn(4) == 0 {
+		l[len(l)-1] = strings.TrimSuffix(l[len(l)-1], "\n")
+	}
+	return l
+}
+
+func renderEdit(a []string, b []string, edits []lineEdit) string {
+	var res strings.Builder
+	for _, e := range edits {
+		switch e.Type {
+		case SAME: res.WriteString(" " + a[e.AIndex])
+		case DELETE: res.WriteString("-" + a[e.AIndex])
+		case APPEND: res.WriteString("+" + b[e.BIndex])
+		}
+	}
+	return res.String()
+}
+
+// the whole file as one hunk, w/o the header.
+func gitDiffLines(t *testing.T, dir string, a []string, b []string) string {
+	t.Helper()
+	pa := path.Join(dir, "a")
+	pb := path.Join(dir, "b")
+	writeTestFile(t, pa, strings.Join(a, ""))
+	writeTestFile(t, pb, strings.Join(b, ""))
+	out, _ := runGitRaw(dir, "", "diff", "--no-index", "--no-color", "-U1000000", pa, pb)
+	l := strings.SplitAfter(out, "\n")
+	for i, s := range l {
+		if strings.HasPrefix(s, "@@") { return strings.Join(l[i+1:], "") }
+	}
+	// no changes.
+	return renderEdit(a, b, diffLines(a, a))
+}
+
+func TestDiffLines(t *testing.T) {
+	requireGit(t)
+	dir := t.TempDir()
+	caseList := [][2]string{
+		{ "", "a\n" },
+		{ "a\n", "" },
+		{ "a\nb\nc\n", "a\nb\nc\n" },
+		{ "a\nb\nc\n", "a\nc\n" },
+		{ "a\nb\nc\n", "x\ny\nz\n" },
+		// an added function is shown as a whole, not split by the
+		// closing braces of the functions around it.
+		{
+			"func a() {\n\treturn\n}\n\nfunc c() {\n\treturn\n}\n",
+			"func a() {\n\treturn\n}\n\nfunc b() {\n\treturn\n}\n\nfunc c() {\n\treturn\n}\n",
+		},
+		{ "if x {\n\tfoo\n}\n", "if x {\n\tfoo\n\tbar\n}\nif y {\n\tfoo\n\tbar\n}\n" },
+	}
+	check := func(a []string, b []string) {
+		t.Helper()
+		want := gitDiffLines(t, dir, a, b)
+		if got := renderEdit(a, b, diffLines(a, b)); got != want {
+			t.Errorf("diff of %q & %q:\n%s\nwant (git diff):\n%s", a, b, got, want)
+		}
+	}
+	for _, c := range caseList {
+		check(splitLines([]byte(c[0])), splitLines([]byte(c[1])))
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := range 300 {
+		a := randomLines(r, testLinePool, 3 + r.Intn(12 + i / 10))
+		check(a, mutateLines(r, testLinePool, a))
+	}
+}
+
+func gitMergeFile(t *testing.T, dir string, base []string, ours []string, theirs []string) string {
+	t.Helper()
+	writeTestFile(t, path.Join(dir, "base"), strings.Join(base, ""))
+	writeTestFile(t, path.Join(dir, "ours"), strings.Join(ours, ""))
+	writeTestFile(t, path.Join(dir, "theirs"), strings.Join(theirs, ""))
+	// exits w/ the number of conflicts.
+	out, _ := runGitRaw(dir, "", "merge-file", "-p", "-L", "ours", "-L", "base", "-L", "theirs", "ours", "base", "theirs")
+	return out
+}
+
+// the changes next to each other are a conflict even if they don't
+// overlap.
+func TestMergeLineChunksAdjacentChange(t *testing.T) {
+	base := []string{ "\tfoo\n", "\tfoo\n", "\tbar\n" }
+	ours := []string{ "\tfoo\n", "\tbar\n" }
+	theirs := []string{ "\tfoo\n", "\tfoo\n", "\tbaz\n", "\tbar\n" }
+	for _, opt := range []lineMergeOption{ mergeOrtOption, mergeFileOption } {
+		got := mergeLineChunksWith(base, ours, theirs, opt)
+		want := []MergeChunk{
+			{ Line: []string{ "\tfoo\n" } },
+			{
+				Conflict: true,
+				Base: []string{ "\tfoo\n" },
+				Ours: []string{},
+				Theirs: []string{ "\tfoo\n", "\tbaz\n" },
+			},
+			{ Line: []string{ "\tbar\n" } },
+		}
+		if len(got) != len(want) {
+			t.Fatalf("got %+v, want %+v", got, want)
+		}
+		for i := range got {
+			if got[i].Conflict != want[i].Conflict ||
+				!sameLines(got[i].Line, want[i].Line) ||
+				!sameLines(got[i].Base, want[i].Base) ||
+				!sameLines(got[i].Ours, want[i].Ours) ||
+				!sameLines(got[i].Theirs, want[i].Theirs) {
+				t.Fatalf("got %+v, want %+v", got, want)
+			}
+		}
+	}
+}
+
+func TestMergeLines(t *testing.T) {
+	requireGit(t)
+	dir := t.TempDir()
+	caseList := [][3]string{
+		{ "a\nb\nc\n", "a\nb\nc\n", "a\nb\nc\n" },
+		{ "a\nb\nc\n", "a\nB\nc\n", "a\nb\nc\n" },
+		{ "a\nb\nc\n", "a\nb\nc\n", "a\nb\nC\n" },
+		{ "a\nb\nc\n", "A\nb\nc\n", "a\nb\nC\n" },
+		{ "a\nb\nc\n", "a\nB\nc\n", "a\nB\nc\n" },
+		{ "a\nb\nc\n", "a\nB\nc\n", "a\nX\nc\n" },
+		{ "a\nb\nc\n", "a\nc\n", "a\nX\nc\n" },
+		{ "\tfoo\n\tfoo\n\tbar", "\tfoo\n\tbar", "\tfoo\n\tfoo\nx\n\tbar" },
+		// the lines that are the same on both sides are not part of
+		// the conflict.
+		{ "1\n2\n3\n4\n5\n", "1\nA\n3\nB\n5\n", "1\nC\n3\nD\n5\n" },
+		{ "1\n2\n3\n4\n5\n6\n7\n8\n", "1\nA\n3\n4\n5\n6\nB\n8\n", "1\nC\n3\n4\n5\n6\nD\n8\n" },
+		{ "1\n2\n}\n}\n}\n}\n7\n", "1\nA\n}\n}\n}\n}\nB\n", "1\nC\n}\n}\n}\n}\nD\n" },
+		// no newline at the end.
+		{ "a\nb", "a\nc", "a\nd" },
+		{ "a\nb\n", "a\nb", "a\nb\nc\n" },
+	}
+	check := func(base []string, ours []string, theirs []string) {
+		t.Helper()
+		want := gitMergeFile(t, dir, base, ours, theirs)
+		got := strings.Join(RenderMergeChunk(mergeLineChunksWith(base, ours, theirs, mergeFileOption), "ours", "theirs"), "")
+		if got != want {
+			t.Errorf("merge of %q, %q & %q:\n%s\nwant (git merge-file):\n%s", base, ours, theirs, got, want)
+		}
+	}
+	for _, c := range caseList {
+		check(splitLines([]byte(c[0])), splitLines([]byte(c[1])), splitLines([]byte(c[2])))
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := range 300 {
+		base := randomLines(r, testLinePool, 3 + r.Intn(12 + i / 10))
+		ours := maybeNoNewlineAtEnd(r, mutateLines(r, testLinePool, base))
+		theirs := maybeNoNewlineAtEnd(r, mutateLines(r, testLinePool, base))
+		check(maybeNoNewlineAtEnd(r, base), ours, theirs)
+	}
+}
+
+// the file `f` merged by `git merge-tree`, w/ the branches named
+// "ours" & "theirs".
+func gitMergeTreeFile(t *testing.T, dir string, base []string, ours []string, theirs []string) string {
+	t.Helper()
+	commit := func(l []string, parent ...string) string {
+		blob := runGit(t, dir, strings.Join(l, ""), "hash-object", "-w", "--stdin")
+		tree := runGit(t, dir, "100644 blob " + blob + "\tf\n", "mktree")
+		arg := []string{ "commit-tree", tree, "-m", "x" }
+		for _, p := range parent { arg = append(arg, "-p", p) }
+		return runGit(t, dir, "", arg...)
+	}
+	b := commit(base)
+	runGit(t, dir, "", "update-ref", "refs/heads/ours", commit(ours, b))
+	runGit(t, dir, "", "update-ref", "refs/heads/theirs", commit(theirs, b))
+	// exits w/ 1 on conflicts; the first line is the tree.
+	out, _ := runGitRaw(dir, "", "merge-tree", "--write-tree", "ours", "theirs")
+	tree, _, _ := strings.Cut(out, "\n")
+	res, err := runGitRaw(dir, "", "cat-file", "blob", tree + ":f")
+	if err != nil { t.Fatalf("merge-tree: %v\n%s", err, out) }
+	return res
+}
+
+func TestMergeLineChunks(t *testing.T) {
+	requireGit(t)
+	dir := t.TempDir()
+	runGit(t, dir, "", "init", "-q", "--bare")
+	r := rand.New(rand.NewSource(2))
+	for i := range 200 {
+		base := randomLines(r, testLinePool, 3 + r.Intn(12 + i / 5))
+		ours := maybeNoNewlineAtEnd(r, mutateLines(r, testLinePool, base))
+		theirs := maybeNoNewlineAtEnd(r, mutateLines(r, testLinePool, base))
+		base = maybeNoNewlineAtEnd(r, base)
+		want := gitMergeTreeFile(t, dir, base, ours, theirs)
+		got := strings.Join(RenderMergeChunk(MergeLineChunks(base, ours, theirs), "ours", "theirs"), "")
+		if got != want {
+			t.Errorf("merge of %q, %q & %q:\n%s\nwant (git merge-tree):\n%s", base, ours, theirs, got, want)
+		}
+	}
+}
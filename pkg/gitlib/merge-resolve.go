@@ -0,0 +1,181 @@
+package gitlib
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// resolving the conflicts of a merge w/o a worktree, i.e. what one
+// would do w/ `git merge`, an editor & `git commit` locally. see
+// docs/merge-conflict-resolution.org.
+
+// returned when either of the branches has been updated since the
+// conflicts were shown to the user.
+var ErrMergeTargetMoved = errors.New("The branches have been updated since the conflicts were checked")
+// returned when not every conflicted file is resolved.
+var ErrUnresolvedConflict = errors.New("Not every conflicted file is resolved")
+
+// a file in conflict. the stages are nil when the file doesn't exist
+// on that side (e.g. it's deleted on one side & modified on the
+// other).
+type ConflictedFile struct {
+	Path string
+	Base *MergeCheckConflictedFileInfo
+	Ours *MergeCheckConflictedFileInfo
+	Theirs *MergeCheckConflictedFileInfo
+	// whether the file can be resolved line by line. binary files,
+	// symbolic links, submodules & files that don't exist on both
+	// sides can only be resolved by taking one side as a whole.
+	Mergeable bool
+	// only when Mergeable.
+	ChunkList []MergeChunk
+}
+
+type MergeConflictDetail struct {
+	// the commits the conflicts are computed from; they need to be
+	// passed to CommitMergeResolution.
+	OursId string
+	TheirsId string
+	Result *MergeCheckResult
+	FileList []*ConflictedFile
+}
+
+// groups the conflicted versions by file. the files that only exist
+// in the merge base (e.g. the old name of a file renamed differently
+// on both sides) are left out since there's nothing to resolve.
+func groupConflictedFile(l []MergeCheckConflictedFileInfo) []*ConflictedFile {
+	fileMap := make(map[string]*ConflictedFile)
+	for i := range l {
+		v := &l[i]
+		f, ok := fileMap[v.FileName]
+		if !ok {
+			f = &ConflictedFile{ Path: v.FileName }
+			fileMap[v.FileName] = f
+		}
+		switch v.Stage {
+		case 1: f.Base = v
+		case 2: f.Ours = v
+		case 3: f.Theirs = v
+		}
+	}
+	res := make([]*ConflictedFile, 0, len(fileMap))
+	for _, f := range fileMap {
+		if f.Ours == nil && f.Theirs == nil { continue }
+		res = append(res, f)
+	}
+	slices.SortFunc(res, func(a, b *ConflictedFile) int { return strings.Compare(a.Path, b.Path) })
+	return res
+}
+
+func isLineMergeableMode(m int) bool {
+	return m == TREE_NORMAL_FILE || m == TREE_EXECUTABLE_FILE
+}
+
+func (gr LocalGitRepository) fillMergeChunk(f *ConflictedFile) error {
+	if f.Ours == nil || f.Theirs == nil { return nil }
+	if !isLineMergeableMode(f.Ours.Mode) || !isLineMergeableMode(f.Theirs.Mode) { return nil }
+	if f.Base != nil && !isLineMergeableMode(f.Base.Mode) { return nil }
+	var baseData []byte
+	var err error
+	if f.Base != nil {
+		baseData, err = gr.readBlobData(f.Base.ObjectId)
+		if err != nil { return err }
+	}
+	oursData, err := gr.readBlobData(f.Ours.ObjectId)
+	if err != nil { return err }
+	theirsData, err := gr.readBlobData(f.Theirs.ObjectId)
+	if err != nil { return err }
+	if isBinaryContent(baseData) || isBinaryContent(oursData) || isBinaryContent(theirsData) { return nil }
+	f.Mergeable = true
+	f.ChunkList = MergeLineChunks(splitLines(baseData), splitLines(oursData), splitLines(theirsData))
+	return nil
+}
+
+// fetches the provider's branch and computes the conflicts of merging
+// it into `localBranch`, file by file.
+func (gr LocalGitRepository) GetMergeConflictDetail(localBranch string, remote string, remoteBranch string) (*MergeConflictDetail, error) {
+	gr, oursId, theirsId, err := gr.fetchMergeTarget(localBranch, remote, remoteBranch)
+	if err != nil { return nil, err }
+	mr, err := gr.checkMergeConflict(oursId, theirsId, localBranch, remote, remoteBranch)
+	if err != nil { return nil, err }
+	res := &MergeConflictDetail{
+		OursId: oursId,
+		TheirsId: theirsId,
+		Result: mr,
+		FileList: groupConflictedFile(mr.FileInfo),
+	}
+	for _, f := range res.FileList {
+		err = gr.fillMergeChunk(f)
+		if err != nil { return nil, err }
+	}
+	return res, nil
+}
+
+// how a conflicted file is resolved: it's either deleted, set to an
+// existing blob (`ObjectId`) or set to `Data`. `Mode` is the mode of
+// the file in the result; 0 keeps the mode from the merge.
+type MergeResolution struct {
+	Delete bool
+	Mode int
+	ObjectId string
+	Data []byte
+}
+
+// merges the provider's branch into `localBranch` like Merge does, but
+// w/ the conflicted files replaced by `resolution` (keyed by path),
+// and returns the id of the merge commit. `oursId` & `theirsId` are
+// the ones from GetMergeConflictDetail; the merge is refused w/
+// ErrMergeTargetMoved when either of the branches has been updated
+// since then.
+func (gr LocalGitRepository) CommitMergeResolution(remote string, remoteBranch string, localBranch string, oursId string, theirsId string, resolution map[string]*MergeResolution, author string, email string) (string, error) {
+	gr, currentOursId, currentTheirsId, err := gr.fetchMergeTarget(localBranch, remote, remoteBranch)
+	if err != nil { return "", err }
+	if currentOursId != oursId || currentTheirsId != theirsId { return "", ErrMergeTargetMoved }
+	providerFullName := mergeTargetName(remote, remoteBranch)
+	mr, err := gr.MergeCommit(oursId, theirsId, localBranch, providerFullName, true)
+	if err != nil { return "", fmt.Errorf("Failed while merge-tree: %s", err.Error()) }
+	for _, f := range groupConflictedFile(mr.FileInfo) {
+		if _, ok := resolution[f.Path]; !ok { return "", ErrUnresolvedConflict }
+	}
+	merged := make(map[string]treeEntry)
+	err = gr.flattenTree(mr.TreeId, "", merged)
+	if err != nil { return "", err }
+	for p, v := range resolution {
+		if v.Delete {
+			delete(merged, p)
+			continue
+		}
+		mode := v.Mode
+		if mode == 0 {
+			e, ok := merged[p]
+			mode = TREE_NORMAL_FILE
+			if ok { mode = e.Mode }
+		}
+		id := v.ObjectId
+		if len(id) <= 0 {
+			id, err = gr.WriteLooseObject(BLOB, v.Data)
+			if err != nil { return "", err }
+		}
+		merged[p] = treeEntry{ Mode: mode, Id: id }
+	}
+	treeId, err := writeFlattenedTree(merged, gr.WriteLooseObject)
+	if err != nil { return "", err }
+	now := time.Now()
+	cobj := &CommitObject{
+		TreeObjId: treeId,
+		ParentIdList: []string{ oursId, theirsId },
+		AuthorInfo: AuthorTime{ AuthorName: author, AuthorEmail: email, Time: now },
+		CommitterInfo: AuthorTime{ AuthorName: author, AuthorEmail: email, Time: now },
+		CommitMessage: fmt.Sprintf("merge: from %s to %s", providerFullName, localBranch),
+	}
+	commitId, err := gr.WriteLooseObject(COMMIT, []byte(cobj.RenderAsString()))
+	if err != nil { return "", fmt.Errorf("Failed while writing commit: %s", err.Error()) }
+	// a push could still have come in while merging.
+	err = gr.WriteRef(fmt.Sprintf("refs/heads/%s", localBranch), commitId, oursId)
+	if errors.Is(err, ErrRefChanged) { return "", ErrMergeTargetMoved }
+	if err != nil { return "", err }
+	return commitId, nil
+}
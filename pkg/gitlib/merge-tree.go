@@ -0,0 +1,503 @@
+package gitlib
+
+import (
+	"bytes"
+	"container/heap"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// three-way merge of trees, i.e. what `git merge-tree` does. the
+// result is reported in the same way as `git merge-tree -z`.
+
+var ErrNoMergeBase = errors.New("refusing to merge unrelated histories")
+
+func (gr LocalGitRepository) readCommit(commitId string) (*CommitObject, error) {
+	gobj, err := gr.ReadObject(commitId)
+	if err != nil { return nil, err }
+	cobj, ok := gobj.(*CommitObject)
+	if !ok { return nil, errors.New("Not a commit object") }
+	return cobj, nil
+}
+
+const (
+	mergeBaseFromA uint8 = 1
+	mergeBaseFromB uint8 = 2
+)
+
+// commits ordered by commit time, the most recent one first.
+type commitQueue []*CommitObject
+func (q commitQueue) Len() int { return len(q) }
+func (q commitQueue) Less(i, j int) bool { return q[i].CommitTime.After(q[j].CommitTime) }
+func (q commitQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *commitQueue) Push(x any) { *q = append(*q, x.(*CommitObject)) }
+func (q *commitQueue) Pop() any {
+	old := *q
+	n := len(old)
+	res := old[n-1]
+	*q = old[:n-1]
+	return res
+}
+
+// returns the most recent common ancestor of two commits. both
+// histories are walked from the newest commit to the oldest, marking
+// each commit w/ the side(s) it's reachable from; the first commit
+// that's reachable from both sides is the merge base.
+func (gr LocalGitRepository) MergeBase(a string, b string) (string, error) {
+	if a == b { return a, nil }
+	flag := make(map[string]uint8)
+	q := make(commitQueue, 0)
+	push := func(commitId string, f uint8) error {
+		cobj, err := gr.readCommit(commitId)
+		if err != nil { return err }
+		flag[commitId] |= f
+		heap.Push(&q, cobj)
+		return nil
+	}
+	err := push(a, mergeBaseFromA)
+	if err != nil { return "", err }
+	err = push(b, mergeBaseFromB)
+	if err != nil { return "", err }
+	for q.Len() > 0 {
+		c := heap.Pop(&q).(*CommitObject)
+		f := flag[c.Id]
+		if f == mergeBaseFromA | mergeBaseFromB { return c.Id, nil }
+		for _, p := range c.ParentIdList {
+			if flag[p] & f == f { continue }
+			err = push(p, f)
+			if err != nil { return "", err }
+		}
+	}
+	return "", ErrNoMergeBase
+}
+
+// called w/ every object the merge creates; either only computes its
+// id (when checking for conflicts) or writes it as well.
+type mergeObjectWriter func(t GitObjectType, data []byte) (string, error)
+
+type mergeTreeNode struct {
+	entry map[string]treeEntry
+	child map[string]*mergeTreeNode
+}
+
+func newMergeTreeNode() *mergeTreeNode {
+	return &mergeTreeNode{
+		entry: make(map[string]treeEntry),
+		child: make(map[string]*mergeTreeNode),
+	}
+}
+
+// builds the nested tree objects from a flattened tree.
+func writeFlattenedTree(l map[string]treeEntry, put mergeObjectWriter) (string, error) {
+	root := newMergeTreeNode()
+	for p, e := range l {
+		n := root
+		pathList := strings.Split(p, "/")
+		for _, k := range pathList[:len(pathList)-1] {
+			c, ok := n.child[k]
+			if !ok {
+				c = newMergeTreeNode()
+				n.child[k] = c
+			}
+			n = c
+		}
+		n.entry[pathList[len(pathList)-1]] = e
+	}
+	return writeMergeTreeNode(root, put)
+}
+
+func writeMergeTreeNode(n *mergeTreeNode, put mergeObjectWriter) (string, error) {
+	type item struct {
+		name string
+		sortKey string
+		mode int
+		id string
+	}
+	itemList := make([]item, 0, len(n.entry) + len(n.child))
+	for k, c := range n.child {
+		id, err := writeMergeTreeNode(c, put)
+		if err != nil { return "", err }
+		itemList = append(itemList, item{ name: k, sortKey: k + "/", mode: TREE_TREE_OBJECT, id: id })
+	}
+	for k, e := range n.entry {
+		itemList = append(itemList, item{ name: k, sortKey: k, mode: e.Mode, id: e.Id })
+	}
+	// git sorts tree entries as if the names of subtrees end w/ a
+	// slash.
+	slices.SortFunc(itemList, func(a, b item) int { return strings.Compare(a.sortKey, b.sortKey) })
+	b := new(bytes.Buffer)
+	for _, v := range itemList {
+		// NOTE: the mode of subtrees is "40000" in the actual tree
+		// objects, not "040000".
+		fmt.Fprintf(b, "%d %s\x00", v.mode, v.name)
+		b.Write(hexStringToBytes(v.id))
+	}
+	return put(TREE, b.Bytes())
+}
+
+type TreeMergeResult struct {
+	TreeId string
+	Conflict bool
+	FileInfo []MergeCheckConflictedFileInfo
+	Message []MergeCheckInformationalMessage
+}
+
+type treeMerger struct {
+	gr LocalGitRepository
+	oursLabel string
+	theirsLabel string
+	put mergeObjectWriter
+	res *TreeMergeResult
+	// the path a version of a file had before it's moved by a rename
+	// on the other side.
+	oursOrigin map[string]string
+	theirsOrigin map[string]string
+	// the files renamed on one side & deleted on the other, which are
+	// kept where they were renamed to.
+	renameDelete map[string]bool
+}
+
+func (tm *treeMerger) message(t string, msg string, pathList ...string) {
+	tm.res.Message = append(tm.res.Message, MergeCheckInformationalMessage{
+		Path: pathList,
+		Type: t,
+		Message: msg,
+	})
+}
+
+// records the conflicted versions of a file; stage 1 is the merge
+// base, 2 is ours, 3 is theirs.
+func (tm *treeMerger) conflict(p string, stage int, e *treeEntry) {
+	tm.res.Conflict = true
+	if e == nil { return }
+	tm.res.FileInfo = append(tm.res.FileInfo, MergeCheckConflictedFileInfo{
+		Mode: e.Mode,
+		ObjectId: e.Id,
+		Stage: stage,
+		FileName: p,
+	})
+}
+
+// merges a file changed on both sides. `base` is nil when the file
+// was added on both sides. returns the merged file & whether the merge
+// is clean; in the case of conflict the result contains the conflict
+// markers, or is our version if the file can't be merged at all.
+func (tm *treeMerger) mergeFile(p string, base *treeEntry, ours treeEntry, theirs treeEntry) (treeEntry, bool, error) {
+	res, clean, err := tm.mergeFileContent(p, base, ours, theirs)
+	if err != nil { return treeEntry{}, false, err }
+	// reported for every kind of conflict, same as git.
+	if !clean {
+		reason := "content"
+		if base == nil { reason = "add/add" }
+		if res.Mode == TREE_SUBMODULE { reason = "submodule" }
+		tm.message("CONFLICT (contents)", fmt.Sprintf("CONFLICT (%s): Merge conflict in %s", reason, p), p)
+	}
+	return res, clean, nil
+}
+
+// same as `handle_content_merge` of git's merge-ort.
+func (tm *treeMerger) mergeFileContent(p string, base *treeEntry, ours treeEntry, theirs treeEntry) (treeEntry, bool, error) {
+	if treeModeKind(ours.Mode) != treeModeKind(theirs.Mode) { return ours, false, nil }
+	baseMode := 0
+	baseId := ""
+	if base != nil {
+		baseMode = base.Mode
+		baseId = base.Id
+	}
+	clean := true
+	mode := theirs.Mode
+	if ours.Mode != theirs.Mode && ours.Mode != baseMode {
+		mode = ours.Mode
+		clean = theirs.Mode == baseMode
+	}
+	switch {
+	case ours.Id == theirs.Id || ours.Id == baseId:
+		return treeEntry{ Mode: mode, Id: theirs.Id }, clean, nil
+	case theirs.Id == baseId:
+		return treeEntry{ Mode: mode, Id: ours.Id }, clean, nil
+	case ours.Mode == TREE_SUBMODULE:
+		tm.message("CONFLICT (submodule)", fmt.Sprintf("Failed to merge submodule %s", p), p)
+		return treeEntry{ Mode: mode, Id: ours.Id }, false, nil
+	case ours.Mode == TREE_SYMBOLIC_LINK:
+		return treeEntry{ Mode: mode, Id: ours.Id }, false, nil
+	}
+	// the labels have the paths as well when the file is renamed.
+	oursLabel, theirsLabel := tm.oursLabel, tm.theirsLabel
+	oursPath, theirsPath := tm.oursOrigin[p], tm.theirsOrigin[p]
+	if len(oursPath) > 0 || len(theirsPath) > 0 {
+		if len(oursPath) <= 0 { oursPath = p }
+		if len(theirsPath) <= 0 { theirsPath = p }
+		oursLabel = fmt.Sprintf("%s:%s", oursLabel, oursPath)
+		theirsLabel = fmt.Sprintf("%s:%s", theirsLabel, theirsPath)
+	}
+	// a base of another kind (e.g. a symbolic link) is merged as if
+	// there were none.
+	var baseData []byte
+	var err error
+	if base != nil && treeModeKind(base.Mode) == treeModeKind(ours.Mode) {
+		baseData, err = tm.gr.readBlobData(base.Id)
+		if err != nil { return treeEntry{}, false, err }
+	}
+	oursData, err := tm.gr.readBlobData(ours.Id)
+	if err != nil { return treeEntry{}, false, err }
+	theirsData, err := tm.gr.readBlobData(theirs.Id)
+	if err != nil { return treeEntry{}, false, err }
+	if isBinaryContent(baseData) || isBinaryContent(oursData) || isBinaryContent(theirsData) {
+		tm.message("CONFLICT (binary)", fmt.Sprintf("warning: Cannot merge binary files: %s (%s vs. %s)", p, oursLabel, theirsLabel), p)
+		tm.message("Auto-merging", fmt.Sprintf("Auto-merging %s", p), p)
+		return treeEntry{ Mode: mode, Id: ours.Id }, false, nil
+	}
+	merged, hasConflict := mergeLines(splitLines(baseData), splitLines(oursData), splitLines(theirsData), oursLabel, theirsLabel)
+	tm.message("Auto-merging", fmt.Sprintf("Auto-merging %s", p), p)
+	id, err := tm.put(BLOB, []byte(strings.Join(merged, "")))
+	if err != nil { return treeEntry{}, false, err }
+	return treeEntry{ Mode: mode, Id: id }, clean && !hasConflict, nil
+}
+
+// git replaces slashes in branch names when using them as a part of
+// a file name.
+func mergeLabelAsSuffix(s string) string {
+	return strings.ReplaceAll(s, "/", "_")
+}
+
+// moves the base & theirs version of each file renamed only in ours
+// (and the other way around) to the new path so that they can be
+// merged as if they were never renamed.
+func (tm *treeMerger) applyRename(base map[string]treeEntry, ours map[string]treeEntry, theirs map[string]treeEntry, renameOurs map[string]string, renameTheirs map[string]string) {
+	oldList := make([]string, 0, len(renameOurs))
+	for k := range renameOurs { oldList = append(oldList, k) }
+	slices.Sort(oldList)
+	for _, old := range oldList {
+		newOurs := renameOurs[old]
+		newTheirs, ok := renameTheirs[old]
+		if !ok {
+			if _, exists := theirs[newOurs]; exists { continue }
+			base[newOurs] = base[old]
+			delete(base, old)
+			if t, ok := theirs[old]; ok {
+				theirs[newOurs] = t
+				delete(theirs, old)
+				tm.theirsOrigin[newOurs] = old
+			} else {
+				tm.renameDelete[newOurs] = true
+				tm.message("CONFLICT (rename/delete)", fmt.Sprintf(
+					"CONFLICT (rename/delete): %s renamed to %s in %s, but deleted in %s.",
+					old, newOurs, tm.oursLabel, tm.theirsLabel,
+				), newOurs, old)
+			}
+			continue
+		}
+		if newOurs == newTheirs {
+			base[newOurs] = base[old]
+			delete(base, old)
+			continue
+		}
+		// both versions are kept where they were renamed to.
+		b := base[old]
+		o := ours[newOurs]
+		t := theirs[newTheirs]
+		tm.conflict(old, 1, &b)
+		tm.conflict(newOurs, 2, &o)
+		tm.conflict(newTheirs, 3, &t)
+		tm.message("CONFLICT (rename/rename)", fmt.Sprintf(
+			"CONFLICT (rename/rename): %s renamed to %s in %s and to %s in %s.",
+			old, newOurs, tm.oursLabel, newTheirs, tm.theirsLabel,
+		), old, newOurs, newTheirs)
+		delete(base, old)
+	}
+	oldList = oldList[:0]
+	for k := range renameTheirs { oldList = append(oldList, k) }
+	slices.Sort(oldList)
+	for _, old := range oldList {
+		if _, ok := renameOurs[old]; ok { continue }
+		newTheirs := renameTheirs[old]
+		if _, exists := ours[newTheirs]; exists { continue }
+		base[newTheirs] = base[old]
+		delete(base, old)
+		if o, ok := ours[old]; ok {
+			ours[newTheirs] = o
+			delete(ours, old)
+			tm.oursOrigin[newTheirs] = old
+		} else {
+			tm.renameDelete[newTheirs] = true
+			tm.message("CONFLICT (rename/delete)", fmt.Sprintf(
+				"CONFLICT (rename/delete): %s renamed to %s in %s, but deleted in %s.",
+				old, newTheirs, tm.theirsLabel, tm.oursLabel,
+			), newTheirs, old)
+		}
+	}
+}
+
+func (gr LocalGitRepository) renameMap(baseTreeId string, treeId string) (map[string]string, error) {
+	changeList, err := gr.DiffTree(baseTreeId, treeId, true)
+	if err != nil { return nil, err }
+	res := make(map[string]string)
+	for _, v := range changeList {
+		if v.Type == TREE_CHANGE_RENAME { res[v.OldPath] = v.NewPath }
+	}
+	return res, nil
+}
+
+// merges `theirsTreeId` into `oursTreeId` w/ `baseTreeId` as the
+// common ancestor. the labels are used in conflict markers & messages.
+// the blobs & trees created by the merge are only written into the
+// repository when `write` is true; the ids are the same either way.
+func (gr LocalGitRepository) MergeTree(baseTreeId string, oursTreeId string, theirsTreeId string, oursLabel string, theirsLabel string, write bool) (*TreeMergeResult, error) {
+	var put mergeObjectWriter = gr.HashObject
+	if write { put = gr.WriteLooseObject }
+	res := &TreeMergeResult{
+		FileInfo: make([]MergeCheckConflictedFileInfo, 0),
+		Message: make([]MergeCheckInformationalMessage, 0),
+	}
+	if oursTreeId == theirsTreeId || baseTreeId == theirsTreeId {
+		res.TreeId = oursTreeId
+		return res, nil
+	}
+	if baseTreeId == oursTreeId {
+		res.TreeId = theirsTreeId
+		return res, nil
+	}
+	tm := &treeMerger{
+		gr: gr,
+		oursLabel: oursLabel,
+		theirsLabel: theirsLabel,
+		put: put,
+		res: res,
+		oursOrigin: make(map[string]string),
+		theirsOrigin: make(map[string]string),
+		renameDelete: make(map[string]bool),
+	}
+	base := make(map[string]treeEntry)
+	ours := make(map[string]treeEntry)
+	theirs := make(map[string]treeEntry)
+	err := gr.flattenTree(baseTreeId, "", base)
+	if err != nil { return nil, err }
+	err = gr.flattenTree(oursTreeId, "", ours)
+	if err != nil { return nil, err }
+	err = gr.flattenTree(theirsTreeId, "", theirs)
+	if err != nil { return nil, err }
+	renameOurs, err := gr.renameMap(baseTreeId, oursTreeId)
+	if err != nil { return nil, err }
+	renameTheirs, err := gr.renameMap(baseTreeId, theirsTreeId)
+	if err != nil { return nil, err }
+	tm.applyRename(base, ours, theirs, renameOurs, renameTheirs)
+
+	pathSet := make(map[string]bool)
+	for k := range base { pathSet[k] = true }
+	for k := range ours { pathSet[k] = true }
+	for k := range theirs { pathSet[k] = true }
+	pathList := make([]string, 0, len(pathSet))
+	for k := range pathSet { pathList = append(pathList, k) }
+	slices.Sort(pathList)
+	same := func(x treeEntry, xok bool, y treeEntry, yok bool) bool {
+		return xok == yok && (!xok || x == y)
+	}
+	merged := make(map[string]treeEntry)
+	for _, p := range pathList {
+		b, bok := base[p]
+		o, ook := ours[p]
+		t, tok := theirs[p]
+		switch {
+		case same(o, ook, t, tok):
+			if ook { merged[p] = o }
+		case tm.renameDelete[p] && (same(o, ook, b, bok) || same(t, tok, b, bok)):
+			// renamed w/o changes on one side & deleted on the other;
+			// the file is kept where it's renamed to.
+			kept := o
+			stage := 2
+			if tok {
+				kept = t
+				stage = 3
+			}
+			merged[p] = kept
+			tm.conflict(p, 1, &b)
+			tm.conflict(p, stage, &kept)
+		case same(o, ook, b, bok):
+			if tok { merged[p] = t }
+		case same(t, tok, b, bok):
+			if ook { merged[p] = o }
+		case ook && tok:
+			var bp *treeEntry
+			if bok { bp = &b }
+			e, clean, err := tm.mergeFile(p, bp, o, t)
+			if err != nil { return nil, err }
+			merged[p] = e
+			if !clean {
+				tm.conflict(p, 1, bp)
+				tm.conflict(p, 2, &o)
+				tm.conflict(p, 3, &t)
+			}
+		default:
+			// changed on one side & deleted on the other.
+			deletedIn, modifiedIn := oursLabel, theirsLabel
+			kept := t
+			stage := 3
+			if ook {
+				deletedIn, modifiedIn = theirsLabel, oursLabel
+				kept = o
+				stage = 2
+			}
+			merged[p] = kept
+			tm.conflict(p, 1, &b)
+			tm.conflict(p, stage, &kept)
+			tm.message("CONFLICT (modify/delete)", fmt.Sprintf(
+				"CONFLICT (modify/delete): %s deleted in %s and modified in %s.  Version %s of %s left in tree.",
+				p, deletedIn, modifiedIn, modifiedIn, p,
+			), p)
+		}
+	}
+
+	// a file on one side and a directory on the other; the file is
+	// moved out of the way.
+	dirSet := make(map[string]bool)
+	for p := range merged {
+		for d := path.Dir(p); d != "."; d = path.Dir(d) { dirSet[d] = true }
+	}
+	for _, p := range pathList {
+		e, ok := merged[p]
+		if !ok || !dirSet[p] { continue }
+		label := theirsLabel
+		stage := 3
+		if o, ok := ours[p]; ok && o == e {
+			label = oursLabel
+			stage = 2
+		}
+		newPath := p + "~" + mergeLabelAsSuffix(label)
+		delete(merged, p)
+		merged[newPath] = e
+		// the file might have been conflicted already, in which case
+		// its stages move along w/ it.
+		moved := false
+		for i := range res.FileInfo {
+			if res.FileInfo[i].FileName != p { continue }
+			res.FileInfo[i].FileName = newPath
+			moved = true
+		}
+		if !moved { tm.conflict(newPath, stage, &e) }
+		tm.message("CONFLICT (file/directory)", fmt.Sprintf(
+			"CONFLICT (file/directory): directory in the way of %s from %s; moving it to %s instead.",
+			p, label, newPath,
+		), newPath, p)
+	}
+
+	res.TreeId, err = writeFlattenedTree(merged, put)
+	if err != nil { return nil, err }
+	return res, nil
+}
+
+// merges two commits. the merge base is found w/ MergeBase; unrelated
+// histories are refused like git does by default.
+func (gr LocalGitRepository) MergeCommit(oursId string, theirsId string, oursLabel string, theirsLabel string, write bool) (*TreeMergeResult, error) {
+	baseId, err := gr.MergeBase(oursId, theirsId)
+	if err != nil { return nil, err }
+	baseCommit, err := gr.readCommit(baseId)
+	if err != nil { return nil, err }
+	oursCommit, err := gr.readCommit(oursId)
+	if err != nil { return nil, err }
+	theirsCommit, err := gr.readCommit(theirsId)
+	if err != nil { return nil, err }
+	return gr.MergeTree(baseCommit.TreeObjId, oursCommit.TreeObjId, theirsCommit.TreeObjId, oursLabel, theirsLabel, write)
+}
@@ -0,0 +1,308 @@
+package gitlib
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type testFile struct {
+	mode string
+	data string
+}
+
+func regularFile(data string) testFile { return testFile{ mode: "100644", data: data } }
+func executableFile(data string) testFile { return testFile{ mode: "100755", data: data } }
+func symbolicLink(target string) testFile { return testFile{ mode: "120000", data: target } }
+
+// a bare repository for the tests; `arg` goes to `git init`.
+func newTestRepository(t *testing.T, arg ...string) string {
+	t.Helper()
+	requireGit(t)
+	dir := path.Join(t.TempDir(), "repo.git")
+	runGit(t, "", "", append(append([]string{ "init", "-q", "--bare", "-b", "master" }, arg...), dir)...)
+	return dir
+}
+
+// commits the files w/ git & returns the commit id.
+func commitTestFile(t *testing.T, dir string, fileList map[string]testFile, message string, parent ...string) string {
+	t.Helper()
+	env := []string{ "GIT_INDEX_FILE=" + path.Join(t.TempDir(), "index") }
+	var info strings.Builder
+	for p, f := range fileList {
+		blob := runGit(t, dir, f.data, "hash-object", "-w", "--stdin")
+		fmt.Fprintf(&info, "%s %s\t%s\n", f.mode, blob, p)
+	}
+	if _, err := runGitWithEnv(dir, env, info.String(), "update-index", "--add", "--index-info"); err != nil {
+		t.Fatal(err)
+	}
+	tree, err := runGitWithEnv(dir, env, "", "write-tree")
+	if err != nil { t.Fatal(err) }
+	arg := []string{ "commit-tree", strings.TrimSpace(tree), "-m", message }
+	for _, p := range parent { arg = append(arg, "-p", p) }
+	return runGit(t, dir, "", arg...)
+}
+
+// parses the output of `git merge-tree --write-tree -z`.
+func parseGitMergeTree(t *testing.T, out string) *TreeMergeResult {
+	t.Helper()
+	l := strings.Split(out, "\x00")
+	res := &TreeMergeResult{
+		TreeId: l[0],
+		FileInfo: make([]MergeCheckConflictedFileInfo, 0),
+		Message: make([]MergeCheckInformationalMessage, 0),
+	}
+	i := 1
+	for ; i < len(l) && len(l[i]) > 0; i++ {
+		// {mode} {oid} {stage}\t{path}
+		info, p, _ := strings.Cut(l[i], "\t")
+		field := strings.Fields(info)
+		mode, _ := strconv.Atoi(field[0])
+		stage, _ := strconv.Atoi(field[2])
+		res.FileInfo = append(res.FileInfo, MergeCheckConflictedFileInfo{
+			Mode: mode,
+			ObjectId: field[1],
+			Stage: stage,
+			FileName: p,
+		})
+	}
+	// {path count}, {path}..., {type}, {message}
+	for i += 1; i < len(l) && len(l[i]) > 0; {
+		n, err := strconv.Atoi(l[i])
+		if err != nil { t.Fatalf("bad merge-tree output: %q", out) }
+		m := MergeCheckInformationalMessage{
+			Path: l[i+1:i+1+n],
+			Type: l[i+1+n],
+			Message: strings.TrimSuffix(l[i+2+n], "\n"),
+		}
+		res.Message = append(res.Message, m)
+		i += n + 3
+	}
+	return res
+}
+
+func gitMergeTree(t *testing.T, dir string, oursId string, theirsId string) *TreeMergeResult {
+	t.Helper()
+	out, err := runGitRaw(dir, "", "merge-tree", "--write-tree", "-z", "--messages", oursId, theirsId)
+	res := parseGitMergeTree(t, out)
+	// exits w/ 1 when there are conflicts.
+	res.Conflict = err != nil
+	return res
+}
+
+func formatTreeMergeResult(r *TreeMergeResult) string {
+	var res strings.Builder
+	fmt.Fprintf(&res, "tree %s conflict %v\n", r.TreeId, r.Conflict)
+	fileInfo := slices.Clone(r.FileInfo)
+	slices.SortFunc(fileInfo, func(a, b MergeCheckConflictedFileInfo) int {
+		if c := strings.Compare(a.FileName, b.FileName); c != 0 { return c }
+		return a.Stage - b.Stage
+	})
+	for _, v := range fileInfo {
+		fmt.Fprintf(&res, "%06d %s %d\t%s\n", v.Mode, v.ObjectId, v.Stage, v.FileName)
+	}
+	messageList := make([]string, 0, len(r.Message))
+	for _, v := range r.Message {
+		messageList = append(messageList, fmt.Sprintf("%q %s: %s\n", v.Path, v.Type, v.Message))
+	}
+	slices.Sort(messageList)
+	for _, v := range messageList { res.WriteString(v) }
+	return res.String()
+}
+
+func TestMergeCommit(t *testing.T) {
+	dir := newTestRepository(t)
+	gr := NewLocalGitRepository(dir)
+	file := regularFile
+	caseList := []struct {
+		name string
+		base map[string]testFile
+		ours map[string]testFile
+		theirs map[string]testFile
+	}{
+		{
+			"different files",
+			map[string]testFile{ "a": file("a\n"), "b": file("b\n") },
+			map[string]testFile{ "a": file("A\n"), "b": file("b\n") },
+			map[string]testFile{ "a": file("a\n"), "b": file("B\n"), "c/d": file("d\n") },
+		},
+		{
+			"same file",
+			map[string]testFile{ "a": file("1\n2\n3\n4\n5\n6\n7\n8\n") },
+			map[string]testFile{ "a": file("one\n2\n3\n4\n5\n6\n7\n8\n") },
+			map[string]testFile{ "a": file("1\n2\n3\n4\n5\n6\n7\neight\n") },
+		},
+		{
+			"content conflict",
+			map[string]testFile{ "a": file("1\n2\n3\n"), "d/b": file("b\n") },
+			map[string]testFile{ "a": file("1\ntwo\n3\n"), "d/b": file("b\n") },
+			map[string]testFile{ "a": file("1\nTWO\n3\n"), "d/b": file("B\n") },
+		},
+		{
+			// one side deletes a line, the other adds one after it.
+			"adjacent change",
+			map[string]testFile{ "a": file("\tfoo\n\tfoo\n\tbar\n") },
+			map[string]testFile{ "a": file("\tfoo\n\tbar\n") },
+			map[string]testFile{ "a": file("\tfoo\n\tfoo\n\tbaz\n\tbar\n") },
+		},
+		{
+			"add/add",
+			map[string]testFile{ "a": file("a\n") },
+			map[string]testFile{ "a": file("a\n"), "b": file("same\n"), "c": file("ours\n") },
+			map[string]testFile{ "a": file("a\n"), "b": file("same\n"), "c": file("theirs\n") },
+		},
+		{
+			"modify/delete",
+			map[string]testFile{ "a": file("a\n"), "b": file("b\n"), "c": file("c\n") },
+			map[string]testFile{ "a": file("A\n"), "c": file("c\n") },
+			map[string]testFile{ "b": file("B\n"), "c": file("c\n") },
+		},
+		{
+			"deleted on both sides",
+			map[string]testFile{ "a": file("a\n"), "b": file("b\n") },
+			map[string]testFile{ "b": file("B\n") },
+			map[string]testFile{ "b": file("b\n") },
+		},
+		{
+			"rename & modify",
+			map[string]testFile{ "a": file("1\n2\n3\n4\n5\n6\n7\n8\n9\n") },
+			map[string]testFile{ "d/renamed": file("1\n2\n3\n4\n5\n6\n7\n8\n9\n") },
+			map[string]testFile{ "a": file("1\n2\n3\n4\n5\n6\n7\n8\nnine\n") },
+		},
+		{
+			"similar rename & modify",
+			map[string]testFile{ "a": file("1\n2\n3\n4\n5\n6\n7\n8\n9\n") },
+			map[string]testFile{ "b": file("one\n2\n3\n4\n5\n6\n7\n8\n9\n") },
+			map[string]testFile{ "a": file("1\n2\n3\n4\n5\n6\n7\n8\nnine\n") },
+		},
+		{
+			"rename/rename",
+			map[string]testFile{ "a": file("1\n2\n3\n4\n5\n") },
+			map[string]testFile{ "b": file("1\n2\n3\n4\n5\n") },
+			map[string]testFile{ "c": file("1\n2\n3\n4\n5\n") },
+		},
+		{
+			"same rename",
+			map[string]testFile{ "a": file("1\n2\n3\n4\n5\n") },
+			map[string]testFile{ "b": file("one\n2\n3\n4\n5\n") },
+			map[string]testFile{ "b": file("1\n2\n3\n4\nfive\n") },
+		},
+		{
+			"file/directory",
+			map[string]testFile{ "a": file("a\n") },
+			map[string]testFile{ "a": file("a\n"), "d": file("file\n") },
+			map[string]testFile{ "a": file("a\n"), "d/x": file("x\n") },
+		},
+		{
+			"mode change",
+			map[string]testFile{ "a": file("1\n2\n3\n4\n5\n") },
+			map[string]testFile{ "a": executableFile("1\n2\n3\n4\n5\n") },
+			map[string]testFile{ "a": file("1\n2\n3\n4\nfive\n") },
+		},
+		{
+			"add/add w/ different modes",
+			map[string]testFile{ "a": file("a\n") },
+			map[string]testFile{ "a": file("a\n"), "b": file("b\n") },
+			map[string]testFile{ "a": file("a\n"), "b": executableFile("b\n") },
+		},
+		{
+			"symbolic link",
+			map[string]testFile{ "a": symbolicLink("x") },
+			map[string]testFile{ "a": symbolicLink("y") },
+			map[string]testFile{ "a": symbolicLink("z") },
+		},
+		{
+			"base of another kind",
+			map[string]testFile{ "a": symbolicLink("x") },
+			map[string]testFile{ "a": file("1\n2\n") },
+			map[string]testFile{ "a": file("1\n3\n") },
+		},
+		{
+			"rename/delete",
+			map[string]testFile{ "a": file("1\n2\n3\n4\n5\n") },
+			map[string]testFile{},
+			map[string]testFile{ "b": file("1\n2\n3\n4\n5\n") },
+		},
+		{
+			"rename & change/delete",
+			map[string]testFile{ "a": file("1\n2\n3\n4\n5\n6\n7\n8\n9\n") },
+			map[string]testFile{ "b": file("1\n2\nthree\n4\n5\n6\n7\n8\n9\n") },
+			map[string]testFile{},
+		},
+		{
+			"rename & conflict",
+			map[string]testFile{ "a": file("1\n2\n3\n4\n5\n6\n7\n8\n9\n") },
+			map[string]testFile{ "b": file("1\n2\nthree\n4\n5\n6\n7\n8\n9\n") },
+			map[string]testFile{ "a": file("1\n2\nTHREE\n4\n5\n6\n7\n8\n9\n") },
+		},
+		{
+			"binary",
+			map[string]testFile{ "a": file("a\x00\n") },
+			map[string]testFile{ "a": file("b\x00\n") },
+			map[string]testFile{ "a": file("c\x00\n") },
+		},
+		{
+			"no newline at the end",
+			map[string]testFile{ "a": file("1\n2\n3") },
+			map[string]testFile{ "a": file("1\n2\nthree") },
+			map[string]testFile{ "a": file("1\n2\nTHREE") },
+		},
+	}
+	for _, c := range caseList {
+		t.Run(c.name, func(t *testing.T) {
+			baseId := commitTestFile(t, dir, c.base, "base")
+			oursId := commitTestFile(t, dir, c.ours, "ours", baseId)
+			theirsId := commitTestFile(t, dir, c.theirs, "theirs", baseId)
+			runGit(t, dir, "", "update-ref", "refs/heads/ours", oursId)
+			runGit(t, dir, "", "update-ref", "refs/heads/theirs", theirsId)
+			want := gitMergeTree(t, dir, "ours", "theirs")
+			got, err := gr.MergeCommit(oursId, theirsId, "ours", "theirs", false)
+			if err != nil { t.Fatal(err) }
+			if g, w := formatTreeMergeResult(got), formatTreeMergeResult(want); g != w {
+				t.Errorf("got:\n%s\nwant (git merge-tree):\n%s", g, w)
+			}
+			// the objects written are the same as git's.
+			got, err = gr.MergeCommit(oursId, theirsId, "ours", "theirs", true)
+			if err != nil { t.Fatal(err) }
+			if got.TreeId != want.TreeId { t.Errorf("tree %s, want %s", got.TreeId, want.TreeId) }
+			runGit(t, dir, "", "cat-file", "-e", got.TreeId)
+		})
+	}
+	runGit(t, dir, "", "fsck", "--strict", "--no-dangling")
+}
+
+func TestMergeCommitUnrelated(t *testing.T) {
+	dir := newTestRepository(t)
+	gr := NewLocalGitRepository(dir)
+	a := commitTestFile(t, dir, map[string]testFile{ "a": regularFile("a\n") }, "a")
+	b := commitTestFile(t, dir, map[string]testFile{ "b": regularFile("b\n") }, "b")
+	if _, err := gr.MergeCommit(a, b, "ours", "theirs", false); err != ErrNoMergeBase {
+		t.Errorf("got %v, want ErrNoMergeBase", err)
+	}
+}
+
+func TestMergeBase(t *testing.T) {
+	dir := newTestRepository(t)
+	gr := NewLocalGitRepository(dir)
+	f := func(s string) map[string]testFile { return map[string]testFile{ "a": regularFile(s) } }
+	root := commitTestFile(t, dir, f("0\n"), "root")
+	a1 := commitTestFile(t, dir, f("a1\n"), "a1", root)
+	b1 := commitTestFile(t, dir, f("b1\n"), "b1", root)
+	// criss-cross.
+	a2 := commitTestFile(t, dir, f("a2\n"), "a2", a1, b1)
+	b2 := commitTestFile(t, dir, f("b2\n"), "b2", b1, a1)
+	a3 := commitTestFile(t, dir, f("a3\n"), "a3", a2)
+	for _, c := range [][2]string{ { a1, b1 }, { a2, b1 }, { a3, b1 }, { a3, a1 }, { root, a3 }, { a3, a3 } } {
+		want := runGit(t, dir, "", "merge-base", c[0], c[1])
+		got, err := gr.MergeBase(c[0], c[1])
+		if err != nil { t.Fatal(err) }
+		if got != want { t.Errorf("merge base of %s & %s: got %s, want %s", c[0], c[1], got, want) }
+	}
+	// there are two; git picks one of them as well.
+	got, err := gr.MergeBase(a2, b2)
+	if err != nil { t.Fatal(err) }
+	if got != a1 && got != b1 { t.Errorf("merge base of %s & %s: got %s", a2, b2, got) }
+}
@@ -2,14 +2,23 @@ package gitlib
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
-	"os"
+	"maps"
 	"os/exec"
-	"strconv"
+	"slices"
 	"strings"
+	"time"
 )
 
+// returned when a rebase stops at a commit that can't be applied w/o
+// conflict.
+var ErrRebaseConflict = errors.New("Cannot rebase w/o conflict")
+// returned when a branch that's supposed to be fast-forwarded has
+// commits that the other branch doesn't have.
+var ErrDiverged = errors.New("The branches have diverged")
+
 type MergeCheckConflictedFileInfo struct {
 	// object mode
 	Mode int `json:"mode"`
@@ -33,74 +42,8 @@ type MergeCheckResult struct {
 	Message []MergeCheckInformationalMessage `json:"msg"`
 }
 
-func parseMergeCheckZResult(s string) *MergeCheckResult {
-	// get top level oid.
-	ss := strings.SplitN(s, "\x00", 2)
-	topOid := string(ss[0])
-	if len(ss[1]) <= 0 {
-		return &MergeCheckResult{
-			Successful: true,
-			ToplevelTreeOid: topOid,
-			FileInfo: nil,
-			Message: nil,
-		}
-	}
-	subj := ss[1]
-	// get conflicted file info.
-	cfl := make([]MergeCheckConflictedFileInfo, 0)
-	for {
-		ss := strings.SplitN(subj, "\x00", 2)
-		if ss[0] == "" { subj = ss[1]; break }
-		ss1 := strings.Split(ss[0], "\t")
-		fileName := ss1[1]
-		ss2 := strings.Split(ss1[0], " ")
-		fileMode, _ := strconv.Atoi(ss2[0])
-		fileObjId := ss2[1]
-		stage, _ := strconv.Atoi(ss2[2])
-		cfl = append(cfl, MergeCheckConflictedFileInfo{
-			Mode: fileMode,
-			ObjectId: fileObjId,
-			Stage: stage,
-			FileName: fileName,
-		})
-		subj = ss[1]
-	}
-	// get informational message.
-	msgl := make([]MergeCheckInformationalMessage, 0)
-	for len(subj) > 0 {
-		// get list of paths
-		pathList := make([]string, 0)
-		ss = strings.SplitN(subj, "\x00", 2)
-		subj = ss[1]
-		pathnum, _ := strconv.Atoi(ss[0])
-		for range pathnum {
-			ss = strings.SplitN(subj, "\x00", 2)
-			subj = ss[1]
-			pathList = append(pathList, ss[0])
-		}
-		ss = strings.SplitN(subj, "\x00", 2)
-		conflictType := ss[0]
-		subj = ss[1]
-		ss = strings.SplitN(subj, "\x00", 2)
-		conflictMessage := ss[0]
-		subj = ss[1]
-		msgl = append(msgl, MergeCheckInformationalMessage{
-			Path: pathList,
-			Type: conflictType,
-			Message: conflictMessage,
-		})
-	}
-	return &MergeCheckResult{
-		Successful: false,
-		ToplevelTreeOid: topOid,
-		FileInfo: cfl,
-		Message: msgl,
-	}
-}
-
 func (gr LocalGitRepository) SetUpMergeTarget(providerName string, providerPath string) error {
-	cmd := exec.Command("git", "remote", "add", providerName, providerPath)
-	cmd.Dir = gr.GitDirectoryPath
+	cmd := gr.gitCommand("remote", "add", providerName, providerPath)
 	buf := new(bytes.Buffer)
 	cmd.Stderr = buf
 	err := cmd.Run()
@@ -111,146 +54,299 @@ func (gr LocalGitRepository) SetUpMergeTarget(providerName string, providerPath
 	return nil
 }
 
-func (gr LocalGitRepository) CheckBranchMergeConflict(localBranch string, remote string, remoteBranch string) (*MergeCheckResult, error) {
-	// this would fetch the branch for you.
-	cmd := exec.Command("git", "fetch", remote, remoteBranch)
+// fetches the branch of the provider into its remote-tracking ref
+// (i.e. `refs/remotes/{remote}/{remoteBranch}`) and returns the
+// commit ids of both sides. the returned repository has its pack
+// index reloaded since the fetch could have added new packs. the
+// refspec is explicit since the remotes of bare clones (e.g. the
+// `origin` of a fork) don't have one configured. an empty `remote`
+// means `remoteBranch` is a branch of this repository (e.g. a pull
+// request between two branches of the same repository); nothing is
+// fetched in that case.
+func (gr LocalGitRepository) fetchMergeTarget(localBranch string, remote string, remoteBranch string) (LocalGitRepository, string, string, error) {
+	if len(remote) <= 0 {
+		oursId, err := gr.ResolveRef(fmt.Sprintf("refs/heads/%s", localBranch))
+		if err != nil { return gr, "", "", fmt.Errorf("Failed to resolve %s: %s", localBranch, err.Error()) }
+		theirsId, err := gr.ResolveRef(fmt.Sprintf("refs/heads/%s", remoteBranch))
+		if err != nil { return gr, "", "", fmt.Errorf("Failed to resolve %s: %s", remoteBranch, err.Error()) }
+		return gr, oursId, theirsId, nil
+	}
+	cmd := gr.gitCommand("fetch", remote, fmt.Sprintf("+refs/heads/%s:refs/remotes/%s/%s", remoteBranch, remote, remoteBranch))
 	buf := new(bytes.Buffer)
 	cmd.Stderr = buf
-	cmd.Dir = gr.GitDirectoryPath
 	err := cmd.Run()
 	if err != nil {
-		return nil, errors.New(err.Error() + ": " + buf.String())
-	}
-	remoteBranchFullName := fmt.Sprintf("%s/%s", remote, remoteBranch)
-	cmd2 := exec.Command("git", "merge-tree", "-z", localBranch, remoteBranchFullName)
-	buf.Reset()
-	cmd2.Stdout = buf
-	cmd2.Dir = gr.GitDirectoryPath
-	err = cmd2.Run()
-	// NOTE: we must check exit status because that's what the
-	// document says:
-	//   Do NOT interpret an empty Conflicted file info
-	//   list as a clean merge; check the exit status. A merge can have
-	//   conflicts without having individual files conflict (there are a
-	//   few types of directory rename conflicts that fall into this
-	//   category, and others might also be added in the future).
-	// the command would not put things to stderr - everything is put
-	// to stdout.
-	treeId := strings.TrimSpace(buf.String())
-	preres := parseMergeCheckZResult(treeId)
-	if err != nil {
-		preres.Successful = false
-	} else {
-		preres.Successful = true
+		return gr, "", "", errors.New(err.Error() + ": " + buf.String())
 	}
-	preres.ReceiverLocation = gr.GitDirectoryPath
-	preres.ReceiverBranch = localBranch
-	preres.ProviderRemoteName = remote
-	preres.ProviderBranch = remoteBranch
-	return preres, nil
+	pi, err := gr.readAllPackIndex()
+	if err != nil { return gr, "", "", err }
+	gr.PackIndex = pi
+	oursId, err := gr.ResolveRef(fmt.Sprintf("refs/heads/%s", localBranch))
+	if err != nil { return gr, "", "", fmt.Errorf("Failed to resolve %s: %s", localBranch, err.Error()) }
+	theirsId, err := gr.ResolveRef(fmt.Sprintf("refs/remotes/%s/%s", remote, remoteBranch))
+	if err != nil { return gr, "", "", fmt.Errorf("Failed to resolve %s/%s: %s", remote, remoteBranch, err.Error()) }
+	return gr, oursId, theirsId, nil
 }
 
-func (gr LocalGitRepository) Merge(remote string, remoteBranch string, localBranch string, author string, email string) error {
-	buf := new(bytes.Buffer)
-	cmd1 := exec.Command("git", "fetch", remote, remoteBranch)
-	cmd1.Dir = gr.GitDirectoryPath
-	cmd1.Stderr = buf
-	err := cmd1.Run()
-	if err != nil { return fmt.Errorf("%s: %s", err.Error(), buf.String()) }
-	buf.Reset()
-	providerFullName := fmt.Sprintf("%s/%s", remote, remoteBranch)
-	cmd2 := exec.Command("git", "merge-tree", "--write-tree", localBranch, providerFullName)
-	cmd2.Dir = gr.GitDirectoryPath
-	cmd2.Stdout = buf
-	err = cmd2.Run()
-	if err != nil { return fmt.Errorf("Failed while merge-tree: %s", err.Error()) }
-	treeId := strings.TrimSpace(buf.String())
-	mergeMessage := fmt.Sprintf("merge: from %s/%s to %s", remote, remoteBranch, localBranch)
-	buf.Reset()
-	cmd3 := exec.Command("git", "commit-tree", treeId, "-m", mergeMessage, "-p", localBranch, "-p", providerFullName)
-	cmd3.Dir = gr.GitDirectoryPath
-	cmd3.Env = os.Environ()
-	cmd3.Env = append(cmd3.Env, fmt.Sprintf("GIT_AUTHOR_NAME=%s", author))
-	cmd3.Env = append(cmd3.Env, fmt.Sprintf("GIT_AUTHOR_EMAIL=%s", email))
-	cmd3.Env = append(cmd3.Env, fmt.Sprintf("GIT_COMMITTER_NAME=%s", author))
-	cmd3.Env = append(cmd3.Env, fmt.Sprintf("GIT_COMMITTER_EMAIL=%s", email))
-	err = cmd3.Run()
-	if err != nil { return fmt.Errorf("Failed while commit-tree: %s", err.Error()) }
-	commitId := strings.TrimSpace(buf.String())
-	buf.Reset()
-	localBranchFullName := fmt.Sprintf("refs/heads/%s", localBranch)
-	cmd4 := exec.Command("git", "update-ref", localBranchFullName, commitId)
-	cmd4.Dir = gr.GitDirectoryPath
-	cmd4.Stderr = buf
-	err = cmd4.Run()
-	if err != nil { return fmt.Errorf("Failed while update-ref: %s; %s", err.Error(), buf.String()) }
-	return nil
+// the name of the provider's branch used in the conflict markers &
+// the merge messages.
+func mergeTargetName(remote string, remoteBranch string) string {
+	if len(remote) <= 0 { return remoteBranch }
+	return fmt.Sprintf("%s/%s", remote, remoteBranch)
 }
 
+func (gr LocalGitRepository) CheckBranchMergeConflict(localBranch string, remote string, remoteBranch string) (*MergeCheckResult, error) {
+	// this would fetch the branch for you.
+	gr, oursId, theirsId, err := gr.fetchMergeTarget(localBranch, remote, remoteBranch)
+	if err != nil { return nil, err }
+	return gr.checkMergeConflict(oursId, theirsId, localBranch, remote, remoteBranch)
+}
 
+func (gr LocalGitRepository) checkMergeConflict(oursId string, theirsId string, localBranch string, remote string, remoteBranch string) (*MergeCheckResult, error) {
+	res := &MergeCheckResult{
+		ReceiverLocation: gr.GitDirectoryPath,
+		ReceiverBranch: localBranch,
+		ProviderRemoteName: remote,
+		ProviderBranch: remoteBranch,
+	}
+	// nothing would be written into the repository at this point;
+	// the tree id is only computed.
+	mr, err := gr.MergeCommit(oursId, theirsId, localBranch, mergeTargetName(remote, remoteBranch), false)
+	if errors.Is(err, ErrNoMergeBase) {
+		res.Successful = false
+		res.Message = []MergeCheckInformationalMessage{
+			MergeCheckInformationalMessage{
+				Path: make([]string, 0),
+				Type: "CONFLICT (unrelated histories)",
+				Message: err.Error(),
+			},
+		}
+		return res, nil
+	}
+	if err != nil { return nil, err }
+	// NOTE: a merge can be conflicted w/o any conflicted file (e.g.
+	// rename/rename), so `mr.Conflict` is what should be checked, not
+	// the length of `FileInfo`.
+	res.Successful = !mr.Conflict
+	res.ToplevelTreeOid = mr.TreeId
+	res.FileInfo = mr.FileInfo
+	res.Message = mr.Message
+	return res, nil
+}
 
+type MergeTargetComparison struct {
+	OursId string
+	TheirsId string
+	// the commits only in the provider's branch (i.e. the ones that
+	// would be merged), newest first; at most `limit` of them.
+	CommitList []*CommitObject
+	// the number of the commits only in the provider's branch, which
+	// could be more than the length of CommitList.
+	AheadCount int
+	// the number of the commits only in `localBranch`.
+	BehindCount int
+	Result *MergeCheckResult
+}
 
+func (gr LocalGitRepository) revList(spec string) ([]string, error) {
+	cmd := gr.gitCommand("rev-list", "--topo-order", spec)
+	stdoutBuf := new(bytes.Buffer)
+	stderrBuf := new(bytes.Buffer)
+	cmd.Stdout = stdoutBuf
+	cmd.Stderr = stderrBuf
+	err := cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to git-rev-list: %s; %s", err, stderrBuf.String())
+	}
+	res := make([]string, 0)
+	for v := range strings.SplitSeq(stdoutBuf.String(), "\n") {
+		v = strings.TrimSpace(v)
+		if len(v) > 0 { res = append(res, v) }
+	}
+	return res, nil
+}
 
+// compares `localBranch` w/ the provider's branch the way a pull
+// request from the latter into the former would see it, i.e. which
+// commits would be merged & whether it can be merged w/o conflict.
+// the branch is fetched the same way CheckBranchMergeConflict does.
+func (gr LocalGitRepository) CompareMergeTarget(localBranch string, remote string, remoteBranch string, limit int) (*MergeTargetComparison, error) {
+	gr, oursId, theirsId, err := gr.fetchMergeTarget(localBranch, remote, remoteBranch)
+	if err != nil { return nil, err }
+	aheadList, err := gr.revList(fmt.Sprintf("%s..%s", oursId, theirsId))
+	if err != nil { return nil, err }
+	behindList, err := gr.revList(fmt.Sprintf("%s..%s", theirsId, oursId))
+	if err != nil { return nil, err }
+	mr, err := gr.checkMergeConflict(oursId, theirsId, localBranch, remote, remoteBranch)
+	if err != nil { return nil, err }
+	res := &MergeTargetComparison{
+		OursId: oursId,
+		TheirsId: theirsId,
+		CommitList: make([]*CommitObject, 0),
+		AheadCount: len(aheadList),
+		BehindCount: len(behindList),
+		Result: mr,
+	}
+	for i, commitId := range aheadList {
+		if i >= limit { break }
+		cobj, err := gr.readCommit(commitId)
+		if err != nil { return nil, err }
+		res.CommitList = append(res.CommitList, cobj)
+	}
+	return res, nil
+}
 
+// merges the provider's branch into `localBranch` w/ a merge commit.
+// fails w/ ErrRefChanged when `localBranch` is updated in the meantime.
+func (gr LocalGitRepository) Merge(remote string, remoteBranch string, localBranch string, author string, email string) error {
+	gr, oursId, theirsId, err := gr.fetchMergeTarget(localBranch, remote, remoteBranch)
+	if err != nil { return err }
+	providerFullName := mergeTargetName(remote, remoteBranch)
+	mr, err := gr.MergeCommit(oursId, theirsId, localBranch, providerFullName, true)
+	if err != nil { return fmt.Errorf("Failed while merge-tree: %s", err.Error()) }
+	if mr.Conflict { return fmt.Errorf("Failed while merge-tree: %s cannot be merged into %s w/o conflict", providerFullName, localBranch) }
+	mergeMessage := fmt.Sprintf("merge: from %s to %s", providerFullName, localBranch)
+	now := time.Now()
+	cobj := &CommitObject{
+		TreeObjId: mr.TreeId,
+		ParentIdList: []string{ oursId, theirsId },
+		AuthorInfo: AuthorTime{ AuthorName: author, AuthorEmail: email, Time: now },
+		CommitterInfo: AuthorTime{ AuthorName: author, AuthorEmail: email, Time: now },
+		CommitMessage: mergeMessage,
+	}
+	commitId, err := gr.WriteLooseObject(COMMIT, []byte(cobj.RenderAsString()))
+	if err != nil { return fmt.Errorf("Failed while writing commit: %s", err.Error()) }
+	return gr.WriteRef(fmt.Sprintf("refs/heads/%s", localBranch), commitId, oursId)
+}
 
+// returns the non-merge commits reachable from `headId` but not from
+// `baseId`, oldest first.
+func (gr LocalGitRepository) commitListSince(baseId string, headId string) ([]string, error) {
+	cmd := gr.gitCommand("rev-list", "--reverse", "--topo-order", "--no-merges", fmt.Sprintf("%s..%s", baseId, headId))
+	stdoutBuf := new(bytes.Buffer)
+	stderrBuf := new(bytes.Buffer)
+	cmd.Stdout = stdoutBuf
+	cmd.Stderr = stderrBuf
+	err := cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to git-rev-list: %s; %s", err, stderrBuf.String())
+	}
+	res := make([]string, 0)
+	for v := range strings.SplitSeq(stdoutBuf.String(), "\n") {
+		v = strings.TrimSpace(v)
+		if len(v) > 0 { res = append(res, v) }
+	}
+	return res, nil
+}
 
+// replays the commits of `localBranch` that aren't in the provider's
+// branch on top of the latter, like `git rebase` does. merge commits
+// are dropped & commits that become empty are skipped. the authors &
+// the messages are kept while the committer becomes `author`; the
+// signatures are dropped since they wouldn't be valid anymore.
+// nothing is changed when any of the commits can't be applied w/o
+// conflict, or (w/ ErrRefChanged) when `localBranch` is updated while
+// the commits are being replayed.
+func (gr LocalGitRepository) Rebase(remote string, remoteBranch string, localBranch string, author string, email string) error {
+	gr, oursId, theirsId, err := gr.fetchMergeTarget(localBranch, remote, remoteBranch)
+	if err != nil { return err }
+	providerFullName := mergeTargetName(remote, remoteBranch)
+	baseId, err := gr.MergeBase(oursId, theirsId)
+	if err != nil { return fmt.Errorf("Failed while rebasing: %s", err.Error()) }
+	// already up to date.
+	if baseId == theirsId { return nil }
+	// nothing to replay; it's a fast-forward.
+	if baseId == oursId { return gr.WriteRef(fmt.Sprintf("refs/heads/%s", localBranch), theirsId, oursId) }
+	commitList, err := gr.commitListSince(baseId, oursId)
+	if err != nil { return err }
+	headId := theirsId
+	headCommit, err := gr.readCommit(theirsId)
+	if err != nil { return err }
+	headTreeId := headCommit.TreeObjId
+	now := time.Now()
+	for _, commitId := range commitList {
+		cobj, err := gr.readCommit(commitId)
+		if err != nil { return err }
+		parentTreeId := ""
+		if len(cobj.ParentIdList) > 0 {
+			parentCommit, err := gr.readCommit(cobj.ParentIdList[0])
+			if err != nil { return err }
+			parentTreeId = parentCommit.TreeObjId
+		} else {
+			parentTreeId, err = gr.WriteLooseObject(TREE, []byte{})
+			if err != nil { return err }
+		}
+		mr, err := gr.MergeTree(parentTreeId, headTreeId, cobj.TreeObjId, providerFullName, commitId[:8], true)
+		if err != nil { return fmt.Errorf("Failed while merge-tree: %s", err.Error()) }
+		if mr.Conflict { return fmt.Errorf("%w: commit %s cannot be applied onto %s", ErrRebaseConflict, commitId, providerFullName) }
+		if mr.TreeId == headTreeId { continue }
+		newCommit := &CommitObject{
+			TreeObjId: mr.TreeId,
+			ParentIdList: []string{ headId },
+			AuthorInfo: cobj.AuthorInfo,
+			CoAuthorInfo: cobj.CoAuthorInfo,
+			CommitterInfo: AuthorTime{ AuthorName: author, AuthorEmail: email, Time: now },
+			CommitMessage: cobj.CommitMessage,
+		}
+		headId, err = gr.WriteLooseObject(COMMIT, []byte(newCommit.RenderAsString()))
+		if err != nil { return fmt.Errorf("Failed while writing commit: %s", err.Error()) }
+		headTreeId = mr.TreeId
+	}
+	return gr.WriteRef(fmt.Sprintf("refs/heads/%s", localBranch), headId, oursId)
+}
 
+// fast-forwards `localBranch` to the provider's branch. fails w/
+// ErrDiverged when both branches have commits the other doesn't have;
+// does nothing when `localBranch` already contains the provider's
+// branch. fails w/ ErrRefChanged when `localBranch` is updated in the
+// meantime.
+func (gr LocalGitRepository) FastForward(remote string, remoteBranch string, localBranch string) error {
+	gr, oursId, theirsId, err := gr.fetchMergeTarget(localBranch, remote, remoteBranch)
+	if err != nil { return err }
+	if oursId == theirsId { return nil }
+	baseId, err := gr.MergeBase(oursId, theirsId)
+	if errors.Is(err, ErrNoMergeBase) { return ErrDiverged }
+	if err != nil { return err }
+	if baseId == theirsId { return nil }
+	if baseId != oursId { return ErrDiverged }
+	return gr.WriteRef(fmt.Sprintf("refs/heads/%s", localBranch), theirsId, oursId)
+}
 
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
+// fast-forwards every local branch to the branch of the same name of
+// `remote` when the local branch is behind it & hasn't diverged from
+// it. the other branches, incl. the ones pushed to while this runs,
+// are left untouched. returns the names of the branches that are
+// updated. the fetch is stopped when `ctx` is done & is run w/ `run`
+// (e.g. to keep track of it), or w/ exec.Cmd.Run when it's nil.
+func (gr LocalGitRepository) FastForwardAllFromRemote(ctx context.Context, remote string, run func(*exec.Cmd) error) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "git", "fetch", remote, fmt.Sprintf("+refs/heads/*:refs/remotes/%s/*", remote))
+	buf := new(bytes.Buffer)
+	cmd.Stderr = buf
+	if run == nil { run = (*exec.Cmd).Run }
+	err := run(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to git-fetch: %s; %s", err, buf.String())
+	}
+	pi, err := gr.readAllPackIndex()
+	if err != nil { return nil, err }
+	gr.PackIndex = pi
+	branchList, err := gr.GetAllBranchList()
+	if err != nil { return nil, err }
+	res := make([]string, 0)
+	for _, name := range slices.Sorted(maps.Keys(branchList)) {
+		oursId := branchList[name].HeadId
+		theirsId, err := gr.ResolveRef(fmt.Sprintf("refs/remotes/%s/%s", remote, name))
+		// not on the remote.
+		if err != nil { continue }
+		if oursId == theirsId { continue }
+		baseId, err := gr.MergeBase(oursId, theirsId)
+		if errors.Is(err, ErrNoMergeBase) { continue }
+		if err != nil { return res, err }
+		if baseId != oursId { continue }
+		err = gr.WriteRef(fmt.Sprintf("refs/heads/%s", name), theirsId, oursId)
+		if errors.Is(err, ErrRefChanged) { continue }
+		if err != nil { return res, err }
+		res = append(res, name)
+	}
+	return res, nil
+}
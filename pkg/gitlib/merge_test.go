@@ -0,0 +1,140 @@
+package gitlib
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// a repository w/ the branch `master` & the branch `topic` forked from
+// it, each w/ the given files committed on top.
+func newTestBranchPair(t *testing.T, masterList []map[string]testFile, topicList []map[string]testFile) (string, string, string) {
+	t.Helper()
+	dir := newTestRepository(t)
+	baseId := commitTestFile(t, dir, map[string]testFile{ "a": regularFile("1\n2\n3\n4\n5\n6\n7\n8\n9\n") }, "base")
+	masterId := baseId
+	for _, v := range masterList { masterId = commitTestFile(t, dir, v, "master", masterId) }
+	topicId := baseId
+	for _, v := range topicList { topicId = commitTestFile(t, dir, v, "topic", topicId) }
+	runGit(t, dir, "", "update-ref", "refs/heads/master", masterId)
+	runGit(t, dir, "", "update-ref", "refs/heads/topic", topicId)
+	return dir, masterId, topicId
+}
+
+var (
+	testMasterChange = map[string]testFile{ "a": regularFile("1\n2\nthree\n4\n5\n6\n7\n8\n9\n") }
+	testTopicChange = map[string]testFile{ "a": regularFile("1\n2\n3\n4\n5\n6\n7\neight\n9\n"), "b": regularFile("b\n") }
+	testConflictChange = map[string]testFile{ "a": regularFile("1\n2\nTHREE\n4\n5\n6\n7\n8\n9\n") }
+)
+
+func TestCheckBranchMergeConflict(t *testing.T) {
+	dir, _, _ := newTestBranchPair(t, []map[string]testFile{ testMasterChange }, []map[string]testFile{ testTopicChange })
+	gr := NewLocalGitRepository(dir)
+	res, err := gr.CheckBranchMergeConflict("master", "", "topic")
+	if err != nil { t.Fatal(err) }
+	// nothing is written by the check.
+	if _, err := runGitRaw(dir, "", "cat-file", "-e", res.ToplevelTreeOid); err == nil {
+		t.Errorf("tree %s is written", res.ToplevelTreeOid)
+	}
+	want := gitMergeTree(t, dir, "master", "topic")
+	if !res.Successful || res.ToplevelTreeOid != want.TreeId {
+		t.Errorf("got %+v, want tree %s", res, want.TreeId)
+	}
+
+	dir, _, _ = newTestBranchPair(t, []map[string]testFile{ testMasterChange }, []map[string]testFile{ testConflictChange })
+	gr = NewLocalGitRepository(dir)
+	res, err = gr.CheckBranchMergeConflict("master", "", "topic")
+	if err != nil { t.Fatal(err) }
+	want = gitMergeTree(t, dir, "master", "topic")
+	got := &TreeMergeResult{ TreeId: res.ToplevelTreeOid, Conflict: !res.Successful, FileInfo: res.FileInfo, Message: res.Message }
+	if g, w := formatTreeMergeResult(got), formatTreeMergeResult(want); g != w {
+		t.Errorf("got:\n%s\nwant (git merge-tree):\n%s", g, w)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	dir, masterId, topicId := newTestBranchPair(t, []map[string]testFile{ testMasterChange }, []map[string]testFile{ testTopicChange })
+	gr := NewLocalGitRepository(dir)
+	want := gitMergeTree(t, dir, "master", "topic")
+	if err := gr.Merge("", "topic", "master", "Test", "test@example.com"); err != nil { t.Fatal(err) }
+	if tree := runGit(t, dir, "", "rev-parse", "master^{tree}"); tree != want.TreeId {
+		t.Errorf("tree %s, want %s", tree, want.TreeId)
+	}
+	if parent := runGit(t, dir, "", "rev-parse", "master^1", "master^2"); parent != masterId + "\n" + topicId {
+		t.Errorf("parents %q, want %s & %s", parent, masterId, topicId)
+	}
+	runGit(t, dir, "", "fsck", "--strict", "--no-dangling")
+
+	dir, masterId, _ = newTestBranchPair(t, []map[string]testFile{ testMasterChange }, []map[string]testFile{ testConflictChange })
+	gr = NewLocalGitRepository(dir)
+	if err := gr.Merge("", "topic", "master", "Test", "test@example.com"); err == nil {
+		t.Error("conflicted merge succeeded")
+	}
+	if head := runGit(t, dir, "", "rev-parse", "master"); head != masterId {
+		t.Errorf("master moved to %s", head)
+	}
+}
+
+func TestRebase(t *testing.T) {
+	topicList := []map[string]testFile{
+		testTopicChange,
+		{ "a": regularFile("1\n2\n3\n4\n5\n6\n7\neight\nnine\n"), "b": regularFile("b\n") },
+	}
+	dir, masterId, topicId := newTestBranchPair(t, []map[string]testFile{ testMasterChange }, topicList)
+	gr := NewLocalGitRepository(dir)
+	// topic is rebased onto master.
+	if err := gr.Rebase("", "master", "topic", "Test", "test@example.com"); err != nil { t.Fatal(err) }
+	l := strings.Fields(runGit(t, dir, "", "rev-list", "--first-parent", "master..topic"))
+	if len(l) != len(topicList) {
+		t.Fatalf("%d commits replayed, want %d", len(l), len(topicList))
+	}
+	if base := runGit(t, dir, "", "rev-parse", l[len(l)-1] + "^"); base != masterId {
+		t.Errorf("replayed onto %s, want %s", base, masterId)
+	}
+	// git would end up w/ the same tree.
+	runGit(t, dir, "", "update-ref", "refs/heads/original", topicId)
+	want := gitMergeTree(t, dir, "master", "original")
+	if tree := runGit(t, dir, "", "rev-parse", "topic^{tree}"); tree != want.TreeId {
+		t.Errorf("tree %s, want %s", tree, want.TreeId)
+	}
+	for _, v := range l {
+		if msg := runGit(t, dir, "", "log", "-1", "--format=%an %s", v); msg != "Test topic" {
+			t.Errorf("commit %s: %q", v, msg)
+		}
+	}
+	runGit(t, dir, "", "fsck", "--strict", "--no-dangling")
+
+	dir, _, topicId = newTestBranchPair(t, []map[string]testFile{ testMasterChange }, []map[string]testFile{ testConflictChange })
+	gr = NewLocalGitRepository(dir)
+	if err := gr.Rebase("", "master", "topic", "Test", "test@example.com"); !errors.Is(err, ErrRebaseConflict) {
+		t.Errorf("got %v, want ErrRebaseConflict", err)
+	}
+	if head := runGit(t, dir, "", "rev-parse", "topic"); head != topicId {
+		t.Errorf("topic moved to %s", head)
+	}
+}
+
+func TestFastForward(t *testing.T) {
+	dir, masterId, topicId := newTestBranchPair(t, nil, []map[string]testFile{ testTopicChange })
+	gr := NewLocalGitRepository(dir)
+	if err := gr.FastForward("", "topic", "master"); err != nil { t.Fatal(err) }
+	if head := runGit(t, dir, "", "rev-parse", "master"); head != topicId {
+		t.Errorf("master at %s, want %s", head, topicId)
+	}
+	// already up to date.
+	if err := gr.FastForward("", "master", "topic"); err != nil { t.Fatal(err) }
+	runGit(t, dir, "", "update-ref", "refs/heads/master", masterId)
+	if err := gr.FastForward("", "master", "topic"); err != nil { t.Fatal(err) }
+	if head := runGit(t, dir, "", "rev-parse", "topic"); head != topicId {
+		t.Errorf("topic moved to %s", head)
+	}
+
+	dir, masterId, _ = newTestBranchPair(t, []map[string]testFile{ testMasterChange }, []map[string]testFile{ testTopicChange })
+	gr = NewLocalGitRepository(dir)
+	if err := gr.FastForward("", "topic", "master"); !errors.Is(err, ErrDiverged) {
+		t.Errorf("got %v, want ErrDiverged", err)
+	}
+	if head := runGit(t, dir, "", "rev-parse", "master"); head != masterId {
+		t.Errorf("master moved to %s", head)
+	}
+}
@@ -0,0 +1,81 @@
+package gitlib
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"os/exec"
+	"strings"
+)
+
+// the hash function a repository uses for its object ids, i.e. the
+// value of `extensions.objectformat` in the repository's config. sha-1
+// ids are 20 bytes (40 characters in hex); sha-256 ids are 32 bytes
+// (64 characters in hex). the id size is the same everywhere in a
+// repository: loose objects, tree entries, pack .idx files, REF_DELTA
+// bases and the trailing checksums of packs and .idx files.
+type ObjectFormat string
+const (
+	OBJECT_FORMAT_SHA1 ObjectFormat = "sha1"
+	OBJECT_FORMAT_SHA256 ObjectFormat = "sha256"
+)
+
+var ErrInvalidObjectFormat = errors.New("Invalid object format")
+var ErrInvalidObjectId = errors.New("Invalid object id")
+
+func ParseObjectFormat(s string) (ObjectFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "sha1": return OBJECT_FORMAT_SHA1, nil
+	case "sha256": return OBJECT_FORMAT_SHA256, nil
+	default: return "", ErrInvalidObjectFormat
+	}
+}
+
+// the size of object ids in bytes.
+func (f ObjectFormat) HashSize() int {
+	if f == OBJECT_FORMAT_SHA256 { return 32 }
+	return 20
+}
+
+func (f ObjectFormat) NewHash() hash.Hash {
+	if f == OBJECT_FORMAT_SHA256 { return sha256.New() }
+	return sha1.New()
+}
+
+// the all-zero id git uses for "no object" (e.g. the old value of a
+// ref that's being created).
+func (f ObjectFormat) NullObjectId() string {
+	return strings.Repeat("0", f.HashSize() * 2)
+}
+
+// checks whether `s` is a full object id of this format. ids are
+// always lowercase in git's own output, but uppercase ones are
+// accepted as well; use `strings.ToLower` before looking them up.
+func (f ObjectFormat) IsValidObjectId(s string) bool {
+	if f == OBJECT_FORMAT_SHA256 { return IsValidSHA256(s) }
+	return IsValidSHA1(s)
+}
+
+func isNullObjectId(s string) bool {
+	return len(s) > 0 && strings.Trim(s, "0") == ""
+}
+
+func (gr LocalGitRepository) ObjectFormat() ObjectFormat {
+	if gr.isSHA256 { return OBJECT_FORMAT_SHA256 }
+	return OBJECT_FORMAT_SHA1
+}
+
+// creates a bare repository at `p` (which should be an empty
+// directory) w/ the specified object format. an empty `format` leaves
+// it to git (i.e. `init.defaultObjectFormat`, which is sha-1 unless
+// configured otherwise).
+func InitBareRepository(p string, format ObjectFormat) error {
+	args := []string{"init", "--bare", "--quiet"}
+	if len(format) > 0 { args = append(args, "--object-format=" + string(format)) }
+	cmd := exec.Command("git", args...)
+	cmd.Dir = p
+	out, err := cmd.CombinedOutput()
+	if err != nil { return errors.New(strings.TrimSpace(string(out))) }
+	return nil
+}
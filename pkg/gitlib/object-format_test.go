@@ -0,0 +1,111 @@
+package gitlib
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+const testSHA256 = "--object-format=sha256"
+
+func TestInitBareRepository(t *testing.T) {
+	requireGit(t)
+	for _, format := range []ObjectFormat{ OBJECT_FORMAT_SHA1, OBJECT_FORMAT_SHA256 } {
+		dir := path.Join(t.TempDir(), "repo.git")
+		if err := os.MkdirAll(dir, 0755); err != nil { t.Fatal(err) }
+		if err := InitBareRepository(dir, format); err != nil { t.Fatal(err) }
+		if got := runGit(t, dir, "", "rev-parse", "--show-object-format"); got != string(format) {
+			t.Errorf("%s: git reports %s", format, got)
+		}
+		if got := NewLocalGitRepository(dir).ObjectFormat(); got != format {
+			t.Errorf("%s: read as %s", format, got)
+		}
+	}
+}
+
+func TestWriteLooseObjectSHA256(t *testing.T) {
+	testWriteLooseObject(t, testSHA256)
+}
+
+func TestWritePackFileSHA256(t *testing.T) {
+	testWritePackFile(t, testSHA256)
+}
+
+func TestWriteRefSHA256(t *testing.T) {
+	testWriteRef(t, testSHA256)
+}
+
+// the objects & refs written by git are read the same way, both loose
+// & packed by git (w/ deltas).
+func TestReadSHA256(t *testing.T) {
+	dir := newTestRepository(t, testSHA256)
+	var text strings.Builder
+	for i := range 200 { fmt.Fprintf(&text, "line %d\n", i) }
+	files := map[string]testFile{ "a": regularFile(text.String()), "d/b": executableFile("b\n"), "c": symbolicLink("a") }
+	commitList := []string{ commitTestFile(t, dir, files, "first") }
+	files["a"] = regularFile(text.String() + "more\n")
+	files["e"] = regularFile("e\n")
+	commitList = append(commitList, commitTestFile(t, dir, files, "second", commitList[0]))
+	runGit(t, dir, "", "update-ref", "refs/heads/master", commitList[1])
+	runGit(t, dir, "", "tag", "-a", "-m", "v1", "v1", commitList[0])
+	objectList := strings.Fields(runGit(t, dir, "", "rev-list", "--objects", "--no-object-names", "--all"))
+	check := func(gr *LocalGitRepository) {
+		t.Helper()
+		if !gr.IsSHA256() { t.Fatal("not read as sha-256") }
+		for _, oid := range objectList {
+			typeName := runGit(t, dir, "", "cat-file", "-t", oid)
+			want, err := runGitRaw(dir, "", "cat-file", typeName, oid)
+			if err != nil { t.Fatal(err) }
+			gobj, err := gr.ReadObject(oid)
+			if err != nil { t.Fatalf("%s: %v", oid, err) }
+			if gotType, _ := objectTypeName(gobj.Type()); gotType != typeName || !bytes.Equal(gobj.RawData(), []byte(want)) {
+				t.Errorf("%s: read as %s %q, want %s %q", oid, gotType, gobj.RawData(), typeName, want)
+			}
+		}
+		for _, name := range []string{ "refs/heads/master", "refs/tags/v1", "HEAD" } {
+			want := runGit(t, dir, "", "rev-parse", name)
+			if got, err := gr.ResolveRef(name); err != nil || got != want {
+				t.Errorf("%s: got %s, %v; want %s", name, got, err, want)
+			}
+		}
+		got, err := gr.readCommit(commitList[1])
+		if err != nil { t.Fatal(err) }
+		if got.TreeObjId != runGit(t, dir, "", "rev-parse", commitList[1] + "^{tree}") || got.ParentId() != commitList[0] {
+			t.Errorf("commit read as %+v", got)
+		}
+		// the tree ids are 32 bytes long in the tree objects.
+		oldTree := runGit(t, dir, "", "rev-parse", commitList[0] + "^{tree}")
+		changeList, err := gr.DiffTree(oldTree, got.TreeObjId, true)
+		if err != nil { t.Fatal(err) }
+		if g, w := formatTreeChange(changeList), gitDiffTree(t, dir, oldTree, got.TreeObjId); g != w {
+			t.Errorf("got:\n%s\nwant (git diff-tree):\n%s", g, w)
+		}
+	}
+	check(NewLocalGitRepository(dir))
+	runGit(t, dir, "", "gc", "-q", "--aggressive")
+	if out := runGit(t, dir, "", "count-objects", "-v"); !strings.Contains(out, "count: 0\n") {
+		t.Fatalf("not packed:\n%s", out)
+	}
+	check(NewLocalGitRepository(dir))
+}
+
+// merging writes sha-256 objects that git agrees on.
+func TestMergeCommitSHA256(t *testing.T) {
+	dir := newTestRepository(t, testSHA256)
+	gr := NewLocalGitRepository(dir)
+	baseId := commitTestFile(t, dir, map[string]testFile{ "a": regularFile("1\n2\n3\n4\n5\n"), "b": regularFile("b\n") }, "base")
+	oursId := commitTestFile(t, dir, map[string]testFile{ "a": regularFile("one\n2\n3\n4\n5\n"), "b": regularFile("b\n") }, "ours", baseId)
+	theirsId := commitTestFile(t, dir, map[string]testFile{ "a": regularFile("1\n2\n3\n4\nfive\n"), "c": regularFile("b\n") }, "theirs", baseId)
+	runGit(t, dir, "", "update-ref", "refs/heads/ours", oursId)
+	runGit(t, dir, "", "update-ref", "refs/heads/theirs", theirsId)
+	want := gitMergeTree(t, dir, "ours", "theirs")
+	got, err := gr.MergeCommit(oursId, theirsId, "ours", "theirs", true)
+	if err != nil { t.Fatal(err) }
+	if g, w := formatTreeMergeResult(got), formatTreeMergeResult(want); g != w {
+		t.Errorf("got:\n%s\nwant (git merge-tree):\n%s", g, w)
+	}
+	runGit(t, dir, "", "fsck", "--strict", "--no-dangling")
+}
@@ -0,0 +1,83 @@
+package gitlib
+
+import (
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+)
+
+// writing loose objects, i.e. the zlib-compressed "{type} {size}\0"
+// + content files under `objects/xx/`.
+
+func objectTypeName(t GitObjectType) (string, error) {
+	switch t {
+	case TREE: return "tree", nil
+	case COMMIT: return "commit", nil
+	case BLOB: return "blob", nil
+	case TAG: return "tag", nil
+	default: return "", errors.New("Invalid type for encoding: " + t.String())
+	}
+}
+
+// returns the id of an object w/o writing it.
+func (gr LocalGitRepository) HashObject(t GitObjectType, data []byte) (string, error) {
+	typeName, err := objectTypeName(t)
+	if err != nil { return "", err }
+	h := gr.ObjectFormat().NewHash()
+	fmt.Fprintf(h, "%s %d\x00", typeName, len(data))
+	h.Write(data)
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func (gr LocalGitRepository) looseObjectPath(oid string) string {
+	return path.Join(gr.GitDirectoryPath, "objects", oid[:2], oid[2:])
+}
+
+// checks whether the object exists, either as a loose object or in
+// any of the packs.
+func (gr LocalGitRepository) HasObject(oid string) bool {
+	if !gr.ObjectFormat().IsValidObjectId(oid) { return false }
+	if _, err := os.Stat(gr.looseObjectPath(oid)); err == nil { return true }
+	for _, pi := range gr.PackIndex {
+		offset, err := pi.lookupObjectId(oid)
+		if err == nil && offset >= 0 { return true }
+	}
+	return false
+}
+
+// writes an object as a loose object and returns its id. nothing is
+// written if the object already exists. the object is written into a
+// temporary file first and renamed afterwards, so that a half-written
+// object is never seen by other processes.
+func (gr LocalGitRepository) WriteLooseObject(t GitObjectType, data []byte) (string, error) {
+	typeName, err := objectTypeName(t)
+	if err != nil { return "", err }
+	oid, err := gr.HashObject(t, data)
+	if err != nil { return "", err }
+	if gr.HasObject(oid) { return oid, nil }
+	objPath := gr.looseObjectPath(oid)
+	dir := path.Dir(objPath)
+	err = os.MkdirAll(dir, 0755)
+	if err != nil { return "", err }
+	f, err := os.CreateTemp(dir, "tmp_obj_")
+	if err != nil { return "", err }
+	tmpPath := f.Name()
+	w := zlib.NewWriter(f)
+	fmt.Fprintf(w, "%s %d\x00", typeName, len(data))
+	_, err = w.Write(data)
+	if err == nil { err = w.Close() }
+	if err == nil { err = f.Close() } else { f.Close() }
+	if err != nil { os.Remove(tmpPath); return "", err }
+	// git makes objects read-only.
+	os.Chmod(tmpPath, 0444)
+	err = os.Rename(tmpPath, objPath)
+	if err != nil { os.Remove(tmpPath); return "", err }
+	return oid, nil
+}
+
+// requires `gobj` to be resolved (i.e. not delta).
+func (gr LocalGitRepository) WriteObject(gobj GitObject) (string, error) {
+	return gr.WriteLooseObject(gobj.Type(), gobj.RawData())
+}
@@ -0,0 +1,91 @@
+package gitlib
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// objects of every type, each depending on the ones before it.
+func writeTestObjectList(t *testing.T, gr *LocalGitRepository) []*PackObject {
+	t.Helper()
+	res := make([]*PackObject, 0)
+	put := func(typ GitObjectType, data []byte) string {
+		t.Helper()
+		oid, err := gr.WriteLooseObject(typ, data)
+		if err != nil { t.Fatal(err) }
+		res = append(res, &PackObject{ Id: oid, Type: typ, Data: data })
+		return oid
+	}
+	blobA := put(BLOB, []byte("a\n"))
+	blobB := put(BLOB, []byte{})
+	blobC := put(BLOB, []byte("binary\x00\xff\n"))
+	tree := put(TREE, bytes.Join([][]byte{
+		append([]byte("100644 a\x00"), hexStringToBytes(blobA)...),
+		append([]byte("100755 b\x00"), hexStringToBytes(blobB)...),
+		append([]byte("120000 c\x00"), hexStringToBytes(blobC)...),
+	}, nil))
+	subtree := put(TREE, append([]byte("40000 d\x00"), hexStringToBytes(tree)...))
+	commit := put(COMMIT, []byte(fmt.Sprintf(
+		"tree %s\nauthor A U Thor <author@example.com> 1700000000 +0000\ncommitter A U Thor <author@example.com> 1700000000 +0000\n\nroot\n",
+		subtree,
+	)))
+	put(COMMIT, []byte(fmt.Sprintf(
+		"tree %s\nparent %s\nauthor A U Thor <author@example.com> 1700000000 +0000\ncommitter A U Thor <author@example.com> 1700000000 +0000\n\nchild\n",
+		tree, commit,
+	)))
+	put(TAG, []byte(fmt.Sprintf(
+		"object %s\ntype commit\ntag v1\ntagger A U Thor <author@example.com> 1700000000 +0000\n\nv1\n",
+		commit,
+	)))
+	return res
+}
+
+// checks that git reads the objects the same way & that they're
+// valid.
+func checkTestObjectList(t *testing.T, dir string, objList []*PackObject) {
+	t.Helper()
+	for _, o := range objList {
+		typeName, _ := objectTypeName(o.Type)
+		if typ := runGit(t, dir, "", "cat-file", "-t", o.Id); typ != typeName {
+			t.Errorf("%s: type %s, want %s", o.Id, typ, typeName)
+		}
+		data, err := runGitRaw(dir, "", "cat-file", typeName, o.Id)
+		if err != nil { t.Fatal(err) }
+		if data != string(o.Data) { t.Errorf("%s: got %q, want %q", o.Id, data, o.Data) }
+		if oid := runGit(t, dir, string(o.Data), "hash-object", "-t", typeName, "--stdin"); oid != o.Id {
+			t.Errorf("id %s, want %s (git hash-object)", o.Id, oid)
+		}
+	}
+	runGit(t, dir, "", "fsck", "--strict", "--no-dangling")
+}
+
+func testWriteLooseObject(t *testing.T, arg ...string) {
+	dir := newTestRepository(t, arg...)
+	gr := NewLocalGitRepository(dir)
+	objList := writeTestObjectList(t, gr)
+	checkTestObjectList(t, dir, objList)
+	// they're read back as they're written.
+	for _, o := range objList {
+		gobj, err := gr.ReadObject(o.Id)
+		if err != nil { t.Fatal(err) }
+		if gobj.Type() != o.Type || !bytes.Equal(gobj.RawData(), o.Data) {
+			t.Errorf("%s: read back as %v %q", o.Id, gobj.Type(), gobj.RawData())
+		}
+	}
+	// writing an existing object does nothing.
+	oid, err := gr.WriteLooseObject(objList[0].Type, objList[0].Data)
+	if err != nil || oid != objList[0].Id { t.Errorf("got %s, %v; want %s", oid, err, objList[0].Id) }
+	// hashing doesn't write.
+	oid, err = gr.HashObject(BLOB, []byte("not written\n"))
+	if err != nil { t.Fatal(err) }
+	if want := runGit(t, dir, "not written\n", "hash-object", "--stdin"); oid != want {
+		t.Errorf("id %s, want %s (git hash-object)", oid, want)
+	}
+	if gr.HasObject(oid) { t.Errorf("%s is written", oid) }
+	if _, err := runGitRaw(dir, "", "cat-file", "-e", oid); err == nil { t.Errorf("%s is written", oid) }
+}
+
+func TestWriteLooseObject(t *testing.T) {
+	testWriteLooseObject(t)
+}
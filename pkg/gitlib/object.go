@@ -9,8 +9,8 @@ import (
 	"log"
 	"os"
 	"path"
-	"slices"
 	"strconv"
+	"strings"
 )
 
 type GitObjectType int
@@ -65,6 +65,9 @@ type RawGitObject struct {
 	// nil for directly accessible objects.
 	packIndex *PackIndex
 	packOffset int64
+	// the size of object ids (20 or 32 bytes) in tree entries and
+	// REF_DELTA headers.
+	hashSize int
 }
 
 type GitObjectHeader struct {
@@ -74,14 +77,8 @@ type GitObjectHeader struct {
 
 func EncodeAsDirectObject(gobj GitObject) ([]byte, error) {
 	// requires `gobj` to be resolved (i.e. not delta)
-	typestr := ""
-	switch gobj.Type() {
-	case TREE: typestr = "tree"
-	case COMMIT: typestr = "commit"
-	case BLOB: typestr = "blob"
-	case TAG: typestr = "tag"
-	default: return nil, errors.New("Invalid type for encoding: " + gobj.Type().String())
-	}
+	typestr, err := objectTypeName(gobj.Type())
+	if err != nil { return nil, err }
 	size := fmt.Sprintf("%d", len(gobj.RawData()))
 	header := []byte(typestr + " " + size + "\x00")
 	res := append(header, gobj.RawData()...)
@@ -133,11 +130,16 @@ func (gr LocalGitRepository) openRawDirectlyAccessibleObject(oid string) (RawGit
 		objSize: objHead.Size,
 		reader: nr,
 		readerIsUncompressed: true,
+		hashSize: gr.ObjectFormat().HashSize(),
 	}
 	return res, nil
 }
 
 func (gr LocalGitRepository) openRawObject(oid string) (RawGitObject, error) {
+	// ids come from urls as well; this also rules out sha-1 ids in
+	// sha-256 repositories & vice versa.
+	if !gr.ObjectFormat().IsValidObjectId(oid) { return RawGitObject{}, ErrInvalidObjectId }
+	oid = strings.ToLower(oid)
 	dao, err := gr.openRawDirectlyAccessibleObject(oid)
 	if err == nil { return dao, err }
 	for _, val := range gr.PackIndex {
@@ -284,6 +286,7 @@ func (gr LocalGitRepository) resolveObject(obj GitObject) (GitObject, error) {
 			packIndex: dobj.PackIndex,
 			reader: pf,
 			packOffset: off,
+			hashSize: dobj.PackIndex.hashSize,
 		}
 		if baseRO.objType == REF_DELTA || baseRO.objType == OFS_DELTA {
 			dispatched, err := baseRO.dispatch()
@@ -300,7 +303,7 @@ func (gr LocalGitRepository) resolveObject(obj GitObject) (GitObject, error) {
 	
 	res := make([]byte, 0)
 	for _, cmd := range commandList {
-		res = slices.Concat(res, cmd.Execute(baseObj.RawData()))
+		res = append(res, cmd.Execute(baseObj.RawData())...)
 	}
 	br := bytesReader{r: bytes.NewReader(res)}
 	resrgo := RawGitObject{
@@ -309,6 +312,7 @@ func (gr LocalGitRepository) resolveObject(obj GitObject) (GitObject, error) {
 		objSize: int64(len(res)),
 		reader: br,
 		packIndex: packIndex,
+		hashSize: gr.ObjectFormat().HashSize(),
 	}
 	resObj, err := resrgo.dispatchNoDeflate()
 	if err != nil { return nil, err }
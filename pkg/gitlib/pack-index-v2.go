@@ -1,12 +1,14 @@
 package gitlib
 
+import "io"
+
 func (pi PackIndex) lookupObjectIdV2(indexHead string, indexTail string) (int64, error) {
 	// the layout of v2 pack idx file is as follows.
 	// 1.  4 byte magic number.
 	// 2.  4 byte version number.
 	// 3.  256 x 4 byte fanout table. (of which the last item is total
 	//     number of object within this pack file)
-	// 4.  itemCount x 20 byte name table.
+	// 4.  itemCount x 20 byte name table. (32 byte for sha-256)
 	// 5.  itemCount x 4 byte CRC32.
 	// 6.  itemCount x 4 byte level 1 offset.
 	// 7.  nCount x 8 byte level 2 offset, where nCount is the number
@@ -19,8 +21,9 @@ func (pi PackIndex) lookupObjectIdV2(indexHead string, indexTail string) (int64,
 	totalItemCount, err := readBigEndianUInt32(pi.file)
 	if err != nil { return 0, err }
 	objNameTableBase := 8+4*256
-	levelOneOffsetBase := 8+4*256+int64(totalItemCount)*(20+4)
-	levelTwoOffsetBase := 8+4*256+int64(totalItemCount)*(20+4+4)
+	hashSize := int64(pi.hashSize)
+	levelOneOffsetBase := 8+4*256+int64(totalItemCount)*(hashSize+4)
+	levelTwoOffsetBase := 8+4*256+int64(totalItemCount)*(hashSize+4+4)
 	
 	fanoutIdx := byteHexToInt(indexHead)
 	_, err = pi.file.Seek(fanoutBase+int64(fanoutIdx)*4, 0)
@@ -35,18 +38,18 @@ func (pi PackIndex) lookupObjectIdV2(indexHead string, indexTail string) (int64,
 		segmentStartIdx, err = readBigEndianUInt32(pi.file)
 		if err != nil { return 0, err }
 	}
-	// from this point forward we check 20-byte items in the
-	// range of [startValue, fanoutValue].
+	// from this point forward we check 20-byte (or 32-byte) items
+	// in the range of [startValue, fanoutValue].
 	itemCount := segmentEndIdx - segmentStartIdx
-	_, err = pi.file.Seek(int64(objNameTableBase)+int64(segmentStartIdx)*20, 0)
+	_, err = pi.file.Seek(int64(objNameTableBase)+int64(segmentStartIdx)*hashSize, 0)
 	if err != nil { return 0, err }
 	inBatchIndex := uint32(0)
 	found := false
 	for i := range itemCount {
-		objidbuf := make([]byte, 20)
-		_, err = pi.file.Read(objidbuf)
+		objidbuf := make([]byte, hashSize)
+		_, err = io.ReadFull(pi.file, objidbuf)
 		if err != nil { return 0, err }
-		s := make([]byte, 38)
+		s := make([]byte, hashSize*2-2)
 		j := 0
 		for k, b := range objidbuf {
 			// we don't need to check first byte; it's already checked.
@@ -92,7 +95,7 @@ func (pi PackIndex) getAllObjectIdV2() ([]string, error) {
 	_, err = pi.file.Seek(int64(8+4*256), 0)
 	if err != nil { return nil, err }
 	for range count {
-		s, err := readBytesToHex(pi.file, 20)
+		s, err := readBytesToHex(pi.file, pi.hashSize)
 		if err != nil { return nil, err }
 		res = append(res, s)
 	}
@@ -23,6 +23,9 @@ type PackIndex struct {
 	// TODO: fix this (according to above)
 	file *os.File
 	parent *LocalGitRepository
+	// the size of object ids in bytes, which is decided by the object
+	// format of the repository. v1 .idx files are always sha-1.
+	hashSize int
 }
 
 func (gr *LocalGitRepository) makePackIndex(packId string) (*PackIndex, error) {
@@ -44,6 +47,7 @@ func (gr *LocalGitRepository) makePackIndex(packId string) (*PackIndex, error) {
 		PackId: packId,
 		file: f,
 		parent: gr,
+		hashSize: gr.ObjectFormat().HashSize(),
 	}
 	return &pi, nil
 }
@@ -102,7 +106,7 @@ func (pi PackIndex) openPackFile() (*os.File, error) {
 // 1.  normal object, which is 1+n byte type&size in varint
 // 2.  REF_DELTA, which is:
 //     1.  1+n byte type&size in varint
-//     2.  base object id (20-byte)
+//     2.  base object id (20-byte, or 32-byte for sha-256)
 // 3.  OFS_DELTA, which is:
 //     1.  1+n byte type&size in varint
 //     2.  n byte offset 7-bit varint
@@ -152,6 +156,7 @@ func (pi PackIndex) openPackedObject(objid string) (RawGitObject, error) {
 		reader: pf,
 		readerIsUncompressed: false,
 		packOffset: offset,
+		hashSize: pi.hashSize,
 	}, nil
 }
 
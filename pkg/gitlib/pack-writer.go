@@ -0,0 +1,277 @@
+package gitlib
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// writing packfiles (version 2) & their .idx files (version 2).
+// the layout of the packfile is as follows:
+// 1.  4 byte "PACK".
+// 2.  4 byte version number (2).
+// 3.  4 byte number of objects.
+// 4.  the objects, each of which is the type&size varint, the base
+//     offset (only for OFS_DELTA) and the zlib-compressed content (or
+//     delta).
+// 5.  the checksum of everything above.
+// see pack-index-v2.go for the layout of the .idx file. the checksums
+// (and the ids in the .idx file) use the hash of the repository's
+// object format.
+
+// the number of objects before each object that are tried as its
+// delta base, and the longest chain of deltas allowed. same as git's
+// default.
+const (
+	PACK_DELTA_WINDOW = 10
+	PACK_DELTA_MAX_DEPTH = 50
+)
+
+// objects smaller than this are not worth deltifying.
+const packDeltaMinSize = 64
+
+type PackObject struct {
+	Id string
+	Type GitObjectType
+	Data []byte
+}
+
+type PackIndexEntry struct {
+	Id string
+	Offset int64
+	CRC32 uint32
+}
+
+// keeps track of the offset & the checksum of everything written.
+type packHashWriter struct {
+	w io.Writer
+	h hash.Hash
+	n int64
+}
+
+func (hw *packHashWriter) Write(b []byte) (int, error) {
+	n, err := hw.w.Write(b)
+	hw.h.Write(b[:n])
+	hw.n += int64(n)
+	return n, err
+}
+
+func appendPackObjectHeader(b []byte, t GitObjectType, size int) []byte {
+	c := byte(t << 4) | byte(size & 0x0f)
+	size >>= 4
+	for size > 0 {
+		b = append(b, c | 0x80)
+		c = byte(size & 0x7f)
+		size >>= 7
+	}
+	return append(b, c)
+}
+
+// the reverse of `readOfsDeltaOffset`.
+func appendOfsDeltaOffset(b []byte, offset int64) []byte {
+	buf := make([]byte, 0, 10)
+	buf = append(buf, byte(offset & 0x7f))
+	for offset >>= 7; offset > 0; offset >>= 7 {
+		offset -= 1
+		buf = append(buf, byte(offset & 0x7f) | 0x80)
+	}
+	slices.Reverse(buf)
+	return append(b, buf...)
+}
+
+// picks a delta base for each object. objects are sorted by type and
+// then by size (largest first, like git does), and each object is
+// compared w/ the `window` objects before it. returns the index of the
+// base of each object (-1 if it's not deltified) and the delta.
+func findPackDelta(objList []*PackObject, window int) ([]int, [][]byte) {
+	base := make([]int, len(objList))
+	delta := make([][]byte, len(objList))
+	for i := range base { base[i] = -1 }
+	if window <= 0 { return base, delta }
+	order := make([]int, len(objList))
+	for i := range order { order[i] = i }
+	slices.SortStableFunc(order, func(a, b int) int {
+		if objList[a].Type != objList[b].Type { return int(objList[a].Type) - int(objList[b].Type) }
+		return len(objList[b].Data) - len(objList[a].Data)
+	})
+	depth := make([]int, len(objList))
+	for p, i := range order {
+		target := objList[i]
+		if len(target.Data) < packDeltaMinSize { continue }
+		for q := max(p - window, 0); q < p; q++ {
+			j := order[q]
+			if objList[j].Type != target.Type || depth[j] >= PACK_DELTA_MAX_DEPTH { continue }
+			// a delta has to be at most half the size of the object
+			// to be worth it; deeper chains have to be even smaller.
+			maxSize := len(target.Data) / 2 * (PACK_DELTA_MAX_DEPTH - depth[j]) / PACK_DELTA_MAX_DEPTH
+			if delta[i] != nil { maxSize = min(maxSize, len(delta[i])) }
+			if maxSize <= 0 { continue }
+			d := EncodeDelta(objList[j].Data, target.Data, maxSize)
+			if d == nil { continue }
+			base[i] = j
+			delta[i] = d
+			depth[i] = depth[j] + 1
+		}
+	}
+	return base, delta
+}
+
+// writes a packfile containing `objList` to `w`. deltas are only
+// made against objects in the same pack (i.e. OFS_DELTA; the pack is
+// never "thin"). `deltaWindow` of 0 disables deltas. returns the
+// entries for the .idx file & the checksum of the pack.
+func WritePack(w io.Writer, objList []*PackObject, deltaWindow int, format ObjectFormat) ([]PackIndexEntry, []byte, error) {
+	hw := &packHashWriter{ w: w, h: format.NewHash() }
+	header := make([]byte, 12)
+	copy(header, "PACK")
+	binary.BigEndian.PutUint32(header[4:], 2)
+	binary.BigEndian.PutUint32(header[8:], uint32(len(objList)))
+	_, err := hw.Write(header)
+	if err != nil { return nil, nil, err }
+	base, delta := findPackDelta(objList, deltaWindow)
+	offset := make([]int64, len(objList))
+	for i := range offset { offset[i] = -1 }
+	res := make([]PackIndexEntry, 0, len(objList))
+	buf := new(bytes.Buffer)
+	var write func(i int) error
+	write = func(i int) error {
+		if offset[i] >= 0 { return nil }
+		// bases have to come before the objects based on them.
+		if base[i] >= 0 {
+			err := write(base[i])
+			if err != nil { return err }
+		}
+		o := objList[i]
+		entry := make([]byte, 0, 16)
+		content := o.Data
+		if base[i] >= 0 {
+			content = delta[i]
+			entry = appendPackObjectHeader(entry, OFS_DELTA, len(content))
+			entry = appendOfsDeltaOffset(entry, hw.n - offset[base[i]])
+		} else {
+			entry = appendPackObjectHeader(entry, o.Type, len(content))
+		}
+		buf.Reset()
+		buf.Write(entry)
+		zw := zlib.NewWriter(buf)
+		_, err := zw.Write(content)
+		if err != nil { return err }
+		err = zw.Close()
+		if err != nil { return err }
+		offset[i] = hw.n
+		res = append(res, PackIndexEntry{
+			Id: o.Id,
+			Offset: hw.n,
+			CRC32: crc32.ChecksumIEEE(buf.Bytes()),
+		})
+		_, err = hw.Write(buf.Bytes())
+		return err
+	}
+	for i := range objList {
+		err = write(i)
+		if err != nil { return nil, nil, err }
+	}
+	checksum := hw.h.Sum(nil)
+	_, err = w.Write(checksum)
+	if err != nil { return nil, nil, err }
+	return res, checksum, nil
+}
+
+// writes the .idx file (version 2) for a pack written by WritePack.
+func WritePackIndex(w io.Writer, entryList []PackIndexEntry, packChecksum []byte, format ObjectFormat) error {
+	hw := &packHashWriter{ w: w, h: format.NewHash() }
+	l := slices.Clone(entryList)
+	slices.SortFunc(l, func(a, b PackIndexEntry) int { return strings.Compare(a.Id, b.Id) })
+	b := make([]byte, 0, 8 + 256 * 4 + len(l) * (format.HashSize() + 8))
+	b = binary.BigEndian.AppendUint32(b, 0xff744f63)
+	b = binary.BigEndian.AppendUint32(b, 2)
+	fanout := make([]uint32, 256)
+	for _, v := range l {
+		fanout[hexStringToBytes(v.Id[:2])[0]] += 1
+	}
+	count := uint32(0)
+	for _, v := range fanout {
+		count += v
+		b = binary.BigEndian.AppendUint32(b, count)
+	}
+	for _, v := range l {
+		if !format.IsValidObjectId(v.Id) { return fmt.Errorf("Invalid object id: %s", v.Id) }
+		b = append(b, hexStringToBytes(v.Id)...)
+	}
+	for _, v := range l { b = binary.BigEndian.AppendUint32(b, v.CRC32) }
+	// offsets that don't fit in 31 bits go to a separate table of
+	// 64-bit offsets; the MSB marks the index into that table.
+	largeOffset := make([]byte, 0)
+	largeCount := uint32(0)
+	for _, v := range l {
+		if v.Offset < 0x80000000 {
+			b = binary.BigEndian.AppendUint32(b, uint32(v.Offset))
+			continue
+		}
+		b = binary.BigEndian.AppendUint32(b, largeCount | 0x80000000)
+		largeOffset = binary.BigEndian.AppendUint64(largeOffset, uint64(v.Offset))
+		largeCount += 1
+	}
+	b = append(b, largeOffset...)
+	b = append(b, packChecksum...)
+	_, err := hw.Write(b)
+	if err != nil { return err }
+	_, err = w.Write(hw.h.Sum(nil))
+	return err
+}
+
+// writes the objects into a new pack under `objects/pack/` and
+// returns the id of the pack (i.e. the checksum of the pack, same as
+// git). the loose objects are not removed. the .idx file is renamed
+// into place last, since the pack is only seen after its .idx exists.
+func (gr *LocalGitRepository) WritePackFile(oidList []string) (string, error) {
+	if len(oidList) <= 0 { return "", errors.New("No object to pack") }
+	objList := make([]*PackObject, 0, len(oidList))
+	for _, oid := range oidList {
+		gobj, err := gr.ReadObject(oid)
+		if err != nil { return "", fmt.Errorf("Failed to read object %s: %s", oid, err.Error()) }
+		objList = append(objList, &PackObject{
+			Id: oid,
+			Type: gobj.Type(),
+			Data: gobj.RawData(),
+		})
+	}
+	packDir := path.Join(gr.GitDirectoryPath, "objects", "pack")
+	err := os.MkdirAll(packDir, 0755)
+	if err != nil { return "", err }
+	pf, err := os.CreateTemp(packDir, "tmp_pack_")
+	if err != nil { return "", err }
+	packTmpPath := pf.Name()
+	entryList, checksum, err := WritePack(pf, objList, PACK_DELTA_WINDOW, gr.ObjectFormat())
+	if err == nil { err = pf.Close() } else { pf.Close() }
+	if err != nil { os.Remove(packTmpPath); return "", err }
+	xf, err := os.CreateTemp(packDir, "tmp_idx_")
+	if err != nil { os.Remove(packTmpPath); return "", err }
+	idxTmpPath := xf.Name()
+	err = WritePackIndex(xf, entryList, checksum, gr.ObjectFormat())
+	if err == nil { err = xf.Close() } else { xf.Close() }
+	if err != nil { os.Remove(packTmpPath); os.Remove(idxTmpPath); return "", err }
+	packId := fmt.Sprintf("%x", checksum)
+	packPath := path.Join(packDir, "pack-" + packId + ".pack")
+	idxPath := path.Join(packDir, "pack-" + packId + ".idx")
+	os.Chmod(packTmpPath, 0444)
+	os.Chmod(idxTmpPath, 0444)
+	err = os.Rename(packTmpPath, packPath)
+	if err != nil { os.Remove(packTmpPath); os.Remove(idxTmpPath); return "", err }
+	err = os.Rename(idxTmpPath, idxPath)
+	if err != nil { os.Remove(idxTmpPath); return "", err }
+	pi, err := gr.makePackIndex(packId)
+	if err != nil { return "", err }
+	if gr.PackIndex == nil { gr.PackIndex = make(map[string]*PackIndex) }
+	gr.PackIndex[packId] = pi
+	return packId, nil
+}
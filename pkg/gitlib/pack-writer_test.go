@@ -0,0 +1,98 @@
+package gitlib
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func testWritePackFile(t *testing.T, arg ...string) {
+	dir := newTestRepository(t, arg...)
+	gr := NewLocalGitRepository(dir)
+	objList := writeTestObjectList(t, gr)
+	// versions of a file that are large & similar enough to be
+	// deltified.
+	var text strings.Builder
+	for i := range 200 {
+		fmt.Fprintf(&text, "line %d\n", i)
+		if i % 20 != 19 { continue }
+		data := []byte(text.String())
+		oid, err := gr.WriteLooseObject(BLOB, data)
+		if err != nil { t.Fatal(err) }
+		objList = append(objList, &PackObject{ Id: oid, Type: BLOB, Data: data })
+	}
+	oidList := make([]string, 0, len(objList))
+	for _, o := range objList { oidList = append(oidList, o.Id) }
+	packId, err := gr.WritePackFile(oidList)
+	if err != nil { t.Fatal(err) }
+	// the objects are only in the pack from now on.
+	entryList, err := os.ReadDir(path.Join(dir, "objects"))
+	if err != nil { t.Fatal(err) }
+	for _, v := range entryList {
+		if len(v.Name()) != 2 { continue }
+		if err := os.RemoveAll(path.Join(dir, "objects", v.Name())); err != nil { t.Fatal(err) }
+	}
+	packPath := path.Join(dir, "objects", "pack", "pack-" + packId + ".pack")
+	idxPath := path.Join(dir, "objects", "pack", "pack-" + packId + ".idx")
+
+	// {id} {type} {size} {size in pack} {offset} [{depth} {base}]
+	out := runGit(t, dir, "", "verify-pack", "-v", idxPath)
+	found := make(map[string]bool)
+	deltaCount := 0
+	for line := range strings.SplitSeq(out, "\n") {
+		field := strings.Fields(line)
+		if len(field) < 5 || !gr.ObjectFormat().IsValidObjectId(field[0]) { continue }
+		found[field[0]] = true
+		if len(field) >= 7 { deltaCount += 1 }
+	}
+	for _, oid := range oidList {
+		if !found[oid] { t.Errorf("%s is not in the pack", oid) }
+	}
+	if deltaCount <= 0 { t.Errorf("no object is deltified:\n%s", out) }
+	// git writes the same .idx for the pack.
+	gitIdxPath := path.Join(t.TempDir(), "git.idx")
+	runGit(t, dir, "", "index-pack", "-o", gitIdxPath, packPath)
+	idx, err := os.ReadFile(idxPath)
+	if err != nil { t.Fatal(err) }
+	gitIdx, err := os.ReadFile(gitIdxPath)
+	if err != nil { t.Fatal(err) }
+	if !bytes.Equal(idx, gitIdx) { t.Errorf(".idx differs from the one written by git index-pack") }
+	checkTestObjectList(t, dir, objList)
+
+	// read back from the pack, deltas incl.
+	gr = NewLocalGitRepository(dir)
+	for _, o := range objList {
+		if !gr.HasObject(o.Id) { t.Errorf("%s is not found", o.Id) }
+		gobj, err := gr.ReadObject(o.Id)
+		if err != nil { t.Fatal(err) }
+		if gobj.Type() != o.Type || !bytes.Equal(gobj.RawData(), o.Data) {
+			t.Errorf("%s: read back as %v %q", o.Id, gobj.Type(), gobj.RawData())
+		}
+	}
+}
+
+func TestWritePackFile(t *testing.T) {
+	testWritePackFile(t)
+}
+
+// w/o deltas every object is written as is.
+func TestWritePackNoDelta(t *testing.T) {
+	dir := newTestRepository(t)
+	gr := NewLocalGitRepository(dir)
+	objList := writeTestObjectList(t, gr)
+	buf := new(bytes.Buffer)
+	entryList, checksum, err := WritePack(buf, objList, 0, gr.ObjectFormat())
+	if err != nil { t.Fatal(err) }
+	if len(entryList) != len(objList) { t.Fatalf("%d entries, want %d", len(entryList), len(objList)) }
+	packPath := path.Join(t.TempDir(), "test.pack")
+	if err := os.WriteFile(packPath, buf.Bytes(), 0644); err != nil { t.Fatal(err) }
+	out := runGit(t, dir, "", "index-pack", packPath)
+	if out != fmt.Sprintf("%x", checksum) { t.Errorf("pack %s, want %x", out, checksum) }
+	out = runGit(t, dir, "", "verify-pack", "-v", strings.TrimSuffix(packPath, ".pack") + ".idx")
+	if !strings.Contains(out, "non delta: " + fmt.Sprint(len(objList)) + " objects") {
+		t.Errorf("some objects are deltified:\n%s", out)
+	}
+}
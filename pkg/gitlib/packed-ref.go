@@ -1,6 +1,7 @@
 package gitlib
 
 import (
+	"errors"
 	"io"
 	"os"
 	"path"
@@ -38,3 +39,42 @@ func (gr LocalGitRepository) readPackedRefIndex() ([]PackedRefIndexItem, error)
 	return res, nil
 }
 
+
+var ErrRefNotFound = errors.New("Ref not found")
+
+// resolves a full ref name (e.g. "refs/heads/main") to the object id
+// it points to. loose refs take precedence over the ones in
+// "packed-refs", same as git.
+func (gr LocalGitRepository) ResolveRef(name string) (string, error) {
+	// symbolic refs (e.g. HEAD) are followed for a few levels at most.
+	for range 5 {
+		f, err := os.ReadFile(path.Join(gr.GitDirectoryPath, name))
+		if err == nil {
+			s := strings.TrimSpace(string(f))
+			if target, ok := strings.CutPrefix(s, "ref: "); ok {
+				name = target
+				continue
+			}
+			return s, nil
+		}
+		if !os.IsNotExist(err) { return "", err }
+		l, err := gr.readPackedRefIndex()
+		if err != nil { return "", err }
+		for _, v := range l {
+			if v.Name == name { return v.Id, nil }
+		}
+		return "", ErrRefNotFound
+	}
+	return "", ErrRefNotFound
+}
+
+// the name of the branch `HEAD` points to (i.e. the default branch of
+// a bare repository), w/o the "refs/heads/" prefix. returns "" when
+// `HEAD` is detached.
+func (gr LocalGitRepository) GetHeadBranch() (string, error) {
+	f, err := os.ReadFile(path.Join(gr.GitDirectoryPath, "HEAD"))
+	if err != nil { return "", err }
+	target, ok := strings.CutPrefix(strings.TrimSpace(string(f)), "ref: refs/heads/")
+	if !ok { return "", nil }
+	return target, nil
+}
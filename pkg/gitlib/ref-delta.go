@@ -20,7 +20,7 @@ func (rd RefDeltaObject) RawData() []byte { return rd.rawData }
 
 func (rgo RawGitObject) ReadAsRefDeltaObject() (*RefDeltaObject, error) {
 	if rgo.objType != REF_DELTA { return nil, errors.New("Not a REF_DELTA object") }
-	base, err := readBytesToHex(rgo.reader, 20)
+	base, err := readBytesToHex(rgo.reader, rgo.hashSize)
 	if err != nil { return nil, err }
 	decompressed, err := zlib.NewReader(rgo.reader)
 	if err != nil { return nil, err }
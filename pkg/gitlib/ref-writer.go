@@ -0,0 +1,145 @@
+package gitlib
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// updating & deleting refs the same way git does: the new content is
+// written into `{ref}.lock` (created exclusively, so that two writers
+// can't update the same ref at once) and renamed to the ref itself.
+// the old value is checked while the lock is held.
+
+var ErrRefLocked = errors.New("Ref is locked by another process")
+var ErrRefChanged = errors.New("Ref has been changed by another process")
+var ErrInvalidRefName = errors.New("Invalid ref name")
+
+// a subset of the rules of `git check-ref-format`.
+func validRefName(name string) bool {
+	if !strings.HasPrefix(name, "refs/") { return false }
+	if strings.HasSuffix(name, "/") || strings.HasSuffix(name, ".") || strings.HasSuffix(name, ".lock") { return false }
+	if strings.Contains(name, "..") || strings.Contains(name, "//") || strings.Contains(name, "@{") { return false }
+	for _, c := range name {
+		if c < 0x20 || c == 0x7f || strings.ContainsRune(" ~^:?*[\\", c) { return false }
+	}
+	for p := range strings.SplitSeq(name, "/") {
+		if strings.HasPrefix(p, ".") { return false }
+	}
+	return true
+}
+
+type fileLock struct {
+	path string
+	file *os.File
+}
+
+func lockFile(p string) (*fileLock, error) {
+	err := os.MkdirAll(path.Dir(p), 0755)
+	if err != nil { return nil, err }
+	f, err := os.OpenFile(p + ".lock", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) { return nil, ErrRefLocked }
+	if err != nil { return nil, err }
+	return &fileLock{ path: p, file: f }, nil
+}
+
+// replaces the locked file w/ what has been written into the lock.
+func (l *fileLock) commit() error {
+	err := l.file.Close()
+	if err != nil { os.Remove(l.path + ".lock"); return err }
+	err = os.Rename(l.path + ".lock", l.path)
+	if err != nil { os.Remove(l.path + ".lock"); return err }
+	return nil
+}
+
+func (l *fileLock) rollback() {
+	l.file.Close()
+	os.Remove(l.path + ".lock")
+}
+
+// `oldId` is checked against the current value before updating: an
+// empty `oldId` skips the check, the all-zero id (see
+// `ObjectFormat.NullObjectId`) requires the ref to not exist yet.
+func (gr LocalGitRepository) checkRefOldValue(name string, oldId string) error {
+	if len(oldId) <= 0 { return nil }
+	current, err := gr.ResolveRef(name)
+	if errors.Is(err, ErrRefNotFound) {
+		if isNullObjectId(oldId) { return nil }
+		return ErrRefChanged
+	}
+	if err != nil { return err }
+	if current != strings.ToLower(oldId) { return ErrRefChanged }
+	return nil
+}
+
+// points the ref `name` (e.g. "refs/heads/main") to `newId`. see
+// `checkRefOldValue` for `oldId`.
+func (gr LocalGitRepository) WriteRef(name string, newId string, oldId string) error {
+	if !validRefName(name) { return ErrInvalidRefName }
+	if !gr.ObjectFormat().IsValidObjectId(newId) { return fmt.Errorf("Invalid object id: %s", newId) }
+	newId = strings.ToLower(newId)
+	lock, err := lockFile(path.Join(gr.GitDirectoryPath, name))
+	if err != nil { return err }
+	err = gr.checkRefOldValue(name, oldId)
+	if err != nil { lock.rollback(); return err }
+	_, err = fmt.Fprintf(lock.file, "%s\n", newId)
+	if err != nil { lock.rollback(); return err }
+	return lock.commit()
+}
+
+// removes the ref `name` from both the loose refs & `packed-refs`.
+// see `checkRefOldValue` for `oldId`.
+func (gr LocalGitRepository) DeleteRef(name string, oldId string) error {
+	if !validRefName(name) { return ErrInvalidRefName }
+	refPath := path.Join(gr.GitDirectoryPath, name)
+	lock, err := lockFile(refPath)
+	if err != nil { return err }
+	err = gr.checkRefOldValue(name, oldId)
+	if err == nil { err = gr.removePackedRef(name) }
+	if err == nil {
+		err = os.Remove(refPath)
+		if os.IsNotExist(err) { err = nil }
+	}
+	// the lock is only dropped, not committed, since the ref is gone.
+	lock.rollback()
+	if err != nil { return err }
+	// empty directories left behind (e.g. `refs/heads/feature/`) are
+	// removed as well; os.Remove fails on non-empty ones. `refs/heads/`
+	// & the like are kept, same as git.
+	for d := path.Dir(name); strings.Count(d, "/") >= 2; d = path.Dir(d) {
+		if os.Remove(path.Join(gr.GitDirectoryPath, d)) != nil { break }
+	}
+	return nil
+}
+
+// rewrites `packed-refs` w/o the ref `name` (and its peeled line if
+// there's one). the file is locked while doing so.
+func (gr LocalGitRepository) removePackedRef(name string) error {
+	p := path.Join(gr.GitDirectoryPath, "packed-refs")
+	if _, err := os.Stat(p); os.IsNotExist(err) { return nil }
+	lock, err := lockFile(p)
+	if err != nil { return err }
+	f, err := os.ReadFile(p)
+	if err != nil { lock.rollback(); return err }
+	lineList := strings.SplitAfter(string(f), "\n")
+	res := make([]string, 0, len(lineList))
+	found := false
+	skipPeeled := false
+	for _, l := range lineList {
+		if skipPeeled && strings.HasPrefix(l, "^") { continue }
+		skipPeeled = false
+		_, refName, ok := strings.Cut(strings.TrimSpace(l), " ")
+		if ok && !strings.HasPrefix(l, "#") && refName == name {
+			found = true
+			skipPeeled = true
+			continue
+		}
+		res = append(res, l)
+	}
+	if !found { lock.rollback(); return nil }
+	_, err = lock.file.WriteString(strings.Join(res, ""))
+	if err != nil { lock.rollback(); return err }
+	return lock.commit()
+}
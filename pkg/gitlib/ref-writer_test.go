@@ -0,0 +1,120 @@
+package gitlib
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func testWriteRef(t *testing.T, arg ...string) {
+	dir := newTestRepository(t, arg...)
+	gr := NewLocalGitRepository(dir)
+	objList := writeTestObjectList(t, gr)
+	var commitList, tagList []string
+	for _, o := range objList {
+		if o.Type == COMMIT { commitList = append(commitList, o.Id) }
+		if o.Type == TAG { tagList = append(tagList, o.Id) }
+	}
+	null := gr.ObjectFormat().NullObjectId()
+	check := func(name string, want string) {
+		t.Helper()
+		if got := runGit(t, dir, "", "rev-parse", "--verify", "-q", name); got != want {
+			t.Errorf("%s: %s, want %s", name, got, want)
+		}
+		got, err := gr.ResolveRef(name)
+		if err != nil || got != want { t.Errorf("%s: got %s, %v; want %s", name, got, err, want) }
+	}
+
+	// created only when it doesn't exist yet.
+	if err := gr.WriteRef("refs/heads/master", commitList[0], null); err != nil { t.Fatal(err) }
+	check("refs/heads/master", commitList[0])
+	if err := gr.WriteRef("refs/heads/master", commitList[1], null); !errors.Is(err, ErrRefChanged) {
+		t.Errorf("got %v, want ErrRefChanged", err)
+	}
+	// updated only from the expected value.
+	if err := gr.WriteRef("refs/heads/master", commitList[1], commitList[1]); !errors.Is(err, ErrRefChanged) {
+		t.Errorf("got %v, want ErrRefChanged", err)
+	}
+	if err := gr.WriteRef("refs/heads/master", strings.ToUpper(commitList[1]), strings.ToUpper(commitList[0])); err != nil { t.Fatal(err) }
+	check("refs/heads/master", commitList[1])
+	// w/o checking.
+	if err := gr.WriteRef("refs/heads/feature/a", commitList[0], ""); err != nil { t.Fatal(err) }
+	if err := gr.WriteRef("refs/tags/v1", tagList[0], ""); err != nil { t.Fatal(err) }
+	check("refs/heads/feature/a", commitList[0])
+	check("refs/tags/v1", tagList[0])
+	if err := gr.WriteRef("refs/heads/master", "not an id", ""); err == nil { t.Error("invalid id is written") }
+
+	// the lock held by git (or another writer) is respected.
+	lock := path.Join(dir, "refs", "heads", "master.lock")
+	if err := os.WriteFile(lock, []byte{}, 0644); err != nil { t.Fatal(err) }
+	if err := gr.WriteRef("refs/heads/master", commitList[0], ""); !errors.Is(err, ErrRefLocked) {
+		t.Errorf("got %v, want ErrRefLocked", err)
+	}
+	if err := gr.DeleteRef("refs/heads/master", ""); !errors.Is(err, ErrRefLocked) {
+		t.Errorf("got %v, want ErrRefLocked", err)
+	}
+	os.Remove(lock)
+	check("refs/heads/master", commitList[1])
+	out := runGit(t, dir, "", "for-each-ref", "--format=%(objectname) %(refname)")
+	want := strings.Join([]string{
+		commitList[0] + " refs/heads/feature/a",
+		commitList[1] + " refs/heads/master",
+		tagList[0] + " refs/tags/v1",
+	}, "\n")
+	if out != want { t.Errorf("git for-each-ref:\n%s\nwant:\n%s", out, want) }
+	runGit(t, dir, "", "fsck", "--strict", "--no-dangling")
+
+	// deleted from `packed-refs` as well, w/ the peeled line of tags.
+	runGit(t, dir, "", "pack-refs", "--all")
+	if err := gr.WriteRef("refs/heads/master", commitList[0], ""); err != nil { t.Fatal(err) }
+	if err := gr.DeleteRef("refs/tags/v1", commitList[0]); !errors.Is(err, ErrRefChanged) {
+		t.Errorf("got %v, want ErrRefChanged", err)
+	}
+	if err := gr.DeleteRef("refs/tags/v1", tagList[0]); err != nil { t.Fatal(err) }
+	if err := gr.DeleteRef("refs/heads/master", commitList[0]); err != nil { t.Fatal(err) }
+	if err := gr.DeleteRef("refs/heads/feature/a", ""); err != nil { t.Fatal(err) }
+	if out := runGit(t, dir, "", "for-each-ref"); out != "" { t.Errorf("refs left: %s", out) }
+	packedRef, err := os.ReadFile(path.Join(dir, "packed-refs"))
+	if err != nil { t.Fatal(err) }
+	for line := range strings.SplitSeq(string(packedRef), "\n") {
+		if len(line) > 0 && !strings.HasPrefix(line, "#") { t.Errorf("packed-refs: %q left", line) }
+	}
+	if _, err := os.Stat(path.Join(dir, "refs", "heads", "feature")); !os.IsNotExist(err) {
+		t.Errorf("refs/heads/feature is not removed: %v", err)
+	}
+	if _, err := os.Stat(path.Join(dir, "refs", "heads")); err != nil { t.Errorf("refs/heads is removed: %v", err) }
+	// deleting a ref that's not there.
+	if err := gr.DeleteRef("refs/heads/master", ""); err != nil { t.Error(err) }
+	if err := gr.DeleteRef("refs/heads/master", commitList[0]); !errors.Is(err, ErrRefChanged) {
+		t.Errorf("got %v, want ErrRefChanged", err)
+	}
+	runGit(t, dir, "", "fsck", "--strict")
+}
+
+func TestWriteRef(t *testing.T) {
+	testWriteRef(t)
+}
+
+// the names refused are the ones `git check-ref-format` refuses.
+func TestValidRefName(t *testing.T) {
+	requireGit(t)
+	for _, name := range []string{
+		"refs/heads/master", "refs/heads/feature/a", "refs/tags/v1.0", "refs/heads/a-b_c",
+		"refs/heads/", "refs/heads/a.", "refs/heads/a.lock", "refs/heads/a..b",
+		"refs/heads//a", "refs/heads/a@{b", "refs/heads/a b", "refs/heads/a~b",
+		"refs/heads/a^b", "refs/heads/a:b", "refs/heads/a?b", "refs/heads/a*b",
+		"refs/heads/a[b", "refs/heads/a\\b", "refs/heads/.a", "refs/heads/a/.b",
+		"refs/heads/a\x01", "refs/heads/a\x7f",
+	} {
+		_, err := runGitRaw("", "", "check-ref-format", name)
+		if got, want := validRefName(name), err == nil; got != want {
+			t.Errorf("%q: got %v, want %v", name, got, want)
+		}
+	}
+	// git allows these but refs outside of `refs/` aren't written.
+	for _, name := range []string{ "HEAD", "heads/master" } {
+		if validRefName(name) { t.Errorf("%q is valid", name) }
+	}
+}
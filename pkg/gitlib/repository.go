@@ -3,10 +3,12 @@ package gitlib
 import (
 	"bytes"
 	"compress/zlib"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path"
@@ -25,32 +27,60 @@ type LocalGitRepository struct {
 	isSHA256 bool
 	Hooks map[string]string
 	Submodule map[string]*SubmoduleConfig
+	// the context & the extra environment variables of the git
+	// commands run by this handle (see WithContext).
+	ctx context.Context
+	env []string
 }
 
 func (gr LocalGitRepository) IsSHA256() bool {
 	return gr.isSHA256
 }
 
+// returns a copy of the handle whose git commands are stopped when
+// `ctx` is done, are logged w/ `ctx` (so that the records carry e.g.
+// the request id) & are run w/ the extra environment variables `env`
+// (`KEY=value`), which are inherited by the hooks they run.
+func (gr LocalGitRepository) WithContext(ctx context.Context, env ...string) *LocalGitRepository {
+	gr.ctx = ctx
+	gr.env = env
+	return &gr
+}
+
+func (gr LocalGitRepository) context() context.Context {
+	if gr.ctx == nil { return context.Background() }
+	return gr.ctx
+}
+
+// a git command run in the repository w/ the handle's context &
+// environment.
+func (gr LocalGitRepository) gitCommand(arg ...string) *exec.Cmd {
+	return gr.gitCommandContext(gr.context(), arg...)
+}
+
+func (gr LocalGitRepository) gitCommandContext(ctx context.Context, arg ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "git", arg...)
+	cmd.Dir = gr.GitDirectoryPath
+	if len(gr.env) > 0 { cmd.Env = append(cmd.Environ(), gr.env...) }
+	slog.DebugContext(ctx, "gitlib: running git", "dir", cmd.Dir, "args", arg)
+	return cmd
+}
+
 func NewLocalGitRepository(p string) *LocalGitRepository {
 	res := LocalGitRepository{
 		GitDirectoryPath: p,
 		PackIndex: nil,
 		Hooks: nil,
 	}
-	pi, err := res.readAllPackIndex()
+	_, err := os.ReadDir(path.Join(p, "objects", "pack"))
 	if err != nil {
 		errs := err.Error()
 		os.MkdirAll(p, os.ModeDir|0755)
-		cmd := exec.Command("git", "init", "--bare")
-		cmd.Dir = p
-		err := cmd.Run()
+		err := InitBareRepository(p, "")
 		if err != nil {
 			log.Panicf("Failed to create a handle on local git repository:\n%s\n%s", errs, err.Error())
-		} else {
-			pi, err = res.readAllPackIndex()
 		}
 	}
-	res.PackIndex = pi
 	description, err := res.readDescription()
 	if err != nil { description = "Error due to: " + err.Error() }
 	res.Description = description
@@ -71,9 +101,12 @@ func NewLocalGitRepository(p string) *LocalGitRepository {
 			res.isSHA256 = false
 		}
 	}
+	// the pack indices need to know the object format, so they're read
+	// after the config.
+	pi, _ := res.readAllPackIndex()
+	res.PackIndex = pi
 	res.LoadSubmoduleConfig()
-	cmd := exec.Command("git", "update-server-info")
-	cmd.Dir = p
+	cmd := res.gitCommand("update-server-info")
 	// ignore error for now.
 	cmd.Run()
 	return &res
@@ -126,7 +159,9 @@ func (gr LocalGitRepository) OpenDirectlyAccessibleObject(h string) (io.ReadClos
 
 // targetAbsDir must be absolute path.
 func (gr LocalGitRepository) LocalForkTo(targetName string, targetAbsDir string) error {
-	cmd := exec.Command("git", "clone", "--bare", gr.GitDirectoryPath, targetAbsDir)
+	cmd := gr.gitCommand("clone", "--bare", gr.GitDirectoryPath, targetAbsDir)
+	// the repository's path could be relative to the working directory.
+	cmd.Dir = ""
 	stderrBuf := new(bytes.Buffer)
 	cmd.Stderr = stderrBuf
 	err := cmd.Run()
@@ -139,13 +174,12 @@ func (gr LocalGitRepository) LocalForkTo(targetName string, targetAbsDir string)
 		return errors.New(err.Error() + ": " + stderrBuf.String())
 	}
 	if !containsRemote {
-		cmd2 = exec.Command("git", "remote", "add", targetName, targetAbsDir)
+		cmd2 = gr.gitCommand("remote", "add", targetName, targetAbsDir)
 	} else {
-		cmd2 = exec.Command("git", "remote", "set-url", targetName, targetAbsDir)
+		cmd2 = gr.gitCommand("remote", "set-url", targetName, targetAbsDir)
 	}
 	stderrBuf.Reset()
 	cmd2.Stderr = stderrBuf
-	cmd2.Dir = gr.GitDirectoryPath
 	err = cmd2.Run()
 	if err != nil {
 		return errors.New(err.Error() + ": " + stderrBuf.String())
@@ -185,16 +219,14 @@ func (gr LocalGitRepository) CompareBranchWithRemote(localBranch string, remoteN
 	hasRemote, err := gr.HasRemote(remoteName)
 	if err != nil { return nil, err }
 	if !hasRemote { return nil, nil }
-	cmd1 := exec.Command("git", "fetch", remoteName)
-	cmd1.Dir = gr.GitDirectoryPath
+	cmd1 := gr.gitCommand("fetch", remoteName)
 	stderrBuf := new(bytes.Buffer)
 	cmd1.Stderr = stderrBuf
 	err = cmd1.Run()
 	if err != nil {
 		return nil, fmt.Errorf("Failed to git-fetch: %s; %s", err, stderrBuf.String())
 	}
-	cmd2 := exec.Command("git", "merge-base", fmt.Sprintf("refs/heads/%s", localBranch), fmt.Sprintf("%s/%s", remoteName, localBranch))
-	cmd2.Dir = gr.GitDirectoryPath
+	cmd2 := gr.gitCommand("merge-base", fmt.Sprintf("refs/heads/%s", localBranch), fmt.Sprintf("%s/%s", remoteName, localBranch))
 	stdoutBuf := new(bytes.Buffer)
 	cmd2.Stdout = stdoutBuf
 	stderrBuf.Reset()
@@ -204,8 +236,7 @@ func (gr LocalGitRepository) CompareBranchWithRemote(localBranch string, remoteN
 		return nil, fmt.Errorf("Failed to git-merge-base: %s; %s", err, stderrBuf.String())
 	}
 	baseId := strings.TrimSpace(stdoutBuf.String())
-	cmd3 := exec.Command("git", "rev-list", fmt.Sprintf("%s..refs/heads/%s", baseId, localBranch))
-	cmd3.Dir = gr.GitDirectoryPath
+	cmd3 := gr.gitCommand("rev-list", fmt.Sprintf("%s..refs/heads/%s", baseId, localBranch))
 	stdoutBuf.Reset()
 	cmd3.Stdout = stdoutBuf
 	stderrBuf.Reset()
@@ -221,8 +252,7 @@ func (gr LocalGitRepository) CompareBranchWithRemote(localBranch string, remoteN
 	} else {
 		alist = strings.Split(ares, "\n")
 	}
-	cmd4 := exec.Command("git", "rev-list", fmt.Sprintf("%s..%s/%s", baseId, remoteName,localBranch))
-	cmd4.Dir = gr.GitDirectoryPath
+	cmd4 := gr.gitCommand("rev-list", fmt.Sprintf("%s..%s/%s", baseId, remoteName,localBranch))
 	stdoutBuf.Reset()
 	cmd4.Stdout = stdoutBuf
 	stderrBuf.Reset()
@@ -249,16 +279,14 @@ func (gr LocalGitRepository) CheckIfCanFastForward(branch string, remoteName str
 	hasRemote, err := gr.HasRemote(remoteName)
 	if err != nil { return false, err }
 	if !hasRemote { return false, nil }
-	cmd1 := exec.Command("git", "fetch", remoteName)
-	cmd1.Dir = gr.GitDirectoryPath
+	cmd1 := gr.gitCommand("fetch", remoteName)
 	stderrBuf := new(bytes.Buffer)
 	cmd1.Stderr = stderrBuf
 	err = cmd1.Run()
 	if err != nil {
 		return false, fmt.Errorf("Failed to git-fetch: %s; %s", err, stderrBuf.String())
 	}
-	cmd2 := exec.Command("git", "merge-base", fmt.Sprintf("refs/heads/%s", branch), fmt.Sprintf("%s/%s", remoteName, branch))
-	cmd2.Dir = gr.GitDirectoryPath
+	cmd2 := gr.gitCommand("merge-base", fmt.Sprintf("refs/heads/%s", branch), fmt.Sprintf("%s/%s", remoteName, branch))
 	stdoutBuf := new(bytes.Buffer)
 	cmd2.Stdout = stdoutBuf
 	stderrBuf.Reset()
@@ -277,8 +305,7 @@ func (gr LocalGitRepository) CheckIfCanFastForward(branch string, remoteName str
 }
 
 func (gr LocalGitRepository) FetchRemote(remote string) error {
-	cmd1 := exec.Command("git", "fetch", remote, "--tags")
-	cmd1.Dir = gr.GitDirectoryPath
+	cmd1 := gr.gitCommand("fetch", remote, "--tags")
 	stderrBuf := new(bytes.Buffer)
 	cmd1.Stderr = stderrBuf
 	err := cmd1.Run()
@@ -291,8 +318,7 @@ func (gr LocalGitRepository) FetchRemote(remote string) error {
 func (gr LocalGitRepository) SyncEmptyRepositoryFromRemote(remote string) error {
 	err := gr.FetchRemote(remote)
 	if err != nil { return err }
-	cmd1 := exec.Command("git", "ls-remote", "--branches", "--tags", remote)
-	cmd1.Dir = gr.GitDirectoryPath
+	cmd1 := gr.gitCommand("ls-remote", "--branches", "--tags", remote)
 	stdout := new(bytes.Buffer)
 	cmd1.Stdout = stdout
 	err = cmd1.Run()
@@ -300,22 +326,19 @@ func (gr LocalGitRepository) SyncEmptyRepositoryFromRemote(remote string) error
 	for v := range strings.SplitSeq(stdout.String(), "\n") {
 		p := strings.Split(v, "\t")
 		if len(p) < 2 { continue }
-		cmd2 := exec.Command("git", "update-ref", p[1], p[0])
-		cmd2.Dir = gr.GitDirectoryPath
-		err = cmd2.Run()
+		// the peeled entries of annotated tags (`refs/tags/v1^{}`)
+		// aren't refs.
+		if strings.HasSuffix(p[1], "^{}") { continue }
+		err = gr.WriteRef(p[1], p[0], "")
 		if err != nil { return err }
 	}
 	return nil
 }
 
 func (gr LocalGitRepository) UpdateRef(branch string, targetId string) error {
-	cmd := exec.Command("git", "update-ref", fmt.Sprintf("refs/heads/%s", branch), targetId)
-	cmd.Dir = gr.GitDirectoryPath
-	stderrBuf := new(bytes.Buffer)
-	cmd.Stderr = stderrBuf
-	err := cmd.Run()
+	err := gr.WriteRef(fmt.Sprintf("refs/heads/%s", branch), targetId, "")
 	if err != nil {
-		return fmt.Errorf("Failed to update-ref: %s; %s", err, stderrBuf.String())
+		return fmt.Errorf("Failed to update-ref: %s", err)
 	}
 	return nil
 }
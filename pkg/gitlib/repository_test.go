@@ -0,0 +1,52 @@
+package gitlib
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+type testContextKey struct{}
+
+// records the value of testContextKey in the contexts it's logged w/.
+type testContextHandler struct {
+	slog.Handler
+	valueList *[]any
+}
+
+func (h testContextHandler) Handle(ctx context.Context, r slog.Record) error {
+	*h.valueList = append(*h.valueList, ctx.Value(testContextKey{}))
+	return nil
+}
+
+// the git commands run w/ the context & the environment of the handle,
+// & the handle it's made from is left as it is.
+func TestWithContext(t *testing.T) {
+	dir := newTestRepository(t)
+	commitId := commitTestFile(t, dir, map[string]testFile{ "a": regularFile("a\n") }, "first")
+	gr := NewLocalGitRepository(dir)
+	ctx := context.WithValue(context.Background(), testContextKey{}, "request")
+	cgr := gr.WithContext(ctx, "GIT_EDITOR=context-test")
+
+	valueList := make([]any, 0)
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(testContextHandler{ Handler: slog.NewTextHandler(nil, &slog.HandlerOptions{ Level: slog.LevelDebug }), valueList: &valueList }))
+	defer slog.SetDefault(defaultLogger)
+	out, err := cgr.gitCommand("var", "GIT_EDITOR").Output()
+	if err != nil { t.Fatal(err) }
+	if strings.TrimSpace(string(out)) != "context-test" { t.Errorf("editor %q", out) }
+	if len(valueList) != 1 || valueList[0] != "request" { t.Errorf("logged w/ %v", valueList) }
+	out, err = gr.gitCommand("var", "GIT_EDITOR").Output()
+	if err != nil { t.Fatal(err) }
+	if strings.TrimSpace(string(out)) == "context-test" { t.Errorf("editor %q", out) }
+
+	cobj, err := gr.readCommit(commitId)
+	if err != nil { t.Fatal(err) }
+	if _, err := cgr.ResolvePathLastCommitId(cobj, "a"); err != nil { t.Fatal(err) }
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := gr.WithContext(cctx).ResolvePathLastCommitId(cobj, "a"); err == nil {
+		t.Error("git is run w/ a cancelled context")
+	}
+}
@@ -0,0 +1,103 @@
+package gitlib
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// helpers for collecting repository statistics. these go through the
+// whole history (or the whole tree) of a commit so they're expensive
+// on big repositories; the caller is expected to cache the result.
+
+type TreeFileSize struct {
+	Path string
+	Size int64
+}
+
+// returns the size of every blob reachable from the tree of the
+// specified commit. submodules are skipped.
+func (gr LocalGitRepository) GetTreeFileSizeList(commitId string) ([]TreeFileSize, error) {
+	cmd := gr.gitCommand("ls-tree", "-r", "-l", "-z", "--full-tree", commitId)
+	stdoutBuf := new(bytes.Buffer)
+	cmd.Stdout = stdoutBuf
+	stderrBuf := new(bytes.Buffer)
+	cmd.Stderr = stderrBuf
+	err := cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to git-ls-tree: %s; %s", err, stderrBuf.String())
+	}
+	res := make([]TreeFileSize, 0)
+	// each entry is in the format of:
+	//     {mode} SP {type} SP {objid} SP* {size} TAB {path} NUL
+	for item := range strings.SplitSeq(stdoutBuf.String(), "\x00") {
+		if len(item) <= 0 { continue }
+		tabPos := strings.IndexByte(item, '\t')
+		if tabPos == -1 { continue }
+		header := strings.Fields(item[:tabPos])
+		if len(header) < 4 { continue }
+		if header[1] != "blob" { continue }
+		size, err := strconv.ParseInt(header[3], 10, 64)
+		if err != nil { continue }
+		res = append(res, TreeFileSize{
+			Path: item[tabPos+1:],
+			Size: size,
+		})
+	}
+	return res, nil
+}
+
+type CommitStat struct {
+	CommitId string
+	AuthorName string
+	AuthorEmail string
+	Time time.Time
+	// number of lines added & deleted. binary files are not counted;
+	// merge commits always have both as 0.
+	Addition int64
+	Deletion int64
+}
+
+// returns the author & line-change statistics of every commit
+// reachable from the specified commit, newest first.
+func (gr LocalGitRepository) GetCommitStatList(commitId string) ([]*CommitStat, error) {
+	cmd := gr.gitCommand("log", "--numstat", "--format=%x00%H%x00%an%x00%ae%x00%at", commitId, "--")
+	stdoutBuf := new(bytes.Buffer)
+	cmd.Stdout = stdoutBuf
+	stderrBuf := new(bytes.Buffer)
+	cmd.Stderr = stderrBuf
+	err := cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to git-log: %s; %s", err, stderrBuf.String())
+	}
+	res := make([]*CommitStat, 0)
+	var current *CommitStat = nil
+	for line := range strings.SplitSeq(stdoutBuf.String(), "\n") {
+		if len(line) <= 0 { continue }
+		if line[0] == 0 {
+			header := strings.Split(line[1:], "\x00")
+			if len(header) < 4 { return nil, ErrInvalidFormat }
+			timestamp, _ := strconv.ParseInt(header[3], 10, 64)
+			current = &CommitStat{
+				CommitId: header[0],
+				AuthorName: header[1],
+				AuthorEmail: header[2],
+				Time: time.Unix(timestamp, 0).UTC(),
+			}
+			res = append(res, current)
+			continue
+		}
+		if current == nil { continue }
+		// numstat line: {added} TAB {deleted} TAB {path}. binary
+		// files have "-" in place of the numbers.
+		p := strings.SplitN(line, "\t", 3)
+		if len(p) < 3 { continue }
+		a, err := strconv.ParseInt(p[0], 10, 64)
+		if err == nil { current.Addition += a }
+		d, err := strconv.ParseInt(p[1], 10, 64)
+		if err == nil { current.Deletion += d }
+	}
+	return res, nil
+}
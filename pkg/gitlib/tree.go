@@ -16,7 +16,8 @@ import (
 // 1.  6 byte "object mode", digits in ascii
 // 2.  1 byte space character 0x20
 // 3.  variable length zero-terminated string, which is the file name;
-// 4.  20 byte SHA-1 hash, which to us is the object id.
+// 4.  20 byte SHA-1 hash (32 byte SHA-256 hash in sha-256
+//     repositories), which to us is the object id.
 // tree objects represent folders within the repo.
 // possible valid mode numbers are listed as follows:
 // 100644  -  normal file.
@@ -146,7 +147,7 @@ func (rgo RawGitObject) ReadAsTreeObject() (*TreeObject, error) {
 	_, err = io.ReadFull(decompressedReader, sourceBytes)
 	if err != nil { return nil, err }
 	newReader := bytes.NewReader(sourceBytes)
-	resobj, err := parseTreeObject(rgo.objId, newReader)
+	resobj, err := parseTreeObject(rgo.objId, newReader, rgo.hashSize)
 	if err != nil { return nil, err }
 	resobj.rawData = sourceBytes
 	return resobj, nil
@@ -159,14 +160,14 @@ func (rgo RawGitObject) readAsTreeObjectNoDeflate() (*TreeObject, error) {
 	_, err := io.ReadFull(rgo.reader, sourceBytes)
 	if err != nil { return nil, err }
 	newReader := bytes.NewReader(sourceBytes)
-	resobj, err := parseTreeObject(rgo.objId, newReader)
+	resobj, err := parseTreeObject(rgo.objId, newReader, rgo.hashSize)
 	if err != nil { return nil, err }
 	resobj.rawData = sourceBytes
 	resobj.Id = rgo.objId
 	return resobj, nil
 }
 
-func parseTreeObject(objid string, f io.Reader) (*TreeObject, error) {
+func parseTreeObject(objid string, f io.Reader, hashSize int) (*TreeObject, error) {
 	submoduleList := make([]TreeObjectItem, 0)
 	dirList := make([]TreeObjectItem, 0)
 	fileList := make([]TreeObjectItem, 0)
@@ -177,7 +178,7 @@ func parseTreeObject(objid string, f io.Reader) (*TreeObject, error) {
 		mode, err := strconv.ParseInt(modeAndNameList[0], 10, 64)
 		if err != nil { return nil, err }
 		mode = int64(resolveCanonicalMode(mode))
-		objid, err := readBytesToHex(f, 20)
+		objid, err := readBytesToHex(f, hashSize)
 		if err != nil { return nil, err }
 		treeItem := TreeObjectItem{
 			Mode: int(mode),
@@ -0,0 +1,281 @@
+package gitlib
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// tree-to-tree diff w/ rename detection.
+
+const (
+	TREE_CHANGE_ADD uint8 = 1
+	TREE_CHANGE_DELETE uint8 = 2
+	TREE_CHANGE_MODIFY uint8 = 3
+	TREE_CHANGE_RENAME uint8 = 4
+)
+
+// the minimum similarity (in percent) for a deleted file & an added
+// file to be considered a rename. same as git's default.
+const DIFF_RENAME_THRESHOLD = 50
+
+// inexact rename detection compares every deleted file with every
+// added file; it's skipped when there are more than this many of
+// either. same as git's default `diff.renameLimit`.
+const DIFF_RENAME_LIMIT = 1000
+
+type TreeChange struct {
+	Type uint8
+	OldPath string
+	NewPath string
+	OldMode int
+	NewMode int
+	OldId string
+	NewId string
+	// only for TREE_CHANGE_RENAME; in percent.
+	Similarity int
+}
+
+// the path the change would be listed under.
+func (tc *TreeChange) Path() string {
+	if tc.Type == TREE_CHANGE_DELETE { return tc.OldPath }
+	return tc.NewPath
+}
+
+type treeEntry struct {
+	Mode int
+	Id string
+}
+
+// normal files & executable files are the same kind of thing; a
+// change between different kinds is shown as a deletion and an
+// addition.
+func treeModeKind(m int) int {
+	if m == TREE_EXECUTABLE_FILE { return TREE_NORMAL_FILE }
+	return m
+}
+
+var ErrNotTree = errors.New("Not a tree object")
+var ErrNotBlob = errors.New("Not a blob object")
+
+// an empty `treeId` is treated as an empty tree.
+func (gr LocalGitRepository) readTreeEntryList(treeId string) ([]TreeObjectItem, error) {
+	if len(treeId) <= 0 { return nil, nil }
+	gobj, err := gr.ReadObject(treeId)
+	if err != nil { return nil, err }
+	tobj, ok := gobj.(*TreeObject)
+	if !ok { return nil, ErrNotTree }
+	return tobj.ObjectList, nil
+}
+
+func (gr LocalGitRepository) readBlobData(blobId string) ([]byte, error) {
+	gobj, err := gr.ReadObject(blobId)
+	if err != nil { return nil, err }
+	bobj, ok := gobj.(*BlobObject)
+	if !ok { return nil, ErrNotBlob }
+	return bobj.Data, nil
+}
+
+// collects all non-tree entries under `treeId` into `res`, keyed by
+// their full path.
+func (gr LocalGitRepository) flattenTree(treeId string, prefix string, res map[string]treeEntry) error {
+	l, err := gr.readTreeEntryList(treeId)
+	if err != nil { return err }
+	for _, v := range l {
+		p := path.Join(prefix, v.Name)
+		if v.Mode == TREE_TREE_OBJECT {
+			err = gr.flattenTree(v.Hash, p, res)
+			if err != nil { return err }
+			continue
+		}
+		res[p] = treeEntry{ Mode: v.Mode, Id: v.Hash }
+	}
+	return nil
+}
+
+// the changes between two trees (w/o rename detection). subtrees
+// with the same id on both sides are not read at all.
+func (gr LocalGitRepository) diffTreeNoRename(oldTreeId string, newTreeId string, prefix string, res *[]*TreeChange) error {
+	if oldTreeId == newTreeId { return nil }
+	ol, err := gr.readTreeEntryList(oldTreeId)
+	if err != nil { return err }
+	nl, err := gr.readTreeEntryList(newTreeId)
+	if err != nil { return err }
+	om := make(map[string]TreeObjectItem, len(ol))
+	for _, v := range ol { om[v.Name] = v }
+	nm := make(map[string]TreeObjectItem, len(nl))
+	for _, v := range nl { nm[v.Name] = v }
+	addAll := func(item TreeObjectItem, isDelete bool) error {
+		p := path.Join(prefix, item.Name)
+		if item.Mode == TREE_TREE_OBJECT {
+			if isDelete { return gr.diffTreeNoRename(item.Hash, "", p, res) }
+			return gr.diffTreeNoRename("", item.Hash, p, res)
+		}
+		if isDelete {
+			*res = append(*res, &TreeChange{
+				Type: TREE_CHANGE_DELETE, OldPath: p, OldMode: item.Mode, OldId: item.Hash,
+			})
+		} else {
+			*res = append(*res, &TreeChange{
+				Type: TREE_CHANGE_ADD, NewPath: p, NewMode: item.Mode, NewId: item.Hash,
+			})
+		}
+		return nil
+	}
+	for _, o := range ol {
+		n, ok := nm[o.Name]
+		if !ok {
+			err = addAll(o, true)
+			if err != nil { return err }
+			continue
+		}
+		if o.Mode == n.Mode && o.Hash == n.Hash { continue }
+		p := path.Join(prefix, o.Name)
+		if o.Mode == TREE_TREE_OBJECT && n.Mode == TREE_TREE_OBJECT {
+			err = gr.diffTreeNoRename(o.Hash, n.Hash, p, res)
+			if err != nil { return err }
+			continue
+		}
+		if treeModeKind(o.Mode) != treeModeKind(n.Mode) {
+			err = addAll(o, true)
+			if err != nil { return err }
+			err = addAll(n, false)
+			if err != nil { return err }
+			continue
+		}
+		*res = append(*res, &TreeChange{
+			Type: TREE_CHANGE_MODIFY,
+			OldPath: p, NewPath: p,
+			OldMode: o.Mode, NewMode: n.Mode,
+			OldId: o.Hash, NewId: n.Hash,
+		})
+	}
+	for _, n := range nl {
+		if _, ok := om[n.Name]; ok { continue }
+		err = addAll(n, false)
+		if err != nil { return err }
+	}
+	return nil
+}
+
+// returns the changes from `oldTreeId` to `newTreeId`, sorted by
+// path. an empty tree id is treated as an empty tree.
+func (gr LocalGitRepository) DiffTree(oldTreeId string, newTreeId string, detectRename bool) ([]*TreeChange, error) {
+	res := make([]*TreeChange, 0)
+	err := gr.diffTreeNoRename(oldTreeId, newTreeId, "", &res)
+	if err != nil { return nil, err }
+	if detectRename {
+		res, err = gr.detectRename(res)
+		if err != nil { return nil, err }
+	}
+	slices.SortStableFunc(res, func(a, b *TreeChange) int {
+		return strings.Compare(a.Path(), b.Path())
+	})
+	return res, nil
+}
+
+// only files & symbolic links can be renamed.
+func isRenameCandidate(mode int) bool {
+	return mode == TREE_NORMAL_FILE || mode == TREE_EXECUTABLE_FILE || mode == TREE_SYMBOLIC_LINK
+}
+
+// the similarity of two blobs in percent, i.e. the size of the lines
+// they have in common divided by the size of the larger one.
+func blobSimilarity(a []byte, b []byte) int {
+	if len(a) <= 0 && len(b) <= 0 { return 100 }
+	count := make(map[string]int)
+	for _, l := range splitLines(a) { count[l] += 1 }
+	common := 0
+	for _, l := range splitLines(b) {
+		if count[l] > 0 {
+			count[l] -= 1
+			common += len(l)
+		}
+	}
+	return common * 100 / max(len(a), len(b))
+}
+
+// pairs up deleted & added files into renames. exact matches (same
+// blob id) are paired first, then the pairs w/ the highest similarity
+// above DIFF_RENAME_THRESHOLD.
+func (gr LocalGitRepository) detectRename(l []*TreeChange) ([]*TreeChange, error) {
+	deleted := make([]*TreeChange, 0)
+	added := make([]*TreeChange, 0)
+	for _, v := range l {
+		if v.Type == TREE_CHANGE_DELETE && isRenameCandidate(v.OldMode) { deleted = append(deleted, v) }
+		if v.Type == TREE_CHANGE_ADD && isRenameCandidate(v.NewMode) { added = append(added, v) }
+	}
+	if len(deleted) <= 0 || len(added) <= 0 { return l, nil }
+	// the deleted/added changes that became a part of a rename.
+	used := make(map[*TreeChange]bool)
+	renameList := make([]*TreeChange, 0)
+	pair := func(d *TreeChange, a *TreeChange, similarity int) {
+		used[d] = true
+		used[a] = true
+		renameList = append(renameList, &TreeChange{
+			Type: TREE_CHANGE_RENAME,
+			OldPath: d.OldPath, NewPath: a.NewPath,
+			OldMode: d.OldMode, NewMode: a.NewMode,
+			OldId: d.OldId, NewId: a.NewId,
+			Similarity: similarity,
+		})
+	}
+	byId := make(map[string][]*TreeChange)
+	for _, d := range deleted { byId[d.OldId] = append(byId[d.OldId], d) }
+	for _, a := range added {
+		for _, d := range byId[a.NewId] {
+			if used[d] || treeModeKind(d.OldMode) != treeModeKind(a.NewMode) { continue }
+			pair(d, a, 100)
+			break
+		}
+	}
+	if len(deleted) <= DIFF_RENAME_LIMIT && len(added) <= DIFF_RENAME_LIMIT {
+		blobCache := make(map[string][]byte)
+		readBlob := func(id string) ([]byte, error) {
+			if d, ok := blobCache[id]; ok { return d, nil }
+			d, err := gr.readBlobData(id)
+			if err != nil { return nil, err }
+			blobCache[id] = d
+			return d, nil
+		}
+		type candidate struct {
+			d *TreeChange
+			a *TreeChange
+			score int
+		}
+		candidateList := make([]candidate, 0)
+		for _, d := range deleted {
+			if used[d] { continue }
+			dData, err := readBlob(d.OldId)
+			if err != nil { return nil, err }
+			for _, a := range added {
+				if used[a] || treeModeKind(d.OldMode) != treeModeKind(a.NewMode) { continue }
+				aData, err := readBlob(a.NewId)
+				if err != nil { return nil, err }
+				// can't reach the threshold if one is less than half
+				// the size of the other.
+				if min(len(dData), len(aData)) * 100 < max(len(dData), len(aData)) * DIFF_RENAME_THRESHOLD { continue }
+				score := blobSimilarity(dData, aData)
+				if score < DIFF_RENAME_THRESHOLD { continue }
+				candidateList = append(candidateList, candidate{ d: d, a: a, score: score })
+			}
+		}
+		slices.SortStableFunc(candidateList, func(x, y candidate) int { return y.score - x.score })
+		for _, c := range candidateList {
+			if used[c.d] || used[c.a] { continue }
+			pair(c.d, c.a, c.score)
+		}
+	}
+	res := make([]*TreeChange, 0, len(l))
+	for _, v := range l {
+		if !used[v] { res = append(res, v) }
+	}
+	res = append(res, renameList...)
+	return res, nil
+}
+
+func (tc *TreeChange) String() string {
+	return fmt.Sprintf("<<%d,%s,%s>>", tc.Type, tc.OldPath, tc.NewPath)
+}
@@ -0,0 +1,129 @@
+package gitlib
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// the changes as listed by `git diff-tree -r -M`, w/o the similarity
+// since git computes it differently.
+func formatTreeChange(l []*TreeChange) string {
+	res := make([]string, 0, len(l))
+	for _, v := range l {
+		switch v.Type {
+		case TREE_CHANGE_ADD:
+			res = append(res, fmt.Sprintf("A %06d %s\t%s", v.NewMode, v.NewId, v.NewPath))
+		case TREE_CHANGE_DELETE:
+			res = append(res, fmt.Sprintf("D %06d %s\t%s", v.OldMode, v.OldId, v.OldPath))
+		case TREE_CHANGE_MODIFY:
+			res = append(res, fmt.Sprintf("M %06d %06d %s %s\t%s", v.OldMode, v.NewMode, v.OldId, v.NewId, v.NewPath))
+		case TREE_CHANGE_RENAME:
+			res = append(res, fmt.Sprintf("R %06d %06d %s %s\t%s\t%s", v.OldMode, v.NewMode, v.OldId, v.NewId, v.OldPath, v.NewPath))
+		}
+	}
+	slices.Sort(res)
+	return strings.Join(res, "\n")
+}
+
+func gitDiffTree(t *testing.T, dir string, oldId string, newId string) string {
+	t.Helper()
+	out := runGit(t, dir, "", "diff-tree", "-r", "-M", "-z", "--no-abbrev", oldId, newId)
+	l := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	res := make([]string, 0)
+	for i := 0; i + 1 < len(l); i += 2 {
+		// :{old mode} {new mode} {old id} {new id} {status}
+		field := strings.Fields(strings.TrimPrefix(l[i], ":"))
+		switch field[4][0] {
+		case 'A':
+			res = append(res, fmt.Sprintf("A %s %s\t%s", field[1], field[3], l[i+1]))
+		case 'D':
+			res = append(res, fmt.Sprintf("D %s %s\t%s", field[0], field[2], l[i+1]))
+		case 'M':
+			res = append(res, fmt.Sprintf("M %s %s %s %s\t%s", field[0], field[1], field[2], field[3], l[i+1]))
+		case 'R':
+			res = append(res, fmt.Sprintf("R %s %s %s %s\t%s\t%s", field[0], field[1], field[2], field[3], l[i+1], l[i+2]))
+			i += 1
+		default:
+			t.Fatalf("unexpected diff-tree output: %q", out)
+		}
+	}
+	slices.Sort(res)
+	return strings.Join(res, "\n")
+}
+
+func TestDiffTree(t *testing.T) {
+	dir := newTestRepository(t)
+	gr := NewLocalGitRepository(dir)
+	file := regularFile
+	text := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+	caseList := []struct {
+		name string
+		old map[string]testFile
+		new map[string]testFile
+	}{
+		{
+			"add, delete & modify",
+			map[string]testFile{ "a": file("a\n"), "d/b": file("b\n"), "d/e/c": file("c\n") },
+			map[string]testFile{ "a": file("A\n"), "d/e/c": file("c\n"), "d/e/f": file("f\n"), "g": file("g\n") },
+		},
+		{
+			"directory deleted",
+			map[string]testFile{ "a": file("a\n"), "d/b": file("b\n"), "d/e/c": file("c\n") },
+			map[string]testFile{ "a": file("a\n") },
+		},
+		{
+			"mode change",
+			map[string]testFile{ "a": file("a\n"), "b": file("b\n") },
+			map[string]testFile{ "a": executableFile("a\n"), "b": executableFile("B\n") },
+		},
+		{
+			"exact rename",
+			map[string]testFile{ "a": file(text) },
+			map[string]testFile{ "d/b": file(text) },
+		},
+		{
+			"similar rename",
+			map[string]testFile{ "a": file(text) },
+			map[string]testFile{ "b": file(strings.Replace(text, "5\n", "five\n", 1)) },
+		},
+		{
+			"rename w/ mode change",
+			map[string]testFile{ "a": file(text) },
+			map[string]testFile{ "b": executableFile(text) },
+		},
+		{
+			"not similar enough",
+			map[string]testFile{ "a": file(text) },
+			map[string]testFile{ "b": file("x\ny\nz\n") },
+		},
+		{
+			"same content renamed twice",
+			map[string]testFile{ "a": file(text), "b": file(text) },
+			map[string]testFile{ "c": file(text), "d": file(text) },
+		},
+		{
+			"renamed & replaced",
+			map[string]testFile{ "a": file(text) },
+			map[string]testFile{ "a": file("x\ny\nz\n"), "b": file(text) },
+		},
+		{
+			"symbolic link",
+			map[string]testFile{ "a": symbolicLink("x") },
+			map[string]testFile{ "a": symbolicLink("y"), "b": symbolicLink("z") },
+		},
+	}
+	for _, c := range caseList {
+		t.Run(c.name, func(t *testing.T) {
+			oldId := runGit(t, dir, "", "rev-parse", commitTestFile(t, dir, c.old, "old") + "^{tree}")
+			newId := runGit(t, dir, "", "rev-parse", commitTestFile(t, dir, c.new, "new") + "^{tree}")
+			want := gitDiffTree(t, dir, oldId, newId)
+			got, err := gr.DiffTree(oldId, newId, true)
+			if err != nil { t.Fatal(err) }
+			if g := formatTreeChange(got); g != want {
+				t.Errorf("got:\n%s\nwant (git diff-tree):\n%s", g, want)
+			}
+		})
+	}
+}
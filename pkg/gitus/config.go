@@ -10,6 +10,7 @@ import (
 	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	"github.com/GitusCodeForge/Gitus/pkg/gitlib"
@@ -104,6 +105,31 @@ type GitusConfig struct {
 
 	BindAddress string `json:"bindAddress"`
 	BindPort int `json:"bindPort"`
+	// how long (in seconds) to wait for the in-flight requests & git
+	// subprocesses when shutting down before cutting them off. 0 means
+	// the default (30 seconds). see docs/shutdown.org.
+	ShutdownTimeout int `json:"shutdownTimeout"`
+	// reload the config when the config file is changed. see
+	// docs/config-reload.org.
+	WatchConfigFile bool `json:"watchConfigFile"`
+
+	// the built-in ssh server, which can be used instead of sshd &
+	// the authorized_keys file of the git user. see
+	// docs/ssh-server.org.
+	SSHServer GitusSSHServerConfig `json:"sshServer"`
+
+	// prometheus metrics. see docs/metrics.org.
+	Metrics GitusMetricsConfig `json:"metrics"`
+
+	// the periodic maintenance of the repositories (repack, prune,
+	// etc.). see docs/repo-maintenance.org.
+	Maintenance GitusMaintenanceConfig `json:"maintenance"`
+
+	// storage quotas. see docs/quota.org.
+	Quota GitusQuotaConfig `json:"quota"`
+
+	// logging. see docs/logging.org.
+	Log GitusLogConfig `json:"log"`
 
 	// namespaces you need gitus to ignore during initial searching.
 	// only valid when browse-only mode is enabled. (when browse-only
@@ -173,6 +199,10 @@ type GitusConfig struct {
 	// max session lifetime (seconds)
 	MaxSessionLifetime int `json:"maxSessionLifetime"`
 
+	// openid connect identity providers for single sign-on. see
+	// docs/oidc.org.
+	OIDCProvider []GitusOIDCProviderConfig `json:"oidcProvider"`
+
 	// ====================================================================
 	// configs below this line are advanced configs.
 	// these configs are not meant to be exposed to the UI unless the admins
@@ -183,6 +213,10 @@ type GitusConfig struct {
 
 	// password hashing strength
 	PasswordHashStrength int `json:"passwordHashStrength"`
+
+	// the restart-requiring settings read from the config file that
+	// aren't in effect yet; see AssignReloadable.
+	pendingRestart *pendingRestartSetting
 }
 
 const (
@@ -219,6 +253,86 @@ type GitusDatabaseConfig struct {
 	TablePrefix string `json:"tablePrefix"`
 }
 
+type GitusLogConfig struct {
+	// "debug", "info" (default), "warn" or "error".
+	Level string `json:"level"`
+	// "text" (default) or "json".
+	Format string `json:"format"`
+	// "stderr" (default), "stdout", "file", "syslog" or "journald".
+	// the commands run by sshd & git hooks can only log to the
+	// last three.
+	Output string `json:"output"`
+	// the following three are only used when `output` is "file".
+	// relative paths are resolved against the dir of the config file.
+	FilePath string `json:"filePath"`
+	properFilePath string
+	// 0 means no rotation.
+	MaxSizeMB int `json:"maxSizeMB"`
+	// the number of rotated files to keep.
+	MaxBackup int `json:"maxBackup"`
+	// the following two are only used when `output` is "syslog". when
+	// `syslogAddress` is empty the local syslog daemon is used.
+	SyslogNetwork string `json:"syslogNetwork"`
+	SyslogAddress string `json:"syslogAddress"`
+}
+
+type GitusMetricsConfig struct {
+	// only works in forge mode. see docs/metrics.org.
+	Enable bool `json:"enable"`
+	// when `bindPort` is 0 the metrics is served at `/metrics` on
+	// the main http server; or else it's served on a separate one
+	// at `bindAddress:bindPort`.
+	BindAddress string `json:"bindAddress"`
+	BindPort int `json:"bindPort"`
+	// if not empty, requests must send `Authorization: Bearer {token}`.
+	BearerToken string `json:"bearerToken"`
+}
+
+type GitusMaintenanceConfig struct {
+	// only works in forge mode. the "run now" action on the admin
+	// panel works even when this is false.
+	Enable bool `json:"enable"`
+	// how often (in hours) each task is run on each repository. 0
+	// disables the task.
+	RepackInterval int `json:"repackInterval"`
+	PruneInterval int `json:"pruneInterval"`
+	CommitGraphInterval int `json:"commitGraphInterval"`
+	FsckInterval int `json:"fsckInterval"`
+	// only applies to the forks that have auto-sync turned on. see
+	// docs/fork-sync.org.
+	ForkSyncInterval int `json:"forkSyncInterval"`
+	// the max number of repositories handled every minute. 0 means
+	// the default (5).
+	RepositoryPerMinute int `json:"repositoryPerMinute"`
+	// the number of runs kept in the history of each repository. 0
+	// means the default (50).
+	HistorySize int `json:"historySize"`
+}
+
+type GitusQuotaConfig struct {
+	// only works in forge mode.
+	Enable bool `json:"enable"`
+	// the limits of the whole site.
+	Site model.Quota `json:"site"`
+	// the defaults for each user & each namespace; they can be
+	// overridden for a specific user or namespace on the admin panel.
+	User model.Quota `json:"user"`
+	Namespace model.Quota `json:"namespace"`
+}
+
+type GitusSSHServerConfig struct {
+	// only works in forge mode.
+	Enable bool `json:"enable"`
+	BindAddress string `json:"bindAddress"`
+	BindPort int `json:"bindPort"`
+	// paths to the host private keys (in OpenSSH format). relative
+	// paths are resolved against the dir of the config file. keys
+	// that don't exist are generated (ed25519) when the server
+	// starts. only one key of each algorithm is used.
+	HostKey []string `json:"hostKey"`
+	properHostKey []string
+}
+
 type GitusGitHTTPTransferProtocolDescriptor struct {
 	// true if enabled.
 	V1Dumb bool `json:"v1dumb"`
@@ -226,6 +340,19 @@ type GitusGitHTTPTransferProtocolDescriptor struct {
 }
 type GitusGitConfig struct {
 	HTTPCloneProtocol GitusGitHTTPTransferProtocolDescriptor `json:"httpCloneProtocol"`
+	// git lfs. see docs/lfs.org.
+	LFS GitusLFSConfig `json:"lfs"`
+}
+
+type GitusLFSConfig struct {
+	// only works in forge mode. `jwtSecret` must be set as well.
+	Enable bool `json:"enable"`
+	// the directory the objects are stored in. relative paths are
+	// resolved against the dir of the config file.
+	Root string `json:"root"`
+	properRoot string
+	// the max size of a single object in bytes; 0 means no limit.
+	MaxObjectSize int64 `json:"maxObjectSize"`
 }
 
 type GitusSessionConfig struct {
@@ -330,6 +457,57 @@ type GitusFrontPageConfig struct {
 	FileContent string `json:"fileContent"`
 }
 
+type GitusOIDCProviderConfig struct {
+	// used in the urls, i.e. "/login/oidc/{id}". must consist of
+	// letters, digits, underscore and hyphen. the callback url to be
+	// registered with the provider is "{hostName}/login/oidc/{id}/callback".
+	Id string `json:"id"`
+	// shown on the login page as "Sign in with {displayName}".
+	DisplayName string `json:"displayName"`
+	// the issuer url. the provider's metadata is retrieved from
+	// "{issuer}/.well-known/openid-configuration".
+	Issuer string `json:"issuer"`
+	ClientId string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+	// the scopes to request besides "openid". "email" and "profile"
+	// are needed for linking & provisioning; add e.g. "groups" if
+	// your provider requires it for the group claim.
+	Scope []string `json:"scope"`
+	// when set to true, a user logging in for the first time would
+	// be linked to the existing user that has the same email address
+	// verified, given that the provider says the email is verified.
+	LinkByEmail bool `json:"linkByEmail"`
+	// when set to true, a new user is created when the user can't be
+	// linked to an existing one. the username is taken from the
+	// "preferred_username" claim. the new user follows
+	// `defaultNewUserStatus` & `defaultNewUserNamespace`.
+	AutoProvision bool `json:"autoProvision"`
+	// the name of the claim that contains the list of groups the
+	// user belongs to, e.g. "groups". group mapping is disabled when
+	// it's empty.
+	GroupClaim string `json:"groupClaim"`
+	GroupMapping []GitusOIDCGroupMapping `json:"groupMapping"`
+	// when set to true, users who are no longer in a group are
+	// removed from the mapped namespace when they log in. the owner
+	// of the namespace is never removed.
+	SyncGroupMembership bool `json:"syncGroupMembership"`
+}
+
+// members of the group are added to the namespace with the specified
+// privileges when they log in thru the provider.
+type GitusOIDCGroupMapping struct {
+	Group string `json:"group"`
+	Namespace string `json:"namespace"`
+	Privilege model.ACLTuple `json:"privilege"`
+}
+
+func (cfg *GitusConfig) GetOIDCProvider(id string) *GitusOIDCProviderConfig {
+	for i := range cfg.OIDCProvider {
+		if cfg.OIDCProvider[i].Id == id { return &cfg.OIDCProvider[i] }
+	}
+	return nil
+}
+
 type GitusThemeConfig struct {
 	ForegroundColor string `json:"foregroundColor"`
 	BackgroundColor string `json:"backgroundColor"`
@@ -357,6 +535,33 @@ func (cfg *GitusConfig) ProperReceiptSystemPath() string {
 	return cfg.ReceiptSystem.properPath
 }
 
+func (cfg *GitusConfig) ProperSSHServerHostKeyPath() []string {
+	return cfg.SSHServer.properHostKey
+}
+
+func (cfg *GitusConfig) ProperLFSRoot() string {
+	return cfg.GitConfig.LFS.properRoot
+}
+
+func (cfg *GitusConfig) ProperLogFilePath() string {
+	return cfg.Log.properFilePath
+}
+
+func (cfg *GitusConfig) ProperShutdownTimeout() time.Duration {
+	if cfg.ShutdownTimeout <= 0 { return 30 * time.Second }
+	return time.Duration(cfg.ShutdownTimeout) * time.Second
+}
+
+func (cfg *GitusConfig) ProperMaintenanceRepositoryPerMinute() int {
+	if cfg.Maintenance.RepositoryPerMinute <= 0 { return 5 }
+	return cfg.Maintenance.RepositoryPerMinute
+}
+
+func (cfg *GitusConfig) ProperMaintenanceHistorySize() int {
+	if cfg.Maintenance.HistorySize <= 0 { return 50 }
+	return cfg.Maintenance.HistorySize
+}
+
 func (cfg *GitusConfig) GitSSHHostName() string {
 	return cfg.gitSshHostName
 }
@@ -415,6 +620,43 @@ func CreateConfigFile(p string) error {
 		StaticAssetDirectory: "static/",
 		BindAddress: "127.0.0.1",
 		BindPort: 8000,
+		ShutdownTimeout: 30,
+		WatchConfigFile: false,
+		SSHServer: GitusSSHServerConfig{
+			Enable: false,
+			BindAddress: "0.0.0.0",
+			BindPort: 2222,
+			HostKey: []string{"ssh_host_ed25519_key"},
+		},
+		Metrics: GitusMetricsConfig{
+			Enable: false,
+			BindAddress: "127.0.0.1",
+			BindPort: 0,
+			BearerToken: "",
+		},
+		Maintenance: GitusMaintenanceConfig{
+			Enable: false,
+			RepackInterval: 24,
+			PruneInterval: 168,
+			CommitGraphInterval: 24,
+			FsckInterval: 720,
+			ForkSyncInterval: 6,
+			RepositoryPerMinute: 5,
+			HistorySize: 50,
+		},
+		Quota: GitusQuotaConfig{
+			Enable: false,
+		},
+		Log: GitusLogConfig{
+			Level: "info",
+			Format: "text",
+			Output: "stderr",
+			FilePath: "gitus.log",
+			MaxSizeMB: 100,
+			MaxBackup: 5,
+			SyslogNetwork: "",
+			SyslogAddress: "",
+		},
 		IgnoreNamespace: nil,
 		IgnoreRepository: nil,
 		GlobalVisibility: "public",
@@ -424,6 +666,11 @@ func CreateConfigFile(p string) error {
 				V1Dumb: true,
 				V2: true,
 			},
+			LFS: GitusLFSConfig{
+				Enable: false,
+				Root: "lfs",
+				MaxObjectSize: 0,
+			},
 		},
 		Database: GitusDatabaseConfig{
 			Type: "sqlite",
@@ -478,6 +725,7 @@ func CreateConfigFile(p string) error {
 			BackgroundColor: "white",
 		},
 		MaxSessionLifetime: 7 * 24 * 60 * 60,
+		OIDCProvider: []GitusOIDCProviderConfig{},
 		NoInteractiveShellMessage: "Direct shell access is forbidden on this host.",
 		JWTSecret: "",
 		PasswordHashStrength: 16,
@@ -564,6 +812,32 @@ func (c *GitusConfig) RecalculateProperPath() error {
 		}
 		c.ReceiptSystem.properPath = rsp
 	}
+
+	if len(c.Log.FilePath) > 0 {
+		if path.IsAbs(c.Log.FilePath) {
+			c.Log.properFilePath = c.Log.FilePath
+		} else {
+			c.Log.properFilePath = path.Join(configDir, c.Log.FilePath)
+		}
+	}
+
+	c.GitConfig.LFS.properRoot = ""
+	if len(c.GitConfig.LFS.Root) > 0 {
+		if path.IsAbs(c.GitConfig.LFS.Root) {
+			c.GitConfig.LFS.properRoot = c.GitConfig.LFS.Root
+		} else {
+			c.GitConfig.LFS.properRoot = path.Join(configDir, c.GitConfig.LFS.Root)
+		}
+	}
+
+	c.SSHServer.properHostKey = make([]string, 0, len(c.SSHServer.HostKey))
+	for _, k := range c.SSHServer.HostKey {
+		if path.IsAbs(k) {
+			c.SSHServer.properHostKey = append(c.SSHServer.properHostKey, k)
+		} else {
+			c.SSHServer.properHostKey = append(c.SSHServer.properHostKey, path.Join(configDir, k))
+		}
+	}
 	
 	return nil
 }
@@ -582,7 +856,7 @@ func LoadConfigFile(p string) (*GitusConfig, error) {
 
 func (cfg *GitusConfig) Sync() error {
 	p := cfg.FilePath
-	s, err := json.MarshalIndent(cfg, "", "    ")
+	s, err := json.MarshalIndent(cfg.Saved(), "", "    ")
 	if err != nil { return err }
 	st, err := os.Stat(p)
 	if err != nil && !os.IsNotExist(err) { return err }
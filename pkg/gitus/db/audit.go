@@ -0,0 +1,43 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+)
+
+// builds the WHERE clause (without the keyword; "1 = 1" when there's
+// no condition) of an audit log query. `placeholder` returns the
+// placeholder of the i-th (starting from 1) argument, since the
+// backends use different ones.
+func BuildAuditLogCondition(f *model.AuditLogFilter, placeholder func(i int) string) (string, []any) {
+	cond := make([]string, 0)
+	args := make([]any, 0)
+	// each %s in `s` is replaced with the placeholder of the
+	// corresponding value.
+	add := func(s string, v ...any) {
+		ph := make([]any, 0, len(v))
+		for _, k := range v {
+			args = append(args, k)
+			ph = append(ph, placeholder(len(args)))
+		}
+		cond = append(cond, fmt.Sprintf(s, ph...))
+	}
+	if f != nil {
+		if len(f.Actor) > 0 { add("audit_actor = %s", f.Actor) }
+		if len(f.Action) > 0 { add("(audit_action = %s OR audit_action LIKE %s ESCAPE '\\')", f.Action, escapeLikePattern(f.Action) + ".%") }
+		if len(f.Target) > 0 { add("audit_target LIKE %s ESCAPE '\\'", "%" + escapeLikePattern(f.Target) + "%") }
+		if len(f.IP) > 0 { add("audit_ip = %s", f.IP) }
+		if f.Since > 0 { add("audit_timestamp >= %s", f.Since) }
+		if f.Until > 0 { add("audit_timestamp <= %s", f.Until) }
+	}
+	if len(cond) <= 0 { return "1 = 1", args }
+	return strings.Join(cond, " AND "), args
+}
+
+func escapeLikePattern(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, "%", "\\%")
+	return strings.ReplaceAll(s, "_", "\\_")
+}
@@ -10,3 +10,6 @@ var ErrDatabaseNotSupported = errors.New("DATABASE_NOT_SUPPORTED: The version of
 var ErrNotEnoughPermission = errors.New("NOT_ENOUGH_PERMISSION: Not enough permission.")
 var ErrInvalidLocation = errors.New("INVALID_LOCATION: The resulting on-disk location is invalid.")
 
+var ErrDatabaseNotInstalled = errors.New("DATABASE_NOT_INSTALLED: The database tables are not installed.")
+var ErrSchemaTooOld = errors.New("SCHEMA_TOO_OLD: The database schema is older than what this version of Gitus requires.")
+var ErrSchemaTooNew = errors.New("SCHEMA_TOO_NEW: The database schema is newer than what this version of Gitus supports.")
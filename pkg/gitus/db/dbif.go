@@ -1,6 +1,8 @@
 package db
 
 import (
+	"context"
+
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	"github.com/GitusCodeForge/Gitus/pkg/gitlib"
 )
@@ -9,7 +11,21 @@ type GitusDatabaseInterface interface {
 	// we have to discern between "database unusable" and "error while detecting".
 	IsDatabaseUsable() (bool, error)
 	InstallTables() error
+	// returns 0 if the tables aren't installed. installations made
+	// before schema versioning was introduced are at version 1.
+	GetSchemaVersion() (int, error)
+	GetMigrationList() []*Migration
+	// applies all the pending migrations in order, each within its
+	// own transaction; returns the ones that are applied.
+	Migrate() ([]*Migration, error)
 	Dispose() error
+
+	// backend-neutral dump of the whole database, used by `gitus
+	// backup` & `gitus restore`. see dump.go.
+	ExportDump(w *DumpWriter) error
+	// imports a dump into a freshly installed database within one
+	// transaction.
+	ImportDump(r *DumpReader) error
 	
 	GetUserByName(name string) (*model.GitusUser, error)
 	GetAllAuthKeyByUsername(name string) ([]model.GitusAuthKey, error)
@@ -17,6 +33,28 @@ type GitusDatabaseInterface interface {
 	RegisterAuthKey(username string, keyname string, keytext string) error
 	UpdateAuthKey(username string, keyname string, keytext string) error
 	RemoveAuthKey(username string, keyname string) error
+
+	GetAllWebAuthnCredentialByUsername(username string) ([]*model.WebAuthnCredential, error)
+	// `credentialId` is base64url-encoded; credential ids are unique
+	// across all users.
+	GetWebAuthnCredential(credentialId string) (*model.WebAuthnCredential, error)
+	RegisterWebAuthnCredential(cred *model.WebAuthnCredential) error
+	UpdateWebAuthnCredentialUsage(credentialId string, signCount int64, lastUsedTime int64) error
+	RemoveWebAuthnCredential(username string, credentialId string) error
+
+	// should return db.ErrEntityNotFound when the account is not linked.
+	GetOIDCLink(providerId string, subject string) (*model.OIDCLink, error)
+	GetAllOIDCLinkOfUser(username string) ([]*model.OIDCLink, error)
+	RegisterOIDCLink(link *model.OIDCLink) error
+	RemoveOIDCLink(username string, providerId string) error
+
+	GetAllAccessTokenByUsername(username string) ([]*model.AccessToken, error)
+	// should return db.ErrEntityNotFound when there's no such token.
+	GetAccessTokenByHash(tokenHash string) (*model.AccessToken, error)
+	RegisterAccessToken(token *model.AccessToken) error
+	UpdateAccessTokenUsage(tokenHash string, lastUsedTime int64) error
+	RemoveAccessToken(username string, tokenName string) error
+
 	GetAllSignKeyByUsername(name string) ([]model.GitusSigningKey, error)
 	GetSignKeyByName(userName string, keyName string) (*model.GitusSigningKey, error)
 	UpdateSignKey(username string, keyname string, keytext string) error
@@ -49,9 +87,10 @@ type GitusDatabaseInterface interface {
 	UpdateNamespaceStatus(name string, newStatus model.GitusNamespaceStatus) error
 	// the implementer should remove the directory as well.
 	HardDeleteNamespaceByName(name string) error
-	// the implementer should create the git directory as well. common
-	// method is to run "git init".
-	CreateRepository(ns string, name string, repoType uint8, owner string) (*model.Repository, error)
+	// the implementer should create the git directory as well
+	// (`model.InitLocalRepository`). `objectFormat` is only meaningful
+	// for git repositories; empty means git's default.
+	CreateRepository(ns string, name string, repoType uint8, objectFormat gitlib.ObjectFormat, owner string) (*model.Repository, error)
 	// same as `CreateRepository` but with related fields being set.
 	SetUpCloneRepository(originNs string, originName string, targetNs string, targetName string, owner string) (*model.Repository, error)
 	UpdateRepositoryInfo(ns string, name string, robj *model.Repository) error
@@ -61,6 +100,46 @@ type GitusDatabaseInterface interface {
 	// we'll reconsider this when the appropriate time comes.
 	// MoveRepository(oldNs string, oldName string, newNs string, newName string) error
 
+	// the git lfs objects of the repositories; the content is stored
+	// on disk. HardDeleteRepository & HardDeleteNamespaceByName should
+	// remove the entries as well. see docs/lfs.org.
+	// should return db.ErrEntityNotFound when there's no such object.
+	GetLFSObject(ns string, name string, oid string) (*model.LFSObject, error)
+	// registering an object that's already registered does nothing.
+	RegisterLFSObject(obj *model.LFSObject) error
+	GetLFSStorageUsage(ns string, name string) (*model.LFSStorageUsage, error)
+
+	// the history of the maintenance runs of the repositories. see
+	// docs/repo-maintenance.org. HardDeleteRepository &
+	// HardDeleteNamespaceByName should remove the entries as well.
+	// only the latest `keep` runs of each repository are kept.
+	RecordMaintenanceRun(run *model.MaintenanceRun, keep int) error
+	// the latest ones first.
+	GetMaintenanceRunList(ns string, name string, pageNum int64, pageSize int64) ([]*model.MaintenanceRun, error)
+	// the latest run of each task on each repository, w/o the output.
+	GetLatestMaintenanceRunList() ([]*model.MaintenanceRun, error)
+
+	// the per-user & per-namespace quota overrides. see docs/quota.org.
+	// subjectType is one of model.QUOTA_SUBJECT_USER &
+	// model.QUOTA_SUBJECT_NAMESPACE. HardDeleteUserByName &
+	// HardDeleteNamespaceByName should remove the entries as well.
+	// should return db.ErrEntityNotFound when there's no override.
+	GetQuota(subjectType string, name string) (*model.Quota, error)
+	// replaces the existing override if there is one.
+	SetQuota(subjectType string, name string, quota *model.Quota) error
+	RemoveQuota(subjectType string, name string) error
+	// the last measured size of the repositories on disk.
+	// HardDeleteRepository & HardDeleteNamespaceByName should remove
+	// the entries as well.
+	UpdateRepositorySize(ns string, name string, size int64) error
+	// returns 0 when the repository hasn't been measured yet.
+	GetRepositorySize(ns string, name string) (int64, error)
+	// the usage of the repositories owned by a user, the repositories
+	// under a namespace, or all repositories when subjectType is
+	// model.QUOTA_SUBJECT_SITE. SnippetSize is not filled since
+	// snippets are not in the database.
+	GetQuotaUsage(subjectType string, name string) (*model.QuotaUsage, error)
+
 	GetAllUsers(pageNum int64, pageSize int64) ([]*model.GitusUser, error)
 	GetAllNamespaces(pageNum int64, pageSize int64) (map[string]*model.Namespace, error)
 	GetAllRepositories(pageNum int64, pageSize int64) ([]*model.Repository, error)
@@ -88,6 +167,8 @@ type GitusDatabaseInterface interface {
 	// `targetUserName` when `acl` is nil,
 	SetNamespaceACL(nsName string, targetUserName string, acl *model.ACLTuple) error
 	SetRepositoryACL(nsName string, repoName string, targetUserName string, acl *model.ACLTuple) error
+	// set the .Require2FA field of the namespace's ACL.
+	SetNamespaceRequire2FA(nsName string, require bool) error
 
 	GetAllComprisingNamespace(username string) (map[string]*model.Namespace, error)
 	
@@ -119,17 +200,40 @@ type GitusDatabaseInterface interface {
 	// isn't any fork repo of the specified repo; the caller should
 	// check for both.
 	GetForkRepositoryOfUser(username string, originNamespace string, originName string) ([]*model.Repository, error)
+	// all the forks of the specified repo regardless of their
+	// visibility; the caller should filter them.
+	GetForkRepositoryList(originNamespace string, originName string) ([]*model.Repository, error)
+	// whether the fork is synced w/ its upstream automatically. see
+	// docs/fork-sync.org. HardDeleteRepository &
+	// HardDeleteNamespaceByName should remove the entries as well.
+	GetForkAutoSync(ns string, name string) (bool, error)
+	SetForkAutoSync(ns string, name string, enable bool) error
 
 	GetAllPullRequestPaginated(namespace string, name string, pageNum int64, pageSize int64) ([]*model.PullRequest, error)
 	NewPullRequest(username string, title string, receiverNamespace string, receiverName string, receiverBranch string, providerNamespace string, providerName string, providerBranch string) (int64, error)
 	GetPullRequest(namespace string, name string, id int64) (*model.PullRequest, error)
 	GetPullRequestByAbsId(absId int64) (*model.PullRequest, error)
-	CheckPullRequestMergeConflict(absId int64) (*gitlib.MergeCheckResult, error)
+	// the git commands run for the check & the merge are logged w/
+	// `ctx` & carry its request id.
+	CheckPullRequestMergeConflict(ctx context.Context, absId int64) (*gitlib.MergeCheckResult, error)
 	DeletePullRequest(absId int64) error
 	GetAllPullRequestEventPaginated(absId int64, pageNum int64, pageSize int64) ([]*model.PullRequestEvent, error)
-	CheckAndMergePullRequest(absId int64, username string) error
+	CheckAndMergePullRequest(ctx context.Context, absId int64, username string) error
 	CommentOnPullRequest(absId int64, author string, content string) (*model.PullRequestEvent, error)
 	CommentOnPullRequestCode(absId int64, comment *model.PullRequestCommentOnCode) (*model.PullRequestEvent, error)
+	// records a PULL_REQUEST_EVENT_UPDATE_ON_BRANCH event, e.g. when the
+	// conflicts are resolved on the web or the provider branch is
+	// pushed to. `commitId` becomes the provider head as well.
+	RecordPullRequestBranchUpdate(absId int64, author string, commitId string) (*model.PullRequestEvent, error)
+	// the commit the provider branch pointed to when it was last seen;
+	// "" when it's never recorded (e.g. the pull requests created
+	// before it was tracked). see docs/pull-request.org.
+	GetPullRequestProviderHead(absId int64) (string, error)
+	SetPullRequestProviderHead(absId int64, commitId string) error
+	// the open pull requests whose provider is the specified
+	// repository, e.g. the ones a push to it could update. the merge
+	// check result is not filled.
+	GetOpenPullRequestOfProvider(providerNamespace string, providerName string) ([]*model.PullRequest, error)
 	ClosePullRequestAsNotMerged(absid int64, author string) error
 	ReopenPullRequest(absid int64, author string) error
 	// filterType: 0 - all, 1 - open, 2 - closed, 3 - merged, 4 - discarded
@@ -178,6 +282,16 @@ type GitusDatabaseInterface interface {
 	RegisterWebhookRequest(uuid string, reportUuid string, repoNs string, repoName string, commitId string) error
 	UpdateWebhookResult(uuid string, result *model.WebhookResult) error
 	GetWebhookResultByUUID(uuid string) (*model.WebhookResult, error)
+	// returns the number of webhook log entries of each status
+	// (model.WEBHOOK_RESULT_*).
+	CountWebhookResultByStatus() (map[uint8]int64, error)
+
+	// the audit log is append-only; there's no way to change or
+	// remove an entry. see docs/audit-log.org.
+	AppendAuditLog(entry *model.AuditLogEntry) error
+	CountAuditLog(filter *model.AuditLogFilter) (int64, error)
+	// newest first.
+	SearchAuditLogPaginated(filter *model.AuditLogFilter, pageNum int64, pageSize int64) ([]*model.AuditLogEntry, error)
 }
 
 
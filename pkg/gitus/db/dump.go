@@ -0,0 +1,415 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// the backend-neutral database dump used by `gitus backup` & `gitus
+// restore`. the dump is a sequence of json objects (one per line),
+// each holding one row of one table:
+//
+//     {"table":"user","row":{"user_name":"admin",...}}
+//
+// the tables & columns listed here are the "neutral" ones; each
+// backend maps them to its own tables (e.g. sqlite uses `rowid` for
+// the identity columns). see docs/backup.org.
+
+const DUMP_FORMAT_VERSION = 1
+
+type DumpColumnType int
+
+const (
+	DUMP_TEXT DumpColumnType = 1
+	DUMP_INTEGER DumpColumnType = 2
+	DUMP_BOOLEAN DumpColumnType = 3
+	// unix timestamp (in seconds).
+	DUMP_TIME DumpColumnType = 4
+	// json text.
+	DUMP_JSON DumpColumnType = 5
+	// binary data; base64-encoded in the dump.
+	DUMP_BLOB DumpColumnType = 6
+)
+
+type DumpColumn struct {
+	Name string
+	Type DumpColumnType
+	// identity columns are referred to by other tables and must be
+	// preserved when importing.
+	Identity bool
+}
+
+type DumpTable struct {
+	Name string
+	Column []*DumpColumn
+}
+
+func (t *DumpTable) GetColumn(name string) *DumpColumn {
+	for _, k := range t.Column {
+		if k.Name == name { return k }
+	}
+	return nil
+}
+
+func (t *DumpTable) IdentityColumn() *DumpColumn {
+	for _, k := range t.Column {
+		if k.Identity { return k }
+	}
+	return nil
+}
+
+func dumpText(name string) *DumpColumn { return &DumpColumn{ Name: name, Type: DUMP_TEXT } }
+func dumpInteger(name string) *DumpColumn { return &DumpColumn{ Name: name, Type: DUMP_INTEGER } }
+func dumpTime(name string) *DumpColumn { return &DumpColumn{ Name: name, Type: DUMP_TIME } }
+func dumpJSON(name string) *DumpColumn { return &DumpColumn{ Name: name, Type: DUMP_JSON } }
+func dumpIdentity(name string) *DumpColumn { return &DumpColumn{ Name: name, Type: DUMP_INTEGER, Identity: true } }
+
+// in the order of dependency, i.e. a table only refers to the ones
+// before it, so that the rows can be inserted in the order they
+// appear in the dump.
+var DumpTableList = []*DumpTable{
+	&DumpTable{ Name: "user", Column: []*DumpColumn{
+		dumpText("user_name"),
+		dumpText("user_title"),
+		dumpText("user_email"),
+		dumpText("user_bio"),
+		dumpText("user_website"),
+		dumpTime("user_reg_datetime"),
+		dumpText("user_password_hash"),
+		dumpInteger("user_status"),
+		dumpJSON("user_2fa_config"),
+		dumpJSON("user_website_preference"),
+	}},
+	&DumpTable{ Name: "user_authkey", Column: []*DumpColumn{
+		dumpText("user_name"),
+		dumpText("key_name"),
+		dumpText("key_text"),
+	}},
+	&DumpTable{ Name: "user_signkey", Column: []*DumpColumn{
+		dumpText("user_name"),
+		dumpText("key_name"),
+		dumpText("key_text"),
+	}},
+	&DumpTable{ Name: "user_webauthn", Column: []*DumpColumn{
+		dumpText("user_name"),
+		dumpText("credential_id"),
+		dumpText("credential_name"),
+		&DumpColumn{ Name: "public_key", Type: DUMP_BLOB },
+		dumpInteger("sign_count"),
+		&DumpColumn{ Name: "passkey", Type: DUMP_BOOLEAN },
+		dumpInteger("reg_timestamp"),
+		dumpInteger("last_used_timestamp"),
+	}},
+	&DumpTable{ Name: "user_oidc", Column: []*DumpColumn{
+		dumpText("provider_id"),
+		dumpText("subject"),
+		dumpText("user_name"),
+		dumpInteger("link_timestamp"),
+	}},
+	&DumpTable{ Name: "user_access_token", Column: []*DumpColumn{
+		dumpText("user_name"),
+		dumpText("token_name"),
+		dumpText("token_hash"),
+		dumpInteger("reg_timestamp"),
+		dumpInteger("last_used_timestamp"),
+		dumpInteger("expire_timestamp"),
+	}},
+	&DumpTable{ Name: "user_email", Column: []*DumpColumn{
+		dumpText("username"),
+		dumpText("email"),
+		dumpInteger("verified"),
+	}},
+	&DumpTable{ Name: "user_reg_request", Column: []*DumpColumn{
+		dumpIdentity("request_absid"),
+		dumpText("username"),
+		dumpText("email"),
+		dumpText("password_hash"),
+		dumpText("reason"),
+		dumpTime("timestamp"),
+	}},
+	&DumpTable{ Name: "namespace", Column: []*DumpColumn{
+		dumpText("ns_name"),
+		dumpText("ns_title"),
+		dumpText("ns_description"),
+		dumpText("ns_email"),
+		dumpText("ns_owner"),
+		dumpTime("ns_reg_datetime"),
+		dumpJSON("ns_acl"),
+		dumpInteger("ns_status"),
+	}},
+	&DumpTable{ Name: "repository", Column: []*DumpColumn{
+		dumpInteger("repo_type"),
+		dumpText("repo_namespace"),
+		dumpText("repo_name"),
+		dumpText("repo_description"),
+		dumpText("repo_owner"),
+		dumpJSON("repo_acl"),
+		dumpInteger("repo_status"),
+		dumpText("repo_fork_origin_namespace"),
+		dumpText("repo_fork_origin_name"),
+		dumpText("repo_label_list"),
+		dumpJSON("repo_webhook"),
+	}},
+	&DumpTable{ Name: "lfs_object", Column: []*DumpColumn{
+		dumpText("repo_namespace"),
+		dumpText("repo_name"),
+		dumpText("oid"),
+		dumpInteger("size"),
+		dumpInteger("upload_timestamp"),
+	}},
+	&DumpTable{ Name: "repo_maintenance", Column: []*DumpColumn{
+		dumpText("repo_namespace"),
+		dumpText("repo_name"),
+		dumpText("run_task"),
+		dumpText("run_trigger"),
+		dumpInteger("run_start_timestamp"),
+		dumpInteger("run_duration"),
+		&DumpColumn{ Name: "run_success", Type: DUMP_BOOLEAN },
+		dumpText("run_output"),
+	}},
+	&DumpTable{ Name: "repo_size", Column: []*DumpColumn{
+		dumpText("repo_namespace"),
+		dumpText("repo_name"),
+		dumpInteger("repo_size"),
+		dumpInteger("update_timestamp"),
+	}},
+	&DumpTable{ Name: "quota", Column: []*DumpColumn{
+		dumpText("subject_type"),
+		dumpText("subject_name"),
+		dumpInteger("repository_size"),
+		dumpInteger("lfs_size"),
+		dumpInteger("snippet_size"),
+		dumpInteger("repository_count"),
+	}},
+	&DumpTable{ Name: "fork_sync", Column: []*DumpColumn{
+		dumpText("repo_namespace"),
+		dumpText("repo_name"),
+	}},
+	&DumpTable{ Name: "issue", Column: []*DumpColumn{
+		dumpIdentity("issue_absid"),
+		dumpText("repo_namespace"),
+		dumpText("repo_name"),
+		dumpInteger("issue_id"),
+		dumpTime("issue_timestamp"),
+		dumpText("issue_author"),
+		dumpText("issue_title"),
+		dumpText("issue_content"),
+		dumpInteger("issue_status"),
+		dumpInteger("issue_priority"),
+	}},
+	&DumpTable{ Name: "issue_event", Column: []*DumpColumn{
+		dumpIdentity("issue_event_absid"),
+		dumpInteger("issue_absid"),
+		dumpInteger("issue_event_type"),
+		dumpTime("issue_event_time"),
+		dumpText("issue_event_author"),
+		dumpText("issue_event_content"),
+	}},
+	&DumpTable{ Name: "pull_request", Column: []*DumpColumn{
+		dumpIdentity("pull_request_absid"),
+		dumpText("author_username"),
+		dumpInteger("pull_request_id"),
+		dumpText("title"),
+		dumpText("receiver_namespace"),
+		dumpText("receiver_name"),
+		dumpText("receiver_branch"),
+		dumpText("provider_namespace"),
+		dumpText("provider_name"),
+		dumpText("provider_branch"),
+		dumpText("merge_conflict_check_result"),
+		dumpTime("merge_conflict_check_timestamp"),
+		dumpInteger("pull_request_status"),
+		dumpTime("pull_request_timestamp"),
+	}},
+	&DumpTable{ Name: "pull_request_head", Column: []*DumpColumn{
+		dumpInteger("pull_request_absid"),
+		dumpText("head_commit_id"),
+	}},
+	&DumpTable{ Name: "pull_request_event", Column: []*DumpColumn{
+		dumpInteger("pull_request_absid"),
+		dumpInteger("event_type"),
+		dumpTime("event_timestamp"),
+		dumpText("event_author"),
+		dumpText("event_content"),
+	}},
+	&DumpTable{ Name: "snippet", Column: []*DumpColumn{
+		dumpText("name"),
+		dumpText("username"),
+		dumpText("description"),
+		dumpTime("timestamp"),
+		dumpInteger("status"),
+		dumpJSON("shared_user"),
+	}},
+	&DumpTable{ Name: "webhook_log", Column: []*DumpColumn{
+		dumpText("uuid"),
+		dumpText("repo_namespace"),
+		dumpText("repo_name"),
+		dumpText("commit_id"),
+		dumpJSON("webhook_result"),
+	}},
+	&DumpTable{ Name: "audit_log", Column: []*DumpColumn{
+		dumpIdentity("audit_absid"),
+		// stored as an integer (instead of TIMESTAMP) in postgres.
+		dumpInteger("audit_timestamp"),
+		dumpText("audit_actor"),
+		dumpText("audit_ip"),
+		dumpText("audit_action"),
+		dumpText("audit_target"),
+		dumpText("audit_diff"),
+	}},
+}
+
+func GetDumpTable(name string) *DumpTable {
+	for _, k := range DumpTableList {
+		if k.Name == name { return k }
+	}
+	return nil
+}
+
+var ErrInvalidDump = errors.New("INVALID_DUMP: The database dump is invalid.")
+
+type DumpRecord struct {
+	Table string `json:"table"`
+	Row map[string]any `json:"row"`
+}
+
+type DumpWriter struct {
+	enc *json.Encoder
+	// the number of rows written for each table.
+	Count map[string]int
+}
+
+func NewDumpWriter(w io.Writer) *DumpWriter {
+	return &DumpWriter{
+		enc: json.NewEncoder(w),
+		Count: make(map[string]int, 0),
+	}
+}
+
+func (w *DumpWriter) Write(table string, row map[string]any) error {
+	err := w.enc.Encode(&DumpRecord{ Table: table, Row: row })
+	if err != nil { return err }
+	w.Count[table] += 1
+	return nil
+}
+
+type DumpReader struct {
+	dec *json.Decoder
+}
+
+func NewDumpReader(r io.Reader) *DumpReader {
+	dec := json.NewDecoder(r)
+	// so that big integers are not turned into float64.
+	dec.UseNumber()
+	return &DumpReader{ dec: dec }
+}
+
+// returns io.EOF when there's no more record. the record is checked
+// against DumpTableList.
+func (r *DumpReader) Next() (*DumpRecord, error) {
+	res := new(DumpRecord)
+	err := r.dec.Decode(res)
+	if err != nil { return nil, err }
+	t := GetDumpTable(res.Table)
+	if t == nil { return nil, fmt.Errorf("%w Unknown table: %s", ErrInvalidDump, res.Table) }
+	for k := range res.Row {
+		if t.GetColumn(k) == nil {
+			return nil, fmt.Errorf("%w Unknown column %s in table %s", ErrInvalidDump, k, res.Table)
+		}
+	}
+	return res, nil
+}
+
+func parseDumpTime(s string) (int64, error) {
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err == nil { return i, nil }
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil { return 0, err }
+	return t.Unix(), nil
+}
+
+// converts a value scanned from the database to the neutral
+// representation. the backends are expected to convert their own
+// special representations (e.g. postgres TIMESTAMP) beforehand.
+func ToDumpValue(t DumpColumnType, v any) (any, error) {
+	if v == nil { return nil, nil }
+	switch t {
+	case DUMP_TEXT:
+		switch vv := v.(type) {
+		case string: return vv, nil
+		case []byte: return string(vv), nil
+		}
+		return fmt.Sprint(v), nil
+	case DUMP_INTEGER, DUMP_TIME:
+		switch vv := v.(type) {
+		case int64: return vv, nil
+		case int32: return int64(vv), nil
+		case int16: return int64(vv), nil
+		case int: return int64(vv), nil
+		case float64: return int64(vv), nil
+		case bool:
+			if vv { return int64(1), nil }
+			return int64(0), nil
+		case time.Time: return vv.Unix(), nil
+		case string:
+			if len(vv) <= 0 { return nil, nil }
+			if t == DUMP_TIME { return parseDumpTime(vv) }
+			return strconv.ParseInt(vv, 10, 64)
+		case []byte:
+			if len(vv) <= 0 { return nil, nil }
+			if t == DUMP_TIME { return parseDumpTime(string(vv)) }
+			return strconv.ParseInt(string(vv), 10, 64)
+		}
+	case DUMP_BOOLEAN:
+		switch vv := v.(type) {
+		case bool: return vv, nil
+		case int64: return vv != 0, nil
+		case int32: return vv != 0, nil
+		case int16: return vv != 0, nil
+		}
+	case DUMP_JSON:
+		switch vv := v.(type) {
+		case string: return vv, nil
+		case []byte: return string(vv), nil
+		}
+		// e.g. postgres JSONB, which is decoded by the driver.
+		b, err := json.Marshal(v)
+		if err != nil { return nil, err }
+		return string(b), nil
+	case DUMP_BLOB:
+		switch vv := v.(type) {
+		case []byte: return vv, nil
+		case string: return []byte(vv), nil
+		}
+	}
+	return nil, fmt.Errorf("Cannot convert %T to dump value", v)
+}
+
+// converts a value from the dump (as decoded by DumpReader) to a go
+// value: string, int64, bool or []byte. DUMP_TIME values are int64;
+// it's up to the backends to convert them.
+func FromDumpValue(t DumpColumnType, v any) (any, error) {
+	if v == nil { return nil, nil }
+	switch t {
+	case DUMP_TEXT, DUMP_JSON:
+		if s, ok := v.(string); ok { return s, nil }
+	case DUMP_INTEGER, DUMP_TIME:
+		switch vv := v.(type) {
+		case json.Number: return vv.Int64()
+		case int64: return vv, nil
+		}
+	case DUMP_BOOLEAN:
+		if b, ok := v.(bool); ok { return b, nil }
+	case DUMP_BLOB:
+		switch vv := v.(type) {
+		case string: return base64.StdEncoding.DecodeString(vv)
+		case []byte: return vv, nil
+		}
+	}
+	return nil, fmt.Errorf("%w Unexpected value of type %T", ErrInvalidDump, v)
+}
@@ -0,0 +1,967 @@
+// generated by devtools/generate-error-hook.go. DO NOT EDIT
+
+package db
+
+import (
+	"context"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	"github.com/GitusCodeForge/Gitus/pkg/gitlib"
+)
+
+// wraps a GitusDatabaseInterface & calls `Hook` with the name of the method
+// whenever a method returns a non-nil error.
+type ErrorHookedGitusDatabaseInterface struct {
+	GitusDatabaseInterface
+	Hook func(method string, err error)
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) IsDatabaseUsable() (bool, error) {
+	r0, r1 := h.GitusDatabaseInterface.IsDatabaseUsable()
+	if r1 != nil { h.Hook("IsDatabaseUsable", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) InstallTables() error {
+	r0 := h.GitusDatabaseInterface.InstallTables()
+	if r0 != nil { h.Hook("InstallTables", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetSchemaVersion() (int, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetSchemaVersion()
+	if r1 != nil { h.Hook("GetSchemaVersion", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) Migrate() ([]*Migration, error) {
+	r0, r1 := h.GitusDatabaseInterface.Migrate()
+	if r1 != nil { h.Hook("Migrate", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) Dispose() error {
+	r0 := h.GitusDatabaseInterface.Dispose()
+	if r0 != nil { h.Hook("Dispose", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) ExportDump(a0 *DumpWriter) error {
+	r0 := h.GitusDatabaseInterface.ExportDump(a0)
+	if r0 != nil { h.Hook("ExportDump", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) ImportDump(a0 *DumpReader) error {
+	r0 := h.GitusDatabaseInterface.ImportDump(a0)
+	if r0 != nil { h.Hook("ImportDump", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetUserByName(a0 string) (*model.GitusUser, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetUserByName(a0)
+	if r1 != nil { h.Hook("GetUserByName", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllAuthKeyByUsername(a0 string) ([]model.GitusAuthKey, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllAuthKeyByUsername(a0)
+	if r1 != nil { h.Hook("GetAllAuthKeyByUsername", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAuthKeyByName(a0 string, a1 string) (*model.GitusAuthKey, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAuthKeyByName(a0, a1)
+	if r1 != nil { h.Hook("GetAuthKeyByName", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) RegisterAuthKey(a0 string, a1 string, a2 string) error {
+	r0 := h.GitusDatabaseInterface.RegisterAuthKey(a0, a1, a2)
+	if r0 != nil { h.Hook("RegisterAuthKey", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) UpdateAuthKey(a0 string, a1 string, a2 string) error {
+	r0 := h.GitusDatabaseInterface.UpdateAuthKey(a0, a1, a2)
+	if r0 != nil { h.Hook("UpdateAuthKey", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) RemoveAuthKey(a0 string, a1 string) error {
+	r0 := h.GitusDatabaseInterface.RemoveAuthKey(a0, a1)
+	if r0 != nil { h.Hook("RemoveAuthKey", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllWebAuthnCredentialByUsername(a0 string) ([]*model.WebAuthnCredential, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllWebAuthnCredentialByUsername(a0)
+	if r1 != nil { h.Hook("GetAllWebAuthnCredentialByUsername", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetWebAuthnCredential(a0 string) (*model.WebAuthnCredential, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetWebAuthnCredential(a0)
+	if r1 != nil { h.Hook("GetWebAuthnCredential", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) RegisterWebAuthnCredential(a0 *model.WebAuthnCredential) error {
+	r0 := h.GitusDatabaseInterface.RegisterWebAuthnCredential(a0)
+	if r0 != nil { h.Hook("RegisterWebAuthnCredential", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) UpdateWebAuthnCredentialUsage(a0 string, a1 int64, a2 int64) error {
+	r0 := h.GitusDatabaseInterface.UpdateWebAuthnCredentialUsage(a0, a1, a2)
+	if r0 != nil { h.Hook("UpdateWebAuthnCredentialUsage", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) RemoveWebAuthnCredential(a0 string, a1 string) error {
+	r0 := h.GitusDatabaseInterface.RemoveWebAuthnCredential(a0, a1)
+	if r0 != nil { h.Hook("RemoveWebAuthnCredential", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetOIDCLink(a0 string, a1 string) (*model.OIDCLink, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetOIDCLink(a0, a1)
+	if r1 != nil { h.Hook("GetOIDCLink", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllOIDCLinkOfUser(a0 string) ([]*model.OIDCLink, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllOIDCLinkOfUser(a0)
+	if r1 != nil { h.Hook("GetAllOIDCLinkOfUser", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) RegisterOIDCLink(a0 *model.OIDCLink) error {
+	r0 := h.GitusDatabaseInterface.RegisterOIDCLink(a0)
+	if r0 != nil { h.Hook("RegisterOIDCLink", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) RemoveOIDCLink(a0 string, a1 string) error {
+	r0 := h.GitusDatabaseInterface.RemoveOIDCLink(a0, a1)
+	if r0 != nil { h.Hook("RemoveOIDCLink", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllAccessTokenByUsername(a0 string) ([]*model.AccessToken, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllAccessTokenByUsername(a0)
+	if r1 != nil { h.Hook("GetAllAccessTokenByUsername", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAccessTokenByHash(a0 string) (*model.AccessToken, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAccessTokenByHash(a0)
+	if r1 != nil { h.Hook("GetAccessTokenByHash", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) RegisterAccessToken(a0 *model.AccessToken) error {
+	r0 := h.GitusDatabaseInterface.RegisterAccessToken(a0)
+	if r0 != nil { h.Hook("RegisterAccessToken", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) UpdateAccessTokenUsage(a0 string, a1 int64) error {
+	r0 := h.GitusDatabaseInterface.UpdateAccessTokenUsage(a0, a1)
+	if r0 != nil { h.Hook("UpdateAccessTokenUsage", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) RemoveAccessToken(a0 string, a1 string) error {
+	r0 := h.GitusDatabaseInterface.RemoveAccessToken(a0, a1)
+	if r0 != nil { h.Hook("RemoveAccessToken", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllSignKeyByUsername(a0 string) ([]model.GitusSigningKey, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllSignKeyByUsername(a0)
+	if r1 != nil { h.Hook("GetAllSignKeyByUsername", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetSignKeyByName(a0 string, a1 string) (*model.GitusSigningKey, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetSignKeyByName(a0, a1)
+	if r1 != nil { h.Hook("GetSignKeyByName", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) UpdateSignKey(a0 string, a1 string, a2 string) error {
+	r0 := h.GitusDatabaseInterface.UpdateSignKey(a0, a1, a2)
+	if r0 != nil { h.Hook("UpdateSignKey", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) RegisterSignKey(a0 string, a1 string, a2 string) error {
+	r0 := h.GitusDatabaseInterface.RegisterSignKey(a0, a1, a2)
+	if r0 != nil { h.Hook("RegisterSignKey", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) RemoveSignKey(a0 string, a1 string) error {
+	r0 := h.GitusDatabaseInterface.RemoveSignKey(a0, a1)
+	if r0 != nil { h.Hook("RemoveSignKey", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetNamespaceByName(a0 string) (*model.Namespace, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetNamespaceByName(a0)
+	if r1 != nil { h.Hook("GetNamespaceByName", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetRepositoryByName(a0 string, a1 string) (*model.Repository, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetRepositoryByName(a0, a1)
+	if r1 != nil { h.Hook("GetRepositoryByName", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllNamespace() (map[string]*model.Namespace, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllNamespace()
+	if r1 != nil { h.Hook("GetAllNamespace", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllVisibleNamespace(a0 string) (map[string]*model.Namespace, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllVisibleNamespace(a0)
+	if r1 != nil { h.Hook("GetAllVisibleNamespace", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllVisibleNamespacePaginated(a0 string, a1 int64, a2 int64) (map[string]*model.Namespace, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllVisibleNamespacePaginated(a0, a1, a2)
+	if r1 != nil { h.Hook("GetAllVisibleNamespacePaginated", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) SearchAllVisibleNamespacePaginated(a0 string, a1 string, a2 int64, a3 int64) (map[string]*model.Namespace, error) {
+	r0, r1 := h.GitusDatabaseInterface.SearchAllVisibleNamespacePaginated(a0, a1, a2, a3)
+	if r1 != nil { h.Hook("SearchAllVisibleNamespacePaginated", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllVisibleRepositoryPaginated(a0 string, a1 int64, a2 int64) ([]*model.Repository, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllVisibleRepositoryPaginated(a0, a1, a2)
+	if r1 != nil { h.Hook("GetAllVisibleRepositoryPaginated", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) SearchAllVisibleRepositoryPaginated(a0 string, a1 string, a2 int64, a3 int64) ([]*model.Repository, error) {
+	r0, r1 := h.GitusDatabaseInterface.SearchAllVisibleRepositoryPaginated(a0, a1, a2, a3)
+	if r1 != nil { h.Hook("SearchAllVisibleRepositoryPaginated", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllNamespaceByOwner(a0 string) (map[string]*model.Namespace, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllNamespaceByOwner(a0)
+	if r1 != nil { h.Hook("GetAllNamespaceByOwner", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllRepositoryFromNamespace(a0 string) (map[string]*model.Repository, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllRepositoryFromNamespace(a0)
+	if r1 != nil { h.Hook("GetAllRepositoryFromNamespace", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllVisibleRepositoryFromNamespace(a0 string, a1 string) ([]*model.Repository, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllVisibleRepositoryFromNamespace(a0, a1)
+	if r1 != nil { h.Hook("GetAllVisibleRepositoryFromNamespace", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) RegisterUser(a0 string, a1 string, a2 string, a3 model.GitusUserStatus) (*model.GitusUser, error) {
+	r0, r1 := h.GitusDatabaseInterface.RegisterUser(a0, a1, a2, a3)
+	if r1 != nil { h.Hook("RegisterUser", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) UpdateUserInfo(a0 string, a1 *model.GitusUser) error {
+	r0 := h.GitusDatabaseInterface.UpdateUserInfo(a0, a1)
+	if r0 != nil { h.Hook("UpdateUserInfo", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) UpdateUserPassword(a0 string, a1 string) error {
+	r0 := h.GitusDatabaseInterface.UpdateUserPassword(a0, a1)
+	if r0 != nil { h.Hook("UpdateUserPassword", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) HardDeleteUserByName(a0 string) error {
+	r0 := h.GitusDatabaseInterface.HardDeleteUserByName(a0)
+	if r0 != nil { h.Hook("HardDeleteUserByName", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) UpdateUserStatus(a0 string, a1 model.GitusUserStatus) error {
+	r0 := h.GitusDatabaseInterface.UpdateUserStatus(a0, a1)
+	if r0 != nil { h.Hook("UpdateUserStatus", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) RegisterNamespace(a0 string, a1 string) (*model.Namespace, error) {
+	r0, r1 := h.GitusDatabaseInterface.RegisterNamespace(a0, a1)
+	if r1 != nil { h.Hook("RegisterNamespace", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) UpdateNamespaceInfo(a0 string, a1 *model.Namespace) error {
+	r0 := h.GitusDatabaseInterface.UpdateNamespaceInfo(a0, a1)
+	if r0 != nil { h.Hook("UpdateNamespaceInfo", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) UpdateNamespaceOwner(a0 string, a1 string) error {
+	r0 := h.GitusDatabaseInterface.UpdateNamespaceOwner(a0, a1)
+	if r0 != nil { h.Hook("UpdateNamespaceOwner", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) UpdateNamespaceStatus(a0 string, a1 model.GitusNamespaceStatus) error {
+	r0 := h.GitusDatabaseInterface.UpdateNamespaceStatus(a0, a1)
+	if r0 != nil { h.Hook("UpdateNamespaceStatus", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) HardDeleteNamespaceByName(a0 string) error {
+	r0 := h.GitusDatabaseInterface.HardDeleteNamespaceByName(a0)
+	if r0 != nil { h.Hook("HardDeleteNamespaceByName", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) CreateRepository(a0 string, a1 string, a2 uint8, a3 gitlib.ObjectFormat, a4 string) (*model.Repository, error) {
+	r0, r1 := h.GitusDatabaseInterface.CreateRepository(a0, a1, a2, a3, a4)
+	if r1 != nil { h.Hook("CreateRepository", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) SetUpCloneRepository(a0 string, a1 string, a2 string, a3 string, a4 string) (*model.Repository, error) {
+	r0, r1 := h.GitusDatabaseInterface.SetUpCloneRepository(a0, a1, a2, a3, a4)
+	if r1 != nil { h.Hook("SetUpCloneRepository", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) UpdateRepositoryInfo(a0 string, a1 string, a2 *model.Repository) error {
+	r0 := h.GitusDatabaseInterface.UpdateRepositoryInfo(a0, a1, a2)
+	if r0 != nil { h.Hook("UpdateRepositoryInfo", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) UpdateRepositoryStatus(a0 string, a1 string, a2 model.GitusRepositoryStatus) error {
+	r0 := h.GitusDatabaseInterface.UpdateRepositoryStatus(a0, a1, a2)
+	if r0 != nil { h.Hook("UpdateRepositoryStatus", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) HardDeleteRepository(a0 string, a1 string) error {
+	r0 := h.GitusDatabaseInterface.HardDeleteRepository(a0, a1)
+	if r0 != nil { h.Hook("HardDeleteRepository", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetLFSObject(a0 string, a1 string, a2 string) (*model.LFSObject, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetLFSObject(a0, a1, a2)
+	if r1 != nil { h.Hook("GetLFSObject", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) RegisterLFSObject(a0 *model.LFSObject) error {
+	r0 := h.GitusDatabaseInterface.RegisterLFSObject(a0)
+	if r0 != nil { h.Hook("RegisterLFSObject", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetLFSStorageUsage(a0 string, a1 string) (*model.LFSStorageUsage, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetLFSStorageUsage(a0, a1)
+	if r1 != nil { h.Hook("GetLFSStorageUsage", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) RecordMaintenanceRun(a0 *model.MaintenanceRun, a1 int) error {
+	r0 := h.GitusDatabaseInterface.RecordMaintenanceRun(a0, a1)
+	if r0 != nil { h.Hook("RecordMaintenanceRun", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetMaintenanceRunList(a0 string, a1 string, a2 int64, a3 int64) ([]*model.MaintenanceRun, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetMaintenanceRunList(a0, a1, a2, a3)
+	if r1 != nil { h.Hook("GetMaintenanceRunList", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetLatestMaintenanceRunList() ([]*model.MaintenanceRun, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetLatestMaintenanceRunList()
+	if r1 != nil { h.Hook("GetLatestMaintenanceRunList", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetQuota(a0 string, a1 string) (*model.Quota, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetQuota(a0, a1)
+	if r1 != nil { h.Hook("GetQuota", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) SetQuota(a0 string, a1 string, a2 *model.Quota) error {
+	r0 := h.GitusDatabaseInterface.SetQuota(a0, a1, a2)
+	if r0 != nil { h.Hook("SetQuota", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) RemoveQuota(a0 string, a1 string) error {
+	r0 := h.GitusDatabaseInterface.RemoveQuota(a0, a1)
+	if r0 != nil { h.Hook("RemoveQuota", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) UpdateRepositorySize(a0 string, a1 string, a2 int64) error {
+	r0 := h.GitusDatabaseInterface.UpdateRepositorySize(a0, a1, a2)
+	if r0 != nil { h.Hook("UpdateRepositorySize", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetRepositorySize(a0 string, a1 string) (int64, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetRepositorySize(a0, a1)
+	if r1 != nil { h.Hook("GetRepositorySize", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetQuotaUsage(a0 string, a1 string) (*model.QuotaUsage, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetQuotaUsage(a0, a1)
+	if r1 != nil { h.Hook("GetQuotaUsage", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllUsers(a0 int64, a1 int64) ([]*model.GitusUser, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllUsers(a0, a1)
+	if r1 != nil { h.Hook("GetAllUsers", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllNamespaces(a0 int64, a1 int64) (map[string]*model.Namespace, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllNamespaces(a0, a1)
+	if r1 != nil { h.Hook("GetAllNamespaces", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllRepositories(a0 int64, a1 int64) ([]*model.Repository, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllRepositories(a0, a1)
+	if r1 != nil { h.Hook("GetAllRepositories", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) CountAllUser() (int64, error) {
+	r0, r1 := h.GitusDatabaseInterface.CountAllUser()
+	if r1 != nil { h.Hook("CountAllUser", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) CountAllNamespace() (int64, error) {
+	r0, r1 := h.GitusDatabaseInterface.CountAllNamespace()
+	if r1 != nil { h.Hook("CountAllNamespace", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) CountAllRepositories() (int64, error) {
+	r0, r1 := h.GitusDatabaseInterface.CountAllRepositories()
+	if r1 != nil { h.Hook("CountAllRepositories", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) CountAllRepositoriesSearchResult(a0 string) (int64, error) {
+	r0, r1 := h.GitusDatabaseInterface.CountAllRepositoriesSearchResult(a0)
+	if r1 != nil { h.Hook("CountAllRepositoriesSearchResult", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) CountAllVisibleNamespace(a0 string) (int64, error) {
+	r0, r1 := h.GitusDatabaseInterface.CountAllVisibleNamespace(a0)
+	if r1 != nil { h.Hook("CountAllVisibleNamespace", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) CountAllVisibleRepositories(a0 string) (int64, error) {
+	r0, r1 := h.GitusDatabaseInterface.CountAllVisibleRepositories(a0)
+	if r1 != nil { h.Hook("CountAllVisibleRepositories", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) SearchForUser(a0 string, a1 int64, a2 int64) ([]*model.GitusUser, error) {
+	r0, r1 := h.GitusDatabaseInterface.SearchForUser(a0, a1, a2)
+	if r1 != nil { h.Hook("SearchForUser", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) SearchForNamespace(a0 string, a1 int64, a2 int64) (map[string]*model.Namespace, error) {
+	r0, r1 := h.GitusDatabaseInterface.SearchForNamespace(a0, a1, a2)
+	if r1 != nil { h.Hook("SearchForNamespace", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) SearchForRepository(a0 string, a1 int64, a2 int64) ([]*model.Repository, error) {
+	r0, r1 := h.GitusDatabaseInterface.SearchForRepository(a0, a1, a2)
+	if r1 != nil { h.Hook("SearchForRepository", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) SetNamespaceACL(a0 string, a1 string, a2 *model.ACLTuple) error {
+	r0 := h.GitusDatabaseInterface.SetNamespaceACL(a0, a1, a2)
+	if r0 != nil { h.Hook("SetNamespaceACL", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) SetRepositoryACL(a0 string, a1 string, a2 string, a3 *model.ACLTuple) error {
+	r0 := h.GitusDatabaseInterface.SetRepositoryACL(a0, a1, a2, a3)
+	if r0 != nil { h.Hook("SetRepositoryACL", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) SetNamespaceRequire2FA(a0 string, a1 bool) error {
+	r0 := h.GitusDatabaseInterface.SetNamespaceRequire2FA(a0, a1)
+	if r0 != nil { h.Hook("SetNamespaceRequire2FA", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllComprisingNamespace(a0 string) (map[string]*model.Namespace, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllComprisingNamespace(a0)
+	if r1 != nil { h.Hook("GetAllComprisingNamespace", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) CountAllVisibleNamespaceSearchResult(a0 string, a1 string) (int64, error) {
+	r0, r1 := h.GitusDatabaseInterface.CountAllVisibleNamespaceSearchResult(a0, a1)
+	if r1 != nil { h.Hook("CountAllVisibleNamespaceSearchResult", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) CountAllVisibleRepositoriesSearchResult(a0 string, a1 string) (int64, error) {
+	r0, r1 := h.GitusDatabaseInterface.CountAllVisibleRepositoriesSearchResult(a0, a1)
+	if r1 != nil { h.Hook("CountAllVisibleRepositoriesSearchResult", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllRepositoryIssue(a0 string, a1 string) ([]*model.Issue, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllRepositoryIssue(a0, a1)
+	if r1 != nil { h.Hook("GetAllRepositoryIssue", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetRepositoryIssue(a0 string, a1 string, a2 int) (*model.Issue, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetRepositoryIssue(a0, a1, a2)
+	if r1 != nil { h.Hook("GetRepositoryIssue", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) CountAllRepositoryIssue(a0 string, a1 string) (int, error) {
+	r0, r1 := h.GitusDatabaseInterface.CountAllRepositoryIssue(a0, a1)
+	if r1 != nil { h.Hook("CountAllRepositoryIssue", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) CountIssue(a0 string, a1 string, a2 string, a3 int) (int64, error) {
+	r0, r1 := h.GitusDatabaseInterface.CountIssue(a0, a1, a2, a3)
+	if r1 != nil { h.Hook("CountIssue", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) SearchIssuePaginated(a0 string, a1 string, a2 string, a3 int, a4 int64, a5 int64) ([]*model.Issue, error) {
+	r0, r1 := h.GitusDatabaseInterface.SearchIssuePaginated(a0, a1, a2, a3, a4, a5)
+	if r1 != nil { h.Hook("SearchIssuePaginated", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) NewRepositoryIssue(a0 string, a1 string, a2 string, a3 string, a4 string) (int64, error) {
+	r0, r1 := h.GitusDatabaseInterface.NewRepositoryIssue(a0, a1, a2, a3, a4)
+	if r1 != nil { h.Hook("NewRepositoryIssue", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) HardDeleteRepositoryIssue(a0 string, a1 string, a2 int) error {
+	r0 := h.GitusDatabaseInterface.HardDeleteRepositoryIssue(a0, a1, a2)
+	if r0 != nil { h.Hook("HardDeleteRepositoryIssue", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) SetIssuePriority(a0 string, a1 string, a2 int64, a3 int) error {
+	r0 := h.GitusDatabaseInterface.SetIssuePriority(a0, a1, a2, a3)
+	if r0 != nil { h.Hook("SetIssuePriority", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllIssueEvent(a0 string, a1 string, a2 int) ([]*model.IssueEvent, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllIssueEvent(a0, a1, a2)
+	if r1 != nil { h.Hook("GetAllIssueEvent", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) NewRepositoryIssueEvent(a0 string, a1 string, a2 int64, a3 int, a4 string, a5 string) error {
+	r0 := h.GitusDatabaseInterface.NewRepositoryIssueEvent(a0, a1, a2, a3, a4, a5)
+	if r0 != nil { h.Hook("NewRepositoryIssueEvent", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) HardDeleteRepositoryIssueEvent(a0 int64) error {
+	r0 := h.GitusDatabaseInterface.HardDeleteRepositoryIssueEvent(a0)
+	if r0 != nil { h.Hook("HardDeleteRepositoryIssueEvent", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllBelongingNamespace(a0 string, a1 string) ([]*model.Namespace, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllBelongingNamespace(a0, a1)
+	if r1 != nil { h.Hook("GetAllBelongingNamespace", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllBelongingRepository(a0 string, a1 string, a2 string, a3 int64, a4 int64) ([]*model.Repository, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllBelongingRepository(a0, a1, a2, a3, a4)
+	if r1 != nil { h.Hook("GetAllBelongingRepository", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) CountAllBelongingRepository(a0 string, a1 string, a2 string) (int64, error) {
+	r0, r1 := h.GitusDatabaseInterface.CountAllBelongingRepository(a0, a1, a2)
+	if r1 != nil { h.Hook("CountAllBelongingRepository", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetForkRepositoryOfUser(a0 string, a1 string, a2 string) ([]*model.Repository, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetForkRepositoryOfUser(a0, a1, a2)
+	if r1 != nil { h.Hook("GetForkRepositoryOfUser", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetForkRepositoryList(a0 string, a1 string) ([]*model.Repository, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetForkRepositoryList(a0, a1)
+	if r1 != nil { h.Hook("GetForkRepositoryList", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetForkAutoSync(a0 string, a1 string) (bool, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetForkAutoSync(a0, a1)
+	if r1 != nil { h.Hook("GetForkAutoSync", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) SetForkAutoSync(a0 string, a1 string, a2 bool) error {
+	r0 := h.GitusDatabaseInterface.SetForkAutoSync(a0, a1, a2)
+	if r0 != nil { h.Hook("SetForkAutoSync", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllPullRequestPaginated(a0 string, a1 string, a2 int64, a3 int64) ([]*model.PullRequest, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllPullRequestPaginated(a0, a1, a2, a3)
+	if r1 != nil { h.Hook("GetAllPullRequestPaginated", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) NewPullRequest(a0 string, a1 string, a2 string, a3 string, a4 string, a5 string, a6 string, a7 string) (int64, error) {
+	r0, r1 := h.GitusDatabaseInterface.NewPullRequest(a0, a1, a2, a3, a4, a5, a6, a7)
+	if r1 != nil { h.Hook("NewPullRequest", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetPullRequest(a0 string, a1 string, a2 int64) (*model.PullRequest, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetPullRequest(a0, a1, a2)
+	if r1 != nil { h.Hook("GetPullRequest", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetPullRequestByAbsId(a0 int64) (*model.PullRequest, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetPullRequestByAbsId(a0)
+	if r1 != nil { h.Hook("GetPullRequestByAbsId", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) CheckPullRequestMergeConflict(a0 context.Context, a1 int64) (*gitlib.MergeCheckResult, error) {
+	r0, r1 := h.GitusDatabaseInterface.CheckPullRequestMergeConflict(a0, a1)
+	if r1 != nil { h.Hook("CheckPullRequestMergeConflict", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) DeletePullRequest(a0 int64) error {
+	r0 := h.GitusDatabaseInterface.DeletePullRequest(a0)
+	if r0 != nil { h.Hook("DeletePullRequest", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllPullRequestEventPaginated(a0 int64, a1 int64, a2 int64) ([]*model.PullRequestEvent, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllPullRequestEventPaginated(a0, a1, a2)
+	if r1 != nil { h.Hook("GetAllPullRequestEventPaginated", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) CheckAndMergePullRequest(a0 context.Context, a1 int64, a2 string) error {
+	r0 := h.GitusDatabaseInterface.CheckAndMergePullRequest(a0, a1, a2)
+	if r0 != nil { h.Hook("CheckAndMergePullRequest", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) CommentOnPullRequest(a0 int64, a1 string, a2 string) (*model.PullRequestEvent, error) {
+	r0, r1 := h.GitusDatabaseInterface.CommentOnPullRequest(a0, a1, a2)
+	if r1 != nil { h.Hook("CommentOnPullRequest", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) CommentOnPullRequestCode(a0 int64, a1 *model.PullRequestCommentOnCode) (*model.PullRequestEvent, error) {
+	r0, r1 := h.GitusDatabaseInterface.CommentOnPullRequestCode(a0, a1)
+	if r1 != nil { h.Hook("CommentOnPullRequestCode", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) RecordPullRequestBranchUpdate(a0 int64, a1 string, a2 string) (*model.PullRequestEvent, error) {
+	r0, r1 := h.GitusDatabaseInterface.RecordPullRequestBranchUpdate(a0, a1, a2)
+	if r1 != nil { h.Hook("RecordPullRequestBranchUpdate", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetPullRequestProviderHead(a0 int64) (string, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetPullRequestProviderHead(a0)
+	if r1 != nil { h.Hook("GetPullRequestProviderHead", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) SetPullRequestProviderHead(a0 int64, a1 string) error {
+	r0 := h.GitusDatabaseInterface.SetPullRequestProviderHead(a0, a1)
+	if r0 != nil { h.Hook("SetPullRequestProviderHead", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetOpenPullRequestOfProvider(a0 string, a1 string) ([]*model.PullRequest, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetOpenPullRequestOfProvider(a0, a1)
+	if r1 != nil { h.Hook("GetOpenPullRequestOfProvider", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) ClosePullRequestAsNotMerged(a0 int64, a1 string) error {
+	r0 := h.GitusDatabaseInterface.ClosePullRequestAsNotMerged(a0, a1)
+	if r0 != nil { h.Hook("ClosePullRequestAsNotMerged", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) ReopenPullRequest(a0 int64, a1 string) error {
+	r0 := h.GitusDatabaseInterface.ReopenPullRequest(a0, a1)
+	if r0 != nil { h.Hook("ReopenPullRequest", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) CountPullRequest(a0 string, a1 string, a2 string, a3 int) (int64, error) {
+	r0, r1 := h.GitusDatabaseInterface.CountPullRequest(a0, a1, a2, a3)
+	if r1 != nil { h.Hook("CountPullRequest", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) SearchPullRequestPaginated(a0 string, a1 string, a2 string, a3 int, a4 int64, a5 int64) ([]*model.PullRequest, error) {
+	r0, r1 := h.GitusDatabaseInterface.SearchPullRequestPaginated(a0, a1, a2, a3, a4, a5)
+	if r1 != nil { h.Hook("SearchPullRequestPaginated", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllRegisteredEmailOfUser(a0 string) ([]struct {
+	Email		string
+	Verified	bool
+}, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllRegisteredEmailOfUser(a0)
+	if r1 != nil { h.Hook("GetAllRegisteredEmailOfUser", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) AddEmail(a0 string, a1 string) error {
+	r0 := h.GitusDatabaseInterface.AddEmail(a0, a1)
+	if r0 != nil { h.Hook("AddEmail", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) VerifyRegisteredEmail(a0 string, a1 string) error {
+	r0 := h.GitusDatabaseInterface.VerifyRegisteredEmail(a0, a1)
+	if r0 != nil { h.Hook("VerifyRegisteredEmail", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) DeleteRegisteredEmail(a0 string, a1 string) error {
+	r0 := h.GitusDatabaseInterface.DeleteRegisteredEmail(a0, a1)
+	if r0 != nil { h.Hook("DeleteRegisteredEmail", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) CheckIfEmailVerified(a0 string, a1 string) (bool, error) {
+	r0, r1 := h.GitusDatabaseInterface.CheckIfEmailVerified(a0, a1)
+	if r1 != nil { h.Hook("CheckIfEmailVerified", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) ResolveEmailToUsername(a0 string) (string, error) {
+	r0, r1 := h.GitusDatabaseInterface.ResolveEmailToUsername(a0)
+	if r1 != nil { h.Hook("ResolveEmailToUsername", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) ResolveMultipleEmailToUsername(a0 map[string]string) (map[string]string, error) {
+	r0, r1 := h.GitusDatabaseInterface.ResolveMultipleEmailToUsername(a0)
+	if r1 != nil { h.Hook("ResolveMultipleEmailToUsername", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) InsertRegistrationRequest(a0 string, a1 string, a2 string, a3 string) error {
+	r0 := h.GitusDatabaseInterface.InsertRegistrationRequest(a0, a1, a2, a3)
+	if r0 != nil { h.Hook("InsertRegistrationRequest", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetRegistrationRequestPaginated(a0 int64, a1 int64) ([]*model.RegistrationRequest, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetRegistrationRequestPaginated(a0, a1)
+	if r1 != nil { h.Hook("GetRegistrationRequestPaginated", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetRequestOfUsernamePaginated(a0 string, a1 int64, a2 int64) ([]*model.RegistrationRequest, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetRequestOfUsernamePaginated(a0, a1, a2)
+	if r1 != nil { h.Hook("GetRequestOfUsernamePaginated", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) ApproveRegistrationRequest(a0 int64) error {
+	r0 := h.GitusDatabaseInterface.ApproveRegistrationRequest(a0)
+	if r0 != nil { h.Hook("ApproveRegistrationRequest", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) DisapproveRegistrationRequest(a0 int64) error {
+	r0 := h.GitusDatabaseInterface.DisapproveRegistrationRequest(a0)
+	if r0 != nil { h.Hook("DisapproveRegistrationRequest", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) CountRegistrationRequest(a0 string) (int64, error) {
+	r0, r1 := h.GitusDatabaseInterface.CountRegistrationRequest(a0)
+	if r1 != nil { h.Hook("CountRegistrationRequest", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) SearchRegistrationRequestPaginated(a0 string, a1 int64, a2 int64) ([]*model.RegistrationRequest, error) {
+	r0, r1 := h.GitusDatabaseInterface.SearchRegistrationRequestPaginated(a0, a1, a2)
+	if r1 != nil { h.Hook("SearchRegistrationRequestPaginated", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetRegistrationRequestByAbsId(a0 int64) (*model.RegistrationRequest, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetRegistrationRequestByAbsId(a0)
+	if r1 != nil { h.Hook("GetRegistrationRequestByAbsId", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) AddRepositoryLabel(a0 string, a1 string, a2 string) error {
+	r0 := h.GitusDatabaseInterface.AddRepositoryLabel(a0, a1, a2)
+	if r0 != nil { h.Hook("AddRepositoryLabel", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) RemoveRepositoryLabel(a0 string, a1 string, a2 string) error {
+	r0 := h.GitusDatabaseInterface.RemoveRepositoryLabel(a0, a1, a2)
+	if r0 != nil { h.Hook("RemoveRepositoryLabel", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetRepositoryLabel(a0 string, a1 string) ([]string, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetRepositoryLabel(a0, a1)
+	if r1 != nil { h.Hook("GetRepositoryLabel", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) CountRepositoryWithLabel(a0 string, a1 string) (int64, error) {
+	r0, r1 := h.GitusDatabaseInterface.CountRepositoryWithLabel(a0, a1)
+	if r1 != nil { h.Hook("CountRepositoryWithLabel", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetRepositoryWithLabelPaginated(a0 string, a1 string, a2 int64, a3 int64) ([]*model.Repository, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetRepositoryWithLabelPaginated(a0, a1, a2, a3)
+	if r1 != nil { h.Hook("GetRepositoryWithLabelPaginated", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) NewSnippet(a0 string, a1 string, a2 uint8) (*model.Snippet, error) {
+	r0, r1 := h.GitusDatabaseInterface.NewSnippet(a0, a1, a2)
+	if r1 != nil { h.Hook("NewSnippet", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllSnippet(a0 string) ([]*model.Snippet, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllSnippet(a0)
+	if r1 != nil { h.Hook("GetAllSnippet", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) CountAllVisibleSnippet(a0 string, a1 string, a2 string) (int64, error) {
+	r0, r1 := h.GitusDatabaseInterface.CountAllVisibleSnippet(a0, a1, a2)
+	if r1 != nil { h.Hook("CountAllVisibleSnippet", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetAllVisibleSnippetPaginated(a0 string, a1 string, a2 string, a3 int64, a4 int64) ([]*model.Snippet, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetAllVisibleSnippetPaginated(a0, a1, a2, a3, a4)
+	if r1 != nil { h.Hook("GetAllVisibleSnippetPaginated", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) DeleteSnippet(a0 string, a1 string) error {
+	r0 := h.GitusDatabaseInterface.DeleteSnippet(a0, a1)
+	if r0 != nil { h.Hook("DeleteSnippet", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) SaveSnippetInfo(a0 *model.Snippet) error {
+	r0 := h.GitusDatabaseInterface.SaveSnippetInfo(a0)
+	if r0 != nil { h.Hook("SaveSnippetInfo", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetSnippet(a0 string, a1 string) (*model.Snippet, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetSnippet(a0, a1)
+	if r1 != nil { h.Hook("GetSnippet", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) RegisterWebhookRequest(a0 string, a1 string, a2 string, a3 string, a4 string) error {
+	r0 := h.GitusDatabaseInterface.RegisterWebhookRequest(a0, a1, a2, a3, a4)
+	if r0 != nil { h.Hook("RegisterWebhookRequest", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) UpdateWebhookResult(a0 string, a1 *model.WebhookResult) error {
+	r0 := h.GitusDatabaseInterface.UpdateWebhookResult(a0, a1)
+	if r0 != nil { h.Hook("UpdateWebhookResult", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) GetWebhookResultByUUID(a0 string) (*model.WebhookResult, error) {
+	r0, r1 := h.GitusDatabaseInterface.GetWebhookResultByUUID(a0)
+	if r1 != nil { h.Hook("GetWebhookResultByUUID", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) CountWebhookResultByStatus() (map[uint8]int64, error) {
+	r0, r1 := h.GitusDatabaseInterface.CountWebhookResultByStatus()
+	if r1 != nil { h.Hook("CountWebhookResultByStatus", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) AppendAuditLog(a0 *model.AuditLogEntry) error {
+	r0 := h.GitusDatabaseInterface.AppendAuditLog(a0)
+	if r0 != nil { h.Hook("AppendAuditLog", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) CountAuditLog(a0 *model.AuditLogFilter) (int64, error) {
+	r0, r1 := h.GitusDatabaseInterface.CountAuditLog(a0)
+	if r1 != nil { h.Hook("CountAuditLog", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusDatabaseInterface) SearchAuditLogPaginated(a0 *model.AuditLogFilter, a1 int64, a2 int64) ([]*model.AuditLogEntry, error) {
+	r0, r1 := h.GitusDatabaseInterface.SearchAuditLogPaginated(a0, a1, a2)
+	if r1 != nil { h.Hook("SearchAuditLogPaginated", r1) }
+	return r0, r1
+}
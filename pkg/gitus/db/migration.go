@@ -0,0 +1,54 @@
+package db
+
+import "fmt"
+
+// versioned schema migrations. each backend has its own ordered list
+// of migrations; the version of a database is the largest version
+// recorded in its `{prefix}_schema_version` table. see
+// docs/migration.org.
+
+type Migration struct {
+	Version int
+	Description string
+	// the sql statements, with the table prefix already filled in.
+	// version 1 (the initial schema) is created by InstallTables
+	// and has no statements.
+	Statement []string
+}
+
+func LatestSchemaVersion(l []*Migration) int {
+	res := 0
+	for _, k := range l {
+		if k.Version > res { res = k.Version }
+	}
+	return res
+}
+
+func GetPendingMigration(dbif GitusDatabaseInterface) ([]*Migration, error) {
+	v, err := dbif.GetSchemaVersion()
+	if err != nil { return nil, err }
+	if v <= 0 { return nil, ErrDatabaseNotInstalled }
+	res := make([]*Migration, 0)
+	for _, k := range dbif.GetMigrationList() {
+		if k.Version > v { res = append(res, k) }
+	}
+	return res, nil
+}
+
+// checks the version of the database against the migrations this
+// build of gitus knows about. gitus refuses to work with a database
+// that is either too old (needs `gitus migrate up`) or too new (was
+// migrated by a later version of gitus).
+func CheckSchemaVersion(dbif GitusDatabaseInterface) error {
+	v, err := dbif.GetSchemaVersion()
+	if err != nil { return err }
+	if v <= 0 { return ErrDatabaseNotInstalled }
+	latest := LatestSchemaVersion(dbif.GetMigrationList())
+	if v < latest {
+		return fmt.Errorf("%w (database: %d, required: %d) Run `gitus migrate up` to upgrade the database.", ErrSchemaTooOld, v, latest)
+	}
+	if v > latest {
+		return fmt.Errorf("%w (database: %d, supported: %d) Please upgrade Gitus.", ErrSchemaTooNew, v, latest)
+	}
+	return nil
+}
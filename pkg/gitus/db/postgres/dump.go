@@ -0,0 +1,147 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	pgx "github.com/jackc/pgx/v5"
+)
+
+// see pkg/gitus/db/dump.go & docs/backup.org.
+
+// the neutral columns that are named differently in postgres.
+var dumpColumnName = map[string]map[string]string{
+	"user_email": {
+		"verified": "email_verified",
+	},
+}
+
+func getDumpColumnName(table string, column string) string {
+	m, ok := dumpColumnName[table]
+	if !ok { return column }
+	s, ok := m[column]
+	if !ok { return column }
+	return s
+}
+
+// TIMESTAMP columns are written with local time (see e.g.
+// RegisterUser) and read back as the same wall clock in UTC.
+func timestampToUnix(t time.Time) int64 {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.Local).Unix()
+}
+
+func (dbif *PostgresGitusDatabaseInterface) ExportDump(w *db.DumpWriter) error {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	// everything is read within one snapshot so that the dump is
+	// consistent.
+	tx, err := dbif.pool.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel: pgx.RepeatableRead,
+		AccessMode: pgx.ReadOnly,
+	})
+	if err != nil { return err }
+	defer tx.Rollback(ctx)
+	for _, t := range db.DumpTableList {
+		selectList := make([]string, 0, len(t.Column))
+		for _, c := range t.Column {
+			selectList = append(selectList, getDumpColumnName(t.Name, c.Name))
+		}
+		order := ""
+		if idc := t.IdentityColumn(); idc != nil {
+			order = fmt.Sprintf(" ORDER BY %s ASC", getDumpColumnName(t.Name, idc.Name))
+		}
+		err := func() error {
+			rows, err := tx.Query(ctx, fmt.Sprintf(
+				"SELECT %s FROM %s_%s%s",
+				strings.Join(selectList, ", "), pfx, t.Name, order,
+			))
+			if err != nil { return err }
+			defer rows.Close()
+			for rows.Next() {
+				val, err := rows.Values()
+				if err != nil { return err }
+				row := make(map[string]any, len(t.Column))
+				for i, c := range t.Column {
+					v := val[i]
+					if tv, ok := v.(time.Time); ok { v = timestampToUnix(tv) }
+					dv, err := db.ToDumpValue(c.Type, v)
+					if err != nil { return fmt.Errorf("%s.%s: %w", t.Name, c.Name, err) }
+					row[c.Name] = dv
+				}
+				err = w.Write(t.Name, row)
+				if err != nil { return err }
+			}
+			return rows.Err()
+		}()
+		if err != nil { return err }
+	}
+	return nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) ImportDump(r *db.DumpReader) error {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	tx, err := dbif.pool.Begin(ctx)
+	if err != nil { return err }
+	defer tx.Rollback(ctx)
+	identityUsed := make(map[string]bool, 0)
+	for {
+		rec, err := r.Next()
+		if err == io.EOF { break }
+		if err != nil { return err }
+		t := db.GetDumpTable(rec.Table)
+		columnList := make([]string, 0)
+		placeholderList := make([]string, 0)
+		argList := make([]any, 0)
+		override := false
+		for _, c := range t.Column {
+			v, ok := rec.Row[c.Name]
+			if !ok { continue }
+			gv, err := db.FromDumpValue(c.Type, v)
+			if err != nil { return fmt.Errorf("%s.%s: %w", t.Name, c.Name, err) }
+			if gv != nil {
+				switch c.Type {
+				case db.DUMP_TIME:
+					gv = time.Unix(gv.(int64), 0)
+				case db.DUMP_JSON:
+					// JSONB doesn't take empty strings, which sqlite
+					// uses for e.g. empty ACLs.
+					if len(gv.(string)) <= 0 { gv = "null" }
+				}
+			}
+			if c.Identity {
+				if gv == nil { continue }
+				override = true
+			}
+			columnList = append(columnList, getDumpColumnName(t.Name, c.Name))
+			argList = append(argList, gv)
+			placeholderList = append(placeholderList, fmt.Sprintf("$%d", len(argList)))
+		}
+		if len(columnList) <= 0 { continue }
+		overrideClause := ""
+		if override {
+			overrideClause = " OVERRIDING SYSTEM VALUE"
+			identityUsed[t.Name] = true
+		}
+		_, err = tx.Exec(ctx, fmt.Sprintf(
+			"INSERT INTO %s_%s(%s)%s VALUES (%s)",
+			pfx, t.Name, strings.Join(columnList, ", "), overrideClause,
+			strings.Join(placeholderList, ", "),
+		), argList...)
+		if err != nil { return fmt.Errorf("Failed to import into %s: %w", t.Name, err) }
+	}
+	// the identity sequences must be moved past the imported ids.
+	for tableName := range identityUsed {
+		c := getDumpColumnName(tableName, db.GetDumpTable(tableName).IdentityColumn().Name)
+		fullName := fmt.Sprintf("%s_%s", pfx, tableName)
+		_, err = tx.Exec(ctx, fmt.Sprintf(`
+SELECT setval(pg_get_serial_sequence($1, $2), (SELECT COALESCE(MAX(%s), 0) + 1 FROM %s), false)
+`, c, fullName), fullName, c)
+		if err != nil { return err }
+	}
+	return tx.Commit(ctx)
+}
@@ -176,10 +176,12 @@ CREATE TABLE IF NOT EXISTS %s_webhook_log (
 	commit_id VARCHAR(96),
     webhook_result JSONB
 )`, pfx))
+	if err != nil { return err }
+	err = dbif.initSchemaVersion(ctx, tx)
 	if err != nil { return err }
 	err = tx.Commit(ctx)
 	if err != nil { return err }
-
-	return nil
+	_, err = dbif.Migrate()
+	return err
 }
 
@@ -0,0 +1,260 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	pgx "github.com/jackc/pgx/v5"
+)
+
+// NOTE: migrations must never be changed once released; add a new
+// one instead. see docs/migration.org.
+func (dbif *PostgresGitusDatabaseInterface) GetMigrationList() []*db.Migration {
+	pfx := dbif.config.Database.TablePrefix
+	return []*db.Migration{
+		&db.Migration{
+			Version: 1,
+			Description: "Initial schema",
+		},
+		&db.Migration{
+			Version: 2,
+			Description: "Add WebAuthn credential table",
+			Statement: []string{fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s_user_webauthn (
+    user_name VARCHAR(64),
+    credential_id VARCHAR(2048) UNIQUE,
+    credential_name VARCHAR(96),
+    public_key BYTEA,
+    sign_count BIGINT,
+    passkey BOOLEAN,
+    reg_timestamp BIGINT,
+    last_used_timestamp BIGINT,
+    FOREIGN KEY (user_name) REFERENCES %s_user(user_name)
+)`, pfx, pfx)},
+		},
+		&db.Migration{
+			Version: 3,
+			Description: "Add OpenID Connect account link table",
+			Statement: []string{fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s_user_oidc (
+    provider_id VARCHAR(64),
+    subject VARCHAR(255),
+    user_name VARCHAR(64),
+    link_timestamp BIGINT,
+    UNIQUE (provider_id, subject),
+    FOREIGN KEY (user_name) REFERENCES %s_user(user_name)
+)`, pfx, pfx)},
+		},
+		&db.Migration{
+			Version: 4,
+			Description: "Add audit log table",
+			Statement: []string{
+				fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s_audit_log (
+    audit_absid BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    audit_timestamp BIGINT,
+    audit_actor VARCHAR(64),
+    audit_ip VARCHAR(64),
+    audit_action VARCHAR(64),
+    audit_target VARCHAR(256),
+    audit_diff TEXT
+)`, pfx),
+				fmt.Sprintf(`
+CREATE INDEX IF NOT EXISTS %s_audit_log_timestamp ON %s_audit_log(audit_timestamp)
+`, pfx, pfx),
+				// the audit log is append-only.
+				fmt.Sprintf(`
+CREATE OR REPLACE FUNCTION %s_audit_log_append_only() RETURNS trigger AS $$
+BEGIN RAISE EXCEPTION 'the audit log is append-only'; END;
+$$ LANGUAGE plpgsql
+`, pfx),
+				fmt.Sprintf(`
+DROP TRIGGER IF EXISTS %s_audit_log_append_only ON %s_audit_log
+`, pfx, pfx),
+				fmt.Sprintf(`
+CREATE TRIGGER %s_audit_log_append_only BEFORE UPDATE OR DELETE OR TRUNCATE ON %s_audit_log
+FOR EACH STATEMENT EXECUTE FUNCTION %s_audit_log_append_only()
+`, pfx, pfx, pfx),
+			},
+		},
+		&db.Migration{
+			Version: 5,
+			Description: "Add personal access token table",
+			Statement: []string{fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s_user_access_token (
+    user_name VARCHAR(64),
+    token_name VARCHAR(96),
+    token_hash VARCHAR(64) UNIQUE,
+    reg_timestamp BIGINT,
+    last_used_timestamp BIGINT,
+    expire_timestamp BIGINT,
+    UNIQUE (user_name, token_name),
+    FOREIGN KEY (user_name) REFERENCES %s_user(user_name)
+)`, pfx, pfx)},
+		},
+		&db.Migration{
+			Version: 6,
+			Description: "Add Git LFS object table",
+			Statement: []string{fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s_lfs_object (
+    repo_namespace VARCHAR(64),
+    repo_name VARCHAR(64),
+    oid VARCHAR(64),
+    size BIGINT,
+    upload_timestamp BIGINT,
+    UNIQUE (repo_namespace, repo_name, oid),
+    FOREIGN KEY (repo_namespace, repo_name)
+      REFERENCES %s_repository(repo_namespace, repo_name)
+)`, pfx, pfx)},
+		},
+		&db.Migration{
+			Version: 7,
+			Description: "Add repository maintenance run table",
+			Statement: []string{
+				fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s_repo_maintenance (
+    repo_namespace VARCHAR(64),
+    repo_name VARCHAR(64),
+    run_task VARCHAR(64),
+    run_trigger VARCHAR(64),
+    run_start_timestamp BIGINT,
+    run_duration BIGINT,
+    run_success BOOLEAN,
+    run_output TEXT,
+    FOREIGN KEY (repo_namespace, repo_name)
+      REFERENCES %s_repository(repo_namespace, repo_name)
+)`, pfx, pfx),
+				fmt.Sprintf(`
+CREATE INDEX IF NOT EXISTS %s_repo_maintenance_repo ON %s_repo_maintenance(repo_namespace, repo_name, run_task, run_start_timestamp)
+`, pfx, pfx),
+			},
+		},
+		&db.Migration{
+			Version: 8,
+			Description: "Add quota & repository size tables",
+			Statement: []string{
+				fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s_quota (
+    subject_type VARCHAR(64),
+    subject_name VARCHAR(64),
+    repository_size BIGINT,
+    lfs_size BIGINT,
+    snippet_size BIGINT,
+    repository_count BIGINT,
+    UNIQUE (subject_type, subject_name)
+)`, pfx),
+				fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s_repo_size (
+    repo_namespace VARCHAR(64),
+    repo_name VARCHAR(64),
+    repo_size BIGINT,
+    update_timestamp BIGINT,
+    UNIQUE (repo_namespace, repo_name),
+    FOREIGN KEY (repo_namespace, repo_name)
+      REFERENCES %s_repository(repo_namespace, repo_name)
+)`, pfx, pfx),
+			},
+		},
+		&db.Migration{
+			Version: 9,
+			Description: "Add fork auto-sync table",
+			Statement: []string{
+				fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s_fork_sync (
+    repo_namespace VARCHAR(64),
+    repo_name VARCHAR(64),
+    UNIQUE (repo_namespace, repo_name),
+    FOREIGN KEY (repo_namespace, repo_name)
+      REFERENCES %s_repository(repo_namespace, repo_name)
+)`, pfx, pfx),
+			},
+		},
+		&db.Migration{
+			Version: 10,
+			Description: "Add pull request provider head table",
+			Statement: []string{
+				fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s_pull_request_head (
+    pull_request_absid BIGINT UNIQUE,
+    head_commit_id VARCHAR(64),
+    FOREIGN KEY (pull_request_absid) REFERENCES %s_pull_request(pull_request_absid)
+)`, pfx, pfx),
+			},
+		},
+	}
+}
+
+// creates the version table & records the initial schema if it's not
+// there yet.
+func (dbif *PostgresGitusDatabaseInterface) initSchemaVersion(ctx context.Context, tx pgx.Tx) error {
+	pfx := dbif.config.Database.TablePrefix
+	_, err := tx.Exec(ctx, fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s_schema_version (
+    version INTEGER UNIQUE,
+    description VARCHAR(256),
+    applied_timestamp BIGINT
+)`, pfx))
+	if err != nil { return err }
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+INSERT INTO %s_schema_version(version, description, applied_timestamp)
+SELECT 1, $1, $2 WHERE NOT EXISTS (SELECT FROM %s_schema_version)
+`, pfx, pfx), "Initial schema", time.Now().Unix())
+	return err
+}
+
+func (dbif *PostgresGitusDatabaseInterface) GetSchemaVersion() (int, error) {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	var a bool
+	err := dbif.pool.QueryRow(ctx, `
+SELECT EXISTS (SELECT FROM pg_tables WHERE schemaname = 'public' AND tablename = $1)
+`, pfx + "_schema_version").Scan(&a)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) { return 0, err }
+	if !a {
+		b, err := dbif.IsDatabaseUsable()
+		if err != nil { return 0, err }
+		if b { return 1, nil }
+		return 0, nil
+	}
+	var v *int32
+	err = dbif.pool.QueryRow(ctx, fmt.Sprintf("SELECT MAX(version) FROM %s_schema_version", pfx)).Scan(&v)
+	if err != nil { return 0, err }
+	if v == nil { return 1, nil }
+	return int(*v), nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) Migrate() ([]*db.Migration, error) {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	v, err := dbif.GetSchemaVersion()
+	if err != nil { return nil, err }
+	if v <= 0 { return nil, db.ErrDatabaseNotInstalled }
+	res := make([]*db.Migration, 0)
+	for _, m := range dbif.GetMigrationList() {
+		if m.Version <= v { continue }
+		err := func() error {
+			tx, err := dbif.pool.Begin(ctx)
+			if err != nil { return err }
+			defer tx.Rollback(ctx)
+			err = dbif.initSchemaVersion(ctx, tx)
+			if err != nil { return err }
+			for _, stmt := range m.Statement {
+				_, err = tx.Exec(ctx, stmt)
+				if err != nil { return err }
+			}
+			_, err = tx.Exec(ctx, fmt.Sprintf(`
+INSERT INTO %s_schema_version(version, description, applied_timestamp) VALUES ($1,$2,$3)
+`, pfx), m.Version, m.Description, time.Now().Unix())
+			if err != nil { return err }
+			return tx.Commit(ctx)
+		}()
+		if err != nil {
+			return res, fmt.Errorf("Failed to apply migration %d (%s): %w", m.Version, m.Description, err)
+		}
+		res = append(res, m)
+	}
+	return res, nil
+}
@@ -1,14 +1,12 @@
 package postgres
 
 import (
-	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path"
 	"slices"
 	"strings"
@@ -16,6 +14,7 @@ import (
 
 	"github.com/GitusCodeForge/Gitus/pkg/gitlib"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	gitlog "github.com/GitusCodeForge/Gitus/pkg/gitus/log"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	pgx "github.com/jackc/pgx/v5"
 )
@@ -140,6 +139,231 @@ WHERE user_name = $1 AND key_name = $2
 	return nil
 }
 
+func (dbif *PostgresGitusDatabaseInterface) GetAllWebAuthnCredentialByUsername(username string) ([]*model.WebAuthnCredential, error) {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	stmt, err := dbif.pool.Query(ctx, fmt.Sprintf(`
+SELECT credential_id, credential_name, public_key, sign_count, passkey, reg_timestamp, last_used_timestamp
+FROM %s_user_webauthn
+WHERE user_name = $1
+ORDER BY reg_timestamp ASC
+`, pfx), username)
+	if err != nil { return nil, err }
+	defer stmt.Close()
+	res := make([]*model.WebAuthnCredential, 0)
+	for stmt.Next() {
+		c := &model.WebAuthnCredential{ UserName: username }
+		err := stmt.Scan(&c.CredentialId, &c.Name, &c.PublicKey, &c.SignCount, &c.Passkey, &c.RegisterTime, &c.LastUsedTime)
+		if err != nil { return nil, err }
+		res = append(res, c)
+	}
+	return res, nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) GetWebAuthnCredential(credentialId string) (*model.WebAuthnCredential, error) {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	stmt := dbif.pool.QueryRow(ctx, fmt.Sprintf(`
+SELECT user_name, credential_name, public_key, sign_count, passkey, reg_timestamp, last_used_timestamp
+FROM %s_user_webauthn
+WHERE credential_id = $1
+`, pfx), credentialId)
+	c := &model.WebAuthnCredential{ CredentialId: credentialId }
+	err := stmt.Scan(&c.UserName, &c.Name, &c.PublicKey, &c.SignCount, &c.Passkey, &c.RegisterTime, &c.LastUsedTime)
+	if errors.Is(err, pgx.ErrNoRows) { return nil, db.ErrEntityNotFound }
+	if err != nil { return nil, err }
+	return c, nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) RegisterWebAuthnCredential(cred *model.WebAuthnCredential) error {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	tx, err := dbif.pool.Begin(ctx)
+	if err != nil { return err }
+	defer tx.Rollback(ctx)
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+INSERT INTO %s_user_webauthn(user_name, credential_id, credential_name, public_key, sign_count, passkey, reg_timestamp, last_used_timestamp)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+`, pfx), cred.UserName, cred.CredentialId, cred.Name, cred.PublicKey, cred.SignCount, cred.Passkey, cred.RegisterTime, cred.LastUsedTime)
+	if err != nil { return err }
+	err = tx.Commit(ctx)
+	if err != nil { return err }
+	return nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) UpdateWebAuthnCredentialUsage(credentialId string, signCount int64, lastUsedTime int64) error {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	tx, err := dbif.pool.Begin(ctx)
+	if err != nil { return err }
+	defer tx.Rollback(ctx)
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+UPDATE %s_user_webauthn SET sign_count = $1, last_used_timestamp = $2 WHERE credential_id = $3
+`, pfx), signCount, lastUsedTime, credentialId)
+	if err != nil { return err }
+	err = tx.Commit(ctx)
+	if err != nil { return err }
+	return nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) RemoveWebAuthnCredential(username string, credentialId string) error {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	tx, err := dbif.pool.Begin(ctx)
+	if err != nil { return err }
+	defer tx.Rollback(ctx)
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+DELETE FROM %s_user_webauthn WHERE user_name = $1 AND credential_id = $2
+`, pfx), username, credentialId)
+	if err != nil { return err }
+	err = tx.Commit(ctx)
+	if err != nil { return err }
+	return nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) GetOIDCLink(providerId string, subject string) (*model.OIDCLink, error) {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	stmt := dbif.pool.QueryRow(ctx, fmt.Sprintf(`
+SELECT user_name, link_timestamp FROM %s_user_oidc WHERE provider_id = $1 AND subject = $2
+`, pfx), providerId, subject)
+	l := &model.OIDCLink{ ProviderId: providerId, Subject: subject }
+	err := stmt.Scan(&l.UserName, &l.LinkTime)
+	if errors.Is(err, pgx.ErrNoRows) { return nil, db.ErrEntityNotFound }
+	if err != nil { return nil, err }
+	return l, nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) GetAllOIDCLinkOfUser(username string) ([]*model.OIDCLink, error) {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	r, err := dbif.pool.Query(ctx, fmt.Sprintf(`
+SELECT provider_id, subject, link_timestamp FROM %s_user_oidc WHERE user_name = $1 ORDER BY link_timestamp ASC
+`, pfx), username)
+	if err != nil { return nil, err }
+	defer r.Close()
+	res := make([]*model.OIDCLink, 0)
+	for r.Next() {
+		l := &model.OIDCLink{ UserName: username }
+		err = r.Scan(&l.ProviderId, &l.Subject, &l.LinkTime)
+		if err != nil { return nil, err }
+		res = append(res, l)
+	}
+	return res, nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) RegisterOIDCLink(link *model.OIDCLink) error {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	tx, err := dbif.pool.Begin(ctx)
+	if err != nil { return err }
+	defer tx.Rollback(ctx)
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+INSERT INTO %s_user_oidc(provider_id, subject, user_name, link_timestamp) VALUES ($1, $2, $3, $4)
+`, pfx), link.ProviderId, link.Subject, link.UserName, link.LinkTime)
+	if err != nil { return err }
+	err = tx.Commit(ctx)
+	if err != nil { return err }
+	return nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) RemoveOIDCLink(username string, providerId string) error {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	tx, err := dbif.pool.Begin(ctx)
+	if err != nil { return err }
+	defer tx.Rollback(ctx)
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+DELETE FROM %s_user_oidc WHERE user_name = $1 AND provider_id = $2
+`, pfx), username, providerId)
+	if err != nil { return err }
+	err = tx.Commit(ctx)
+	if err != nil { return err }
+	return nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) GetAllAccessTokenByUsername(username string) ([]*model.AccessToken, error) {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	stmt, err := dbif.pool.Query(ctx, fmt.Sprintf(`
+SELECT token_name, token_hash, reg_timestamp, last_used_timestamp, expire_timestamp
+FROM %s_user_access_token
+WHERE user_name = $1
+ORDER BY reg_timestamp ASC
+`, pfx), username)
+	if err != nil { return nil, err }
+	defer stmt.Close()
+	res := make([]*model.AccessToken, 0)
+	for stmt.Next() {
+		t := &model.AccessToken{ UserName: username }
+		err := stmt.Scan(&t.Name, &t.TokenHash, &t.RegisterTime, &t.LastUsedTime, &t.ExpireTime)
+		if err != nil { return nil, err }
+		res = append(res, t)
+	}
+	return res, nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) GetAccessTokenByHash(tokenHash string) (*model.AccessToken, error) {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	stmt := dbif.pool.QueryRow(ctx, fmt.Sprintf(`
+SELECT user_name, token_name, reg_timestamp, last_used_timestamp, expire_timestamp
+FROM %s_user_access_token
+WHERE token_hash = $1
+`, pfx), tokenHash)
+	t := &model.AccessToken{ TokenHash: tokenHash }
+	err := stmt.Scan(&t.UserName, &t.Name, &t.RegisterTime, &t.LastUsedTime, &t.ExpireTime)
+	if errors.Is(err, pgx.ErrNoRows) { return nil, db.ErrEntityNotFound }
+	if err != nil { return nil, err }
+	return t, nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) RegisterAccessToken(token *model.AccessToken) error {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	tx, err := dbif.pool.Begin(ctx)
+	if err != nil { return err }
+	defer tx.Rollback(ctx)
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+INSERT INTO %s_user_access_token(user_name, token_name, token_hash, reg_timestamp, last_used_timestamp, expire_timestamp)
+VALUES ($1, $2, $3, $4, $5, $6)
+`, pfx), token.UserName, token.Name, token.TokenHash, token.RegisterTime, token.LastUsedTime, token.ExpireTime)
+	if err != nil { return err }
+	err = tx.Commit(ctx)
+	if err != nil { return err }
+	return nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) UpdateAccessTokenUsage(tokenHash string, lastUsedTime int64) error {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	tx, err := dbif.pool.Begin(ctx)
+	if err != nil { return err }
+	defer tx.Rollback(ctx)
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+UPDATE %s_user_access_token SET last_used_timestamp = $1 WHERE token_hash = $2
+`, pfx), lastUsedTime, tokenHash)
+	if err != nil { return err }
+	err = tx.Commit(ctx)
+	if err != nil { return err }
+	return nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) RemoveAccessToken(username string, tokenName string) error {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	tx, err := dbif.pool.Begin(ctx)
+	if err != nil { return err }
+	defer tx.Rollback(ctx)
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+DELETE FROM %s_user_access_token WHERE user_name = $1 AND token_name = $2
+`, pfx), username, tokenName)
+	if err != nil { return err }
+	err = tx.Commit(ctx)
+	if err != nil { return err }
+	return nil
+}
+
 func (dbif *PostgresGitusDatabaseInterface) GetAllSignKeyByUsername(name string) ([]model.GitusSigningKey, error) {
 	pfx := dbif.config.Database.TablePrefix
 	ctx := context.Background()
@@ -805,6 +1029,10 @@ func (dbif *PostgresGitusDatabaseInterface) HardDeleteUserByName(name string) er
 	_, err = tx.Exec(ctx, fmt.Sprintf(`
 DELETE FROM %s_user WHERE user_name = $1
 `, pfx), name)
+	if err != nil { return err }
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+DELETE FROM %s_quota WHERE subject_type = $1 AND subject_name = $2
+`, pfx), model.QUOTA_SUBJECT_USER, name)
 	if err != nil { return err }
 	err = tx.Commit(ctx)
 	if err != nil { return err }
@@ -922,6 +1150,26 @@ func (dbif *PostgresGitusDatabaseInterface) HardDeleteNamespaceByName(name strin
 	if err != nil { return err }
 	defer tx.Rollback(ctx)
 	_, err = tx.Exec(ctx, fmt.Sprintf(`
+DELETE FROM %s_lfs_object WHERE repo_namespace = $1
+`, pfx), name)
+	if err != nil { return err }
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+DELETE FROM %s_repo_maintenance WHERE repo_namespace = $1
+`, pfx), name)
+	if err != nil { return err }
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+DELETE FROM %s_repo_size WHERE repo_namespace = $1
+`, pfx), name)
+	if err != nil { return err }
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+DELETE FROM %s_fork_sync WHERE repo_namespace = $1
+`, pfx), name)
+	if err != nil { return err }
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+DELETE FROM %s_quota WHERE subject_type = $1 AND subject_name = $2
+`, pfx), model.QUOTA_SUBJECT_NAMESPACE, name)
+	if err != nil { return err }
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
 DELETE FROM %s_namespace WHERE ns_name = $1
 `, pfx), name)
 	if err != nil { return err }
@@ -930,7 +1178,7 @@ DELETE FROM %s_namespace WHERE ns_name = $1
 	return nil
 }
 
-func (dbif *PostgresGitusDatabaseInterface) CreateRepository(ns string, name string, repoType uint8, owner string) (*model.Repository, error) {
+func (dbif *PostgresGitusDatabaseInterface) CreateRepository(ns string, name string, repoType uint8, objectFormat gitlib.ObjectFormat, owner string) (*model.Repository, error) {
 	pfx := dbif.config.Database.TablePrefix
 	ctx := context.Background()
 	tx, err := dbif.pool.Begin(ctx)
@@ -948,9 +1196,9 @@ VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 	}
 	if err = os.RemoveAll(p); err != nil { return nil, err }
 	if err = os.MkdirAll(p, os.ModeDir|0755); err != nil { return nil, err }
+	if err = model.InitLocalRepository(repoType, p, objectFormat); err != nil { return nil, err }
 	lr, err := model.CreateLocalRepository(repoType, ns, name, p)
 	if err != nil { return nil, err }
-	if err = model.InitLocalRepository(lr); err != nil { return nil, err }
 	if err = tx.Commit(ctx); err != nil { return nil, err }
 	r, err := model.NewRepository(ns, name, lr)
 	if err != nil { return nil, err }
@@ -1034,6 +1282,26 @@ func (dbif *PostgresGitusDatabaseInterface) HardDeleteRepository(ns string, name
 	if err != nil { return err }
 	defer tx.Rollback(ctx)
 	_, err = tx.Exec(ctx, fmt.Sprintf(`
+DELETE FROM %s_lfs_object
+WHERE repo_namespace = $1 AND repo_name = $2
+`, pfx), ns, name)
+	if err != nil { return err }
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+DELETE FROM %s_repo_maintenance
+WHERE repo_namespace = $1 AND repo_name = $2
+`, pfx), ns, name)
+	if err != nil { return err }
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+DELETE FROM %s_repo_size
+WHERE repo_namespace = $1 AND repo_name = $2
+`, pfx), ns, name)
+	if err != nil { return err }
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+DELETE FROM %s_fork_sync
+WHERE repo_namespace = $1 AND repo_name = $2
+`, pfx), ns, name)
+	if err != nil { return err }
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
 DELETE FROM %s_repository
 WHERE repo_namespace = $1 AND repo_name = $2
 `, pfx), ns, name)
@@ -1042,6 +1310,121 @@ WHERE repo_namespace = $1 AND repo_name = $2
 	return nil
 }
 
+func (dbif *PostgresGitusDatabaseInterface) GetLFSObject(ns string, name string, oid string) (*model.LFSObject, error) {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	stmt := dbif.pool.QueryRow(ctx, fmt.Sprintf(`
+SELECT size, upload_timestamp
+FROM %s_lfs_object
+WHERE repo_namespace = $1 AND repo_name = $2 AND oid = $3
+`, pfx), ns, name, oid)
+	res := &model.LFSObject{ RepoNamespace: ns, RepoName: name, OID: oid }
+	err := stmt.Scan(&res.Size, &res.UploadTime)
+	if errors.Is(err, pgx.ErrNoRows) { return nil, db.ErrEntityNotFound }
+	if err != nil { return nil, err }
+	return res, nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) RegisterLFSObject(obj *model.LFSObject) error {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	tx, err := dbif.pool.Begin(ctx)
+	if err != nil { return err }
+	defer tx.Rollback(ctx)
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+INSERT INTO %s_lfs_object(repo_namespace, repo_name, oid, size, upload_timestamp)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (repo_namespace, repo_name, oid) DO NOTHING
+`, pfx), obj.RepoNamespace, obj.RepoName, obj.OID, obj.Size, obj.UploadTime)
+	if err != nil { return err }
+	err = tx.Commit(ctx)
+	if err != nil { return err }
+	return nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) GetLFSStorageUsage(ns string, name string) (*model.LFSStorageUsage, error) {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	stmt := dbif.pool.QueryRow(ctx, fmt.Sprintf(`
+SELECT COUNT(*), COALESCE(SUM(size), 0)::BIGINT
+FROM %s_lfs_object
+WHERE repo_namespace = $1 AND repo_name = $2
+`, pfx), ns, name)
+	res := &model.LFSStorageUsage{}
+	err := stmt.Scan(&res.ObjectCount, &res.TotalSize)
+	if err != nil { return nil, err }
+	return res, nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) RecordMaintenanceRun(run *model.MaintenanceRun, keep int) error {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	tx, err := dbif.pool.Begin(ctx)
+	if err != nil { return err }
+	defer tx.Rollback(ctx)
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+INSERT INTO %s_repo_maintenance(repo_namespace, repo_name, run_task, run_trigger, run_start_timestamp, run_duration, run_success, run_output)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+`, pfx), run.RepoNamespace, run.RepoName, run.Task, run.Trigger, run.StartTime, run.Duration, run.Success, run.Output)
+	if err != nil { return err }
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+DELETE FROM %s_repo_maintenance
+WHERE repo_namespace = $1 AND repo_name = $2 AND run_start_timestamp < (
+    SELECT MIN(run_start_timestamp) FROM (
+        SELECT run_start_timestamp FROM %s_repo_maintenance
+        WHERE repo_namespace = $1 AND repo_name = $2
+        ORDER BY run_start_timestamp DESC LIMIT $3
+    ) AS t
+)
+`, pfx, pfx), run.RepoNamespace, run.RepoName, keep)
+	if err != nil { return err }
+	return tx.Commit(ctx)
+}
+
+func (dbif *PostgresGitusDatabaseInterface) GetMaintenanceRunList(ns string, name string, pageNum int64, pageSize int64) ([]*model.MaintenanceRun, error) {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	stmt, err := dbif.pool.Query(ctx, fmt.Sprintf(`
+SELECT run_task, run_trigger, run_start_timestamp, run_duration, run_success, run_output
+FROM %s_repo_maintenance
+WHERE repo_namespace = $1 AND repo_name = $2
+ORDER BY run_start_timestamp DESC LIMIT $3 OFFSET $4
+`, pfx), ns, name, pageSize, pageNum*pageSize)
+	if err != nil { return nil, err }
+	defer stmt.Close()
+	res := make([]*model.MaintenanceRun, 0)
+	for stmt.Next() {
+		r := &model.MaintenanceRun{ RepoNamespace: ns, RepoName: name }
+		err = stmt.Scan(&r.Task, &r.Trigger, &r.StartTime, &r.Duration, &r.Success, &r.Output)
+		if err != nil { return nil, err }
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) GetLatestMaintenanceRunList() ([]*model.MaintenanceRun, error) {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	stmt, err := dbif.pool.Query(ctx, fmt.Sprintf(`
+SELECT a.repo_namespace, a.repo_name, a.run_task, a.run_trigger, a.run_start_timestamp, a.run_duration, a.run_success
+FROM %s_repo_maintenance AS a
+WHERE a.run_start_timestamp = (
+    SELECT MAX(b.run_start_timestamp) FROM %s_repo_maintenance AS b
+    WHERE b.repo_namespace = a.repo_namespace AND b.repo_name = a.repo_name AND b.run_task = a.run_task
+)
+`, pfx, pfx))
+	if err != nil { return nil, err }
+	defer stmt.Close()
+	res := make([]*model.MaintenanceRun, 0)
+	for stmt.Next() {
+		r := &model.MaintenanceRun{}
+		err = stmt.Scan(&r.RepoNamespace, &r.RepoName, &r.Task, &r.Trigger, &r.StartTime, &r.Duration, &r.Success)
+		if err != nil { return nil, err }
+		res = append(res, r)
+	}
+	return res, nil
+}
+
 func (dbif *PostgresGitusDatabaseInterface) GetAllUsers(pageNum int64, pageSize int64) ([]*model.GitusUser, error) {
 	pfx := dbif.config.Database.TablePrefix
 	ctx := context.Background()
@@ -1390,6 +1773,23 @@ UPDATE %s_namespace SET ns_acl = jsonb_set(ns_acl, $1, $2) WHERE ns_name = $3
 	return nil
 }
 
+func (dbif *PostgresGitusDatabaseInterface) SetNamespaceRequire2FA(nsName string, require bool) error {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	tx, err := dbif.pool.Begin(ctx)
+	if err != nil { return err }
+	defer tx.Rollback(ctx)
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+UPDATE %s_namespace
+SET ns_acl = jsonb_set(COALESCE(ns_acl, '{"version":"0","acl":{}}'::jsonb), '{require2fa}', to_jsonb($1::boolean))
+WHERE ns_name = $2
+`, pfx), require, nsName)
+	if err != nil { return err }
+	err = tx.Commit(ctx)
+	if err != nil { return err }
+	return nil
+}
+
 func (dbif *PostgresGitusDatabaseInterface) SetRepositoryACL(nsName string, repoName string, targetUserName string, acl *model.ACLTuple) error {
 	pfx := dbif.config.Database.TablePrefix
 	ctx := context.Background()
@@ -2228,7 +2628,7 @@ WHERE receiver_namespace = $1 AND receiver_name = $2 AND pull_request_id = $3
 	}, nil
 }
 
-func (dbif *PostgresGitusDatabaseInterface) CheckPullRequestMergeConflict(absId int64) (*gitlib.MergeCheckResult, error) {
+func (dbif *PostgresGitusDatabaseInterface) CheckPullRequestMergeConflict(ctx context.Context, absId int64) (*gitlib.MergeCheckResult, error) {
 	// WARNING: currently only works when when the source &
 	// the target is git repo. currently (2025.8.27) this check
 	// is performed at the controller side, i.e. users cannot
@@ -2236,8 +2636,9 @@ func (dbif *PostgresGitusDatabaseInterface) CheckPullRequestMergeConflict(absId
 	// code can still be called. DO NOT CALL UNLESS YOU KNOW
 	// WHAT YOU'RE DOING.
 	// TODO: fix this after figuring things out.
+	// the pull request is updated even if the request is gone.
+	ctx = context.WithoutCancel(ctx)
 	pfx := dbif.config.Database.TablePrefix
-	ctx := context.Background()
 	stmt := dbif.pool.QueryRow(ctx, fmt.Sprintf(`
 SELECT receiver_namespace, receiver_name, receiver_branch, provider_namespace, provider_name, provider_branch
 FROM %s_pull_request
@@ -2251,8 +2652,16 @@ WHERE pull_request_absid = $1
 	if err != nil { return nil, err }
 	defer tx.Rollback(ctx)
 	p := path.Join(dbif.config.GitRoot, receiverNamespace, receiverName)
-	lgr := gitlib.NewLocalGitRepository(p)
-	remoteName := fmt.Sprintf("%s/%s", providerNamespace, providerName)
+	lgr := gitlib.NewLocalGitRepository(p).WithContext(ctx, gitlog.RequestIDEnvOf(ctx)...)
+	// pull requests between branches of the same repository don't
+	// need a remote; the remote of other repositories is added when
+	// missing (forks have theirs added when forked).
+	remoteName := ""
+	if providerNamespace != receiverNamespace || providerName != receiverName {
+		remoteName = fmt.Sprintf("%s/%s", providerNamespace, providerName)
+		err = lgr.SetUpMergeTarget(remoteName, path.Join(dbif.config.GitRoot, providerNamespace, providerName))
+		if err != nil { return nil, err }
+	}
 	mr, err := lgr.CheckBranchMergeConflict(receiverBranch, remoteName, providerBranch)
 	if err != nil { return nil, err }
 	mrstr, err := json.Marshal(mr)
@@ -2275,6 +2684,10 @@ func (dbif *PostgresGitusDatabaseInterface) DeletePullRequest(absId int64) error
 	if err != nil { return err }
 	defer tx.Rollback(ctx)
 	_, err = tx.Exec(ctx, fmt.Sprintf(`
+DELETE FROM %s_pull_request_head WHERE pull_request_absid = $1
+`, pfx), absId)
+	if err != nil { return err }
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
 DELETE FROM %s_pull_request WHERE pull_request_absid = $1
 `, pfx), absId)
 	if err != nil { return err }
@@ -2312,7 +2725,7 @@ ORDER BY event_timestamp ASC LIMIT $2 OFFSET $3
 	return res, nil
 }
 
-func (dbif *PostgresGitusDatabaseInterface) CheckAndMergePullRequest(absId int64, username string) error {
+func (dbif *PostgresGitusDatabaseInterface) CheckAndMergePullRequest(ctx context.Context, absId int64, username string) error {
 	// WARNING: currently only works when when the source &
 	// the target is git repo. currently (2025.8.27) this check
 	// is performed at the controller side, i.e. users cannot
@@ -2322,52 +2735,21 @@ func (dbif *PostgresGitusDatabaseInterface) CheckAndMergePullRequest(absId int64
 	// TODO: fix this after figuring things out. (doing the
 	// following possibly bad for performance?) this would
 	// need to be fixed in the future...
-	r, err := dbif.CheckPullRequestMergeConflict(absId)
+	// the merge isn't stopped half-way when the request is gone.
+	ctx = context.WithoutCancel(ctx)
+	r, err := dbif.CheckPullRequestMergeConflict(ctx, absId)
 	if err != nil { return err }
 	if !r.Successful { return nil }
 	pfx := dbif.config.Database.TablePrefix
-	ctx := context.Background()
 	stmt0 := dbif.pool.QueryRow(ctx, fmt.Sprintf(`
 SELECT user_email, user_title FROM %s_user WHERE user_name = $1
 `, pfx), username)
 	var email, userTitle string
 	err = stmt0.Scan(&email, &userTitle)
 	if err != nil { return err }
-	// fetch
-	buf := new(bytes.Buffer)
-	cmd1 := exec.Command("git", "fetch", r.ProviderRemoteName, r.ProviderBranch)
-	cmd1.Dir = r.ReceiverLocation
-	cmd1.Stderr = buf
-	err = cmd1.Run()
-	if err != nil { return errors.New(err.Error() + ": " + buf.String()) }
-	buf.Reset()
-	providerFullName := fmt.Sprintf("%s/%s", r.ProviderRemoteName, r.ProviderBranch)
-	cmd2 := exec.Command("git", "merge-tree", "--write-tree", r.ReceiverBranch, providerFullName)
-	cmd2.Dir = r.ReceiverLocation
-	cmd2.Stdout = buf
-	err = cmd2.Run()
-	if err != nil { return fmt.Errorf("Failed while merge-tree: %s", err.Error()) }
-	treeId := strings.TrimSpace(buf.String())
-	mergeMessage := fmt.Sprintf("merge: from %s/%s to %s", r.ProviderRemoteName, r.ProviderBranch, r.ReceiverBranch)
-	buf.Reset()
-	cmd3 := exec.Command("git", "commit-tree", treeId, "-m", mergeMessage, "-p", r.ReceiverBranch, "-p", providerFullName)
-	cmd3.Dir = r.ReceiverLocation
-	cmd3.Stdout = buf
-	cmd3.Env = os.Environ()
-	cmd3.Env = append(cmd3.Env, fmt.Sprintf("GIT_AUTHOR_NAME=%s", userTitle))
-	cmd3.Env = append(cmd3.Env, fmt.Sprintf("GIT_AUTHOR_EMAIL=%s", email))
-	cmd3.Env = append(cmd3.Env, fmt.Sprintf("GIT_COMMITTER_NAME=%s", userTitle))
-	cmd3.Env = append(cmd3.Env, fmt.Sprintf("GIT_COMMITTER_EMAIL=%s", email))
-	err = cmd3.Run()
-	if err != nil { return fmt.Errorf("Failed while commit-tree: %s", err.Error()) }
-	commitId := strings.TrimSpace(buf.String())
-	buf.Reset()
-	receiverBranchFullName := fmt.Sprintf("refs/heads/%s", r.ReceiverBranch)
-	cmd4 := exec.Command("git", "update-ref", receiverBranchFullName, commitId)
-	cmd4.Dir = r.ReceiverLocation
-	cmd4.Stderr = buf
-	err = cmd4.Run()
-	if err != nil { return fmt.Errorf("Failed while update-ref: %s; %s", err.Error(), buf.String()) }
+	lgr := gitlib.NewLocalGitRepository(r.ReceiverLocation).WithContext(ctx, gitlog.RequestIDEnvOf(ctx)...)
+	err = lgr.Merge(r.ProviderRemoteName, r.ProviderBranch, r.ReceiverBranch, userTitle, email)
+	if err != nil { return err }
 	tx, err := dbif.pool.Begin(ctx)
 	if err != nil { return err }
 	defer tx.Rollback(ctx)
@@ -2377,7 +2759,7 @@ UPDATE %s_pull_request SET pull_request_status = $1, pull_request_timestamp = $2
 `, pfx), model.PULL_REQUEST_CLOSED_AS_MERGED, t, absId)
 	if err != nil { return err }
 	_, err = tx.Exec(ctx, fmt.Sprintf(`
-INSERT INTO %s_pull_request_event(pull_request_abs_id, event_type, event_timestamp, event_author, event_content)
+INSERT INTO %s_pull_request_event(pull_request_absid, event_type, event_timestamp, event_author, event_content)
 VALUES ($1,$2,$3,$4,$5)
 `, pfx), absId, model.PULL_REQUEST_EVENT_CLOSE_AS_MERGED, t, username, "")
 	if err != nil { return err }
@@ -2433,6 +2815,81 @@ VALUES ($1,$2,$3,$4,$5)
 	}, nil
 }
 
+func (dbif *PostgresGitusDatabaseInterface) RecordPullRequestBranchUpdate(absId int64, author string, commitId string) (*model.PullRequestEvent, error) {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	tx, err := dbif.pool.Begin(ctx)
+	if err != nil { return nil, err }
+	defer tx.Rollback(ctx)
+	t := time.Now().Unix()
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+INSERT INTO %s_pull_request_event(pull_request_absid, event_type, event_timestamp, event_author, event_content) VALUES ($1,$2,$3,$4,$5)
+`, pfx), absId, model.PULL_REQUEST_EVENT_UPDATE_ON_BRANCH, t, author, commitId)
+	if err != nil { return nil, err }
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+INSERT INTO %s_pull_request_head(pull_request_absid, head_commit_id) VALUES ($1, $2)
+ON CONFLICT (pull_request_absid) DO UPDATE SET head_commit_id = excluded.head_commit_id
+`, pfx), absId, commitId)
+	if err != nil { return nil, err }
+	err = tx.Commit(ctx)
+	if err != nil { return nil, err }
+	return &model.PullRequestEvent{
+		PRAbsId: absId,
+		EventType: model.PULL_REQUEST_EVENT_UPDATE_ON_BRANCH,
+		EventTimestamp: t,
+		EventAuthor: author,
+		EventContent: commitId,
+	}, nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) GetPullRequestProviderHead(absId int64) (string, error) {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	var res string
+	err := dbif.pool.QueryRow(ctx, fmt.Sprintf(`
+SELECT head_commit_id FROM %s_pull_request_head WHERE pull_request_absid = $1
+`, pfx), absId).Scan(&res)
+	if errors.Is(err, pgx.ErrNoRows) { return "", nil }
+	if err != nil { return "", err }
+	return res, nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) SetPullRequestProviderHead(absId int64, commitId string) error {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	_, err := dbif.pool.Exec(ctx, fmt.Sprintf(`
+INSERT INTO %s_pull_request_head(pull_request_absid, head_commit_id) VALUES ($1, $2)
+ON CONFLICT (pull_request_absid) DO UPDATE SET head_commit_id = excluded.head_commit_id
+`, pfx), absId, commitId)
+	return err
+}
+
+func (dbif *PostgresGitusDatabaseInterface) GetOpenPullRequestOfProvider(providerNamespace string, providerName string) ([]*model.PullRequest, error) {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	stmt, err := dbif.pool.Query(ctx, fmt.Sprintf(`
+SELECT pull_request_absid, author_username, pull_request_id, title, receiver_namespace, receiver_name, receiver_branch, provider_branch, pull_request_timestamp
+FROM %s_pull_request
+WHERE provider_namespace = $1 AND provider_name = $2 AND pull_request_status = $3
+`, pfx), providerNamespace, providerName, model.PULL_REQUEST_OPEN)
+	if err != nil { return nil, err }
+	defer stmt.Close()
+	res := make([]*model.PullRequest, 0)
+	for stmt.Next() {
+		pr := &model.PullRequest{
+			ProviderNamespace: providerNamespace,
+			ProviderName: providerName,
+			Status: model.PULL_REQUEST_OPEN,
+		}
+		var timestamp time.Time
+		err = stmt.Scan(&pr.PRAbsId, &pr.Author, &pr.PRId, &pr.Title, &pr.ReceiverNamespace, &pr.ReceiverName, &pr.ReceiverBranch, &pr.ProviderBranch, &timestamp)
+		if err != nil { return nil, err }
+		pr.Timestamp = timestamp.Unix()
+		res = append(res, pr)
+	}
+	return res, nil
+}
+
 func (dbif *PostgresGitusDatabaseInterface) ClosePullRequestAsNotMerged(absid int64, author string) error {
 	pfx := dbif.config.Database.TablePrefix
 	ctx := context.Background()
@@ -3329,4 +3786,245 @@ WHERE uuid = $1
 	return webhookRes, nil
 }
 
+func (dbif *PostgresGitusDatabaseInterface) CountWebhookResultByStatus() (map[uint8]int64, error) {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	rs, err := dbif.pool.Query(ctx, fmt.Sprintf(`
+SELECT COALESCE((webhook_result->>'status')::INTEGER, 0), COUNT(*)
+FROM %s_webhook_log
+GROUP BY 1
+`, pfx))
+	if err != nil { return nil, err }
+	defer rs.Close()
+	res := make(map[uint8]int64, 0)
+	for rs.Next() {
+		var status int32
+		var count int64
+		err = rs.Scan(&status, &count)
+		if err != nil { return nil, err }
+		res[uint8(status)] += count
+	}
+	return res, rs.Err()
+}
+
+func (dbif *PostgresGitusDatabaseInterface) AppendAuditLog(entry *model.AuditLogEntry) error {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	return dbif.pool.QueryRow(ctx, fmt.Sprintf(`
+INSERT INTO %s_audit_log(audit_timestamp, audit_actor, audit_ip, audit_action, audit_target, audit_diff)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING audit_absid
+`, pfx), entry.Timestamp, entry.Actor, entry.IP, entry.Action, entry.Target, entry.Diff).Scan(&entry.AbsId)
+}
+
+func pgPlaceholder(i int) string { return fmt.Sprintf("$%d", i) }
+
+func (dbif *PostgresGitusDatabaseInterface) CountAuditLog(filter *model.AuditLogFilter) (int64, error) {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	cond, args := db.BuildAuditLogCondition(filter, pgPlaceholder)
+	var res int64
+	err := dbif.pool.QueryRow(ctx, fmt.Sprintf(`
+SELECT COUNT(*) FROM %s_audit_log WHERE %s
+`, pfx, cond), args...).Scan(&res)
+	if err != nil { return 0, err }
+	return res, nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) SearchAuditLogPaginated(filter *model.AuditLogFilter, pageNum int64, pageSize int64) ([]*model.AuditLogEntry, error) {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	cond, args := db.BuildAuditLogCondition(filter, pgPlaceholder)
+	args = append(args, pageSize, pageNum * pageSize)
+	rs, err := dbif.pool.Query(ctx, fmt.Sprintf(`
+SELECT audit_absid, audit_timestamp, audit_actor, audit_ip, audit_action, audit_target, audit_diff
+FROM %s_audit_log
+WHERE %s
+ORDER BY audit_absid DESC LIMIT $%d OFFSET $%d
+`, pfx, cond, len(args)-1, len(args)), args...)
+	if err != nil { return nil, err }
+	defer rs.Close()
+	res := make([]*model.AuditLogEntry, 0)
+	for rs.Next() {
+		e := new(model.AuditLogEntry)
+		err = rs.Scan(&e.AbsId, &e.Timestamp, &e.Actor, &e.IP, &e.Action, &e.Target, &e.Diff)
+		if err != nil { return nil, err }
+		res = append(res, e)
+	}
+	return res, rs.Err()
+}
+
+func (dbif *PostgresGitusDatabaseInterface) GetQuota(subjectType string, name string) (*model.Quota, error) {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	stmt := dbif.pool.QueryRow(ctx, fmt.Sprintf(`
+SELECT repository_size, lfs_size, snippet_size, repository_count
+FROM %s_quota
+WHERE subject_type = $1 AND subject_name = $2
+`, pfx), subjectType, name)
+	res := &model.Quota{}
+	err := stmt.Scan(&res.RepositorySize, &res.LFSSize, &res.SnippetSize, &res.RepositoryCount)
+	if errors.Is(err, pgx.ErrNoRows) { return nil, db.ErrEntityNotFound }
+	if err != nil { return nil, err }
+	return res, nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) SetQuota(subjectType string, name string, quota *model.Quota) error {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	tx, err := dbif.pool.Begin(ctx)
+	if err != nil { return err }
+	defer tx.Rollback(ctx)
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+INSERT INTO %s_quota(subject_type, subject_name, repository_size, lfs_size, snippet_size, repository_count)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (subject_type, subject_name) DO UPDATE SET
+    repository_size = excluded.repository_size,
+    lfs_size = excluded.lfs_size,
+    snippet_size = excluded.snippet_size,
+    repository_count = excluded.repository_count
+`, pfx), subjectType, name, quota.RepositorySize, quota.LFSSize, quota.SnippetSize, quota.RepositoryCount)
+	if err != nil { return err }
+	return tx.Commit(ctx)
+}
+
+func (dbif *PostgresGitusDatabaseInterface) RemoveQuota(subjectType string, name string) error {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	_, err := dbif.pool.Exec(ctx, fmt.Sprintf(`
+DELETE FROM %s_quota WHERE subject_type = $1 AND subject_name = $2
+`, pfx), subjectType, name)
+	return err
+}
+
+func (dbif *PostgresGitusDatabaseInterface) UpdateRepositorySize(ns string, name string, size int64) error {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	_, err := dbif.pool.Exec(ctx, fmt.Sprintf(`
+INSERT INTO %s_repo_size(repo_namespace, repo_name, repo_size, update_timestamp)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (repo_namespace, repo_name) DO UPDATE SET
+    repo_size = excluded.repo_size,
+    update_timestamp = excluded.update_timestamp
+`, pfx), ns, name, size, time.Now().Unix())
+	return err
+}
+
+func (dbif *PostgresGitusDatabaseInterface) GetRepositorySize(ns string, name string) (int64, error) {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	var res int64
+	err := dbif.pool.QueryRow(ctx, fmt.Sprintf(`
+SELECT repo_size FROM %s_repo_size
+WHERE repo_namespace = $1 AND repo_name = $2
+`, pfx), ns, name).Scan(&res)
+	if errors.Is(err, pgx.ErrNoRows) { return 0, nil }
+	if err != nil { return 0, err }
+	return res, nil
+}
 
+func (dbif *PostgresGitusDatabaseInterface) GetQuotaUsage(subjectType string, name string) (*model.QuotaUsage, error) {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	cond := "1 = 1"
+	args := make([]any, 0)
+	switch subjectType {
+	case model.QUOTA_SUBJECT_SITE:
+	case model.QUOTA_SUBJECT_USER: cond = "r.repo_owner = $1"; args = append(args, name)
+	case model.QUOTA_SUBJECT_NAMESPACE: cond = "r.repo_namespace = $1"; args = append(args, name)
+	default: return nil, fmt.Errorf("Unknown quota subject type: %s", subjectType)
+	}
+	res := &model.QuotaUsage{}
+	err := dbif.pool.QueryRow(ctx, fmt.Sprintf(`
+SELECT COUNT(*), COALESCE(SUM(s.repo_size), 0)::BIGINT
+FROM %s_repository AS r
+LEFT JOIN %s_repo_size AS s
+    ON s.repo_namespace = r.repo_namespace AND s.repo_name = r.repo_name
+WHERE %s
+`, pfx, pfx, cond), args...).Scan(&res.RepositoryCount, &res.RepositorySize)
+	if err != nil { return nil, err }
+	err = dbif.pool.QueryRow(ctx, fmt.Sprintf(`
+SELECT COALESCE(SUM(l.size), 0)::BIGINT
+FROM %s_lfs_object AS l
+JOIN %s_repository AS r
+    ON l.repo_namespace = r.repo_namespace AND l.repo_name = r.repo_name
+WHERE %s
+`, pfx, pfx, cond), args...).Scan(&res.LFSSize)
+	if err != nil { return nil, err }
+	return res, nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) GetForkRepositoryList(originNamespace string, originName string) ([]*model.Repository, error) {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	stmt, err := dbif.pool.Query(ctx, fmt.Sprintf(`
+SELECT repo_type, repo_namespace, repo_name, repo_description, repo_acl, repo_owner, repo_status, repo_label_list, repo_absid
+FROM %s_repository
+WHERE repo_fork_origin_namespace = $1 AND repo_fork_origin_name = $2
+ORDER BY repo_absid ASC
+`, pfx), originNamespace, originName)
+	if err != nil { return nil, err }
+	defer stmt.Close()
+	var ns, name, desc, acl, owner, labelList string
+	var status int
+	var rowid int64
+	var repoType uint8
+	res := make([]*model.Repository, 0)
+	for stmt.Next() {
+		err = stmt.Scan(&repoType, &ns, &name, &desc, &acl, &owner, &status, &labelList, &rowid)
+		if err != nil { return nil, err }
+		p := path.Join(dbif.config.GitRoot, ns, name)
+		lr, err := model.CreateLocalRepository(repoType, ns, name, p)
+		if err != nil { return nil, err }
+		var tags []string = nil
+		if len(labelList) > 0 {
+			tags = strings.Split(labelList[1:len(labelList)-1], "}{")
+		}
+		aclobj, err := model.ParseACL(acl)
+		if err != nil { return nil, err }
+		res = append(res, &model.Repository{
+			AbsId: rowid,
+			Type: repoType,
+			Namespace: ns,
+			Name: name,
+			Owner: owner,
+			Description: desc,
+			AccessControlList: aclobj,
+			Status: model.GitusRepositoryStatus(status),
+			Repository: lr,
+			ForkOriginNamespace: originNamespace,
+			ForkOriginName: originName,
+			RepoLabelList: tags,
+		})
+	}
+	return res, nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) GetForkAutoSync(ns string, name string) (bool, error) {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	var res int64
+	err := dbif.pool.QueryRow(ctx, fmt.Sprintf(`
+SELECT COUNT(*) FROM %s_fork_sync
+WHERE repo_namespace = $1 AND repo_name = $2
+`, pfx), ns, name).Scan(&res)
+	if err != nil { return false, err }
+	return res > 0, nil
+}
+
+func (dbif *PostgresGitusDatabaseInterface) SetForkAutoSync(ns string, name string, enable bool) error {
+	pfx := dbif.config.Database.TablePrefix
+	ctx := context.Background()
+	var err error
+	if enable {
+		_, err = dbif.pool.Exec(ctx, fmt.Sprintf(`
+INSERT INTO %s_fork_sync(repo_namespace, repo_name) VALUES ($1, $2)
+ON CONFLICT (repo_namespace, repo_name) DO NOTHING
+`, pfx), ns, name)
+	} else {
+		_, err = dbif.pool.Exec(ctx, fmt.Sprintf(`
+DELETE FROM %s_fork_sync WHERE repo_namespace = $1 AND repo_name = $2
+`, pfx), ns, name)
+	}
+	return err
+}
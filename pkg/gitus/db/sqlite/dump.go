@@ -0,0 +1,167 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+)
+
+// see pkg/gitus/db/dump.go & docs/backup.org.
+
+// the neutral columns that are named differently in sqlite. an empty
+// string means the column doesn't exist in sqlite.
+var dumpColumnName = map[string]map[string]string{
+	"user_reg_request": {
+		"request_absid": "rowid",
+		// NOTE: the `timestamp` column of this table was never
+		// created in sqlite due to a missing comma in InstallTables.
+		"timestamp": "",
+	},
+	"issue": {
+		"issue_absid": "rowid",
+	},
+	"issue_event": {
+		"issue_event_absid": "rowid",
+		"issue_absid": "issue_abs_id",
+	},
+	"pull_request": {
+		"pull_request_absid": "rowid",
+		"author_username": "username",
+	},
+	"pull_request_event": {
+		"pull_request_absid": "pull_request_abs_id",
+	},
+	"pull_request_head": {
+		"pull_request_absid": "pull_request_abs_id",
+	},
+	"audit_log": {
+		"audit_absid": "rowid",
+	},
+}
+
+func getDumpColumnName(table string, column string) string {
+	m, ok := dumpColumnName[table]
+	if !ok { return column }
+	s, ok := m[column]
+	if !ok { return column }
+	return s
+}
+
+type derivedColumn struct {
+	name string
+	value func(row map[string]any) any
+}
+
+func dumpRowString(row map[string]any, k string) string {
+	s, _ := row[k].(string)
+	return s
+}
+
+// the sqlite-only columns that are computed from the others when
+// importing.
+var dumpDerivedColumn = map[string][]derivedColumn{
+	"repository": {
+		{"repo_fullname", func(row map[string]any) any {
+			return dumpRowString(row, "repo_namespace") + ":" + dumpRowString(row, "repo_name")
+		}},
+	},
+	"snippet": {
+		{"snippet_full_name", func(row map[string]any) any {
+			return dumpRowString(row, "username") + ":" + dumpRowString(row, "name")
+		}},
+	},
+}
+
+func (dbif *SqliteGitusDatabaseInterface) ExportDump(w *db.DumpWriter) error {
+	pfx := dbif.config.Database.TablePrefix
+	// everything is read within one transaction so that the dump is
+	// consistent.
+	tx, err := dbif.connection.Begin()
+	if err != nil { return err }
+	defer tx.Rollback()
+	for _, t := range db.DumpTableList {
+		columnList := make([]*db.DumpColumn, 0)
+		selectList := make([]string, 0)
+		for _, c := range t.Column {
+			n := getDumpColumnName(t.Name, c.Name)
+			if len(n) <= 0 { continue }
+			columnList = append(columnList, c)
+			selectList = append(selectList, n)
+		}
+		err := func() error {
+			rows, err := tx.Query(fmt.Sprintf(
+				"SELECT %s FROM %s_%s ORDER BY rowid ASC",
+				strings.Join(selectList, ", "), pfx, t.Name,
+			))
+			if err != nil { return err }
+			defer rows.Close()
+			val := make([]any, len(columnList))
+			ptr := make([]any, len(columnList))
+			for i := range val { ptr[i] = &val[i] }
+			for rows.Next() {
+				err = rows.Scan(ptr...)
+				if err != nil { return err }
+				row := make(map[string]any, len(columnList))
+				for i, c := range columnList {
+					v, err := db.ToDumpValue(c.Type, val[i])
+					if err != nil { return fmt.Errorf("%s.%s: %w", t.Name, c.Name, err) }
+					row[c.Name] = v
+				}
+				err = w.Write(t.Name, row)
+				if err != nil { return err }
+			}
+			return rows.Err()
+		}()
+		if err != nil { return err }
+	}
+	return nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) ImportDump(r *db.DumpReader) error {
+	pfx := dbif.config.Database.TablePrefix
+	tx, err := dbif.connection.Begin()
+	if err != nil { return err }
+	defer tx.Rollback()
+	stmtCache := make(map[string]*sql.Stmt, 0)
+	for {
+		rec, err := r.Next()
+		if err == io.EOF { break }
+		if err != nil { return err }
+		t := db.GetDumpTable(rec.Table)
+		columnList := make([]string, 0)
+		argList := make([]any, 0)
+		for _, c := range t.Column {
+			v, ok := rec.Row[c.Name]
+			if !ok { continue }
+			n := getDumpColumnName(t.Name, c.Name)
+			if len(n) <= 0 { continue }
+			gv, err := db.FromDumpValue(c.Type, v)
+			if err != nil { return fmt.Errorf("%s.%s: %w", t.Name, c.Name, err) }
+			columnList = append(columnList, n)
+			argList = append(argList, gv)
+		}
+		for _, d := range dumpDerivedColumn[t.Name] {
+			columnList = append(columnList, d.name)
+			argList = append(argList, d.value(rec.Row))
+		}
+		if len(columnList) <= 0 { continue }
+		q := fmt.Sprintf(
+			"INSERT INTO %s_%s(%s) VALUES (%s)",
+			pfx, t.Name, strings.Join(columnList, ", "),
+			strings.TrimSuffix(strings.Repeat("?,", len(columnList)), ","),
+		)
+		stmt, ok := stmtCache[q]
+		if !ok {
+			stmt, err = tx.Prepare(q)
+			if err != nil { return err }
+			defer stmt.Close()
+			stmtCache[q] = stmt
+		}
+		_, err = stmt.Exec(argList...)
+		if err != nil { return fmt.Errorf("Failed to import into %s: %w", t.Name, err) }
+	}
+	return tx.Commit()
+}
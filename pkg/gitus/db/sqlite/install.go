@@ -226,8 +226,11 @@ CREATE TABLE IF NOT EXISTS %s_webhook_log (
     webhook_result TEXT
 )`, pfx))
 	if err != nil { return err }
-	
-	tx.Commit()
-	return nil
+	err = dbif.initSchemaVersion(tx)
+	if err != nil { return err }
+	err = tx.Commit()
+	if err != nil { return err }
+	_, err = dbif.Migrate()
+	return err
 }
 
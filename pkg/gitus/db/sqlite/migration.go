@@ -0,0 +1,249 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+)
+
+// NOTE: migrations must never be changed once released; add a new
+// one instead. see docs/migration.org.
+func (dbif *SqliteGitusDatabaseInterface) GetMigrationList() []*db.Migration {
+	pfx := dbif.config.Database.TablePrefix
+	return []*db.Migration{
+		&db.Migration{
+			Version: 1,
+			Description: "Initial schema",
+		},
+		&db.Migration{
+			Version: 2,
+			Description: "Add WebAuthn credential table",
+			Statement: []string{fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s_user_webauthn (
+    user_name TEXT,
+    credential_id TEXT UNIQUE,
+    credential_name TEXT,
+    public_key BLOB,
+    sign_count INTEGER,
+    passkey INTEGER,
+    reg_timestamp INTEGER,
+    last_used_timestamp INTEGER,
+    FOREIGN KEY (user_name) REFERENCES %s_user(user_name)
+)`, pfx, pfx)},
+		},
+		&db.Migration{
+			Version: 3,
+			Description: "Add OpenID Connect account link table",
+			Statement: []string{fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s_user_oidc (
+    provider_id TEXT,
+    subject TEXT,
+    user_name TEXT,
+    link_timestamp INTEGER,
+    UNIQUE (provider_id, subject),
+    FOREIGN KEY (user_name) REFERENCES %s_user(user_name)
+)`, pfx, pfx)},
+		},
+		&db.Migration{
+			Version: 4,
+			Description: "Add audit log table",
+			Statement: []string{
+				fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s_audit_log (
+    audit_timestamp INTEGER,
+    audit_actor TEXT,
+    audit_ip TEXT,
+    audit_action TEXT,
+    audit_target TEXT,
+    audit_diff TEXT
+)`, pfx),
+				fmt.Sprintf(`
+CREATE INDEX IF NOT EXISTS %s_audit_log_timestamp ON %s_audit_log(audit_timestamp)
+`, pfx, pfx),
+				// the audit log is append-only.
+				fmt.Sprintf(`
+CREATE TRIGGER IF NOT EXISTS %s_audit_log_no_update BEFORE UPDATE ON %s_audit_log
+BEGIN SELECT RAISE(ABORT, 'the audit log is append-only'); END
+`, pfx, pfx),
+				fmt.Sprintf(`
+CREATE TRIGGER IF NOT EXISTS %s_audit_log_no_delete BEFORE DELETE ON %s_audit_log
+BEGIN SELECT RAISE(ABORT, 'the audit log is append-only'); END
+`, pfx, pfx),
+			},
+		},
+		&db.Migration{
+			Version: 5,
+			Description: "Add personal access token table",
+			Statement: []string{fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s_user_access_token (
+    user_name TEXT,
+    token_name TEXT,
+    token_hash TEXT UNIQUE,
+    reg_timestamp INTEGER,
+    last_used_timestamp INTEGER,
+    expire_timestamp INTEGER,
+    UNIQUE (user_name, token_name),
+    FOREIGN KEY (user_name) REFERENCES %s_user(user_name)
+)`, pfx, pfx)},
+		},
+		&db.Migration{
+			Version: 6,
+			Description: "Add Git LFS object table",
+			Statement: []string{fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s_lfs_object (
+    repo_namespace TEXT,
+    repo_name TEXT,
+    oid TEXT,
+    size INTEGER,
+    upload_timestamp INTEGER,
+    UNIQUE (repo_namespace, repo_name, oid),
+    FOREIGN KEY (repo_namespace, repo_name)
+      REFERENCES %s_repository(repo_namespace, repo_name)
+)`, pfx, pfx)},
+		},
+		&db.Migration{
+			Version: 7,
+			Description: "Add repository maintenance run table",
+			Statement: []string{
+				fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s_repo_maintenance (
+    repo_namespace TEXT,
+    repo_name TEXT,
+    run_task TEXT,
+    run_trigger TEXT,
+    run_start_timestamp INTEGER,
+    run_duration INTEGER,
+    run_success INTEGER,
+    run_output TEXT,
+    FOREIGN KEY (repo_namespace, repo_name)
+      REFERENCES %s_repository(repo_namespace, repo_name)
+)`, pfx, pfx),
+				fmt.Sprintf(`
+CREATE INDEX IF NOT EXISTS %s_repo_maintenance_repo ON %s_repo_maintenance(repo_namespace, repo_name, run_task, run_start_timestamp)
+`, pfx, pfx),
+			},
+		},
+		&db.Migration{
+			Version: 8,
+			Description: "Add quota & repository size tables",
+			Statement: []string{
+				fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s_quota (
+    subject_type TEXT,
+    subject_name TEXT,
+    repository_size INTEGER,
+    lfs_size INTEGER,
+    snippet_size INTEGER,
+    repository_count INTEGER,
+    UNIQUE (subject_type, subject_name)
+)`, pfx),
+				fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s_repo_size (
+    repo_namespace TEXT,
+    repo_name TEXT,
+    repo_size INTEGER,
+    update_timestamp INTEGER,
+    UNIQUE (repo_namespace, repo_name),
+    FOREIGN KEY (repo_namespace, repo_name)
+      REFERENCES %s_repository(repo_namespace, repo_name)
+)`, pfx, pfx),
+			},
+		},
+		&db.Migration{
+			Version: 9,
+			Description: "Add fork auto-sync table",
+			Statement: []string{
+				fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s_fork_sync (
+    repo_namespace TEXT,
+    repo_name TEXT,
+    UNIQUE (repo_namespace, repo_name),
+    FOREIGN KEY (repo_namespace, repo_name)
+      REFERENCES %s_repository(repo_namespace, repo_name)
+)`, pfx, pfx),
+			},
+		},
+		&db.Migration{
+			Version: 10,
+			Description: "Add pull request provider head table",
+			Statement: []string{
+				fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s_pull_request_head (
+    pull_request_abs_id INTEGER UNIQUE,
+    head_commit_id TEXT,
+    FOREIGN KEY (pull_request_abs_id) REFERENCES %s_pull_request(rowid)
+)`, pfx, pfx),
+			},
+		},
+	}
+}
+
+// creates the version table & records the initial schema if it's not
+// there yet.
+func (dbif *SqliteGitusDatabaseInterface) initSchemaVersion(tx *sql.Tx) error {
+	pfx := dbif.config.Database.TablePrefix
+	_, err := tx.Exec(fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s_schema_version (
+    version INTEGER UNIQUE,
+    description TEXT,
+    applied_timestamp INTEGER
+)`, pfx))
+	if err != nil { return err }
+	_, err = tx.Exec(fmt.Sprintf(`
+INSERT INTO %s_schema_version(version, description, applied_timestamp)
+SELECT 1, ?, ? WHERE NOT EXISTS (SELECT 1 FROM %s_schema_version)
+`, pfx, pfx), "Initial schema", time.Now().Unix())
+	return err
+}
+
+func (dbif *SqliteGitusDatabaseInterface) GetSchemaVersion() (int, error) {
+	pfx := dbif.config.Database.TablePrefix
+	var a int
+	err := dbif.connection.QueryRow("SELECT 1 FROM sqlite_schema WHERE type = 'table' AND name = ?", pfx + "_schema_version").Scan(&a)
+	if err == sql.ErrNoRows {
+		b, err := dbif.IsDatabaseUsable()
+		if err != nil { return 0, err }
+		if b { return 1, nil }
+		return 0, nil
+	}
+	if err != nil { return 0, err }
+	var v sql.NullInt64
+	err = dbif.connection.QueryRow(fmt.Sprintf("SELECT MAX(version) FROM %s_schema_version", pfx)).Scan(&v)
+	if err != nil { return 0, err }
+	if !v.Valid { return 1, nil }
+	return int(v.Int64), nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) Migrate() ([]*db.Migration, error) {
+	pfx := dbif.config.Database.TablePrefix
+	v, err := dbif.GetSchemaVersion()
+	if err != nil { return nil, err }
+	if v <= 0 { return nil, db.ErrDatabaseNotInstalled }
+	res := make([]*db.Migration, 0)
+	for _, m := range dbif.GetMigrationList() {
+		if m.Version <= v { continue }
+		err := func() error {
+			tx, err := dbif.connection.Begin()
+			if err != nil { return err }
+			defer tx.Rollback()
+			err = dbif.initSchemaVersion(tx)
+			if err != nil { return err }
+			for _, stmt := range m.Statement {
+				_, err = tx.Exec(stmt)
+				if err != nil { return err }
+			}
+			_, err = tx.Exec(fmt.Sprintf(`
+INSERT INTO %s_schema_version(version, description, applied_timestamp) VALUES (?,?,?)
+`, pfx), m.Version, m.Description, time.Now().Unix())
+			if err != nil { return err }
+			return tx.Commit()
+		}()
+		if err != nil {
+			return res, fmt.Errorf("Failed to apply migration %d (%s): %w", m.Version, m.Description, err)
+		}
+		res = append(res, m)
+	}
+	return res, nil
+}
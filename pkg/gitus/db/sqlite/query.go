@@ -1,20 +1,19 @@
 package sqlite
 
 import (
-	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/fs"
 	"os"
-	"os/exec"
 	"path"
 	"slices"
 	"strings"
 	"time"
 
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	gitlog "github.com/GitusCodeForge/Gitus/pkg/gitus/log"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	"github.com/GitusCodeForge/Gitus/pkg/gitlib"
 	_ "github.com/mattn/go-sqlite3"
@@ -168,6 +167,262 @@ WHERE user_name = ? AND key_name = ?
 	return nil
 }
 
+func (dbif *SqliteGitusDatabaseInterface) GetAllWebAuthnCredentialByUsername(username string) ([]*model.WebAuthnCredential, error) {
+	pfx := dbif.config.Database.TablePrefix
+	stmt, err := dbif.connection.Prepare(fmt.Sprintf(`
+SELECT credential_id, credential_name, public_key, sign_count, passkey, reg_timestamp, last_used_timestamp
+FROM %s_user_webauthn
+WHERE user_name = ?
+ORDER BY reg_timestamp ASC
+`, pfx))
+	if err != nil { return nil, err }
+	defer stmt.Close()
+	r, err := stmt.Query(username)
+	if err != nil { return nil, err }
+	defer r.Close()
+	res := make([]*model.WebAuthnCredential, 0)
+	for r.Next() {
+		c := &model.WebAuthnCredential{ UserName: username }
+		err = r.Scan(&c.CredentialId, &c.Name, &c.PublicKey, &c.SignCount, &c.Passkey, &c.RegisterTime, &c.LastUsedTime)
+		if err != nil { return nil, err }
+		res = append(res, c)
+	}
+	return res, nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) GetWebAuthnCredential(credentialId string) (*model.WebAuthnCredential, error) {
+	pfx := dbif.config.Database.TablePrefix
+	stmt, err := dbif.connection.Prepare(fmt.Sprintf(`
+SELECT user_name, credential_name, public_key, sign_count, passkey, reg_timestamp, last_used_timestamp
+FROM %s_user_webauthn
+WHERE credential_id = ?
+`, pfx))
+	if err != nil { return nil, err }
+	defer stmt.Close()
+	r := stmt.QueryRow(credentialId)
+	if r.Err() != nil { return nil, r.Err() }
+	c := &model.WebAuthnCredential{ CredentialId: credentialId }
+	err = r.Scan(&c.UserName, &c.Name, &c.PublicKey, &c.SignCount, &c.Passkey, &c.RegisterTime, &c.LastUsedTime)
+	if err == sql.ErrNoRows { return nil, db.ErrEntityNotFound }
+	if err != nil { return nil, err }
+	return c, nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) RegisterWebAuthnCredential(cred *model.WebAuthnCredential) error {
+	pfx := dbif.config.Database.TablePrefix
+	tx, err := dbif.connection.Begin()
+	if err != nil { return err }
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(fmt.Sprintf(`
+INSERT INTO %s_user_webauthn(user_name, credential_id, credential_name, public_key, sign_count, passkey, reg_timestamp, last_used_timestamp)
+VALUES (?,?,?,?,?,?,?,?)
+`, pfx))
+	if err != nil { return err }
+	defer stmt.Close()
+	_, err = stmt.Exec(cred.UserName, cred.CredentialId, cred.Name, cred.PublicKey, cred.SignCount, cred.Passkey, cred.RegisterTime, cred.LastUsedTime)
+	if err != nil { return err }
+	err = tx.Commit()
+	if err != nil { return err }
+	return nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) UpdateWebAuthnCredentialUsage(credentialId string, signCount int64, lastUsedTime int64) error {
+	pfx := dbif.config.Database.TablePrefix
+	tx, err := dbif.connection.Begin()
+	if err != nil { return err }
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(fmt.Sprintf(`
+UPDATE %s_user_webauthn SET sign_count = ?, last_used_timestamp = ? WHERE credential_id = ?
+`, pfx))
+	if err != nil { return err }
+	defer stmt.Close()
+	_, err = stmt.Exec(signCount, lastUsedTime, credentialId)
+	if err != nil { return err }
+	err = tx.Commit()
+	if err != nil { return err }
+	return nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) RemoveWebAuthnCredential(username string, credentialId string) error {
+	pfx := dbif.config.Database.TablePrefix
+	tx, err := dbif.connection.Begin()
+	if err != nil { return err }
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(fmt.Sprintf(`
+DELETE FROM %s_user_webauthn WHERE user_name = ? AND credential_id = ?
+`, pfx))
+	if err != nil { return err }
+	defer stmt.Close()
+	_, err = stmt.Exec(username, credentialId)
+	if err != nil { return err }
+	err = tx.Commit()
+	if err != nil { return err }
+	return nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) GetOIDCLink(providerId string, subject string) (*model.OIDCLink, error) {
+	pfx := dbif.config.Database.TablePrefix
+	stmt, err := dbif.connection.Prepare(fmt.Sprintf(`
+SELECT user_name, link_timestamp FROM %s_user_oidc WHERE provider_id = ? AND subject = ?
+`, pfx))
+	if err != nil { return nil, err }
+	defer stmt.Close()
+	r := stmt.QueryRow(providerId, subject)
+	if r.Err() != nil { return nil, r.Err() }
+	l := &model.OIDCLink{ ProviderId: providerId, Subject: subject }
+	err = r.Scan(&l.UserName, &l.LinkTime)
+	if err == sql.ErrNoRows { return nil, db.ErrEntityNotFound }
+	if err != nil { return nil, err }
+	return l, nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) GetAllOIDCLinkOfUser(username string) ([]*model.OIDCLink, error) {
+	pfx := dbif.config.Database.TablePrefix
+	stmt, err := dbif.connection.Prepare(fmt.Sprintf(`
+SELECT provider_id, subject, link_timestamp FROM %s_user_oidc WHERE user_name = ? ORDER BY link_timestamp ASC
+`, pfx))
+	if err != nil { return nil, err }
+	defer stmt.Close()
+	r, err := stmt.Query(username)
+	if err != nil { return nil, err }
+	defer r.Close()
+	res := make([]*model.OIDCLink, 0)
+	for r.Next() {
+		l := &model.OIDCLink{ UserName: username }
+		err = r.Scan(&l.ProviderId, &l.Subject, &l.LinkTime)
+		if err != nil { return nil, err }
+		res = append(res, l)
+	}
+	return res, nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) RegisterOIDCLink(link *model.OIDCLink) error {
+	pfx := dbif.config.Database.TablePrefix
+	tx, err := dbif.connection.Begin()
+	if err != nil { return err }
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(fmt.Sprintf(`
+INSERT INTO %s_user_oidc(provider_id, subject, user_name, link_timestamp) VALUES (?,?,?,?)
+`, pfx))
+	if err != nil { return err }
+	defer stmt.Close()
+	_, err = stmt.Exec(link.ProviderId, link.Subject, link.UserName, link.LinkTime)
+	if err != nil { return err }
+	err = tx.Commit()
+	if err != nil { return err }
+	return nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) RemoveOIDCLink(username string, providerId string) error {
+	pfx := dbif.config.Database.TablePrefix
+	tx, err := dbif.connection.Begin()
+	if err != nil { return err }
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(fmt.Sprintf(`
+DELETE FROM %s_user_oidc WHERE user_name = ? AND provider_id = ?
+`, pfx))
+	if err != nil { return err }
+	defer stmt.Close()
+	_, err = stmt.Exec(username, providerId)
+	if err != nil { return err }
+	err = tx.Commit()
+	if err != nil { return err }
+	return nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) GetAllAccessTokenByUsername(username string) ([]*model.AccessToken, error) {
+	pfx := dbif.config.Database.TablePrefix
+	stmt, err := dbif.connection.Prepare(fmt.Sprintf(`
+SELECT token_name, token_hash, reg_timestamp, last_used_timestamp, expire_timestamp
+FROM %s_user_access_token
+WHERE user_name = ?
+ORDER BY reg_timestamp ASC
+`, pfx))
+	if err != nil { return nil, err }
+	defer stmt.Close()
+	r, err := stmt.Query(username)
+	if err != nil { return nil, err }
+	defer r.Close()
+	res := make([]*model.AccessToken, 0)
+	for r.Next() {
+		t := &model.AccessToken{ UserName: username }
+		err = r.Scan(&t.Name, &t.TokenHash, &t.RegisterTime, &t.LastUsedTime, &t.ExpireTime)
+		if err != nil { return nil, err }
+		res = append(res, t)
+	}
+	return res, nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) GetAccessTokenByHash(tokenHash string) (*model.AccessToken, error) {
+	pfx := dbif.config.Database.TablePrefix
+	stmt, err := dbif.connection.Prepare(fmt.Sprintf(`
+SELECT user_name, token_name, reg_timestamp, last_used_timestamp, expire_timestamp
+FROM %s_user_access_token
+WHERE token_hash = ?
+`, pfx))
+	if err != nil { return nil, err }
+	defer stmt.Close()
+	r := stmt.QueryRow(tokenHash)
+	if r.Err() != nil { return nil, r.Err() }
+	t := &model.AccessToken{ TokenHash: tokenHash }
+	err = r.Scan(&t.UserName, &t.Name, &t.RegisterTime, &t.LastUsedTime, &t.ExpireTime)
+	if err == sql.ErrNoRows { return nil, db.ErrEntityNotFound }
+	if err != nil { return nil, err }
+	return t, nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) RegisterAccessToken(token *model.AccessToken) error {
+	pfx := dbif.config.Database.TablePrefix
+	tx, err := dbif.connection.Begin()
+	if err != nil { return err }
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(fmt.Sprintf(`
+INSERT INTO %s_user_access_token(user_name, token_name, token_hash, reg_timestamp, last_used_timestamp, expire_timestamp)
+VALUES (?,?,?,?,?,?)
+`, pfx))
+	if err != nil { return err }
+	defer stmt.Close()
+	_, err = stmt.Exec(token.UserName, token.Name, token.TokenHash, token.RegisterTime, token.LastUsedTime, token.ExpireTime)
+	if err != nil { return err }
+	err = tx.Commit()
+	if err != nil { return err }
+	return nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) UpdateAccessTokenUsage(tokenHash string, lastUsedTime int64) error {
+	pfx := dbif.config.Database.TablePrefix
+	tx, err := dbif.connection.Begin()
+	if err != nil { return err }
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(fmt.Sprintf(`
+UPDATE %s_user_access_token SET last_used_timestamp = ? WHERE token_hash = ?
+`, pfx))
+	if err != nil { return err }
+	defer stmt.Close()
+	_, err = stmt.Exec(lastUsedTime, tokenHash)
+	if err != nil { return err }
+	err = tx.Commit()
+	if err != nil { return err }
+	return nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) RemoveAccessToken(username string, tokenName string) error {
+	pfx := dbif.config.Database.TablePrefix
+	tx, err := dbif.connection.Begin()
+	if err != nil { return err }
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(fmt.Sprintf(`
+DELETE FROM %s_user_access_token WHERE user_name = ? AND token_name = ?
+`, pfx))
+	if err != nil { return err }
+	defer stmt.Close()
+	_, err = stmt.Exec(username, tokenName)
+	if err != nil { return err }
+	err = tx.Commit()
+	if err != nil { return err }
+	return nil
+}
+
 func (dbif *SqliteGitusDatabaseInterface) GetAllSignKeyByUsername(name string) ([]model.GitusSigningKey, error) {
 	pfx := dbif.config.Database.TablePrefix
 	stmt, err := dbif.connection.Prepare(fmt.Sprintf(`
@@ -383,6 +638,10 @@ DELETE FROM %s_user WHERE user_name = ?
 `, pfx))
 	if err != nil { return err }
 	_, err = stmt.Exec(name)
+	if err != nil { return err }
+	_, err = tx.Exec(fmt.Sprintf(`
+DELETE FROM %s_quota WHERE subject_type = ? AND subject_name = ?
+`, pfx), model.QUOTA_SUBJECT_USER, name)
 	if err != nil { return err }
 	userNsPath := path.Join(dbif.config.GitRoot, name)
 	err = os.RemoveAll(userNsPath)
@@ -787,6 +1046,26 @@ func (dbif *SqliteGitusDatabaseInterface) HardDeleteNamespaceByName(name string)
 	pfx := dbif.config.Database.TablePrefix
 	tx, err := dbif.connection.Begin()
 	if err != nil { return err }
+	_, err = tx.Exec(fmt.Sprintf(`
+DELETE FROM %s_lfs_object WHERE repo_namespace = ?
+`, pfx), name)
+	if err != nil { tx.Rollback(); return err }
+	_, err = tx.Exec(fmt.Sprintf(`
+DELETE FROM %s_repo_maintenance WHERE repo_namespace = ?
+`, pfx), name)
+	if err != nil { tx.Rollback(); return err }
+	_, err = tx.Exec(fmt.Sprintf(`
+DELETE FROM %s_repo_size WHERE repo_namespace = ?
+`, pfx), name)
+	if err != nil { tx.Rollback(); return err }
+	_, err = tx.Exec(fmt.Sprintf(`
+DELETE FROM %s_fork_sync WHERE repo_namespace = ?
+`, pfx), name)
+	if err != nil { tx.Rollback(); return err }
+	_, err = tx.Exec(fmt.Sprintf(`
+DELETE FROM %s_quota WHERE subject_type = ? AND subject_name = ?
+`, pfx), model.QUOTA_SUBJECT_NAMESPACE, name)
+	if err != nil { tx.Rollback(); return err }
 	stmt, err := tx.Prepare(fmt.Sprintf(`
 DELETE FROM %s_namespace WHERE ns_name = ?
 `, pfx))
@@ -801,7 +1080,7 @@ DELETE FROM %s_namespace WHERE ns_name = ?
 	return nil
 }
 
-func (dbif *SqliteGitusDatabaseInterface) CreateRepository(ns string, name string, repoType uint8, owner string) (*model.Repository, error) {
+func (dbif *SqliteGitusDatabaseInterface) CreateRepository(ns string, name string, repoType uint8, objectFormat gitlib.ObjectFormat, owner string) (*model.Repository, error) {
 	pfx := dbif.config.Database.TablePrefix
 	if !model.ValidNamespaceName(ns) || !model.ValidRepositoryName(name) {
 		return nil, db.ErrInvalidLocation
@@ -827,9 +1106,9 @@ VALUES (?,?,?,?,?,?,?,?,?,?,?,?)
 	if err = os.MkdirAll(p, os.ModeDir|0755); err != nil {
 		return nil, err
 	}
-	lr, err := model.CreateLocalRepository(repoType, ns, name, p)
+	err = model.InitLocalRepository(repoType, p, objectFormat)
 	if err != nil { return nil, err }
-	err = model.InitLocalRepository(lr)
+	lr, err := model.CreateLocalRepository(repoType, ns, name, p)
 	if err != nil { return nil, err }
 	if err = tx.Commit(); err != nil { return nil, err }
 	r, err := model.NewRepository(ns, name, lr)
@@ -953,6 +1232,26 @@ func (dbif *SqliteGitusDatabaseInterface) HardDeleteRepository(ns string, name s
 	pfx := dbif.config.Database.TablePrefix
 	tx, err := dbif.connection.Begin()
 	if err != nil { return err }
+	_, err = tx.Exec(fmt.Sprintf(`
+DELETE FROM %s_lfs_object
+WHERE repo_namespace = ? AND repo_name = ?
+`, pfx), ns, name)
+	if err != nil { tx.Rollback(); return err }
+	_, err = tx.Exec(fmt.Sprintf(`
+DELETE FROM %s_repo_maintenance
+WHERE repo_namespace = ? AND repo_name = ?
+`, pfx), ns, name)
+	if err != nil { tx.Rollback(); return err }
+	_, err = tx.Exec(fmt.Sprintf(`
+DELETE FROM %s_repo_size
+WHERE repo_namespace = ? AND repo_name = ?
+`, pfx), ns, name)
+	if err != nil { tx.Rollback(); return err }
+	_, err = tx.Exec(fmt.Sprintf(`
+DELETE FROM %s_fork_sync
+WHERE repo_namespace = ? AND repo_name = ?
+`, pfx), ns, name)
+	if err != nil { tx.Rollback(); return err }
 	stmt, err := tx.Prepare(fmt.Sprintf(`
 DELETE FROM %s_repository
 WHERE repo_namespace = ? AND repo_name = ?
@@ -968,6 +1267,128 @@ WHERE repo_namespace = ? AND repo_name = ?
 	return nil
 }
 
+func (dbif *SqliteGitusDatabaseInterface) GetLFSObject(ns string, name string, oid string) (*model.LFSObject, error) {
+	pfx := dbif.config.Database.TablePrefix
+	stmt, err := dbif.connection.Prepare(fmt.Sprintf(`
+SELECT size, upload_timestamp
+FROM %s_lfs_object
+WHERE repo_namespace = ? AND repo_name = ? AND oid = ?
+`, pfx))
+	if err != nil { return nil, err }
+	defer stmt.Close()
+	r := stmt.QueryRow(ns, name, oid)
+	if r.Err() != nil { return nil, r.Err() }
+	res := &model.LFSObject{ RepoNamespace: ns, RepoName: name, OID: oid }
+	err = r.Scan(&res.Size, &res.UploadTime)
+	if err == sql.ErrNoRows { return nil, db.ErrEntityNotFound }
+	if err != nil { return nil, err }
+	return res, nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) RegisterLFSObject(obj *model.LFSObject) error {
+	pfx := dbif.config.Database.TablePrefix
+	tx, err := dbif.connection.Begin()
+	if err != nil { return err }
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(fmt.Sprintf(`
+INSERT OR IGNORE INTO %s_lfs_object(repo_namespace, repo_name, oid, size, upload_timestamp)
+VALUES (?,?,?,?,?)
+`, pfx))
+	if err != nil { return err }
+	defer stmt.Close()
+	_, err = stmt.Exec(obj.RepoNamespace, obj.RepoName, obj.OID, obj.Size, obj.UploadTime)
+	if err != nil { return err }
+	err = tx.Commit()
+	if err != nil { return err }
+	return nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) GetLFSStorageUsage(ns string, name string) (*model.LFSStorageUsage, error) {
+	pfx := dbif.config.Database.TablePrefix
+	stmt, err := dbif.connection.Prepare(fmt.Sprintf(`
+SELECT COUNT(*), COALESCE(SUM(size), 0)
+FROM %s_lfs_object
+WHERE repo_namespace = ? AND repo_name = ?
+`, pfx))
+	if err != nil { return nil, err }
+	defer stmt.Close()
+	r := stmt.QueryRow(ns, name)
+	if r.Err() != nil { return nil, r.Err() }
+	res := &model.LFSStorageUsage{}
+	err = r.Scan(&res.ObjectCount, &res.TotalSize)
+	if err != nil { return nil, err }
+	return res, nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) RecordMaintenanceRun(run *model.MaintenanceRun, keep int) error {
+	pfx := dbif.config.Database.TablePrefix
+	tx, err := dbif.connection.Begin()
+	if err != nil { return err }
+	defer tx.Rollback()
+	_, err = tx.Exec(fmt.Sprintf(`
+INSERT INTO %s_repo_maintenance(repo_namespace, repo_name, run_task, run_trigger, run_start_timestamp, run_duration, run_success, run_output)
+VALUES (?,?,?,?,?,?,?,?)
+`, pfx), run.RepoNamespace, run.RepoName, run.Task, run.Trigger, run.StartTime, run.Duration, run.Success, run.Output)
+	if err != nil { return err }
+	_, err = tx.Exec(fmt.Sprintf(`
+DELETE FROM %s_repo_maintenance
+WHERE repo_namespace = ?1 AND repo_name = ?2 AND run_start_timestamp < (
+    SELECT MIN(run_start_timestamp) FROM (
+        SELECT run_start_timestamp FROM %s_repo_maintenance
+        WHERE repo_namespace = ?1 AND repo_name = ?2
+        ORDER BY run_start_timestamp DESC LIMIT ?3
+    )
+)
+`, pfx, pfx), run.RepoNamespace, run.RepoName, keep)
+	if err != nil { return err }
+	return tx.Commit()
+}
+
+func (dbif *SqliteGitusDatabaseInterface) GetMaintenanceRunList(ns string, name string, pageNum int64, pageSize int64) ([]*model.MaintenanceRun, error) {
+	pfx := dbif.config.Database.TablePrefix
+	stmt, err := dbif.connection.Prepare(fmt.Sprintf(`
+SELECT run_task, run_trigger, run_start_timestamp, run_duration, run_success, run_output
+FROM %s_repo_maintenance
+WHERE repo_namespace = ? AND repo_name = ?
+ORDER BY run_start_timestamp DESC LIMIT ? OFFSET ?
+`, pfx))
+	if err != nil { return nil, err }
+	defer stmt.Close()
+	r, err := stmt.Query(ns, name, pageSize, pageNum*pageSize)
+	if err != nil { return nil, err }
+	defer r.Close()
+	res := make([]*model.MaintenanceRun, 0)
+	for r.Next() {
+		run := &model.MaintenanceRun{ RepoNamespace: ns, RepoName: name }
+		err = r.Scan(&run.Task, &run.Trigger, &run.StartTime, &run.Duration, &run.Success, &run.Output)
+		if err != nil { return nil, err }
+		res = append(res, run)
+	}
+	return res, nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) GetLatestMaintenanceRunList() ([]*model.MaintenanceRun, error) {
+	pfx := dbif.config.Database.TablePrefix
+	r, err := dbif.connection.Query(fmt.Sprintf(`
+SELECT a.repo_namespace, a.repo_name, a.run_task, a.run_trigger, a.run_start_timestamp, a.run_duration, a.run_success
+FROM %s_repo_maintenance AS a
+WHERE a.run_start_timestamp = (
+    SELECT MAX(b.run_start_timestamp) FROM %s_repo_maintenance AS b
+    WHERE b.repo_namespace = a.repo_namespace AND b.repo_name = a.repo_name AND b.run_task = a.run_task
+)
+`, pfx, pfx))
+	if err != nil { return nil, err }
+	defer r.Close()
+	res := make([]*model.MaintenanceRun, 0)
+	for r.Next() {
+		run := &model.MaintenanceRun{}
+		err = r.Scan(&run.RepoNamespace, &run.RepoName, &run.Task, &run.Trigger, &run.StartTime, &run.Duration, &run.Success)
+		if err != nil { return nil, err }
+		res = append(res, run)
+	}
+	return res, nil
+}
+
 func (dbif *SqliteGitusDatabaseInterface) GetAllUsers(pageNum int64, pageSize int64) ([]*model.GitusUser, error) {
 	pfx := dbif.config.Database.TablePrefix
 	stmt, err := dbif.connection.Prepare(fmt.Sprintf(`
@@ -1312,6 +1733,38 @@ UPDATE %s_namespace SET ns_acl = ? WHERE ns_name = ?
 	return nil
 }
 
+func (dbif *SqliteGitusDatabaseInterface) SetNamespaceRequire2FA(nsName string, require bool) error {
+	pfx := dbif.config.Database.TablePrefix
+	stmt1, err := dbif.connection.Prepare(fmt.Sprintf(`
+SELECT ns_acl FROM %s_namespace WHERE ns_name = ?
+`, pfx))
+	if err != nil { return err }
+	defer stmt1.Close()
+	r := stmt1.QueryRow(nsName)
+	if r.Err() != nil { return r.Err() }
+	var aclStr string
+	err = r.Scan(&aclStr)
+	if err != nil { return err }
+	acl, err := model.ParseACL(aclStr)
+	if err != nil { return err }
+	if acl == nil { acl = model.NewACL() }
+	acl.Require2FA = require
+	aclStr, err = acl.SerializeACL()
+	if err != nil { return err }
+	tx, err := dbif.connection.Begin()
+	if err != nil { return err }
+	defer tx.Rollback()
+	stmt2, err := tx.Prepare(fmt.Sprintf(`
+UPDATE %s_namespace SET ns_acl = ? WHERE ns_name = ?
+`, pfx))
+	if err != nil { return err }
+	_, err = stmt2.Exec(aclStr, nsName)
+	if err != nil { return err }
+	err = tx.Commit()
+	if err != nil { return err }
+	return nil
+}
+
 func (dbif *SqliteGitusDatabaseInterface) SetRepositoryACL(nsName string, repoName string, targetUserName string, aclt *model.ACLTuple) error {
 	pfx := dbif.config.Database.TablePrefix
 	stmt1, err := dbif.connection.Prepare(fmt.Sprintf(`
@@ -2370,7 +2823,7 @@ func (dbif *SqliteGitusDatabaseInterface) GetAllPullRequestPaginated(namespace s
 	pfx := dbif.config.Database.TablePrefix
 	stmt, err := dbif.connection.Prepare(fmt.Sprintf(`
 SELECT rowid, pull_request_id, username, title, receiver_branch, provider_namespace, provider_name, provider_branch, merge_conflict_check_result, merge_conflict_check_timestamp, pull_request_status, pull_request_timestamp
-FROM %spull_request
+FROM %s_pull_request
 WHERE receiver_namespace = ? AND receiver_name = ?
 ORDER BY pull_request_id ASC LIMIT ? OFFSET ?
 `, pfx))
@@ -2510,7 +2963,7 @@ ORDER BY issue_priority DESC, issue_timestamp DESC LIMIT ? OFFSET ?
 func (dbif *SqliteGitusDatabaseInterface) NewPullRequest(username string, title string, receiverNamespace string, receiverName string, receiverBranch string, providerNamespace string, providerName string, providerBranch string) (int64, error) {
 	pfx := dbif.config.Database.TablePrefix
 	stmt1, err := dbif.connection.Prepare(fmt.Sprintf(`
-SELECT COUNT(*) FROM %spull_request
+SELECT COUNT(*) FROM %s_pull_request
 WHERE receiver_namespace = ? AND receiver_name = ?
 `, pfx))
 	if err != nil { return 0, err }
@@ -2522,7 +2975,7 @@ WHERE receiver_namespace = ? AND receiver_name = ?
 	if err != nil { return 0, err }
 	defer tx.Rollback()
 	stmt, err := tx.Prepare(fmt.Sprintf(`
-INSERT INTO %spull_request(
+INSERT INTO %s_pull_request(
     username, pull_request_id, title,
     receiver_namespace, receiver_name, receiver_branch,
     provider_namespace, provider_name, provider_branch,
@@ -2548,7 +3001,7 @@ func (dbif *SqliteGitusDatabaseInterface) GetPullRequest(namespace string, name
 	pfx := dbif.config.Database.TablePrefix
 	stmt, err := dbif.connection.Prepare(fmt.Sprintf(`
 SELECT rowid, username, title, receiver_branch, provider_namespace, provider_name, provider_branch, merge_conflict_check_result, merge_conflict_check_timestamp, pull_request_status, pull_request_timestamp
-FROM %spull_request
+FROM %s_pull_request
 WHERE receiver_namespace = ? AND receiver_name = ? AND pull_request_id = ?
 `, pfx))
 	if err != nil { return nil, err }
@@ -2588,7 +3041,7 @@ func (dbif *SqliteGitusDatabaseInterface) GetPullRequestByAbsId(absId int64) (*m
 	pfx := dbif.config.Database.TablePrefix
 	stmt, err := dbif.connection.Prepare(fmt.Sprintf(`
 SELECT username, pull_request_id, title, receiver_namespace, receiver_name, receiver_branch, provider_namespace, provider_name, provider_branch, merge_conflict_check_result, merge_conflict_check_timestamp, pull_request_status, pull_request_timestamp
-FROM %spull_request
+FROM %s_pull_request
 WHERE rowid = ?
 `, pfx))
 	if err != nil { return nil, err }
@@ -2621,7 +3074,7 @@ WHERE rowid = ?
 	}, nil
 }
 
-func (dbif *SqliteGitusDatabaseInterface) CheckPullRequestMergeConflict(absId int64) (*gitlib.MergeCheckResult, error) {
+func (dbif *SqliteGitusDatabaseInterface) CheckPullRequestMergeConflict(ctx context.Context, absId int64) (*gitlib.MergeCheckResult, error) {
 	// WARNING: currently only works when when the source &
 	// the target is git repo. currently (2025.7.28) this check
 	// is performed at the controller side, i.e. users cannot
@@ -2629,10 +3082,12 @@ func (dbif *SqliteGitusDatabaseInterface) CheckPullRequestMergeConflict(absId in
 	// code can still be called. DO NOT CALL UNLESS YOU KNOW
 	// WHAT YOU'RE DOING.
 	// TODO: fix this after figuring things out.
+	// the pull request is updated even if the request is gone.
+	ctx = context.WithoutCancel(ctx)
 	pfx := dbif.config.Database.TablePrefix
 	stmt, err := dbif.connection.Prepare(fmt.Sprintf(`
 SELECT receiver_namespace, receiver_name, receiver_branch, provider_namespace, provider_name, provider_branch
-FROM %spull_request
+FROM %s_pull_request
 WHERE rowid = ?
 `, pfx))
 	if err != nil { return nil, err }
@@ -2646,12 +3101,20 @@ WHERE rowid = ?
 	if err != nil { return nil, err }
 	defer tx.Rollback()
 	p := path.Join(dbif.config.GitRoot, receiverNamespace, receiverName)
-	lgr := gitlib.NewLocalGitRepository(p)
-	remoteName := fmt.Sprintf("%s/%s", providerNamespace, providerName)
+	lgr := gitlib.NewLocalGitRepository(p).WithContext(ctx, gitlog.RequestIDEnvOf(ctx)...)
+	// pull requests between branches of the same repository don't
+	// need a remote; the remote of other repositories is added when
+	// missing (forks have theirs added when forked).
+	remoteName := ""
+	if providerNamespace != receiverNamespace || providerName != receiverName {
+		remoteName = fmt.Sprintf("%s/%s", providerNamespace, providerName)
+		err = lgr.SetUpMergeTarget(remoteName, path.Join(dbif.config.GitRoot, providerNamespace, providerName))
+		if err != nil { return nil, err }
+	}
 	mr, err := lgr.CheckBranchMergeConflict(receiverBranch, remoteName, providerBranch)
 	if err != nil { return nil, err }
 	stmt2, err := tx.Prepare(fmt.Sprintf(`
-UPDATE %spull_request
+UPDATE %s_pull_request
 SET merge_conflict_check_result = ?, merge_conflict_check_timestamp = ?
 WHERE rowid = ?
 `, pfx))
@@ -2670,8 +3133,12 @@ func (dbif *SqliteGitusDatabaseInterface) DeletePullRequest(absId int64) error {
 	tx, err := dbif.connection.Begin()
 	if err != nil { return err }
 	defer tx.Rollback()
+	_, err = tx.Exec(fmt.Sprintf(`
+DELETE FROM %s_pull_request_head WHERE pull_request_abs_id = ?
+`, pfx), absId)
+	if err != nil { return err }
 	stmt, err := tx.Prepare(fmt.Sprintf(`
-DELETE FROM %spull_request WHERE rowid = ?
+DELETE FROM %s_pull_request WHERE rowid = ?
 `, pfx))
 	if err != nil { return err }
 	_, err = stmt.Exec(absId)
@@ -2685,7 +3152,7 @@ func (dbif *SqliteGitusDatabaseInterface) GetAllPullRequestEventPaginated(absId
 	pfx := dbif.config.Database.TablePrefix
 	stmt, err := dbif.connection.Prepare(fmt.Sprintf(`
 SELECT event_type, event_timestamp, event_author, event_content
-FROM %spull_request_event
+FROM %s_pull_request_event
 WHERE pull_request_abs_id = ?
 ORDER BY event_timestamp ASC LIMIT ? OFFSET ?
 `, pfx))
@@ -2710,7 +3177,7 @@ ORDER BY event_timestamp ASC LIMIT ? OFFSET ?
 	return res, nil
 }
 
-func (dbif *SqliteGitusDatabaseInterface) CheckAndMergePullRequest(absId int64, username string) error {
+func (dbif *SqliteGitusDatabaseInterface) CheckAndMergePullRequest(ctx context.Context, absId int64, username string) error {
 	// WARNING: currently only works when when the source &
 	// the target is git repo. currently (2025.7.28) this check
 	// is performed at the controller side, i.e. users cannot
@@ -2718,7 +3185,9 @@ func (dbif *SqliteGitusDatabaseInterface) CheckAndMergePullRequest(absId int64,
 	// code can still be called. DO NOT CALL UNLESS YOU KNOW
 	// WHAT YOU'RE DOING.
 	// TODO: fix this after figuring things out.
-	r, err := dbif.CheckPullRequestMergeConflict(absId)
+	// the merge isn't stopped half-way when the request is gone.
+	ctx = context.WithoutCancel(ctx)
+	r, err := dbif.CheckPullRequestMergeConflict(ctx, absId)
 	if err != nil { return err }
 	// TODO: this would need to be fixed in the future...
 	if !r.Successful { return nil }
@@ -2732,53 +3201,21 @@ SELECT user_email, user_title FROM %s_user WHERE user_name = ?
 	var email, userTitle string
 	err = rr.Scan(&email, &userTitle)
 	if err != nil { return err }
-	// fetch
-	buf := new(bytes.Buffer)
-	cmd1 := exec.Command("git", "fetch", r.ProviderRemoteName, r.ProviderBranch)
-	cmd1.Dir = r.ReceiverLocation
-	cmd1.Stderr = buf
-	err = cmd1.Run()
-	if err != nil { return errors.New(err.Error() + ": " + buf.String()) }
-	buf.Reset()
-	providerFullName := fmt.Sprintf("%s/%s", r.ProviderRemoteName, r.ProviderBranch)
-	cmd2 := exec.Command("git", "merge-tree", "--write-tree", r.ReceiverBranch, providerFullName)
-	cmd2.Dir = r.ReceiverLocation
-	cmd2.Stdout = buf
-	err = cmd2.Run()
-	if err != nil { return fmt.Errorf("Failed while merge-tree: %s", err.Error()) }
-	treeId := strings.TrimSpace(buf.String())
-	mergeMessage := fmt.Sprintf("merge: from %s/%s to %s", r.ProviderRemoteName, r.ProviderBranch, r.ReceiverBranch)
-	buf.Reset()
-	cmd3 := exec.Command("git", "commit-tree", treeId, "-m", mergeMessage, "-p", r.ReceiverBranch, "-p", providerFullName)
-	cmd3.Dir = r.ReceiverLocation
-	cmd3.Stdout = buf
-	cmd3.Env = os.Environ()
-	cmd3.Env = append(cmd3.Env, fmt.Sprintf("GIT_AUTHOR_NAME=%s", userTitle))
-	cmd3.Env = append(cmd3.Env, fmt.Sprintf("GIT_AUTHOR_EMAIL=%s", email))
-	cmd3.Env = append(cmd3.Env, fmt.Sprintf("GIT_COMMITTER_NAME=%s", userTitle))
-	cmd3.Env = append(cmd3.Env, fmt.Sprintf("GIT_COMMITTER_EMAIL=%s", email))
-	err = cmd3.Run()
-	if err != nil { return fmt.Errorf("Failed while commit-tree: %s", err.Error()) }
-	commitId := strings.TrimSpace(buf.String())
-	buf.Reset()
-	receiverBranchFullName := fmt.Sprintf("refs/heads/%s", r.ReceiverBranch)
-	cmd4 := exec.Command("git", "update-ref", receiverBranchFullName, commitId)
-	cmd4.Dir = r.ReceiverLocation
-	cmd4.Stderr = buf
-	err = cmd4.Run()
-	if err != nil { return fmt.Errorf("Failed while update-ref: %s; %s", err.Error(), buf.String()) }
+	lgr := gitlib.NewLocalGitRepository(r.ReceiverLocation).WithContext(ctx, gitlog.RequestIDEnvOf(ctx)...)
+	err = lgr.Merge(r.ProviderRemoteName, r.ProviderBranch, r.ReceiverBranch, userTitle, email)
+	if err != nil { return err }
 	tx, err := dbif.connection.Begin()
 	if err != nil { return err }
 	defer tx.Rollback()
 	t := time.Now().Unix()
 	stmt, err := tx.Prepare(fmt.Sprintf(`
-UPDATE %spull_request SET pull_request_status = ?, pull_request_timestamp = ? WHERE rowid = ?
+UPDATE %s_pull_request SET pull_request_status = ?, pull_request_timestamp = ? WHERE rowid = ?
 `, pfx))
 	if err != nil { return err }
 	_, err = stmt.Exec(model.PULL_REQUEST_CLOSED_AS_MERGED, t, absId)
 	if err != nil { return err }
 	stmt2, err := tx.Prepare(fmt.Sprintf(`
-INSERT INTO %spull_request_event(pull_request_abs_id, event_type, event_timestamp, event_author, event_content)
+INSERT INTO %s_pull_request_event(pull_request_abs_id, event_type, event_timestamp, event_author, event_content)
 VALUES (?,?,?,?,?)
 `, pfx))
 	if err != nil { return err }
@@ -2796,7 +3233,7 @@ func (dbif *SqliteGitusDatabaseInterface) CommentOnPullRequest(absId int64, auth
 	if err != nil { return nil, err }
 	defer tx.Rollback()
 	stmt, err := tx.Prepare(fmt.Sprintf(`
-INSERT INTO %spull_request_event(pull_request_abs_id, event_type, event_timestamp, event_author, event_content) VALUES (?,?,?,?,?)
+INSERT INTO %s_pull_request_event(pull_request_abs_id, event_type, event_timestamp, event_author, event_content) VALUES (?,?,?,?,?)
 `, pfx))
 	if err != nil { return nil, err }
 	eventContentString := content
@@ -2819,7 +3256,7 @@ func (dbif *SqliteGitusDatabaseInterface) CommentOnPullRequestCode(absId int64,
 	if err != nil { return nil, err }
 	defer tx.Rollback()
 	stmt, err := tx.Prepare(fmt.Sprintf(`
-INSERT INTO %spull_request_event(pull_request_abs_id, event_type, event_timestamp, event_author, event_content)
+INSERT INTO %s_pull_request_event(pull_request_abs_id, event_type, event_timestamp, event_author, event_content)
 VALUES (?,?,?,?,?)
 `, pfx))
 	if err != nil { return nil, err }
@@ -2839,13 +3276,84 @@ VALUES (?,?,?,?,?)
 	}, nil
 }
 
+func (dbif *SqliteGitusDatabaseInterface) RecordPullRequestBranchUpdate(absId int64, author string, commitId string) (*model.PullRequestEvent, error) {
+	pfx := dbif.config.Database.TablePrefix
+	t := time.Now().Unix()
+	tx, err := dbif.connection.Begin()
+	if err != nil { return nil, err }
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(fmt.Sprintf(`
+INSERT INTO %s_pull_request_event(pull_request_abs_id, event_type, event_timestamp, event_author, event_content) VALUES (?,?,?,?,?)
+`, pfx))
+	if err != nil { return nil, err }
+	_, err = stmt.Exec(absId, model.PULL_REQUEST_EVENT_UPDATE_ON_BRANCH, t, author, commitId)
+	if err != nil { return nil, err }
+	_, err = tx.Exec(fmt.Sprintf(`
+INSERT INTO %s_pull_request_head(pull_request_abs_id, head_commit_id) VALUES (?,?)
+ON CONFLICT (pull_request_abs_id) DO UPDATE SET head_commit_id = excluded.head_commit_id
+`, pfx), absId, commitId)
+	if err != nil { return nil, err }
+	err = tx.Commit()
+	if err != nil { return nil, err }
+	return &model.PullRequestEvent{
+		PRAbsId: absId,
+		EventType: model.PULL_REQUEST_EVENT_UPDATE_ON_BRANCH,
+		EventTimestamp: t,
+		EventAuthor: author,
+		EventContent: commitId,
+	}, nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) GetPullRequestProviderHead(absId int64) (string, error) {
+	pfx := dbif.config.Database.TablePrefix
+	var res string
+	err := dbif.connection.QueryRow(fmt.Sprintf(`
+SELECT head_commit_id FROM %s_pull_request_head WHERE pull_request_abs_id = ?
+`, pfx), absId).Scan(&res)
+	if err == sql.ErrNoRows { return "", nil }
+	if err != nil { return "", err }
+	return res, nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) SetPullRequestProviderHead(absId int64, commitId string) error {
+	pfx := dbif.config.Database.TablePrefix
+	_, err := dbif.connection.Exec(fmt.Sprintf(`
+INSERT INTO %s_pull_request_head(pull_request_abs_id, head_commit_id) VALUES (?,?)
+ON CONFLICT (pull_request_abs_id) DO UPDATE SET head_commit_id = excluded.head_commit_id
+`, pfx), absId, commitId)
+	return err
+}
+
+func (dbif *SqliteGitusDatabaseInterface) GetOpenPullRequestOfProvider(providerNamespace string, providerName string) ([]*model.PullRequest, error) {
+	pfx := dbif.config.Database.TablePrefix
+	r, err := dbif.connection.Query(fmt.Sprintf(`
+SELECT rowid, username, pull_request_id, title, receiver_namespace, receiver_name, receiver_branch, provider_branch, pull_request_timestamp
+FROM %s_pull_request
+WHERE provider_namespace = ? AND provider_name = ? AND pull_request_status = ?
+`, pfx), providerNamespace, providerName, model.PULL_REQUEST_OPEN)
+	if err != nil { return nil, err }
+	defer r.Close()
+	res := make([]*model.PullRequest, 0)
+	for r.Next() {
+		pr := &model.PullRequest{
+			ProviderNamespace: providerNamespace,
+			ProviderName: providerName,
+			Status: model.PULL_REQUEST_OPEN,
+		}
+		err = r.Scan(&pr.PRAbsId, &pr.Author, &pr.PRId, &pr.Title, &pr.ReceiverNamespace, &pr.ReceiverName, &pr.ReceiverBranch, &pr.ProviderBranch, &pr.Timestamp)
+		if err != nil { return nil, err }
+		res = append(res, pr)
+	}
+	return res, nil
+}
+
 func (dbif *SqliteGitusDatabaseInterface) ClosePullRequestAsNotMerged(absid int64, author string) error {
 	pfx := dbif.config.Database.TablePrefix
 	tx, err := dbif.connection.Begin()
 	if err != nil { return err }
 	defer tx.Rollback()
 	stmt, err := tx.Prepare(fmt.Sprintf(`
-INSERT INTO %spull_request_event(pull_request_abs_id, event_type, event_timestamp, event_author, event_content)
+INSERT INTO %s_pull_request_event(pull_request_abs_id, event_type, event_timestamp, event_author, event_content)
 VALUES (?,?,?,?,?)
 `, pfx))
 	if err != nil { return err }
@@ -2853,7 +3361,7 @@ VALUES (?,?,?,?,?)
 	_, err = stmt.Exec(absid, model.PULL_REQUEST_EVENT_CLOSE_AS_NOT_MERGED, t, author, new(string))
 	if err != nil { return err }
 	stmt2, err := tx.Prepare(fmt.Sprintf(`
-UPDATE %spull_request
+UPDATE %s_pull_request
 SET pull_request_status = ?
 WHERE rowid = ?
 `, pfx))
@@ -2871,7 +3379,7 @@ func (dbif *SqliteGitusDatabaseInterface) ReopenPullRequest(absid int64, author
 	if err != nil { return err }
 	defer tx.Rollback()
 	stmt, err := tx.Prepare(fmt.Sprintf(`
-INSERT INTO %spull_request_event(pull_request_abs_id, event_type, event_timestamp, event_author, event_content)
+INSERT INTO %s_pull_request_event(pull_request_abs_id, event_type, event_timestamp, event_author, event_content)
 VALUES (?,?,?,?,?)
 `, pfx))
 	if err != nil { return err }
@@ -2879,7 +3387,7 @@ VALUES (?,?,?,?,?)
 	_, err = stmt.Exec(absid, model.PULL_REQUEST_EVENT_REOPEN, t, author, new(string))
 	if err != nil { return err }
 	stmt2, err := tx.Prepare(fmt.Sprintf(`
-UPDATE %spull_request
+UPDATE %s_pull_request
 SET pull_request_status = ?
 WHERE rowid = ?
 `, pfx))
@@ -2902,7 +3410,7 @@ func (dbif *SqliteGitusDatabaseInterface) CountPullRequest(query string, namespa
 	queryClause := ""
 	if query != "" { queryClause = "AND title LIKE ? ESCAPE ?" }
 	stmt, err := dbif.connection.Prepare(fmt.Sprintf(`
-SELECT COUNT(*) FROM %spull_request
+SELECT COUNT(*) FROM %s_pull_request
 WHERE receiver_namespace = ? AND receiver_name = ? %s %s
 `, pfx, statusClause, queryClause))
 	if err != nil { return 0, err }
@@ -2934,7 +3442,7 @@ func (dbif *SqliteGitusDatabaseInterface) SearchPullRequestPaginated(query strin
 	if query != "" { queryClause = "AND title LIKE ? ESCAPE ?" }
 	stmt, err := dbif.connection.Prepare(fmt.Sprintf(`
 SELECT rowid, username, pull_request_id, title, receiver_branch, provider_namespace, provider_name, provider_branch, merge_conflict_check_result, merge_conflict_check_timestamp, pull_request_status, pull_request_timestamp
-FROM %spull_request
+FROM %s_pull_request
 WHERE receiver_namespace = ? AND receiver_name = ? %s %s
 ORDER BY pull_request_timestamp DESC LIMIT ? OFFSET ?
 `, pfx, statusClause, queryClause))
@@ -3841,3 +4349,245 @@ WHERE uuid = ?
 	return webhookResult, nil
 }
 
+func (dbif *SqliteGitusDatabaseInterface) CountWebhookResultByStatus() (map[uint8]int64, error) {
+	pfx := dbif.config.Database.TablePrefix
+	stmt, err := dbif.connection.Prepare(fmt.Sprintf(`
+SELECT COALESCE(json_extract(webhook_result, '$.status'), 0), COUNT(*)
+FROM %s_webhook_log
+GROUP BY 1
+`, pfx))
+	if err != nil { return nil, err }
+	defer stmt.Close()
+	r, err := stmt.Query()
+	if err != nil { return nil, err }
+	defer r.Close()
+	res := make(map[uint8]int64, 0)
+	for r.Next() {
+		var status uint8
+		var count int64
+		err = r.Scan(&status, &count)
+		if err != nil { return nil, err }
+		res[status] += count
+	}
+	return res, r.Err()
+}
+
+func (dbif *SqliteGitusDatabaseInterface) AppendAuditLog(entry *model.AuditLogEntry) error {
+	pfx := dbif.config.Database.TablePrefix
+	stmt, err := dbif.connection.Prepare(fmt.Sprintf(`
+INSERT INTO %s_audit_log(audit_timestamp, audit_actor, audit_ip, audit_action, audit_target, audit_diff)
+VALUES (?,?,?,?,?,?)
+`, pfx))
+	if err != nil { return err }
+	defer stmt.Close()
+	r, err := stmt.Exec(entry.Timestamp, entry.Actor, entry.IP, entry.Action, entry.Target, entry.Diff)
+	if err != nil { return err }
+	entry.AbsId, err = r.LastInsertId()
+	return err
+}
+
+func (dbif *SqliteGitusDatabaseInterface) CountAuditLog(filter *model.AuditLogFilter) (int64, error) {
+	pfx := dbif.config.Database.TablePrefix
+	cond, args := db.BuildAuditLogCondition(filter, func(int) string { return "?" })
+	var res int64
+	err := dbif.connection.QueryRow(fmt.Sprintf(`
+SELECT COUNT(*) FROM %s_audit_log WHERE %s
+`, pfx, cond), args...).Scan(&res)
+	if err != nil { return 0, err }
+	return res, nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) SearchAuditLogPaginated(filter *model.AuditLogFilter, pageNum int64, pageSize int64) ([]*model.AuditLogEntry, error) {
+	pfx := dbif.config.Database.TablePrefix
+	cond, args := db.BuildAuditLogCondition(filter, func(int) string { return "?" })
+	args = append(args, pageSize, pageNum * pageSize)
+	r, err := dbif.connection.Query(fmt.Sprintf(`
+SELECT rowid, audit_timestamp, audit_actor, audit_ip, audit_action, audit_target, audit_diff
+FROM %s_audit_log
+WHERE %s
+ORDER BY rowid DESC LIMIT ? OFFSET ?
+`, pfx, cond), args...)
+	if err != nil { return nil, err }
+	defer r.Close()
+	res := make([]*model.AuditLogEntry, 0)
+	for r.Next() {
+		e := new(model.AuditLogEntry)
+		err = r.Scan(&e.AbsId, &e.Timestamp, &e.Actor, &e.IP, &e.Action, &e.Target, &e.Diff)
+		if err != nil { return nil, err }
+		res = append(res, e)
+	}
+	return res, r.Err()
+}
+
+func (dbif *SqliteGitusDatabaseInterface) GetQuota(subjectType string, name string) (*model.Quota, error) {
+	pfx := dbif.config.Database.TablePrefix
+	stmt, err := dbif.connection.Prepare(fmt.Sprintf(`
+SELECT repository_size, lfs_size, snippet_size, repository_count
+FROM %s_quota
+WHERE subject_type = ? AND subject_name = ?
+`, pfx))
+	if err != nil { return nil, err }
+	defer stmt.Close()
+	r := stmt.QueryRow(subjectType, name)
+	if r.Err() != nil { return nil, r.Err() }
+	res := &model.Quota{}
+	err = r.Scan(&res.RepositorySize, &res.LFSSize, &res.SnippetSize, &res.RepositoryCount)
+	if err == sql.ErrNoRows { return nil, db.ErrEntityNotFound }
+	if err != nil { return nil, err }
+	return res, nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) SetQuota(subjectType string, name string, quota *model.Quota) error {
+	pfx := dbif.config.Database.TablePrefix
+	tx, err := dbif.connection.Begin()
+	if err != nil { return err }
+	defer tx.Rollback()
+	_, err = tx.Exec(fmt.Sprintf(`
+INSERT INTO %s_quota(subject_type, subject_name, repository_size, lfs_size, snippet_size, repository_count)
+VALUES (?,?,?,?,?,?)
+ON CONFLICT (subject_type, subject_name) DO UPDATE SET
+    repository_size = excluded.repository_size,
+    lfs_size = excluded.lfs_size,
+    snippet_size = excluded.snippet_size,
+    repository_count = excluded.repository_count
+`, pfx), subjectType, name, quota.RepositorySize, quota.LFSSize, quota.SnippetSize, quota.RepositoryCount)
+	if err != nil { return err }
+	return tx.Commit()
+}
+
+func (dbif *SqliteGitusDatabaseInterface) RemoveQuota(subjectType string, name string) error {
+	pfx := dbif.config.Database.TablePrefix
+	_, err := dbif.connection.Exec(fmt.Sprintf(`
+DELETE FROM %s_quota WHERE subject_type = ? AND subject_name = ?
+`, pfx), subjectType, name)
+	return err
+}
+
+func (dbif *SqliteGitusDatabaseInterface) UpdateRepositorySize(ns string, name string, size int64) error {
+	pfx := dbif.config.Database.TablePrefix
+	_, err := dbif.connection.Exec(fmt.Sprintf(`
+INSERT INTO %s_repo_size(repo_namespace, repo_name, repo_size, update_timestamp)
+VALUES (?,?,?,?)
+ON CONFLICT (repo_namespace, repo_name) DO UPDATE SET
+    repo_size = excluded.repo_size,
+    update_timestamp = excluded.update_timestamp
+`, pfx), ns, name, size, time.Now().Unix())
+	return err
+}
+
+func (dbif *SqliteGitusDatabaseInterface) GetRepositorySize(ns string, name string) (int64, error) {
+	pfx := dbif.config.Database.TablePrefix
+	var res int64
+	err := dbif.connection.QueryRow(fmt.Sprintf(`
+SELECT repo_size FROM %s_repo_size
+WHERE repo_namespace = ? AND repo_name = ?
+`, pfx), ns, name).Scan(&res)
+	if err == sql.ErrNoRows { return 0, nil }
+	if err != nil { return 0, err }
+	return res, nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) GetQuotaUsage(subjectType string, name string) (*model.QuotaUsage, error) {
+	pfx := dbif.config.Database.TablePrefix
+	cond := "1 = 1"
+	args := make([]any, 0)
+	switch subjectType {
+	case model.QUOTA_SUBJECT_SITE:
+	case model.QUOTA_SUBJECT_USER: cond = "r.repo_owner = ?"; args = append(args, name)
+	case model.QUOTA_SUBJECT_NAMESPACE: cond = "r.repo_namespace = ?"; args = append(args, name)
+	default: return nil, fmt.Errorf("Unknown quota subject type: %s", subjectType)
+	}
+	res := &model.QuotaUsage{}
+	err := dbif.connection.QueryRow(fmt.Sprintf(`
+SELECT COUNT(*), COALESCE(SUM(s.repo_size), 0)
+FROM %s_repository AS r
+LEFT JOIN %s_repo_size AS s
+    ON s.repo_namespace = r.repo_namespace AND s.repo_name = r.repo_name
+WHERE %s
+`, pfx, pfx, cond), args...).Scan(&res.RepositoryCount, &res.RepositorySize)
+	if err != nil { return nil, err }
+	err = dbif.connection.QueryRow(fmt.Sprintf(`
+SELECT COALESCE(SUM(l.size), 0)
+FROM %s_lfs_object AS l
+JOIN %s_repository AS r
+    ON l.repo_namespace = r.repo_namespace AND l.repo_name = r.repo_name
+WHERE %s
+`, pfx, pfx, cond), args...).Scan(&res.LFSSize)
+	if err != nil { return nil, err }
+	return res, nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) GetForkRepositoryList(originNamespace string, originName string) ([]*model.Repository, error) {
+	pfx := dbif.config.Database.TablePrefix
+	stmt, err := dbif.connection.Prepare(fmt.Sprintf(`
+SELECT repo_type, repo_namespace, repo_name, repo_description, repo_acl, repo_owner, repo_status, repo_label_list, rowid
+FROM %s_repository
+WHERE repo_fork_origin_namespace = ? AND repo_fork_origin_name = ?
+ORDER BY rowid ASC
+`, pfx))
+	if err != nil { return nil, err }
+	defer stmt.Close()
+	r, err := stmt.Query(originNamespace, originName)
+	if err != nil { return nil, err }
+	defer r.Close()
+	res := make([]*model.Repository, 0)
+	for r.Next() {
+		var ns, name, desc, acl, owner, labelList string
+		var status int
+		var rowid int64
+		var repoType uint8
+		err = r.Scan(&repoType, &ns, &name, &desc, &acl, &owner, &status, &labelList, &rowid)
+		if err != nil { return nil, err }
+		p := path.Join(dbif.config.GitRoot, ns, name)
+		lr, err := model.CreateLocalRepository(repoType, ns, name, p)
+		if err != nil { return nil, err }
+		var tags []string = nil
+		if len(labelList) > 0 {
+			tags = strings.Split(labelList[1:len(labelList)-1], "}{")
+		}
+		aclobj, err := model.ParseACL(acl)
+		if err != nil { return nil, err }
+		res = append(res, &model.Repository{
+			AbsId: rowid,
+			Type: repoType,
+			Namespace: ns,
+			Name: name,
+			Owner: owner,
+			Description: desc,
+			AccessControlList: aclobj,
+			Status: model.GitusRepositoryStatus(status),
+			Repository: lr,
+			ForkOriginNamespace: originNamespace,
+			ForkOriginName: originName,
+			RepoLabelList: tags,
+		})
+	}
+	return res, nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) GetForkAutoSync(ns string, name string) (bool, error) {
+	pfx := dbif.config.Database.TablePrefix
+	var res int64
+	err := dbif.connection.QueryRow(fmt.Sprintf(`
+SELECT COUNT(*) FROM %s_fork_sync
+WHERE repo_namespace = ? AND repo_name = ?
+`, pfx), ns, name).Scan(&res)
+	if err != nil { return false, err }
+	return res > 0, nil
+}
+
+func (dbif *SqliteGitusDatabaseInterface) SetForkAutoSync(ns string, name string, enable bool) error {
+	pfx := dbif.config.Database.TablePrefix
+	var err error
+	if enable {
+		_, err = dbif.connection.Exec(fmt.Sprintf(`
+INSERT INTO %s_fork_sync(repo_namespace, repo_name) VALUES (?,?)
+ON CONFLICT (repo_namespace, repo_name) DO NOTHING
+`, pfx), ns, name)
+	} else {
+		_, err = dbif.connection.Exec(fmt.Sprintf(`
+DELETE FROM %s_fork_sync WHERE repo_namespace = ? AND repo_name = ?
+`, pfx), ns, name)
+	}
+	return err
+}
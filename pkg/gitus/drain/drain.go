@@ -0,0 +1,74 @@
+package drain
+
+import (
+	"context"
+	"os/exec"
+	"sync"
+)
+
+// keeps track of the work that should be finished before Gitus
+// exits: git subprocesses & background tasks like sending mails. the
+// http & ssh servers wait for their own in-flight requests; this is
+// for the things they don't know about. see docs/shutdown.org.
+
+var lock sync.Mutex
+var wg sync.WaitGroup
+var process = make(map[*exec.Cmd]bool)
+
+// runs `f` in a new goroutine that is waited for by Wait.
+func Go(f func()) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		f()
+	}()
+}
+
+// starts `cmd`, which is waited for by Wait & killed if it's still
+// running when Wait gives up.
+func Start(cmd *exec.Cmd) error {
+	lock.Lock()
+	defer lock.Unlock()
+	if err := cmd.Start(); err != nil { return err }
+	process[cmd] = true
+	wg.Add(1)
+	return nil
+}
+
+// waits for a command started by Start. must be called exactly once
+// for every successful call to Start.
+func Wait(cmd *exec.Cmd) error {
+	err := cmd.Wait()
+	lock.Lock()
+	delete(process, cmd)
+	lock.Unlock()
+	wg.Done()
+	return err
+}
+
+// the same as `cmd.Run` but tracked.
+func Run(cmd *exec.Cmd) error {
+	if err := Start(cmd); err != nil { return err }
+	return Wait(cmd)
+}
+
+// waits for all tracked work to finish until `ctx` is done, after
+// which the remaining subprocesses are killed.
+func WaitAll(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		lock.Lock()
+		for cmd := range process {
+			if cmd.Process != nil { cmd.Process.Kill() }
+		}
+		lock.Unlock()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,203 @@
+package handover
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// passing listening sockets to Gitus, either by systemd (socket
+// activation) or by the previous Gitus process when it's re-executed
+// with SIGUSR2. see docs/shutdown.org.
+
+// the listeners are named; the names are:
+// + "web": the main web server.
+// + "ssh": the built-in ssh server.
+// + "metrics": the metrics server when it's on a separate port.
+const (
+	LISTENER_WEB = "web"
+	LISTENER_SSH = "ssh"
+	LISTENER_METRICS = "metrics"
+)
+
+// the file descriptors passed to the child start from 3, the same as
+// systemd.
+const listenFdStart = 3
+
+const envListenFdNames = "GITUS_LISTEN_FDNAMES"
+const envReadyFd = "GITUS_READY_FD"
+
+var ErrNotReady = errors.New("the new process exited before it's ready")
+
+var lock sync.Mutex
+var inherited map[string]net.Listener
+var inheritedLoaded = false
+var active = make(map[string]net.Listener)
+var activeOrder = make([]string, 0)
+
+func isKnownName(s string) bool {
+	return s == LISTENER_WEB || s == LISTENER_SSH || s == LISTENER_METRICS
+}
+
+func fileListener(fd int, name string) (net.Listener, error) {
+	f := os.NewFile(uintptr(fd), name)
+	if f == nil { return nil, fmt.Errorf("invalid file descriptor %d", fd) }
+	defer f.Close()
+	return net.FileListener(f)
+}
+
+// collects the listeners passed by systemd or the previous process.
+// the environment variables are removed afterwards so that they're not
+// passed down to the subprocesses.
+func loadInherited() error {
+	inherited = make(map[string]net.Listener)
+	inheritedLoaded = true
+	nameList := make([]string, 0)
+	if s, ok := os.LookupEnv(envListenFdNames); ok {
+		os.Unsetenv(envListenFdNames)
+		nameList = strings.Split(s, ":")
+	} else if os.Getenv("LISTEN_PID") == strconv.Itoa(os.Getpid()) {
+		n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+		names := os.Getenv("LISTEN_FDNAMES")
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+		if err != nil { return fmt.Errorf("invalid LISTEN_FDS: %w", err) }
+		if names != "" { nameList = strings.Split(names, ":") }
+		for len(nameList) < n { nameList = append(nameList, "") }
+		nameList = nameList[:n]
+		// systemd names the sockets after the socket unit when
+		// FileDescriptorName= is not set; a single socket is taken
+		// as the web server's.
+		if n == 1 && !isKnownName(nameList[0]) { nameList[0] = LISTENER_WEB }
+	}
+	for i, name := range nameList {
+		if name == "" { continue }
+		l, err := fileListener(listenFdStart + i, name)
+		if err != nil { return fmt.Errorf("failed to use inherited listener %s: %w", name, err) }
+		if !isKnownName(name) {
+			l.Close()
+			continue
+		}
+		inherited[name] = l
+	}
+	return nil
+}
+
+// returns the inherited listener named `name`, or listens on `addr`
+// if there isn't one. the listener is remembered so that it can be
+// passed on by Reexec.
+func Listen(name string, addr string) (net.Listener, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	if !inheritedLoaded {
+		if err := loadInherited(); err != nil { return nil, err }
+	}
+	l, ok := inherited[name]
+	if ok {
+		delete(inherited, name)
+	} else {
+		var err error
+		l, err = net.Listen("tcp", addr)
+		if err != nil { return nil, err }
+	}
+	if _, ok := active[name]; !ok { activeOrder = append(activeOrder, name) }
+	active[name] = l
+	return l, nil
+}
+
+// tells the previous process that this one is ready to serve, if
+// this process is started by Reexec.
+func NotifyReady() {
+	s, ok := os.LookupEnv(envReadyFd)
+	if !ok { return }
+	os.Unsetenv(envReadyFd)
+	fd, err := strconv.Atoi(s)
+	if err != nil { return }
+	f := os.NewFile(uintptr(fd), "ready")
+	if f == nil { return }
+	f.Write([]byte{1})
+	f.Close()
+}
+
+type fileListenerIf interface {
+	File() (*os.File, error)
+}
+
+// starts a new Gitus process with the same arguments & passes the
+// active listeners to it. returns after the new process is ready to
+// serve or has failed to start; in the latter case the caller should
+// keep serving.
+func Reexec(timeout time.Duration) (*os.Process, error) {
+	exe, err := os.Executable()
+	if err != nil { return nil, err }
+	lock.Lock()
+	fileList := make([]*os.File, 0)
+	nameList := make([]string, 0)
+	for _, name := range activeOrder {
+		fl, ok := active[name].(fileListenerIf)
+		if !ok { continue }
+		f, err := fl.File()
+		if err != nil {
+			lock.Unlock()
+			for _, f := range fileList { f.Close() }
+			return nil, fmt.Errorf("failed to get the file of listener %s: %w", name, err)
+		}
+		fileList = append(fileList, f)
+		nameList = append(nameList, name)
+	}
+	lock.Unlock()
+	defer func() {
+		for _, f := range fileList { f.Close() }
+	}()
+	readyR, readyW, err := os.Pipe()
+	if err != nil { return nil, err }
+	defer readyR.Close()
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = append(fileList, readyW)
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("%s=%s", envListenFdNames, strings.Join(nameList, ":")),
+		fmt.Sprintf("%s=%d", envReadyFd, listenFdStart + len(fileList)),
+	)
+	err = cmd.Start()
+	readyW.Close()
+	if err != nil { return nil, err }
+	go cmd.Wait()
+
+	// the pipe is closed without anything written to it if the new
+	// process exits before it's ready.
+	readyR.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 1)
+	n, err := readyR.Read(buf)
+	if n <= 0 {
+		cmd.Process.Kill()
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil, fmt.Errorf("the new process is not ready after %s", timeout)
+		}
+		return nil, ErrNotReady
+	}
+	notifySystemdMainPid(cmd.Process.Pid)
+	return cmd.Process, nil
+}
+
+// tells systemd that the new process is now the main process of the
+// service. requires `NotifyAccess=all` in the service unit.
+func notifySystemdMainPid(pid int) {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" { return }
+	if strings.HasPrefix(addr, "@") { addr = "\x00" + addr[1:] }
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{ Name: addr, Net: "unixgram" })
+	if err != nil { return }
+	defer conn.Close()
+	conn.Write([]byte(fmt.Sprintf("MAINPID=%d", pid)))
+}
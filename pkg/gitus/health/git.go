@@ -0,0 +1,95 @@
+package health
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+)
+
+// checks used only by `gitus doctor` since they're too slow to be
+// run on every /readyz request.
+
+func CheckGitBinary() *ComponentStatus {
+	out, err := exec.Command("git", "--version").Output()
+	if err != nil { return fail("git", err.Error()) }
+	return &ComponentStatus{
+		Name: "git",
+		Status: STATUS_OK,
+		Message: strings.TrimSpace(string(out)),
+	}
+}
+
+func isBareRepository(p string) bool {
+	s, err := os.Stat(path.Join(p, "HEAD"))
+	if err != nil || s.IsDir() { return false }
+	s, err = os.Stat(path.Join(p, "objects"))
+	return err == nil && s.IsDir()
+}
+
+// returns the path of the repositories under GitRoot. in forge mode
+// the repositories are always put under a namespace (which is the
+// owner's username when namespaces aren't enabled) but in simple mode
+// they could be right under GitRoot, so both levels are checked.
+func listRepository(cfg *gitus.GitusConfig) ([]string, error) {
+	res := make([]string, 0)
+	l, err := os.ReadDir(cfg.GitRoot)
+	if err != nil { return nil, err }
+	for _, e := range l {
+		if !e.IsDir() { continue }
+		p := path.Join(cfg.GitRoot, e.Name())
+		if isBareRepository(p) {
+			res = append(res, p)
+			continue
+		}
+		sub, err := os.ReadDir(p)
+		if err != nil { return nil, err }
+		for _, se := range sub {
+			if !se.IsDir() { continue }
+			sp := path.Join(p, se.Name())
+			if isBareRepository(sp) { res = append(res, sp) }
+		}
+	}
+	return res, nil
+}
+
+// git refuses to work in repositories owned by another user (the
+// "dubious ownership" error that gitlib.ErrDubiousOwnership stands
+// for), which usually happens when the repositories are copied over
+// as root. we ask git instead of comparing the owners ourselves so
+// that `safe.directory` is taken into account.
+func CheckOwnership(cfg *gitus.GitusConfig) *ComponentStatus {
+	l, err := listRepository(cfg)
+	if err != nil { return fail("ownership", err.Error()) }
+	dubious := make([]string, 0)
+	for _, p := range l {
+		cmd := exec.Command("git", "rev-parse", "--git-dir")
+		cmd.Dir = p
+		stderrBuf := new(bytes.Buffer)
+		cmd.Stderr = stderrBuf
+		if err := cmd.Run(); err != nil {
+			if strings.Contains(stderrBuf.String(), "dubious ownership") {
+				dubious = append(dubious, p)
+				continue
+			}
+			return fail("ownership", fmt.Sprintf("%s: %s", p, strings.TrimSpace(stderrBuf.String())))
+		}
+	}
+	if len(dubious) <= 0 {
+		return &ComponentStatus{
+			Name: "ownership",
+			Status: STATUS_OK,
+			Message: fmt.Sprintf("%d repositories checked", len(l)),
+		}
+	}
+	shown := dubious
+	if len(shown) > 5 { shown = shown[:5] }
+	msg := fmt.Sprintf("%d of %d repositories have dubious ownership (%s", len(dubious), len(l), strings.Join(shown, ", "))
+	if len(dubious) > len(shown) { msg += ", ..." }
+	msg += fmt.Sprintf("); run `chown -R %s %s` to fix", cfg.GitUser, cfg.GitRoot)
+	return fail("ownership", msg)
+}
@@ -0,0 +1,190 @@
+package health
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/mail"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/receipt"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/session"
+)
+
+// the checks behind /readyz & `gitus doctor`. see docs/health.org.
+
+const (
+	STATUS_OK = "ok"
+	STATUS_FAIL = "fail"
+	STATUS_SKIPPED = "skipped"
+	// only used for things that wouldn't stop gitus from serving
+	// requests, e.g. the site being in maintenance mode.
+	STATUS_WARN = "warn"
+)
+
+type ComponentStatus struct {
+	Name string `json:"name"`
+	Status string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+type Report struct {
+	// "ok" or "fail". a site in maintenance/shutdown mode is still
+	// considered ready; the mode is reported in GlobalVisibility.
+	Status string `json:"status"`
+	GlobalVisibility string `json:"globalVisibility"`
+	Component []*ComponentStatus `json:"component"`
+}
+
+// the parts of gitus to check. nil fields are reported as "not
+// initialized" in forge mode.
+type Target struct {
+	Config *gitus.GitusConfig
+	Database db.GitusDatabaseInterface
+	SessionStore session.GitusSessionStore
+	ReceiptSystem receipt.GitusReceiptSystemInterface
+	Mailer mail.GitusMailerInterface
+}
+
+var MailerDialTimeout = 3 * time.Second
+
+func ok(name string) *ComponentStatus {
+	return &ComponentStatus{ Name: name, Status: STATUS_OK }
+}
+
+func fail(name string, msg string) *ComponentStatus {
+	return &ComponentStatus{ Name: name, Status: STATUS_FAIL, Message: msg }
+}
+
+func skipped(name string, msg string) *ComponentStatus {
+	return &ComponentStatus{ Name: name, Status: STATUS_SKIPPED, Message: msg }
+}
+
+func checkUsable(name string, f func() (bool, error)) *ComponentStatus {
+	b, err := f()
+	if err != nil { return fail(name, err.Error()) }
+	if !b { return fail(name, "not usable") }
+	return ok(name)
+}
+
+func CheckDatabase(t *Target) *ComponentStatus {
+	if !t.Config.IsInForgeMode() { return skipped("database", "not used outside forge mode") }
+	if t.Database == nil { return fail("database", "not initialized") }
+	return checkUsable("database", t.Database.IsDatabaseUsable)
+}
+
+func CheckSessionStore(t *Target) *ComponentStatus {
+	if !t.Config.IsInForgeMode() { return skipped("sessionStore", "not used outside forge mode") }
+	if t.SessionStore == nil { return fail("sessionStore", "not initialized") }
+	return checkUsable("sessionStore", t.SessionStore.IsSessionStoreUsable)
+}
+
+func CheckReceiptSystem(t *Target) *ComponentStatus {
+	if !t.Config.IsInForgeMode() { return skipped("receiptSystem", "not used outside forge mode") }
+	if t.ReceiptSystem == nil { return fail("receiptSystem", "not initialized") }
+	return checkUsable("receiptSystem", t.ReceiptSystem.IsReceiptSystemUsable)
+}
+
+// the mailer interface has no way of checking itself without
+// sending a mail, so we only check if the smtp server is reachable.
+func CheckMailer(t *Target) *ComponentStatus {
+	if !t.Config.IsInForgeMode() { return skipped("mailer", "not used outside forge mode") }
+	cfg := &t.Config.Mailer
+	if cfg.Type == "" { return skipped("mailer", "not configured") }
+	if t.Mailer == nil { return fail("mailer", "failed to initialize") }
+	var addr string
+	switch cfg.Type {
+	case "gmail-plain":
+		addr = "smtp.gmail.com:587"
+	case "smtp":
+		addr = net.JoinHostPort(cfg.SMTPServer, fmt.Sprintf("%d", cfg.SMTPPort))
+	default:
+		return fail("mailer", fmt.Sprintf("unsupported mailer type %s", cfg.Type))
+	}
+	conn, err := net.DialTimeout("tcp", addr, MailerDialTimeout)
+	if err != nil { return fail("mailer", err.Error()) }
+	conn.Close()
+	return ok("mailer")
+}
+
+// checks if a file can be created in dir.
+func CheckWritable(name string, dir string) *ComponentStatus {
+	if dir == "" { return skipped(name, "not configured") }
+	f, err := os.CreateTemp(dir, ".gitus-health-*")
+	if err != nil { return fail(name, err.Error()) }
+	p := f.Name()
+	f.Close()
+	if err = os.Remove(p); err != nil { return fail(name, err.Error()) }
+	return ok(name)
+}
+
+func CheckGlobalVisibility(t *Target) *ComponentStatus {
+	switch t.Config.GlobalVisibility {
+	case gitus.GLOBAL_VISIBILITY_MAINTENANCE:
+		return &ComponentStatus{ Name: "globalVisibility", Status: STATUS_WARN, Message: "the site is in maintenance mode" }
+	case gitus.GLOBAL_VISIBILITY_SHUTDOWN:
+		return &ComponentStatus{ Name: "globalVisibility", Status: STATUS_WARN, Message: "the site is shut down" }
+	}
+	return ok("globalVisibility")
+}
+
+func NewReport(t *Target, l []*ComponentStatus) *Report {
+	status := STATUS_OK
+	for _, c := range l {
+		if c.Status == STATUS_FAIL { status = STATUS_FAIL; break }
+	}
+	return &Report{
+		Status: status,
+		GlobalVisibility: t.Config.GlobalVisibility,
+		Component: l,
+	}
+}
+
+// runs the checks used by /readyz.
+func Check(t *Target) []*ComponentStatus {
+	// snippets are only available in forge mode.
+	snippetRoot := skipped("snippetRoot", "not used outside forge mode")
+	if t.Config.IsInForgeMode() {
+		snippetRoot = CheckWritable("snippetRoot", t.Config.SnippetRoot)
+	}
+	lfsRoot := skipped("lfsRoot", "git lfs not enabled")
+	if t.Config.IsInForgeMode() && t.Config.GitConfig.LFS.Enable {
+		// the root is created on the first upload.
+		if _, err := os.Stat(t.Config.ProperLFSRoot()); os.IsNotExist(err) {
+			lfsRoot = skipped("lfsRoot", "not created yet")
+		} else {
+			lfsRoot = CheckWritable("lfsRoot", t.Config.ProperLFSRoot())
+		}
+	}
+	return []*ComponentStatus{
+		CheckDatabase(t),
+		CheckSessionStore(t),
+		CheckReceiptSystem(t),
+		CheckMailer(t),
+		CheckWritable("gitRoot", t.Config.GitRoot),
+		snippetRoot,
+		lfsRoot,
+		CheckGlobalVisibility(t),
+	}
+}
+
+func Run(t *Target) *Report {
+	return NewReport(t, Check(t))
+}
+
+// a copy of the report w/o the messages, which could contain paths,
+// host names & the details of the errors. used for anonymous callers
+// of /readyz.
+func (r *Report) WithoutMessage() *Report {
+	l := make([]*ComponentStatus, len(r.Component))
+	for i, c := range r.Component {
+		l[i] = &ComponentStatus{ Name: c.Name, Status: c.Status }
+	}
+	return &Report{
+		Status: r.Status,
+		GlobalVisibility: r.GlobalVisibility,
+		Component: l,
+	}
+}
@@ -0,0 +1,132 @@
+package lfs
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// git lfs (large file storage) support: the pointer files, the batch
+// api & the storage of the objects. see docs/lfs.org.
+
+const MEDIA_TYPE = "application/vnd.git-lfs+json"
+
+const (
+	OPERATION_DOWNLOAD = "download"
+	OPERATION_UPLOAD = "upload"
+)
+
+// the command git-lfs runs through ssh to get the http endpoint & the
+// credentials when the remote is an ssh one.
+const SSH_AUTHENTICATE_COMMAND = "git-lfs-authenticate"
+
+// the only transfer adapter supported.
+const TRANSFER_BASIC = "basic"
+
+const HASH_ALGO_SHA256 = "sha256"
+
+// the lfs server url of the repository. git-lfs derives the same url
+// from the clone url `{base}/repo/{fullName}` by appending
+// `.git/info/lfs`. `base` has no trailing slash.
+func Endpoint(base string, repoFullName string) string {
+	return base + "/repo/" + repoFullName + ".git/info/lfs"
+}
+
+// object ids are hex-encoded sha256 of the content.
+func IsValidOID(s string) bool {
+	if len(s) != 64 { return false }
+	for _, ch := range s {
+		if !(('0' <= ch && ch <= '9') || ('a' <= ch && ch <= 'f')) { return false }
+	}
+	return true
+}
+
+// the file committed to git in place of the actual content.
+type Pointer struct {
+	OID string
+	Size int64
+}
+
+const POINTER_VERSION = "https://git-lfs.github.com/spec/v1"
+
+// pointer files larger than this are not considered as pointers.
+const MAX_POINTER_SIZE = 1024
+
+// returns nil if `data` is not a pointer file. see:
+//     https://github.com/git-lfs/git-lfs/blob/main/docs/spec.md
+func ParsePointer(data []byte) *Pointer {
+	if len(data) > MAX_POINTER_SIZE || !bytes.HasSuffix(data, []byte("\n")) { return nil }
+	lineList := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lineList) < 3 || lineList[0] != "version " + POINTER_VERSION { return nil }
+	res := &Pointer{ Size: -1 }
+	for _, line := range lineList[1:] {
+		k, v, ok := strings.Cut(line, " ")
+		if !ok { return nil }
+		switch k {
+		case "oid":
+			oid, ok := strings.CutPrefix(v, HASH_ALGO_SHA256 + ":")
+			if !ok || !IsValidOID(oid) { return nil }
+			res.OID = oid
+		case "size":
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 { return nil }
+			res.Size = n
+		}
+	}
+	if res.OID == "" || res.Size < 0 { return nil }
+	return res
+}
+
+type BatchRequest struct {
+	Operation string `json:"operation"`
+	Transfers []string `json:"transfers"`
+	Objects []*BatchObject `json:"objects"`
+	HashAlgo string `json:"hash_algo"`
+}
+
+type BatchObject struct {
+	OID string `json:"oid"`
+	Size int64 `json:"size"`
+	Authenticated bool `json:"authenticated,omitempty"`
+	Actions map[string]*BatchAction `json:"actions,omitempty"`
+	Error *BatchError `json:"error,omitempty"`
+}
+
+type BatchAction struct {
+	Href string `json:"href"`
+	Header map[string]string `json:"header,omitempty"`
+	// in seconds.
+	ExpiresIn int64 `json:"expires_in,omitempty"`
+}
+
+// the error of a single object; the http status codes are used as
+// the error codes.
+type BatchError struct {
+	Code int `json:"code"`
+	Message string `json:"message"`
+}
+
+type BatchResponse struct {
+	Transfer string `json:"transfer"`
+	Objects []*BatchObject `json:"objects"`
+	HashAlgo string `json:"hash_algo"`
+}
+
+// the body of the requests sent to the verify action.
+type VerifyRequest struct {
+	OID string `json:"oid"`
+	Size int64 `json:"size"`
+}
+
+// the body of the error responses of the whole request.
+type ErrorResponse struct {
+	Message string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// the output of git-lfs-authenticate.
+type AuthenticateResponse struct {
+	Href string `json:"href"`
+	Header map[string]string `json:"header"`
+	ExpiresIn int64 `json:"expires_in"`
+}
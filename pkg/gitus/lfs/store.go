@@ -0,0 +1,90 @@
+package lfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+)
+
+// the objects are stored at `{root}/{namespace}/{repository}/{oid[0:2]}/{oid[2:4]}/{oid}`.
+// they're stored separately for each repository so that an object
+// can only be downloaded thru the repositories it's uploaded to, &
+// so that the storage used by each repository can be accounted.
+
+var ErrObjectMismatch = errors.New("LFS_OBJECT_MISMATCH: The content doesn't match the object id or size.")
+
+func RepositoryPath(cfg *gitus.GitusConfig, ns string, name string) string {
+	return path.Join(cfg.ProperLFSRoot(), ns, name)
+}
+
+// `oid` must be checked with IsValidOID before calling this.
+func ObjectPath(cfg *gitus.GitusConfig, ns string, name string, oid string) string {
+	return path.Join(RepositoryPath(cfg, ns, name), oid[0:2], oid[2:4], oid)
+}
+
+// returns the size of the object; the error satisfies
+// `errors.Is(err, os.ErrNotExist)` when there isn't such object.
+func StatObject(cfg *gitus.GitusConfig, ns string, name string, oid string) (int64, error) {
+	st, err := os.Stat(ObjectPath(cfg, ns, name, oid))
+	if err != nil { return 0, err }
+	return st.Size(), nil
+}
+
+func OpenObject(cfg *gitus.GitusConfig, ns string, name string, oid string) (*os.File, error) {
+	return os.Open(ObjectPath(cfg, ns, name, oid))
+}
+
+// writes the object to a temporary file first & only moves it in place
+// after the content is checked, so that a broken upload never leaves
+// a broken object behind.
+func writeObject(p string, oid string, size int64, r io.Reader) error {
+	dir := path.Dir(p)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil { return err }
+	f, err := os.CreateTemp(dir, oid + ".tmp-*")
+	if err != nil { return err }
+	tmpPath := f.Name()
+	defer os.Remove(tmpPath)
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), io.LimitReader(r, size + 1))
+	if err != nil { f.Close(); return err }
+	if err = f.Close(); err != nil { return err }
+	if n != size || hex.EncodeToString(h.Sum(nil)) != oid { return ErrObjectMismatch }
+	return os.Rename(tmpPath, p)
+}
+
+func PutObject(cfg *gitus.GitusConfig, ns string, name string, oid string, size int64, r io.Reader) error {
+	return writeObject(ObjectPath(cfg, ns, name, oid), oid, size, r)
+}
+
+// makes an object of one repository available in another one, e.g.
+// from the origin of a fork to the fork. a hard link is used when
+// possible.
+func LinkObject(cfg *gitus.GitusConfig, fromNs string, fromName string, toNs string, toName string, oid string, size int64) error {
+	src := ObjectPath(cfg, fromNs, fromName, oid)
+	dst := ObjectPath(cfg, toNs, toName, oid)
+	err := os.MkdirAll(path.Dir(dst), 0755)
+	if err != nil { return err }
+	if err = os.Link(src, dst); err == nil || errors.Is(err, os.ErrExist) { return nil }
+	f, err := os.Open(src)
+	if err != nil { return err }
+	defer f.Close()
+	return writeObject(dst, oid, size, f)
+}
+
+// removes all the objects of the repository.
+func RemoveRepository(cfg *gitus.GitusConfig, ns string, name string) error {
+	if cfg.ProperLFSRoot() == "" || name == "" { return nil }
+	return os.RemoveAll(RepositoryPath(cfg, ns, name))
+}
+
+// removes all the objects of the repositories in the namespace.
+func RemoveNamespace(cfg *gitus.GitusConfig, ns string) error {
+	if cfg.ProperLFSRoot() == "" || ns == "" { return nil }
+	return os.RemoveAll(path.Join(cfg.ProperLFSRoot(), ns))
+}
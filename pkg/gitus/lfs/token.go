@@ -0,0 +1,71 @@
+package lfs
+
+import (
+	"errors"
+	"time"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// the tokens sent with the actions of the batch api & the ones
+// returned by git-lfs-authenticate. they're jwts signed with
+// `jwtSecret` so that the ones issued by `gitus ssh` can be checked
+// by the web server.
+
+var ErrNoSecret = errors.New("LFS_NO_SECRET: jwtSecret must be set to use Git LFS.")
+var ErrInvalidToken = errors.New("LFS_INVALID_TOKEN: Invalid or expired token.")
+
+const TOKEN_LIFETIME = time.Hour
+
+// used as the audience so that tokens signed with `jwtSecret` for
+// other purposes can't be used here.
+const tokenAudience = "gitus-lfs"
+
+type TokenClaim struct {
+	UserName string
+	// the full name of the repository.
+	Repository string
+	// upload tokens can be used for downloading as well.
+	Operation string
+}
+
+func IssueToken(cfg *gitus.GitusConfig, claim *TokenClaim) (string, error) {
+	if cfg.JWTSecret == "" { return "", ErrNoSecret }
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{
+		"aud": tokenAudience,
+		"sub": claim.UserName,
+		"repo": claim.Repository,
+		"op": claim.Operation,
+		"iat": now.Unix(),
+		"exp": now.Add(TOKEN_LIFETIME).Unix(),
+	})
+	return token.SignedString([]byte(cfg.JWTSecret))
+}
+
+func VerifyToken(cfg *gitus.GitusConfig, s string) (*TokenClaim, error) {
+	if cfg.JWTSecret == "" { return nil, ErrNoSecret }
+	token, err := jwt.Parse(s, func(token *jwt.Token) (any, error) {
+		return []byte(cfg.JWTSecret), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS512.Alg()}),
+		jwt.WithAudience(tokenAudience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil { return nil, ErrInvalidToken }
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok { return nil, ErrInvalidToken }
+	res := &TokenClaim{}
+	res.UserName, _ = claims["sub"].(string)
+	res.Repository, _ = claims["repo"].(string)
+	res.Operation, _ = claims["op"].(string)
+	if res.UserName == "" || res.Repository == "" { return nil, ErrInvalidToken }
+	if res.Operation != OPERATION_DOWNLOAD && res.Operation != OPERATION_UPLOAD { return nil, ErrInvalidToken }
+	return res, nil
+}
+
+// whether the token can be used for `op`.
+func (c *TokenClaim) Allows(op string) bool {
+	return c.Operation == OPERATION_UPLOAD || c.Operation == op
+}
@@ -0,0 +1,57 @@
+package log
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// sinks that care about the level of the record (syslog & journald)
+// implement this in addition to io.Writer.
+type levelWriter interface {
+	WriteLevel(level slog.Level, p []byte) (int, error)
+}
+
+// the writer given to the slog handlers. each call to `Handle` writes
+// exactly one line, so the level of the record being written is kept
+// here for the sinks that need it.
+type sink struct {
+	lock sync.Mutex
+	level slog.Level
+	w io.Writer
+}
+
+func (s *sink) Write(p []byte) (int, error) {
+	if lw, ok := s.w.(levelWriter); ok { return lw.WriteLevel(s.level, p) }
+	return s.w.Write(p)
+}
+
+// adds the request id in the context to the record.
+type handler struct {
+	inner slog.Handler
+	sink *sink
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); len(id) > 0 {
+		r = r.Clone()
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	h.sink.lock.Lock()
+	defer h.sink.lock.Unlock()
+	h.sink.level = r.Level
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &handler{ inner: h.inner.WithAttrs(attrs), sink: h.sink }
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{ inner: h.inner.WithGroup(name), sink: h.sink }
+}
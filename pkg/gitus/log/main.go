@@ -1,22 +1,132 @@
 package log
 
+// structured logging. this is a thin layer over log/slog: `Setup`
+// installs a default logger according to the config (level, format
+// & sink), which the stdlib `log` package also writes to, so the
+// existing `log.Printf` calls end up in the same place. the request
+// id is carried in context.Context within the web server & in the
+// environment variable `GITUS_REQUEST_ID` across processes. see
+// docs/logging.org.
+
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
-	"time"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus"
 )
 
+// the environment variable used to hand the request id down to
+// subprocesses (git, the hooks & the commands they call).
+const RequestIDEnv = "GITUS_REQUEST_ID"
+
+type requestIDKey struct{}
+
+func NewRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// request ids coming from outside (e.g. the `X-Request-Id` header
+// set by a reverse proxy) are only accepted when they look sane.
+func IsValidRequestID(s string) bool {
+	if len(s) <= 0 || len(s) > 64 { return false }
+	for _, c := range s {
+		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// returns an empty string if there isn't one.
+func RequestID(ctx context.Context) string {
+	if ctx == nil { return "" }
+	s, _ := ctx.Value(requestIDKey{}).(string)
+	return s
+}
+
+// returns the environment variable (in the `KEY=value` form used by
+// exec.Cmd) carrying the request id of `ctx`; nil if there isn't one.
+func RequestIDEnvOf(ctx context.Context) []string {
+	id := RequestID(ctx)
+	if len(id) <= 0 { return nil }
+	return []string{fmt.Sprintf("%s=%s", RequestIDEnv, id)}
+}
+
+func parseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug": return slog.LevelDebug, nil
+	case "", "info": return slog.LevelInfo, nil
+	case "warn", "warning": return slog.LevelWarn, nil
+	case "error": return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level: %s", s)
+}
+
+func setup(cfg *gitus.GitusConfig, component string, subprocess bool) error {
+	lcfg := cfg.Log
+	level, err := parseLevel(lcfg.Level)
+	if err != nil { return err }
+	w, err := openSink(cfg, subprocess)
+	if err != nil { return err }
+	s := &sink{ w: w }
+	opt := &slog.HandlerOptions{ Level: level }
+	var inner slog.Handler
+	switch lcfg.Format {
+	case "", "text": inner = slog.NewTextHandler(s, opt)
+	case "json": inner = slog.NewJSONHandler(s, opt)
+	default: return fmt.Errorf("unknown log format: %s", lcfg.Format)
+	}
+	logger := slog.New(&handler{ inner: inner, sink: s }).With("component", component)
+	if subprocess {
+		if id := os.Getenv(RequestIDEnv); IsValidRequestID(id) {
+			logger = logger.With("request_id", id)
+		}
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// sets up logging for long-running processes & commands run by the
+// administrator. `component` is added to every record (e.g. "web").
+func Setup(cfg *gitus.GitusConfig, component string) error {
+	return setup(cfg, component, false)
+}
+
+// sets up logging for the commands run by sshd & git hooks (`gitus
+// ssh`, `gitus web-hooks`, ...). their stdout & stderr go to the git
+// client, so nothing is logged when the sink is stdout or stderr.
+// the request id passed down through `GITUS_REQUEST_ID` is added to
+// every record.
+func SetupSubprocess(cfg *gitus.GitusConfig, component string) error {
+	return setup(cfg, component, true)
+}
+
+// NOTE: the following are kept for compatibility & simply log the
+// arguments (as `fmt.Sprint` would) with the default logger.
+
+func DEBUG(a ...any) {
+	slog.Debug(strings.TrimSuffix(fmt.Sprint(a...), "\n"))
+}
+
 func INFO(a ...any) {
-	fmt.Printf("%s [INFO] ", time.Now().Format(time.RFC3339))
-	fmt.Print(a...)
+	slog.Info(strings.TrimSuffix(fmt.Sprint(a...), "\n"))
 }
 
 func WARN(a ...any) {
-	fmt.Printf("%s [WARN] ", time.Now().Format(time.RFC3339))
-	fmt.Print(a...)
+	slog.Warn(strings.TrimSuffix(fmt.Sprint(a...), "\n"))
 }
 
 func ERR(a ...any) {
-	r := fmt.Sprintf("$s [ERR] ", time.Now().Format(time.RFC3339))
-	r2 := fmt.Sprint(a...)
-	fmt.Print(r + r2)
+	slog.Error(strings.TrimSuffix(fmt.Sprint(a...), "\n"))
 }
@@ -0,0 +1,125 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+)
+
+func openSink(cfg *gitus.GitusConfig, subprocess bool) (io.Writer, error) {
+	lcfg := cfg.Log
+	switch lcfg.Output {
+	case "", "stderr":
+		if subprocess { return io.Discard, nil }
+		return os.Stderr, nil
+	case "stdout":
+		if subprocess { return io.Discard, nil }
+		return os.Stdout, nil
+	case "file":
+		p := cfg.ProperLogFilePath()
+		if len(p) <= 0 { return nil, fmt.Errorf("log output is file but no file path is given") }
+		return newRotatingFile(p, int64(lcfg.MaxSizeMB) * 1024 * 1024, lcfg.MaxBackup), nil
+	case "syslog":
+		return openSyslog(lcfg.SyslogNetwork, lcfg.SyslogAddress)
+	case "journald":
+		return openJournald()
+	}
+	return nil, fmt.Errorf("unknown log output: %s", lcfg.Output)
+}
+
+// a log file that's rotated when it's bigger than `maxSize`; the
+// rotated files are named `{path}.1`, `{path}.2`, etc., `{path}.1`
+// being the newest. the file is shared by the web server & the
+// subprocesses; since rotating is done by renaming, a process that
+// finds the file at `path` isn't the one it has opened simply opens
+// it again.
+type rotatingFile struct {
+	path string
+	maxSize int64
+	maxBackup int
+	f *os.File
+}
+
+func newRotatingFile(p string, maxSize int64, maxBackup int) *rotatingFile {
+	return &rotatingFile{ path: p, maxSize: maxSize, maxBackup: maxBackup }
+}
+
+func (rf *rotatingFile) open() error {
+	if rf.f != nil { rf.f.Close(); rf.f = nil }
+	err := os.MkdirAll(path.Dir(rf.path), os.ModeDir|0755)
+	if err != nil { return err }
+	f, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil { return err }
+	rf.f = f
+	return nil
+}
+
+func (rf *rotatingFile) rotate() error {
+	rf.f.Close()
+	rf.f = nil
+	if rf.maxBackup <= 0 {
+		os.Remove(rf.path)
+	} else {
+		for i := rf.maxBackup-1; i >= 1; i -= 1 {
+			os.Rename(fmt.Sprintf("%s.%d", rf.path, i), fmt.Sprintf("%s.%d", rf.path, i+1))
+		}
+		os.Rename(rf.path, rf.path + ".1")
+	}
+	return rf.open()
+}
+
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	if rf.f == nil {
+		if err := rf.open(); err != nil { return 0, err }
+	}
+	fst, err := rf.f.Stat()
+	if err != nil { return 0, err }
+	st, err := os.Stat(rf.path)
+	if err != nil || !os.SameFile(st, fst) {
+		if err := rf.open(); err != nil { return 0, err }
+	} else if rf.maxSize > 0 && fst.Size() + int64(len(p)) > rf.maxSize && fst.Size() > 0 {
+		if err := rf.rotate(); err != nil { return 0, err }
+	}
+	return rf.f.Write(p)
+}
+
+// the native protocol of journald, which keeps the level as the
+// priority of the entry. see systemd.journal-fields(7).
+const journaldSocket = "/run/systemd/journal/socket"
+
+type journaldWriter struct {
+	conn *net.UnixConn
+}
+
+func openJournald() (io.Writer, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{ Name: journaldSocket, Net: "unixgram" })
+	if err != nil { return nil, fmt.Errorf("journald not available: %s", err) }
+	return &journaldWriter{ conn: conn }, nil
+}
+
+func syslogPriority(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError: return 3
+	case level >= slog.LevelWarn: return 4
+	case level >= slog.LevelInfo: return 6
+	}
+	return 7
+}
+
+func (jw *journaldWriter) WriteLevel(level slog.Level, p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	entry := fmt.Sprintf("PRIORITY=%d\nSYSLOG_IDENTIFIER=gitus\nMESSAGE=%s\n", syslogPriority(level), msg)
+	_, err := jw.conn.Write([]byte(entry))
+	if err != nil { return 0, err }
+	return len(p), nil
+}
+
+func (jw *journaldWriter) Write(p []byte) (int, error) {
+	return jw.WriteLevel(slog.LevelInfo, p)
+}
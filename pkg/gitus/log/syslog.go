@@ -0,0 +1,37 @@
+//go:build !windows && !plan9
+
+package log
+
+import (
+	"io"
+	"log/slog"
+	"log/syslog"
+)
+
+type syslogWriter struct {
+	w *syslog.Writer
+}
+
+// an empty address means the local syslog daemon.
+func openSyslog(network string, address string) (io.Writer, error) {
+	w, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_DAEMON, "gitus")
+	if err != nil { return nil, err }
+	return &syslogWriter{ w: w }, nil
+}
+
+func (sw *syslogWriter) WriteLevel(level slog.Level, p []byte) (int, error) {
+	s := string(p)
+	var err error
+	switch {
+	case level >= slog.LevelError: err = sw.w.Err(s)
+	case level >= slog.LevelWarn: err = sw.w.Warning(s)
+	case level >= slog.LevelInfo: err = sw.w.Info(s)
+	default: err = sw.w.Debug(s)
+	}
+	if err != nil { return 0, err }
+	return len(p), nil
+}
+
+func (sw *syslogWriter) Write(p []byte) (int, error) {
+	return sw.WriteLevel(slog.LevelInfo, p)
+}
@@ -0,0 +1,312 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitlib"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/drain"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/quota"
+)
+
+// the periodic maintenance of the bare repositories under GitRoot,
+// roughly what `git maintenance` does for a local clone. see
+// docs/repo-maintenance.org.
+
+// a single git command is killed after this long.
+const TASK_TIMEOUT = 1 * time.Hour
+
+// the output of a run is truncated to this many bytes before being
+// saved.
+const MAX_OUTPUT_SIZE = 4096
+
+// the max number of pending "run now" requests.
+const RUN_NOW_QUEUE_SIZE = 16
+
+var ErrQueueFull = errors.New("Too many pending maintenance requests; please try again later.")
+
+// the git commands of each task. they're run one after another in
+// the repository's directory; the first failing one fails the task.
+// MAINTENANCE_TASK_FORK_SYNC isn't here since it's done w/ gitlib.
+var taskCommand = map[string][][]string{
+	model.MAINTENANCE_TASK_REPACK: [][]string{
+		// `--geometric` only repacks the small packs into bigger ones
+		// so that big repositories aren't fully repacked every time.
+		[]string{"repack", "-d", "-l", "-q", "--geometric=2"},
+		[]string{"pack-refs", "--all", "--prune"},
+	},
+	model.MAINTENANCE_TASK_PRUNE: [][]string{
+		[]string{"prune-packed", "-q"},
+		// objects newer than this could still be used by a push that's
+		// in progress.
+		[]string{"prune", "--expire=2.weeks.ago"},
+	},
+	model.MAINTENANCE_TASK_COMMIT_GRAPH: [][]string{
+		[]string{"commit-graph", "write", "--reachable", "--split", "--no-progress"},
+	},
+	model.MAINTENANCE_TASK_FSCK: [][]string{
+		[]string{"fsck", "--no-progress", "--no-dangling"},
+	},
+}
+
+// the interval of the task in the config. 0 means it's disabled.
+func taskInterval(cfg *gitus.GitusConfig, task string) time.Duration {
+	var h int
+	switch task {
+	case model.MAINTENANCE_TASK_REPACK: h = cfg.Maintenance.RepackInterval
+	case model.MAINTENANCE_TASK_PRUNE: h = cfg.Maintenance.PruneInterval
+	case model.MAINTENANCE_TASK_COMMIT_GRAPH: h = cfg.Maintenance.CommitGraphInterval
+	case model.MAINTENANCE_TASK_FSCK: h = cfg.Maintenance.FsckInterval
+	case model.MAINTENANCE_TASK_FORK_SYNC: h = cfg.Maintenance.ForkSyncInterval
+	}
+	if h <= 0 { return 0 }
+	return time.Duration(h) * time.Hour
+}
+
+type runNowRequest struct {
+	namespace string
+	name string
+	trigger string
+}
+
+type Scheduler struct {
+	config *gitus.GitusConfig
+	dbif db.GitusDatabaseInterface
+	ctx context.Context
+	cancel context.CancelFunc
+	runNow chan *runNowRequest
+	// the repositories w/ a run in progress ("ns:name" or "name").
+	runningLock sync.Mutex
+	running map[string]bool
+}
+
+func NewScheduler(cfg *gitus.GitusConfig, dbif db.GitusDatabaseInterface) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		config: cfg,
+		dbif: dbif,
+		ctx: ctx,
+		cancel: cancel,
+		runNow: make(chan *runNowRequest, RUN_NOW_QUEUE_SIZE),
+		running: make(map[string]bool),
+	}
+}
+
+// starts the scheduler in the background. the scheduled runs only
+// happen when `maintenance.enable` is true, but "run now" requests
+// are always handled.
+func (s *Scheduler) Start() {
+	drain.Go(s.loop)
+}
+
+// stops the scheduler. the git commands that are still running are
+// killed & their runs aren't recorded.
+func (s *Scheduler) Stop() {
+	s.cancel()
+}
+
+// queues a run of all the tasks on the repository.
+func (s *Scheduler) RunNow(ns string, name string, trigger string) error {
+	select {
+	case s.runNow <- &runNowRequest{ namespace: ns, name: name, trigger: trigger }:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (s *Scheduler) IsRunning(ns string, name string) bool {
+	s.runningLock.Lock()
+	defer s.runningLock.Unlock()
+	return s.running[fullName(ns, name)]
+}
+
+func fullName(ns string, name string) string {
+	if len(ns) > 0 { return fmt.Sprintf("%s:%s", ns, name) }
+	return name
+}
+
+func (s *Scheduler) loop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case req := <-s.runNow:
+			s.runAll(req.namespace, req.name, req.trigger)
+		case <-ticker.C:
+			if !s.config.Maintenance.Enable { continue }
+			if s.config.OperationMode != gitus.OP_MODE_FORGE { continue }
+			err := s.tick()
+			if err != nil { log.Printf("Repository maintenance failed: %s\n", err.Error()) }
+		}
+	}
+}
+
+type dueRepository struct {
+	namespace string
+	name string
+	task []string
+	// how long the most overdue task has been overdue.
+	overdue time.Duration
+}
+
+// runs the tasks that are due on at most `repositoryPerMinute`
+// repositories, the most overdue ones first. since every repository
+// has its own schedule which starts from its own first run, the load
+// is spread over time after the first few rounds.
+func (s *Scheduler) tick() error {
+	latestList, err := s.dbif.GetLatestMaintenanceRunList()
+	if err != nil { return err }
+	latest := make(map[string]int64, len(latestList))
+	for _, r := range latestList {
+		latest[fullName(r.RepoNamespace, r.RepoName) + "/" + r.Task] = r.StartTime
+	}
+	now := time.Now()
+	dueList := make([]*dueRepository, 0)
+	var pageNum int64 = 0
+	const pageSize = 100
+	for {
+		repoList, err := s.dbif.GetAllRepositories(pageNum, pageSize)
+		if err != nil { return err }
+		for _, repo := range repoList {
+			if repo.Type != model.REPO_TYPE_GIT { continue }
+			d := &dueRepository{ namespace: repo.Namespace, name: repo.Name }
+			for _, task := range model.MaintenanceTaskList {
+				interval := taskInterval(s.config, task)
+				if interval <= 0 { continue }
+				last, ok := latest[repo.FullName() + "/" + task]
+				overdue := interval
+				if ok { overdue = now.Sub(time.Unix(last, 0)) - interval }
+				if overdue < 0 { continue }
+				if task == model.MAINTENANCE_TASK_FORK_SYNC && !s.shouldSyncFork(repo) { continue }
+				d.task = append(d.task, task)
+				if overdue > d.overdue { d.overdue = overdue }
+			}
+			if len(d.task) > 0 { dueList = append(dueList, d) }
+		}
+		if len(repoList) < pageSize { break }
+		pageNum += 1
+	}
+	sort.SliceStable(dueList, func(i, j int) bool {
+		return dueList[i].overdue > dueList[j].overdue
+	})
+	limit := s.config.ProperMaintenanceRepositoryPerMinute()
+	for i, d := range dueList {
+		if i >= limit { break }
+		for _, task := range d.task {
+			if s.ctx.Err() != nil { return nil }
+			s.run(d.namespace, d.name, task, model.MAINTENANCE_TRIGGER_SCHEDULE)
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) runAll(ns string, name string, trigger string) {
+	for _, task := range model.MaintenanceTaskList {
+		if s.ctx.Err() != nil { return }
+		s.run(ns, name, task, trigger)
+	}
+}
+
+// whether the repository is a fork that has auto-sync turned on.
+func (s *Scheduler) shouldSyncFork(repo *model.Repository) bool {
+	if len(repo.ForkOriginNamespace) <= 0 && len(repo.ForkOriginName) <= 0 { return false }
+	res, err := s.dbif.GetForkAutoSync(repo.Namespace, repo.Name)
+	if err != nil {
+		log.Printf("Failed to check auto-sync of repository %s: %s\n", repo.FullName(), err.Error())
+		return false
+	}
+	return res
+}
+
+// runs a single task on the repository & records the result.
+func (s *Scheduler) run(ns string, name string, task string, trigger string) {
+	fn := fullName(ns, name)
+	// "run now" requests go through all the tasks.
+	if task == model.MAINTENANCE_TASK_FORK_SYNC {
+		repo, err := s.dbif.GetRepositoryByName(ns, name)
+		if err != nil {
+			log.Printf("Failed to retrieve repository %s: %s\n", fn, err.Error())
+			return
+		}
+		if !s.shouldSyncFork(repo) { return }
+	}
+	s.runningLock.Lock()
+	if s.running[fn] { s.runningLock.Unlock(); return }
+	s.running[fn] = true
+	s.runningLock.Unlock()
+	defer func() {
+		s.runningLock.Lock()
+		delete(s.running, fn)
+		s.runningLock.Unlock()
+	}()
+	p := path.Join(s.config.GitRoot, ns, name)
+	start := time.Now()
+	out, err := runTask(s.ctx, p, task)
+	// a run that's cut short by a shutdown says nothing about the
+	// repository.
+	if s.ctx.Err() != nil { return }
+	if err != nil {
+		log.Printf("Maintenance task %s failed on repository %s: %s\n", task, fn, err.Error())
+		out = strings.TrimSpace(out + "\n" + err.Error())
+	}
+	if len(out) > MAX_OUTPUT_SIZE { out = out[:MAX_OUTPUT_SIZE] }
+	err = s.dbif.RecordMaintenanceRun(&model.MaintenanceRun{
+		RepoNamespace: ns,
+		RepoName: name,
+		Task: task,
+		Trigger: trigger,
+		StartTime: start.Unix(),
+		Duration: time.Since(start).Milliseconds(),
+		Success: err == nil,
+		Output: out,
+	}, s.config.ProperMaintenanceHistorySize())
+	if err != nil {
+		log.Printf("Failed to record maintenance run on repository %s: %s\n", fn, err.Error())
+	}
+	// repacking, pruning & syncing change the size of the repository,
+	// which is counted against the storage quotas. see docs/quota.org.
+	if task == model.MAINTENANCE_TASK_REPACK || task == model.MAINTENANCE_TASK_PRUNE || task == model.MAINTENANCE_TASK_FORK_SYNC {
+		_, err = quota.RefreshRepositorySize(s.config, s.dbif, ns, name)
+		if err != nil { log.Printf("Failed to measure repository %s: %s\n", fn, err.Error()) }
+	}
+}
+
+func runTask(ctx context.Context, p string, task string) (string, error) {
+	if task == model.MAINTENANCE_TASK_FORK_SYNC { return runForkSync(ctx, p) }
+	var output strings.Builder
+	for _, args := range taskCommand[task] {
+		cctx, cancel := context.WithTimeout(ctx, TASK_TIMEOUT)
+		cmd := exec.CommandContext(cctx, "git", args...)
+		cmd.Dir = p
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		err := drain.Run(cmd)
+		cancel()
+		if err != nil { return output.String(), err }
+	}
+	return output.String(), nil
+}
+
+// the upstream of a fork is its `origin` remote.
+func runForkSync(ctx context.Context, p string) (string, error) {
+	cctx, cancel := context.WithTimeout(ctx, TASK_TIMEOUT)
+	defer cancel()
+	updated, err := gitlib.NewLocalGitRepository(p).FastForwardAllFromRemote(cctx, "origin", drain.Run)
+	out := ""
+	if len(updated) > 0 { out = fmt.Sprintf("Fast-forwarded: %s", strings.Join(updated, ", ")) }
+	return out, err
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"errors"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/session"
+)
+
+// wraps the database interface so that its errors are counted.
+// "not found" & "already exists" are part of the normal flow (e.g.
+// checking whether a name is taken) and are not counted.
+func HookDatabaseInterface(dbif db.GitusDatabaseInterface) db.GitusDatabaseInterface {
+	return &db.ErrorHookedGitusDatabaseInterface{
+		GitusDatabaseInterface: dbif,
+		Hook: func(method string, err error) {
+			if errors.Is(err, db.ErrEntityNotFound) || errors.Is(err, db.ErrEntityAlreadyExists) { return }
+			DatabaseErrorTotal.Inc(method)
+		},
+	}
+}
+
+func HookSessionStore(ssif session.GitusSessionStore) session.GitusSessionStore {
+	return &session.ErrorHookedGitusSessionStore{
+		GitusSessionStore: ssif,
+		Hook: func(method string, err error) {
+			SessionStoreErrorTotal.Inc(method)
+		},
+	}
+}
+
+var webhookResultStatusName = map[uint8]string{
+	model.WEBHOOK_RESULT_UNDEFINED: "undefined",
+	model.WEBHOOK_RESULT_SUCCESS: "success",
+	model.WEBHOOK_RESULT_FAILURE: "failure",
+}
+
+// registers the gauges computed from the database at scrape time.
+func RegisterDatabaseGauge(dbif db.GitusDatabaseInterface) {
+	countGauge := func(name string, help string, f func() (int64, error)) {
+		NewGaugeFunc(name, help, func() ([]GaugeSample, error) {
+			n, err := f()
+			if err != nil { return nil, err }
+			return []GaugeSample{{ Value: float64(n) }}, nil
+		})
+	}
+	countGauge("gitus_users", "Number of registered users.", dbif.CountAllUser)
+	countGauge("gitus_namespaces", "Number of namespaces.", dbif.CountAllNamespace)
+	countGauge("gitus_repositories", "Number of repositories.", dbif.CountAllRepositories)
+	NewGaugeFunc(
+		"gitus_webhook_results",
+		"Number of webhook deliveries in the webhook log, by status.",
+		func() ([]GaugeSample, error) {
+			m, err := dbif.CountWebhookResultByStatus()
+			if err != nil { return nil, err }
+			res := make([]GaugeSample, 0)
+			for _, status := range []uint8{model.WEBHOOK_RESULT_UNDEFINED, model.WEBHOOK_RESULT_SUCCESS, model.WEBHOOK_RESULT_FAILURE} {
+				res = append(res, GaugeSample{
+					LabelValue: []string{webhookResultStatusName[status]},
+					Value: float64(m[status]),
+				})
+			}
+			return res, nil
+		},
+		"status",
+	)
+}
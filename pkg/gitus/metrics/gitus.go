@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// the metrics collected by gitus. the ones that need the database
+// (e.g. the number of users) are registered by RegisterDatabaseGauge.
+
+var HTTPRequestTotal = NewCounterVec(
+	"gitus_http_requests_total",
+	"Number of HTTP requests handled, by route pattern, method & status code.",
+	"route", "method", "code",
+)
+
+var HTTPRequestDuration = NewHistogramVec(
+	"gitus_http_request_duration_seconds",
+	"Time spent handling HTTP requests, by route pattern & method.",
+	DefaultBucket,
+	"route", "method",
+)
+
+var RateLimitRejectedTotal = NewCounterVec(
+	"gitus_rate_limit_rejected_total",
+	"Number of requests rejected by the rate limiter.",
+)
+
+// protocol: "http-dumb", "http-v2", "ssh".
+// service: "git-upload-pack", "git-receive-pack", "git-upload-archive".
+var GitOperationTotal = NewCounterVec(
+	"gitus_git_operations_total",
+	"Number of git clone/fetch/push operations served, by protocol & service.",
+	"protocol", "service",
+)
+
+var DatabaseErrorTotal = NewCounterVec(
+	"gitus_database_errors_total",
+	"Number of errors returned by the database, by method.",
+	"method",
+)
+
+var SessionStoreErrorTotal = NewCounterVec(
+	"gitus_session_store_errors_total",
+	"Number of errors returned by the session store, by method.",
+	"method",
+)
+
+// serves the metrics. when `token` is not empty the request must
+// carry it as a bearer token.
+func Handler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(token) > 0 {
+			s, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(s), []byte(token)) != 1 {
+				w.WriteHeader(401)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteTo(w)
+	})
+}
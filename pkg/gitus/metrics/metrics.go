@@ -0,0 +1,226 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// a minimal implementation of prometheus metrics & its text
+// exposition format. see docs/metrics.org.
+
+type collector interface {
+	write(w io.Writer) error
+}
+
+var registryLock sync.Mutex
+var registry []collector = make([]collector, 0)
+
+func register(c collector) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registry = append(registry, c)
+}
+
+// writes all registered metrics in the prometheus text format.
+func WriteTo(w io.Writer) error {
+	registryLock.Lock()
+	l := make([]collector, len(registry))
+	copy(l, registry)
+	registryLock.Unlock()
+	for _, c := range l {
+		if err := c.write(w); err != nil { return err }
+	}
+	return nil
+}
+
+func escapeLabelValue(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, "\"", "\\\"")
+	return strings.ReplaceAll(s, "\n", "\\n")
+}
+
+func formatLabel(nameList []string, valueList []string, extra ...string) string {
+	if len(nameList) <= 0 && len(extra) <= 0 { return "" }
+	l := make([]string, 0, len(nameList) + len(extra)/2)
+	for i, n := range nameList {
+		l = append(l, fmt.Sprintf("%s=\"%s\"", n, escapeLabelValue(valueList[i])))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		l = append(l, fmt.Sprintf("%s=\"%s\"", extra[i], escapeLabelValue(extra[i+1])))
+	}
+	return "{" + strings.Join(l, ",") + "}"
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) { return "+Inf" }
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func writeHeader(w io.Writer, name string, help string, typ string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	return err
+}
+
+// label values are joined with this as the key of the maps below.
+const labelSeparator = "\xff"
+
+func sortedKey[T any](m map[string]T) []string {
+	res := make([]string, 0, len(m))
+	for k := range m { res = append(res, k) }
+	sort.Strings(res)
+	return res
+}
+
+type CounterVec struct {
+	name string
+	help string
+	labelName []string
+	lock sync.Mutex
+	value map[string]float64
+}
+
+func NewCounterVec(name string, help string, labelName ...string) *CounterVec {
+	res := &CounterVec{
+		name: name,
+		help: help,
+		labelName: labelName,
+		value: make(map[string]float64, 0),
+	}
+	register(res)
+	return res
+}
+
+func (c *CounterVec) Add(v float64, labelValue ...string) {
+	k := strings.Join(labelValue, labelSeparator)
+	c.lock.Lock()
+	c.value[k] += v
+	c.lock.Unlock()
+}
+
+func (c *CounterVec) Inc(labelValue ...string) {
+	c.Add(1, labelValue...)
+}
+
+func (c *CounterVec) write(w io.Writer) error {
+	if err := writeHeader(w, c.name, c.help, "counter"); err != nil { return err }
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	// an unlabelled counter is always exposed, even when it's zero.
+	if len(c.labelName) <= 0 {
+		_, err := fmt.Fprintf(w, "%s %s\n", c.name, formatFloat(c.value[""]))
+		return err
+	}
+	for _, k := range sortedKey(c.value) {
+		_, err := fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabel(c.labelName, strings.Split(k, labelSeparator)), formatFloat(c.value[k]))
+		if err != nil { return err }
+	}
+	return nil
+}
+
+// in seconds; the same as the default of the official client.
+var DefaultBucket = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type histogramEntry struct {
+	bucket []uint64
+	sum float64
+	count uint64
+}
+
+type HistogramVec struct {
+	name string
+	help string
+	labelName []string
+	bucket []float64
+	lock sync.Mutex
+	value map[string]*histogramEntry
+}
+
+func NewHistogramVec(name string, help string, bucket []float64, labelName ...string) *HistogramVec {
+	res := &HistogramVec{
+		name: name,
+		help: help,
+		labelName: labelName,
+		bucket: bucket,
+		value: make(map[string]*histogramEntry, 0),
+	}
+	register(res)
+	return res
+}
+
+func (h *HistogramVec) Observe(v float64, labelValue ...string) {
+	k := strings.Join(labelValue, labelSeparator)
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	e, ok := h.value[k]
+	if !ok {
+		e = &histogramEntry{ bucket: make([]uint64, len(h.bucket)) }
+		h.value[k] = e
+	}
+	for i, b := range h.bucket {
+		if v <= b { e.bucket[i] += 1 }
+	}
+	e.sum += v
+	e.count += 1
+}
+
+func (h *HistogramVec) write(w io.Writer) error {
+	if err := writeHeader(w, h.name, h.help, "histogram"); err != nil { return err }
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for _, k := range sortedKey(h.value) {
+		e := h.value[k]
+		var labelValue []string
+		if len(h.labelName) > 0 { labelValue = strings.Split(k, labelSeparator) }
+		for i, b := range h.bucket {
+			_, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabel(h.labelName, labelValue, "le", formatFloat(b)), e.bucket[i])
+			if err != nil { return err }
+		}
+		_, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabel(h.labelName, labelValue, "le", "+Inf"), e.count)
+		if err != nil { return err }
+		l := formatLabel(h.labelName, labelValue)
+		_, err = fmt.Fprintf(w, "%s_sum%s %s\n%s_count%s %d\n", h.name, l, formatFloat(e.sum), h.name, l, e.count)
+		if err != nil { return err }
+	}
+	return nil
+}
+
+type GaugeSample struct {
+	LabelValue []string
+	Value float64
+}
+
+// a gauge whose values are computed when the metrics are scraped.
+// when `f` fails the gauge is left out of the output.
+type GaugeFunc struct {
+	name string
+	help string
+	labelName []string
+	f func() ([]GaugeSample, error)
+}
+
+func NewGaugeFunc(name string, help string, f func() ([]GaugeSample, error), labelName ...string) *GaugeFunc {
+	res := &GaugeFunc{
+		name: name,
+		help: help,
+		labelName: labelName,
+		f: f,
+	}
+	register(res)
+	return res
+}
+
+func (g *GaugeFunc) write(w io.Writer) error {
+	l, err := g.f()
+	if err != nil { return nil }
+	if err = writeHeader(w, g.name, g.help, "gauge"); err != nil { return err }
+	for _, s := range l {
+		_, err := fmt.Fprintf(w, "%s%s %s\n", g.name, formatLabel(g.labelName, s.LabelValue), formatFloat(s.Value))
+		if err != nil { return err }
+	}
+	return nil
+}
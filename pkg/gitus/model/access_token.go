@@ -0,0 +1,49 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// a personal access token, used in place of the password when cloning
+// & fetching through http. only the hash of the token is stored; the
+// token itself is only shown once when it's created. see
+// docs/http-clone.org.
+type AccessToken struct {
+	UserName string `json:"userName"`
+	Name string `json:"name"`
+	// hex-encoded sha256 of the token.
+	TokenHash string `json:"tokenHash"`
+	RegisterTime int64 `json:"regTime"`
+	LastUsedTime int64 `json:"lastUsedTime"`
+	// unix time; 0 means the token never expires.
+	ExpireTime int64 `json:"expireTime"`
+}
+
+// the prefix makes the tokens easy to tell apart from passwords (and
+// easy to find by secret scanners).
+const ACCESS_TOKEN_PREFIX = "gitus_"
+
+func NewAccessTokenString() (string, error) {
+	b := make([]byte, 24)
+	_, err := rand.Read(b)
+	if err != nil { return "", err }
+	return ACCESS_TOKEN_PREFIX + hex.EncodeToString(b), nil
+}
+
+func IsAccessTokenString(s string) bool {
+	return strings.HasPrefix(s, ACCESS_TOKEN_PREFIX)
+}
+
+// tokens are random enough that a plain sha256 is sufficient; this
+// allows looking them up by the hash.
+func HashAccessToken(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+func (t *AccessToken) IsExpired(now int64) bool {
+	return t.ExpireTime > 0 && now >= t.ExpireTime
+}
@@ -21,6 +21,9 @@ type ACLTuple struct {
 type ACL struct {
 	Version string `json:"version"`
 	ACL map[string]*ACLTuple `json:"acl"`
+	// members must have two-factor authentication enabled. new
+	// members without it are refused.
+	Require2FA bool `json:"require2fa"`
 }
 
 func (aclt *ACLTuple) HasSettingPrivilege() bool {
@@ -82,3 +85,8 @@ func (acl *ACL) GetUserPrivilege(username string) *ACLTuple {
 }
 
 
+
+func (acl *ACL) Requires2FA() bool {
+	if acl == nil { return false }
+	return acl.Require2FA
+}
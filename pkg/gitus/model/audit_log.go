@@ -0,0 +1,94 @@
+package model
+
+// an entry of the security audit log. see docs/audit-log.org.
+type AuditLogEntry struct {
+	AbsId int64 `json:"id"`
+	Timestamp int64 `json:"timestamp"`
+	// the user who performed the action. for failed logins it's the
+	// username that was tried.
+	Actor string `json:"actor"`
+	IP string `json:"ip"`
+	// one of the AUDIT_* constants.
+	Action string `json:"action"`
+	// what the action is performed on, e.g. `user:alice`,
+	// `repo:ns:name`, `namespace:ns` or `config:mailer`.
+	Target string `json:"target"`
+	// a json object mapping the changed field to `[old, new]`, or
+	// holding other details of the action; empty if there's none.
+	Diff string `json:"diff"`
+}
+
+// empty fields are not used for filtering.
+type AuditLogFilter struct {
+	// exact match.
+	Actor string
+	// matches the action itself & the ones under it, e.g. "login"
+	// matches all the login actions but "session.revoke" doesn't
+	// match "session.revoke-all".
+	Action string
+	// substring match.
+	Target string
+	// exact match.
+	IP string
+	// unix time, inclusive; 0 means unbounded.
+	Since int64
+	Until int64
+}
+
+const (
+	AUDIT_LOGIN_SUCCESS = "login.success"
+	AUDIT_LOGIN_FAILURE = "login.failure"
+	AUDIT_LOGIN_2FA_SUCCESS = "login.2fa.success"
+	AUDIT_LOGIN_2FA_FAILURE = "login.2fa.failure"
+	AUDIT_SESSION_REVOKE = "session.revoke"
+	AUDIT_SESSION_REVOKE_ALL = "session.revoke-all"
+	AUDIT_SSH_KEY_ADD = "ssh-key.add"
+	AUDIT_SSH_KEY_UPDATE = "ssh-key.update"
+	AUDIT_SSH_KEY_REMOVE = "ssh-key.remove"
+	AUDIT_GPG_KEY_ADD = "gpg-key.add"
+	AUDIT_GPG_KEY_UPDATE = "gpg-key.update"
+	AUDIT_GPG_KEY_REMOVE = "gpg-key.remove"
+	AUDIT_ACCESS_TOKEN_ADD = "access-token.add"
+	AUDIT_ACCESS_TOKEN_REMOVE = "access-token.remove"
+	AUDIT_NAMESPACE_ACL = "namespace.acl"
+	AUDIT_NAMESPACE_REQUIRE_2FA = "namespace.require-2fa"
+	AUDIT_REPOSITORY_ACL = "repository.acl"
+	AUDIT_REPOSITORY_CREATE = "repository.create"
+	AUDIT_REPOSITORY_DELETE = "repository.delete"
+	AUDIT_REPOSITORY_STATUS = "repository.status"
+	AUDIT_REPOSITORY_MAINTENANCE = "repository.maintenance"
+	AUDIT_USER_STATUS = "user.status"
+	AUDIT_SITE_LOCKDOWN = "site.lockdown"
+	AUDIT_ADMIN_CONFIG = "admin.config"
+	AUDIT_ADMIN_CONFIG_RELOAD = "admin.config.reload"
+	AUDIT_ADMIN_QUOTA = "admin.quota"
+)
+
+var AuditActionList = []string{
+	AUDIT_LOGIN_SUCCESS,
+	AUDIT_LOGIN_FAILURE,
+	AUDIT_LOGIN_2FA_SUCCESS,
+	AUDIT_LOGIN_2FA_FAILURE,
+	AUDIT_SESSION_REVOKE,
+	AUDIT_SESSION_REVOKE_ALL,
+	AUDIT_SSH_KEY_ADD,
+	AUDIT_SSH_KEY_UPDATE,
+	AUDIT_SSH_KEY_REMOVE,
+	AUDIT_GPG_KEY_ADD,
+	AUDIT_GPG_KEY_UPDATE,
+	AUDIT_GPG_KEY_REMOVE,
+	AUDIT_ACCESS_TOKEN_ADD,
+	AUDIT_ACCESS_TOKEN_REMOVE,
+	AUDIT_NAMESPACE_ACL,
+	AUDIT_NAMESPACE_REQUIRE_2FA,
+	AUDIT_REPOSITORY_ACL,
+	AUDIT_REPOSITORY_CREATE,
+	AUDIT_REPOSITORY_DELETE,
+	AUDIT_REPOSITORY_STATUS,
+	AUDIT_REPOSITORY_MAINTENANCE,
+	AUDIT_USER_STATUS,
+	AUDIT_SITE_LOCKDOWN,
+	AUDIT_ADMIN_CONFIG,
+	AUDIT_ADMIN_CONFIG_RELOAD,
+	AUDIT_ADMIN_QUOTA,
+}
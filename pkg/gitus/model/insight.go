@@ -0,0 +1,52 @@
+package model
+
+import "time"
+
+// statistics shown on the "insight" page of a repository. these are
+// computed against the head of the default branch and are cached
+// with that head id; see docs/insight.org.
+
+type LanguageStat struct {
+	Language string
+	Bytes int64
+	// percentage of bytes, 0~100.
+	Percentage float64
+}
+
+type AuthorStat struct {
+	AuthorName string
+	AuthorEmail string
+	CommitCount int64
+	Addition int64
+	Deletion int64
+	FirstCommitTime time.Time
+	LastCommitTime time.Time
+	// commit count of each month in .MonthList of the insight this
+	// author stat belongs to.
+	MonthlyCommitCount []int64
+}
+
+type WeeklyCodeFrequency struct {
+	// the monday (UTC) the week starts with.
+	WeekStart time.Time
+	CommitCount int64
+	Addition int64
+	Deletion int64
+}
+
+type RepositoryInsight struct {
+	BranchName string
+	HeadId string
+	ComputedAt time.Time
+	TotalBytes int64
+	LanguageList []*LanguageStat
+	TotalCommit int64
+	// the months covered by .MonthlyCommitCount of each AuthorStat,
+	// oldest first.
+	MonthList []time.Time
+	AuthorList []*AuthorStat
+	CodeFrequency []*WeeklyCodeFrequency
+	// the max value of addition/deletion among .CodeFrequency; kept
+	// here for rendering the bars.
+	MaxWeeklyChange int64
+}
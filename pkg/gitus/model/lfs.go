@@ -0,0 +1,18 @@
+package model
+
+// a git lfs object stored for a repository. the content is stored on
+// disk (see pkg/gitus/lfs); the database keeps the list for accounting
+// the storage used by each repository. see docs/lfs.org.
+type LFSObject struct {
+	RepoNamespace string `json:"repoNs"`
+	RepoName string `json:"repoName"`
+	OID string `json:"oid"`
+	Size int64 `json:"size"`
+	UploadTime int64 `json:"uploadTime"`
+}
+
+// the storage used by the lfs objects of a repository.
+type LFSStorageUsage struct {
+	ObjectCount int64 `json:"objectCount"`
+	TotalSize int64 `json:"totalSize"`
+}
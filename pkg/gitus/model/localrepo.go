@@ -6,7 +6,6 @@ import (
 	"io"
 	"log"
 	"os"
-	"os/exec"
 	"os/user"
 	"strconv"
 
@@ -32,12 +31,13 @@ func CreateLocalRepository(repoType uint8, namespace string, name string, dirPat
 	}
 }
 
-func InitLocalRepository(lr LocalRepository) error {
-	switch GetGitusType(lr) {
+// initializes an empty repository at `dirPath`; this needs to happen
+// before `CreateLocalRepository` since the object format can't be
+// changed afterwards.
+func InitLocalRepository(repoType uint8, dirPath string, objectFormat gitlib.ObjectFormat) error {
+	switch repoType {
 	case REPO_TYPE_GIT:
-		cmd := exec.Command("git", "init", "--bare")
-		cmd.Dir = GetLocalRepositoryLocalPath(lr)
-		return cmd.Run()
+		return gitlib.InitBareRepository(dirPath, objectFormat)
 	default:
 		return ErrNotSupported
 	}
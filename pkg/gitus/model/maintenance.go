@@ -0,0 +1,40 @@
+package model
+
+// a run of one maintenance task on a repository. see
+// docs/repo-maintenance.org.
+type MaintenanceRun struct {
+	RepoNamespace string `json:"repoNs"`
+	RepoName string `json:"repoName"`
+	// one of the MAINTENANCE_TASK_* values.
+	Task string `json:"task"`
+	// MAINTENANCE_TRIGGER_SCHEDULE or the name of the admin who
+	// clicked "run now".
+	Trigger string `json:"trigger"`
+	StartTime int64 `json:"startTime"`
+	// in milliseconds.
+	Duration int64 `json:"duration"`
+	Success bool `json:"success"`
+	// the output of the git commands (truncated); mostly useful when
+	// the run has failed.
+	Output string `json:"output"`
+}
+
+const (
+	MAINTENANCE_TASK_REPACK = "repack"
+	MAINTENANCE_TASK_PRUNE = "prune"
+	MAINTENANCE_TASK_COMMIT_GRAPH = "commit-graph"
+	MAINTENANCE_TASK_FSCK = "fsck"
+	// fast-forwards the branches of a fork to its upstream. only runs
+	// on the forks that have auto-sync turned on.
+	MAINTENANCE_TASK_FORK_SYNC = "fork-sync"
+)
+
+var MaintenanceTaskList = []string{
+	MAINTENANCE_TASK_REPACK,
+	MAINTENANCE_TASK_PRUNE,
+	MAINTENANCE_TASK_COMMIT_GRAPH,
+	MAINTENANCE_TASK_FSCK,
+	MAINTENANCE_TASK_FORK_SYNC,
+}
+
+const MAINTENANCE_TRIGGER_SCHEDULE = "schedule"
@@ -0,0 +1,13 @@
+package model
+
+// the link between a user & their account on an openid connect
+// identity provider. see docs/oidc.org.
+type OIDCLink struct {
+	// the id of the provider in the config.
+	ProviderId string `json:"providerId"`
+	// the "sub" claim, i.e. the id of the user at the provider.
+	Subject string `json:"subject"`
+	UserName string `json:"userName"`
+	LinkTime int64 `json:"linkTime"`
+}
+
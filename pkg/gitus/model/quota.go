@@ -0,0 +1,47 @@
+package model
+
+// storage quotas. see docs/quota.org.
+
+const (
+	QUOTA_SUBJECT_SITE = "site"
+	QUOTA_SUBJECT_USER = "user"
+	QUOTA_SUBJECT_NAMESPACE = "namespace"
+)
+
+// the limits of a user, a namespace or the whole site. sizes are in
+// bytes; 0 means no limit. in the per-user & per-namespace quotas
+// stored in the database, QUOTA_DEFAULT means the default in the
+// config is used.
+type Quota struct {
+	RepositorySize int64 `json:"repositorySize"`
+	LFSSize int64 `json:"lfsSize"`
+	// only meaningful for users & the site since snippets belong to
+	// users.
+	SnippetSize int64 `json:"snippetSize"`
+	RepositoryCount int64 `json:"repositoryCount"`
+}
+
+const QUOTA_DEFAULT int64 = -1
+
+// fills the fields set to QUOTA_DEFAULT w/ the ones from `d`.
+func (q *Quota) WithDefault(d *Quota) *Quota {
+	pick := func(v int64, dv int64) int64 {
+		if v == QUOTA_DEFAULT { return dv }
+		return v
+	}
+	return &Quota{
+		RepositorySize: pick(q.RepositorySize, d.RepositorySize),
+		LFSSize: pick(q.LFSSize, d.LFSSize),
+		SnippetSize: pick(q.SnippetSize, d.SnippetSize),
+		RepositoryCount: pick(q.RepositoryCount, d.RepositoryCount),
+	}
+}
+
+// what's counted against a quota. the size of a repository is the
+// size of its directory as last measured (see docs/quota.org).
+type QuotaUsage struct {
+	RepositorySize int64 `json:"repositorySize"`
+	LFSSize int64 `json:"lfsSize"`
+	SnippetSize int64 `json:"snippetSize"`
+	RepositoryCount int64 `json:"repositoryCount"`
+}
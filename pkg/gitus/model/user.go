@@ -44,6 +44,32 @@ type GitusUser2FAConfig struct {
 	Email struct{
 		Enable bool `json:"enable"`
 	} `json:"email"`
+	TOTP struct{
+		Enable bool `json:"enable"`
+		// base32-encoded secret. this is set when the user starts
+		// enrolling; .Enable is only set after the user has proved
+		// to have the secret by entering a valid code.
+		Secret string `json:"secret"`
+		// the time step of the last accepted code. codes of this
+		// step & the ones before are rejected so that a code can
+		// only be used once.
+		LastUsedStep int64 `json:"lastUsedStep"`
+	} `json:"totp"`
+	// set when the user has at least one webauthn credential; the
+	// credentials themselves are stored separately.
+	WebAuthn struct{
+		Enable bool `json:"enable"`
+	} `json:"webauthn"`
+	// bcrypt hashes of unused recovery codes. a code is removed from
+	// this list once it's used.
+	RecoveryCode []string `json:"recoveryCode"`
+	// require a totp code (or a recovery code) when adding or
+	// editing ssh keys.
+	RequireForSSHKey bool `json:"requireForSSHKey"`
+}
+
+func (c GitusUser2FAConfig) IsEnabled() bool {
+	return c.Email.Enable || c.TOTP.Enable || c.WebAuthn.Enable
 }
 
 type GitusUserWebsitePreference struct {
@@ -0,0 +1,19 @@
+package model
+
+// a webauthn credential (security key or passkey) registered by a
+// user. see docs/webauthn.org.
+type WebAuthnCredential struct {
+	UserName string `json:"userName"`
+	// base64url (without padding) of the credential id.
+	CredentialId string `json:"credentialId"`
+	Name string `json:"name"`
+	// cbor-encoded COSE_Key.
+	PublicKey []byte `json:"publicKey"`
+	SignCount int64 `json:"signCount"`
+	// whether this credential can be used to log in without
+	// password. only discoverable credentials registered with user
+	// verification can be passkeys.
+	Passkey bool `json:"passkey"`
+	RegisterTime int64 `json:"regTime"`
+	LastUsedTime int64 `json:"lastUsedTime"`
+}
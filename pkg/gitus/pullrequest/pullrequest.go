@@ -0,0 +1,62 @@
+package pullrequest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitlib"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+)
+
+// keeping the pull requests in sync w/ their provider branches. see
+// docs/pull-request.org.
+
+// the commit the provider branch of the pull request points to now.
+// returns gitlib.ErrRefNotFound when the branch doesn't exist anymore.
+func ResolveProviderHead(cfg *gitus.GitusConfig, pr *model.PullRequest) (string, error) {
+	lgr := gitlib.NewLocalGitRepository(path.Join(cfg.GitRoot, pr.ProviderNamespace, pr.ProviderName))
+	return lgr.ResolveRef(fmt.Sprintf("refs/heads/%s", pr.ProviderBranch))
+}
+
+// records the current provider head of a newly created pull request so
+// that the pushes after it can be told apart.
+func TrackProviderHead(cfg *gitus.GitusConfig, dbif db.GitusDatabaseInterface, pr *model.PullRequest) error {
+	head, err := ResolveProviderHead(cfg, pr)
+	if err != nil { return err }
+	return dbif.SetPullRequestProviderHead(pr.PRAbsId, head)
+}
+
+// called after `ns:name` is updated (e.g. pushed to). every open pull
+// request whose provider branch is in `ns:name` & has moved since it
+// was last seen gets a PULL_REQUEST_EVENT_UPDATE_ON_BRANCH event by
+// `author` & has its merge conflict checked again. the pull requests
+// whose head was never recorded only get it recorded, & the ones whose
+// provider branch is gone are left alone. the checks are run w/ `ctx`.
+func SyncProviderBranch(ctx context.Context, cfg *gitus.GitusConfig, dbif db.GitusDatabaseInterface, ns string, name string, author string) error {
+	l, err := dbif.GetOpenPullRequestOfProvider(ns, name)
+	if err != nil { return err }
+	errList := make([]error, 0)
+	for _, pr := range l {
+		err = syncPullRequest(ctx, cfg, dbif, pr, author)
+		if err != nil { errList = append(errList, fmt.Errorf("pull request %s:%s#%d: %w", pr.ReceiverNamespace, pr.ReceiverName, pr.PRId, err)) }
+	}
+	return errors.Join(errList...)
+}
+
+func syncPullRequest(ctx context.Context, cfg *gitus.GitusConfig, dbif db.GitusDatabaseInterface, pr *model.PullRequest, author string) error {
+	head, err := ResolveProviderHead(cfg, pr)
+	if errors.Is(err, gitlib.ErrRefNotFound) { return nil }
+	if err != nil { return err }
+	lastHead, err := dbif.GetPullRequestProviderHead(pr.PRAbsId)
+	if err != nil { return err }
+	if head == lastHead { return nil }
+	if len(lastHead) <= 0 { return dbif.SetPullRequestProviderHead(pr.PRAbsId, head) }
+	_, err = dbif.RecordPullRequestBranchUpdate(pr.PRAbsId, author, head)
+	if err != nil { return err }
+	_, err = dbif.CheckPullRequestMergeConflict(ctx, pr.PRAbsId)
+	return err
+}
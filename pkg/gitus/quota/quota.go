@@ -0,0 +1,264 @@
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitlib"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+)
+
+// storage quotas of the site, the users & the namespaces. see
+// docs/quota.org.
+
+type ErrQuotaExceeded struct {
+	SubjectType string
+	SubjectName string
+	// e.g. "repository size".
+	Item string
+	Usage int64
+	Delta int64
+	Limit int64
+	// whether the numbers are sizes in bytes.
+	IsSize bool
+}
+
+func (e *ErrQuotaExceeded) Error() string {
+	subject := "this site"
+	if e.SubjectType != model.QUOTA_SUBJECT_SITE {
+		subject = fmt.Sprintf("%s %s", e.SubjectType, e.SubjectName)
+	}
+	f := func(n int64) string { return fmt.Sprintf("%d", n) }
+	if e.IsSize { f = FormatSize }
+	return fmt.Sprintf("The %s quota of %s has been exceeded: %s used, %s more requested, %s allowed.", e.Item, subject, f(e.Usage), f(e.Delta), f(e.Limit))
+}
+
+// formats a size in bytes with binary units, e.g. 1.5 MiB.
+func FormatSize(n int64) string {
+	if n < 1024 { return fmt.Sprintf("%d B", n) }
+	f := float64(n)
+	for _, unit := range []string{"KiB", "MiB", "GiB", "TiB"} {
+		f /= 1024
+		if f < 1024 || unit == "TiB" { return fmt.Sprintf("%.1f %s", f, unit) }
+	}
+	return ""
+}
+
+// the total size of the regular files under `p`. files removed while
+// walking (e.g. by a concurrent `git gc`) are skipped.
+func MeasureDirectory(p string) (int64, error) {
+	var res int64 = 0
+	err := filepath.WalkDir(p, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) { return nil }
+			return err
+		}
+		if !d.Type().IsRegular() { return nil }
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) { return nil }
+			return err
+		}
+		res += info.Size()
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) { return 0, nil }
+	return res, err
+}
+
+// measures the repository on disk & saves its size.
+func RefreshRepositorySize(cfg *gitus.GitusConfig, dbif db.GitusDatabaseInterface, ns string, name string) (int64, error) {
+	size, err := MeasureDirectory(path.Join(cfg.GitRoot, ns, name))
+	if err != nil { return 0, err }
+	err = dbif.UpdateRepositorySize(ns, name, size)
+	if err != nil { return 0, err }
+	return size, nil
+}
+
+// the limits that apply to the subject, i.e. the override set on the
+// admin panel merged w/ the defaults in the config.
+func GetEffectiveQuota(cfg *gitus.GitusConfig, dbif db.GitusDatabaseInterface, subjectType string, name string) (*model.Quota, error) {
+	var d *model.Quota
+	switch subjectType {
+	case model.QUOTA_SUBJECT_SITE:
+		q := cfg.Quota.Site
+		return &q, nil
+	case model.QUOTA_SUBJECT_USER: d = &cfg.Quota.User
+	case model.QUOTA_SUBJECT_NAMESPACE: d = &cfg.Quota.Namespace
+	default: return nil, fmt.Errorf("Unknown quota subject type: %s", subjectType)
+	}
+	q, err := dbif.GetQuota(subjectType, name)
+	if errors.Is(err, db.ErrEntityNotFound) {
+		res := *d
+		return &res, nil
+	}
+	if err != nil { return nil, err }
+	return q.WithDefault(d), nil
+}
+
+// the size of the snippets of a user, or of all users for the site.
+// snippets don't belong to namespaces.
+func GetSnippetUsage(cfg *gitus.GitusConfig, subjectType string, name string) (int64, error) {
+	if cfg.SnippetRoot == "" { return 0, nil }
+	switch subjectType {
+	case model.QUOTA_SUBJECT_SITE: return MeasureDirectory(cfg.SnippetRoot)
+	case model.QUOTA_SUBJECT_USER: return MeasureDirectory(path.Join(cfg.SnippetRoot, name))
+	}
+	return 0, nil
+}
+
+func GetUsage(cfg *gitus.GitusConfig, dbif db.GitusDatabaseInterface, subjectType string, name string) (*model.QuotaUsage, error) {
+	res, err := dbif.GetQuotaUsage(subjectType, name)
+	if err != nil { return nil, err }
+	res.SnippetSize, err = GetSnippetUsage(cfg, subjectType, name)
+	if err != nil { return nil, err }
+	return res, nil
+}
+
+// checks whether adding `delta` to the repository (or snippet) in the
+// namespace `ns` owned by the user `owner` would exceed any of the
+// quotas of the site, the namespace & the owner. `ns` & `owner` can be
+// empty, in which case the corresponding quota is not checked. always
+// succeeds when quotas are disabled. returns *ErrQuotaExceeded when a
+// quota would be exceeded.
+func Check(cfg *gitus.GitusConfig, dbif db.GitusDatabaseInterface, ns string, owner string, delta *model.QuotaUsage) error {
+	if !cfg.Quota.Enable { return nil }
+	if !cfg.IsInForgeMode() { return nil }
+	err := checkSubject(cfg, dbif, model.QUOTA_SUBJECT_SITE, "", delta)
+	if err != nil { return err }
+	if ns != "" {
+		err = checkSubject(cfg, dbif, model.QUOTA_SUBJECT_NAMESPACE, ns, delta)
+		if err != nil { return err }
+	}
+	if owner != "" {
+		err = checkSubject(cfg, dbif, model.QUOTA_SUBJECT_USER, owner, delta)
+		if err != nil { return err }
+	}
+	return nil
+}
+
+func checkSubject(cfg *gitus.GitusConfig, dbif db.GitusDatabaseInterface, subjectType string, name string, delta *model.QuotaUsage) error {
+	q, err := GetEffectiveQuota(cfg, dbif, subjectType, name)
+	if err != nil { return err }
+	exceeded := func(limit int64, usage int64, d int64) bool {
+		return limit > 0 && d > 0 && usage + d > limit
+	}
+	mkerr := func(item string, usage int64, d int64, limit int64, isSize bool) error {
+		return &ErrQuotaExceeded{
+			SubjectType: subjectType,
+			SubjectName: name,
+			Item: item,
+			Usage: usage,
+			Delta: d,
+			Limit: limit,
+			IsSize: isSize,
+		}
+	}
+	u, err := dbif.GetQuotaUsage(subjectType, name)
+	if err != nil { return err }
+	if exceeded(q.RepositoryCount, u.RepositoryCount, delta.RepositoryCount) {
+		return mkerr("repository count", u.RepositoryCount, delta.RepositoryCount, q.RepositoryCount, false)
+	}
+	if exceeded(q.RepositorySize, u.RepositorySize, delta.RepositorySize) {
+		return mkerr("repository size", u.RepositorySize, delta.RepositorySize, q.RepositorySize, true)
+	}
+	if exceeded(q.LFSSize, u.LFSSize, delta.LFSSize) {
+		return mkerr("Git LFS storage", u.LFSSize, delta.LFSSize, q.LFSSize, true)
+	}
+	// measuring the snippets means walking the directories, so it's
+	// only done when needed.
+	if q.SnippetSize > 0 && delta.SnippetSize > 0 {
+		s, err := GetSnippetUsage(cfg, subjectType, name)
+		if err != nil { return err }
+		if exceeded(q.SnippetSize, s, delta.SnippetSize) {
+			return mkerr("snippet storage", s, delta.SnippetSize, q.SnippetSize, true)
+		}
+	}
+	return nil
+}
+
+// the hooks of the pushes over ssh are run from a directory owned by
+// gitus (w/ `core.hooksPath`) instead of the repository's own, so that
+// the hooks the repository owner manages from the web ui are neither
+// overwritten nor able to skip the quota check. the pre-receive hook
+// checks the quotas & then runs the repository's own pre-receive hook;
+// every other hook just runs the repository's own. the variables are
+// only set for the pushes over ssh.
+const QUOTA_HOOK_DIRECTORY = ".gitus-hooks"
+
+// the git config set by the environment is dropped before running the
+// repository's own hooks so that the git commands they run are not
+// affected by it.
+const quotaHookChainTemplate = `h="$GITUS_HOOK_GIT_DIR/hooks/%s"
+[ -x "$h" ] || exit 0
+unset GIT_CONFIG_COUNT GIT_CONFIG_KEY_0 GIT_CONFIG_VALUE_0
+`
+
+const PRE_RECEIVE_HOOK = `#!/bin/sh
+# installed by gitus to enforce storage quotas. see docs/quota.org.
+[ -n "$GITUS_HOOK_EXECUTABLE" ] || exit 0
+check() {
+	"$GITUS_HOOK_EXECUTABLE" -config "$GITUS_HOOK_CONFIG" quota pre-receive "$GITUS_HOOK_REPOSITORY"
+}
+h="$GITUS_HOOK_GIT_DIR/hooks/pre-receive"
+if ! [ -x "$h" ]; then
+	check
+	exit $?
+fi
+input=$(cat)
+printf '%s\n' "$input" | check || exit 1
+unset GIT_CONFIG_COUNT GIT_CONFIG_KEY_0 GIT_CONFIG_VALUE_0
+printf '%s\n' "$input" | "$h" "$@"
+`
+
+func quotaHookContent(name string) string {
+	if name == "pre-receive" { return PRE_RECEIVE_HOOK }
+	return "#!/bin/sh\n# installed by gitus; runs the repository's own hook. see docs/quota.org.\n" +
+		fmt.Sprintf(quotaHookChainTemplate, name) +
+		"exec \"$h\" \"$@\"\n"
+}
+
+func writeHookIfChanged(p string, content string) error {
+	b, err := os.ReadFile(p)
+	if err == nil && string(b) == content { return nil }
+	err = os.WriteFile(p, []byte(content), 0755)
+	if err != nil { return err }
+	// WriteFile doesn't change the mode of an existing file.
+	return os.Chmod(p, 0755)
+}
+
+// installs the hooks in QUOTA_HOOK_DIRECTORY if they're not there yet
+// & returns the environment variables that should be passed to
+// git-receive-pack.
+func PrepareReceivePack(cfg *gitus.GitusConfig, repo *model.Repository) ([]string, error) {
+	hookDir, err := filepath.Abs(path.Join(cfg.GitRoot, QUOTA_HOOK_DIRECTORY))
+	if err != nil { return nil, err }
+	err = os.MkdirAll(hookDir, 0755)
+	if err != nil { return nil, err }
+	for _, name := range gitlib.HookList {
+		err = writeHookIfChanged(path.Join(hookDir, name), quotaHookContent(name))
+		if err != nil { return nil, err }
+	}
+	gitDir, err := filepath.Abs(path.Join(cfg.GitRoot, repo.Namespace, repo.Name))
+	if err != nil { return nil, err }
+	exe, err := os.Executable()
+	if err != nil { return nil, err }
+	// the hook is run in the repository's directory.
+	configPath, err := filepath.Abs(cfg.FilePath)
+	if err != nil { return nil, err }
+	return []string{
+		"GITUS_HOOK_EXECUTABLE=" + exe,
+		"GITUS_HOOK_CONFIG=" + configPath,
+		"GITUS_HOOK_REPOSITORY=" + repo.FullName(),
+		"GITUS_HOOK_GIT_DIR=" + gitDir,
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=core.hooksPath",
+		"GIT_CONFIG_VALUE_0=" + hookDir,
+	}, nil
+}
@@ -206,3 +206,42 @@ WHERE id = $4
 	return nil
 }
 
+
+func (rsif *GitusPostgresReceiptSystemInterface) ExportAllReceipt() ([]*receipt.Receipt, error) {
+	pfx := rsif.config.ReceiptSystem.TablePrefix
+	ctx := context.Background()
+	stmt, err := rsif.pool.Query(ctx, fmt.Sprintf(`
+SELECT id, command, issue_time, timeout_minute
+FROM %s_receipt
+ORDER BY issue_time ASC`, pfx))
+	if err != nil { return nil, err }
+	defer stmt.Close()
+	res := make([]*receipt.Receipt, 0)
+	var id, command string
+	var issueTime time.Time
+	var timeoutMinute int64
+	for stmt.Next() {
+		err = stmt.Scan(&id, &command, &issueTime, &timeoutMinute)
+		if err != nil { return nil, err }
+		// TIMESTAMP is written with local time & read back as the
+		// same wall clock in UTC.
+		t := time.Date(issueTime.Year(), issueTime.Month(), issueTime.Day(), issueTime.Hour(), issueTime.Minute(), issueTime.Second(), 0, time.Local)
+		res = append(res, &receipt.Receipt{
+			Id: id,
+			Command: receipt.ParseReceiptCommand(command),
+			IssueTime: t.Unix(),
+			TimeoutMinute: timeoutMinute,
+		})
+	}
+	return res, stmt.Err()
+}
+
+func (rsif *GitusPostgresReceiptSystemInterface) ImportReceipt(robj *receipt.Receipt) error {
+	pfx := rsif.config.ReceiptSystem.TablePrefix
+	ctx := context.Background()
+	_, err := rsif.pool.Exec(ctx, fmt.Sprintf(`
+INSERT INTO %s_receipt(id, command, issue_time, timeout_minute)
+VALUES ($1,$2,$3,$4)
+`, pfx), robj.Id, receipt.SerializeReceiptCommand(robj.Command), time.Unix(robj.IssueTime, 0), robj.TimeoutMinute)
+	return err
+}
@@ -26,6 +26,10 @@ type GitusReceiptSystemInterface interface {
 	GetAllReceipt(pageNum int, pageSize int) ([]*Receipt, error)
 	SearchReceipt(q string, pageNum int, pageSize int) ([]*Receipt, error)
 	EditReceipt(id string, robj *Receipt) error
+	// used by `gitus backup` & `gitus restore`. the receipts are
+	// imported with their original ids.
+	ExportAllReceipt() ([]*Receipt, error)
+	ImportReceipt(robj *Receipt) error
 }
 
 const passchdict = "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
@@ -210,3 +210,36 @@ WHERE id = ?
 	return nil
 }
 
+
+func (rsif *GitusSqliteReceiptSystemInterface) ExportAllReceipt() ([]*receipt.Receipt, error) {
+	pfx := rsif.config.ReceiptSystem.TablePrefix
+	r, err := rsif.connection.Query(fmt.Sprintf(`
+SELECT id, command, issue_time, timeout_minute
+FROM %s_receipt
+ORDER BY rowid ASC`, pfx))
+	if err != nil { return nil, err }
+	defer r.Close()
+	res := make([]*receipt.Receipt, 0)
+	var id, command string
+	var issueTime, timeoutMinute int64
+	for r.Next() {
+		err = r.Scan(&id, &command, &issueTime, &timeoutMinute)
+		if err != nil { return nil, err }
+		res = append(res, &receipt.Receipt{
+			Id: id,
+			Command: receipt.ParseReceiptCommand(command),
+			IssueTime: issueTime,
+			TimeoutMinute: timeoutMinute,
+		})
+	}
+	return res, r.Err()
+}
+
+func (rsif *GitusSqliteReceiptSystemInterface) ImportReceipt(robj *receipt.Receipt) error {
+	pfx := rsif.config.ReceiptSystem.TablePrefix
+	_, err := rsif.connection.Exec(fmt.Sprintf(`
+INSERT INTO %s_receipt(id, command, issue_time, timeout_minute)
+VALUES (?,?,?,?)
+`, pfx), robj.Id, receipt.SerializeReceiptCommand(robj.Command), robj.IssueTime, robj.TimeoutMinute)
+	return err
+}
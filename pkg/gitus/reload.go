@@ -0,0 +1,173 @@
+package gitus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// things used for reloading the config while gitus is running. see
+// docs/config-reload.org.
+
+// the settings that are only used when gitus starts, by their json
+// names. changing these requires a restart to take effect.
+var restartRequiredSetting = []string{
+	"root", "gitUser", "enableNamespace", "operationMode",
+	"bindAddress", "bindPort", "staticAssetDirectory",
+	"database", "sshServer", "metrics", "log",
+}
+
+func settingByJSONName(cfg *GitusConfig, name string) reflect.Value {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		n, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if n == name { return v.Field(i) }
+	}
+	return reflect.Value{}
+}
+
+// returns the json names of the settings that are different in `a`
+// & `b`.
+func ChangedSetting(a *GitusConfig, b *GitusConfig, nameList []string) []string {
+	res := make([]string, 0)
+	for _, name := range nameList {
+		va := settingByJSONName(a, name)
+		vb := settingByJSONName(b, name)
+		if !va.IsValid() || !vb.IsValid() { continue }
+		if !reflect.DeepEqual(va.Interface(), vb.Interface()) {
+			res = append(res, name)
+		}
+	}
+	return res
+}
+
+// returns the json names of the restart-requiring settings that are
+// different in `a` & `b`.
+func RestartRequiredSettingChange(a *GitusConfig, b *GitusConfig) []string {
+	return ChangedSetting(a, b, restartRequiredSetting)
+}
+
+// returns a copy of the config that doesn't share anything with it.
+func (cfg *GitusConfig) Clone() (*GitusConfig, error) {
+	s, err := json.Marshal(cfg)
+	if err != nil { return nil, err }
+	var c GitusConfig
+	err = json.Unmarshal(s, &c)
+	if err != nil { return nil, err }
+	c.FilePath = cfg.FilePath
+	err = c.RecalculateProperPath()
+	if err != nil { return nil, err }
+	return &c, nil
+}
+
+// replaces all the settings with the ones in `src`. the caller
+// should hold the lock (LockForSync).
+func (cfg *GitusConfig) Assign(src *GitusConfig) error {
+	dst := reflect.ValueOf(cfg).Elem()
+	s := reflect.ValueOf(src).Elem()
+	t := dst.Type()
+	for i := 0; i < t.NumField(); i++ {
+		// the unexported ones are the lock & the things calculated
+		// by RecalculateProperPath.
+		if !t.Field(i).IsExported() { continue }
+		dst.Field(i).Set(s.Field(i))
+	}
+	return cfg.RecalculateProperPath()
+}
+
+type pendingRestartSetting struct {
+	// the json names of the settings.
+	name []string
+	// the values in effect when the config file was read.
+	running *GitusConfig
+	// the values in the config file.
+	saved *GitusConfig
+}
+
+// the same as Assign but the restart-requiring settings are kept as
+// they are in `running` (the config gitus was started with), since
+// the things they're used for are only set up when starting. the
+// values in `src` are kept so that they're written back by Sync
+// instead of the running ones.
+func (cfg *GitusConfig) AssignReloadable(src *GitusConfig, running *GitusConfig) error {
+	changed := RestartRequiredSettingChange(running, src)
+	var pending *pendingRestartSetting
+	if len(changed) > 0 {
+		saved, err := src.Clone()
+		if err != nil { return err }
+		r, err := running.Clone()
+		if err != nil { return err }
+		pending = &pendingRestartSetting{ name: changed, running: r, saved: saved }
+	}
+	for _, name := range restartRequiredSetting {
+		settingByJSONName(src, name).Set(settingByJSONName(running, name))
+	}
+	err := cfg.Assign(src)
+	if err != nil { return err }
+	cfg.pendingRestart = pending
+	return nil
+}
+
+// the config as it would be written to the config file, i.e. w/ the
+// restart-requiring settings that are read from the file but aren't
+// in effect yet, unless they've been changed since (e.g. on the admin
+// pages).
+func (cfg *GitusConfig) Saved() *GitusConfig {
+	if cfg.pendingRestart == nil { return cfg }
+	c, err := cfg.Clone()
+	if err != nil { return cfg }
+	for _, name := range cfg.pendingRestart.name {
+		v := settingByJSONName(cfg, name)
+		if !reflect.DeepEqual(v.Interface(), settingByJSONName(cfg.pendingRestart.running, name).Interface()) { continue }
+		settingByJSONName(c, name).Set(settingByJSONName(cfg.pendingRestart.saved, name))
+	}
+	return c
+}
+
+var ErrInvalidConfig = errors.New("INVALID_CONFIG: the configuration is invalid")
+
+func invalidConfig(format string, a ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidConfig, fmt.Sprintf(format, a...))
+}
+
+// checks the things that would make gitus misbehave instead of
+// failing to start. backends that can't be initialized are reported
+// when they're initialized.
+func (cfg *GitusConfig) Validate() error {
+	if cfg.Version != 0 { return invalidConfig("unsupported version %d", cfg.Version) }
+	switch cfg.OperationMode {
+	case OP_MODE_BROWSE_ONLY, OP_MODE_HOST, OP_MODE_FORGE:
+	default:
+		return invalidConfig("unknown operation mode %q", cfg.OperationMode)
+	}
+	switch cfg.GlobalVisibility {
+	case "", GLOBAL_VISIBILITY_PUBLIC, GLOBAL_VISIBILITY_PRIVATE, GLOBAL_VISIBILITY_SHUTDOWN, GLOBAL_VISIBILITY_MAINTENANCE:
+	default:
+		return invalidConfig("unknown global visibility %q", cfg.GlobalVisibility)
+	}
+	if strings.TrimSpace(cfg.GitRoot) == "" { return invalidConfig("root is empty") }
+	if cfg.BindPort <= 0 || cfg.BindPort > 65535 { return invalidConfig("invalid bindPort %d", cfg.BindPort) }
+	if cfg.SSHServer.Enable && (cfg.SSHServer.BindPort <= 0 || cfg.SSHServer.BindPort > 65535) {
+		return invalidConfig("invalid sshServer.bindPort %d", cfg.SSHServer.BindPort)
+	}
+	if cfg.Metrics.BindPort < 0 || cfg.Metrics.BindPort > 65535 {
+		return invalidConfig("invalid metrics.bindPort %d", cfg.Metrics.BindPort)
+	}
+	switch cfg.Mailer.Type {
+	case "", "gmail-plain", "smtp":
+	default:
+		return invalidConfig("unknown mailer type %q", cfg.Mailer.Type)
+	}
+	if cfg.MaxRequestInSecond < 0 { return invalidConfig("maxRequestInSecond is negative") }
+	if cfg.MaxSessionLifetime < 0 { return invalidConfig("maxSessionLifetime is negative") }
+	if cfg.ShutdownTimeout < 0 { return invalidConfig("shutdownTimeout is negative") }
+	if cfg.GitConfig.LFS.Enable {
+		if strings.TrimSpace(cfg.GitConfig.LFS.Root) == "" { return invalidConfig("gitConfig.lfs.root is empty") }
+		if cfg.JWTSecret == "" { return invalidConfig("jwtSecret must be set to enable gitConfig.lfs") }
+	}
+	if cfg.GitConfig.LFS.MaxObjectSize < 0 { return invalidConfig("gitConfig.lfs.maxObjectSize is negative") }
+	return nil
+}
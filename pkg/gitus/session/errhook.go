@@ -0,0 +1,70 @@
+// generated by devtools/generate-error-hook.go. DO NOT EDIT
+
+package session
+
+// wraps a GitusSessionStore & calls `Hook` with the name of the method
+// whenever a method returns a non-nil error.
+type ErrorHookedGitusSessionStore struct {
+	GitusSessionStore
+	Hook func(method string, err error)
+}
+
+func (h *ErrorHookedGitusSessionStore) Install() error {
+	r0 := h.GitusSessionStore.Install()
+	if r0 != nil { h.Hook("Install", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusSessionStore) Dispose() error {
+	r0 := h.GitusSessionStore.Dispose()
+	if r0 != nil { h.Hook("Dispose", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusSessionStore) IsSessionStoreUsable() (bool, error) {
+	r0, r1 := h.GitusSessionStore.IsSessionStoreUsable()
+	if r1 != nil { h.Hook("IsSessionStoreUsable", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusSessionStore) RegisterSession(a0 string, a1 string) (*GitusSession, error) {
+	r0, r1 := h.GitusSessionStore.RegisterSession(a0, a1)
+	if r1 != nil { h.Hook("RegisterSession", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusSessionStore) RetrieveSession(a0 string) ([]*GitusSession, error) {
+	r0, r1 := h.GitusSessionStore.RetrieveSession(a0)
+	if r1 != nil { h.Hook("RetrieveSession", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusSessionStore) RetrieveSessionByKey(a0 string, a1 string) (*GitusSession, error) {
+	r0, r1 := h.GitusSessionStore.RetrieveSessionByKey(a0, a1)
+	if r1 != nil { h.Hook("RetrieveSessionByKey", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusSessionStore) RevokeSession(a0 string, a1 string) error {
+	r0 := h.GitusSessionStore.RevokeSession(a0, a1)
+	if r0 != nil { h.Hook("RevokeSession", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusSessionStore) RevokeAllSession(a0 string) error {
+	r0 := h.GitusSessionStore.RevokeAllSession(a0)
+	if r0 != nil { h.Hook("RevokeAllSession", r0) }
+	return r0
+}
+
+func (h *ErrorHookedGitusSessionStore) VerifySessionExist(a0 string, a1 string) (bool, error) {
+	r0, r1 := h.GitusSessionStore.VerifySessionExist(a0, a1)
+	if r1 != nil { h.Hook("VerifySessionExist", r1) }
+	return r0, r1
+}
+
+func (h *ErrorHookedGitusSessionStore) VerifySessionFull(a0 string, a1 string, a2 string) (bool, error) {
+	r0, r1 := h.GitusSessionStore.VerifySessionFull(a0, a1, a2)
+	if r1 != nil { h.Hook("VerifySessionFull", r1) }
+	return r0, r1
+}
@@ -0,0 +1,214 @@
+package ssh
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/lfs"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/quota"
+	"github.com/GitusCodeForge/Gitus/pkg/shellparse"
+)
+
+// the access check of git over ssh in forge mode. this is shared by
+// `gitus ssh` (called through authorized_keys) & the built-in ssh
+// server so that both of them give the same verdict. the push check is
+// used by git lfs uploads over http as well.
+
+var ErrInvalidSSHCommand = errors.New("Invalid SSH command")
+var ErrInvalidRepositoryPath = errors.New("Invalid repository path specification.")
+var ErrNotEnoughPermission = errors.New("Not enough permission.")
+var ErrLFSNotEnabled = errors.New("Git LFS is not enabled on this instance.")
+
+func IsValidGitSSHCommand(s string) bool {
+	return (s == "git-upload-pack" || s == "git-receive-pack" || s == "git-upload-archive" || s == lfs.SSH_AUTHENTICATE_COMMAND)
+}
+
+// parses the repository path sent by the git client, e.g. `/ns/repo`,
+// `~ns/repo` or `ns:repo`, into the namespace name & the repository
+// name.
+func ParseRepositoryPath(cfg *gitus.GitusConfig, relPath string) (string, string, error) {
+	if len(relPath) <= 0 { return "", "", ErrInvalidRepositoryPath }
+	if relPath[0] == '~' || relPath[0] == '/' {
+		relPath = relPath[1:]
+	}
+	relPathSegment := strings.SplitN(relPath, "/", 2)
+	if cfg.UseNamespace {
+		if len(relPathSegment) <= 1 {
+			relPathSegment = strings.SplitN(relPath, ":", 2)
+			if len(relPathSegment) <= 1 { return "", "", ErrInvalidRepositoryPath }
+		}
+		return relPathSegment[0], relPathSegment[1], nil
+	}
+	if len(relPathSegment) > 1 { return "", "", ErrInvalidRepositoryPath }
+	return "", relPathSegment[0], nil
+}
+
+// checks whether git over ssh is available at all. in browse-only
+// mode there isn't a database to check the keys against.
+func CheckGitSSHAvailable(cfg *gitus.GitusConfig) error {
+	if cfg.IsInBrowseOnlyMode() {
+		return errors.New("This instance of Gitus is in Browse-Only Mode which does not allow Git over SSH.")
+	}
+	if cfg.GlobalVisibility != gitus.GLOBAL_VISIBILITY_PUBLIC &&
+		cfg.GlobalVisibility != gitus.GLOBAL_VISIBILITY_PRIVATE {
+		return errors.New("This instance of Gitus is currently unavailable.")
+	}
+	return nil
+}
+
+type GitCommand struct {
+	// the command line to run, with the repository path resolved to
+	// the real path under GitRoot. not used for git-lfs-authenticate.
+	Command []string
+	IsPush bool
+	Repository *model.Repository
+	// the operation ("upload" or "download") of git-lfs-authenticate,
+	// which is answered by gitus itself; empty for the other commands.
+	LFSOperation string
+	// the extra environment variables of the command, e.g. the ones
+	// used by the quota check in the pre-receive hook.
+	Env []string
+}
+
+// checks whether the user `username` can run the git command
+// `command` (i.e. SSH_ORIGINAL_COMMAND) & resolves the command to
+// run. the errors returned are meant to be shown to the user.
+func ResolveGitCommand(cfg *gitus.GitusConfig, dbif db.GitusDatabaseInterface, username string, command string) (*GitCommand, error) {
+	if err := CheckGitSSHAvailable(cfg); err != nil { return nil, err }
+	// one might be tempted to think that one can just pass SSH_ORIGINAL_COMMAND
+	// to exec.Command, but things don't work that way...
+	parsedCmd := shellparse.ParseShellCommand(command)
+	if len(parsedCmd) <= 0 { return nil, ErrInvalidSSHCommand }
+	// need to have a guard here or else normal users might get to
+	// execute commands as the git user due to incorrect acl config.
+	if !IsValidGitSSHCommand(parsedCmd[0]) { return nil, ErrInvalidSSHCommand }
+	isPushingToRemote := parsedCmd[0] == "git-receive-pack"
+	relPath := parsedCmd[len(parsedCmd)-1]
+	// git-lfs-authenticate {path} {operation}
+	lfsOperation := ""
+	if parsedCmd[0] == lfs.SSH_AUTHENTICATE_COMMAND {
+		if !cfg.GitConfig.LFS.Enable { return nil, ErrLFSNotEnabled }
+		if len(parsedCmd) != 3 { return nil, ErrInvalidSSHCommand }
+		relPath = parsedCmd[1]
+		lfsOperation = parsedCmd[2]
+		if lfsOperation != lfs.OPERATION_UPLOAD && lfsOperation != lfs.OPERATION_DOWNLOAD { return nil, ErrInvalidSSHCommand }
+		isPushingToRemote = lfsOperation == lfs.OPERATION_UPLOAD
+	}
+	namespaceName, repositoryName, err := ParseRepositoryPath(cfg, relPath)
+	if err != nil { return nil, err }
+
+	// check acl.
+	r, err := dbif.GetRepositoryByName(namespaceName, repositoryName)
+	if err != nil { return nil, fmt.Errorf("Failed while reading ACL: %s.", err.Error()) }
+	ns, err := dbif.GetNamespaceByName(namespaceName)
+	if err != nil { return nil, fmt.Errorf("Failed while reading namespace: %s.", err.Error()) }
+	if err = CheckGitAccess(ns, r, username, isPushingToRemote); err != nil { return nil, err }
+
+	if lfsOperation != "" {
+		return &GitCommand{
+			IsPush: isPushingToRemote,
+			Repository: r,
+			LFSOperation: lfsOperation,
+		}, nil
+	}
+
+	// see also:
+	//     https://git-scm.com/docs/git-receive-pack
+	//     https://git-scm.com/docs/git-upload-pack
+	//     https://git-scm.com/docs/git-upload-archive
+	// all commands have the git dir path at the end of the call, so we resolve it
+	// with cfg.
+	parsedCmd[len(parsedCmd)-1] = path.Join(cfg.GitRoot, r.Namespace, r.Name)
+	var env []string
+	if isPushingToRemote && cfg.Quota.Enable {
+		env, err = quota.PrepareReceivePack(cfg, r)
+		if err != nil { return nil, fmt.Errorf("Failed to set up quota check: %s.", err.Error()) }
+	}
+	return &GitCommand{
+		Command: parsedCmd,
+		IsPush: isPushingToRemote,
+		Repository: r,
+		Env: env,
+	}, nil
+}
+
+// checks whether the user `username` can pull from (or push to, when
+// `isPushingToRemote` is true) the repository `r` in the namespace `ns`. the
+// global visibility should be checked beforehand with
+// CheckGitSSHAvailable.
+func CheckGitAccess(ns *model.Namespace, r *model.Repository, username string, isPushingToRemote bool) error {
+	if r.Status == model.REPO_ARCHIVED && isPushingToRemote {
+		return fmt.Errorf("The repository %s/%s is ARCHIVED; no push to remote is allowed. ", r.Namespace, r.Name)
+	}
+	// public visibility + public repo + clone: yes
+	// public visibility + public repo + push: ns push / repo push
+	// public visibility + internal repo + clone: user
+	// public visibility + internal repo + push: ns push / repo push
+	// public visibility + limited repo + clone: ns any / repo any
+	// public visibility + limited repo + push: ns push / repo push
+	// public visibility + private repo + clone: repo any
+	// public visibility + private repo + push: repo push
+	// private visibility + public/internal repo + clone: user
+	// private visibility + public/internal repo + push: ns push / repo push
+	// private visibility + limited repo + clone: ns any / repo any
+	// private visibility + limited repo + push: ns push / repo push
+	// private visibility + private repo + clone: repo any
+	// private visibility + private repo + push: repo push
+	// shutdown visibility + any repo + clone/push: reject
+	// maintenance visibility + any repo + clone/push: reject
+
+	// user check is to check if the key is used by any user.
+	// when we reach here we are in public/private visibility and the repo
+	// is not archived (i.e. push is allowed).
+	isPublicRepo := r.Status == model.REPO_NORMAL_PUBLIC
+	isInternalRepo := r.Status == model.REPO_INTERNAL
+	isLimitedRepo := r.Status == model.REPO_LIMITED
+	isPrivateRepo := r.Status == model.REPO_NORMAL_PRIVATE
+	nsACLCheck := ns.ACL.GetUserPrivilege(username)
+	isNSOwner := ns.Owner == username
+	isRepoOwner := r.Owner == username
+	isNSAny := isNSOwner || (nsACLCheck != nil)
+	isNSPush := isNSOwner || (isNSAny && nsACLCheck.PushToRepository)
+	repoACLCheck := r.AccessControlList.GetUserPrivilege(username)
+	isRepoAny := isRepoOwner || (repoACLCheck != nil)
+	isRepoPush := isRepoOwner || (isRepoAny && repoACLCheck.PushToRepository)
+	if isPushingToRemote && (isPublicRepo || isInternalRepo || isLimitedRepo) {
+		if !isNSPush && !isRepoPush { return ErrNotEnoughPermission }
+	}
+	if !isPushingToRemote && isLimitedRepo {
+		if !isNSAny && !isRepoAny { return ErrNotEnoughPermission }
+	}
+	if isPushingToRemote && isPrivateRepo {
+		if !isRepoPush { return ErrNotEnoughPermission }
+	}
+	if !isPushingToRemote && isPrivateRepo {
+		if !isRepoAny { return ErrNotEnoughPermission }
+	}
+	return nil
+}
+
+// the output of git-lfs-authenticate: the http endpoint of the
+// repository & a token that's accepted there in place of the
+// password. see docs/lfs.org.
+func ResolveLFSAuthentication(cfg *gitus.GitusConfig, username string, gitCmd *GitCommand) ([]byte, error) {
+	if cfg.ProperHTTPHostName() == "" {
+		return nil, errors.New("hostName must be set to use Git LFS over SSH.")
+	}
+	token, err := lfs.IssueToken(cfg, &lfs.TokenClaim{
+		UserName: username,
+		Repository: gitCmd.Repository.FullName(),
+		Operation: gitCmd.LFSOperation,
+	})
+	if err != nil { return nil, err }
+	return json.Marshal(&lfs.AuthenticateResponse{
+		Href: lfs.Endpoint(cfg.ProperHTTPHostName(), gitCmd.Repository.FullName()),
+		Header: map[string]string{ "Authorization": "Bearer " + token },
+		ExpiresIn: int64(lfs.TOKEN_LIFETIME.Seconds()),
+	})
+}
@@ -13,6 +13,10 @@ import (
 	"github.com/GitusCodeForge/Gitus/pkg/shellparse"
 )
 
+// manages the entries gitus adds to the git user's authorized_keys.
+// a nil context is valid & does nothing; this is the case when the
+// built-in ssh server is used, which checks the keys against the
+// database directly (see pkg/gitus/sshserver).
 type SSHKeyManagingContext struct {
 	configFilePath string
 	keyFilePath string
@@ -101,6 +105,7 @@ func ToContext(cfg *gitus.GitusConfig) (*SSHKeyManagingContext, error) {
 }
 
 func (ctx *SSHKeyManagingContext) Sync() error {
+	if ctx == nil { return nil }
 	f, err := os.OpenFile(ctx.keyFilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil { return err }
 	defer f.Close()
@@ -126,6 +131,7 @@ func (ctx *SSHKeyManagingContext) Sync() error {
 }
 
 func (ctx *SSHKeyManagingContext) AddAuthorizedKey(username string, keyname string, key string) {
+	if ctx == nil { return }
 	if ctx.Managed == nil {
 		ctx.Managed = make(map[string]map[string]string)
 	}
@@ -138,14 +144,14 @@ func (ctx *SSHKeyManagingContext) AddAuthorizedKey(username string, keyname stri
 }
 
 func (ctx *SSHKeyManagingContext) RemoveAuthorizedKey(username string, keyname string) {
-	if ctx.Managed == nil { return }
+	if ctx == nil || ctx.Managed == nil { return }
 	pack, ok := ctx.Managed[username]
 	if !ok { return }
 	delete(pack, keyname)
 }
 
 func (ctx *SSHKeyManagingContext) GetAuthorizedKey(username string, keyname string) string {
-	if ctx.Managed == nil { return "" }
+	if ctx == nil || ctx.Managed == nil { return "" }
 	pack, ok := ctx.Managed[username]
 	if !ok { return "" }
 	s, ok := pack[keyname]
@@ -0,0 +1,116 @@
+package sshserver
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// the host keys are re-read whenever the files are changed so that
+// rotating them doesn't require restarting gitus. see
+// docs/ssh-server.org.
+
+type hostKeyFile struct {
+	path string
+	modTime time.Time
+	signer gossh.Signer
+}
+
+type HostKeySet struct {
+	lock sync.Mutex
+	file []*hostKeyFile
+}
+
+func LoadHostKey(p string) (gossh.Signer, error) {
+	b, err := os.ReadFile(p)
+	if err != nil { return nil, err }
+	return gossh.ParsePrivateKey(b)
+}
+
+// generates an ed25519 key at `p`. fails if `p` already exists.
+func GenerateHostKey(p string) (gossh.Signer, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil { return nil, err }
+	blk, err := gossh.MarshalPrivateKey(priv, "gitus")
+	if err != nil { return nil, err }
+	err = os.MkdirAll(path.Dir(p), os.ModeDir|0700)
+	if err != nil { return nil, err }
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil { return nil, err }
+	err = pem.Encode(f, blk)
+	if err == nil { err = f.Sync() }
+	f.Close()
+	if err != nil {
+		os.Remove(p)
+		return nil, err
+	}
+	return gossh.NewSignerFromKey(priv)
+}
+
+// replaces the key at `p` with a newly generated one. the old key is
+// kept at `{p}.old`. returns the old signer (nil if there wasn't one)
+// & the new signer.
+func RotateHostKey(p string) (gossh.Signer, gossh.Signer, error) {
+	old, err := LoadHostKey(p)
+	if err != nil && !os.IsNotExist(err) { return nil, nil, err }
+	// generated at a temporary location first so that the running
+	// server never sees a missing or half-written key.
+	tmpPath := p + ".new"
+	os.Remove(tmpPath)
+	signer, err := GenerateHostKey(tmpPath)
+	if err != nil { return nil, nil, err }
+	if old != nil {
+		b, err := os.ReadFile(p)
+		if err != nil { return nil, nil, err }
+		err = os.WriteFile(p + ".old", b, 0600)
+		if err != nil { return nil, nil, err }
+	}
+	if err = os.Rename(tmpPath, p); err != nil { return nil, nil, err }
+	return old, signer, nil
+}
+
+// loads the keys at the paths & generates the missing ones.
+func NewHostKeySet(pathList []string) (*HostKeySet, error) {
+	res := &HostKeySet{
+		file: make([]*hostKeyFile, 0, len(pathList)),
+	}
+	for _, p := range pathList {
+		if _, err := os.Stat(p); os.IsNotExist(err) {
+			if _, err = GenerateHostKey(p); err != nil { return nil, err }
+		}
+		f := &hostKeyFile{ path: p }
+		if err := f.reload(); err != nil { return nil, err }
+		res.file = append(res.file, f)
+	}
+	return res, nil
+}
+
+func (f *hostKeyFile) reload() error {
+	s, err := os.Stat(f.path)
+	if err != nil { return err }
+	if f.signer != nil && s.ModTime().Equal(f.modTime) { return nil }
+	signer, err := LoadHostKey(f.path)
+	if err != nil { return err }
+	f.signer = signer
+	f.modTime = s.ModTime()
+	return nil
+}
+
+// returns the current keys. a key that fails to reload (e.g. in the
+// middle of being replaced by hand) keeps its last version.
+func (hks *HostKeySet) Signers() []gossh.Signer {
+	hks.lock.Lock()
+	defer hks.lock.Unlock()
+	res := make([]gossh.Signer, 0, len(hks.file))
+	for _, f := range hks.file {
+		f.reload()
+		res = append(res, f.signer)
+	}
+	return res
+}
@@ -0,0 +1,400 @@
+package sshserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitlib"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/drain"
+	gitlog "github.com/GitusCodeForge/Gitus/pkg/gitus/log"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/metrics"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/pullrequest"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/quota"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// the built-in ssh server. it checks the public keys against the
+// database directly & runs the git commands itself, so neither
+// sshd nor the authorized_keys file of the git user is involved.
+// see docs/ssh-server.org.
+
+const HANDSHAKE_TIMEOUT = 30 * time.Second
+// the key index (see findKeyOwner) is rebuilt at most this often.
+const KEY_INDEX_REBUILD_INTERVAL = 10 * time.Second
+
+var ErrServerClosed = errors.New("sshserver: Server closed")
+
+type authKeyRef struct {
+	userName string
+	keyName string
+}
+
+type Server struct {
+	config *gitus.GitusConfig
+	dbif db.GitusDatabaseInterface
+	hostKey *HostKeySet
+
+	lock sync.Mutex
+	listener net.Listener
+	conn map[net.Conn]bool
+	closed bool
+	wg sync.WaitGroup
+
+	// fingerprint -> key. only used when the client logs in as the
+	// git user, in which case the key is the only thing telling
+	// which gitus user it is.
+	keyIndexLock sync.Mutex
+	keyIndex map[string]authKeyRef
+	keyIndexTime time.Time
+}
+
+func NewServer(cfg *gitus.GitusConfig, dbif db.GitusDatabaseInterface) (*Server, error) {
+	if len(cfg.ProperSSHServerHostKeyPath()) <= 0 {
+		return nil, errors.New("No host key is configured for the built-in SSH server")
+	}
+	hks, err := NewHostKeySet(cfg.ProperSSHServerHostKeyPath())
+	if err != nil { return nil, fmt.Errorf("Failed to load host keys: %w", err) }
+	return &Server{
+		config: cfg,
+		dbif: dbif,
+		hostKey: hks,
+		conn: make(map[net.Conn]bool, 0),
+		keyIndex: make(map[string]authKeyRef, 0),
+	}, nil
+}
+
+func (s *Server) HostKey() *HostKeySet {
+	return s.hostKey
+}
+
+func matchAuthKey(keyList []model.GitusAuthKey, key gossh.PublicKey) *model.GitusAuthKey {
+	b := key.Marshal()
+	for i := range keyList {
+		pk, _, _, _, err := gossh.ParseAuthorizedKey([]byte(keyList[i].KeyText))
+		if err != nil { continue }
+		if bytes.Equal(pk.Marshal(), b) { return &keyList[i] }
+	}
+	return nil
+}
+
+func (s *Server) checkUser(userName string, key gossh.PublicKey) (*model.GitusAuthKey, error) {
+	keyList, err := s.dbif.GetAllAuthKeyByUsername(userName)
+	if err != nil { return nil, err }
+	k := matchAuthKey(keyList, key)
+	if k == nil { return nil, errors.New("Key not found") }
+	u, err := s.dbif.GetUserByName(userName)
+	if err != nil { return nil, err }
+	switch u.Status {
+	case model.BANNED, model.NORMAL_USER_APPROVAL_NEEDED, model.NORMAL_USER_CONFIRM_NEEDED:
+		return nil, errors.New("User not allowed")
+	}
+	return k, nil
+}
+
+func (s *Server) rebuildKeyIndex() error {
+	res := make(map[string]authKeyRef, 0)
+	var pageNum int64 = 0
+	const pageSize = 100
+	for {
+		userList, err := s.dbif.GetAllUsers(pageNum, pageSize)
+		if err != nil { return err }
+		for _, u := range userList {
+			keyList, err := s.dbif.GetAllAuthKeyByUsername(u.Name)
+			if err != nil { return err }
+			for _, k := range keyList {
+				pk, _, _, _, err := gossh.ParseAuthorizedKey([]byte(k.KeyText))
+				if err != nil { continue }
+				res[gossh.FingerprintSHA256(pk)] = authKeyRef{ userName: u.Name, keyName: k.KeyName }
+			}
+		}
+		if len(userList) < pageSize { break }
+		pageNum += 1
+	}
+	s.keyIndex = res
+	s.keyIndexTime = time.Now()
+	return nil
+}
+
+// finds the user a key belongs to. the index can be stale (e.g. the
+// key has been removed or has just been added), so the result is
+// always checked against the database & the index is rebuilt on a
+// miss.
+func (s *Server) findKeyOwner(key gossh.PublicKey) (string, *model.GitusAuthKey, error) {
+	s.keyIndexLock.Lock()
+	defer s.keyIndexLock.Unlock()
+	fp := gossh.FingerprintSHA256(key)
+	ref, ok := s.keyIndex[fp]
+	if ok {
+		k, err := s.checkUser(ref.userName, key)
+		if err == nil { return ref.userName, k, nil }
+	}
+	if time.Since(s.keyIndexTime) < KEY_INDEX_REBUILD_INTERVAL {
+		return "", nil, errors.New("Key not found")
+	}
+	if err := s.rebuildKeyIndex(); err != nil { return "", nil, err }
+	ref, ok = s.keyIndex[fp]
+	if !ok { return "", nil, errors.New("Key not found") }
+	k, err := s.checkUser(ref.userName, key)
+	if err != nil { return "", nil, err }
+	return ref.userName, k, nil
+}
+
+// the ssh login name is either the git user (as shown in the cloning
+// address), in which case the key decides the gitus user, or the
+// name of the gitus user.
+func (s *Server) authenticate(conn gossh.ConnMetadata, key gossh.PublicKey) (*gossh.Permissions, error) {
+	var userName string
+	var k *model.GitusAuthKey
+	var err error
+	if conn.User() == s.config.GitUser {
+		userName, k, err = s.findKeyOwner(key)
+	} else {
+		userName = conn.User()
+		k, err = s.checkUser(userName, key)
+	}
+	if err != nil {
+		slog.Warn("ssh: key rejected", "remote", conn.RemoteAddr().String(), "fingerprint", gossh.FingerprintSHA256(key), "user", conn.User(), "error", err)
+		return nil, errors.New("Authentication failed")
+	}
+	return &gossh.Permissions{
+		Extensions: map[string]string{
+			"gitus-user-name": userName,
+			"gitus-key-name": k.KeyName,
+		},
+	}, nil
+}
+
+func (s *Server) serverConfig() *gossh.ServerConfig {
+	cfg := &gossh.ServerConfig{
+		PublicKeyCallback: s.authenticate,
+		ServerVersion: "SSH-2.0-Gitus",
+	}
+	for _, signer := range s.hostKey.Signers() {
+		cfg.AddHostKey(signer)
+	}
+	return cfg
+}
+
+func (s *Server) trackConn(c net.Conn, add bool) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if add {
+		if s.closed { return false }
+		s.conn[c] = true
+		s.wg.Add(1)
+	} else {
+		delete(s.conn, c)
+		s.wg.Done()
+	}
+	return true
+}
+
+func (s *Server) ListenAndServe() error {
+	addr := fmt.Sprintf("%s:%d", s.config.SSHServer.BindAddress, s.config.SSHServer.BindPort)
+	l, err := net.Listen("tcp", addr)
+	if err != nil { return err }
+	return s.Serve(l)
+}
+
+func (s *Server) Serve(l net.Listener) error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listener = l
+	s.lock.Unlock()
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			s.lock.Lock()
+			closed := s.closed
+			s.lock.Unlock()
+			if closed { return ErrServerClosed }
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		if !s.trackConn(c, true) {
+			c.Close()
+			return ErrServerClosed
+		}
+		go func() {
+			defer s.trackConn(c, false)
+			defer c.Close()
+			s.handleConn(c)
+		}()
+	}
+}
+
+// stops accepting new connections & waits for the running ones to
+// finish until `ctx` is done, after which they're closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.lock.Lock()
+	s.closed = true
+	if s.listener != nil { s.listener.Close() }
+	s.lock.Unlock()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.lock.Lock()
+		for c := range s.conn { c.Close() }
+		s.lock.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (s *Server) handleConn(c net.Conn) {
+	c.SetDeadline(time.Now().Add(HANDSHAKE_TIMEOUT))
+	sconn, chans, reqs, err := gossh.NewServerConn(c, s.serverConfig())
+	if err != nil { return }
+	c.SetDeadline(time.Time{})
+	defer sconn.Close()
+	go gossh.DiscardRequests(reqs)
+	userName := sconn.Permissions.Extensions["gitus-user-name"]
+	keyName := sconn.Permissions.Extensions["gitus-key-name"]
+	slog.Info("ssh: logged in", "remote", c.RemoteAddr().String(), "user", userName, "key", keyName)
+	var wg sync.WaitGroup
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(gossh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		ch, chReqs, err := nc.Accept()
+		if err != nil { continue }
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handleSession(userName, ch, chReqs)
+		}()
+	}
+	wg.Wait()
+}
+
+func sendExitStatus(ch gossh.Channel, status uint32) {
+	ch.SendRequest("exit-status", false, gossh.Marshal(struct{ Status uint32 }{ status }))
+}
+
+// every session (i.e. every git command) gets its own request id,
+// which is passed down to git & the hooks.
+func (s *Server) handleSession(userName string, ch gossh.Channel, reqs <-chan *gossh.Request) {
+	defer ch.Close()
+	ctx := gitlog.WithRequestID(context.Background(), gitlog.NewRequestID())
+	env := make([]string, 0)
+	for req := range reqs {
+		switch req.Type {
+		case "env":
+			var kv struct{ Name string; Value string }
+			if err := gossh.Unmarshal(req.Payload, &kv); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			// used by git for protocol v2.
+			if kv.Name != "GIT_PROTOCOL" {
+				req.Reply(false, nil)
+				continue
+			}
+			env = append(env, fmt.Sprintf("%s=%s", kv.Name, kv.Value))
+			req.Reply(true, nil)
+		case "exec":
+			var payload struct{ Command string }
+			if err := gossh.Unmarshal(req.Payload, &payload); err != nil {
+				req.Reply(false, nil)
+				return
+			}
+			req.Reply(true, nil)
+			sendExitStatus(ch, s.runGitCommand(ctx, userName, payload.Command, env, ch))
+			return
+		case "shell":
+			req.Reply(true, nil)
+			fmt.Fprintf(ch.Stderr(), "%s\r\n", s.config.NoInteractiveShellMessage)
+			sendExitStatus(ch, 1)
+			return
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
+
+// same as `gitus ssh`: git clients show it as a remote error.
+func writeGitError(w io.Writer, msg string) {
+	s, _ := gitlib.ToPktLine(fmt.Sprintf("ERR %s\n", msg))
+	io.WriteString(w, s)
+}
+
+func (s *Server) runGitCommand(ctx context.Context, userName string, command string, env []string, ch gossh.Channel) uint32 {
+	gitCmd, err := ssh.ResolveGitCommand(s.config, s.dbif, userName, command)
+	if err != nil {
+		slog.WarnContext(ctx, "ssh: command rejected", "user", userName, "error", err)
+		writeGitError(ch, err.Error())
+		return 1
+	}
+	if gitCmd.LFSOperation != "" {
+		b, err := ssh.ResolveLFSAuthentication(s.config, userName, gitCmd)
+		if err != nil {
+			writeGitError(ch, err.Error())
+			return 1
+		}
+		slog.InfoContext(ctx, "ssh: git lfs authenticated", "user", userName, "operation", gitCmd.LFSOperation, "repository", gitCmd.Repository.FullName())
+		ch.Write(append(b, '\n'))
+		return 0
+	}
+	slog.InfoContext(ctx, "ssh: running git command", "user", userName, "service", gitCmd.Command[0], "repository", gitCmd.Repository.FullName())
+	metrics.GitOperationTotal.Inc("ssh", gitCmd.Command[0])
+	cmd := exec.Command(gitCmd.Command[0], gitCmd.Command[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Env = append(cmd.Env, gitlog.RequestIDEnvOf(ctx)...)
+	cmd.Env = append(cmd.Env, gitCmd.Env...)
+	cmd.Stdout = ch
+	cmd.Stderr = ch.Stderr()
+	// a pipe is used instead of setting `cmd.Stdin` since otherwise
+	// `Wait` would wait for the client to close its side, which git
+	// clients don't do until the command exits.
+	stdin, err := cmd.StdinPipe()
+	if err != nil { return 1 }
+	if err = drain.Start(cmd); err != nil {
+		writeGitError(ch, err.Error())
+		return 1
+	}
+	go func() {
+		io.Copy(stdin, ch)
+		stdin.Close()
+	}()
+	err = drain.Wait(cmd)
+	if gitCmd.IsPush {
+		_, serr := quota.RefreshRepositorySize(s.config, s.dbif, gitCmd.Repository.Namespace, gitCmd.Repository.Name)
+		if serr != nil { slog.WarnContext(ctx, "ssh: failed to measure repository", "repository", gitCmd.Repository.FullName(), "error", serr) }
+		serr = pullrequest.SyncProviderBranch(ctx, s.config, s.dbif, gitCmd.Repository.Namespace, gitCmd.Repository.Name, userName)
+		if serr != nil { slog.WarnContext(ctx, "ssh: failed to update pull requests", "repository", gitCmd.Repository.FullName(), "error", serr) }
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "ssh: git command failed", "user", userName, "service", gitCmd.Command[0], "error", err)
+		var ee *exec.ExitError
+		if errors.As(err, &ee) { return uint32(ee.ExitCode()) }
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,144 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// json web key sets (RFC 7517). only the keys for the algorithms in
+// SUPPORTED_ALGORITHM_LIST are used; the others are ignored.
+
+var SUPPORTED_ALGORITHM_LIST = []string{"RS256", "RS384", "RS512", "ES256", "ES384"}
+
+// the key set is refetched when a token is signed with an unknown key
+// (i.e. the provider has rotated its keys), but not more often than
+// this.
+const KEY_SET_MIN_REFRESH_INTERVAL = time.Minute
+
+var ErrKeyNotFound = errors.New("Signing key not found")
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N string `json:"n"`
+	E string `json:"e"`
+	Crv string `json:"crv"`
+	X string `json:"x"`
+	Y string `json:"y"`
+}
+
+type keySet struct {
+	uri string
+	lock sync.Mutex
+	keys []*parsedKey
+	fetchTime time.Time
+}
+
+type parsedKey struct {
+	kid string
+	alg string
+	key crypto.PublicKey
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil { return nil, err }
+	return new(big.Int).SetBytes(b), nil
+}
+
+func parseJSONWebKey(k *jsonWebKey) (*parsedKey, error) {
+	if len(k.Use) > 0 && k.Use != "sig" { return nil, nil }
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil { return nil, err }
+		e, err := decodeBigInt(k.E)
+		if err != nil { return nil, err }
+		if !e.IsInt64() || e.Int64() > 1 << 31 { return nil, errors.New("Invalid RSA exponent") }
+		return &parsedKey{ kid: k.Kid, alg: k.Alg, key: &rsa.PublicKey{ N: n, E: int(e.Int64()) } }, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256": curve = elliptic.P256()
+		case "P-384": curve = elliptic.P384()
+		default: return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil { return nil, err }
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil { return nil, err }
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size { return nil, errors.New("Invalid EC key") }
+		point := append([]byte{4}, append(x, y...)...)
+		pk, err := ecdsa.ParseUncompressedPublicKey(curve, point)
+		if err != nil { return nil, err }
+		return &parsedKey{ kid: k.Kid, alg: k.Alg, key: pk }, nil
+	}
+	return nil, nil
+}
+
+func (ks *keySet) fetch(p *Provider) error {
+	var doc struct{
+		Keys []*jsonWebKey `json:"keys"`
+	}
+	err := p.getJSON(ks.uri, nil, &doc)
+	if err != nil { return err }
+	res := make([]*parsedKey, 0, len(doc.Keys))
+	for _, k := range doc.Keys {
+		pk, err := parseJSONWebKey(k)
+		// malformed keys are skipped so that one bad key doesn't
+		// break the others.
+		if err != nil || pk == nil { continue }
+		res = append(res, pk)
+	}
+	ks.keys = res
+	ks.fetchTime = time.Now()
+	return nil
+}
+
+func keyMatchesAlg(k *parsedKey, alg string) bool {
+	if len(k.alg) > 0 && k.alg != alg { return false }
+	switch k.key.(type) {
+	case *rsa.PublicKey: return alg[:2] == "RS"
+	case *ecdsa.PublicKey: return alg[:2] == "ES"
+	}
+	return false
+}
+
+func (ks *keySet) find(kid string, alg string) crypto.PublicKey {
+	var candidate crypto.PublicKey
+	count := 0
+	for _, k := range ks.keys {
+		if !keyMatchesAlg(k, alg) { continue }
+		if len(kid) > 0 && k.kid == kid { return k.key }
+		candidate = k.key
+		count += 1
+	}
+	// a token without "kid" is only accepted when there's no
+	// ambiguity.
+	if len(kid) <= 0 && count == 1 { return candidate }
+	return nil
+}
+
+func (ks *keySet) get(p *Provider, kid string, alg string) (crypto.PublicKey, error) {
+	ks.lock.Lock()
+	defer ks.lock.Unlock()
+	if ks.keys != nil {
+		if k := ks.find(kid, alg); k != nil { return k, nil }
+		if time.Since(ks.fetchTime) < KEY_SET_MIN_REFRESH_INTERVAL { return nil, ErrKeyNotFound }
+	}
+	err := ks.fetch(p)
+	if err != nil { return nil, err }
+	if k := ks.find(kid, alg); k != nil { return k, nil }
+	return nil, ErrKeyNotFound
+}
+
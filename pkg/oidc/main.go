@@ -0,0 +1,309 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// a minimal openid connect relying party: discovery, the authorization
+// code flow (with PKCE) & id token verification. see docs/oidc.org.
+
+const DISCOVERY_TTL = time.Hour
+const HTTP_TIMEOUT = 10 * time.Second
+// the max size of the responses from the identity provider.
+const MAX_RESPONSE_SIZE = 1024 * 1024
+
+var ErrIssuerMismatch = errors.New("Issuer mismatch")
+var ErrNonceMismatch = errors.New("Nonce mismatch")
+var ErrNoIDToken = errors.New("No id token in token response")
+var ErrSubjectMismatch = errors.New("Subject mismatch")
+
+type Discovery struct {
+	Issuer string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint string `json:"token_endpoint"`
+	UserInfoEndpoint string `json:"userinfo_endpoint"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+type Provider struct {
+	Issuer string
+	ClientId string
+	ClientSecret string
+	// the url of our callback endpoint; must be registered with the
+	// identity provider.
+	RedirectURI string
+	// "openid" is always requested.
+	Scope []string
+	Client *http.Client
+	lock sync.Mutex
+	discovery *Discovery
+	discoveryTime time.Time
+	keySet *keySet
+}
+
+func NewProvider(issuer string, clientId string, clientSecret string, redirectURI string, scope []string) *Provider {
+	return &Provider{
+		Issuer: issuer,
+		ClientId: clientId,
+		ClientSecret: clientSecret,
+		RedirectURI: redirectURI,
+		Scope: scope,
+		Client: &http.Client{ Timeout: HTTP_TIMEOUT },
+	}
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil { return "", err }
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// used for the "state" & "nonce" parameters and the PKCE code
+// verifier.
+func NewRandomString() (string, error) {
+	return randomString()
+}
+
+// checks the "state" parameter of the callback against the one the
+// login is started with (e.g. kept in a cookie of the browser).
+func CheckState(expected string, state string) bool {
+	return len(state) > 0 && subtle.ConstantTimeCompare([]byte(expected), []byte(state)) == 1
+}
+
+func codeChallenge(verifier string) string {
+	h := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
+
+func (p *Provider) getJSON(u string, header http.Header, target any) error {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil { return err }
+	for k, v := range header { req.Header[k] = v }
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.Client.Do(req)
+	if err != nil { return err }
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, MAX_RESPONSE_SIZE))
+	if err != nil { return err }
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Request to %s failed with status %d", u, resp.StatusCode)
+	}
+	return json.Unmarshal(b, target)
+}
+
+// retrieves (and caches) the provider's metadata from
+// "{issuer}/.well-known/openid-configuration".
+func (p *Provider) Discover() (*Discovery, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.discovery != nil && time.Since(p.discoveryTime) < DISCOVERY_TTL {
+		return p.discovery, nil
+	}
+	d := new(Discovery)
+	err := p.getJSON(strings.TrimSuffix(p.Issuer, "/") + "/.well-known/openid-configuration", nil, d)
+	if err != nil { return nil, err }
+	if strings.TrimSuffix(d.Issuer, "/") != strings.TrimSuffix(p.Issuer, "/") {
+		return nil, ErrIssuerMismatch
+	}
+	if len(d.AuthorizationEndpoint) <= 0 || len(d.TokenEndpoint) <= 0 || len(d.JWKSURI) <= 0 {
+		return nil, errors.New("Incomplete provider metadata")
+	}
+	p.discovery = d
+	p.discoveryTime = time.Now()
+	if p.keySet == nil || p.keySet.uri != d.JWKSURI {
+		p.keySet = &keySet{ uri: d.JWKSURI }
+	}
+	return d, nil
+}
+
+// returns the url the user should be redirected to.
+func (p *Provider) AuthCodeURL(state string, nonce string, codeVerifier string) (string, error) {
+	d, err := p.Discover()
+	if err != nil { return "", err }
+	u, err := url.Parse(d.AuthorizationEndpoint)
+	if err != nil { return "", err }
+	scope := []string{"openid"}
+	for _, k := range p.Scope {
+		if k != "openid" { scope = append(scope, k) }
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientId)
+	q.Set("redirect_uri", p.RedirectURI)
+	q.Set("scope", strings.Join(scope, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", codeChallenge(codeVerifier))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType string `json:"token_type"`
+	IDToken string `json:"id_token"`
+	Error string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// exchanges the authorization code for tokens at the token endpoint.
+// the client authenticates with client_secret_basic.
+func (p *Provider) Exchange(code string, codeVerifier string) (*TokenResponse, error) {
+	d, err := p.Discover()
+	if err != nil { return nil, err }
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURI)
+	form.Set("code_verifier", codeVerifier)
+	req, err := http.NewRequest(http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil { return nil, err }
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.ClientId), url.QueryEscape(p.ClientSecret))
+	resp, err := p.Client.Do(req)
+	if err != nil { return nil, err }
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, MAX_RESPONSE_SIZE))
+	if err != nil { return nil, err }
+	res := new(TokenResponse)
+	err = json.Unmarshal(b, res)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid token response (status %d)", resp.StatusCode)
+	}
+	if len(res.Error) > 0 {
+		return nil, fmt.Errorf("Token request failed: %s %s", res.Error, res.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Token request failed with status %d", resp.StatusCode)
+	}
+	if len(res.IDToken) <= 0 { return nil, ErrNoIDToken }
+	return res, nil
+}
+
+type Claims struct {
+	Subject string
+	Email string
+	EmailVerified bool
+	PreferredUsername string
+	Name string
+	// all the claims, e.g. for retrieving the groups.
+	Raw map[string]any
+}
+
+func claimString(m map[string]any, k string) string {
+	s, _ := m[k].(string)
+	return s
+}
+
+// some providers send "email_verified" as a string.
+func claimBool(m map[string]any, k string) bool {
+	switch v := m[k].(type) {
+	case bool: return v
+	case string: return v == "true"
+	}
+	return false
+}
+
+func newClaims(m map[string]any) *Claims {
+	return &Claims{
+		Subject: claimString(m, "sub"),
+		Email: claimString(m, "email"),
+		EmailVerified: claimBool(m, "email_verified"),
+		PreferredUsername: claimString(m, "preferred_username"),
+		Name: claimString(m, "name"),
+		Raw: m,
+	}
+}
+
+// returns the claim `k` as a list of strings. a single string is
+// treated as a list with one item.
+func (c *Claims) StringList(k string) []string {
+	switch v := c.Raw[k].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		res := make([]string, 0, len(v))
+		for _, s := range v {
+			if ss, ok := s.(string); ok { res = append(res, ss) }
+		}
+		return res
+	}
+	return nil
+}
+
+// verifies the signature & the standard claims of an id token.
+func (p *Provider) VerifyIDToken(raw string, nonce string) (*Claims, error) {
+	d, err := p.Discover()
+	if err != nil { return nil, err }
+	p.lock.Lock()
+	ks := p.keySet
+	p.lock.Unlock()
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return ks.get(p, kid, t.Method.Alg())
+	},
+		jwt.WithValidMethods(SUPPORTED_ALGORITHM_LIST),
+		jwt.WithIssuer(d.Issuer),
+		jwt.WithAudience(p.ClientId),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil { return nil, err }
+	// when there are multiple audiences the authorized party must be
+	// us.
+	aud, _ := claims.GetAudience()
+	if azp, ok := claims["azp"].(string); (ok || len(aud) > 1) && azp != p.ClientId {
+		return nil, errors.New("Invalid authorized party")
+	}
+	if claimString(claims, "nonce") != nonce { return nil, ErrNonceMismatch }
+	res := newClaims(claims)
+	if len(res.Subject) <= 0 { return nil, errors.New("No subject in id token") }
+	return res, nil
+}
+
+// retrieves the claims from the userinfo endpoint, for providers that
+// don't put everything in the id token. the subject is checked
+// against the one from the id token.
+func (p *Provider) UserInfo(accessToken string, subject string) (*Claims, error) {
+	d, err := p.Discover()
+	if err != nil { return nil, err }
+	if len(d.UserInfoEndpoint) <= 0 { return nil, errors.New("No userinfo endpoint") }
+	m := make(map[string]any)
+	err = p.getJSON(d.UserInfoEndpoint, http.Header{"Authorization": []string{"Bearer " + accessToken}}, &m)
+	if err != nil { return nil, err }
+	res := newClaims(m)
+	if res.Subject != subject { return nil, ErrSubjectMismatch }
+	return res, nil
+}
+
+// fills in the claims that are missing in `c` from `other`.
+func (c *Claims) Merge(other *Claims) {
+	if len(c.Email) <= 0 {
+		c.Email = other.Email
+		c.EmailVerified = other.EmailVerified
+	}
+	if len(c.PreferredUsername) <= 0 { c.PreferredUsername = other.PreferredUsername }
+	if len(c.Name) <= 0 { c.Name = other.Name }
+	for k, v := range other.Raw {
+		if _, ok := c.Raw[k]; !ok { c.Raw[k] = v }
+	}
+}
+
@@ -0,0 +1,338 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// a stub identity provider w/ the endpoints the relying party uses.
+// the authorization endpoint isn't served; the test plays the part of
+// the browser & asks for a code directly w/ `authorize`.
+
+const testClientId = "gitus"
+const testClientSecret = "secret"
+const testRedirectURI = "https://git.example.com/login/oidc/test/callback"
+
+type testKey struct {
+	kid string
+	key *rsa.PrivateKey
+}
+
+type testAuthorization struct {
+	challenge string
+	nonce string
+}
+
+type stubProvider struct {
+	t *testing.T
+	server *httptest.Server
+	lock sync.Mutex
+	// the issuer in the discovery document; the server's url if empty.
+	issuer string
+	keyList []testKey
+	jwksCount int
+	code map[string]testAuthorization
+	// the claims put into the id token & returned by the userinfo
+	// endpoint, on top of the required ones.
+	claims jwt.MapClaims
+	userInfo map[string]any
+}
+
+func newTestKey(t *testing.T, kid string) testKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil { t.Fatal(err) }
+	return testKey{ kid: kid, key: key }
+}
+
+func newStubProvider(t *testing.T) *stubProvider {
+	sp := &stubProvider{
+		t: t,
+		keyList: []testKey{ newTestKey(t, "key-1") },
+		code: make(map[string]testAuthorization),
+		claims: jwt.MapClaims{ "email": "user@example.com", "email_verified": true },
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", sp.serveDiscovery)
+	mux.HandleFunc("GET /jwks", sp.serveJWKS)
+	mux.HandleFunc("POST /token", sp.serveToken)
+	mux.HandleFunc("GET /userinfo", sp.serveUserInfo)
+	sp.server = httptest.NewServer(mux)
+	t.Cleanup(sp.server.Close)
+	return sp
+}
+
+func (sp *stubProvider) newProvider() *Provider {
+	return NewProvider(sp.server.URL, testClientId, testClientSecret, testRedirectURI, []string{ "email", "profile" })
+}
+
+func (sp *stubProvider) serveDiscovery(w http.ResponseWriter, r *http.Request) {
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+	issuer := sp.issuer
+	if len(issuer) <= 0 { issuer = sp.server.URL }
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer": issuer,
+		"authorization_endpoint": sp.server.URL + "/authorize",
+		"token_endpoint": sp.server.URL + "/token",
+		"userinfo_endpoint": sp.server.URL + "/userinfo",
+		"jwks_uri": sp.server.URL + "/jwks",
+	})
+}
+
+func (sp *stubProvider) serveJWKS(w http.ResponseWriter, r *http.Request) {
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+	sp.jwksCount += 1
+	keys := make([]map[string]string, 0)
+	for _, k := range sp.keyList {
+		keys = append(keys, map[string]string{
+			"kty": "RSA",
+			"kid": k.kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n": base64.RawURLEncoding.EncodeToString(k.key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.key.E)).Bytes()),
+		})
+	}
+	json.NewEncoder(w).Encode(map[string]any{ "keys": keys })
+}
+
+func tokenError(w http.ResponseWriter, e string) {
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{ "error": e })
+}
+
+// what the user would be redirected back w/ after logging in at the
+// authorization url.
+func (sp *stubProvider) authorize(authURL string) url.Values {
+	sp.t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil { sp.t.Fatal(err) }
+	q := u.Query()
+	if u.Path != "/authorize" { sp.t.Errorf("authorization endpoint %s", u.Path) }
+	for k, v := range map[string]string{
+		"response_type": "code",
+		"client_id": testClientId,
+		"redirect_uri": testRedirectURI,
+		"scope": "openid email profile",
+		"code_challenge_method": "S256",
+	} {
+		if q.Get(k) != v { sp.t.Errorf("%s: %q, want %q", k, q.Get(k), v) }
+	}
+	code, err := NewRandomString()
+	if err != nil { sp.t.Fatal(err) }
+	sp.lock.Lock()
+	sp.code[code] = testAuthorization{ challenge: q.Get("code_challenge"), nonce: q.Get("nonce") }
+	sp.lock.Unlock()
+	return url.Values{ "code": { code }, "state": { q.Get("state") } }
+}
+
+func (sp *stubProvider) signIDToken(nonce string) string {
+	sp.t.Helper()
+	claims := jwt.MapClaims{
+		"iss": sp.server.URL,
+		"sub": "subject-1",
+		"aud": testClientId,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"nonce": nonce,
+	}
+	for k, v := range sp.claims { claims[k] = v }
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = sp.keyList[0].kid
+	res, err := token.SignedString(sp.keyList[0].key)
+	if err != nil { sp.t.Fatal(err) }
+	return res
+}
+
+func (sp *stubProvider) serveToken(w http.ResponseWriter, r *http.Request) {
+	clientId, secret, ok := r.BasicAuth()
+	if !ok || clientId != testClientId || secret != testClientSecret {
+		tokenError(w, "invalid_client")
+		return
+	}
+	if r.FormValue("grant_type") != "authorization_code" || r.FormValue("redirect_uri") != testRedirectURI {
+		tokenError(w, "invalid_request")
+		return
+	}
+	sp.lock.Lock()
+	a, ok := sp.code[r.FormValue("code")]
+	delete(sp.code, r.FormValue("code"))
+	sp.lock.Unlock()
+	if !ok || codeChallenge(r.FormValue("code_verifier")) != a.challenge {
+		tokenError(w, "invalid_grant")
+		return
+	}
+	sp.lock.Lock()
+	idToken := sp.signIDToken(a.nonce)
+	sp.lock.Unlock()
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "access-token",
+		"token_type": "Bearer",
+		"id_token": idToken,
+	})
+}
+
+func (sp *stubProvider) serveUserInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer access-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+	json.NewEncoder(w).Encode(sp.userInfo)
+}
+
+// a login from start to finish as the callback handler does it; the
+// state the callback is called w/ is changed by `tamper`.
+func login(t *testing.T, sp *stubProvider, p *Provider, tamper func(url.Values)) (*TokenResponse, *Claims, error) {
+	t.Helper()
+	state, _ := NewRandomString()
+	nonce, _ := NewRandomString()
+	verifier, _ := NewRandomString()
+	authURL, err := p.AuthCodeURL(state, nonce, verifier)
+	if err != nil { t.Fatal(err) }
+	q := sp.authorize(authURL)
+	if tamper != nil { tamper(q) }
+	if !CheckState(state, q.Get("state")) { return nil, nil, errors.New("state mismatch") }
+	token, err := p.Exchange(q.Get("code"), verifier)
+	if err != nil { return nil, nil, err }
+	claims, err := p.VerifyIDToken(token.IDToken, nonce)
+	return token, claims, err
+}
+
+func TestLogin(t *testing.T) {
+	sp := newStubProvider(t)
+	p := sp.newProvider()
+	token, claims, err := login(t, sp, p, nil)
+	if err != nil { t.Fatal(err) }
+	if claims.Subject != "subject-1" || claims.Email != "user@example.com" || !claims.EmailVerified {
+		t.Errorf("claims %+v", claims)
+	}
+	// the rest of the claims come from the userinfo endpoint.
+	sp.userInfo = map[string]any{ "sub": "subject-1", "preferred_username": "user", "groups": []string{ "a", "b" } }
+	info, err := p.UserInfo(token.AccessToken, claims.Subject)
+	if err != nil { t.Fatal(err) }
+	claims.Merge(info)
+	if claims.PreferredUsername != "user" || strings.Join(claims.StringList("groups"), ",") != "a,b" {
+		t.Errorf("claims %+v", claims)
+	}
+	sp.userInfo["sub"] = "subject-2"
+	if _, err := p.UserInfo(token.AccessToken, claims.Subject); !errors.Is(err, ErrSubjectMismatch) {
+		t.Errorf("got %v, want ErrSubjectMismatch", err)
+	}
+	// the discovery document & the key set are cached.
+	if _, _, err := login(t, sp, p, nil); err != nil { t.Fatal(err) }
+	if sp.jwksCount != 1 { t.Errorf("key set fetched %d times", sp.jwksCount) }
+}
+
+func TestLoginStateMismatch(t *testing.T) {
+	sp := newStubProvider(t)
+	p := sp.newProvider()
+	for _, state := range []string{ "", "another state" } {
+		_, _, err := login(t, sp, p, func(q url.Values) { q.Set("state", state) })
+		if err == nil { t.Errorf("state %q accepted", state) }
+	}
+	if CheckState("", "") { t.Error("empty state accepted") }
+}
+
+func TestDiscoverIssuerMismatch(t *testing.T) {
+	sp := newStubProvider(t)
+	sp.issuer = "https://idp.example.com"
+	if _, err := sp.newProvider().Discover(); !errors.Is(err, ErrIssuerMismatch) {
+		t.Errorf("got %v, want ErrIssuerMismatch", err)
+	}
+	// a trailing slash doesn't matter.
+	sp.issuer = sp.server.URL + "/"
+	if _, err := sp.newProvider().Discover(); err != nil { t.Error(err) }
+}
+
+func TestExchange(t *testing.T) {
+	sp := newStubProvider(t)
+	p := sp.newProvider()
+	nonce, verifier := "nonce", "verifier"
+	authURL, err := p.AuthCodeURL("state", nonce, verifier)
+	if err != nil { t.Fatal(err) }
+	// the code is bound to the code challenge.
+	q := sp.authorize(authURL)
+	if _, err := p.Exchange(q.Get("code"), "another verifier"); err == nil { t.Error("wrong verifier accepted") }
+	// & can only be used once.
+	q = sp.authorize(authURL)
+	if _, err := p.Exchange(q.Get("code"), verifier); err != nil { t.Fatal(err) }
+	if _, err := p.Exchange(q.Get("code"), verifier); err == nil { t.Error("code used twice") }
+	wrong := sp.newProvider()
+	wrong.ClientSecret = "wrong"
+	q = sp.authorize(authURL)
+	if _, err := wrong.Exchange(q.Get("code"), verifier); err == nil { t.Error("wrong client secret accepted") }
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	sp := newStubProvider(t)
+	p := sp.newProvider()
+	if _, err := p.Discover(); err != nil { t.Fatal(err) }
+	sign := func(method jwt.SigningMethod, key any, kid string, claims jwt.MapClaims) string {
+		t.Helper()
+		base := jwt.MapClaims{
+			"iss": sp.server.URL,
+			"sub": "subject-1",
+			"aud": testClientId,
+			"exp": time.Now().Add(time.Hour).Unix(),
+			"nonce": "nonce",
+		}
+		for k, v := range claims {
+			if v == nil { delete(base, k) } else { base[k] = v }
+		}
+		token := jwt.NewWithClaims(method, base)
+		if len(kid) > 0 { token.Header["kid"] = kid }
+		res, err := token.SignedString(key)
+		if err != nil { t.Fatal(err) }
+		return res
+	}
+	key := sp.keyList[0]
+	other := newTestKey(t, "key-2")
+	if _, err := p.VerifyIDToken(sign(jwt.SigningMethodRS256, key.key, key.kid, nil), "nonce"); err != nil {
+		t.Fatal(err)
+	}
+	for name, token := range map[string]string{
+		"nonce": sign(jwt.SigningMethodRS256, key.key, key.kid, jwt.MapClaims{ "nonce": "other" }),
+		"issuer": sign(jwt.SigningMethodRS256, key.key, key.kid, jwt.MapClaims{ "iss": "https://idp.example.com" }),
+		"audience": sign(jwt.SigningMethodRS256, key.key, key.kid, jwt.MapClaims{ "aud": "other" }),
+		"authorized party": sign(jwt.SigningMethodRS256, key.key, key.kid, jwt.MapClaims{ "aud": []string{ testClientId, "other" } }),
+		"expired": sign(jwt.SigningMethodRS256, key.key, key.kid, jwt.MapClaims{ "exp": time.Now().Add(-time.Hour).Unix() }),
+		"no expiry": sign(jwt.SigningMethodRS256, key.key, key.kid, jwt.MapClaims{ "exp": nil }),
+		"no subject": sign(jwt.SigningMethodRS256, key.key, key.kid, jwt.MapClaims{ "sub": nil }),
+		"unknown key": sign(jwt.SigningMethodRS256, other.key, other.kid, nil),
+		"wrong key": sign(jwt.SigningMethodRS256, other.key, key.kid, nil),
+		"hmac": sign(jwt.SigningMethodHS256, []byte(testClientSecret), key.kid, nil),
+		"none": sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", nil),
+	} {
+		if _, err := p.VerifyIDToken(token, "nonce"); err == nil { t.Errorf("%s: accepted", name) }
+	}
+	// the key set is refetched when the provider rotates its keys, but
+	// not for every token signed w/ an unknown key.
+	count := sp.jwksCount
+	sp.lock.Lock()
+	sp.keyList = []testKey{ other }
+	sp.lock.Unlock()
+	p.keySet.fetchTime = time.Now().Add(-KEY_SET_MIN_REFRESH_INTERVAL)
+	if _, err := p.VerifyIDToken(sign(jwt.SigningMethodRS256, other.key, other.kid, nil), "nonce"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.VerifyIDToken(sign(jwt.SigningMethodRS256, key.key, key.kid, nil), "nonce"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("got %v, want ErrKeyNotFound", err)
+	}
+	if sp.jwksCount != count + 1 { t.Errorf("key set fetched %d times, want %d", sp.jwksCount - count, 1) }
+}
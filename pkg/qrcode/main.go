@@ -0,0 +1,384 @@
+package qrcode
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// a minimal qr code encoder. it only does what we need for showing
+// things like totp enrollment uris: byte mode, error correction level
+// M, version 1 to 10 (i.e. up to 213 bytes of data). the algorithm
+// follows ISO/IEC 18004.
+
+var ErrDataTooLong = errors.New("Data too long for qr code")
+
+type blockSpec struct {
+	// number of error correction codewords per block.
+	ecPerBlock int
+	// number of data codewords of each block.
+	dataPerBlock []int
+}
+
+// error correction level M.
+var versionSpecList = []blockSpec{
+	{}, // version 0 does not exist.
+	{10, []int{16}},
+	{16, []int{28}},
+	{26, []int{44}},
+	{18, []int{32, 32}},
+	{24, []int{43, 43}},
+	{16, []int{27, 27, 27, 27}},
+	{18, []int{31, 31, 31, 31}},
+	{22, []int{38, 38, 39, 39}},
+	{22, []int{36, 36, 36, 37, 37}},
+	{26, []int{43, 43, 43, 43, 44}},
+}
+
+var alignmentPositionList = [][]int{
+	{}, {},
+	{6, 18}, {6, 22}, {6, 26}, {6, 30}, {6, 34},
+	{6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50},
+}
+
+const maxVersion = 10
+
+func (s blockSpec) dataCapacity() int {
+	res := 0
+	for _, k := range s.dataPerBlock { res += k }
+	return res
+}
+
+type QRCode struct {
+	Size int
+	// Module[y][x] is true if the module is dark.
+	Module [][]bool
+	isFunction [][]bool
+}
+
+func Encode(data []byte) (*QRCode, error) {
+	version := 0
+	for v := 1; v <= maxVersion; v++ {
+		countBits := 8
+		if v >= 10 { countBits = 16 }
+		if 4 + countBits + len(data)*8 <= versionSpecList[v].dataCapacity()*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 { return nil, ErrDataTooLong }
+	spec := versionSpecList[version]
+
+	// data bit stream: mode indicator, character count, data,
+	// terminator, padding.
+	bb := &bitBuffer{}
+	bb.append(0x4, 4)
+	if version >= 10 {
+		bb.append(len(data), 16)
+	} else {
+		bb.append(len(data), 8)
+	}
+	for _, k := range data { bb.append(int(k), 8) }
+	capacityBits := spec.dataCapacity() * 8
+	bb.append(0, min(4, capacityBits-len(bb.bits)))
+	for len(bb.bits) % 8 != 0 { bb.bits = append(bb.bits, false) }
+	for i := 0; len(bb.bits) < capacityBits; i++ {
+		if i % 2 == 0 { bb.append(0xec, 8) } else { bb.append(0x11, 8) }
+	}
+	dataCodeword := bb.bytes()
+
+	// split into blocks, compute error correction, interleave.
+	divisor := reedSolomonDivisor(spec.ecPerBlock)
+	dataBlockList := make([][]byte, 0, len(spec.dataPerBlock))
+	ecBlockList := make([][]byte, 0, len(spec.dataPerBlock))
+	k := 0
+	for _, n := range spec.dataPerBlock {
+		blk := dataCodeword[k:k+n]
+		k += n
+		dataBlockList = append(dataBlockList, blk)
+		ecBlockList = append(ecBlockList, reedSolomonRemainder(blk, divisor))
+	}
+	final := make([]byte, 0)
+	maxDataLen := spec.dataPerBlock[len(spec.dataPerBlock)-1]
+	for i := range maxDataLen {
+		for _, blk := range dataBlockList {
+			if i < len(blk) { final = append(final, blk[i]) }
+		}
+	}
+	for i := range spec.ecPerBlock {
+		for _, blk := range ecBlockList {
+			final = append(final, blk[i])
+		}
+	}
+
+	size := version * 4 + 17
+	qr := &QRCode{
+		Size: size,
+		Module: make([][]bool, size),
+		isFunction: make([][]bool, size),
+	}
+	for i := range size {
+		qr.Module[i] = make([]bool, size)
+		qr.isFunction[i] = make([]bool, size)
+	}
+	qr.drawFunctionPattern(version)
+	qr.drawCodeword(final)
+
+	// pick the mask with the lowest penalty.
+	bestMask := 0
+	bestPenalty := -1
+	for mask := range 8 {
+		qr.applyMask(mask)
+		qr.drawFormatBit(mask)
+		p := qr.penalty()
+		if bestPenalty < 0 || p < bestPenalty {
+			bestMask = mask
+			bestPenalty = p
+		}
+		qr.applyMask(mask)
+	}
+	qr.applyMask(bestMask)
+	qr.drawFormatBit(bestMask)
+	return qr, nil
+}
+
+type bitBuffer struct {
+	bits []bool
+}
+
+func (bb *bitBuffer) append(v int, n int) {
+	for i := n-1; i >= 0; i-- {
+		bb.bits = append(bb.bits, (v >> i) & 1 != 0)
+	}
+}
+
+func (bb *bitBuffer) bytes() []byte {
+	res := make([]byte, len(bb.bits)/8)
+	for i, b := range bb.bits {
+		if b { res[i/8] |= 1 << (7 - i%8) }
+	}
+	return res
+}
+
+func gfMultiply(x byte, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11d)
+		if (y >> i) & 1 != 0 { z ^= int(x) }
+	}
+	return byte(z)
+}
+
+func reedSolomonDivisor(degree int) []byte {
+	res := make([]byte, degree)
+	res[degree-1] = 1
+	var root byte = 1
+	for range degree {
+		for j := range degree {
+			res[j] = gfMultiply(res[j], root)
+			if j+1 < degree { res[j] ^= res[j+1] }
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return res
+}
+
+func reedSolomonRemainder(data []byte, divisor []byte) []byte {
+	res := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ res[0]
+		copy(res, res[1:])
+		res[len(res)-1] = 0
+		for i := range res {
+			res[i] ^= gfMultiply(divisor[i], factor)
+		}
+	}
+	return res
+}
+
+func (qr *QRCode) setFunction(x int, y int, dark bool) {
+	qr.Module[y][x] = dark
+	qr.isFunction[y][x] = true
+}
+
+func (qr *QRCode) drawFinder(cx int, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || x >= qr.Size || y < 0 || y >= qr.Size { continue }
+			d := max(abs(dx), abs(dy))
+			qr.setFunction(x, y, d != 2 && d != 4)
+		}
+	}
+}
+
+func (qr *QRCode) drawAlignment(cx int, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			qr.setFunction(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+func abs(x int) int {
+	if x < 0 { return -x }
+	return x
+}
+
+func (qr *QRCode) drawFunctionPattern(version int) {
+	for i := range qr.Size {
+		qr.setFunction(6, i, i%2 == 0)
+		qr.setFunction(i, 6, i%2 == 0)
+	}
+	qr.drawFinder(3, 3)
+	qr.drawFinder(qr.Size-4, 3)
+	qr.drawFinder(3, qr.Size-4)
+	pos := alignmentPositionList[version]
+	n := len(pos)
+	for i := range n {
+		for j := range n {
+			// skip the three corners occupied by finder patterns.
+			if (i == 0 && j == 0) || (i == 0 && j == n-1) || (i == n-1 && j == 0) { continue }
+			qr.drawAlignment(pos[i], pos[j])
+		}
+	}
+	// reserve the format area; the real bits are drawn after masking.
+	qr.drawFormatBit(0)
+	if version >= 7 {
+		rem := version
+		for range 12 { rem = (rem << 1) ^ ((rem >> 11) * 0x1f25) }
+		bits := version << 12 | rem
+		for i := range 18 {
+			dark := (bits >> i) & 1 != 0
+			a := qr.Size - 11 + i % 3
+			b := i / 3
+			qr.setFunction(a, b, dark)
+			qr.setFunction(b, a, dark)
+		}
+	}
+}
+
+func (qr *QRCode) drawFormatBit(mask int) {
+	// level M is 00.
+	data := 0 << 3 | mask
+	rem := data
+	for range 10 { rem = (rem << 1) ^ ((rem >> 9) * 0x537) }
+	bits := (data << 10 | rem) ^ 0x5412
+	bit := func(i int) bool { return (bits >> i) & 1 != 0 }
+	for i := 0; i <= 5; i++ { qr.setFunction(8, i, bit(i)) }
+	qr.setFunction(8, 7, bit(6))
+	qr.setFunction(8, 8, bit(7))
+	qr.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ { qr.setFunction(14-i, 8, bit(i)) }
+	for i := range 8 { qr.setFunction(qr.Size-1-i, 8, bit(i)) }
+	for i := 8; i < 15; i++ { qr.setFunction(8, qr.Size-15+i, bit(i)) }
+	qr.setFunction(8, qr.Size-8, true)
+}
+
+func (qr *QRCode) drawCodeword(data []byte) {
+	i := 0
+	for right := qr.Size - 1; right >= 1; right -= 2 {
+		if right == 6 { right = 5 }
+		for vert := range qr.Size {
+			for j := range 2 {
+				x := right - j
+				upward := (right + 1) & 2 == 0
+				y := vert
+				if upward { y = qr.Size - 1 - vert }
+				if !qr.isFunction[y][x] && i < len(data)*8 {
+					qr.Module[y][x] = (data[i/8] >> (7 - i%8)) & 1 != 0
+					i += 1
+				}
+			}
+		}
+	}
+}
+
+func (qr *QRCode) applyMask(mask int) {
+	for y := range qr.Size {
+		for x := range qr.Size {
+			if qr.isFunction[y][x] { continue }
+			var invert bool
+			switch mask {
+			case 0: invert = (x + y) % 2 == 0
+			case 1: invert = y % 2 == 0
+			case 2: invert = x % 3 == 0
+			case 3: invert = (x + y) % 3 == 0
+			case 4: invert = (x / 3 + y / 2) % 2 == 0
+			case 5: invert = x * y % 2 + x * y % 3 == 0
+			case 6: invert = (x * y % 2 + x * y % 3) % 2 == 0
+			case 7: invert = ((x + y) % 2 + x * y % 3) % 2 == 0
+			}
+			if invert { qr.Module[y][x] = !qr.Module[y][x] }
+		}
+	}
+}
+
+// a simplified version of the penalty rules in the standard: runs of
+// the same color, 2x2 blocks and dark/light balance. the finder-like
+// pattern rule is left out; any mask produces a valid symbol anyway,
+// this is only for better readability.
+func (qr *QRCode) penalty() int {
+	res := 0
+	for y := range qr.Size {
+		runX, runY := 1, 1
+		for x := 1; x < qr.Size; x++ {
+			if qr.Module[y][x] == qr.Module[y][x-1] {
+				runX += 1
+				if runX == 5 { res += 3 } else if runX > 5 { res += 1 }
+			} else {
+				runX = 1
+			}
+			if qr.Module[x][y] == qr.Module[x-1][y] {
+				runY += 1
+				if runY == 5 { res += 3 } else if runY > 5 { res += 1 }
+			} else {
+				runY = 1
+			}
+		}
+	}
+	dark := 0
+	for y := range qr.Size {
+		for x := range qr.Size {
+			if qr.Module[y][x] { dark += 1 }
+			if x+1 < qr.Size && y+1 < qr.Size {
+				c := qr.Module[y][x]
+				if c == qr.Module[y][x+1] && c == qr.Module[y+1][x] && c == qr.Module[y+1][x+1] {
+					res += 3
+				}
+			}
+		}
+	}
+	total := qr.Size * qr.Size
+	k := (abs(dark*20 - total*10) + total - 1) / total - 1
+	res += max(k, 0) * 10
+	return res
+}
+
+// renders the qr code as a black-on-white image; each module is
+// `scale` pixels wide and a quiet zone of 4 modules is added around.
+func (qr *QRCode) Image(scale int) image.Image {
+	border := 4
+	px := (qr.Size + border*2) * scale
+	img := image.NewGray(image.Rect(0, 0, px, px))
+	for y := range px {
+		for x := range px {
+			mx := x/scale - border
+			my := y/scale - border
+			dark := mx >= 0 && my >= 0 && mx < qr.Size && my < qr.Size && qr.Module[my][mx]
+			if dark {
+				img.SetGray(x, y, color.Gray{Y: 0})
+			} else {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	return img
+}
+
+func (qr *QRCode) WritePNG(w io.Writer, scale int) error {
+	return png.Encode(w, qr.Image(scale))
+}
+
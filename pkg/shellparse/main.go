@@ -61,6 +61,7 @@ func ParseShellCommand(s string) []string {
 			}
 			if i < lens && s[i] == '\'' { i += 1 }
 			res = append(res, strings.Join(buf, ""))
+			buf = make([]string, 0)
 		} else {
 			st := i
 			for i < lens && !isWhiteSpace(s[i]) { i += 1 }
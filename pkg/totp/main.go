@@ -0,0 +1,95 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// time-based one-time password as specified in RFC 6238. only the
+// parameters every authenticator app supports are used, i.e. SHA-1,
+// 6 digits and a period of 30 seconds.
+
+const (
+	PERIOD = 30
+	DIGITS = 6
+	// number of steps before & after the current one that are also
+	// accepted, to tolerate clock drift & slow typing.
+	SKEW = 1
+	SECRET_SIZE = 20
+)
+
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// returns a new random secret in base32 (without padding), which is
+// the format authenticator apps expect.
+func NewSecret() (string, error) {
+	b := make([]byte, SECRET_SIZE)
+	_, err := rand.Read(b)
+	if err != nil { return "", err }
+	return secretEncoding.EncodeToString(b), nil
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	s := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(secret), " ", ""))
+	s = strings.TrimRight(s, "=")
+	return secretEncoding.DecodeString(s)
+}
+
+func StepOf(t time.Time) int64 {
+	return t.Unix() / PERIOD
+}
+
+// computes the code of the specified time step (RFC 4226 section 5.3).
+func CodeOfStep(secret string, step int64) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil { return "", err }
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0xf
+	v := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", v % 1000000), nil
+}
+
+// checks `code` against the steps around `t`. returns the matching
+// step, or -1 if there isn't one. steps not after `lastUsedStep` are
+// never accepted so that a code cannot be used twice.
+func Validate(secret string, code string, t time.Time, lastUsedStep int64) (int64, error) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != DIGITS { return -1, nil }
+	current := StepOf(t)
+	for i := -SKEW; i <= SKEW; i++ {
+		step := current + int64(i)
+		if step <= lastUsedStep { continue }
+		expected, err := CodeOfStep(secret, step)
+		if err != nil { return -1, err }
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, nil
+		}
+	}
+	return -1, nil
+}
+
+// the "otpauth://" uri that is encoded into the qr code. see
+// https://github.com/google/google-authenticator/wiki/Key-Uri-Format
+func KeyURI(issuer string, accountName string, secret string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(accountName)
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprintf("%d", DIGITS))
+	q.Set("period", fmt.Sprintf("%d", PERIOD))
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
@@ -0,0 +1,145 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// a minimal cbor (RFC 8949) decoder; only what's needed for reading
+// attestation objects & cose keys. the decoded values are:
+//
+//     unsigned int      -> int64 (or uint64 when it's too big)
+//     negative int      -> int64
+//     byte string       -> []byte
+//     text string       -> string
+//     array             -> []any
+//     map               -> map[any]any
+//     true/false        -> bool
+//     null/undefined    -> nil
+//     float             -> float64
+//
+// tags are decoded as the tagged value. indefinite-length items are
+// not supported since they're not allowed in ctap2 canonical cbor.
+
+var ErrInvalidCBOR = errors.New("Invalid CBOR data")
+
+const cborMaxDepth = 16
+
+// decodes the first item in `b` and returns the item together with the
+// number of bytes it took.
+func decodeCBOR(b []byte) (any, int, error) {
+	return decodeCBORItem(b, 0)
+}
+
+func readCBORArgument(b []byte, info byte) (uint64, int, error) {
+	switch {
+	case info < 24:
+		return uint64(info), 0, nil
+	case info == 24:
+		if len(b) < 1 { return 0, 0, ErrInvalidCBOR }
+		return uint64(b[0]), 1, nil
+	case info == 25:
+		if len(b) < 2 { return 0, 0, ErrInvalidCBOR }
+		return uint64(binary.BigEndian.Uint16(b)), 2, nil
+	case info == 26:
+		if len(b) < 4 { return 0, 0, ErrInvalidCBOR }
+		return uint64(binary.BigEndian.Uint32(b)), 4, nil
+	case info == 27:
+		if len(b) < 8 { return 0, 0, ErrInvalidCBOR }
+		return binary.BigEndian.Uint64(b), 8, nil
+	}
+	return 0, 0, ErrInvalidCBOR
+}
+
+func decodeCBORItem(b []byte, depth int) (any, int, error) {
+	if depth > cborMaxDepth { return nil, 0, ErrInvalidCBOR }
+	if len(b) < 1 { return nil, 0, ErrInvalidCBOR }
+	major := b[0] >> 5
+	info := b[0] & 0x1f
+	if major == 7 {
+		switch info {
+		case 20: return false, 1, nil
+		case 21: return true, 1, nil
+		case 22, 23: return nil, 1, nil
+		case 25:
+			if len(b) < 3 { return nil, 0, ErrInvalidCBOR }
+			return float64(halfToFloat(binary.BigEndian.Uint16(b[1:]))), 3, nil
+		case 26:
+			if len(b) < 5 { return nil, 0, ErrInvalidCBOR }
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(b[1:]))), 5, nil
+		case 27:
+			if len(b) < 9 { return nil, 0, ErrInvalidCBOR }
+			return math.Float64frombits(binary.BigEndian.Uint64(b[1:])), 9, nil
+		}
+		return nil, 0, ErrInvalidCBOR
+	}
+	arg, n, err := readCBORArgument(b[1:], info)
+	if err != nil { return nil, 0, err }
+	pos := 1 + n
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 { return arg, pos, nil }
+		return int64(arg), pos, nil
+	case 1:
+		if arg > math.MaxInt64 { return nil, 0, ErrInvalidCBOR }
+		return -1 - int64(arg), pos, nil
+	case 2, 3:
+		if arg > uint64(len(b) - pos) { return nil, 0, ErrInvalidCBOR }
+		end := pos + int(arg)
+		if major == 2 {
+			res := make([]byte, int(arg))
+			copy(res, b[pos:end])
+			return res, end, nil
+		}
+		return string(b[pos:end]), end, nil
+	case 4:
+		if arg > uint64(len(b)) { return nil, 0, ErrInvalidCBOR }
+		res := make([]any, 0, int(arg))
+		for range arg {
+			v, n, err := decodeCBORItem(b[pos:], depth+1)
+			if err != nil { return nil, 0, err }
+			pos += n
+			res = append(res, v)
+		}
+		return res, pos, nil
+	case 5:
+		if arg > uint64(len(b)) { return nil, 0, ErrInvalidCBOR }
+		res := make(map[any]any, int(arg))
+		for range arg {
+			k, n, err := decodeCBORItem(b[pos:], depth+1)
+			if err != nil { return nil, 0, err }
+			pos += n
+			switch k.(type) {
+			case int64, string: // only these are allowed as keys.
+			default: return nil, 0, ErrInvalidCBOR
+			}
+			v, n, err := decodeCBORItem(b[pos:], depth+1)
+			if err != nil { return nil, 0, err }
+			pos += n
+			res[k] = v
+		}
+		return res, pos, nil
+	case 6:
+		v, n, err := decodeCBORItem(b[pos:], depth+1)
+		if err != nil { return nil, 0, err }
+		return v, pos + n, nil
+	}
+	return nil, 0, ErrInvalidCBOR
+}
+
+func halfToFloat(h uint16) float32 {
+	sign := uint32(h >> 15) << 31
+	exp := uint32(h >> 10) & 0x1f
+	frac := uint32(h) & 0x3ff
+	switch exp {
+	case 0:
+		f := float32(frac) / 1024 / 16384
+		if sign != 0 { f = -f }
+		return f
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | frac << 13)
+	}
+	return math.Float32frombits(sign | (exp + 112) << 23 | frac << 13)
+}
+
@@ -0,0 +1,116 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+// COSE_Key (RFC 9052) parsing & signature verification. the algorithms
+// supported are the ones requested in pubKeyCredParams; see
+// SUPPORTED_ALGORITHM_LIST.
+
+const (
+	COSE_ALG_ES256 = -7
+	COSE_ALG_EDDSA = -8
+	COSE_ALG_RS256 = -257
+)
+
+var SUPPORTED_ALGORITHM_LIST = []int64{COSE_ALG_ES256, COSE_ALG_EDDSA, COSE_ALG_RS256}
+
+const (
+	coseKeyType = 1
+	coseKeyAlg = 3
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+	coseCurveP256 = 1
+	coseCurveEd25519 = 6
+)
+
+var ErrUnsupportedKey = errors.New("Unsupported public key")
+var ErrInvalidSignature = errors.New("Invalid signature")
+
+type PublicKey struct {
+	Algorithm int64
+	key crypto.PublicKey
+}
+
+func coseInt(m map[any]any, k int64) (int64, bool) {
+	v, ok := m[k].(int64)
+	return v, ok
+}
+
+func coseBytes(m map[any]any, k int64) ([]byte, bool) {
+	v, ok := m[k].([]byte)
+	return v, ok
+}
+
+// parses a cbor-encoded COSE_Key.
+func ParsePublicKey(b []byte) (*PublicKey, error) {
+	v, _, err := decodeCBOR(b)
+	if err != nil { return nil, err }
+	m, ok := v.(map[any]any)
+	if !ok { return nil, ErrUnsupportedKey }
+	kty, ok := coseInt(m, coseKeyType)
+	if !ok { return nil, ErrUnsupportedKey }
+	alg, ok := coseInt(m, coseKeyAlg)
+	if !ok { return nil, ErrUnsupportedKey }
+	switch {
+	case kty == coseKeyTypeEC2 && alg == COSE_ALG_ES256:
+		crv, _ := coseInt(m, -1)
+		x, okx := coseBytes(m, -2)
+		y, oky := coseBytes(m, -3)
+		if crv != coseCurveP256 || !okx || !oky || len(x) != 32 || len(y) != 32 {
+			return nil, ErrUnsupportedKey
+		}
+		// ecdsa.PublicKey doesn't check whether the point is on the
+		// curve, so we go thru the uncompressed point encoding.
+		point := append([]byte{4}, append(x, y...)...)
+		k, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+		if err != nil { return nil, ErrUnsupportedKey }
+		return &PublicKey{ Algorithm: alg, key: k }, nil
+	case kty == coseKeyTypeOKP && alg == COSE_ALG_EDDSA:
+		crv, _ := coseInt(m, -1)
+		x, ok := coseBytes(m, -2)
+		if crv != coseCurveEd25519 || !ok || len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedKey
+		}
+		return &PublicKey{ Algorithm: alg, key: ed25519.PublicKey(x) }, nil
+	case kty == coseKeyTypeRSA && alg == COSE_ALG_RS256:
+		n, okn := coseBytes(m, -1)
+		e, oke := coseBytes(m, -2)
+		if !okn || !oke || len(e) > 4 || len(n) < 256 {
+			return nil, ErrUnsupportedKey
+		}
+		ev := new(big.Int).SetBytes(e)
+		return &PublicKey{ Algorithm: alg, key: &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(ev.Int64()),
+		}}, nil
+	}
+	return nil, ErrUnsupportedKey
+}
+
+func (k *PublicKey) Verify(data []byte, sig []byte) error {
+	switch k.Algorithm {
+	case COSE_ALG_ES256:
+		h := sha256.Sum256(data)
+		if !ecdsa.VerifyASN1(k.key.(*ecdsa.PublicKey), h[:], sig) { return ErrInvalidSignature }
+		return nil
+	case COSE_ALG_EDDSA:
+		if !ed25519.Verify(k.key.(ed25519.PublicKey), data, sig) { return ErrInvalidSignature }
+		return nil
+	case COSE_ALG_RS256:
+		h := sha256.Sum256(data)
+		if rsa.VerifyPKCS1v15(k.key.(*rsa.PublicKey), crypto.SHA256, h[:], sig) != nil { return ErrInvalidSignature }
+		return nil
+	}
+	return ErrUnsupportedKey
+}
+
@@ -0,0 +1,193 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// server-side verification of webauthn (https://www.w3.org/TR/webauthn-2/)
+// registration & authentication ceremonies. attestation statements
+// are not verified (we always ask for "none" attestation), i.e. we
+// trust whatever authenticator the user registers, the same way we
+// trust the ssh keys they upload.
+
+const (
+	FLAG_USER_PRESENT = 0x01
+	FLAG_USER_VERIFIED = 0x04
+	FLAG_BACKUP_ELIGIBLE = 0x08
+	FLAG_BACKUP_STATE = 0x10
+	FLAG_ATTESTED_CREDENTIAL_DATA = 0x40
+	FLAG_EXTENSION_DATA = 0x80
+)
+
+const CHALLENGE_SIZE = 32
+
+var ErrInvalidClientData = errors.New("Invalid client data")
+var ErrChallengeMismatch = errors.New("Challenge mismatch")
+var ErrOriginMismatch = errors.New("Origin mismatch")
+var ErrInvalidAuthenticatorData = errors.New("Invalid authenticator data")
+var ErrRPIDMismatch = errors.New("Relying party id mismatch")
+var ErrUserNotPresent = errors.New("User presence not asserted")
+var ErrUserNotVerified = errors.New("User verification required but not performed")
+var ErrSignCount = errors.New("Signature counter went backwards; the authenticator may have been cloned")
+
+// webauthn uses base64url without padding everywhere.
+var Encoding = base64.RawURLEncoding
+
+func NewChallenge() (string, error) {
+	b := make([]byte, CHALLENGE_SIZE)
+	_, err := rand.Read(b)
+	if err != nil { return "", err }
+	return Encoding.EncodeToString(b), nil
+}
+
+type RelyingParty struct {
+	// usually the host name of the site, e.g. "git.example.com".
+	ID string
+	// e.g. "https://git.example.com".
+	Origin string
+}
+
+type ClientData struct {
+	Type string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin string `json:"origin"`
+	CrossOrigin bool `json:"crossOrigin"`
+}
+
+func ParseClientData(b []byte) (*ClientData, error) {
+	res := new(ClientData)
+	err := json.Unmarshal(b, res)
+	if err != nil { return nil, ErrInvalidClientData }
+	return res, nil
+}
+
+func (rp *RelyingParty) checkClientData(cd *ClientData, typ string, challenge string) error {
+	if cd.Type != typ { return ErrInvalidClientData }
+	if subtle.ConstantTimeCompare([]byte(cd.Challenge), []byte(challenge)) != 1 {
+		return ErrChallengeMismatch
+	}
+	if cd.Origin != rp.Origin || cd.CrossOrigin { return ErrOriginMismatch }
+	return nil
+}
+
+type AuthenticatorData struct {
+	RPIDHash []byte
+	Flags byte
+	SignCount uint32
+	// the following are only available when
+	// FLAG_ATTESTED_CREDENTIAL_DATA is set.
+	AAGUID []byte
+	CredentialId []byte
+	// cbor-encoded COSE_Key.
+	PublicKey []byte
+}
+
+func ParseAuthenticatorData(b []byte) (*AuthenticatorData, error) {
+	if len(b) < 37 { return nil, ErrInvalidAuthenticatorData }
+	res := &AuthenticatorData{
+		RPIDHash: b[:32],
+		Flags: b[32],
+		SignCount: binary.BigEndian.Uint32(b[33:37]),
+	}
+	if res.Flags & FLAG_ATTESTED_CREDENTIAL_DATA != 0 {
+		rest := b[37:]
+		if len(rest) < 18 { return nil, ErrInvalidAuthenticatorData }
+		res.AAGUID = rest[:16]
+		l := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if len(rest) < l { return nil, ErrInvalidAuthenticatorData }
+		res.CredentialId = rest[:l]
+		rest = rest[l:]
+		_, n, err := decodeCBOR(rest)
+		if err != nil { return nil, ErrInvalidAuthenticatorData }
+		res.PublicKey = rest[:n]
+	}
+	return res, nil
+}
+
+func (rp *RelyingParty) checkAuthenticatorData(ad *AuthenticatorData, requireUserVerification bool) error {
+	h := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(h[:], ad.RPIDHash) { return ErrRPIDMismatch }
+	if ad.Flags & FLAG_USER_PRESENT == 0 { return ErrUserNotPresent }
+	if requireUserVerification && ad.Flags & FLAG_USER_VERIFIED == 0 { return ErrUserNotVerified }
+	return nil
+}
+
+type NewCredential struct {
+	Id []byte
+	PublicKey []byte
+	SignCount uint32
+	UserVerified bool
+	BackupEligible bool
+}
+
+// verifies the response of navigator.credentials.create().
+func (rp *RelyingParty) VerifyRegistration(challenge string, clientDataJSON []byte, attestationObject []byte, requireUserVerification bool) (*NewCredential, error) {
+	cd, err := ParseClientData(clientDataJSON)
+	if err != nil { return nil, err }
+	err = rp.checkClientData(cd, "webauthn.create", challenge)
+	if err != nil { return nil, err }
+	v, _, err := decodeCBOR(attestationObject)
+	if err != nil { return nil, err }
+	m, ok := v.(map[any]any)
+	if !ok { return nil, ErrInvalidCBOR }
+	authData, ok := m["authData"].([]byte)
+	if !ok { return nil, ErrInvalidAuthenticatorData }
+	ad, err := ParseAuthenticatorData(authData)
+	if err != nil { return nil, err }
+	err = rp.checkAuthenticatorData(ad, requireUserVerification)
+	if err != nil { return nil, err }
+	if ad.CredentialId == nil { return nil, ErrInvalidAuthenticatorData }
+	if len(ad.CredentialId) > 1023 { return nil, ErrInvalidAuthenticatorData }
+	_, err = ParsePublicKey(ad.PublicKey)
+	if err != nil { return nil, err }
+	return &NewCredential{
+		Id: ad.CredentialId,
+		PublicKey: ad.PublicKey,
+		SignCount: ad.SignCount,
+		UserVerified: ad.Flags & FLAG_USER_VERIFIED != 0,
+		BackupEligible: ad.Flags & FLAG_BACKUP_ELIGIBLE != 0,
+	}, nil
+}
+
+// verifies the response of navigator.credentials.get() against a
+// stored credential. returns the new signature counter, which the
+// caller should store.
+func (rp *RelyingParty) VerifyAssertion(challenge string, publicKey []byte, storedSignCount uint32, clientDataJSON []byte, authenticatorData []byte, signature []byte, requireUserVerification bool) (uint32, error) {
+	cd, err := ParseClientData(clientDataJSON)
+	if err != nil { return 0, err }
+	err = rp.checkClientData(cd, "webauthn.get", challenge)
+	if err != nil { return 0, err }
+	ad, err := ParseAuthenticatorData(authenticatorData)
+	if err != nil { return 0, err }
+	err = rp.checkAuthenticatorData(ad, requireUserVerification)
+	if err != nil { return 0, err }
+	pk, err := ParsePublicKey(publicKey)
+	if err != nil { return 0, err }
+	h := sha256.Sum256(clientDataJSON)
+	signed := make([]byte, 0, len(authenticatorData) + len(h))
+	signed = append(signed, authenticatorData...)
+	signed = append(signed, h[:]...)
+	err = pk.Verify(signed, signature)
+	if err != nil { return 0, err }
+	// authenticators that don't implement the counter always send 0.
+	if ad.SignCount != 0 || storedSignCount != 0 {
+		if ad.SignCount <= storedSignCount { return 0, ErrSignCount }
+	}
+	return ad.SignCount, nil
+}
+
+func DecodeField(s string) ([]byte, error) {
+	res, err := Encoding.DecodeString(s)
+	if err != nil { return nil, fmt.Errorf("Invalid base64url data: %s", err) }
+	return res, nil
+}
+
@@ -0,0 +1,327 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// a software authenticator w/ an ES256 key, which produces the same
+// data a browser would hand over from navigator.credentials.create()
+// & navigator.credentials.get().
+
+const testRPID = "git.example.com"
+const testOrigin = "https://git.example.com"
+
+// only the parts of cbor needed to build the attestation object & the
+// COSE_Key.
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{ major << 5 | byte(n) }
+	case n < 0x100:
+		return []byte{ major << 5 | 24, byte(n) }
+	case n < 0x10000:
+		return binary.BigEndian.AppendUint16([]byte{ major << 5 | 25 }, uint16(n))
+	}
+	return binary.BigEndian.AppendUint32([]byte{ major << 5 | 26 }, uint32(n))
+}
+
+func cborInt(i int64) []byte {
+	if i < 0 { return cborHead(1, uint64(-1 - i)) }
+	return cborHead(0, uint64(i))
+}
+
+func cborBytes(b []byte) []byte {
+	return append(cborHead(2, uint64(len(b))), b...)
+}
+
+func cborText(s string) []byte {
+	return append(cborHead(3, uint64(len(s))), s...)
+}
+
+// `kv` are the encoded keys & values in turn.
+func cborMap(kv ...[]byte) []byte {
+	res := cborHead(5, uint64(len(kv) / 2))
+	for _, v := range kv { res = append(res, v...) }
+	return res
+}
+
+type softAuthenticator struct {
+	rpId string
+	key *ecdsa.PrivateKey
+	credentialId []byte
+	signCount uint32
+	// authenticators w/o a signature counter always send 0.
+	noCounter bool
+	flags byte
+}
+
+func newSoftAuthenticator(t *testing.T, rpId string) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil { t.Fatal(err) }
+	credentialId := make([]byte, 16)
+	rand.Read(credentialId)
+	return &softAuthenticator{
+		rpId: rpId,
+		key: key,
+		credentialId: credentialId,
+		flags: FLAG_USER_PRESENT | FLAG_USER_VERIFIED,
+	}
+}
+
+func (a *softAuthenticator) coseKey(t *testing.T) []byte {
+	point, err := a.key.PublicKey.Bytes()
+	if err != nil { t.Fatal(err) }
+	return cborMap(
+		cborInt(coseKeyType), cborInt(coseKeyTypeEC2),
+		cborInt(coseKeyAlg), cborInt(COSE_ALG_ES256),
+		cborInt(-1), cborInt(coseCurveP256),
+		cborInt(-2), cborBytes(point[1:33]),
+		cborInt(-3), cborBytes(point[33:]),
+	)
+}
+
+func (a *softAuthenticator) authenticatorData(t *testing.T, flags byte, withCredential bool) []byte {
+	h := sha256.Sum256([]byte(a.rpId))
+	res := append([]byte{}, h[:]...)
+	if withCredential { flags |= FLAG_ATTESTED_CREDENTIAL_DATA }
+	res = append(res, flags)
+	res = binary.BigEndian.AppendUint32(res, a.signCount)
+	if withCredential {
+		res = append(res, make([]byte, 16)...)
+		res = binary.BigEndian.AppendUint16(res, uint16(len(a.credentialId)))
+		res = append(res, a.credentialId...)
+		res = append(res, a.coseKey(t)...)
+	}
+	return res
+}
+
+func clientDataJSON(t *testing.T, typ string, challenge string, origin string) []byte {
+	b, err := json.Marshal(&ClientData{ Type: typ, Challenge: challenge, Origin: origin })
+	if err != nil { t.Fatal(err) }
+	return b
+}
+
+// returns clientDataJSON & attestationObject.
+func (a *softAuthenticator) create(t *testing.T, challenge string, origin string) ([]byte, []byte) {
+	attestationObject := cborMap(
+		cborText("fmt"), cborText("none"),
+		cborText("attStmt"), cborMap(),
+		cborText("authData"), cborBytes(a.authenticatorData(t, a.flags, true)),
+	)
+	return clientDataJSON(t, "webauthn.create", challenge, origin), attestationObject
+}
+
+// returns clientDataJSON, authenticatorData & signature.
+func (a *softAuthenticator) get(t *testing.T, challenge string, origin string) ([]byte, []byte, []byte) {
+	if !a.noCounter { a.signCount += 1 }
+	cd := clientDataJSON(t, "webauthn.get", challenge, origin)
+	ad := a.authenticatorData(t, a.flags, false)
+	h := sha256.Sum256(cd)
+	digest := sha256.Sum256(append(append([]byte{}, ad...), h[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil { t.Fatal(err) }
+	return cd, ad, sig
+}
+
+func newTestChallenge(t *testing.T) string {
+	c, err := NewChallenge()
+	if err != nil { t.Fatal(err) }
+	return c
+}
+
+func register(t *testing.T, rp *RelyingParty, a *softAuthenticator) *NewCredential {
+	challenge := newTestChallenge(t)
+	cd, ao := a.create(t, challenge, testOrigin)
+	cred, err := rp.VerifyRegistration(challenge, cd, ao, false)
+	if err != nil { t.Fatalf("registration failed: %s", err) }
+	return cred
+}
+
+func TestRegistration(t *testing.T) {
+	rp := &RelyingParty{ ID: testRPID, Origin: testOrigin }
+	a := newSoftAuthenticator(t, testRPID)
+	cred := register(t, rp, a)
+	if string(cred.Id) != string(a.credentialId) { t.Errorf("credential id = %x, want %x", cred.Id, a.credentialId) }
+	if !cred.UserVerified { t.Errorf("user verified flag not reported") }
+	if cred.SignCount != 0 { t.Errorf("sign count = %d, want 0", cred.SignCount) }
+	pk, err := ParsePublicKey(cred.PublicKey)
+	if err != nil { t.Fatalf("stored public key can't be parsed: %s", err) }
+	if pk.Algorithm != COSE_ALG_ES256 { t.Errorf("algorithm = %d, want %d", pk.Algorithm, COSE_ALG_ES256) }
+}
+
+func TestRegistrationRejected(t *testing.T) {
+	rp := &RelyingParty{ ID: testRPID, Origin: testOrigin }
+	for _, c := range []struct{
+		name string
+		rpId string
+		origin string
+		flags byte
+		requireUserVerification bool
+		wrongChallenge bool
+		want error
+	}{
+		{ "wrong origin", testRPID, "https://evil.example.com", FLAG_USER_PRESENT, false, false, ErrOriginMismatch },
+		{ "wrong challenge", testRPID, testOrigin, FLAG_USER_PRESENT, false, true, ErrChallengeMismatch },
+		{ "wrong rp id", "evil.example.com", testOrigin, FLAG_USER_PRESENT, false, false, ErrRPIDMismatch },
+		{ "user not present", testRPID, testOrigin, 0, false, false, ErrUserNotPresent },
+		{ "user not verified", testRPID, testOrigin, FLAG_USER_PRESENT, true, false, ErrUserNotVerified },
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			a := newSoftAuthenticator(t, c.rpId)
+			a.flags = c.flags
+			challenge := newTestChallenge(t)
+			cd, ao := a.create(t, challenge, c.origin)
+			if c.wrongChallenge { challenge = newTestChallenge(t) }
+			_, err := rp.VerifyRegistration(challenge, cd, ao, c.requireUserVerification)
+			if !errors.Is(err, c.want) { t.Errorf("err = %v, want %v", err, c.want) }
+		})
+	}
+}
+
+func TestAssertion(t *testing.T) {
+	rp := &RelyingParty{ ID: testRPID, Origin: testOrigin }
+	a := newSoftAuthenticator(t, testRPID)
+	cred := register(t, rp, a)
+	signCount := cred.SignCount
+	for i := 0; i < 2; i++ {
+		challenge := newTestChallenge(t)
+		cd, ad, sig := a.get(t, challenge, testOrigin)
+		n, err := rp.VerifyAssertion(challenge, cred.PublicKey, signCount, cd, ad, sig, true)
+		if err != nil { t.Fatalf("assertion %d failed: %s", i, err) }
+		if n != a.signCount { t.Errorf("sign count = %d, want %d", n, a.signCount) }
+		signCount = n
+	}
+}
+
+func TestAssertionRejected(t *testing.T) {
+	rp := &RelyingParty{ ID: testRPID, Origin: testOrigin }
+	a := newSoftAuthenticator(t, testRPID)
+	cred := register(t, rp, a)
+	otherCred := newSoftAuthenticator(t, testRPID)
+
+	t.Run("wrong origin", func(t *testing.T) {
+		challenge := newTestChallenge(t)
+		cd, ad, sig := a.get(t, challenge, "https://evil.example.com")
+		_, err := rp.VerifyAssertion(challenge, cred.PublicKey, 0, cd, ad, sig, false)
+		if !errors.Is(err, ErrOriginMismatch) { t.Errorf("err = %v, want %v", err, ErrOriginMismatch) }
+	})
+	t.Run("wrong challenge", func(t *testing.T) {
+		cd, ad, sig := a.get(t, newTestChallenge(t), testOrigin)
+		_, err := rp.VerifyAssertion(newTestChallenge(t), cred.PublicKey, 0, cd, ad, sig, false)
+		if !errors.Is(err, ErrChallengeMismatch) { t.Errorf("err = %v, want %v", err, ErrChallengeMismatch) }
+	})
+	t.Run("wrong rp id", func(t *testing.T) {
+		// same key, different rp id.
+		other := newSoftAuthenticator(t, "evil.example.com")
+		other.key = a.key
+		challenge := newTestChallenge(t)
+		cd, ad, sig := other.get(t, challenge, testOrigin)
+		_, err := rp.VerifyAssertion(challenge, cred.PublicKey, 0, cd, ad, sig, false)
+		if !errors.Is(err, ErrRPIDMismatch) { t.Errorf("err = %v, want %v", err, ErrRPIDMismatch) }
+	})
+	t.Run("registration data used for assertion", func(t *testing.T) {
+		challenge := newTestChallenge(t)
+		cd, _ := a.create(t, challenge, testOrigin)
+		_, ad, sig := a.get(t, challenge, testOrigin)
+		_, err := rp.VerifyAssertion(challenge, cred.PublicKey, 0, cd, ad, sig, false)
+		if !errors.Is(err, ErrInvalidClientData) { t.Errorf("err = %v, want %v", err, ErrInvalidClientData) }
+	})
+	t.Run("signed by another key", func(t *testing.T) {
+		challenge := newTestChallenge(t)
+		cd, ad, sig := otherCred.get(t, challenge, testOrigin)
+		_, err := rp.VerifyAssertion(challenge, cred.PublicKey, 0, cd, ad, sig, false)
+		if !errors.Is(err, ErrInvalidSignature) { t.Errorf("err = %v, want %v", err, ErrInvalidSignature) }
+	})
+	t.Run("tampered authenticator data", func(t *testing.T) {
+		challenge := newTestChallenge(t)
+		cd, ad, sig := a.get(t, challenge, testOrigin)
+		ad[32] ^= FLAG_BACKUP_STATE
+		_, err := rp.VerifyAssertion(challenge, cred.PublicKey, 0, cd, ad, sig, false)
+		if !errors.Is(err, ErrInvalidSignature) { t.Errorf("err = %v, want %v", err, ErrInvalidSignature) }
+	})
+	t.Run("user not verified", func(t *testing.T) {
+		a.flags = FLAG_USER_PRESENT
+		defer func() { a.flags = FLAG_USER_PRESENT | FLAG_USER_VERIFIED }()
+		challenge := newTestChallenge(t)
+		cd, ad, sig := a.get(t, challenge, testOrigin)
+		_, err := rp.VerifyAssertion(challenge, cred.PublicKey, 0, cd, ad, sig, true)
+		if !errors.Is(err, ErrUserNotVerified) { t.Errorf("err = %v, want %v", err, ErrUserNotVerified) }
+	})
+}
+
+func TestAssertionSignCount(t *testing.T) {
+	rp := &RelyingParty{ ID: testRPID, Origin: testOrigin }
+	a := newSoftAuthenticator(t, testRPID)
+	cred := register(t, rp, a)
+	a.signCount = 10
+	challenge := newTestChallenge(t)
+	cd, ad, sig := a.get(t, challenge, testOrigin)
+	// the stored counter is ahead of the authenticator, e.g. a clone
+	// of it has been used in the meantime.
+	for _, stored := range []uint32{ 11, 12 } {
+		_, err := rp.VerifyAssertion(challenge, cred.PublicKey, stored, cd, ad, sig, false)
+		if !errors.Is(err, ErrSignCount) { t.Errorf("stored %d: err = %v, want %v", stored, err, ErrSignCount) }
+	}
+	n, err := rp.VerifyAssertion(challenge, cred.PublicKey, 10, cd, ad, sig, false)
+	if err != nil { t.Fatalf("err = %v", err) }
+	if n != 11 { t.Errorf("sign count = %d, want 11", n) }
+
+	// authenticators w/o a counter always send 0, which is fine as
+	// long as they've never sent anything else.
+	a.signCount = 0
+	a.noCounter = true
+	cd, ad, sig = a.get(t, challenge, testOrigin)
+	_, err = rp.VerifyAssertion(challenge, cred.PublicKey, 0, cd, ad, sig, false)
+	if err != nil { t.Errorf("zero counter: err = %v", err) }
+	_, err = rp.VerifyAssertion(challenge, cred.PublicKey, 11, cd, ad, sig, false)
+	if !errors.Is(err, ErrSignCount) { t.Errorf("zero counter after non-zero: err = %v, want %v", err, ErrSignCount) }
+}
+
+func TestParsePublicKey(t *testing.T) {
+	a := newSoftAuthenticator(t, testRPID)
+	point, err := a.key.PublicKey.Bytes()
+	if err != nil { t.Fatal(err) }
+	y := append([]byte{}, point[33:]...)
+	y[31] ^= 1
+	for _, c := range []struct{
+		name string
+		key []byte
+	}{
+		{ "point not on the curve", cborMap(
+			cborInt(coseKeyType), cborInt(coseKeyTypeEC2),
+			cborInt(coseKeyAlg), cborInt(COSE_ALG_ES256),
+			cborInt(-1), cborInt(coseCurveP256),
+			cborInt(-2), cborBytes(point[1:33]),
+			cborInt(-3), cborBytes(y),
+		) },
+		{ "algorithm not matching the key type", cborMap(
+			cborInt(coseKeyType), cborInt(coseKeyTypeEC2),
+			cborInt(coseKeyAlg), cborInt(COSE_ALG_RS256),
+			cborInt(-1), cborInt(coseCurveP256),
+			cborInt(-2), cborBytes(point[1:33]),
+			cborInt(-3), cborBytes(point[33:]),
+		) },
+		{ "unsupported curve", cborMap(
+			cborInt(coseKeyType), cborInt(coseKeyTypeEC2),
+			cborInt(coseKeyAlg), cborInt(COSE_ALG_ES256),
+			cborInt(-1), cborInt(2),
+			cborInt(-2), cborBytes(point[1:33]),
+			cborInt(-3), cborBytes(point[33:]),
+		) },
+		{ "not a map", cborBytes(point) },
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ParsePublicKey(c.key)
+			if !errors.Is(err, ErrUnsupportedKey) { t.Errorf("err = %v, want %v", err, ErrUnsupportedKey) }
+		})
+	}
+	_, err = ParsePublicKey([]byte{ 0xa1 })
+	if err == nil { t.Errorf("truncated cbor accepted") }
+}
@@ -0,0 +1,154 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// the audit log. see docs/audit-log.org.
+
+// records an action performed by the logged-in user. `diff` is
+// usually a map from the changed field to `[]any{old, new}`; nil if
+// there isn't any. failing to record is logged but doesn't stop the
+// action, since at that point it's already done.
+func (ctx *RouterContext) Audit(action string, target string, diff any, w http.ResponseWriter, r *http.Request) {
+	actor := ""
+	if ctx.LoginInfo != nil { actor = ctx.LoginInfo.UserName }
+	ctx.AuditAs(actor, action, target, diff, w, r)
+}
+
+// same as `Audit` but with the actor specified, e.g. for logins.
+func (ctx *RouterContext) AuditAs(actor string, action string, target string, diff any, w http.ResponseWriter, r *http.Request) {
+	if ctx.DatabaseInterface == nil || !ctx.Config.IsInForgeMode() { return }
+	d := ""
+	if diff != nil {
+		b, err := json.Marshal(diff)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "audit: failed to serialize diff", "action", action, "error", err)
+		} else {
+			d = string(b)
+		}
+	}
+	err := ctx.DatabaseInterface.AppendAuditLog(&model.AuditLogEntry{
+		Timestamp: time.Now().Unix(),
+		Actor: actor,
+		IP: ResolveMostPossibleIP(w, r),
+		Action: action,
+		Target: target,
+		Diff: d,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "audit: failed to record", "action", action, "actor", actor, "target", target, "error", err)
+	}
+}
+
+func AuditUserTarget(name string) string { return "user:" + name }
+func AuditNamespaceTarget(ns string) string { return "namespace:" + ns }
+func AuditRepositoryTarget(ns string, name string) string { return fmt.Sprintf("repo:%s:%s", ns, name) }
+func AuditConfigTarget(section string) string { return "config:" + section }
+
+// the diff recorded for ssh key changes. the key text itself isn't
+// recorded; only its fingerprint (empty if the key can't be parsed).
+func AuditSSHKeyDiff(keyName string, keyText string) map[string]any {
+	fp := ""
+	pk, _, _, _, err := gossh.ParseAuthorizedKey([]byte(keyText))
+	if err == nil { fp = gossh.FingerprintSHA256(pk) }
+	return map[string]any{
+		"key": keyName,
+		"fingerprint": fp,
+	}
+}
+
+// the diff of a member's privilege in a namespace or repository
+// ACL. a nil tuple (i.e. not a member) is recorded as null. nil if
+// nothing is changed.
+func AuditACLDiff(user string, old *model.ACLTuple, new *model.ACLTuple) map[string]any {
+	if old == nil && new == nil { return nil }
+	if old != nil && new != nil && *old == *new { return nil }
+	var a, b any
+	if old != nil { a = model.ToCommaSeparatedString(old) }
+	if new != nil { b = model.ToCommaSeparatedString(new) }
+	return map[string]any{
+		"user": user,
+		"privilege": []any{a, b},
+	}
+}
+
+// records an admin config edit with the diff against `before`, which
+// is taken with `ConfigSnapshot` before the edit. nothing is recorded
+// if nothing is changed.
+func (ctx *RouterContext) AuditConfigEdit(action string, section string, before map[string]any, w http.ResponseWriter, r *http.Request) {
+	d := ConfigSnapshotDiff(before, ConfigSnapshot(ctx.Config))
+	if d == nil { return }
+	ctx.Audit(action, AuditConfigTarget(section), d, w, r)
+}
+
+// the fields whose name contains any of these are recorded as
+// changed but without their values.
+var auditSecretFieldKeyword = []string{"password", "secret", "token", "key"}
+
+func isAuditSecretField(k string) bool {
+	l := strings.ToLower(k)
+	for _, s := range auditSecretFieldKeyword {
+		if strings.Contains(l, s) { return true }
+	}
+	return false
+}
+
+func flattenConfigSnapshot(prefix string, v any, res map[string]any) {
+	if m, ok := v.(map[string]any); ok {
+		for k, vv := range m {
+			p := k
+			if len(prefix) > 0 { p = prefix + "." + k }
+			flattenConfigSnapshot(p, vv, res)
+		}
+		return
+	}
+	res[prefix] = v
+}
+
+// takes a snapshot of the config for computing the diff of an admin
+// config edit with `ConfigSnapshotDiff`. the keys are the json paths
+// of the fields (e.g. `mailer.smtpServer`).
+func ConfigSnapshot(cfg *gitus.GitusConfig) map[string]any {
+	res := make(map[string]any, 0)
+	b, err := json.Marshal(cfg)
+	if err != nil { return res }
+	var m map[string]any
+	if err = json.Unmarshal(b, &m); err != nil { return res }
+	flattenConfigSnapshot("", m, res)
+	return res
+}
+
+// returns the changed fields as `[old, new]`; the values of secrets
+// are replaced with "***". nil if nothing is changed.
+func ConfigSnapshotDiff(before map[string]any, after map[string]any) map[string]any {
+	keyList := make([]string, 0)
+	for k := range before { keyList = append(keyList, k) }
+	for k := range after {
+		if _, ok := before[k]; !ok { keyList = append(keyList, k) }
+	}
+	sort.Strings(keyList)
+	res := make(map[string]any, 0)
+	for _, k := range keyList {
+		a, b := before[k], after[k]
+		if reflect.DeepEqual(a, b) { continue }
+		if isAuditSecretField(k) {
+			res[k] = []any{"***", "***"}
+		} else {
+			res[k] = []any{a, b}
+		}
+	}
+	if len(res) <= 0 { return nil }
+	return res
+}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"html/template"
+	"log/slog"
 	"net/http"
 	"path"
 	"strings"
@@ -16,6 +17,7 @@ import (
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/confirm_code"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/mail"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/maintenance"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/receipt"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/session"
@@ -39,6 +41,9 @@ type RouterContext struct {
 	RateLimiter *RateLimiter
 	ConfirmCodeManager confirm_code.GitusConfirmCodeManager
 	HostModeConfigCache model.HostModeConfigCache
+	ConfigReloader *ConfigReloader
+	// nil when not in forge mode.
+	Maintenance *maintenance.Scheduler
 }
 
 func (ctx RouterContext) LoadTemplate(name string) *template.Template {
@@ -46,6 +51,8 @@ func (ctx RouterContext) LoadTemplate(name string) *template.Template {
 }
 
 func (ctx *RouterContext) NewLocal() *RouterContext {
+	contextLock.RLock()
+	defer contextLock.RUnlock()
 	return &RouterContext{
 		GitUserHomeDirectory: ctx.GitUserHomeDirectory,
 		Config: ctx.Config,
@@ -61,6 +68,8 @@ func (ctx *RouterContext) NewLocal() *RouterContext {
 		LastError: ctx.LastError,
 		RateLimiter: ctx.RateLimiter,
 		ConfirmCodeManager: ctx.ConfirmCodeManager,
+		ConfigReloader: ctx.ConfigReloader,
+		Maintenance: ctx.Maintenance,
 	}
 }
 
@@ -101,6 +110,7 @@ func (ctx RouterContext) ReportNormalError(msg string, w http.ResponseWriter, r
 }
 
 func (ctx RouterContext) ReportInternalError(msg string, w http.ResponseWriter, r *http.Request) {
+	slog.ErrorContext(r.Context(), "internal error", "method", r.Method, "path", r.URL.Path, "message", msg)
 	w.WriteHeader(500)
 	LogTemplateError(ctx.LoadTemplate("error").Execute(w,
 		templates.ErrorTemplateModel{
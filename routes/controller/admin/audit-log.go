@@ -0,0 +1,174 @@
+package admin
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	. "github.com/GitusCodeForge/Gitus/routes"
+	"github.com/GitusCodeForge/Gitus/templates"
+)
+
+// the dates are given as `yyyy-mm-dd` in server local time; `until`
+// includes the whole day.
+func parseAuditLogFilter(r *http.Request) (*model.AuditLogFilter, error) {
+	q := r.URL.Query()
+	res := &model.AuditLogFilter{
+		Actor: strings.TrimSpace(q.Get("actor")),
+		Action: strings.TrimSpace(q.Get("action")),
+		Target: strings.TrimSpace(q.Get("target")),
+		IP: strings.TrimSpace(q.Get("ip")),
+	}
+	if s := strings.TrimSpace(q.Get("since")); len(s) > 0 {
+		t, err := time.ParseInLocation(time.DateOnly, s, time.Local)
+		if err != nil { return nil, fmt.Errorf("Invalid start date: %s", s) }
+		res.Since = t.Unix()
+	}
+	if s := strings.TrimSpace(q.Get("until")); len(s) > 0 {
+		t, err := time.ParseInLocation(time.DateOnly, s, time.Local)
+		if err != nil { return nil, fmt.Errorf("Invalid end date: %s", s) }
+		res.Until = t.AddDate(0, 0, 1).Unix() - 1
+	}
+	return res, nil
+}
+
+// the diff is exported as it is instead of as a string.
+type auditLogExportEntry struct {
+	*model.AuditLogEntry
+	Diff json.RawMessage `json:"diff,omitempty"`
+}
+
+// entries are exported this many at a time so that the whole log
+// doesn't have to be held in memory.
+const auditLogExportBatchSize = 500
+
+// /admin/audit-log?actor=&action=&target=&ip=&since=&until=&p=&s=
+// /admin/audit-log/export?format=csv|json&(the same filter)
+func bindAdminAuditLogController(ctx *RouterContext) {
+	http.HandleFunc("GET /admin/audit-log", UseMiddleware(
+		[]Middleware{Logged, LoginRequired, AdminRequired,
+			GlobalVisibility, ErrorGuard,
+		}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			errorMsg := ""
+			filter, err := parseAuditLogFilter(r)
+			if err != nil {
+				errorMsg = err.Error()
+				filter = &model.AuditLogFilter{}
+			}
+			i, err := rc.DatabaseInterface.CountAuditLog(filter)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to count audit log: %s", err), w, r)
+				return
+			}
+			p := r.URL.Query().Get("p")
+			if len(p) <= 0 { p = "1" }
+			s := r.URL.Query().Get("s")
+			if len(s) <= 0 { s = "50" }
+			pageNum, err := strconv.ParseInt(p, 10, 64)
+			if err != nil { pageNum = 1 }
+			pageSize, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || pageSize <= 0 { pageSize = 50 }
+			totalPage := i / pageSize
+			if i % pageSize != 0 { totalPage += 1 }
+			if pageNum > totalPage { pageNum = totalPage }
+			if pageNum <= 1 { pageNum = 1 }
+			entryList, err := rc.DatabaseInterface.SearchAuditLogPaginated(filter, pageNum-1, pageSize)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to get audit log: %s", err), w, r)
+				return
+			}
+			q := r.URL.Query()
+			LogTemplateError(rc.LoadTemplate("admin/audit-log").Execute(w, &templates.AdminAuditLogTemplateModel{
+				Config: rc.Config,
+				LoginInfo: rc.LoginInfo,
+				ErrorMsg: errorMsg,
+				EntryList: entryList,
+				ActionList: model.AuditActionList,
+				PageInfo: &templates.PageInfoModel{
+					PageNum: pageNum,
+					PageSize: pageSize,
+					TotalPage: totalPage,
+				},
+				Actor: filter.Actor,
+				Action: filter.Action,
+				Target: filter.Target,
+				IP: filter.IP,
+				Since: strings.TrimSpace(q.Get("since")),
+				Until: strings.TrimSpace(q.Get("until")),
+			}))
+		},
+	))
+
+	http.HandleFunc("GET /admin/audit-log/export", UseMiddleware(
+		[]Middleware{Logged, LoginRequired, AdminRequired,
+			GlobalVisibility, ErrorGuard,
+		}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			filter, err := parseAuditLogFilter(r)
+			if err != nil {
+				rc.ReportNormalError(err.Error(), w, r)
+				return
+			}
+			format := r.URL.Query().Get("format")
+			if len(format) <= 0 { format = "csv" }
+			if format != "csv" && format != "json" {
+				rc.ReportNormalError("Invalid export format", w, r)
+				return
+			}
+			fileName := fmt.Sprintf("audit-log-%s.%s", time.Now().Format("20060102-150405"), format)
+			if format == "csv" {
+				w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			} else {
+				w.Header().Set("Content-Type", "application/json")
+			}
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", fileName))
+			var cw *csv.Writer
+			if format == "csv" {
+				cw = csv.NewWriter(w)
+				cw.Write([]string{"id", "time", "actor", "ip", "action", "target", "diff"})
+			} else {
+				w.Write([]byte("[\n"))
+			}
+			first := true
+			// newest first, the same as the page. the entries
+			// appended during the export may shift the pages & cause
+			// duplicates; the ids can be used to tell them apart.
+			for pageNum := int64(0); ; pageNum++ {
+				l, err := rc.DatabaseInterface.SearchAuditLogPaginated(filter, pageNum, auditLogExportBatchSize)
+				if err != nil {
+					// the header is already sent; all we can do is
+					// to cut the export short.
+					slog.ErrorContext(r.Context(), "audit log export failed", "error", err)
+					break
+				}
+				for _, e := range l {
+					if cw != nil {
+						cw.Write([]string{
+							strconv.FormatInt(e.AbsId, 10),
+							time.Unix(e.Timestamp, 0).Format(time.RFC3339),
+							e.Actor, e.IP, e.Action, e.Target, e.Diff,
+						})
+						continue
+					}
+					x := auditLogExportEntry{ AuditLogEntry: e }
+					if len(e.Diff) > 0 { x.Diff = json.RawMessage(e.Diff) }
+					b, err := json.Marshal(x)
+					if err != nil { continue }
+					if !first { w.Write([]byte(",\n")) }
+					w.Write(b)
+					first = false
+				}
+				if cw != nil { cw.Flush() }
+				if len(l) < auditLogExportBatchSize { break }
+			}
+			if cw == nil { w.Write([]byte("\n]\n")) }
+		},
+	))
+}
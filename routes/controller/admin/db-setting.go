@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	. "github.com/GitusCodeForge/Gitus/routes"
 	"github.com/GitusCodeForge/Gitus/templates"
 )
@@ -33,6 +34,7 @@ func bindAdminDatabaseSettingController(ctx *RouterContext) {
 			}
 			rc.Config.LockForSync()
 			defer rc.Config.Unlock()
+			before := ConfigSnapshot(rc.Config)
 			rc.Config.Database.Type = r.Form.Get("type")
 			rc.Config.Database.Path = r.Form.Get("path")
 			rc.Config.Database.URL = r.Form.Get("url")
@@ -45,6 +47,7 @@ func bindAdminDatabaseSettingController(ctx *RouterContext) {
 				rc.ReportInternalError(fmt.Sprintf("Error while saving config: %s", err), w, r)
 				return
 			}
+			rc.AuditConfigEdit(model.AUDIT_ADMIN_CONFIG, "database", before, w, r)
 			rc.ReportRedirect("/admin/db-setting", 3, "Setting Updated", "Your setting for main database has been updated.", w, r)
 		},
 	))
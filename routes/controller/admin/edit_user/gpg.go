@@ -109,6 +109,7 @@ func bindAdminEditUserGPGController(ctx *RouterContext) {
 				rc.ReportInternalError(fmt.Sprintf("Failed to update signing key: %s", err), w, r)
 				return
 			}
+			rc.Audit(model.AUDIT_GPG_KEY_UPDATE, AuditUserTarget(un), map[string]any{"key": kn}, w, r)
 			rc.ReportRedirect(fmt.Sprintf("/admin/user/%s/gpg", un), 3, "Updated", "The specified GPG key has been updated.", w, r)
 		},
 	))
@@ -158,6 +159,7 @@ func bindAdminEditUserGPGController(ctx *RouterContext) {
 				rc.ReportInternalError(fmt.Sprintf("Failed to delete signing key: %s", err), w, r)
 				return
 			}
+			rc.Audit(model.AUDIT_GPG_KEY_REMOVE, AuditUserTarget(un), map[string]any{"key": keyname}, w, r)
 			rc.ReportRedirect(fmt.Sprintf("/admin/user/%s/gpg", un), 3, "Deleted", "The specified GPG key has been deleted.", w, r)
 		},
 	))
@@ -192,6 +194,7 @@ func bindAdminEditUserGPGController(ctx *RouterContext) {
 				rc.ReportInternalError(err.Error(), w, r)
 				return
 			}
+			rc.Audit(model.AUDIT_GPG_KEY_ADD, AuditUserTarget(un), map[string]any{"key": keyName}, w, r)
 			rc.ReportRedirect(fmt.Sprintf("/admin/user/%s/gpg", un), 3, "Updated", "The key you've provided has been added to the database.", w, r)
 		},
 	))
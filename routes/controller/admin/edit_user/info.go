@@ -71,12 +71,16 @@ func bindAdminEditUserInfoController(ctx *RouterContext) {
 					rc.ReportRedirect("/admin/user-list", 0, "Error", "Not enough permission.", w, r)
 					return
 				}
+				oldStatus := user.Status
 				user.Status = model.GitusUserStatus(i)
 				err = rc.DatabaseInterface.UpdateUserInfo(un, user)
 				if err != nil {
 					rc.ReportInternalError(fmt.Sprintf("Failed to update user info: %s", err.Error()), w, r)
 					return
 				}
+				if oldStatus != user.Status {
+					rc.Audit(model.AUDIT_USER_STATUS, AuditUserTarget(un), map[string]any{"status": []any{oldStatus, user.Status}}, w, r)
+				}
 			case "password":
 				if !rc.LoginInfo.IsSuperAdmin && user.Status == model.SUPER_ADMIN {
 					rc.ReportRedirect("/admin/user-list", 0, "Error", "Not enough permission.", w, r)
@@ -103,6 +107,32 @@ func bindAdminEditUserInfoController(ctx *RouterContext) {
 				}
 				rc.DatabaseInterface.UpdateUserPassword(un, string(newpwh))
 				rc.SessionInterface.RevokeAllSession(un)
+				rc.Audit(model.AUDIT_SESSION_REVOKE_ALL, AuditUserTarget(un), map[string]any{"reason": "password-reset-by-admin"}, w, r)
+			case "2fa-reset":
+				// for users who have lost both their authenticator
+				// and their recovery codes.
+				user.TFAConfig = model.GitusUser2FAConfig{}
+				err = rc.DatabaseInterface.UpdateUserInfo(un, user)
+				if err != nil {
+					rc.ReportInternalError(fmt.Sprintf("Failed to update user info: %s", err.Error()), w, r)
+					return
+				}
+				// security keys are removed as well since passkeys
+				// can be used to log in on their own.
+				credList, err := rc.DatabaseInterface.GetAllWebAuthnCredentialByUsername(un)
+				if err != nil {
+					rc.ReportInternalError(fmt.Sprintf("Failed to retrieve security keys: %s", err.Error()), w, r)
+					return
+				}
+				for _, k := range credList {
+					err = rc.DatabaseInterface.RemoveWebAuthnCredential(un, k.CredentialId)
+					if err != nil {
+						rc.ReportInternalError(fmt.Sprintf("Failed to remove security key: %s", err.Error()), w, r)
+						return
+					}
+				}
+				rc.SessionInterface.RevokeAllSession(un)
+				rc.Audit(model.AUDIT_SESSION_REVOKE_ALL, AuditUserTarget(un), map[string]any{"reason": "2fa-reset-by-admin"}, w, r)
 			}
 			rc.ReportRedirect(fmt.Sprintf("/admin/user/%s/edit", un), 3, "Updated", "Your setting for this user has been updated.", w, r)
 		},
@@ -101,6 +101,7 @@ func bindAdminEditUserSSHController(ctx *RouterContext) {
 				ctx.ReportRedirect("/admin/user-list", 0, "Internal Error", fmt.Sprintf("Failed to update SSH key of user %s: %s", un, err.Error()), w, r)
 				return
 			}
+			rc.Audit(model.AUDIT_SSH_KEY_UPDATE, AuditUserTarget(un), AuditSSHKeyDiff(kn, ktext), w, r)
 			ctx.ReportRedirect(fmt.Sprintf("/admin/user/%s/ssh", un), 3, "Updated", "The specified SSH key has been updated.", w, r)
 		},
 	))
@@ -147,6 +148,7 @@ func bindAdminEditUserSSHController(ctx *RouterContext) {
 				ctx.ReportRedirect(fmt.Sprintf("/admin/user/%s/ssh", un), 0, "Internal Error", fmt.Sprintf("Failed to delete SSH keys of user %s: %s", un, err.Error()), w, r)
 				return
 			}
+			rc.Audit(model.AUDIT_SSH_KEY_REMOVE, AuditUserTarget(un), map[string]any{"key": keyname}, w, r)
 			ctx.SSHKeyManagingContext.RemoveAuthorizedKey(un, keyname)
 			err = ctx.SSHKeyManagingContext.Sync()
 			if err != nil {
@@ -183,6 +185,7 @@ func bindAdminEditUserSSHController(ctx *RouterContext) {
 				ctx.ReportInternalError(fmt.Sprintf("Failed to register authentication key: %s", err), w, r)
 				return
 			}
+			rc.Audit(model.AUDIT_SSH_KEY_ADD, AuditUserTarget(un), AuditSSHKeyDiff(keyName, keyText), w, r)
 			ctx.SSHKeyManagingContext.AddAuthorizedKey(un, keyName, keyText)
 			err = ctx.SSHKeyManagingContext.Sync()
 			if err != nil {
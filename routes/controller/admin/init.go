@@ -20,7 +20,11 @@ func BindAllAdminControllers(context *routes.RouterContext) {
 	bindAdminNamespaceListController(context)
 	bindAdminEditNamespaceController(context)
 	bindAdminRepositoryListController(context)
+	bindAdminRepositoryMaintenanceController(context)
+	bindAdminQuotaController(context)
 	bindAdminReceiptListController(context)
 	bindAdminSiteLockdownController(context)
 	bindAdminRegistrationRequestController(context)
+	bindAdminAuditLogController(context)
+	bindAdminReloadConfigController(context)
 }
@@ -6,7 +6,9 @@ import (
 	"strconv"
 
 	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/drain"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/mail"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	. "github.com/GitusCodeForge/Gitus/routes"
 	"github.com/GitusCodeForge/Gitus/templates"
 )
@@ -54,13 +56,13 @@ func bindAdminMailerSettingController(ctx *RouterContext) {
 					rc.ReportInternalError(fmt.Sprintf("Failed to create mailer: %s", err), w, r)
 					return
 				}
-				go func(){
+				drain.Go(func() {
 					err = mailer.SendPlainTextMail(r.Form.Get("test-email-target"), "Mailer Configuration Test", fmt.Sprintf(`
 This is a test email from %s.
 
 If you can see this message it means the mailer configuration can be used normally.
 `, rc.Config.DepotName))
-				}()
+				})
 				LogTemplateError(rc.LoadTemplate("admin/mailer-setting").Execute(w, &templates.AdminConfigTemplateModel{
 					Config: rc.Config,
 					LoginInfo: rc.LoginInfo,
@@ -71,6 +73,7 @@ If you can see this message it means the mailer configuration can be used normal
 			
 			rc.Config.LockForSync()
 			defer rc.Config.Unlock()
+			before := ConfigSnapshot(rc.Config)
 			rc.Config.Mailer.Type = r.Form.Get("type")
 			rc.Config.Mailer.SMTPServer = r.Form.Get("server")
 			i, err := strconv.ParseInt(r.Form.Get("port"), 10, 32)
@@ -86,6 +89,7 @@ If you can see this message it means the mailer configuration can be used normal
 				rc.ReportInternalError(fmt.Sprintf("Failed to save mailer config: %s", err), w, r)
 				return
 			}
+			rc.AuditConfigEdit(model.AUDIT_ADMIN_CONFIG, "mailer", before, w, r)
 			LogTemplateError(rc.LoadTemplate("admin/mailer-setting").Execute(w, &templates.AdminConfigTemplateModel{
 				Config: rc.Config,
 				LoginInfo: rc.LoginInfo,
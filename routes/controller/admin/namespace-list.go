@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/lfs"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	. "github.com/GitusCodeForge/Gitus/routes"
 	"github.com/GitusCodeForge/Gitus/templates"
@@ -126,6 +127,7 @@ func bindAdminNamespaceListController(ctx *RouterContext) {
 				rc.ReportInternalError(fmt.Sprintf("Failed to delete namespace by name: %s", err), w, r)
 				return
 			}
+			LogIfError(lfs.RemoveNamespace(rc.Config, nsn))
 			FoundAt(w, "/admin/namespace-list")
 		},
 	))
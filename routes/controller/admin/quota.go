@@ -0,0 +1,168 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/quota"
+	. "github.com/GitusCodeForge/Gitus/routes"
+	"github.com/GitusCodeForge/Gitus/templates"
+)
+
+// the sizes on the form are in MiB.
+const QUOTA_FORM_UNIT = 1024 * 1024
+
+// whether the user or the namespace exists.
+func quotaSubjectExists(rc *RouterContext, subjectType string, name string) (bool, error) {
+	var err error
+	switch subjectType {
+	case model.QUOTA_SUBJECT_USER:
+		if !model.ValidUserName(name) { return false, nil }
+		_, err = rc.DatabaseInterface.GetUserByName(name)
+	case model.QUOTA_SUBJECT_NAMESPACE:
+		if !model.ValidNamespaceName(name) { return false, nil }
+		_, err = rc.DatabaseInterface.GetNamespaceByName(name)
+	default:
+		return false, nil
+	}
+	if errors.Is(err, db.ErrEntityNotFound) { return false, nil }
+	if err != nil { return false, err }
+	return true, nil
+}
+
+func quotaSubjectEditPath(subjectType string, name string) string {
+	if subjectType == model.QUOTA_SUBJECT_USER { return fmt.Sprintf("/admin/user/%s/edit", name) }
+	return fmt.Sprintf("/admin/namespace/%s/edit", name)
+}
+
+func quotaAuditTarget(subjectType string, name string) string {
+	if subjectType == model.QUOTA_SUBJECT_USER { return AuditUserTarget(name) }
+	return AuditNamespaceTarget(name)
+}
+
+// blank means model.QUOTA_DEFAULT.
+func toQuotaFormValue(v int64, unit int64) string {
+	if v == model.QUOTA_DEFAULT { return "" }
+	if v % unit == 0 { return strconv.FormatInt(v / unit, 10) }
+	return strconv.FormatFloat(float64(v) / float64(unit), 'f', -1, 64)
+}
+
+func parseQuotaFormValue(s string, unit int64) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" { return model.QUOTA_DEFAULT, nil }
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 { return 0, errors.New("Limits must be empty or non-negative numbers.") }
+	return int64(f * float64(unit)), nil
+}
+
+// see docs/quota.org.
+// /admin/quota/{subjectType}/{name}
+func bindAdminQuotaController(ctx *RouterContext) {
+	http.HandleFunc("GET /admin/quota/{subjectType}/{name}", UseMiddleware(
+		[]Middleware{Logged, LoginRequired, AdminRequired, GlobalVisibility, ErrorGuard}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			subjectType := r.PathValue("subjectType")
+			name := r.PathValue("name")
+			ok, err := quotaSubjectExists(rc, subjectType, name)
+			if err != nil {
+				rc.ReportInternalError(err.Error(), w, r)
+				return
+			}
+			if !ok {
+				rc.ReportNotFound(name, subjectType, "Depot", w, r)
+				return
+			}
+			override, err := rc.DatabaseInterface.GetQuota(subjectType, name)
+			if err != nil && !errors.Is(err, db.ErrEntityNotFound) {
+				rc.ReportInternalError(fmt.Sprintf("Failed to retrieve quota: %s", err.Error()), w, r)
+				return
+			}
+			effective, err := quota.GetEffectiveQuota(rc.Config, rc.DatabaseInterface, subjectType, name)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to retrieve quota: %s", err.Error()), w, r)
+				return
+			}
+			usage, err := quota.GetUsage(rc.Config, rc.DatabaseInterface, subjectType, name)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to retrieve storage usage: %s", err.Error()), w, r)
+				return
+			}
+			form := &templates.AdminQuotaFormValue{}
+			if override != nil {
+				form.RepositorySize = toQuotaFormValue(override.RepositorySize, QUOTA_FORM_UNIT)
+				form.LFSSize = toQuotaFormValue(override.LFSSize, QUOTA_FORM_UNIT)
+				form.SnippetSize = toQuotaFormValue(override.SnippetSize, QUOTA_FORM_UNIT)
+				form.RepositoryCount = toQuotaFormValue(override.RepositoryCount, 1)
+			}
+			LogTemplateError(rc.LoadTemplate("admin/quota").Execute(w, &templates.AdminQuotaTemplateModel{
+				Config: rc.Config,
+				LoginInfo: rc.LoginInfo,
+				SubjectType: subjectType,
+				SubjectName: name,
+				EditPath: quotaSubjectEditPath(subjectType, name),
+				HasOverride: override != nil,
+				Form: form,
+				Quota: effective,
+				Usage: usage,
+			}))
+		},
+	))
+
+	http.HandleFunc("POST /admin/quota/{subjectType}/{name}", UseMiddleware(
+		[]Middleware{Logged, ValidPOSTRequestRequired,
+			LoginRequired, CSRFCheck, AdminRequired,
+			GlobalVisibility, ErrorGuard,
+		}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			subjectType := r.PathValue("subjectType")
+			name := r.PathValue("name")
+			ok, err := quotaSubjectExists(rc, subjectType, name)
+			if err != nil {
+				rc.ReportInternalError(err.Error(), w, r)
+				return
+			}
+			if !ok {
+				rc.ReportNotFound(name, subjectType, "Depot", w, r)
+				return
+			}
+			p := fmt.Sprintf("/admin/quota/%s/%s", subjectType, name)
+			old, err := rc.DatabaseInterface.GetQuota(subjectType, name)
+			if err != nil && !errors.Is(err, db.ErrEntityNotFound) {
+				rc.ReportInternalError(fmt.Sprintf("Failed to retrieve quota: %s", err.Error()), w, r)
+				return
+			}
+			var q *model.Quota
+			if r.Form.Get("action") == "remove" {
+				err = rc.DatabaseInterface.RemoveQuota(subjectType, name)
+			} else {
+				q = &model.Quota{}
+				for _, f := range []struct{ key string; unit int64; v *int64 }{
+					{ "repository-size", QUOTA_FORM_UNIT, &q.RepositorySize },
+					{ "lfs-size", QUOTA_FORM_UNIT, &q.LFSSize },
+					{ "snippet-size", QUOTA_FORM_UNIT, &q.SnippetSize },
+					{ "repository-count", 1, &q.RepositoryCount },
+				} {
+					*f.v, err = parseQuotaFormValue(r.Form.Get(f.key), f.unit)
+					if err != nil {
+						rc.ReportRedirect(p, 3, "Invalid Quota", err.Error(), w, r)
+						return
+					}
+				}
+				err = rc.DatabaseInterface.SetQuota(subjectType, name, q)
+			}
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to update quota: %s", err.Error()), w, r)
+				return
+			}
+			rc.Audit(model.AUDIT_ADMIN_QUOTA, quotaAuditTarget(subjectType, name), map[string]any{
+				"quota": []any{old, q},
+			}, w, r)
+			rc.ReportRedirect(p, 3, "Quota Updated", "The quota has been updated.", w, r)
+		},
+	))
+}
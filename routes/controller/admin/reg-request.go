@@ -90,6 +90,7 @@ func bindAdminRegistrationRequestController(ctx *RouterContext) {
 				rc.ReportInternalError(fmt.Sprintf("Failed to approve registration request: %s.", err), w, r)
 				return
 			}
+			rc.Audit(model.AUDIT_USER_STATUS, AuditUserTarget(regreq.Username), map[string]any{"registration": "approved"}, w, r)
 			if rc.Config.EmailConfirmationRequired {
 				email := regreq.Email
 				command := make([]string, 3)
@@ -0,0 +1,58 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	. "github.com/GitusCodeForge/Gitus/routes"
+	"github.com/GitusCodeForge/Gitus/templates"
+)
+
+// see docs/config-reload.org.
+func bindAdminReloadConfigController(ctx *RouterContext) {
+	http.HandleFunc("GET /admin/reload-config", UseMiddleware(
+		[]Middleware{Logged, LoginRequired, AdminRequired,
+			GlobalVisibility, ErrorGuard,
+		}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			m := &templates.AdminReloadConfigTemplateModel{
+				Config: rc.Config,
+				LoginInfo: rc.LoginInfo,
+			}
+			m.PendingReloadable, m.PendingRestartRequired = rc.ConfigReloader.PendingSetting()
+			if res := rc.ConfigReloader.LastResult(); res != nil {
+				m.LastReloadTime = res.Time.Format(time.RFC3339)
+				if res.Error != nil { m.LastReloadError = res.Error.Error() }
+				m.LastApplied = res.Applied
+				m.LastRestartRequired = res.RestartRequired
+			}
+			LogTemplateError(rc.LoadTemplate("admin/reload-config").Execute(w, m))
+		},
+	))
+
+	http.HandleFunc("POST /admin/reload-config", UseMiddleware(
+		[]Middleware{Logged, ValidPOSTRequestRequired,
+			LoginRequired, CSRFCheck, AdminRequired,
+			GlobalVisibility, ErrorGuard,
+		}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			res := rc.ConfigReloader.Reload()
+			if res.Error != nil {
+				rc.ReportRedirect("/admin/reload-config", 0, "Reload Failed", fmt.Sprintf("Failed to reload config: %s. Nothing has been changed.", res.Error.Error()), w, r)
+				return
+			}
+			if res.Diff != nil || len(res.Applied) > 0 {
+				diff := map[string]any{ "changed": res.Diff, "applied": res.Applied }
+				rc.Audit(model.AUDIT_ADMIN_CONFIG_RELOAD, AuditConfigTarget("file"), diff, w, r)
+			}
+			msg := "Configuration reloaded."
+			if len(res.RestartRequired) > 0 {
+				msg = fmt.Sprintf("Configuration reloaded. These settings require a restart to take effect: %s.", strings.Join(res.RestartRequired, ", "))
+			}
+			rc.ReportRedirect("/admin/reload-config", 3, "Configuration Reloaded", msg, w, r)
+		},
+	))
+}
@@ -11,6 +11,22 @@ import (
 	"github.com/GitusCodeForge/Gitus/templates"
 )
 
+// the size of each repository on the page & the usage of the site.
+// see docs/quota.org.
+func loadRepositorySize(rc *RouterContext, repoList []*model.Repository) (map[string]int64, *model.QuotaUsage, error) {
+	res := make(map[string]int64, len(repoList))
+	for _, repo := range repoList {
+		size, err := rc.DatabaseInterface.GetRepositorySize(repo.Namespace, repo.Name)
+		if err != nil { return nil, nil, err }
+		lfsUsage, err := rc.DatabaseInterface.GetLFSStorageUsage(repo.Namespace, repo.Name)
+		if err != nil { return nil, nil, err }
+		res[repo.FullName()] = size + lfsUsage.TotalSize
+	}
+	siteUsage, err := rc.DatabaseInterface.GetQuotaUsage(model.QUOTA_SUBJECT_SITE, "")
+	if err != nil { return nil, nil, err }
+	return res, siteUsage, nil
+}
+
 // /admin/repo-list?p={pagenum}&s={pagesize}&q={query}
 func bindAdminRepositoryListController(ctx *RouterContext) {
 	http.HandleFunc("GET /admin/repo-list", UseMiddleware(
@@ -46,11 +62,19 @@ func bindAdminRepositoryListController(ctx *RouterContext) {
 				}))
 				return
 			}
+			maintenanceStatus, err := loadMaintenanceStatus(rc)
+			errMsg := ""
+			if err != nil { errMsg = fmt.Sprintf("Failed to load maintenance status: %s", err.Error()) }
+			repoSize, siteUsage, err := loadRepositorySize(rc, repoList)
+			if err != nil { errMsg = fmt.Sprintf("Failed to load storage usage: %s", err.Error()) }
 			LogTemplateError(rc.LoadTemplate("admin/repo-list").Execute(w, &templates.AdminRepositoryListTemplateModel{
 				Config: rc.Config,
 				LoginInfo: rc.LoginInfo,
-				ErrorMsg: "",
+				ErrorMsg: errMsg,
 				RepositoryList: repoList,
+				MaintenanceStatus: maintenanceStatus,
+				RepositorySize: repoSize,
+				SiteUsage: siteUsage,
 				Query: q,
 				PageInfo: &templates.PageInfoModel{
 					PageNum: pageNum,
@@ -0,0 +1,101 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	. "github.com/GitusCodeForge/Gitus/routes"
+	"github.com/GitusCodeForge/Gitus/templates"
+)
+
+// summarizes the latest runs of each repository for the repository
+// list.
+func loadMaintenanceStatus(rc *RouterContext) (map[string]*templates.AdminRepositoryMaintenanceStatus, error) {
+	l, err := rc.DatabaseInterface.GetLatestMaintenanceRunList()
+	if err != nil { return nil, err }
+	res := make(map[string]*templates.AdminRepositoryMaintenanceStatus)
+	for _, run := range l {
+		rfn := run.RepoName
+		if len(run.RepoNamespace) > 0 { rfn = run.RepoNamespace + ":" + run.RepoName }
+		s, ok := res[rfn]
+		if !ok {
+			s = &templates.AdminRepositoryMaintenanceStatus{}
+			res[rfn] = s
+		}
+		if run.StartTime > s.LastRunTime { s.LastRunTime = run.StartTime }
+		if !run.Success { s.Failed = true }
+	}
+	return res, nil
+}
+
+// see docs/repo-maintenance.org.
+// /admin/repo-list/{repoName}/maintenance
+func bindAdminRepositoryMaintenanceController(ctx *RouterContext) {
+	http.HandleFunc("GET /admin/repo-list/{repoName}/maintenance", UseMiddleware(
+		[]Middleware{Logged, LoginRequired, AdminRequired,
+			GlobalVisibility, ErrorGuard,
+			ValidRepositoryNameRequired("repoName"),
+		}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			if rc.Maintenance == nil {
+				rc.ReportNormalError("Repository maintenance is only available in forge mode.", w, r)
+				return
+			}
+			rfn := r.PathValue("repoName")
+			nsName, repoName, _, repo, err := rc.ResolveRepositoryFullName(rfn)
+			if err != nil {
+				rc.ReportRedirect("/admin/repo-list", 0, "Error",
+					fmt.Sprintf("Failed to fetch repository: %s", err.Error()),
+					w, r,
+				)
+				return
+			}
+			runList, err := rc.DatabaseInterface.GetMaintenanceRunList(nsName, repoName, 0, int64(rc.Config.ProperMaintenanceHistorySize()))
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to load maintenance history: %s", err.Error()), w, r)
+				return
+			}
+			LogTemplateError(rc.LoadTemplate("admin/repo-maintenance").Execute(w, &templates.AdminRepositoryMaintenanceTemplateModel{
+				Config: rc.Config,
+				LoginInfo: rc.LoginInfo,
+				Repository: repo,
+				RepoFullName: rfn,
+				Running: rc.Maintenance.IsRunning(nsName, repoName),
+				RunList: runList,
+			}))
+		},
+	))
+
+	http.HandleFunc("POST /admin/repo-list/{repoName}/maintenance", UseMiddleware(
+		[]Middleware{Logged, ValidPOSTRequestRequired,
+			LoginRequired, CSRFCheck, AdminRequired,
+			GlobalVisibility, ErrorGuard,
+			ValidRepositoryNameRequired("repoName"),
+		}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			if rc.Maintenance == nil {
+				rc.ReportNormalError("Repository maintenance is only available in forge mode.", w, r)
+				return
+			}
+			rfn := r.PathValue("repoName")
+			nsName, repoName, _, repo, err := rc.ResolveRepositoryFullName(rfn)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to fetch repository: %s", err.Error()), w, r)
+				return
+			}
+			if repo.Type != model.REPO_TYPE_GIT {
+				rc.ReportNormalError("Maintenance is only supported on git repositories.", w, r)
+				return
+			}
+			p := fmt.Sprintf("/admin/repo-list/%s/maintenance", rfn)
+			err = rc.Maintenance.RunNow(nsName, repoName, rc.LoginInfo.UserName)
+			if err != nil {
+				rc.ReportRedirect(p, 0, "Error", err.Error(), w, r)
+				return
+			}
+			rc.Audit(model.AUDIT_REPOSITORY_MAINTENANCE, AuditRepositoryTarget(nsName, repoName), nil, w, r)
+			rc.ReportRedirect(p, 3, "Maintenance Scheduled", "All maintenance tasks will be run on this repository shortly. Reload the page later to see the result.", w, r)
+		},
+	))
+}
@@ -8,6 +8,7 @@ import (
 	"slices"
 	"strconv"
 
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	. "github.com/GitusCodeForge/Gitus/routes"
 )
 
@@ -52,6 +53,7 @@ func bindAdminRRDocDeleteController(ctx *RouterContext) {
 				rc.ReportNotFound("Required", "document", "instance", w, r)
 				return
 			}
+			before := ConfigSnapshot(rc.Config)
 			p := rc.Config.ReadingRequiredDocument[int(n)-1]
 			tp := path.Join(rc.Config.StaticAssetDirectory, "_rrdoc", p.Path)
 			err = os.Remove(tp)
@@ -62,6 +64,7 @@ func bindAdminRRDocDeleteController(ctx *RouterContext) {
 			rc.Config.ReadingRequiredDocument = slices.Delete(rc.Config.ReadingRequiredDocument, int(n)-1, int(n))
 			// we should probably turn this into a transaction...
 			rc.Config.Sync()
+			rc.AuditConfigEdit(model.AUDIT_ADMIN_CONFIG, "readingRequiredDocument", before, w, r)
 			rc.ReportRedirect("/admin/rrdoc", 3, "Deleted", "The document you've specified has been deleted.", w, r)
 		},
 	))
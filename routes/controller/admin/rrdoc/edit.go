@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	. "github.com/GitusCodeForge/Gitus/routes"
 	"github.com/GitusCodeForge/Gitus/templates"
 )
@@ -72,6 +73,7 @@ func bindAdminRRDocEditController(ctx *RouterContext) {
 				rc.ReportNotFound("Required", "document", "instance", w, r)
 				return
 			}
+			before := ConfigSnapshot(rc.Config)
 			title := r.Form.Get("title")
 			p := strings.TrimSpace(r.Form.Get("path"))
 			content := r.Form.Get("content")
@@ -95,6 +97,7 @@ func bindAdminRRDocEditController(ctx *RouterContext) {
 			}
 			// we should probably turn this into a transaction...
 			rc.Config.Sync()
+			rc.AuditConfigEdit(model.AUDIT_ADMIN_CONFIG, "readingRequiredDocument", before, w, r)
 			rc.ReportRedirect(fmt.Sprintf("/admin/rrdoc/%d/edit", n), 3, "Updated", "The document you've specified has been updated.", w, r)
 		},
 	))
@@ -7,6 +7,7 @@ import (
 	"path"
 	"strings"
 
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	. "github.com/GitusCodeForge/Gitus/routes"
 	"github.com/GitusCodeForge/Gitus/templates"
 )
@@ -38,6 +39,7 @@ func bindAdminRRDocNewController(ctx *RouterContext) {
 			GlobalVisibility, ErrorGuard,
 		}, ctx,
 		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			before := ConfigSnapshot(rc.Config)
 			title := r.Form.Get("title")
 			p := strings.TrimSpace(r.Form.Get("path"))
 			content := r.Form.Get("content")
@@ -63,6 +65,7 @@ func bindAdminRRDocNewController(ctx *RouterContext) {
 			}
 			// we should probably turn this into a transaction...
 			rc.Config.Sync()
+			rc.AuditConfigEdit(model.AUDIT_ADMIN_CONFIG, "readingRequiredDocument", before, w, r)
 			rc.ReportRedirect("/admin/rrdoc/new", 3, "Updated", "The document you've specified has been updated.", w, r)
 		},
 	))
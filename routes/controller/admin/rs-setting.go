@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	. "github.com/GitusCodeForge/Gitus/routes"
 	"github.com/GitusCodeForge/Gitus/templates"
 )
@@ -30,6 +31,7 @@ func bindAdminReceiptSystemSettingController(ctx *RouterContext) {
 		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
 			rc.Config.LockForSync()
 			defer rc.Config.Unlock()
+			before := ConfigSnapshot(rc.Config)
 			rc.Config.ReceiptSystem.Type = r.Form.Get("type")
 			rc.Config.ReceiptSystem.Path = r.Form.Get("path")
 			rc.Config.ReceiptSystem.URL = r.Form.Get("url")
@@ -41,6 +43,7 @@ func bindAdminReceiptSystemSettingController(ctx *RouterContext) {
 				rc.ReportRedirect("/admin/rs-setting", 0, "Internal Error", fmt.Sprintf("Error while saving config: %s. Please contact site owner for this...", err.Error()), w, r)
 				return
 			}
+			rc.AuditConfigEdit(model.AUDIT_ADMIN_CONFIG, "receiptSystem", before, w, r)
 			rc.ReportRedirect("/admin/rs-setting", 3, "Updated", "Configuration is updated.", w, r)
 
 		},
@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	. "github.com/GitusCodeForge/Gitus/routes"
 	"github.com/GitusCodeForge/Gitus/templates"
 )
@@ -32,6 +33,7 @@ func bindAdminSessionSettingController(ctx *RouterContext) {
 		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
 			rc.Config.LockForSync()
 			defer rc.Config.Unlock()
+			before := ConfigSnapshot(rc.Config)
 			rc.Config.Session.Type = r.Form.Get("type")
 			rc.Config.Session.Path = r.Form.Get("path")
 			rc.Config.Session.TablePrefix = r.Form.Get("table-prefix")
@@ -51,6 +53,7 @@ func bindAdminSessionSettingController(ctx *RouterContext) {
 				rc.ReportRedirect("/admin/session-setting", 0, "Internal Error", fmt.Sprintf("Error while saving config: %s. Please contact site owner for this...", err.Error()), w, r)
 				return
 			}
+			rc.AuditConfigEdit(model.AUDIT_ADMIN_CONFIG, "session", before, w, r)
 			rc.ReportRedirect("/admin/session-setting", 3, "Updated", "Configuration updated.", w, r)
 		},
 	))
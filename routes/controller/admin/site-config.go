@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	. "github.com/GitusCodeForge/Gitus/routes"
 	"github.com/GitusCodeForge/Gitus/templates"
 )
@@ -32,6 +33,7 @@ func bindAdminSiteConfigController(ctx *RouterContext) {
 		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
 			rc.Config.LockForSync()
 			defer rc.Config.Unlock()
+			before := ConfigSnapshot(rc.Config)
 			switch r.Form.Get("section") {
 			case "web":
 				rc.Config.HttpHostName = r.Form.Get("http-host-name")
@@ -57,6 +59,7 @@ func bindAdminSiteConfigController(ctx *RouterContext) {
 					}))
 					return
 				}
+				rc.AuditConfigEdit(model.AUDIT_ADMIN_CONFIG, r.Form.Get("section"), before, w, r)
 				rc.ReportRedirect("/admin/site-config", 3, "Updated", "Your specifie config has been updated.", w, r)
 			case "basic":
 				rc.Config.DepotName = r.Form.Get("depot-name")
@@ -86,6 +89,7 @@ func bindAdminSiteConfigController(ctx *RouterContext) {
 					}))
 					return
 				}
+				rc.AuditConfigEdit(model.AUDIT_ADMIN_CONFIG, r.Form.Get("section"), before, w, r)
 				rc.ReportRedirect("/admin/site-config", 3, "Updated", "Your specifie config has been updated.", w, r)
 			case "git":
 				rc.Config.GitRoot = r.Form.Get("root")
@@ -102,6 +106,7 @@ func bindAdminSiteConfigController(ctx *RouterContext) {
 					}))
 					return
 				}
+				rc.AuditConfigEdit(model.AUDIT_ADMIN_CONFIG, r.Form.Get("section"), before, w, r)
 				rc.ReportRedirect("/admin/site-config", 3, "Updated", "Your specifie config has been updated.", w, r)
 			case "theme-config":
 				rc.Config.Theme.ForegroundColor = strings.TrimSpace(r.Form.Get("foreground-color"))
@@ -115,6 +120,7 @@ func bindAdminSiteConfigController(ctx *RouterContext) {
 					}))
 					return
 				}
+				rc.AuditConfigEdit(model.AUDIT_ADMIN_CONFIG, r.Form.Get("section"), before, w, r)
 				rc.ReportRedirect("/admin/site-config", 3, "Updated", "Your specifie config has been updated.", w, r)
 				
 			case "front-page":
@@ -131,6 +137,7 @@ func bindAdminSiteConfigController(ctx *RouterContext) {
 					}))
 					return
 				}
+				rc.AuditConfigEdit(model.AUDIT_ADMIN_CONFIG, r.Form.Get("section"), before, w, r)
 				rc.ReportRedirect("/admin/site-config", 3, "Updated", "Your specifie config has been updated.", w, r)
 			}
 		},
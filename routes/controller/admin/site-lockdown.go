@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	. "github.com/GitusCodeForge/Gitus/routes"
 	"github.com/GitusCodeForge/Gitus/templates"
 )
@@ -37,6 +38,7 @@ func bindAdminSiteLockdownController(ctx *RouterContext) {
 		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
 			rc.Config.LockForSync()
 			defer rc.Config.Unlock()
+			before := ConfigSnapshot(rc.Config)
 			t := strings.TrimSpace(r.Form.Get("type"))
 			switch t {
 			case "public":
@@ -61,6 +63,7 @@ func bindAdminSiteLockdownController(ctx *RouterContext) {
 				rc.ReportRedirect("/admin/site-lockdown", 0, "Internal Error", fmt.Sprintf("Failed to save config due to error: %s", err.Error()), w, r)
 				return
 			}
+			rc.AuditConfigEdit(model.AUDIT_SITE_LOCKDOWN, "lockdown", before, w, r)
 			rc.ReportRedirect("/admin/site-lockdown", 3, "Configuration Saved", "Configuration saved.", w, r)
 		},
 	))
@@ -13,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/GitusCodeForge/Gitus/pkg/gitlib"
+	gitlog "github.com/GitusCodeForge/Gitus/pkg/gitus/log"
 	"github.com/GitusCodeForge/Gitus/routes"
 	"github.com/alecthomas/chroma/v2"
 	chromaHtml "github.com/alecthomas/chroma/v2/formatters/html"
@@ -90,6 +91,12 @@ func responseWithTreeZip(repo *gitlib.LocalGitRepository, obj gitlib.GitObject,
 // language type manually; it somehow can reach to the conclusion that
 // C, Nim and GAS Assembly are all GDScript 3. i'm tempted to make
 // my own syntax coloring engine here.
+// the handle of `gr` whose git commands are stopped w/ the request &
+// carry its request id (in the records & the hooks they run).
+func requestGitRepository(r *http.Request, gr *gitlib.LocalGitRepository) *gitlib.LocalGitRepository {
+	return gr.WithContext(r.Context(), gitlog.RequestIDEnvOf(r.Context())...)
+}
+
 func codeTypeDiscern(s string) string {
 	switch s {
 	case ".as": return "ActionScript"
@@ -257,3 +264,29 @@ func getQueryPath(s string) (string, error) {
 		url.QueryEscape(u.Query().Encode())), nil
 }
 
+
+// blame options are carried in the query string of a blame request:
+// `move` and `copy` turns on `-M` and `-C` respectively, and
+// `no-ignore-revs` disables the `.git-blame-ignore-revs` file in the
+// repository (which is used by default if there is one).
+func resolveBlameOption(rr *gitlib.LocalGitRepository, cobj *gitlib.CommitObject, r *http.Request) (*gitlib.BlameOption, bool, error) {
+	q := r.URL.Query()
+	opt := &gitlib.BlameOption{
+		DetectMove: q.Has("move"),
+		DetectCopy: q.Has("copy"),
+	}
+	ignoreRevs, err := rr.ReadBlameIgnoreRevs(cobj)
+	if err != nil { return nil, false, err }
+	hasIgnoreRevs := len(strings.TrimSpace(ignoreRevs)) > 0
+	if !q.Has("no-ignore-revs") { opt.IgnoreRevs = ignoreRevs }
+	return opt, hasIgnoreRevs, nil
+}
+
+// the query string that reproduces `opt`, w/o the leading `?`.
+func blameOptionToQuery(opt *gitlib.BlameOption, hasIgnoreRevs bool) string {
+	res := []string{"blame"}
+	if opt.DetectMove { res = append(res, "move") }
+	if opt.DetectCopy { res = append(res, "copy") }
+	if hasIgnoreRevs && len(opt.IgnoreRevs) <= 0 { res = append(res, "no-ignore-revs") }
+	return strings.Join(res, "&")
+}
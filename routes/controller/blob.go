@@ -79,6 +79,8 @@ func bindBlobController(ctx *RouterContext) {
 				w.Write(bobj.Data)
 				return
 			}
+			lfsPointer := resolveLFSPointer(rc, repo, bobj.Data, "")
+			if lfsPointer != nil { templateType = "file-text" }
 			str := string(bobj.Data)
 			coloredStr, err := colorSyntax("", str)
 			if err == nil { str = coloredStr }
@@ -90,6 +92,7 @@ func bindBlobController(ctx *RouterContext) {
 					FileLineCount: strings.Count(str, "\n"),
 					FileContent: str,
 				},
+				LFSPointer: lfsPointer,
 				Repository: repo,
 				PermaLink: permaLink,
 				TreePath: nil,
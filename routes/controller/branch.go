@@ -1,11 +1,9 @@
 package controller
 
 import (
-	"bytes"
 	"fmt"
 	"mime"
 	"net/http"
-	"os/exec"
 	"path"
 	"strings"
 
@@ -69,7 +67,7 @@ func bindBranchController(ctx *RouterContext) {
 			
 			treePath := r.PathValue("treePath")
 
-			rr := repo.Repository.(*gitlib.LocalGitRepository)
+			rr := requestGitRepository(r, repo.Repository.(*gitlib.LocalGitRepository))
 			err = rr.SyncAllBranchList()
 			if err != nil {
 				rc.ReportInternalError(
@@ -221,7 +219,7 @@ func bindBranchController(ctx *RouterContext) {
 				upstreamPath := path.Join(rc.Config.GitRoot, repo.ForkOriginNamespace, repo.ForkOriginName)
 				upstream, _ = model.CreateLocalRepository(model.REPO_TYPE_GIT, repo.ForkOriginNamespace, repo.ForkOriginName, upstreamPath)
 				remoteName := fmt.Sprintf("%s/%s", repo.Namespace, repo.Name)
-				compareInfo, err = upstream.(*gitlib.LocalGitRepository).CompareBranchWithRemote(branchName, remoteName)
+				compareInfo, err = requestGitRepository(r, upstream.(*gitlib.LocalGitRepository)).CompareBranchWithRemote(branchName, remoteName)
 			}
 			
 			isFastForwardRequest := r.URL.Query().Has("ff")
@@ -261,7 +259,12 @@ func bindBranchController(ctx *RouterContext) {
 						rc.ReportInternalError(err.Error(), w, r)
 						return
 					}
-					blame, err := rr.Blame(cobj, treePath)
+					blameOption, hasIgnoreRevs, err := resolveBlameOption(rr, cobj, r)
+					if err != nil {
+						rc.ReportInternalError(fmt.Sprintf("Failed to read blame option: %s.", err), w, r)
+						return
+					}
+					blame, err := rr.BlameWithOption(cobj, treePath, blameOption)
 					if err != nil {
 						rc.ReportInternalError(fmt.Sprintf("Failed to run git-blame: %s.", err), w, r)
 						return
@@ -279,6 +282,9 @@ func bindBranchController(ctx *RouterContext) {
 						TreePath: treePathModelValue,
 						PermaLink: permaLink,
 						Blame: blame,
+						BlameOption: blameOption,
+						HasIgnoreRevs: hasIgnoreRevs,
+						BlameQuery: blameOptionToQuery(blameOption, hasIgnoreRevs),
 						CommitInfo: commitInfo,
 						TagInfo: nil,
 						LoginInfo: rc.LoginInfo,
@@ -368,8 +374,10 @@ func bindBranchController(ctx *RouterContext) {
 					w.Write(bobj.Data)
 					return
 				}
-				str := string(bobj.Data)
 				filename := path.Base(treePath)
+				lfsPointer := resolveLFSPointer(rc, repo, bobj.Data, filename)
+				if lfsPointer != nil { templateType = "file-text" }
+				str := string(bobj.Data)
 				coloredStr, err := colorSyntax(filename, str)
 				if err == nil { str = coloredStr }
 				LogTemplateError(rc.LoadTemplate(templateType).Execute(w, templates.FileTemplateModel{
@@ -379,6 +387,7 @@ func bindBranchController(ctx *RouterContext) {
 						FileLineCount: strings.Count(str, "\n"),
 						FileContent: str,
 					},
+					LFSPointer: lfsPointer,
 					PermaLink: permaLink,
 					TreeFileList: &templates.TreeFileListTemplateModel{
 						ShouldHaveParentLink: len(treePath) > 0,
@@ -388,7 +397,7 @@ func bindBranchController(ctx *RouterContext) {
 						FileList: dirObj.(*gitlib.TreeObject).ObjectList,
 					},
 					ComparisonInfo: compareInfo,
-					AllowBlame: !strings.HasPrefix(mime, "image/"),
+					AllowBlame: !strings.HasPrefix(mime, "image/") && lfsPointer == nil,
 					TreePath: treePathModelValue,
 					CommitInfo: commitInfo,
 					TagInfo: nil,
@@ -447,13 +456,15 @@ func bindBranchController(ctx *RouterContext) {
 			}
 			branchName := r.PathValue("branchName")
 			commitMessage := r.Form.Get("commit-message")
+			rr := requestGitRepository(r, repo.Repository.(*gitlib.LocalGitRepository))
 			var commitId string
 			var treePath string
 			switch action {
 			case "edit":
 				treePath = r.PathValue("treePath")
 				content := r.Form.Get("content")
-				commitId, err = model.AddFileToRepoString(repo.Repository, branchName, treePath, rc.LoginInfo.UserFullName, rc.LoginInfo.UserEmail, rc.LoginInfo.UserFullName, rc.LoginInfo.UserEmail, commitMessage, content)
+				if !rc.CheckQuota(repo.Namespace, repo.Owner, &model.QuotaUsage{ RepositorySize: int64(len(content)) }, w, r) { return }
+				commitId, err = model.AddFileToRepoString(rr, branchName, treePath, rc.LoginInfo.UserFullName, rc.LoginInfo.UserEmail, rc.LoginInfo.UserFullName, rc.LoginInfo.UserEmail, commitMessage, content)
 				if err != nil {
 					rc.ReportInternalError(fmt.Sprintf("Failed while adding file to repo: %s", err), w, r)
 					return
@@ -462,14 +473,20 @@ func bindBranchController(ctx *RouterContext) {
 				treePath = strings.TrimSpace(r.Form.Get("new-file-path"))
 				if len(r.Form.Get("use-upload-file")) > 0 {
 					f, e, err := r.FormFile("file-upload")
-					commitId, err = model.AddFileToRepoReader(repo.Repository, branchName, treePath, rc.LoginInfo.UserFullName, rc.LoginInfo.UserEmail, rc.LoginInfo.UserFullName, rc.LoginInfo.UserEmail, commitMessage, f, e.Size)
+					if err != nil {
+						rc.ReportNormalError(fmt.Sprintf("Failed to read uploaded file: %s", err), w, r)
+						return
+					}
+					if !rc.CheckQuota(repo.Namespace, repo.Owner, &model.QuotaUsage{ RepositorySize: e.Size }, w, r) { return }
+					commitId, err = model.AddFileToRepoReader(rr, branchName, treePath, rc.LoginInfo.UserFullName, rc.LoginInfo.UserEmail, rc.LoginInfo.UserFullName, rc.LoginInfo.UserEmail, commitMessage, f, e.Size)
 					if err != nil {
 						rc.ReportInternalError(fmt.Sprintf("Failed while adding file to repo: %s", err), w, r)
 						return
 					}
 				} else {
 					content := r.Form.Get("content")
-					commitId, err = model.AddFileToRepoString(repo.Repository, branchName, treePath, rc.LoginInfo.UserFullName, rc.LoginInfo.UserEmail, rc.LoginInfo.UserFullName, rc.LoginInfo.UserEmail, commitMessage, content)
+					if !rc.CheckQuota(repo.Namespace, repo.Owner, &model.QuotaUsage{ RepositorySize: int64(len(content)) }, w, r) { return }
+					commitId, err = model.AddFileToRepoString(rr, branchName, treePath, rc.LoginInfo.UserFullName, rc.LoginInfo.UserEmail, rc.LoginInfo.UserFullName, rc.LoginInfo.UserEmail, commitMessage, content)
 					if err != nil {
 						rc.ReportInternalError(fmt.Sprintf("Failed while adding file to repo: %s", err), w, r)
 						return
@@ -478,23 +495,25 @@ func bindBranchController(ctx *RouterContext) {
 			case "replace":
 				treePath = r.PathValue("treePath")
 				f, e, err := r.FormFile("file-upload")
-				commitId, err = model.AddFileToRepoReader(repo.Repository, branchName, treePath, rc.LoginInfo.UserFullName, rc.LoginInfo.UserEmail, rc.LoginInfo.UserFullName, rc.LoginInfo.UserEmail, commitMessage, f, e.Size)
+				if err != nil {
+					rc.ReportNormalError(fmt.Sprintf("Failed to read uploaded file: %s", err), w, r)
+					return
+				}
+				if !rc.CheckQuota(repo.Namespace, repo.Owner, &model.QuotaUsage{ RepositorySize: e.Size }, w, r) { return }
+				commitId, err = model.AddFileToRepoReader(rr, branchName, treePath, rc.LoginInfo.UserFullName, rc.LoginInfo.UserEmail, rc.LoginInfo.UserFullName, rc.LoginInfo.UserEmail, commitMessage, f, e.Size)
 				if err != nil {
 					rc.ReportInternalError(fmt.Sprintf("Failed while adding file to repo: %s", err), w, r)
 					return
 				}
 			}
 			commitId = strings.TrimSpace(commitId)
-			cmd2 := exec.Command("git", "update-ref", fmt.Sprintf("refs/heads/%s", branchName), commitId)
-			cmd2.Dir = repo.LocalPath
-			stderrBuf := new(bytes.Buffer)
-			stderrBuf.Reset()
-			cmd2.Stderr = stderrBuf
-			err = cmd2.Run()
+			err = repo.Repository.(*gitlib.LocalGitRepository).UpdateRef(branchName, commitId)
 			if err != nil {
-				rc.ReportInternalError(fmt.Sprintf("Failed to update ref: %s; %s", err.Error(), stderrBuf.String()), w, r)
+				rc.ReportInternalError(fmt.Sprintf("Failed to update ref: %s", err.Error()), w, r)
 				return
 			}
+			rc.RefreshRepositorySize(repo.Namespace, repo.Name, r)
+			rc.SyncPullRequestProviderBranch(repo.Namespace, repo.Name, r)
 			rc.ReportRedirect(fmt.Sprintf("/repo/%s/branch/%s/%s", rfn, branchName, r.PathValue("treePath")), 5, "Updated", "Your edit has been saved to the repository.", w, r)
 		},
 	))
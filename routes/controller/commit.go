@@ -69,7 +69,7 @@ func bindCommitController(ctx *RouterContext) {
 
 			repoHeaderInfo := GenerateRepoHeader("commit", commitId)
 
-			rr := repo.Repository.(*gitlib.LocalGitRepository)
+			rr := requestGitRepository(r, repo.Repository.(*gitlib.LocalGitRepository))
 			gobj, err := rr.ReadObject(commitId)
 			if err != nil {
 				rc.ReportObjectReadFailure(commitId, err.Error(), w, r)
@@ -155,7 +155,12 @@ func bindCommitController(ctx *RouterContext) {
 						rc.ReportInternalError(err.Error(), w, r)
 						return
 					}
-					blame, err := rr.Blame(cobj, treePath)
+					blameOption, hasIgnoreRevs, err := resolveBlameOption(rr, cobj, r)
+					if err != nil {
+						rc.ReportInternalError(fmt.Sprintf("Failed to read blame option: %s.", err), w, r)
+						return
+					}
+					blame, err := rr.BlameWithOption(cobj, treePath, blameOption)
 					if err != nil {
 						rc.ReportInternalError(fmt.Sprintf("Failed to run git-blame: %s.", err), w, r)
 						return
@@ -173,6 +178,9 @@ func bindCommitController(ctx *RouterContext) {
 						TreePath: treePathModelValue,
 						PermaLink: permaLink,
 						Blame: blame,
+						BlameOption: blameOption,
+						HasIgnoreRevs: hasIgnoreRevs,
+						BlameQuery: blameOptionToQuery(blameOption, hasIgnoreRevs),
 						CommitInfo: commitInfo,
 						TagInfo: nil,
 						LoginInfo: rc.LoginInfo,
@@ -253,8 +261,10 @@ func bindCommitController(ctx *RouterContext) {
 					w.Write(bobj.Data)
 					return
 				}
-				str := string(bobj.Data)
 				filename := path.Base(treePath)
+				lfsPointer := resolveLFSPointer(rc, repo, bobj.Data, filename)
+				if lfsPointer != nil { templateType = "file-text" }
+				str := string(bobj.Data)
 				coloredStr, err := colorSyntax(filename, str)
 				if err == nil { str = coloredStr }
 				LogTemplateError(rc.LoadTemplate(templateType).Execute(w, templates.FileTemplateModel{
@@ -264,6 +274,7 @@ func bindCommitController(ctx *RouterContext) {
 						FileLineCount: strings.Count(str, "\n"),
 						FileContent: str,
 					},
+					LFSPointer: lfsPointer,
 					PermaLink: permaLink,
 					TreeFileList: &templates.TreeFileListTemplateModel{
 						ShouldHaveParentLink: len(treePath) > 0,
@@ -272,7 +283,7 @@ func bindCommitController(ctx *RouterContext) {
 						TreePath: dirPath,
 						FileList: dirObj.(*gitlib.TreeObject).ObjectList,
 					},
-					AllowBlame: !strings.HasPrefix(mime, "image/"),
+					AllowBlame: !strings.HasPrefix(mime, "image/") && lfsPointer == nil,
 					TreePath: treePathModelValue,
 					CommitInfo: commitInfo,
 					TagInfo: nil,
@@ -106,6 +106,7 @@ func bindConfirmRegistrationController(ctx *RouterContext) {
 				)
 				return
 			}
+			ctx.AuditAs(username, model.AUDIT_USER_STATUS, AuditUserTarget(username), map[string]any{"status": []any{model.NORMAL_USER_CONFIRM_NEEDED, status}}, w, r)
 			if ctx.Config.UseNamespace {
 				_, err = ctx.DatabaseInterface.RegisterNamespace(username, username)
 				if err != nil {
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/health"
+	. "github.com/GitusCodeForge/Gitus/routes"
+)
+
+// the report of /readyz is reused for a few seconds so that the
+// checks (which dial the smtp server & create files) aren't run on
+// every request.
+const READINESS_CACHE_DURATION = 5 * time.Second
+
+var readinessReport *health.Report
+var readinessReportTime time.Time
+var readinessLock sync.Mutex
+
+func getReadinessReport(rc *RouterContext) *health.Report {
+	readinessLock.Lock()
+	defer readinessLock.Unlock()
+	if readinessReport != nil && time.Since(readinessReportTime) < READINESS_CACHE_DURATION {
+		return readinessReport
+	}
+	readinessReport = health.Run(&health.Target{
+		Config: rc.Config,
+		Database: rc.DatabaseInterface,
+		SessionStore: rc.SessionInterface,
+		ReceiptSystem: rc.ReceiptSystem,
+		Mailer: rc.Mailer,
+	})
+	readinessReportTime = time.Now()
+	return readinessReport
+}
+
+// these are meant for load balancers & container orchestrators, so
+// they're not logged and they bypass the global visibility settings.
+// the messages of /readyz are only shown to admins. see
+// docs/health.org.
+func bindHealthController(ctx *RouterContext) {
+	http.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		fmt.Fprint(w, "ok")
+	})
+	http.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		rc := ctx.NewLocal()
+		report := getReadinessReport(rc)
+		// the login can't be checked when the database or the session
+		// store is the thing that's broken.
+		isAdmin := false
+		if rc.DatabaseInterface != nil && rc.SessionInterface != nil {
+			loginInfo, err := GenerateLoginInfoModel(rc, r)
+			isAdmin = err == nil && loginInfo != nil && loginInfo.LoggedIn && loginInfo.IsAdmin
+		}
+		if !isAdmin { report = report.WithoutMessage() }
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if report.Status != health.STATUS_OK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		LogIfError(json.NewEncoder(w).Encode(report))
+	})
+}
@@ -13,6 +13,10 @@ import (
 
 	"github.com/GitusCodeForge/Gitus/pkg/gitlib"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/drain"
+	gitlog "github.com/GitusCodeForge/Gitus/pkg/gitus/log"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/metrics"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	"github.com/GitusCodeForge/Gitus/routes"
 	. "github.com/GitusCodeForge/Gitus/routes"
@@ -28,8 +32,77 @@ func printGitError(w io.Writer, s string) {
 // HEAD
 // objects/
 
-// NOTE THAT this route handles public http read-only clone, thus it
-// will report 404 for all private repositories.
+// NOTE THAT this route handles http read-only clone. anonymous
+// requests can only read public repositories; the others require
+// basic auth (see routes/httpauth.go & docs/http-clone.org).
+
+func requestHTTPAuth(ctx *RouterContext, w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", ctx.Config.DepotName))
+	w.WriteHeader(401)
+	fmt.Fprint(w, "Authentication required.")
+}
+
+// resolves the repository of a git-over-http request & checks whether
+// the requesting user can read it. the response is already written
+// when nil is returned. refused anonymous requests are asked to
+// authenticate (so that git would prompt for credentials) regardless
+// of whether the repository exists, so that private repositories
+// can't be probed.
+func resolveHTTPCloneRepository(ctx *RouterContext, w http.ResponseWriter, r *http.Request) *model.Repository {
+	if ctx.Config.GlobalVisibility == gitus.GLOBAL_VISIBILITY_MAINTENANCE {
+		w.WriteHeader(403)
+		fmt.Fprint(w, "Service not available right now.")
+		return nil
+	}
+	u, err := ResolveHTTPAuthUser(ctx, w, r)
+	if err == ErrHTTPAuthFailed {
+		requestHTTPAuth(ctx, w)
+		return nil
+	}
+	if err == ErrHTTPAuthRateLimited {
+		w.WriteHeader(429)
+		fmt.Fprint(w, "Too many requests.")
+		return nil
+	}
+	if err != nil {
+		ctx.ReportInternalError(err.Error(), w, r)
+		return nil
+	}
+	canAuthenticate := u == nil && ctx.Config.IsInForgeMode()
+	if !CheckGlobalVisibleToUser(ctx, HTTPAuthLoginInfo(u)) {
+		if canAuthenticate {
+			requestHTTPAuth(ctx, w)
+			return nil
+		}
+		w.WriteHeader(403)
+		fmt.Fprint(w, "Service not available right now.")
+		return nil
+	}
+	notFound := func() *model.Repository {
+		if canAuthenticate {
+			requestHTTPAuth(ctx, w)
+			return nil
+		}
+		w.WriteHeader(404)
+		fmt.Fprint(w, "404 Not Found")
+		return nil
+	}
+	rfn := r.PathValue("repoName")
+	if !model.ValidRepositoryName(rfn) { return notFound() }
+	_, _, ns, repo, err := ctx.ResolveRepositoryFullName(rfn)
+	if err == routes.ErrNotFound || err == db.ErrEntityNotFound { return notFound() }
+	if err != nil {
+		ctx.ReportInternalError(err.Error(), w, r)
+		return nil
+	}
+	if !CheckRepositoryReadable(ctx, HTTPAuthLoginInfo(u), ns, repo) { return notFound() }
+	if repo.Type != model.REPO_TYPE_GIT {
+		w.WriteHeader(403)
+		fmt.Fprint(w, "Repository not Git.")
+		return nil
+	}
+	return repo
+}
 
 func bindHttpCloneController(ctx *RouterContext) {
 	http.HandleFunc("GET /repo/{repoName}/info/{p...}", UseMiddleware(
@@ -42,43 +115,8 @@ func bindHttpCloneController(ctx *RouterContext) {
 				fmt.Fprint(w, "HTTP clone not supported on this instance")
 				return
 			}
-			if ctx.Config.GlobalVisibility != gitus.GLOBAL_VISIBILITY_PUBLIC {
-				ctx.ReportForbidden("Service currently unavailable in this form", w, r)
-				return
-			}
-			rfn := r.PathValue("repoName")
-			if !model.ValidRepositoryName(rfn) {
-				ctx.ReportNotFound(rfn, "Repository", "Depot", w, r)
-				return
-			}
-			_, _, ns, repo, err := ctx.ResolveRepositoryFullName(rfn)
-			if err == routes.ErrNotFound {
-				ctx.ReportNotFound(rfn, "Repository", "Depot", w, r)
-				return
-			}
-			if err != nil {
-				ctx.ReportInternalError(err.Error(), w, r)
-				return
-			}
-			if repo.Type != model.REPO_TYPE_GIT {
-				ctx.ReportNormalError("The repository you have requested isn't a Git repository.", w, r)
-				return
-			}
-			isNamespacePublic := ns.Status == model.NAMESPACE_NORMAL_PUBLIC
-			isRepoPublic := repo.Status == model.REPO_NORMAL_PUBLIC
-			isRepoArchived := repo.Status == model.REPO_ARCHIVED
-			if !ctx.Config.IsInBrowseOnlyMode() {
-				// NOTE: browse-only mode ns/repo visibility is not
-				// controlled via this route but by the "ignored
-				// namespace" / "ignored repository" config which
-				// should've already been accounted for by all the
-				// .Resolve* methods.
-				if !isNamespacePublic || !(isRepoPublic || isRepoArchived) {
-					w.WriteHeader(404)
-					fmt.Fprint(w, "404 Not Found")
-					return
-				}
-			}
+			repo := resolveHTTPCloneRepository(ctx, w, r)
+			if repo == nil { return }
 			// see docs/http-clone.org.
 			if (r.URL.Query().Has("service") && allowV2) {
 				switch r.URL.Query().Get("service") {
@@ -88,9 +126,10 @@ func bindHttpCloneController(ctx *RouterContext) {
 					protocol := r.Header.Get("Git-Protocol")
 					if protocol == "" { protocol = "version=2" }
 					cmd.Env = append(cmd.Env, fmt.Sprintf("GIT_PROTOCOL=%s", protocol))
+					cmd.Env = append(cmd.Env, gitlog.RequestIDEnvOf(r.Context())...)
 					stdout := new(bytes.Buffer)
 					cmd.Stdout = stdout
-					err := cmd.Run()
+					err := drain.Run(cmd)
 					if err != nil {
 						w.WriteHeader(500)
 						printGitError(w, err.Error())
@@ -126,6 +165,10 @@ func bindHttpCloneController(ctx *RouterContext) {
 				ctx.ReportInternalError("Failed to read info/refs", w, r)
 				return
 			}
+			// every dumb clone/fetch starts with info/refs.
+			if r.PathValue("p") == "refs" {
+				metrics.GitOperationTotal.Inc("http-dumb", "git-upload-pack")
+			}
 			w.Write(s)
 		}))
 	http.HandleFunc("POST /repo/{repoName}/git-upload-pack", UseMiddleware(
@@ -136,40 +179,8 @@ func bindHttpCloneController(ctx *RouterContext) {
 				fmt.Fprint(w, "v2 protocl not supported on this instance.")
 				return
 			}
-			if ctx.Config.GlobalVisibility != gitus.GLOBAL_VISIBILITY_PUBLIC {
-				w.WriteHeader(403)
-				w.Write([]byte("Service not available right now."))
-				return
-			}
-			rfn := r.PathValue("repoName")
-			if !model.ValidRepositoryName(rfn) {
-				w.WriteHeader(404)
-				w.Write([]byte("Repository not found."))
-				return
-			}
-			_, _, ns, repo, err := ctx.ResolveRepositoryFullName(rfn)
-			if err == routes.ErrNotFound {
-				w.WriteHeader(404)
-				w.Write([]byte("Repository not found."))
-				return
-			}
-			if err != nil {
-				ctx.ReportInternalError(err.Error(), w, r)
-				return
-			}
-			if repo.Type != model.REPO_TYPE_GIT {
-				w.WriteHeader(403)
-				w.Write([]byte("Repository not Git."))
-				return
-			}
-			isNamespacePublic := ns.Status == model.NAMESPACE_NORMAL_PUBLIC
-			isRepoPublic := repo.Status == model.REPO_NORMAL_PUBLIC
-			isRepoArchived := repo.Status == model.REPO_ARCHIVED
-			if !isNamespacePublic || !(isRepoPublic || isRepoArchived) {
-				w.WriteHeader(404)
-				w.Write([]byte("404 Not Found"))
-				return
-			}
+			repo := resolveHTTPCloneRepository(ctx, w, r)
+			if repo == nil { return }
 			w.Header().Set("Content-Type", "application/x-git-upload-pack-response")
 			w.Header().Set("Cache-Control", "no-cache, max-age=0, must-revalidate")
 			w.WriteHeader(200)
@@ -178,42 +189,16 @@ func bindHttpCloneController(ctx *RouterContext) {
 			protocol := r.Header.Get("Git-Protocol")
 			if protocol == "" { protocol = "version=2" }
 			cmd.Env = append(cmd.Env, fmt.Sprintf("GIT_PROTOCOL=%s", protocol))
+			cmd.Env = append(cmd.Env, gitlog.RequestIDEnvOf(r.Context())...)
 			cmd.Stdout = w
-			cmd.Run()
+			drain.Run(cmd)
+			metrics.GitOperationTotal.Inc("http-v2", "git-upload-pack")
 		}))
 	http.HandleFunc("GET /repo/{repoName}/HEAD", UseMiddleware(
 		[]Middleware{ Logged }, ctx,
 		func(ctx *RouterContext, w http.ResponseWriter, r *http.Request) {
-			if ctx.Config.GlobalVisibility != gitus.GLOBAL_VISIBILITY_PUBLIC {
-				ctx.ReportForbidden("", w, r)
-				return
-			}
-			rfn := r.PathValue("repoName")
-			if !model.ValidRepositoryName(rfn) {
-				ctx.ReportNotFound(rfn, "Repository", "Depot", w, r)
-				return
-			}
-			_, _, ns, repo, err := ctx.ResolveRepositoryFullName(rfn)
-			if err == routes.ErrNotFound {
-				ctx.ReportNotFound(rfn, "Repository", "Depot", w, r)
-				return
-			}
-			if err != nil {
-				ctx.ReportInternalError(err.Error(), w, r)
-				return
-			}
-			if repo.Type != model.REPO_TYPE_GIT {
-				ctx.ReportNormalError("The repository you have requested isn't a Git repository.", w, r)
-				return
-			}
-			isNamespacePublic := ns.Status == model.NAMESPACE_NORMAL_PUBLIC
-			isRepoPublic := repo.Status == model.REPO_NORMAL_PUBLIC
-			isRepoArchived := repo.Status == model.REPO_ARCHIVED
-			if !isNamespacePublic || !(isRepoPublic || isRepoArchived) {
-				w.WriteHeader(404)
-				w.Write([]byte("404 Not Found"))
-				return
-			}
+			repo := resolveHTTPCloneRepository(ctx, w, r)
+			if repo == nil { return }
 			rr := repo.Repository.(*gitlib.LocalGitRepository)
 			p := path.Join(rr.GitDirectoryPath, "HEAD")
 			s, err := os.ReadFile(p)
@@ -226,41 +211,8 @@ func bindHttpCloneController(ctx *RouterContext) {
 	http.HandleFunc("GET /repo/{repoName}/objects/{obj...}", UseMiddleware(
 		[]Middleware{ Logged }, ctx,
 		func(ctx *RouterContext, w http.ResponseWriter, r *http.Request) {
-			if ctx.Config.GlobalVisibility != gitus.GLOBAL_VISIBILITY_PUBLIC {
-				ctx.ReportForbidden("", w, r)
-				return
-			}
-			rfn := r.PathValue("repoName")
-			if !model.ValidRepositoryName(rfn) {
-				ctx.ReportNotFound(rfn, "Repository", "Depot", w, r)
-				return
-			}
-			_, _, ns, repo, err := ctx.ResolveRepositoryFullName(rfn)
-			if err == routes.ErrNotFound {
-				ctx.ReportNotFound(rfn, "Repository", "Depot", w, r)
-				return
-			}
-			if err != nil {
-				ctx.ReportInternalError(err.Error(), w, r)
-				return
-			}
-			if repo.Type != model.REPO_TYPE_GIT {
-				ctx.ReportNormalError("The repository you have requested isn't a Git repository.", w, r)
-				return
-			}
-			isNamespacePublic := ns.Status == model.NAMESPACE_NORMAL_PUBLIC
-			isRepoPublic := repo.Status == model.REPO_NORMAL_PUBLIC
-			isRepoArchived := repo.Status == model.REPO_ARCHIVED
-			if !ctx.Config.IsInBrowseOnlyMode() {
-				// NOTE: browse-only mode ns/repo visibility is not controlled via this route
-				// but by the "ignored namespace" / "ignored repository" config which
-				// should've already been accounted for by all the .Resolve* methods.
-				if !isNamespacePublic || !(isRepoPublic || isRepoArchived) {
-					w.WriteHeader(404)
-					fmt.Fprint(w, "404 Not Found")
-					return
-				}
-			}
+			repo := resolveHTTPCloneRepository(ctx, w, r)
+			if repo == nil { return }
 			obj := r.PathValue("obj")
 			rr := repo.Repository.(*gitlib.LocalGitRepository)
 			p := path.Clean(path.Join(rr.GitDirectoryPath, "objects", obj))
@@ -271,7 +223,7 @@ func bindHttpCloneController(ctx *RouterContext) {
 			}
 			s, err := os.ReadFile(p)
 			if os.IsNotExist(err) {
-				ctx.ReportNotFound(rfn, "object", ctx.Config.DepotName, w, r)
+				ctx.ReportNotFound(obj, "Object", repo.FullName(), w, r)
 				return
 			}
 			if err != nil {
@@ -7,6 +7,7 @@ import (
 
 func InitializeRoute(context *routes.RouterContext) {
 	bindDynamicAssetController(context)
+	bindHealthController(context)
 	
 	bindBlobController(context)
 	bindBranchController(context)
@@ -15,10 +16,12 @@ func InitializeRoute(context *routes.RouterContext) {
 	bindHistoryController(context)
 	bindIndexController(context)
 	bindRepositoryController(context)
+	bindRepositoryInsightController(context)
 	bindTagController(context)
 	bindTreeHandler(context)
 	bindAllController(context)
 	bindHttpCloneController(context)
+	bindLFSController(context)
 	bindShutdownNoticeController(context)
 	bindMaintenanceNoticeController(context)
 	bindPrivateNoticeController(context)
@@ -34,10 +37,14 @@ func InitializeRoute(context *routes.RouterContext) {
 	if context.Config.IsInForgeMode() {
 		bindUserController(context)
 		bindLoginController(context)
+		bindOIDCController(context)
 		bindLogoutController(context)
 		bindSettingController(context)
 		bindSettingSSHController(context)
+		bindSettingWebAuthnController(context)
+		bindSettingLinkedAccountController(context)
 		bindSettingGPGController(context)
+		bindSettingAccessTokenController(context)
 		bindSettingEmailController(context)
 		bindSettingPrivacyController(context)
 		bindRepositorySettingController(context)
@@ -0,0 +1,470 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/lfs"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/metrics"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/quota"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/ssh"
+	"github.com/GitusCodeForge/Gitus/routes"
+	. "github.com/GitusCodeForge/Gitus/routes"
+	"github.com/GitusCodeForge/Gitus/templates"
+)
+
+// the git lfs server: the batch api & the "basic" transfer adapter.
+// the requests are authenticated either with basic auth (the same
+// credentials as http clone) or with the bearer tokens issued by the
+// batch api & git-lfs-authenticate. see docs/lfs.org.
+
+// the batch requests are small json documents; 1MB is enough for
+// thousands of objects.
+const LFS_MAX_BATCH_REQUEST_SIZE = 1024 * 1024
+
+func lfsEnabled(ctx *RouterContext) bool {
+	return ctx.Config.IsInForgeMode() && ctx.Config.GitConfig.LFS.Enable
+}
+
+func writeLFSJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", lfs.MEDIA_TYPE)
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeLFSError(w http.ResponseWriter, code int, message string) {
+	writeLFSJSON(w, code, &lfs.ErrorResponse{ Message: message })
+}
+
+func requestLFSAuth(ctx *RouterContext, w http.ResponseWriter) {
+	w.Header().Set("LFS-Authenticate", fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", ctx.Config.DepotName))
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", ctx.Config.DepotName))
+	writeLFSError(w, 401, "Authentication required.")
+}
+
+// the user authenticated by a bearer token, or nil if the request
+// doesn't have one.
+func resolveLFSTokenUser(ctx *RouterContext, r *http.Request) (*model.GitusUser, *lfs.TokenClaim, error) {
+	s, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok { return nil, nil, nil }
+	claim, err := lfs.VerifyToken(ctx.Config, s)
+	if err != nil { return nil, nil, err }
+	u, err := ctx.DatabaseInterface.GetUserByName(claim.UserName)
+	if err == db.ErrEntityNotFound { return nil, nil, lfs.ErrInvalidToken }
+	if err != nil { return nil, nil, err }
+	switch u.Status {
+	case model.BANNED, model.NORMAL_USER_APPROVAL_NEEDED, model.NORMAL_USER_CONFIRM_NEEDED:
+		return nil, nil, lfs.ErrInvalidToken
+	}
+	return u, claim, nil
+}
+
+type lfsRequest struct {
+	Namespace *model.Namespace
+	Repository *model.Repository
+	// nil for anonymous requests.
+	User *model.GitusUser
+}
+
+// resolves the repository of an lfs request & checks whether the
+// requesting user can perform `op` on it. the rules are the same as
+// http clone for downloading & ssh push for uploading. the response
+// is already written when nil is returned.
+func resolveLFSRequest(ctx *RouterContext, op string, w http.ResponseWriter, r *http.Request) *lfsRequest {
+	if !lfsEnabled(ctx) {
+		writeLFSError(w, 404, "Git LFS is not enabled on this instance.")
+		return nil
+	}
+	if ctx.Config.GlobalVisibility == gitus.GLOBAL_VISIBILITY_MAINTENANCE {
+		writeLFSError(w, 503, "Service not available right now.")
+		return nil
+	}
+	u, claim, err := resolveLFSTokenUser(ctx, r)
+	if err == lfs.ErrInvalidToken || err == lfs.ErrNoSecret {
+		requestLFSAuth(ctx, w)
+		return nil
+	}
+	if err != nil {
+		writeLFSError(w, 500, err.Error())
+		return nil
+	}
+	if claim == nil {
+		u, err = ResolveHTTPAuthUser(ctx, w, r)
+		if err == ErrHTTPAuthFailed {
+			requestLFSAuth(ctx, w)
+			return nil
+		}
+		if err == ErrHTTPAuthRateLimited {
+			writeLFSError(w, 429, "Too many requests.")
+			return nil
+		}
+		if err != nil {
+			writeLFSError(w, 500, err.Error())
+			return nil
+		}
+	}
+	loginInfo := HTTPAuthLoginInfo(u)
+	if !CheckGlobalVisibleToUser(ctx, loginInfo) {
+		if u == nil {
+			requestLFSAuth(ctx, w)
+			return nil
+		}
+		writeLFSError(w, 403, "Service not available right now.")
+		return nil
+	}
+	// refused anonymous requests are asked to authenticate regardless
+	// of whether the repository exists, same as http clone.
+	notFound := func() *lfsRequest {
+		if u == nil {
+			requestLFSAuth(ctx, w)
+			return nil
+		}
+		writeLFSError(w, 404, "Repository not found.")
+		return nil
+	}
+	rfn := strings.TrimSuffix(r.PathValue("repoName"), ".git")
+	if !model.ValidRepositoryName(rfn) { return notFound() }
+	_, _, ns, repo, err := ctx.ResolveRepositoryFullName(rfn)
+	if err == routes.ErrNotFound || err == db.ErrEntityNotFound { return notFound() }
+	if err != nil {
+		writeLFSError(w, 500, err.Error())
+		return nil
+	}
+	if claim != nil && (claim.Repository != repo.FullName() || !claim.Allows(op)) {
+		writeLFSError(w, 403, "The token is not for this repository or operation.")
+		return nil
+	}
+	if !CheckRepositoryReadable(ctx, loginInfo, ns, repo) { return notFound() }
+	if repo.Type != model.REPO_TYPE_GIT {
+		writeLFSError(w, 404, "Repository not Git.")
+		return nil
+	}
+	if op == lfs.OPERATION_UPLOAD {
+		if u == nil {
+			requestLFSAuth(ctx, w)
+			return nil
+		}
+		err = ssh.CheckGitSSHAvailable(ctx.Config)
+		if err == nil { err = ssh.CheckGitAccess(ns, repo, u.Name, true) }
+		if err != nil {
+			writeLFSError(w, 403, err.Error())
+			return nil
+		}
+	}
+	return &lfsRequest{ Namespace: ns, Repository: repo, User: u }
+}
+
+// the base url for the hrefs of the actions. the configured host name
+// is preferred; the one of the request is used when it's not set.
+func lfsBaseURL(ctx *RouterContext, r *http.Request) string {
+	if ctx.Config.ProperHTTPHostName() != "" { return ctx.Config.ProperHTTPHostName() }
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" { scheme = "https" }
+	return scheme + "://" + r.Host
+}
+
+// makes the object available in the repository if it's not uploaded
+// to the repository but to the origin of the fork, so that forks
+// don't need the objects to be uploaded again. returns the size of
+// the object.
+func statLFSObject(ctx *RouterContext, repo *model.Repository, oid string) (int64, error) {
+	size, err := lfs.StatObject(ctx.Config, repo.Namespace, repo.Name, oid)
+	if err == nil || !errors.Is(err, os.ErrNotExist) { return size, err }
+	if repo.ForkOriginName == "" { return 0, err }
+	size, err = lfs.StatObject(ctx.Config, repo.ForkOriginNamespace, repo.ForkOriginName, oid)
+	if err != nil { return 0, err }
+	err = lfs.LinkObject(ctx.Config, repo.ForkOriginNamespace, repo.ForkOriginName, repo.Namespace, repo.Name, oid, size)
+	if err != nil { return 0, err }
+	LogIfError(ctx.DatabaseInterface.RegisterLFSObject(&model.LFSObject{
+		RepoNamespace: repo.Namespace,
+		RepoName: repo.Name,
+		OID: oid,
+		Size: size,
+		UploadTime: time.Now().Unix(),
+	}))
+	return size, nil
+}
+
+// checks the git lfs storage quotas (see docs/quota.org) & returns
+// the status code to report when the check fails.
+func checkLFSQuota(ctx *RouterContext, repo *model.Repository, size int64) (int, error) {
+	err := quota.Check(ctx.Config, ctx.DatabaseInterface, repo.Namespace, repo.Owner, &model.QuotaUsage{ LFSSize: size })
+	if err == nil { return 0, nil }
+	var qe *quota.ErrQuotaExceeded
+	if errors.As(err, &qe) { return 507, err }
+	return 500, err
+}
+
+func bindLFSController(ctx *RouterContext) {
+	http.HandleFunc("POST /repo/{repoName}/info/lfs/objects/batch", UseMiddleware(
+		[]Middleware{ Logged }, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			var req lfs.BatchRequest
+			err := json.NewDecoder(http.MaxBytesReader(w, r.Body, LFS_MAX_BATCH_REQUEST_SIZE)).Decode(&req)
+			if err != nil {
+				writeLFSError(w, 422, "Invalid batch request.")
+				return
+			}
+			if req.Operation != lfs.OPERATION_DOWNLOAD && req.Operation != lfs.OPERATION_UPLOAD {
+				writeLFSError(w, 422, "Invalid operation.")
+				return
+			}
+			if len(req.Transfers) > 0 && !slices.Contains(req.Transfers, lfs.TRANSFER_BASIC) {
+				writeLFSError(w, 422, "Only the basic transfer adapter is supported.")
+				return
+			}
+			if req.HashAlgo != "" && req.HashAlgo != lfs.HASH_ALGO_SHA256 {
+				writeLFSError(w, 409, "Only sha256 is supported.")
+				return
+			}
+			lr := resolveLFSRequest(rc, req.Operation, w, r)
+			if lr == nil { return }
+			repo := lr.Repository
+			header := map[string]string{}
+			if lr.User != nil {
+				token, err := lfs.IssueToken(rc.Config, &lfs.TokenClaim{
+					UserName: lr.User.Name,
+					Repository: repo.FullName(),
+					Operation: req.Operation,
+				})
+				if err != nil {
+					writeLFSError(w, 500, err.Error())
+					return
+				}
+				header["Authorization"] = "Bearer " + token
+			}
+			endpoint := lfs.Endpoint(lfsBaseURL(rc, r), repo.FullName())
+			expiresIn := int64(lfs.TOKEN_LIFETIME.Seconds())
+			maxSize := rc.Config.GitConfig.LFS.MaxObjectSize
+			res := &lfs.BatchResponse{
+				Transfer: lfs.TRANSFER_BASIC,
+				Objects: make([]*lfs.BatchObject, 0, len(req.Objects)),
+				HashAlgo: lfs.HASH_ALGO_SHA256,
+			}
+			// the total size of the objects to be uploaded, which is
+			// checked against the quotas as a whole.
+			var pending int64 = 0
+			for _, obj := range req.Objects {
+				if obj == nil { continue }
+				o := &lfs.BatchObject{ OID: obj.OID, Size: obj.Size, Authenticated: lr.User != nil }
+				res.Objects = append(res.Objects, o)
+				if !lfs.IsValidOID(obj.OID) || obj.Size < 0 {
+					o.Error = &lfs.BatchError{ Code: 422, Message: "Invalid object id or size." }
+					continue
+				}
+				size, err := statLFSObject(rc, repo, obj.OID)
+				exists := err == nil
+				if err != nil && !errors.Is(err, os.ErrNotExist) {
+					o.Error = &lfs.BatchError{ Code: 500, Message: err.Error() }
+					continue
+				}
+				href := endpoint + "/objects/" + obj.OID
+				switch req.Operation {
+				case lfs.OPERATION_DOWNLOAD:
+					if !exists {
+						o.Error = &lfs.BatchError{ Code: 404, Message: "Object not found." }
+						continue
+					}
+					if size != obj.Size {
+						o.Error = &lfs.BatchError{ Code: 422, Message: "Object size mismatch." }
+						continue
+					}
+					o.Actions = map[string]*lfs.BatchAction{
+						"download": &lfs.BatchAction{ Href: href, Header: header, ExpiresIn: expiresIn },
+					}
+				case lfs.OPERATION_UPLOAD:
+					// objects already stored need no action.
+					if exists && size == obj.Size { continue }
+					if maxSize > 0 && obj.Size > maxSize {
+						o.Error = &lfs.BatchError{ Code: 422, Message: fmt.Sprintf("Object larger than the limit of %d bytes.", maxSize) }
+						continue
+					}
+					if code, err := checkLFSQuota(rc, repo, pending + obj.Size); err != nil {
+						o.Error = &lfs.BatchError{ Code: code, Message: err.Error() }
+						continue
+					}
+					pending += obj.Size
+					o.Actions = map[string]*lfs.BatchAction{
+						"upload": &lfs.BatchAction{ Href: href, Header: header, ExpiresIn: expiresIn },
+						"verify": &lfs.BatchAction{ Href: endpoint + "/verify", Header: header, ExpiresIn: expiresIn },
+					}
+				}
+			}
+			writeLFSJSON(w, 200, res)
+		}))
+
+	http.HandleFunc("PUT /repo/{repoName}/info/lfs/objects/{oid}", UseMiddleware(
+		[]Middleware{ Logged }, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			lr := resolveLFSRequest(rc, lfs.OPERATION_UPLOAD, w, r)
+			if lr == nil { return }
+			repo := lr.Repository
+			oid := r.PathValue("oid")
+			if !lfs.IsValidOID(oid) {
+				writeLFSError(w, 422, "Invalid object id.")
+				return
+			}
+			size := r.ContentLength
+			if size < 0 {
+				writeLFSError(w, 411, "Content-Length required.")
+				return
+			}
+			maxSize := rc.Config.GitConfig.LFS.MaxObjectSize
+			if maxSize > 0 && size > maxSize {
+				writeLFSError(w, 413, fmt.Sprintf("Object larger than the limit of %d bytes.", maxSize))
+				return
+			}
+			if code, err := checkLFSQuota(rc, repo, size); err != nil {
+				writeLFSError(w, code, err.Error())
+				return
+			}
+			err := lfs.PutObject(rc.Config, repo.Namespace, repo.Name, oid, size, r.Body)
+			if err == lfs.ErrObjectMismatch {
+				writeLFSError(w, 422, err.Error())
+				return
+			}
+			if err != nil {
+				writeLFSError(w, 500, err.Error())
+				return
+			}
+			err = rc.DatabaseInterface.RegisterLFSObject(&model.LFSObject{
+				RepoNamespace: repo.Namespace,
+				RepoName: repo.Name,
+				OID: oid,
+				Size: size,
+				UploadTime: time.Now().Unix(),
+			})
+			if err != nil {
+				writeLFSError(w, 500, err.Error())
+				return
+			}
+			metrics.GitOperationTotal.Inc("http-lfs", "upload")
+			w.WriteHeader(200)
+		}))
+
+	http.HandleFunc("GET /repo/{repoName}/info/lfs/objects/{oid}", UseMiddleware(
+		[]Middleware{ Logged }, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			lr := resolveLFSRequest(rc, lfs.OPERATION_DOWNLOAD, w, r)
+			if lr == nil { return }
+			serveLFSObject(rc, lr.Repository, r.PathValue("oid"), "", w, r)
+			metrics.GitOperationTotal.Inc("http-lfs", "download")
+		}))
+
+	http.HandleFunc("POST /repo/{repoName}/info/lfs/verify", UseMiddleware(
+		[]Middleware{ Logged }, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			lr := resolveLFSRequest(rc, lfs.OPERATION_UPLOAD, w, r)
+			if lr == nil { return }
+			var req lfs.VerifyRequest
+			err := json.NewDecoder(http.MaxBytesReader(w, r.Body, LFS_MAX_BATCH_REQUEST_SIZE)).Decode(&req)
+			if err != nil || !lfs.IsValidOID(req.OID) {
+				writeLFSError(w, 422, "Invalid verify request.")
+				return
+			}
+			size, err := lfs.StatObject(rc.Config, lr.Repository.Namespace, lr.Repository.Name, req.OID)
+			if errors.Is(err, os.ErrNotExist) {
+				writeLFSError(w, 404, "Object not found.")
+				return
+			}
+			if err != nil {
+				writeLFSError(w, 500, err.Error())
+				return
+			}
+			if size != req.Size {
+				writeLFSError(w, 422, "Object size mismatch.")
+				return
+			}
+			w.Header().Set("Content-Type", lfs.MEDIA_TYPE)
+			w.WriteHeader(200)
+		}))
+
+	// the download link shown in the web ui for pointer files. uses the
+	// web session instead of the lfs authentication.
+	http.HandleFunc("GET /repo/{repoName}/lfs/{oid}", UseMiddleware(
+		[]Middleware{ Logged, UseLoginInfo, GlobalVisibility, ErrorGuard }, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			if !lfsEnabled(rc) {
+				rc.ReportNotFound(r.PathValue("oid"), "LFS object", r.PathValue("repoName"), w, r)
+				return
+			}
+			rfn := r.PathValue("repoName")
+			_, _, ns, repo, err := rc.ResolveRepositoryFullName(rfn)
+			if err == routes.ErrNotFound || err == db.ErrEntityNotFound || (err == nil && !CheckRepositoryReadable(rc, rc.LoginInfo, ns, repo)) {
+				rc.ReportNotFound(rfn, "Repository", "Depot", w, r)
+				return
+			}
+			if err != nil {
+				rc.ReportInternalError(err.Error(), w, r)
+				return
+			}
+			name := r.URL.Query().Get("name")
+			if name == "" { name = r.PathValue("oid") }
+			serveLFSObject(rc, repo, r.PathValue("oid"), name, w, r)
+		}))
+}
+
+// returns nil if `data` is not a git lfs pointer. `filename` is used
+// as the name of the downloaded file; the object id is used when it's
+// empty.
+func resolveLFSPointer(ctx *RouterContext, repo *model.Repository, data []byte, filename string) *templates.LFSPointerTemplateModel {
+	p := lfs.ParsePointer(data)
+	if p == nil { return nil }
+	res := &templates.LFSPointerTemplateModel{ OID: p.OID, Size: p.Size }
+	if !lfsEnabled(ctx) { return res }
+	// objects of the fork origin are linked on download.
+	_, err := lfs.StatObject(ctx.Config, repo.Namespace, repo.Name, p.OID)
+	if err != nil && repo.ForkOriginName != "" {
+		_, err = lfs.StatObject(ctx.Config, repo.ForkOriginNamespace, repo.ForkOriginName, p.OID)
+	}
+	if err != nil { return res }
+	res.DownloadLink = fmt.Sprintf("/repo/%s/lfs/%s", repo.FullName(), p.OID)
+	if filename != "" { res.DownloadLink += "?name=" + url.QueryEscape(filename) }
+	return res
+}
+
+// nil if git lfs is not enabled or the usage can't be retrieved.
+func lfsStorageUsage(ctx *RouterContext, repo *model.Repository) *model.LFSStorageUsage {
+	if !lfsEnabled(ctx) { return nil }
+	res, err := ctx.DatabaseInterface.GetLFSStorageUsage(repo.Namespace, repo.Name)
+	if err != nil { LogIfError(err); return nil }
+	return res
+}
+
+// serves the content of the object; as an attachment named `name` if
+// it's not empty.
+func serveLFSObject(ctx *RouterContext, repo *model.Repository, oid string, name string, w http.ResponseWriter, r *http.Request) {
+	if !lfs.IsValidOID(oid) {
+		writeLFSError(w, 422, "Invalid object id.")
+		return
+	}
+	_, err := statLFSObject(ctx, repo, oid)
+	if err == nil {
+		var f *os.File
+		f, err = lfs.OpenObject(ctx.Config, repo.Namespace, repo.Name, oid)
+		if err == nil {
+			defer f.Close()
+			w.Header().Set("Content-Type", "application/octet-stream")
+			if name != "" {
+				w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+			}
+			http.ServeContent(w, r, "", time.Time{}, f)
+			return
+		}
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		writeLFSError(w, 404, "Object not found.")
+		return
+	}
+	writeLFSError(w, 500, err.Error())
+}
@@ -1,20 +1,228 @@
 package controller
 
 import (
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/GitusCodeForge/Gitus/pkg/auxfuncs"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/session"
+	"github.com/GitusCodeForge/Gitus/pkg/webauthn"
 	. "github.com/GitusCodeForge/Gitus/routes"
 	"github.com/GitusCodeForge/Gitus/templates"
 	"golang.org/x/crypto/bcrypt"
 )
 
 
+const (
+	LOGIN_2FA_METHOD_TOTP = "totp"
+	LOGIN_2FA_METHOD_EMAIL = "email"
+	// email 2fa is enabled but the email could not be sent; only
+	// recovery codes are accepted.
+	LOGIN_2FA_METHOD_RECOVERY = "recovery"
+	LOGIN_2FA_METHOD_WEBAUTHN = "webauthn"
+)
+
+// the state of a pending login confirmation is stored with the confirm
+// code manager as "{method}:{nonce}".
+func login2FAKey(username string) string {
+	return "login-2fa:" + username
+}
+
+func retrieveLogin2FAState(rc *RouterContext, username string) (string, string, bool) {
+	v, ok := rc.ConfirmCodeManager.Get(login2FAKey(username))
+	if !ok || len(v) <= 0 { return "", "", false }
+	method, nonce, ok := strings.Cut(v, ":")
+	if !ok { return "", "", false }
+	return method, nonce, true
+}
+
+func webAuthnLoginKey(username string) string {
+	return "webauthn-login:" + username
+}
+
+// passkey logins don't know the user in advance, so the challenge
+// itself is the key.
+func webAuthnPasskeyKey(challenge string) string {
+	return "webauthn-passkey:" + challenge
+}
+
+func renderLoginPasskey(rc *RouterContext, w http.ResponseWriter, r *http.Request, callback string, errMsg string) {
+	m := &templates.LoginPasskeyTemplateModel{
+		Config: rc.Config,
+		LoginInfo: rc.LoginInfo,
+		ErrorMsg: errMsg,
+		Callback: callback,
+	}
+	rp, err := webAuthnRelyingParty(rc)
+	if err == nil && rc.ConfirmCodeManager != nil {
+		challenge, err := webauthn.NewChallenge()
+		if err != nil {
+			rc.ReportInternalError(fmt.Sprintf("Failed to generate challenge: %s", err), w, r)
+			return
+		}
+		rc.ConfirmCodeManager.Register(webAuthnPasskeyKey(challenge), "1", 10 * time.Minute)
+		m.Available = true
+		m.Challenge = challenge
+		m.RPID = rp.ID
+	}
+	LogTemplateError(rc.LoadTemplate("login-passkey").Execute(w, m))
+}
+
+// renders the login confirmation page. when the method is webauthn a
+// new challenge is issued every time.
+func renderLoginConfirm(rc *RouterContext, w http.ResponseWriter, r *http.Request, user *model.GitusUser, method string, errMsg string) {
+	m := &templates.LoginConfirmTemplateModel{
+		Config: rc.Config,
+		LoginInfo: rc.LoginInfo,
+		ErrorMsg: errMsg,
+		Username: user.Name,
+		Method: method,
+		AcceptTOTP: user.TFAConfig.TOTP.Enable,
+	}
+	if method == LOGIN_2FA_METHOD_WEBAUTHN {
+		rp, err := webAuthnRelyingParty(rc)
+		if err != nil {
+			rc.ReportInternalError(err.Error(), w, r)
+			return
+		}
+		credList, err := rc.DatabaseInterface.GetAllWebAuthnCredentialByUsername(user.Name)
+		if err != nil {
+			rc.ReportInternalError(fmt.Sprintf("Failed to retrieve security keys: %s", err), w, r)
+			return
+		}
+		challenge, err := webauthn.NewChallenge()
+		if err != nil {
+			rc.ReportInternalError(fmt.Sprintf("Failed to generate challenge: %s", err), w, r)
+			return
+		}
+		rc.ConfirmCodeManager.Register(webAuthnLoginKey(user.Name), challenge, 10 * time.Minute)
+		idList := make([]string, 0, len(credList))
+		for _, k := range credList { idList = append(idList, k.CredentialId) }
+		m.Challenge = challenge
+		m.RPID = rp.ID
+		m.AllowList = strings.Join(idList, ",")
+	}
+	LogTemplateError(rc.LoadTemplate("login-confirm").Execute(w, m))
+}
+
+// creates a new session for the user & sets the cookies. the error
+// (if any) is reported to the user & false is returned.
+func startUserSession(rc *RouterContext, w http.ResponseWriter, r *http.Request, username string) bool {
+	ss := session.NewSessionString()
+	_, err := rc.SessionInterface.RegisterSession(username, ss)
+	if err != nil {
+		rc.ReportInternalError(err.Error(), w, r)
+		return false
+	}
+	w.Header().Add("Set-Cookie", (&http.Cookie{
+		Name: COOKIE_KEY_SESSION,
+		Value: ss,
+		Path: "/",
+		MaxAge: 3600,
+		HttpOnly: true,
+		Secure: true,
+		SameSite: http.SameSiteLaxMode,
+	}).String())
+	w.Header().Add("Set-Cookie", (&http.Cookie{
+		Name: "username",
+		Value: username,
+		Path: "/",
+		MaxAge: 3600,
+		HttpOnly: true,
+		Secure: true,
+		SameSite: http.SameSiteLaxMode,
+	}).String())
+	return true
+}
+
+// starts the second step of login for users with 2fa enabled, i.e.
+// redirects the user to /login/confirm. the user must have been
+// authenticated with their password or by an identity provider.
+func beginLogin2FA(rc *RouterContext, w http.ResponseWriter, r *http.Request, u *model.GitusUser) {
+	var err error
+	if rc.ConfirmCodeManager == nil {
+		rc.ReportInternalError("Confirm code manager not initialized. Please contact site owner to fix this problem... ", w, r)
+		return
+	}
+	// security keys are preferred when the user has opted
+	// in for javascript (which they require) or when
+	// they're the only method. totp is preferred over
+	// email since it does not depend on the mailer.
+	var method string
+	switch {
+	case u.TFAConfig.WebAuthn.Enable && (u.WebsitePreference.UseJavascript || (!u.TFAConfig.TOTP.Enable && !u.TFAConfig.Email.Enable)):
+		method = LOGIN_2FA_METHOD_WEBAUTHN
+	case u.TFAConfig.TOTP.Enable:
+		method = LOGIN_2FA_METHOD_TOTP
+	default:
+		method = LOGIN_2FA_METHOD_EMAIL
+		confirmCode := newConfirmCode()
+		if rc.Mailer == nil {
+			err = errors.New("mailer not configured")
+		} else {
+			err = rc.Mailer.SendPlainTextMail(u.Email, fmt.Sprintf("Confirmation Code For Login - %s", rc.Config.DepotName), fmt.Sprintf(`Hello %s,
+
+You're now trying to log in to %s. Since your account has set up email-based two-factor authentication, we have sent you this email.
+
+At the login page you should see a prompt asking you to enter a confirmation code. The code is as follows:
+
+    %s
+
+If this isn't you, we advise you to change your password on %s and other platforms (if you have reused the same password) immediately.
+
+%s
+`, u.Name, rc.Config.DepotName, confirmCode, rc.Config.DepotName, rc.Config.DepotName))
+		}
+		if err != nil {
+			// a recovery code is the only way in when the
+			// mailer is down.
+			if len(u.TFAConfig.RecoveryCode) <= 0 {
+				rc.ReportInternalError(fmt.Sprintf("Failed to send confirmation code email: %s.", err), w, r)
+				return
+			}
+			method = LOGIN_2FA_METHOD_RECOVERY
+		} else {
+			rc.ConfirmCodeManager.Register(u.Name, confirmCode, 10 * time.Minute)
+		}
+	}
+	// the temp key proves that the password has been
+	// checked. it's bound to a one-time nonce so that it
+	// can't be reused after the login is confirmed.
+	nonce := auxfuncs.CryptoGenSym(12)
+	tempKey, err := bcrypt.GenerateFromPassword([]byte(u.PasswordHash+nonce), rc.Config.PasswordHashStrength)
+	if err != nil {
+		rc.ReportInternalError(fmt.Sprintf("Failed to process generated confirmation code: %s.", err), w, r)
+		return
+	}
+	rc.ConfirmCodeManager.Register(login2FAKey(u.Name), method + ":" + nonce, 10 * time.Minute)
+	w.Header().Add("Set-Cookie", (&http.Cookie{
+		Name: COOKIE_KEY_USERNAME,
+		Value: u.Name,
+		Path: "/",
+		MaxAge: 600,
+		HttpOnly: true,
+		Secure: true,
+		SameSite: http.SameSiteLaxMode,
+	}).String())
+	w.Header().Add("Set-Cookie", (&http.Cookie{
+		Name: COOKIE_KEY_TEMP_KEY,
+		Value: string(tempKey),
+		Path: "/",
+		MaxAge: 600,
+		HttpOnly: true,
+		Secure: true,
+		SameSite: http.SameSiteLaxMode,
+	}).String())
+	FoundAt(w, "/login/confirm")
+}
+
 func bindLoginController(ctx *RouterContext) {
 	http.HandleFunc("GET /login", UseMiddleware(
 		[]Middleware{Logged, ErrorGuard}, ctx,
@@ -50,6 +258,9 @@ func bindLoginController(ctx *RouterContext) {
 			ph := r.Form.Get("password")
 			u, err := rc.DatabaseInterface.GetUserByName(un)
 			if err != nil {
+				reason := err.Error()
+				if err == db.ErrEntityNotFound { reason = "no such user" }
+				rc.AuditAs(un, model.AUDIT_LOGIN_FAILURE, AuditUserTarget(un), map[string]any{"method": "password", "reason": reason}, w, r)
 				rc.ReportInternalError(err.Error(), w, r)
 				return
 			}
@@ -76,6 +287,7 @@ func bindLoginController(ctx *RouterContext) {
 			
 			err = bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(ph))
 			if err == bcrypt.ErrMismatchedHashAndPassword {
+				rc.AuditAs(un, model.AUDIT_LOGIN_FAILURE, AuditUserTarget(un), map[string]any{"method": "password", "reason": "wrong password"}, w, r)
 				LogTemplateError(rc.LoadTemplate("login").Execute(w, templates.LoginTemplateModel{
 					Config: rc.Config,
 					ErrorMsg: "Invalid username or password.",
@@ -89,82 +301,101 @@ func bindLoginController(ctx *RouterContext) {
 				return
 			}
 
-			if u.TFAConfig.Email.Enable {
-				confirmCode := newConfirmCode()
-				tempKey, err := bcrypt.GenerateFromPassword([]byte(u.PasswordHash+confirmCode), ctx.Config.PasswordHashStrength)
-				if err != nil {
-					rc.ReportInternalError(fmt.Sprintf("Failed to process generated confirmation code: %s.", err), w, r)
-					return
-				}
-				if rc.ConfirmCodeManager == nil {
-					rc.ReportInternalError("Confirm code manager not initialized. Please contact site owner to fix this problem... ", w, r)
-					return
-				}
-				rc.ConfirmCodeManager.Register(un, confirmCode, 10 * time.Minute)
-				err = rc.Mailer.SendPlainTextMail(u.Email, fmt.Sprintf("Confirmation Code For Login - %s", rc.Config.DepotName), fmt.Sprintf(`Hello %s,
-
-You're now trying to log in to %s. Since your account has set up email-based two-factor authentication, we have sent you this email.
-
-At the login page you should see a prompt asking you to enter a confirmation code. The code is as follows:
-
-    %s
+			if u.TFAConfig.IsEnabled() {
+				beginLogin2FA(rc, w, r, u)
+				return
+			}
+			
+			if !startUserSession(rc, w, r, un) { return }
+			rc.AuditAs(un, model.AUDIT_LOGIN_SUCCESS, AuditUserTarget(un), map[string]any{"method": "password"}, w, r)
+			callbackURL := strings.TrimSpace(r.Form.Get("login-callback"))
+			if callbackURL == "" { callbackURL = "/" }
+			target, err := getQueryPath(callbackURL)
+			if err != nil { target = "/" }
+			FoundAt(w, target)
+		},
+	))
 
-If this isn't you, we advise you to change your password on %s and other platforms (if you have reused the same password) immediately.
+	http.HandleFunc("GET /login/passkey", UseMiddleware(
+		[]Middleware{Logged, ErrorGuard}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			if rc.Config.GlobalVisibility == gitus.GLOBAL_VISIBILITY_MAINTENANCE {
+				FoundAt(w, "/maintenance-notice")
+				return
+			}
+			if rc.LoginInfo != nil && rc.LoginInfo.LoggedIn { FoundAt(w, "/"); return }
+			renderLoginPasskey(rc, w, r, r.URL.Query().Get("callback"), "")
+		},
+	))
 
-%s
-`, u.Name, rc.Config.DepotName, confirmCode, rc.Config.DepotName, rc.Config.DepotName))
-				if err != nil {
-					rc.ReportInternalError(fmt.Sprintf("Failed to send confirmation code email: %s.", err), w, r)
-					return
-				}
-				w.Header().Add("Set-Cookie", (&http.Cookie{
-					Name: COOKIE_KEY_USERNAME,
-					Value: u.Name,
-					Path: "/",
-					MaxAge: 600,
-					HttpOnly: true,
-					Secure: true,
-					SameSite: http.SameSiteLaxMode,
-				}).String())
-				w.Header().Add("Set-Cookie", (&http.Cookie{
-					Name: COOKIE_KEY_TEMP_KEY,
-					Value: string(tempKey),
-					Path: "/",
-					MaxAge: 600,
-					HttpOnly: true,
-					Secure: true,
-					SameSite: http.SameSiteLaxMode,
-				}).String())
-				FoundAt(w, "/login/confirm")
+	http.HandleFunc("POST /login/passkey", UseMiddleware(
+		[]Middleware{Logged, RateLimit, ValidPOSTRequestRequired, ErrorGuard}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			if rc.Config.GlobalVisibility == gitus.GLOBAL_VISIBILITY_MAINTENANCE {
+				FoundAt(w, "/maintenance-notice")
 				return
 			}
-			
-			ss := session.NewSessionString()
-			_, err = rc.SessionInterface.RegisterSession(un, ss)
+			err := r.ParseForm()
 			if err != nil {
-				rc.ReportInternalError(err.Error(), w, r)
+				rc.ReportNormalError("Invalid request", w, r)
+				return
+			}
+			if rc.ConfirmCodeManager == nil {
+				rc.ReportInternalError("Confirm code manager not initialized. Please contact site owner to fix this problem... ", w, r)
 				return
 			}
-			
-			w.Header().Add("Set-Cookie", (&http.Cookie{
-				Name: COOKIE_KEY_SESSION,
-				Value: ss,
-				Path: "/",
-				MaxAge: 3600,
-				HttpOnly: true,
-				Secure: true,
-				SameSite: http.SameSiteLaxMode,
-			}).String())
-			w.Header().Add("Set-Cookie", (&http.Cookie{
-				Name: "username",
-				Value: un,
-				Path: "/",
-				MaxAge: 3600,
-				HttpOnly: true,
-				Secure: true,
-				SameSite: http.SameSiteLaxMode,
-			}).String())
 			callbackURL := strings.TrimSpace(r.Form.Get("login-callback"))
+			// the challenge is taken from the client data & checked
+			// against the issued ones; the signature over the client
+			// data is verified afterwards.
+			clientDataJSON, err := webauthn.DecodeField(r.Form.Get("client-data"))
+			if err != nil {
+				renderLoginPasskey(rc, w, r, callbackURL, "Invalid request.")
+				return
+			}
+			clientData, err := webauthn.ParseClientData(clientDataJSON)
+			if err != nil {
+				renderLoginPasskey(rc, w, r, callbackURL, "Invalid request.")
+				return
+			}
+			challenge := clientData.Challenge
+			v, ok := rc.ConfirmCodeManager.Get(webAuthnPasskeyKey(challenge))
+			if !ok || v != "1" {
+				renderLoginPasskey(rc, w, r, callbackURL, "The login has expired. Please try again.")
+				return
+			}
+			rc.ConfirmCodeManager.Register(webAuthnPasskeyKey(challenge), "", time.Second)
+			cred, err := rc.DatabaseInterface.GetWebAuthnCredential(strings.TrimSpace(r.Form.Get("credential-id")))
+			if err != nil || !cred.Passkey {
+				renderLoginPasskey(rc, w, r, callbackURL, "This security key is not registered as a passkey.")
+				return
+			}
+			credUserName := cred.UserName
+			cred, err = verifyWebAuthnAssertion(rc, r, challenge, cred.UserName, true)
+			if err != nil {
+				rc.AuditAs(credUserName, model.AUDIT_LOGIN_FAILURE, AuditUserTarget(credUserName), map[string]any{"method": "passkey", "reason": err.Error()}, w, r)
+				renderLoginPasskey(rc, w, r, callbackURL, fmt.Sprintf("Failed to verify passkey: %s", err))
+				return
+			}
+			u, err := rc.DatabaseInterface.GetUserByName(cred.UserName)
+			if err != nil {
+				rc.ReportInternalError(err.Error(), w, r)
+				return
+			}
+			switch u.Status {
+			case model.BANNED:
+				renderLoginPasskey(rc, w, r, callbackURL, "User suspended.")
+				return
+			case model.NORMAL_USER_APPROVAL_NEEDED:
+				renderLoginPasskey(rc, w, r, callbackURL, "User waiting for approval.")
+				return
+			case model.NORMAL_USER_CONFIRM_NEEDED:
+				renderLoginPasskey(rc, w, r, callbackURL, "Confirmation needed.")
+				return
+			}
+
+			if !startUserSession(rc, w, r, u.Name) { return }
+			rc.AuditAs(u.Name, model.AUDIT_LOGIN_SUCCESS, AuditUserTarget(u.Name), map[string]any{"method": "passkey"}, w, r)
 			if callbackURL == "" { callbackURL = "/" }
 			target, err := getQueryPath(callbackURL)
 			if err != nil { target = "/" }
@@ -180,15 +411,25 @@ If this isn't you, we advise you to change your password on %s and other platfor
 				return
 			}
 			username, err := r.Cookie(COOKIE_KEY_USERNAME)
-			if err != nil && err != http.ErrNoCookie{
+			if err != nil {
 				rc.ReportNormalError("Invalid request", w, r)
 				return
 			}
-			LogTemplateError(rc.LoadTemplate("login-confirm").Execute(w, &templates.LoginConfirmTemplateModel{
-				Config: rc.Config,
-				LoginInfo: rc.LoginInfo,
-				Username: username.Value,
-			}))
+			if rc.ConfirmCodeManager == nil {
+				rc.ReportInternalError("Confirm code manager not initialized. Please contact site owner to fix this problem... ", w, r)
+				return
+			}
+			method, _, ok := retrieveLogin2FAState(rc, username.Value)
+			if !ok {
+				rc.ReportRedirect("/login", 3, "Confirmation Expired", "The login confirmation has expired. Please log in again.", w, r)
+				return
+			}
+			user, err := rc.DatabaseInterface.GetUserByName(username.Value)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to retrieve user: %s.", err), w, r)
+				return
+			}
+			renderLoginConfirm(rc, w, r, user, method, "")
 		},
 	))
 
@@ -211,49 +452,73 @@ If this isn't you, we advise you to change your password on %s and other platfor
 				rc.ReportInternalError(fmt.Sprintf("Failed to retrieve user: %s.", err), w, r)
 				return
 			}
-			code := r.Form.Get("confirmation-code")
-			err = bcrypt.CompareHashAndPassword([]byte(key.Value), []byte(user.PasswordHash+code))
+			if rc.ConfirmCodeManager == nil {
+				rc.ReportInternalError("Confirm code manager not initialized. Please contact site owner to fix this problem... ", w, r)
+				return
+			}
+			method, nonce, ok := retrieveLogin2FAState(rc, username)
+			if !ok {
+				rc.ReportRedirect("/login", 3, "Confirmation Expired", "The login confirmation has expired. Please log in again.", w, r)
+				return
+			}
+			err = bcrypt.CompareHashAndPassword([]byte(key.Value), []byte(user.PasswordHash+nonce))
 			if err == bcrypt.ErrMismatchedHashAndPassword {
-				LogTemplateError(rc.LoadTemplate("login-confirm").Execute(w, templates.LoginConfirmTemplateModel{
-					Config: rc.Config,
-					ErrorMsg: "Invalid confirmation code.",
-					Username: username,
-				}))
+				rc.ReportRedirect("/login", 3, "Invalid Request", "The login confirmation is invalid. Please log in again.", w, r)
 				return
 			} else if err != nil {
-				LogTemplateError(rc.LoadTemplate("login").Execute(w, templates.LoginConfirmTemplateModel{
-					Config: rc.Config,
-					ErrorMsg: "Internal error: " + err.Error(),
-					Username: username,
-				}))
+				rc.ReportInternalError(err.Error(), w, r)
 				return
 			}
-			
-			ss := session.NewSessionString()
-			_, err = rc.SessionInterface.RegisterSession(username, ss)
+			confirmed := false
+			recoveryCode := strings.TrimSpace(r.Form.Get("recovery-code"))
+			code := strings.TrimSpace(r.Form.Get("confirmation-code"))
+			if len(recoveryCode) > 0 {
+				confirmed = useRecoveryCode(user, recoveryCode)
+			} else if method == LOGIN_2FA_METHOD_WEBAUTHN && len(r.Form.Get("credential-id")) > 0 {
+				challenge, ok := rc.ConfirmCodeManager.Get(webAuthnLoginKey(username))
+				if ok && len(challenge) > 0 {
+					rc.ConfirmCodeManager.Register(webAuthnLoginKey(username), "", time.Second)
+					_, err = verifyWebAuthnAssertion(rc, r, challenge, username, false)
+					confirmed = err == nil
+				}
+			} else {
+				switch method {
+				case LOGIN_2FA_METHOD_TOTP, LOGIN_2FA_METHOD_WEBAUTHN:
+					// totp codes are also accepted when the user
+					// has both set up but doesn't have the key at hand.
+					if !user.TFAConfig.TOTP.Enable { break }
+					confirmed, err = useTOTPCode(user, code)
+					if err != nil {
+						rc.ReportInternalError(err.Error(), w, r)
+						return
+					}
+				case LOGIN_2FA_METHOD_EMAIL:
+					expected, ok := rc.ConfirmCodeManager.Get(username)
+					confirmed = ok && len(expected) > 0 && subtle.ConstantTimeCompare([]byte(strings.ToUpper(code)), []byte(expected)) == 1
+				}
+			}
+			if !confirmed {
+				rc.AuditAs(username, model.AUDIT_LOGIN_2FA_FAILURE, AuditUserTarget(username), map[string]any{"method": method, "recoveryCode": len(recoveryCode) > 0}, w, r)
+				renderLoginConfirm(rc, w, r, user, method, "Invalid confirmation code.")
+				return
+			}
+			// both the used totp step & the used recovery code need
+			// to be recorded.
+			err = rc.DatabaseInterface.UpdateUserInfo(username, user)
 			if err != nil {
 				rc.ReportInternalError(err.Error(), w, r)
 				return
 			}
+			rc.ConfirmCodeManager.Register(login2FAKey(username), "", time.Second)
+			if method == LOGIN_2FA_METHOD_EMAIL {
+				rc.ConfirmCodeManager.Register(username, "", time.Second)
+			}
+			if method == LOGIN_2FA_METHOD_WEBAUTHN {
+				rc.ConfirmCodeManager.Register(webAuthnLoginKey(username), "", time.Second)
+			}
 			
-			w.Header().Add("Set-Cookie", (&http.Cookie{
-				Name: COOKIE_KEY_SESSION,
-				Value: ss,
-				Path: "/",
-				MaxAge: 3600,
-				HttpOnly: true,
-				Secure: true,
-				SameSite: http.SameSiteLaxMode,
-			}).String())
-			w.Header().Add("Set-Cookie", (&http.Cookie{
-				Name: "username",
-				Value: username,
-				Path: "/",
-				MaxAge: 3600,
-				HttpOnly: true,
-				Secure: true,
-				SameSite: http.SameSiteLaxMode,
-			}).String())
+			if !startUserSession(rc, w, r, username) { return }
+			rc.AuditAs(username, model.AUDIT_LOGIN_2FA_SUCCESS, AuditUserTarget(username), map[string]any{"method": method, "recoveryCode": len(recoveryCode) > 0}, w, r)
 			FoundAt(w, "/")
 		},
 	))
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	. "github.com/GitusCodeForge/Gitus/routes"
 	"github.com/GitusCodeForge/Gitus/templates"
 )
@@ -51,6 +52,7 @@ func bindLogoutController(ctx *RouterContext) {
 				ctx.ReportInternalError(err.Error(), w, r)
 				return
 			}
+			ctx.AuditAs(un.Value, model.AUDIT_SESSION_REVOKE, AuditUserTarget(un.Value), map[string]any{"reason": "logout"}, w, r)
 			w.Header().Add("Set-Cookie", (&http.Cookie{
 				Name: COOKIE_KEY_SESSION,
 				Value: "",
@@ -6,7 +6,10 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/lfs"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/quota"
 	auxfuncs "github.com/GitusCodeForge/Gitus/pkg/auxfuncs"
 	. "github.com/GitusCodeForge/Gitus/routes"
 	"github.com/GitusCodeForge/Gitus/templates"
@@ -45,10 +48,25 @@ func bindNamespaceSettingController(ctx *RouterContext) {
 				return
 			}
 			rc.LoginInfo.IsOwner = isOwner
+			usage, err := rc.DatabaseInterface.GetQuotaUsage(model.QUOTA_SUBJECT_NAMESPACE, namespaceName)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to retrieve storage usage: %s", err.Error()), w, r)
+				return
+			}
+			var q *model.Quota
+			if rc.Config.Quota.Enable {
+				q, err = quota.GetEffectiveQuota(rc.Config, rc.DatabaseInterface, model.QUOTA_SUBJECT_NAMESPACE, namespaceName)
+				if err != nil {
+					rc.ReportInternalError(fmt.Sprintf("Failed to retrieve quota: %s", err.Error()), w, r)
+					return
+				}
+			}
 			LogTemplateError(rc.LoadTemplate("namespace-setting/change-info").Execute(w, templates.NamespaceSettingTemplateModel{
 				Namespace: ns,
 				LoginInfo: rc.LoginInfo,
 				Config: rc.Config,
+				QuotaUsage: usage,
+				Quota: q,
 			}))
 		},
 	))
@@ -173,6 +191,7 @@ func bindNamespaceSettingController(ctx *RouterContext) {
 				rc.ReportInternalError(err.Error(), w, r)
 				return
 			}
+			LogIfError(lfs.RemoveNamespace(rc.Config, namespaceName))
 			rc.ReportRedirect("/", 3,
 				"Deleted",
 				"Namespace deleted..",
@@ -237,6 +256,7 @@ func bindNamespaceSettingController(ctx *RouterContext) {
 				Config: rc.Config,
 				ACL: userList,
 				PageInfo: pageInfo,
+				Require2FA: ns.ACL.Requires2FA(),
 			}))
 			
 		},
@@ -281,6 +301,29 @@ func bindNamespaceSettingController(ctx *RouterContext) {
 				)
 				return
 			}
+			if ns.ACL.Requires2FA() {
+				u, err := rc.DatabaseInterface.GetUserByName(username)
+				if err == db.ErrEntityNotFound {
+					rc.ReportRedirect(fmt.Sprintf("/s/%s/member", ns.Name), 3,
+						"User not found",
+						fmt.Sprintf("User %s does not exist.", username),
+						w, r,
+					)
+					return
+				}
+				if err != nil {
+					rc.ReportInternalError(err.Error(), w, r)
+					return
+				}
+				if !u.TFAConfig.IsEnabled() {
+					rc.ReportRedirect(fmt.Sprintf("/s/%s/member", ns.Name), 3,
+						"Two-factor authentication required",
+						fmt.Sprintf("This namespace requires its members to have two-factor authentication enabled, but user %s does not have it enabled.", username),
+						w, r,
+					)
+					return
+				}
+			}
 			t := &model.ACLTuple{
 				AddMember: len(r.Form.Get("addMember")) > 0,
 				DeleteMember: len(r.Form.Get("deleteMember")) > 0,
@@ -297,6 +340,9 @@ func bindNamespaceSettingController(ctx *RouterContext) {
 				rc.ReportInternalError(err.Error(), w, r)
 				return
 			}
+			if d := AuditACLDiff(username, ns.ACL.GetUserPrivilege(username), t); d != nil {
+				rc.Audit(model.AUDIT_NAMESPACE_ACL, AuditNamespaceTarget(namespaceName), d, w, r)
+			}
 			rc.ReportRedirect(fmt.Sprintf("/s/%s/member", ns.Name), 3,
 				"Updated",
 				"Member list updated.",
@@ -306,6 +352,73 @@ func bindNamespaceSettingController(ctx *RouterContext) {
 	))
 
 	
+	http.HandleFunc("POST /s/{namespace}/member-policy", UseMiddleware(
+		[]Middleware{Logged, ValidPOSTRequestRequired,
+			UseLoginInfo, LoginRequired, CSRFCheck,
+			GlobalVisibility, ErrorGuard,
+		}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			namespaceName := r.PathValue("namespace")
+			if !model.ValidNamespaceName(namespaceName) {
+				rc.ReportNotFound(namespaceName, "Repository", "Depot", w, r)
+				return
+			}
+			namespacePath := fmt.Sprintf("/s/%s", namespaceName)
+			if rc.Config.IsInBrowseOnlyMode() { FoundAt(w, namespacePath); return }
+			ns, err := rc.DatabaseInterface.GetNamespaceByName(namespaceName)
+			if err != nil {
+				rc.ReportInternalError(err.Error(), w, r)
+				return
+			}
+			isOwner := ns.Owner == rc.LoginInfo.UserName
+			rc.LoginInfo.IsOwner = isOwner
+			if !rc.LoginInfo.IsAdmin && !isOwner {
+				rc.ReportRedirect(fmt.Sprintf("/s/%s/member", ns.Name), 0,
+					"Not enough privilege",
+					"Only the owner of this namespace can change the member policy.",
+					w, r,
+				)
+				return
+			}
+			require2FA := len(r.Form.Get("require2fa")) > 0
+			if require2FA && ns.ACL != nil {
+				// existing members must all comply before the policy
+				// could be turned on; we don't remove them silently.
+				missing := make([]string, 0)
+				for _, k := range auxfuncs.SortedKeys(ns.ACL.ACL) {
+					u, err := rc.DatabaseInterface.GetUserByName(k)
+					if err == db.ErrEntityNotFound { continue }
+					if err != nil {
+						rc.ReportInternalError(err.Error(), w, r)
+						return
+					}
+					if !u.TFAConfig.IsEnabled() { missing = append(missing, k) }
+				}
+				if len(missing) > 0 {
+					rc.ReportRedirect(fmt.Sprintf("/s/%s/member", ns.Name), 10,
+						"Two-factor authentication required",
+						fmt.Sprintf("The following member(s) do not have two-factor authentication enabled: %s. Please ask them to enable it or remove them before turning on this policy.", strings.Join(missing, ", ")),
+						w, r,
+					)
+					return
+				}
+			}
+			err = rc.DatabaseInterface.SetNamespaceRequire2FA(namespaceName, require2FA)
+			if err != nil {
+				rc.ReportInternalError(err.Error(), w, r)
+				return
+			}
+			if ns.ACL.Requires2FA() != require2FA {
+				rc.Audit(model.AUDIT_NAMESPACE_REQUIRE_2FA, AuditNamespaceTarget(namespaceName), map[string]any{"require2fa": []any{ns.ACL.Requires2FA(), require2FA}}, w, r)
+			}
+			rc.ReportRedirect(fmt.Sprintf("/s/%s/member", ns.Name), 3,
+				"Updated",
+				"Member policy updated.",
+				w, r,
+			)
+		},
+	))
+
 	http.HandleFunc("GET /s/{namespace}/member/{username}/delete", UseMiddleware(
 		[]Middleware{
 			Logged, LoginRequired, ErrorGuard,
@@ -369,6 +482,9 @@ func bindNamespaceSettingController(ctx *RouterContext) {
 				}))
 				return
 			}
+			if d := AuditACLDiff(targetUsername, ns.ACL.GetUserPrivilege(targetUsername), nil); d != nil {
+				rc.Audit(model.AUDIT_NAMESPACE_ACL, AuditNamespaceTarget(namespaceName), d, w, r)
+			}
 			FoundAt(w, fmt.Sprintf("/s/%s/member", namespaceName))
 		},
 	))
@@ -467,6 +583,9 @@ func bindNamespaceSettingController(ctx *RouterContext) {
 				rc.ReportInternalError(err.Error(), w, r)
 				return
 			}
+			if d := AuditACLDiff(targetUsername, ns.ACL.GetUserPrivilege(targetUsername), t); d != nil {
+				rc.Audit(model.AUDIT_NAMESPACE_ACL, AuditNamespaceTarget(namespaceName), d, w, r)
+			}
 			FoundAt(w, fmt.Sprintf("/s/%s/member", namespaceName))
 		},
 	))
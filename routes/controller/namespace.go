@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	"github.com/GitusCodeForge/Gitus/pkg/gitlib"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	. "github.com/GitusCodeForge/Gitus/routes"
 	"github.com/GitusCodeForge/Gitus/templates"
@@ -150,11 +151,19 @@ func bindNamespaceController(ctx *RouterContext) {
 				return
 			}
 			name := r.Form.Get("name")
-			repo, err := rc.DatabaseInterface.CreateRepository(nsName, name, model.REPO_TYPE_GIT, rc.LoginInfo.UserName)
+			objectFormat, err := gitlib.ParseObjectFormat(r.Form.Get("object-format"))
+			if err != nil {
+				rc.ReportRedirect(fmt.Sprintf("/s/%s/new-repo", nsName), 5, "Invalid Object Format", "Object format must be either SHA-1 or SHA-256.", w, r)
+				return
+			}
+			if !rc.CheckQuota(nsName, rc.LoginInfo.UserName, &model.QuotaUsage{ RepositoryCount: 1 }, w, r) { return }
+			repo, err := rc.DatabaseInterface.CreateRepository(nsName, name, model.REPO_TYPE_GIT, objectFormat, rc.LoginInfo.UserName)
 			if err != nil {
 				rc.ReportInternalError(fmt.Sprintf("Failed to create repository: %s", err), w, r)
 				return
 			}
+			rc.RefreshRepositorySize(nsName, name, r)
+			rc.Audit(model.AUDIT_REPOSITORY_CREATE, AuditRepositoryTarget(nsName, name), nil, w, r)
 			rc.ReportRedirect(fmt.Sprintf("/repo/%s", repo.FullName()), 5, "Repository Created", fmt.Sprintf("A new repository named %s has been created under namespace %s.", name, nsName), w, r)
 		},
 	))
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/GitusCodeForge/Gitus/pkg/gitlib"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	. "github.com/GitusCodeForge/Gitus/routes"
 	"github.com/GitusCodeForge/Gitus/templates"
@@ -63,11 +64,19 @@ func bindNewRepositoryController(ctx *RouterContext) {
 				return
 			}
 			newRepoDescription := r.Form.Get("description")
-			repo, err := rc.DatabaseInterface.CreateRepository(newRepoNS, newRepoName, model.REPO_TYPE_GIT, userName)
+			objectFormat, err := gitlib.ParseObjectFormat(r.Form.Get("object-format"))
+			if err != nil {
+				rc.ReportRedirect("/new/repo", 5, "Invalid Object Format", "Object format must be either SHA-1 or SHA-256.", w, r)
+				return
+			}
+			if !rc.CheckQuota(newRepoNS, userName, &model.QuotaUsage{ RepositoryCount: 1 }, w, r) { return }
+			repo, err := rc.DatabaseInterface.CreateRepository(newRepoNS, newRepoName, model.REPO_TYPE_GIT, objectFormat, userName)
 			if err != nil {
 				rc.ReportInternalError(err.Error(), w, r)
 				return
 			}
+			rc.RefreshRepositorySize(newRepoNS, newRepoName, r)
+			rc.Audit(model.AUDIT_REPOSITORY_CREATE, AuditRepositoryTarget(newRepoNS, newRepoName), nil, w, r)
 			repo.Owner = userName
 			repo.Description = newRepoDescription
 			// NOTE: we ignore this error since we have the repository already.
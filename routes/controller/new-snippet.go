@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	. "github.com/GitusCodeForge/Gitus/routes"
 	"github.com/GitusCodeForge/Gitus/templates"
 )
@@ -40,6 +41,7 @@ func bindNewSnippetController(ctx *RouterContext) {
 				return
 			}
 			content := r.Form.Get("content")
+			if !rc.CheckQuota("", username, &model.QuotaUsage{ SnippetSize: int64(len(content)) }, w, r) { return }
 			sn, err := rc.DatabaseInterface.NewSnippet(username, name, uint8(status))
 			if err != nil {
 				rc.ReportInternalError(fmt.Sprintf("Failed to create new snippet: %s", err), w, r)
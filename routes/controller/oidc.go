@@ -0,0 +1,474 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GitusCodeForge/Gitus/pkg/auxfuncs"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	"github.com/GitusCodeForge/Gitus/pkg/oidc"
+	. "github.com/GitusCodeForge/Gitus/routes"
+	"github.com/GitusCodeForge/Gitus/templates"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// single sign-on with openid connect identity providers. see
+// docs/oidc.org.
+
+var ErrOIDCNotAvailable = errors.New("Single sign-on requires the host name of this site to be configured")
+
+// the providers are kept across requests so that the discovery
+// document & the key set are cached. an entry is replaced when the
+// config of the provider changes.
+var oidcProviderCache = make(map[string]*oidc.Provider)
+var oidcProviderCacheLock sync.Mutex
+
+func getOIDCProvider(rc *RouterContext, cfg *gitus.GitusOIDCProviderConfig) (*oidc.Provider, error) {
+	host := rc.Config.ProperHTTPHostName()
+	if len(host) <= 0 { return nil, ErrOIDCNotAvailable }
+	redirectURI := host + "/login/oidc/" + cfg.Id + "/callback"
+	oidcProviderCacheLock.Lock()
+	defer oidcProviderCacheLock.Unlock()
+	p, ok := oidcProviderCache[cfg.Id]
+	if ok && p.Issuer == cfg.Issuer && p.ClientId == cfg.ClientId && p.ClientSecret == cfg.ClientSecret && p.RedirectURI == redirectURI && slices.Equal(p.Scope, cfg.Scope) {
+		return p, nil
+	}
+	p = oidc.NewProvider(cfg.Issuer, cfg.ClientId, cfg.ClientSecret, redirectURI, cfg.Scope)
+	oidcProviderCache[cfg.Id] = p
+	return p, nil
+}
+
+// the state of a pending sso login, stored with the confirm code
+// manager under the "state" parameter.
+type oidcLoginState struct {
+	Provider string `json:"provider"`
+	Nonce string `json:"nonce"`
+	CodeVerifier string `json:"codeVerifier"`
+	Callback string `json:"callback"`
+	// the user who's linking their account from
+	// /setting/linked-account; empty for logins.
+	LinkUser string `json:"linkUser"`
+}
+
+func oidcStateKey(state string) string {
+	return "oidc-state:" + state
+}
+
+// the state is also kept in a cookie of the browser that started the
+// login so that a callback url can't be handed to someone else to log
+// them in as the one who started it (i.e. login csrf).
+func setOIDCStateCookie(w http.ResponseWriter, state string, maxAge int) {
+	w.Header().Add("Set-Cookie", (&http.Cookie{
+		Name: COOKIE_KEY_OIDC_STATE,
+		Value: state,
+		Path: "/login/oidc/",
+		MaxAge: maxAge,
+		HttpOnly: true,
+		Secure: true,
+		SameSite: http.SameSiteLaxMode,
+	}).String())
+}
+
+func checkOIDCStateCookie(r *http.Request, state string) bool {
+	c, err := r.Cookie(COOKIE_KEY_OIDC_STATE)
+	if err != nil { return false }
+	return oidc.CheckState(c.Value, state)
+}
+
+func reportOIDCLoginError(rc *RouterContext, w http.ResponseWriter, msg string) {
+	LogTemplateError(rc.LoadTemplate("login").Execute(w, templates.LoginTemplateModel{
+		Config: rc.Config,
+		ErrorMsg: msg,
+	}))
+}
+
+// derives a username from the claims for auto-provisioning.
+func oidcProposedUsername(claims *oidc.Claims) string {
+	res := claims.PreferredUsername
+	// some providers use the email address as the preferred username.
+	res, _, _ = strings.Cut(res, "@")
+	return strings.TrimSpace(res)
+}
+
+// creates a new user for a user who signs in thru `cfg` for the first
+// time. returns a message for the user when the user cannot be
+// created.
+func provisionOIDCUser(rc *RouterContext, cfg *gitus.GitusOIDCProviderConfig, claims *oidc.Claims) (*model.GitusUser, string, error) {
+	// the email is required since it's the only way to recover the
+	// account other than the identity provider.
+	if len(claims.Email) <= 0 || !claims.EmailVerified {
+		return nil, fmt.Sprintf("Your account at %s does not have a verified email address.", cfg.DisplayName), nil
+	}
+	if _, err := rc.DatabaseInterface.ResolveEmailToUsername(claims.Email); err == nil {
+		return nil, "The email address of your account is already used by another user on this site. Please log in with your password and link your account in the settings.", nil
+	}
+	userName := oidcProposedUsername(claims)
+	if !model.ValidUserName(userName) {
+		return nil, fmt.Sprintf("Your username at %s cannot be used on this site. Please contact the site owner.", cfg.DisplayName), nil
+	}
+	if _, err := rc.DatabaseInterface.GetUserByName(userName); err == nil {
+		return nil, fmt.Sprintf("The username %s has already been taken. Please contact the site owner.", userName), nil
+	}
+	if _, err := rc.DatabaseInterface.GetNamespaceByName(userName); err == nil {
+		return nil, fmt.Sprintf("The username %s has already been taken. Please contact the site owner.", userName), nil
+	}
+	// the user can't log in with a password until they've set one
+	// thru password reset.
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(auxfuncs.CryptoGenSym(32)), rc.Config.PasswordHashStrength)
+	if err != nil { return nil, "", err }
+	status := model.NORMAL_USER
+	if rc.Config.DefaultNewUserStatus != 0 {
+		status = rc.Config.DefaultNewUserStatus
+	}
+	// the email address has been verified by the identity provider.
+	if status == model.NORMAL_USER_CONFIRM_NEEDED { status = model.NORMAL_USER }
+	if rc.Config.ManualApproval || status == model.NORMAL_USER_APPROVAL_NEEDED {
+		status = model.NORMAL_USER_APPROVAL_NEEDED
+		err = rc.DatabaseInterface.InsertRegistrationRequest(userName, claims.Email, string(passwordHash), fmt.Sprintf("Signed in with %s.", cfg.DisplayName))
+		if err != nil { return nil, "", err }
+	}
+	user, err := rc.DatabaseInterface.RegisterUser(userName, claims.Email, string(passwordHash), status)
+	if err != nil { return nil, "", err }
+	if len(claims.Name) > 0 {
+		user.Title = claims.Name
+		err = rc.DatabaseInterface.UpdateUserInfo(userName, user)
+		if err != nil { return nil, "", err }
+	}
+	err = rc.DatabaseInterface.AddEmail(userName, claims.Email)
+	if err != nil { return nil, "", err }
+	err = rc.DatabaseInterface.VerifyRegisteredEmail(userName, claims.Email)
+	if err != nil { return nil, "", err }
+	if status != model.NORMAL_USER_APPROVAL_NEEDED {
+		err = setupNewUserNamespace(rc, userName, status)
+		if err != nil { return nil, "", err }
+	}
+	return user, "", nil
+}
+
+// finds the user linked to the account at the provider; links or
+// creates one according to the config if there's none. returns a
+// message for the user when the login should not proceed.
+func resolveOIDCUser(rc *RouterContext, cfg *gitus.GitusOIDCProviderConfig, claims *oidc.Claims) (*model.GitusUser, string, error) {
+	link, err := rc.DatabaseInterface.GetOIDCLink(cfg.Id, claims.Subject)
+	if err == nil {
+		user, err := rc.DatabaseInterface.GetUserByName(link.UserName)
+		if err != nil { return nil, "", err }
+		return user, "", nil
+	}
+	if err != db.ErrEntityNotFound { return nil, "", err }
+	var user *model.GitusUser
+	if cfg.LinkByEmail && claims.EmailVerified && len(claims.Email) > 0 {
+		// an error here means no user has this email verified.
+		userName, err := rc.DatabaseInterface.ResolveEmailToUsername(claims.Email)
+		if err == nil {
+			verified, err := rc.DatabaseInterface.CheckIfEmailVerified(userName, claims.Email)
+			if err != nil { return nil, "", err }
+			if verified {
+				user, err = rc.DatabaseInterface.GetUserByName(userName)
+				if err != nil { return nil, "", err }
+			}
+		}
+	}
+	if user == nil {
+		if !cfg.AutoProvision {
+			return nil, fmt.Sprintf("There is no user on this site linked to your account at %s.", cfg.DisplayName), nil
+		}
+		var msg string
+		user, msg, err = provisionOIDCUser(rc, cfg, claims)
+		if err != nil { return nil, "", err }
+		if len(msg) > 0 { return nil, msg, nil }
+	}
+	err = rc.DatabaseInterface.RegisterOIDCLink(&model.OIDCLink{
+		ProviderId: cfg.Id,
+		Subject: claims.Subject,
+		UserName: user.Name,
+		LinkTime: time.Now().Unix(),
+	})
+	if err != nil { return nil, "", err }
+	return user, "", nil
+}
+
+func mergeACLTuple(a *model.ACLTuple, b *model.ACLTuple) *model.ACLTuple {
+	return &model.ACLTuple{
+		AddMember: a.AddMember || b.AddMember,
+		DeleteMember: a.DeleteMember || b.DeleteMember,
+		EditMember: a.EditMember || b.EditMember,
+		EditInfo: a.EditInfo || b.EditInfo,
+		AddRepository: a.AddRepository || b.AddRepository,
+		PushToRepository: a.PushToRepository || b.PushToRepository,
+		ArchiveRepository: a.ArchiveRepository || b.ArchiveRepository,
+		DeleteRepository: a.DeleteRepository || b.DeleteRepository,
+		EditHooks: a.EditHooks || b.EditHooks,
+		EditWebHooks: a.EditWebHooks || b.EditWebHooks,
+	}
+}
+
+// applies the group mapping of the provider. failures are logged
+// instead of stopping the login.
+func syncOIDCGroupMembership(rc *RouterContext, w http.ResponseWriter, r *http.Request, cfg *gitus.GitusOIDCProviderConfig, user *model.GitusUser, claims *oidc.Claims) {
+	if !rc.Config.UseNamespace { return }
+	if len(cfg.GroupClaim) <= 0 || len(cfg.GroupMapping) <= 0 { return }
+	groupList := claims.StringList(cfg.GroupClaim)
+	// when several groups are mapped to the same namespace the
+	// privileges are merged.
+	target := make(map[string]*model.ACLTuple)
+	mapped := make([]string, 0)
+	for i := range cfg.GroupMapping {
+		m := &cfg.GroupMapping[i]
+		if !slices.Contains(mapped, m.Namespace) { mapped = append(mapped, m.Namespace) }
+		if !slices.Contains(groupList, m.Group) { continue }
+		if t, ok := target[m.Namespace]; ok {
+			target[m.Namespace] = mergeACLTuple(t, &m.Privilege)
+		} else {
+			p := m.Privilege
+			target[m.Namespace] = &p
+		}
+	}
+	for _, nsName := range mapped {
+		ns, err := rc.DatabaseInterface.GetNamespaceByName(nsName)
+		if err != nil {
+			slog.WarnContext(r.Context(), "oidc group mapping: failed to retrieve namespace", "provider", cfg.Id, "namespace", nsName, "error", err)
+			continue
+		}
+		if ns.Owner == user.Name { continue }
+		old := ns.ACL.GetUserPrivilege(user.Name)
+		t, ok := target[nsName]
+		if ok {
+			if ns.ACL.Requires2FA() && !user.TFAConfig.IsEnabled() {
+				slog.InfoContext(r.Context(), "oidc group mapping: not added since the namespace requires 2fa", "provider", cfg.Id, "user", user.Name, "namespace", nsName)
+				continue
+			}
+			err = rc.DatabaseInterface.SetNamespaceACL(nsName, user.Name, t)
+		} else if cfg.SyncGroupMembership && old != nil {
+			t = nil
+			err = rc.DatabaseInterface.SetNamespaceACL(nsName, user.Name, nil)
+		} else {
+			continue
+		}
+		if err != nil {
+			slog.WarnContext(r.Context(), "oidc group mapping: failed to update membership", "provider", cfg.Id, "user", user.Name, "namespace", nsName, "error", err)
+			continue
+		}
+		if d := AuditACLDiff(user.Name, old, t); d != nil {
+			d["via"] = "oidc:" + cfg.Id
+			rc.AuditAs(user.Name, model.AUDIT_NAMESPACE_ACL, AuditNamespaceTarget(nsName), d, w, r)
+		}
+	}
+}
+
+func bindOIDCController(ctx *RouterContext) {
+	http.HandleFunc("GET /login/oidc/{provider}", UseMiddleware(
+		[]Middleware{Logged, UseLoginInfo, RateLimit, ErrorGuard}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			if rc.Config.GlobalVisibility == gitus.GLOBAL_VISIBILITY_MAINTENANCE {
+				FoundAt(w, "/maintenance-notice")
+				return
+			}
+			cfg := rc.Config.GetOIDCProvider(r.PathValue("provider"))
+			if cfg == nil {
+				rc.ReportNotFound(r.PathValue("provider"), "Identity Provider", "Depot", w, r)
+				return
+			}
+			if rc.ConfirmCodeManager == nil {
+				rc.ReportInternalError("Confirm code manager not initialized. Please contact site owner to fix this problem... ", w, r)
+				return
+			}
+			p, err := getOIDCProvider(rc, cfg)
+			if err != nil {
+				rc.ReportInternalError(err.Error(), w, r)
+				return
+			}
+			state, err := oidc.NewRandomString()
+			if err != nil {
+				rc.ReportInternalError(err.Error(), w, r)
+				return
+			}
+			nonce, err := oidc.NewRandomString()
+			if err != nil {
+				rc.ReportInternalError(err.Error(), w, r)
+				return
+			}
+			verifier, err := oidc.NewRandomString()
+			if err != nil {
+				rc.ReportInternalError(err.Error(), w, r)
+				return
+			}
+			target, err := p.AuthCodeURL(state, nonce, verifier)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to contact the identity provider: %s", err), w, r)
+				return
+			}
+			linkUser := ""
+			if len(r.URL.Query().Get("link")) > 0 {
+				if rc.LoginInfo == nil || !rc.LoginInfo.LoggedIn {
+					FoundAt(w, "/login")
+					return
+				}
+				linkUser = rc.LoginInfo.UserName
+			}
+			s, _ := json.Marshal(&oidcLoginState{
+				Provider: cfg.Id,
+				Nonce: nonce,
+				CodeVerifier: verifier,
+				Callback: r.URL.Query().Get("callback"),
+				LinkUser: linkUser,
+			})
+			rc.ConfirmCodeManager.Register(oidcStateKey(state), string(s), 10 * time.Minute)
+			setOIDCStateCookie(w, state, 600)
+			FoundAt(w, target)
+		},
+	))
+
+	http.HandleFunc("GET /login/oidc/{provider}/callback", UseMiddleware(
+		[]Middleware{Logged, UseLoginInfo, RateLimit, ErrorGuard}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			if rc.Config.GlobalVisibility == gitus.GLOBAL_VISIBILITY_MAINTENANCE {
+				FoundAt(w, "/maintenance-notice")
+				return
+			}
+			cfg := rc.Config.GetOIDCProvider(r.PathValue("provider"))
+			if cfg == nil {
+				rc.ReportNotFound(r.PathValue("provider"), "Identity Provider", "Depot", w, r)
+				return
+			}
+			if rc.ConfirmCodeManager == nil {
+				rc.ReportInternalError("Confirm code manager not initialized. Please contact site owner to fix this problem... ", w, r)
+				return
+			}
+			q := r.URL.Query()
+			if len(q.Get("error")) > 0 {
+				reportOIDCLoginError(rc, w, fmt.Sprintf("%s refused the login: %s %s", cfg.DisplayName, q.Get("error"), q.Get("error_description")))
+				return
+			}
+			stateMatched := checkOIDCStateCookie(r, q.Get("state"))
+			setOIDCStateCookie(w, "", -1)
+			if !stateMatched {
+				reportOIDCLoginError(rc, w, "The login was not started from this browser. Please try again.")
+				return
+			}
+			stateStr, ok := rc.ConfirmCodeManager.Get(oidcStateKey(q.Get("state")))
+			if !ok || len(stateStr) <= 0 {
+				reportOIDCLoginError(rc, w, "The login has expired. Please try again.")
+				return
+			}
+			rc.ConfirmCodeManager.Register(oidcStateKey(q.Get("state")), "", time.Second)
+			var state oidcLoginState
+			err := json.Unmarshal([]byte(stateStr), &state)
+			if err != nil || state.Provider != cfg.Id {
+				reportOIDCLoginError(rc, w, "Invalid request.")
+				return
+			}
+			p, err := getOIDCProvider(rc, cfg)
+			if err != nil {
+				rc.ReportInternalError(err.Error(), w, r)
+				return
+			}
+			token, err := p.Exchange(q.Get("code"), state.CodeVerifier)
+			if err != nil {
+				reportOIDCLoginError(rc, w, fmt.Sprintf("Failed to log in with %s: %s", cfg.DisplayName, err))
+				return
+			}
+			claims, err := p.VerifyIDToken(token.IDToken, state.Nonce)
+			if err != nil {
+				reportOIDCLoginError(rc, w, fmt.Sprintf("Failed to log in with %s: %s", cfg.DisplayName, err))
+				return
+			}
+			// some providers only put the standard claims in the id
+			// token; the rest comes from the userinfo endpoint.
+			needUserInfo := len(claims.Email) <= 0 || len(claims.PreferredUsername) <= 0
+			if len(cfg.GroupClaim) > 0 {
+				_, ok := claims.Raw[cfg.GroupClaim]
+				needUserInfo = needUserInfo || !ok
+			}
+			if needUserInfo && len(token.AccessToken) > 0 {
+				info, err := p.UserInfo(token.AccessToken, claims.Subject)
+				if err == nil {
+					claims.Merge(info)
+				} else {
+					slog.WarnContext(r.Context(), "oidc: failed to retrieve userinfo", "provider", cfg.Id, "error", err)
+				}
+			}
+			if len(state.LinkUser) > 0 {
+				if rc.LoginInfo == nil || !rc.LoginInfo.LoggedIn || rc.LoginInfo.UserName != state.LinkUser {
+					rc.ReportNormalError("Invalid request", w, r)
+					return
+				}
+				link, err := rc.DatabaseInterface.GetOIDCLink(cfg.Id, claims.Subject)
+				if err == nil {
+					msg := "This account has already been linked to your user."
+					if link.UserName != state.LinkUser {
+						msg = "This account has already been linked to another user."
+					}
+					rc.ReportRedirect("/setting/linked-account", 5, "Already Linked", msg, w, r)
+					return
+				}
+				if err != db.ErrEntityNotFound {
+					rc.ReportInternalError(err.Error(), w, r)
+					return
+				}
+				// one account per provider.
+				err = rc.DatabaseInterface.RemoveOIDCLink(state.LinkUser, cfg.Id)
+				if err != nil {
+					rc.ReportInternalError(err.Error(), w, r)
+					return
+				}
+				err = rc.DatabaseInterface.RegisterOIDCLink(&model.OIDCLink{
+					ProviderId: cfg.Id,
+					Subject: claims.Subject,
+					UserName: state.LinkUser,
+					LinkTime: time.Now().Unix(),
+				})
+				if err != nil {
+					rc.ReportInternalError(err.Error(), w, r)
+					return
+				}
+				rc.ReportRedirect("/setting/linked-account", 3, "Account Linked", fmt.Sprintf("Your account at %s has been linked.", cfg.DisplayName), w, r)
+				return
+			}
+			user, msg, err := resolveOIDCUser(rc, cfg, claims)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to log in: %s", err), w, r)
+				return
+			}
+			if len(msg) > 0 {
+				reportOIDCLoginError(rc, w, msg)
+				return
+			}
+			switch user.Status {
+			case model.BANNED:
+				reportOIDCLoginError(rc, w, "User suspended.")
+				return
+			case model.NORMAL_USER_APPROVAL_NEEDED:
+				reportOIDCLoginError(rc, w, "User waiting for approval.")
+				return
+			case model.NORMAL_USER_CONFIRM_NEEDED:
+				reportOIDCLoginError(rc, w, "Confirmation needed.")
+				return
+			}
+			syncOIDCGroupMembership(rc, w, r, cfg, user, claims)
+			// the second factor configured on this site is still
+			// required.
+			if user.TFAConfig.IsEnabled() {
+				beginLogin2FA(rc, w, r, user)
+				return
+			}
+			if !startUserSession(rc, w, r, user.Name) { return }
+			rc.AuditAs(user.Name, model.AUDIT_LOGIN_SUCCESS, AuditUserTarget(user.Name), map[string]any{"method": "oidc", "provider": cfg.Id}, w, r)
+			callbackURL := strings.TrimSpace(state.Callback)
+			if callbackURL == "" { callbackURL = "/" }
+			target, err := getQueryPath(callbackURL)
+			if err != nil { target = "/" }
+			FoundAt(w, target)
+		},
+	))
+}
+
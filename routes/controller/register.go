@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/drain"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/receipt"
 	. "github.com/GitusCodeForge/Gitus/routes"
@@ -14,6 +15,36 @@ import (
 )
 
 
+// creates the user's own namespace (for normal users) & adds the
+// user to the default new user namespace, if the instance uses
+// namespaces.
+func setupNewUserNamespace(rc *RouterContext, userName string, status model.GitusUserStatus) error {
+	if !rc.Config.UseNamespace { return nil }
+	if status == model.NORMAL_USER {
+		_, err := rc.DatabaseInterface.RegisterNamespace(userName, userName)
+		if err != nil { return fmt.Errorf("Failed at registering namespace: %s", err) }
+	}
+	if len(rc.Config.DefaultNewUserNamespace) > 0 {
+		ns, err := rc.DatabaseInterface.GetNamespaceByName(rc.Config.DefaultNewUserNamespace)
+		if err != nil { return fmt.Errorf("Failed at getting default new user namespace: %s", err) }
+		ns.ACL.ACL[userName] = &model.ACLTuple{
+			AddMember: false,
+			DeleteMember: false,
+			EditMember: false,
+			EditInfo: false,
+			AddRepository: true,
+			PushToRepository: false,
+			ArchiveRepository: false,
+			DeleteRepository: false,
+			EditHooks: false,
+			EditWebHooks: false,
+		}
+		err = rc.DatabaseInterface.UpdateNamespaceInfo(ns.Name, ns)
+		if err != nil { return fmt.Errorf("Failed when updating namespace info: %s", err) }
+	}
+	return nil
+}
+
 func bindRegisterController(ctx *RouterContext) {
 	http.HandleFunc("GET /reg", UseMiddleware(
 		[]Middleware{Logged, UseLoginInfo, ErrorGuard}, ctx,
@@ -143,7 +174,7 @@ func bindRegisterController(ctx *RouterContext) {
 					rc.ReportInternalError(fmt.Sprintf("Failed to issue receipt for registration: %s", err.Error()), w, r)
 					return
 				}
-				go func() {
+				drain.Go(func() {
 					email := r.Form.Get("email")
 					title := fmt.Sprintf("Confirmation of registering on %s", rc.Config.DepotName)
 					body := fmt.Sprintf(`
@@ -160,7 +191,7 @@ We wish you all the best in your future endeavours.
 %s
 `, rc.Config.DepotName, rc.Config.ProperHTTPHostName(), rid, rc.Config.DepotName)
 					err = rc.Mailer.SendPlainTextMail(email, title, body)
-				}()
+				})
 				succeedMsg = "A confirmation email has been sent to the email address you have specified. Please proceed from there."
 				rc.ReportRedirect("/", 0, "Request Submitted", succeedMsg, w, r)
 				return
@@ -175,47 +206,10 @@ We wish you all the best in your future endeavours.
 				}))
 				return
 			}
-			if rc.Config.UseNamespace {
-				if newUserStatus == model.NORMAL_USER {
-					_, err = rc.DatabaseInterface.RegisterNamespace(userName, userName)
-					if err != nil {
-						rc.ReportInternalError(
-							fmt.Sprintf("Failed at registering namespace %s. Please contact site admin for this issue.", err.Error()),
-							w, r,
-						)
-						return
-					}
-				}
-				if len(rc.Config.DefaultNewUserNamespace) > 0 {
-					ns, err := rc.DatabaseInterface.GetNamespaceByName(rc.Config.DefaultNewUserNamespace)
-					if err != nil {
-						rc.ReportInternalError(
-							fmt.Sprintf("Failed at getting default new user namespace: %s. Please contact site admin for this issue.", err.Error()),
-							w, r,
-						)
-						return
-					}
-					ns.ACL.ACL[userName] = &model.ACLTuple{
-						AddMember: false,
-						DeleteMember: false,
-						EditMember: false,
-						EditInfo: false,
-						AddRepository: true,
-						PushToRepository: false,
-						ArchiveRepository: false,
-						DeleteRepository: false,
-						EditHooks: false,
-						EditWebHooks: false,
-					}
-					err = rc.DatabaseInterface.UpdateNamespaceInfo(ns.Name, ns)
-					if err != nil {
-						rc.ReportInternalError(
-							fmt.Sprintf("Failed when updating namespace info: %s. Please contact site admin for this issue.", err.Error()),
-							w, r,
-						)
-						return
-					}
-				}
+			err = setupNewUserNamespace(rc, userName, newUserStatus)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("%s. Please contact site admin for this issue.", err), w, r)
+				return
 			}
 			succeedMsg = "Registration complete. You can now login."
 			loginInfo, _ := GenerateLoginInfoModel(ctx, r)
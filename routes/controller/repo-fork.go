@@ -10,6 +10,25 @@ import (
 	"github.com/GitusCodeForge/Gitus/templates"
 )
 
+// the forks of the repository that the current user can see.
+func visibleForkList(rc *RouterContext, repo *model.Repository) ([]*model.Repository, error) {
+	l, err := rc.DatabaseInterface.GetForkRepositoryList(repo.Namespace, repo.Name)
+	if err != nil { return nil, err }
+	nsMap := make(map[string]*model.Namespace)
+	res := make([]*model.Repository, 0)
+	for _, fr := range l {
+		ns, ok := nsMap[fr.Namespace]
+		if !ok {
+			ns, err = rc.DatabaseInterface.GetNamespaceByName(fr.Namespace)
+			if err != nil { return nil, err }
+			nsMap[fr.Namespace] = ns
+		}
+		if !CheckRepositoryReadable(rc, rc.LoginInfo, ns, fr) { continue }
+		res = append(res, fr)
+	}
+	return res, nil
+}
+
 func bindRepositoryForkController(ctx *RouterContext) {
 	http.HandleFunc("GET /repo/{repoName}/fork", UseMiddleware(
 		[]Middleware{Logged, ValidRepositoryNameRequired("repoName"),
@@ -86,6 +105,13 @@ func bindRepositoryForkController(ctx *RouterContext) {
 				rc.ReportRedirect(fmt.Sprintf("/repo/%s/fork", rfn), 0, "Invalid Repository Name", "Repository name must consists of only upper & lowercase letters (a-z, A-Z), 0-9, underscore and hyphen.", w, r)
 				return
 			}
+			// the fork starts w/ a copy of the origin.
+			originSize, err := rc.DatabaseInterface.GetRepositorySize(originNs, originName)
+			if err != nil {
+				rc.ReportInternalError(err.Error(), w, r)
+				return
+			}
+			if !rc.CheckQuota(namespace, rc.LoginInfo.UserName, &model.QuotaUsage{ RepositoryCount: 1, RepositorySize: originSize }, w, r) { return }
 			rp, err := rc.DatabaseInterface.SetUpCloneRepository(originNs, originName, namespace, name, rc.LoginInfo.UserName)
 			if err == db.ErrEntityAlreadyExists {
 				rc.ReportRedirect(fmt.Sprintf("/repo/%s/fork", rfn), 0, "Already Exists", fmt.Sprintf("The repository %s:%s already exists. Please choose a different name or namespace.", namespace, name), w, r)
@@ -95,6 +121,8 @@ func bindRepositoryForkController(ctx *RouterContext) {
 				rc.ReportInternalError(err.Error(), w, r)
 				return
 			}
+			rc.RefreshRepositorySize(rp.Namespace, rp.Name, r)
+			rc.Audit(model.AUDIT_REPOSITORY_CREATE, AuditRepositoryTarget(namespace, name), map[string]any{"forkedFrom": fmt.Sprintf("%s:%s", originNs, originName)}, w, r)
 			FoundAt(w, fmt.Sprintf("/repo/%s", rp.FullName()))
 		},
 	))
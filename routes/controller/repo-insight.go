@@ -0,0 +1,282 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GitusCodeForge/Gitus/pkg/auxfuncs"
+	"github.com/GitusCodeForge/Gitus/pkg/gitlib"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	. "github.com/GitusCodeForge/Gitus/routes"
+	"github.com/GitusCodeForge/Gitus/templates"
+	"github.com/alecthomas/chroma/v2/lexers"
+)
+
+// the insight of a repository is computed in the background and cached
+// with the head id of the default branch, so that only the first
+// visit after a push would trigger a recompute.
+
+const insightMonthCount = 12
+const insightMaxAuthorCount = 50
+
+type repositoryInsightCacheItem struct {
+	headId string
+	computing bool
+	err error
+	insight *model.RepositoryInsight
+}
+
+var repositoryInsightCacheLock sync.Mutex
+var repositoryInsightCache = make(map[string]*repositoryInsightCacheItem, 0)
+
+// "master" first, then "main", then the first branch alphabetically.
+// this is the same rule used by the repository front page.
+func findMajorBranch(rr *gitlib.LocalGitRepository) *gitlib.Branch {
+	br, ok := rr.BranchIndex["master"]
+	if !ok { br, ok = rr.BranchIndex["main"] }
+	if !ok {
+		k := auxfuncs.SortedKeys(rr.BranchIndex)
+		if len(k) <= 0 { return nil }
+		br = rr.BranchIndex[k[0]]
+	}
+	return br
+}
+
+func discernLanguageByFilename(p string) string {
+	base := path.Base(p)
+	switch base {
+	case "Dockerfile": return "Docker"
+	case "Makefile": return "Makefile"
+	}
+	lang := codeTypeDiscern(path.Ext(base))
+	if lang != "" { return lang }
+	lexer := lexers.Match(base)
+	if lexer == nil { return "" }
+	return lexer.Config().Name
+}
+
+func weekStartOf(t time.Time) time.Time {
+	t = t.UTC()
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+}
+
+func monthStartOf(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func computeRepositoryInsight(rr *gitlib.LocalGitRepository, br *gitlib.Branch) (*model.RepositoryInsight, error) {
+	res := &model.RepositoryInsight{
+		BranchName: br.Name,
+		HeadId: br.HeadId,
+		ComputedAt: time.Now(),
+	}
+
+	// language breakdown.
+	fileList, err := rr.GetTreeFileSizeList(br.HeadId)
+	if err != nil { return nil, err }
+	languageMap := make(map[string]int64, 0)
+	for _, item := range fileList {
+		lang := discernLanguageByFilename(item.Path)
+		if lang == "" { lang = "Other" }
+		languageMap[lang] += item.Size
+		res.TotalBytes += item.Size
+	}
+	res.LanguageList = make([]*model.LanguageStat, 0)
+	for k, v := range languageMap {
+		var percentage float64 = 0
+		if res.TotalBytes > 0 {
+			percentage = float64(v) * 100 / float64(res.TotalBytes)
+		}
+		res.LanguageList = append(res.LanguageList, &model.LanguageStat{
+			Language: k,
+			Bytes: v,
+			Percentage: percentage,
+		})
+	}
+	slices.SortFunc(res.LanguageList, func(a, b *model.LanguageStat) int {
+		if a.Bytes > b.Bytes { return -1 }
+		if a.Bytes < b.Bytes { return 1 }
+		return strings.Compare(a.Language, b.Language)
+	})
+
+	// commit history.
+	statList, err := rr.GetCommitStatList(br.HeadId)
+	if err != nil { return nil, err }
+	res.TotalCommit = int64(len(statList))
+	// the months are counted backwards from the latest commit instead of
+	// from now, so that inactive repositories still get a useful chart.
+	lastMonth := monthStartOf(res.ComputedAt)
+	if len(statList) > 0 { lastMonth = monthStartOf(statList[0].Time) }
+	res.MonthList = make([]time.Time, insightMonthCount)
+	for i := range insightMonthCount {
+		res.MonthList[i] = lastMonth.AddDate(0, i-insightMonthCount+1, 0)
+	}
+	authorMap := make(map[string]*model.AuthorStat, 0)
+	weekMap := make(map[int64]*model.WeeklyCodeFrequency, 0)
+	for _, item := range statList {
+		a, ok := authorMap[item.AuthorEmail]
+		if !ok {
+			a = &model.AuthorStat{
+				AuthorName: item.AuthorName,
+				AuthorEmail: item.AuthorEmail,
+				FirstCommitTime: item.Time,
+				LastCommitTime: item.Time,
+				MonthlyCommitCount: make([]int64, insightMonthCount),
+			}
+			authorMap[item.AuthorEmail] = a
+		}
+		a.CommitCount += 1
+		a.Addition += item.Addition
+		a.Deletion += item.Deletion
+		if item.Time.Before(a.FirstCommitTime) { a.FirstCommitTime = item.Time }
+		if item.Time.After(a.LastCommitTime) { a.LastCommitTime = item.Time }
+		m := monthStartOf(item.Time)
+		for i, v := range res.MonthList {
+			if v.Equal(m) { a.MonthlyCommitCount[i] += 1; break }
+		}
+		ws := weekStartOf(item.Time)
+		wk, ok := weekMap[ws.Unix()]
+		if !ok {
+			wk = &model.WeeklyCodeFrequency{ WeekStart: ws }
+			weekMap[ws.Unix()] = wk
+		}
+		wk.CommitCount += 1
+		wk.Addition += item.Addition
+		wk.Deletion += item.Deletion
+	}
+	res.AuthorList = make([]*model.AuthorStat, 0, len(authorMap))
+	for _, v := range authorMap {
+		res.AuthorList = append(res.AuthorList, v)
+	}
+	slices.SortFunc(res.AuthorList, func(a, b *model.AuthorStat) int {
+		if a.CommitCount > b.CommitCount { return -1 }
+		if a.CommitCount < b.CommitCount { return 1 }
+		return strings.Compare(a.AuthorEmail, b.AuthorEmail)
+	})
+	if len(res.AuthorList) > insightMaxAuthorCount {
+		res.AuthorList = res.AuthorList[:insightMaxAuthorCount]
+	}
+	res.CodeFrequency = make([]*model.WeeklyCodeFrequency, 0, len(weekMap))
+	for _, v := range weekMap {
+		res.CodeFrequency = append(res.CodeFrequency, v)
+		if v.Addition > res.MaxWeeklyChange { res.MaxWeeklyChange = v.Addition }
+		if v.Deletion > res.MaxWeeklyChange { res.MaxWeeklyChange = v.Deletion }
+	}
+	slices.SortFunc(res.CodeFrequency, func(a, b *model.WeeklyCodeFrequency) int {
+		return b.WeekStart.Compare(a.WeekStart)
+	})
+	return res, nil
+}
+
+// returns the cached insight if it's computed against `br`. if it's
+// not, a recompute is started in the background (if there isn't one
+// already) and nil is returned.
+func retrieveRepositoryInsight(key string, rr *gitlib.LocalGitRepository, br *gitlib.Branch) (*model.RepositoryInsight, error) {
+	repositoryInsightCacheLock.Lock()
+	defer repositoryInsightCacheLock.Unlock()
+	item, ok := repositoryInsightCache[key]
+	if ok && item.headId == br.HeadId {
+		if item.computing { return nil, nil }
+		return item.insight, item.err
+	}
+	if ok && item.computing { return nil, nil }
+	item = &repositoryInsightCacheItem{
+		headId: br.HeadId,
+		computing: true,
+	}
+	repositoryInsightCache[key] = item
+	go func() {
+		insight, err := computeRepositoryInsight(rr, br)
+		if err != nil {
+			log.Printf("Failed to compute insight for %s: %s\n", key, err.Error())
+		}
+		repositoryInsightCacheLock.Lock()
+		item.insight = insight
+		item.err = err
+		item.computing = false
+		repositoryInsightCacheLock.Unlock()
+	}()
+	return nil, nil
+}
+
+func bindRepositoryInsightController(ctx *RouterContext) {
+	http.HandleFunc("GET /repo/{repoName}/insight", UseMiddleware(
+		[]Middleware{Logged, ValidRepositoryNameRequired("repoName"),
+			UseLoginInfo, GlobalVisibility, ErrorGuard,
+		}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			rfn := r.PathValue("repoName")
+			_, _, ns, repo, err := rc.ResolveRepositoryFullName(rfn)
+			if err == ErrNotFound {
+				rc.ReportNotFound(rfn, "Repository", "Depot", w, r)
+				return
+			}
+			if err != nil {
+				rc.ReportInternalError(err.Error(), w, r)
+				return
+			}
+			if repo.Type != model.REPO_TYPE_GIT {
+				rc.ReportNormalError("The repository you have requested isn't a Git repository.", w, r)
+				return
+			}
+			if rc.Config.IsInForgeMode() {
+				rc.LoginInfo.IsOwner = (repo.Owner == rc.LoginInfo.UserName) || (ns.Owner == rc.LoginInfo.UserName)
+			}
+			if !rc.Config.IsInBrowseOnlyMode() && repo.Status == model.REPO_NORMAL_PRIVATE {
+				chk := rc.LoginInfo.IsAdmin || rc.LoginInfo.IsOwner
+				if !chk {
+					chk = repo.AccessControlList.GetUserPrivilege(rc.LoginInfo.UserName) != nil
+				}
+				if !chk {
+					chk = ns.ACL.GetUserPrivilege(rc.LoginInfo.UserName) != nil
+				}
+				if !chk {
+					rc.ReportNotFound(repo.FullName(), "Repository", "Depot", w, r)
+					return
+				}
+			}
+
+			rr := requestGitRepository(r, repo.Repository.(*gitlib.LocalGitRepository))
+			err = rr.SyncAllBranchList()
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to sync branch list: %s", err.Error()), w, r)
+				return
+			}
+			var insight *model.RepositoryInsight
+			br := findMajorBranch(rr)
+			if br != nil {
+				insight, err = retrieveRepositoryInsight(repo.FullName(), rr, br)
+				if err != nil {
+					rc.ReportInternalError(fmt.Sprintf("Failed to compute repository insight: %s", err.Error()), w, r)
+					return
+				}
+			}
+
+			emailUserMap := make(map[string]string, 0)
+			if insight != nil && rc.Config.IsInForgeMode() {
+				for _, k := range insight.AuthorList {
+					emailUserMap[k.AuthorEmail] = ""
+				}
+				rc.DatabaseInterface.ResolveMultipleEmailToUsername(emailUserMap)
+			}
+
+			LogTemplateError(rc.LoadTemplate("repo-insight").Execute(w, templates.RepositoryInsightModel{
+				Config: rc.Config,
+				Repository: repo,
+				RepoHeaderInfo: *GenerateRepoHeader("", ""),
+				LoginInfo: rc.LoginInfo,
+				IsEmpty: br == nil,
+				Insight: insight,
+				EmailUserMapping: emailUserMap,
+			}))
+		},
+	))
+}
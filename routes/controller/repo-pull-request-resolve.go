@@ -0,0 +1,281 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitlib"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	. "github.com/GitusCodeForge/Gitus/routes"
+	"github.com/GitusCodeForge/Gitus/templates"
+)
+
+// the conflicts of a pull request are resolved by merging the receiver
+// branch into the provider branch (i.e. "ours" is the provider branch)
+// w/ the choices of the user, which makes the pull request mergeable
+// w/o conflict. see docs/merge-conflict-resolution.org.
+
+// the receiver is added as a remote of the provider (named the same
+// way as the remote of the provider in the receiver) so that it can be
+// merged into the provider branch. no remote is needed (i.e. the
+// remote is "") when both branches are in the same repository.
+func setUpResolveTarget(rc *RouterContext, pr *model.PullRequest, provider *model.Repository, r *http.Request) (*gitlib.LocalGitRepository, string, error) {
+	lgr := requestGitRepository(r, provider.Repository.(*gitlib.LocalGitRepository))
+	if pr.ProviderNamespace == pr.ReceiverNamespace && pr.ProviderName == pr.ReceiverName { return lgr, "", nil }
+	remote := fmt.Sprintf("%s/%s", pr.ReceiverNamespace, pr.ReceiverName)
+	err := lgr.SetUpMergeTarget(remote, path.Join(rc.Config.GitRoot, pr.ReceiverNamespace, pr.ReceiverName))
+	if err != nil { return nil, "", err }
+	return lgr, remote, nil
+}
+
+func joinLine(l []string) string {
+	return strings.Join(l, "")
+}
+
+func conflictFileView(f *gitlib.ConflictedFile, oursLabel string, theirsLabel string) *templates.PullRequestConflictFile {
+	res := &templates.PullRequestConflictFile{
+		Path: f.Path,
+		Mergeable: f.Mergeable,
+		OursExists: f.Ours != nil,
+		TheirsExists: f.Theirs != nil,
+	}
+	if !f.Mergeable { return res }
+	res.HunkList = make([]*templates.PullRequestConflictHunk, 0, len(f.ChunkList))
+	for _, c := range f.ChunkList {
+		res.HunkList = append(res.HunkList, &templates.PullRequestConflictHunk{
+			Conflict: c.Conflict,
+			Text: joinLine(c.Line),
+			Base: joinLine(c.Base),
+			Ours: joinLine(c.Ours),
+			Theirs: joinLine(c.Theirs),
+		})
+	}
+	res.MarkedText = joinLine(gitlib.RenderMergeChunk(f.ChunkList, oursLabel, theirsLabel))
+	return res
+}
+
+// reads the choice of the user for the `i`-th conflicted file.
+func readConflictResolution(r *http.Request, i int, f *gitlib.ConflictedFile) (*gitlib.MergeResolution, error) {
+	if !f.Mergeable {
+		side := f.Ours
+		switch r.Form.Get(fmt.Sprintf("file-%d", i)) {
+		case "ours":
+		case "theirs":
+			side = f.Theirs
+		default:
+			return nil, fmt.Errorf("Please choose a version for %s.", f.Path)
+		}
+		if side == nil { return &gitlib.MergeResolution{ Delete: true }, nil }
+		return &gitlib.MergeResolution{ Mode: side.Mode, ObjectId: side.ObjectId }, nil
+	}
+	if r.Form.Get(fmt.Sprintf("mode-%d", i)) == "edit" {
+		// browsers send the content of textareas w/ CRLF.
+		content := strings.ReplaceAll(r.Form.Get(fmt.Sprintf("content-%d", i)), "\r\n", "\n")
+		for _, l := range strings.Split(content, "\n") {
+			if strings.HasPrefix(l, "<<<<<<< ") || strings.HasPrefix(l, ">>>>>>> ") {
+				return nil, fmt.Errorf("The edited content of %s still contains conflict markers.", f.Path)
+			}
+		}
+		return &gitlib.MergeResolution{ Data: []byte(content) }, nil
+	}
+	b := new(strings.Builder)
+	for j, c := range f.ChunkList {
+		if !c.Conflict {
+			b.WriteString(joinLine(c.Line))
+			continue
+		}
+		switch r.Form.Get(fmt.Sprintf("hunk-%d-%d", i, j)) {
+		case "ours":
+			b.WriteString(joinLine(c.Ours))
+		case "theirs":
+			b.WriteString(joinLine(c.Theirs))
+		default:
+			return nil, fmt.Errorf("Please choose a version for every conflicting part of %s.", f.Path)
+		}
+	}
+	return &gitlib.MergeResolution{ Data: []byte(b.String()) }, nil
+}
+
+func bindRepositoryPullRequestResolveController(ctx *RouterContext) {
+	// common part of GET & POST. reports the error & returns nil when
+	// the conflicts can't be resolved by the current user.
+	resolvePullRequest := func(rc *RouterContext, w http.ResponseWriter, r *http.Request) (*model.Repository, *model.PullRequest, *model.Repository) {
+		rfn := r.PathValue("repoName")
+		if rc.Config.IsInBrowseOnlyMode() {
+			FoundAt(w, fmt.Sprintf("/repo/%s", rfn))
+			return nil, nil, nil
+		}
+		_, repoName, ns, s, err := rc.ResolveRepositoryFullName(rfn)
+		if err == ErrNotFound {
+			rc.ReportNotFound(rfn, "Repository", "Depot", w, r)
+			return nil, nil, nil
+		}
+		if err != nil {
+			rc.ReportInternalError(err.Error(), w, r)
+			return nil, nil, nil
+		}
+		// the files of the receiver are shown on the page & its commits
+		// are merged into the provider, so a private receiver has to be
+		// readable by the user the same way the pull request list
+		// requires.
+		rc.LoginInfo.IsOwner = s.Owner == rc.LoginInfo.UserName || ns.Owner == rc.LoginInfo.UserName
+		isMember := ns.ACL.GetUserPrivilege(rc.LoginInfo.UserName) != nil || s.AccessControlList.GetUserPrivilege(rc.LoginInfo.UserName) != nil
+		if (s.Status == model.REPO_NORMAL_PRIVATE) && !rc.LoginInfo.IsAdmin && !rc.LoginInfo.IsOwner && !isMember {
+			rc.ReportNotFound(repoName, "Repository", "", w, r)
+			return nil, nil, nil
+		}
+		pridStr := r.PathValue("prid")
+		prid, err := strconv.ParseInt(pridStr, 10, 64)
+		if err != nil {
+			rc.ReportNotFound(pridStr, "Pull request", rfn, w, r)
+			return nil, nil, nil
+		}
+		pr, err := rc.DatabaseInterface.GetPullRequest(s.Namespace, s.Name, prid)
+		if err != nil {
+			if err == db.ErrEntityNotFound {
+				rc.ReportRedirect(fmt.Sprintf("/repo/%s/pull-request", rfn), 5, "Not Found", "The pull request you've specified does not exist in this repository.", w, r)
+				return nil, nil, nil
+			}
+			rc.ReportInternalError(err.Error(), w, r)
+			return nil, nil, nil
+		}
+		prPath := fmt.Sprintf("/repo/%s/pull-request/%d", rfn, prid)
+		if pr.Status != model.PULL_REQUEST_OPEN {
+			rc.ReportRedirect(prPath, 5, "Pull Request Closed", "The pull request you've specified is already closed.", w, r)
+			return nil, nil, nil
+		}
+		provider, err := pushablePullRequestProvider(rc, pr)
+		if err != nil {
+			rc.ReportInternalError(err.Error(), w, r)
+			return nil, nil, nil
+		}
+		if provider == nil {
+			rc.ReportRedirect(prPath, 5, "Not Enough Privilege", "Resolving the conflicts requires the privilege to push to the branch of the pull request.", w, r)
+			return nil, nil, nil
+		}
+		return s, pr, provider
+	}
+
+	http.HandleFunc("GET /repo/{repoName}/pull-request/{prid}/resolve", UseMiddleware(
+		[]Middleware{Logged, ValidRepositoryNameRequired("repoName"),
+			UseLoginInfo, LoginRequired, GlobalVisibility, ErrorGuard,
+		}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			s, pr, provider := resolvePullRequest(rc, w, r)
+			if s == nil { return }
+			prPath := fmt.Sprintf("/repo/%s/pull-request/%d", r.PathValue("repoName"), pr.PRId)
+			lgr, remote, err := setUpResolveTarget(rc, pr, provider, r)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to set up merge target: %s", err.Error()), w, r)
+				return
+			}
+			detail, err := lgr.GetMergeConflictDetail(pr.ProviderBranch, remote, pr.ReceiverBranch)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to check for merge conflict: %s", err.Error()), w, r)
+				return
+			}
+			if detail.Result.Successful {
+				rc.ReportRedirect(prPath, 5, "No Conflict", "The branches can be merged w/o conflict now; please perform the merge check again.", w, r)
+				return
+			}
+			oursLabel := pr.ProviderBranch
+			theirsLabel := pr.ReceiverBranch
+			if len(remote) > 0 { theirsLabel = fmt.Sprintf("%s/%s", remote, pr.ReceiverBranch) }
+			fileList := make([]*templates.PullRequestConflictFile, 0, len(detail.FileList))
+			for _, f := range detail.FileList {
+				fileList = append(fileList, conflictFileView(f, oursLabel, theirsLabel))
+			}
+			LogTemplateError(rc.LoadTemplate("pull-request/resolve-conflict").Execute(w, &templates.RepositoryPullRequestResolveTemplateModel{
+				Config: rc.Config,
+				Repository: s,
+				RepoHeaderInfo: &templates.RepoHeaderTemplateModel{
+					TypeStr: "", NodeName: "",
+				},
+				LoginInfo: rc.LoginInfo,
+				PullRequest: pr,
+				OursId: detail.OursId,
+				TheirsId: detail.TheirsId,
+				OursLabel: oursLabel,
+				TheirsLabel: theirsLabel,
+				Message: detail.Result.Message,
+				FileList: fileList,
+			}))
+		},
+	))
+
+	http.HandleFunc("POST /repo/{repoName}/pull-request/{prid}/resolve", UseMiddleware(
+		[]Middleware{Logged, ValidPOSTRequestRequired,
+			ValidRepositoryNameRequired("repoName"), UseLoginInfo,
+			LoginRequired, CSRFCheck, GlobalVisibility, ErrorGuard,
+		}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			s, pr, provider := resolvePullRequest(rc, w, r)
+			if s == nil { return }
+			prPath := fmt.Sprintf("/repo/%s/pull-request/%d", r.PathValue("repoName"), pr.PRId)
+			resolvePath := prPath + "/resolve"
+			lgr, remote, err := setUpResolveTarget(rc, pr, provider, r)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to set up merge target: %s", err.Error()), w, r)
+				return
+			}
+			detail, err := lgr.GetMergeConflictDetail(pr.ProviderBranch, remote, pr.ReceiverBranch)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to check for merge conflict: %s", err.Error()), w, r)
+				return
+			}
+			oursId := r.Form.Get("ours-id")
+			theirsId := r.Form.Get("theirs-id")
+			// the choices are only meaningful for the conflicts they
+			// were made on.
+			if detail.OursId != oursId || detail.TheirsId != theirsId {
+				rc.ReportRedirect(resolvePath, 5, "Branch Updated", "The branches have been updated since the conflicts were shown; please review the conflicts again.", w, r)
+				return
+			}
+			if len(detail.FileList) <= 0 {
+				rc.ReportRedirect(prPath, 5, "Cannot Resolve", "The conflicts of this pull request cannot be resolved on the web.", w, r)
+				return
+			}
+			resolution := make(map[string]*gitlib.MergeResolution)
+			var size int64 = 0
+			for i, f := range detail.FileList {
+				res, err := readConflictResolution(r, i, f)
+				if err != nil {
+					rc.ReportNormalError(err.Error(), w, r)
+					return
+				}
+				resolution[f.Path] = res
+				size += int64(len(res.Data))
+			}
+			if !rc.CheckQuota(provider.Namespace, provider.Owner, &model.QuotaUsage{ RepositorySize: size }, w, r) { return }
+			commitId, err := lgr.CommitMergeResolution(remote, pr.ReceiverBranch, pr.ProviderBranch, oursId, theirsId, resolution, rc.LoginInfo.UserFullName, rc.LoginInfo.UserEmail)
+			if errors.Is(err, gitlib.ErrMergeTargetMoved) {
+				rc.ReportRedirect(resolvePath, 5, "Branch Updated", "The branches have been updated since the conflicts were shown; please review the conflicts again.", w, r)
+				return
+			}
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to commit the resolution: %s", err.Error()), w, r)
+				return
+			}
+			rc.RefreshRepositorySize(provider.Namespace, provider.Name, r)
+			_, err = rc.DatabaseInterface.RecordPullRequestBranchUpdate(pr.PRAbsId, rc.LoginInfo.UserName, commitId)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to record the update: %s", err.Error()), w, r)
+				return
+			}
+			// so that the pull request can be merged right away.
+			_, err = rc.DatabaseInterface.CheckPullRequestMergeConflict(r.Context(), pr.PRAbsId)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to check for merge conflict: %s", err.Error()), w, r)
+				return
+			}
+			// the other pull requests from the same branch.
+			rc.SyncPullRequestProviderBranch(provider.Namespace, provider.Name, r)
+			rc.ReportRedirect(prPath, 3, "Conflicts Resolved", fmt.Sprintf("The conflicts have been resolved in commit %s on branch %s.", commitId, pr.ProviderBranch), w, r)
+		},
+	))
+}
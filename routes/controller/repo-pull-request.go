@@ -1,18 +1,77 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"path"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/pullrequest"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/ssh"
 	"github.com/GitusCodeForge/Gitus/pkg/gitlib"
 	. "github.com/GitusCodeForge/Gitus/routes"
 	"github.com/GitusCodeForge/Gitus/templates"
 )
 
+// the number of commits listed when comparing the branches of a new
+// pull request.
+const PULL_REQUEST_COMPARE_COMMIT_LIMIT = 50
+
+// the repository `fullName` if it can be the provider of a pull request
+// opened by the current user, i.e. it's a git repository they can pull
+// from (it doesn't need to be a fork); otherwise nil.
+func resolvePullRequestProvider(rc *RouterContext, fullName string) (*model.Repository, error) {
+	_, _, ns, provider, err := rc.ResolveRepositoryFullName(fullName)
+	if err == ErrNotFound { return nil, nil }
+	if err != nil { return nil, err }
+	if provider.Type != model.REPO_TYPE_GIT { return nil, nil }
+	if ssh.CheckGitAccess(ns, provider, rc.LoginInfo.UserName, false) != nil { return nil, nil }
+	return provider, nil
+}
+
+// the provider repository of the open pull request if the current user
+// can push to it, otherwise nil.
+func pushablePullRequestProvider(rc *RouterContext, pr *model.PullRequest) (*model.Repository, error) {
+	if rc.LoginInfo == nil || !rc.LoginInfo.LoggedIn { return nil, nil }
+	if pr.Status != model.PULL_REQUEST_OPEN { return nil, nil }
+	_, _, ns, provider, err := rc.ResolveRepositoryFullName(fmt.Sprintf("%s:%s", pr.ProviderNamespace, pr.ProviderName))
+	if err == ErrNotFound { return nil, nil }
+	if err != nil { return nil, err }
+	if provider.Type != model.REPO_TYPE_GIT { return nil, nil }
+	if ssh.CheckGitAccess(ns, provider, rc.LoginInfo.UserName, true) != nil { return nil, nil }
+	return provider, nil
+}
+
+// the provider repository of the open pull request if the current user
+// can delete its provider branch after merging it, otherwise nil. the
+// default branch of the provider is never deleted.
+func deletableProviderBranch(rc *RouterContext, pr *model.PullRequest) (*model.Repository, error) {
+	provider, err := pushablePullRequestProvider(rc, pr)
+	if err != nil || provider == nil { return nil, err }
+	headBranch, err := provider.Repository.(*gitlib.LocalGitRepository).GetHeadBranch()
+	if err != nil { return nil, err }
+	if headBranch == pr.ProviderBranch { return nil, nil }
+	return provider, nil
+}
+
+// the open pull request from `providerBranch` of `provider` into
+// `receiverBranch` of `receiver` if there's one, otherwise nil.
+func findOpenPullRequest(rc *RouterContext, receiver *model.Repository, receiverBranch string, provider *model.Repository, providerBranch string) (*model.PullRequest, error) {
+	l, err := rc.DatabaseInterface.GetOpenPullRequestOfProvider(provider.Namespace, provider.Name)
+	if err != nil { return nil, err }
+	for _, pr := range l {
+		if pr.ReceiverNamespace == receiver.Namespace && pr.ReceiverName == receiver.Name && pr.ReceiverBranch == receiverBranch && pr.ProviderBranch == providerBranch {
+			return pr, nil
+		}
+	}
+	return nil, nil
+}
+
 func bindRepositoryPullRequestController(ctx *RouterContext) {
 	http.HandleFunc("GET /repo/{repoName}/pull-request", UseMiddleware(
 		[]Middleware{Logged, ValidRepositoryNameRequired("repoName"),
@@ -122,6 +181,24 @@ func bindRepositoryPullRequestController(ctx *RouterContext) {
 			pn, err := strconv.ParseInt(pnstr, 10, 64)
 			if err != nil { pn = 0 }
 			preList, err := rc.DatabaseInterface.GetAllPullRequestEventPaginated(pr.PRAbsId, pn, 30)
+			canResolve := false
+			if pr.MergeCheckResult != nil && !pr.MergeCheckResult.Successful && len(pr.MergeCheckResult.FileInfo) > 0 {
+				provider, err := pushablePullRequestProvider(rc, pr)
+				if err != nil {
+					rc.ReportInternalError(err.Error(), w, r)
+					return
+				}
+				canResolve = provider != nil
+			}
+			canDeleteBranch := false
+			if pr.Status == model.PULL_REQUEST_OPEN && pr.MergeCheckResult != nil && pr.MergeCheckResult.Successful {
+				provider, err := deletableProviderBranch(rc, pr)
+				if err != nil {
+					rc.ReportInternalError(err.Error(), w, r)
+					return
+				}
+				canDeleteBranch = provider != nil
+			}
 			LogTemplateError(rc.LoadTemplate("pull-request/single-pull-request").Execute(w, &templates.RepositorySinglePullRequestTemplateModel{
 				Config: rc.Config,
 				Repository: s,
@@ -132,6 +209,8 @@ func bindRepositoryPullRequestController(ctx *RouterContext) {
 				PullRequest: pr,
 				PullRequestEventList: preList,
 				PageNum: pn,
+				CanResolveConflict: canResolve,
+				CanDeleteProviderBranch: canDeleteBranch,
 			}))
 		},
 	))
@@ -191,7 +270,7 @@ func bindRepositoryPullRequestController(ctx *RouterContext) {
 				}
 				FoundAt(w, returnPath)
 			case "merge-check":
-				e, err := rc.DatabaseInterface.CheckPullRequestMergeConflict(pr.PRAbsId)
+				e, err := rc.DatabaseInterface.CheckPullRequestMergeConflict(r.Context(), pr.PRAbsId)
 				fmt.Println("ee", e)
 				if err != nil {
 					rc.ReportInternalError(err.Error(), w, r)
@@ -199,11 +278,54 @@ func bindRepositoryPullRequestController(ctx *RouterContext) {
 				}
 				FoundAt(w, returnPath)
 			case "close-as-merged":
-				err = rc.DatabaseInterface.CheckAndMergePullRequest(pr.PRAbsId, rc.LoginInfo.UserName)
+				// the provider branch is only deleted if it's not
+				// updated after it's merged.
+				var provider *model.Repository
+				providerHead := ""
+				if r.Form.Has("delete-branch") {
+					provider, err = deletableProviderBranch(rc, pr)
+					if err != nil {
+						rc.ReportInternalError(err.Error(), w, r)
+						return
+					}
+					if provider == nil {
+						rc.ReportRedirect(returnPath, 5, "Permission Denied", "You cannot delete the provider branch of this pull request.", w, r)
+						return
+					}
+					providerHead, err = pullrequest.ResolveProviderHead(rc.Config, pr)
+					if err != nil {
+						rc.ReportInternalError(err.Error(), w, r)
+						return
+					}
+				}
+				err = rc.DatabaseInterface.CheckAndMergePullRequest(r.Context(), pr.PRAbsId, rc.LoginInfo.UserName)
 				if err != nil {
 					rc.ReportInternalError(err.Error(), w, r)
 					return
 				}
+				// the receiver branch could be the provider branch of
+				// other pull requests.
+				rc.SyncPullRequestProviderBranch(s.Namespace, s.Name, r)
+				if provider != nil {
+					merged, err := rc.DatabaseInterface.GetPullRequestByAbsId(pr.PRAbsId)
+					if err != nil {
+						rc.ReportInternalError(err.Error(), w, r)
+						return
+					}
+					if merged.Status != model.PULL_REQUEST_CLOSED_AS_MERGED {
+						rc.ReportRedirect(returnPath, 5, "Not Merged", "The pull request cannot be merged w/o conflict; the provider branch is kept.", w, r)
+						return
+					}
+					err = provider.Repository.(*gitlib.LocalGitRepository).DeleteRef(fmt.Sprintf("refs/heads/%s", pr.ProviderBranch), providerHead)
+					if errors.Is(err, gitlib.ErrRefChanged) {
+						rc.ReportRedirect(returnPath, 5, "Branch Kept", "The pull request has been merged, but the provider branch has been updated since; it's kept.", w, r)
+						return
+					}
+					if err != nil {
+						rc.ReportInternalError(fmt.Sprintf("The pull request has been merged but the provider branch cannot be deleted: %s", err.Error()), w, r)
+						return
+					}
+				}
 				FoundAt(w, returnPath)
 			case "close-as-not-merged":
 				err = rc.DatabaseInterface.ClosePullRequestAsNotMerged(pr.PRAbsId, rc.LoginInfo.UserName)
@@ -247,56 +369,82 @@ func bindRepositoryPullRequestController(ctx *RouterContext) {
 				rc.ReportInternalError(err.Error(), w, r)
 				return
 			}
-			err = s.Repository.(*gitlib.LocalGitRepository).SyncAllBranchList()
+			if s.Type != model.REPO_TYPE_GIT {
+				rc.ReportNormalError("Pull requests are only supported for git repositories.", w, r)
+				return
+			}
+			lgr := requestGitRepository(r, s.Repository.(*gitlib.LocalGitRepository))
+			err = lgr.SyncAllBranchList()
 			if err != nil {
 				rc.ReportInternalError(fmt.Sprintf("Failed to sync all branch list: %s", err), w, r)
 				return
 			}
+			newPath := fmt.Sprintf("/repo/%s/pull-request/new", rfn)
 			receiverBranch := strings.TrimSpace(r.URL.Query().Get("recv-br"))
 			if len(receiverBranch) > 0 {
-				_, ok := s.Repository.(*gitlib.LocalGitRepository).BranchIndex[receiverBranch]
+				_, ok := lgr.BranchIndex[receiverBranch]
 				if !ok {
-					rc.ReportRedirect(fmt.Sprintf("/repo/%s/pull-request/new", rfn), 5, "Not Found", fmt.Sprintf("Branch \"%s\" does not exist in repository %s. Please choose an existing branch.", receiverBranch, rfn), w, r)
+					rc.ReportRedirect(newPath, 5, "Not Found", fmt.Sprintf("Branch \"%s\" does not exist in repository %s. Please choose an existing branch.", receiverBranch, rfn), w, r)
 					return
 				}
 			}
-			providerRepositoryName := strings.TrimSpace(r.URL.Query().Get("repo"))
+			// a repository that's not in the list (e.g. not a fork)
+			// can be typed in.
+			providerRepositoryName := strings.TrimSpace(r.URL.Query().Get("other-repo"))
+			if len(providerRepositoryName) <= 0 {
+				providerRepositoryName = strings.TrimSpace(r.URL.Query().Get("repo"))
+			}
 			if !model.ValidRepositoryName(providerRepositoryName) {
 				rc.ReportNormalError("Invalid request", w, r)
 				return
 			}
-			if len(providerRepositoryName) <= 0 {
+			if len(providerRepositoryName) <= 0 || len(receiverBranch) <= 0 {
 				fr, err := rc.DatabaseInterface.GetForkRepositoryOfUser(rc.LoginInfo.UserName, s.Namespace, s.Name)
 				if err != nil {
 					rc.ReportInternalError(err.Error(), w, r)
 					return
 				}
+				defaultBranch, err := lgr.GetHeadBranch()
+				if err != nil {
+					rc.ReportInternalError(err.Error(), w, r)
+					return
+				}
 				LogTemplateError(rc.LoadTemplate("pull-request/new-pull-request").Execute(w, &templates.RepositoryNewPullRequestTemplateModel{
 					Config: rc.Config,
 					Repository: s,
 					LoginInfo: rc.LoginInfo,
-					ProviderRepository: fr,
+					ReceiverBranch: defaultBranch,
+					// the repository itself comes first, i.e. a pull
+					// request between two of its branches.
+					ProviderRepository: append([]*model.Repository{s}, fr...),
 					Stage: "repo",
 				}))
-			} else {
-				_, _, _, provider, err := rc.ResolveRepositoryFullName(providerRepositoryName)
-				if err == ErrNotFound {
-					rc.ReportNotFound(provider.FullName(), "Repository", "Depot", w, r)
-					return
-				}
-				if err != nil {
-					rc.ReportInternalError(err.Error(), w, r)
-					return
-				}
+				return
+			}
+			provider, err := resolvePullRequestProvider(rc, providerRepositoryName)
+			if err != nil {
+				rc.ReportInternalError(err.Error(), w, r)
+				return
+			}
+			if provider == nil {
+				rc.ReportRedirect(newPath, 5, "Not Found", fmt.Sprintf("Repository %s does not exist or is not a git repository you have access to.", providerRepositoryName), w, r)
+				return
+			}
+			isSameRepository := provider.Namespace == s.Namespace && provider.Name == s.Name
+			plgr := provider.Repository.(*gitlib.LocalGitRepository)
+			err = plgr.SyncAllBranchList()
+			if err != nil {
+				rc.ReportInternalError(err.Error(), w, r)
+				return
+			}
+			providerBranch := strings.TrimSpace(r.URL.Query().Get("prov-br"))
+			if len(providerBranch) <= 0 {
 				branchNameList := make([]string, 0)
-				err = provider.Repository.(*gitlib.LocalGitRepository).SyncAllBranchList()
-				if err != nil {
-					rc.ReportInternalError(err.Error(), w, r)
-					return
-				}
-				for k := range provider.Repository.(*gitlib.LocalGitRepository).BranchIndex {
+				for k := range plgr.BranchIndex {
+					if isSameRepository && k == receiverBranch { continue }
 					branchNameList = append(branchNameList, k)
 				}
+				slices.Sort(branchNameList)
 				LogTemplateError(rc.LoadTemplate("pull-request/new-pull-request").Execute(w, &templates.RepositoryNewPullRequestTemplateModel{
 					Config: rc.Config,
 					Repository: s,
@@ -306,7 +454,46 @@ func bindRepositoryPullRequestController(ctx *RouterContext) {
 					ProviderBranchList: branchNameList,
 					Stage: "branch",
 				}))
+				return
+			}
+			if _, ok := plgr.BranchIndex[providerBranch]; !ok {
+				rc.ReportRedirect(newPath, 5, "Not Found", fmt.Sprintf("Branch \"%s\" does not exist in repository %s. Please choose an existing branch.", providerBranch, provider.FullName()), w, r)
+				return
+			}
+			if isSameRepository && providerBranch == receiverBranch {
+				rc.ReportRedirect(newPath, 5, "Invalid Request", "A branch cannot be merged into itself. Please choose another branch.", w, r)
+				return
+			}
+			remote := ""
+			if !isSameRepository {
+				remote = fmt.Sprintf("%s/%s", provider.Namespace, provider.Name)
+				err = lgr.SetUpMergeTarget(remote, path.Join(rc.Config.GitRoot, provider.Namespace, provider.Name))
+				if err != nil {
+					rc.ReportInternalError(fmt.Sprintf("Failed to set up the provider repository: %s", err.Error()), w, r)
+					return
+				}
+			}
+			comparison, err := lgr.CompareMergeTarget(receiverBranch, remote, providerBranch, PULL_REQUEST_COMPARE_COMMIT_LIMIT)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to compare the branches: %s", err.Error()), w, r)
+				return
+			}
+			existing, err := findOpenPullRequest(rc, s, receiverBranch, provider, providerBranch)
+			if err != nil {
+				rc.ReportInternalError(err.Error(), w, r)
+				return
 			}
+			LogTemplateError(rc.LoadTemplate("pull-request/new-pull-request").Execute(w, &templates.RepositoryNewPullRequestTemplateModel{
+				Config: rc.Config,
+				Repository: s,
+				LoginInfo: rc.LoginInfo,
+				ReceiverBranch: receiverBranch,
+				ChosenProviderRepository: provider,
+				ProviderBranch: providerBranch,
+				Comparison: comparison,
+				ExistingPullRequest: existing,
+				Stage: "compare",
+			}))
 		},
 	))
 	
@@ -336,18 +523,48 @@ func bindRepositoryPullRequestController(ctx *RouterContext) {
 				rc.ReportNormalError("Invalid request", w, r)
 				return
 			}
+			newPath := fmt.Sprintf("/repo/%s/pull-request/new", rfn)
 			title := r.Form.Get("title")
 			receiverBranch := r.Form.Get("receiver-branch")
 			providerNamespace := r.Form.Get("provider-namespace")
 			providerName := r.Form.Get("provider-name")
 			providerBranch := r.Form.Get("provider-branch")
-			resId, err := rc.DatabaseInterface.NewPullRequest(rc.LoginInfo.UserName, title, s.Namespace, s.Name, receiverBranch, providerNamespace, providerName, providerBranch)
+			if s.Type != model.REPO_TYPE_GIT {
+				rc.ReportNormalError("Pull requests are only supported for git repositories.", w, r)
+				return
+			}
+			provider, err := resolvePullRequestProvider(rc, fmt.Sprintf("%s:%s", providerNamespace, providerName))
+			if err != nil {
+				rc.ReportInternalError(err.Error(), w, r)
+				return
+			}
+			if provider == nil {
+				rc.ReportRedirect(newPath, 5, "Not Found", "The provider repository does not exist or is not a git repository you have access to.", w, r)
+				return
+			}
+			lgr := s.Repository.(*gitlib.LocalGitRepository)
+			plgr := provider.Repository.(*gitlib.LocalGitRepository)
+			_, err = lgr.ResolveRef(fmt.Sprintf("refs/heads/%s", receiverBranch))
+			if err == nil { _, err = plgr.ResolveRef(fmt.Sprintf("refs/heads/%s", providerBranch)) }
+			if errors.Is(err, gitlib.ErrRefNotFound) {
+				rc.ReportRedirect(newPath, 5, "Not Found", "The branches you've chosen do not exist. Please choose existing branches.", w, r)
+				return
+			}
 			if err != nil {
-				rc.ReportRedirect(fmt.Sprintf("/repo/%s/pull-request/new", rfn), 0, "Internal Error", fmt.Sprintf("Failed to create pull request: %s", err.Error()), w, r)
+				rc.ReportInternalError(err.Error(), w, r)
 				return
 			}
+			if provider.Namespace == s.Namespace && provider.Name == s.Name && providerBranch == receiverBranch {
+				rc.ReportRedirect(newPath, 5, "Invalid Request", "A branch cannot be merged into itself. Please choose another branch.", w, r)
+				return
+			}
+			resId, err := rc.DatabaseInterface.NewPullRequest(rc.LoginInfo.UserName, title, s.Namespace, s.Name, receiverBranch, provider.Namespace, provider.Name, providerBranch)
+			if err != nil {
+				rc.ReportRedirect(newPath, 0, "Internal Error", fmt.Sprintf("Failed to create pull request: %s", err.Error()), w, r)
+				return
+			}
+			rc.TrackPullRequestProviderHead(s.Namespace, s.Name, resId, r)
 			FoundAt(w, fmt.Sprintf("/repo/%s/pull-request/%d", rfn, resId))
 		},
 	))
 }
-
@@ -10,6 +10,7 @@ import (
 
 	"github.com/GitusCodeForge/Gitus/pkg/auxfuncs"
 	"github.com/GitusCodeForge/Gitus/pkg/gitlib"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/lfs"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	. "github.com/GitusCodeForge/Gitus/routes"
 	"github.com/GitusCodeForge/Gitus/templates"
@@ -56,6 +57,7 @@ func bindRepositorySettingController(ctx *RouterContext) {
 				Repository: repo,
 				RepoFullName: rfn,
 				LoginInfo: rc.LoginInfo,
+				LFSUsage: lfsStorageUsage(rc, repo),
 			}))
 		},
 	))
@@ -130,18 +132,23 @@ func bindRepositorySettingController(ctx *RouterContext) {
 				return
 			}
 			repo.Description = newDescription
+			oldStatus := repo.Status
 			repo.Status = model.GitusRepositoryStatus(i)
 			err = ctx.DatabaseInterface.UpdateRepositoryInfo(repo.Namespace, repo.Name, repo)
 			if err != nil {
 				rc.ReportInternalError(fmt.Sprintf("Failed to update repository info: %s", err), w, r)
 				return
 			}
+			if oldStatus != repo.Status {
+				rc.Audit(model.AUDIT_REPOSITORY_STATUS, AuditRepositoryTarget(repo.Namespace, repo.Name), map[string]any{"status": []any{oldStatus, repo.Status}}, w, r)
+			}
 			LogTemplateError(ctx.LoadTemplate("repo-setting/change-info").Execute(w, &templates.RepositorySettingTemplateModel{
 				Config: rc.Config,
 				LoginInfo: rc.LoginInfo,
 				Repository: repo,
 				RepoFullName: rfn,
 				ErrorMsg: "Updated.",
+				LFSUsage: lfsStorageUsage(rc, repo),
 			}))
 		},
 	))
@@ -205,6 +212,8 @@ func bindRepositorySettingController(ctx *RouterContext) {
 				)
 				return
 			}
+			LogIfError(lfs.RemoveRepository(rc.Config, repo.Namespace, repo.Name))
+			rc.Audit(model.AUDIT_REPOSITORY_DELETE, AuditRepositoryTarget(repo.Namespace, repo.Name), nil, w, r)
 			redirectTarget := "/"
 			if ctx.Config.UseNamespace { redirectTarget = fmt.Sprintf("/s/%s", ns.Name) }
 			ctx.ReportRedirect(redirectTarget, 3, "Deleted.", "The specified repository is deleted.", w, r)
@@ -355,6 +364,9 @@ func bindRepositorySettingController(ctx *RouterContext) {
 				ctx.ReportInternalError(err.Error(), w, r)
 				return
 			}
+			if d := AuditACLDiff(username, repo.AccessControlList.GetUserPrivilege(username), t); d != nil {
+				rc.Audit(model.AUDIT_REPOSITORY_ACL, AuditRepositoryTarget(repo.Namespace, repo.Name), d, w, r)
+			}
 			FoundAt(w, fmt.Sprintf("/repo/%s/setting/member", rfn))
 
 		},
@@ -470,6 +482,9 @@ func bindRepositorySettingController(ctx *RouterContext) {
 				)
 				return
 			}
+			if d := AuditACLDiff(targetUsername, repo.AccessControlList.GetUserPrivilege(targetUsername), t); d != nil {
+				rc.Audit(model.AUDIT_REPOSITORY_ACL, AuditRepositoryTarget(repo.Namespace, repo.Name), d, w, r)
+			}
 			FoundAt(w, fmt.Sprintf("/repo/%s/setting/member", rfn))
 		},
 	))
@@ -522,6 +537,9 @@ func bindRepositorySettingController(ctx *RouterContext) {
 				ctx.ReportInternalError(fmt.Sprintf("Failed to delete member: %s.", err), w, r)
 				return
 			}
+			if d := AuditACLDiff(targetUsername, repo.AccessControlList.GetUserPrivilege(targetUsername), nil); d != nil {
+				rc.Audit(model.AUDIT_REPOSITORY_ACL, AuditRepositoryTarget(nsName, repoName), d, w, r)
+			}
 			FoundAt(w, fmt.Sprintf("/repo/%s/setting/member", rfn))
 		},
 	))
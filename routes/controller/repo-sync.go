@@ -0,0 +1,215 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitlib"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	. "github.com/GitusCodeForge/Gitus/routes"
+	"github.com/GitusCodeForge/Gitus/templates"
+)
+
+// the upstream of a fork is its `origin` remote.
+const FORK_UPSTREAM_REMOTE = "origin"
+
+// resolves the fork for the sync actions. reports the error & returns
+// nil when the repository can't be synced by the current user.
+func resolveSyncableFork(rc *RouterContext, w http.ResponseWriter, r *http.Request) *model.Repository {
+	rfn := r.PathValue("repoName")
+	_, _, ns, repo, err := rc.ResolveRepositoryFullName(rfn)
+	if err == ErrNotFound {
+		rc.ReportNotFound(rfn, "Repository", "Depot", w, r)
+		return nil
+	}
+	if err != nil {
+		rc.ReportInternalError(err.Error(), w, r)
+		return nil
+	}
+	if repo.Type != model.REPO_TYPE_GIT {
+		rc.ReportNormalError("The repository you have requested isn't a Git repository.", w, r)
+		return nil
+	}
+	if len(repo.ForkOriginNamespace) <= 0 && len(repo.ForkOriginName) <= 0 {
+		rc.ReportNormalError("The repository you have requested isn't a fork.", w, r)
+		return nil
+	}
+	rc.LoginInfo.IsOwner = (repo.Owner == rc.LoginInfo.UserName) || (ns.Owner == rc.LoginInfo.UserName)
+	if !rc.LoginInfo.IsOwner {
+		rc.ReportRedirect(fmt.Sprintf("/repo/%s", rfn), 0,
+			"Not enough privilege",
+			"Your user account seems to not have enough privilege for this action.",
+			w, r,
+		)
+		return nil
+	}
+	return repo
+}
+
+// see docs/fork-sync.org.
+func bindRepositorySyncController(ctx *RouterContext) {
+	http.HandleFunc("GET /repo/{repoName}/sync/{branchName}", UseMiddleware(
+		[]Middleware{Logged, ValidRepositoryNameRequired("repoName"),
+			LoginRequired, GlobalVisibility, ErrorGuard,
+		}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			repo := resolveSyncableFork(rc, w, r)
+			if repo == nil { return }
+			branchName := r.PathValue("branchName")
+			rr := requestGitRepository(r, repo.Repository.(*gitlib.LocalGitRepository))
+			err := rr.SyncAllBranchList()
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to sync branch list: %s", err.Error()), w, r)
+				return
+			}
+			if _, ok := rr.BranchIndex[branchName]; !ok {
+				rc.ReportNotFound(branchName, "Branch", repo.FullName(), w, r)
+				return
+			}
+			upstreamPath := path.Join(rc.Config.GitRoot, repo.ForkOriginNamespace, repo.ForkOriginName)
+			upstream := requestGitRepository(r, gitlib.NewLocalGitRepository(upstreamPath))
+			err = upstream.SyncAllBranchList()
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to sync branch list of upstream: %s", err.Error()), w, r)
+				return
+			}
+			_, hasUpstreamBranch := upstream.BranchIndex[branchName]
+			var compareInfo *gitlib.BranchComparisonInfo
+			var mergeCheck *gitlib.MergeCheckResult
+			if hasUpstreamBranch {
+				compareInfo, err = upstream.CompareBranchWithRemote(branchName, fmt.Sprintf("%s/%s", repo.Namespace, repo.Name))
+				if err != nil {
+					rc.ReportInternalError(fmt.Sprintf("Failed to compare w/ upstream: %s", err.Error()), w, r)
+					return
+				}
+				// the preview only makes sense when a merge commit
+				// would be made.
+				if compareInfo != nil && len(compareInfo.ARevList) > 0 && len(compareInfo.BRevList) > 0 {
+					mergeCheck, err = rr.CheckBranchMergeConflict(branchName, FORK_UPSTREAM_REMOTE, branchName)
+					if err != nil {
+						rc.ReportInternalError(fmt.Sprintf("Failed to check for merge conflict: %s", err.Error()), w, r)
+						return
+					}
+				}
+			}
+			autoSync, err := rc.DatabaseInterface.GetForkAutoSync(repo.Namespace, repo.Name)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to retrieve auto-sync setting: %s", err.Error()), w, r)
+				return
+			}
+			LogTemplateError(rc.LoadTemplate("repo-sync").Execute(w, &templates.RepositorySyncTemplateModel{
+				Config: rc.Config,
+				LoginInfo: rc.LoginInfo,
+				Repository: repo,
+				RepoHeaderInfo: GenerateRepoHeader("branch", branchName),
+				BranchName: branchName,
+				HasUpstreamBranch: hasUpstreamBranch,
+				ComparisonInfo: compareInfo,
+				MergeCheckResult: mergeCheck,
+				AutoSync: autoSync,
+			}))
+		},
+	))
+
+	http.HandleFunc("POST /repo/{repoName}/sync/{branchName}", UseMiddleware(
+		[]Middleware{Logged, ValidPOSTRequestRequired,
+			ValidRepositoryNameRequired("repoName"),
+			LoginRequired, CSRFCheck, GlobalVisibility, ErrorGuard,
+		}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			repo := resolveSyncableFork(rc, w, r)
+			if repo == nil { return }
+			rfn := r.PathValue("repoName")
+			branchName := r.PathValue("branchName")
+			syncPath := fmt.Sprintf("/repo/%s/sync/%s", rfn, branchName)
+			rr := requestGitRepository(r, repo.Repository.(*gitlib.LocalGitRepository))
+			err := rr.SyncAllBranchList()
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to sync branch list: %s", err.Error()), w, r)
+				return
+			}
+			if _, ok := rr.BranchIndex[branchName]; !ok {
+				rc.ReportNotFound(branchName, "Branch", repo.FullName(), w, r)
+				return
+			}
+			var msg string
+			switch r.Form.Get("action") {
+			case "fast-forward":
+				err = rr.FastForward(FORK_UPSTREAM_REMOTE, branchName, branchName)
+				if errors.Is(err, gitlib.ErrDiverged) {
+					rc.ReportRedirect(syncPath, 5, "Cannot Fast-forward", "The branch has diverged from its upstream; please merge or rebase instead.", w, r)
+					return
+				}
+				msg = "The branch has been fast-forwarded to its upstream."
+			case "merge":
+				// the merge itself refuses conflicts as well, but this
+				// gives a better message.
+				var mr *gitlib.MergeCheckResult
+				mr, err = rr.CheckBranchMergeConflict(branchName, FORK_UPSTREAM_REMOTE, branchName)
+				if err != nil {
+					rc.ReportInternalError(fmt.Sprintf("Failed to check for merge conflict: %s", err.Error()), w, r)
+					return
+				}
+				if !mr.Successful {
+					rc.ReportRedirect(syncPath, 5, "Merge Conflict", "The upstream cannot be merged into this branch w/o conflict.", w, r)
+					return
+				}
+				// no need for a merge commit when the branch is only
+				// behind.
+				err = rr.FastForward(FORK_UPSTREAM_REMOTE, branchName, branchName)
+				if errors.Is(err, gitlib.ErrDiverged) {
+					err = rr.Merge(FORK_UPSTREAM_REMOTE, branchName, branchName, rc.LoginInfo.UserFullName, rc.LoginInfo.UserEmail)
+				}
+				msg = "The upstream has been merged into the branch."
+			case "rebase":
+				err = rr.Rebase(FORK_UPSTREAM_REMOTE, branchName, branchName, rc.LoginInfo.UserFullName, rc.LoginInfo.UserEmail)
+				if errors.Is(err, gitlib.ErrRebaseConflict) {
+					rc.ReportRedirect(syncPath, 5, "Rebase Conflict", fmt.Sprintf("%s. Please merge instead, or rebase locally & push.", err.Error()), w, r)
+					return
+				}
+				msg = "The branch has been rebased onto its upstream."
+			default:
+				rc.ReportNormalError("Invalid request", w, r)
+				return
+			}
+			if errors.Is(err, gitlib.ErrRefChanged) {
+				rc.ReportRedirect(syncPath, 5, "Branch Updated", "The branch has been updated while syncing; nothing has been changed. Please try again.", w, r)
+				return
+			}
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to sync repository: %s", err.Error()), w, r)
+				return
+			}
+			rc.RefreshRepositorySize(repo.Namespace, repo.Name, r)
+			rc.SyncPullRequestProviderBranch(repo.Namespace, repo.Name, r)
+			rc.ReportRedirect(fmt.Sprintf("/repo/%s/branch/%s", rfn, branchName), 3, "Repository Synced", msg, w, r)
+		},
+	))
+
+	http.HandleFunc("POST /repo/{repoName}/sync", UseMiddleware(
+		[]Middleware{Logged, ValidPOSTRequestRequired,
+			ValidRepositoryNameRequired("repoName"),
+			LoginRequired, CSRFCheck, GlobalVisibility, ErrorGuard,
+		}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			repo := resolveSyncableFork(rc, w, r)
+			if repo == nil { return }
+			rfn := r.PathValue("repoName")
+			enable := r.Form.Has("auto-sync")
+			err := rc.DatabaseInterface.SetForkAutoSync(repo.Namespace, repo.Name, enable)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to update auto-sync setting: %s", err.Error()), w, r)
+				return
+			}
+			target := fmt.Sprintf("/repo/%s", rfn)
+			if b := r.Form.Get("branch"); len(b) > 0 {
+				target = fmt.Sprintf("/repo/%s/sync/%s", rfn, b)
+			}
+			msg := "Auto-sync has been turned off."
+			if enable { msg = "Auto-sync has been turned on." }
+			rc.ReportRedirect(target, 3, "Setting Updated", msg, w, r)
+		},
+	))
+}
@@ -57,7 +57,7 @@ func bindRepositoryController(ctx *RouterContext) {
 				}
 			}
 
-			rr := s.Repository.(*gitlib.LocalGitRepository)
+			rr := requestGitRepository(r, s.Repository.(*gitlib.LocalGitRepository))
 			err = rr.SyncAllBranchList()
 			if err != nil {
 				LogTemplateError(rc.LoadTemplate("error").Execute(w, templates.ErrorTemplateModel{
@@ -199,7 +199,7 @@ func bindRepositoryController(ctx *RouterContext) {
 				upstreamPath := path.Join(ctx.Config.GitRoot, s.ForkOriginNamespace, s.ForkOriginName)
 				upstream, _ = model.CreateLocalRepository(model.REPO_TYPE_GIT, s.ForkOriginNamespace, s.ForkOriginName, upstreamPath)
 				remoteName := fmt.Sprintf("%s/%s", s.Namespace, s.Name)
-				compareInfo, err = upstream.(*gitlib.LocalGitRepository).CompareBranchWithRemote(br.Name, remoteName)
+				compareInfo, err = requestGitRepository(r, upstream.(*gitlib.LocalGitRepository)).CompareBranchWithRemote(br.Name, remoteName)
 			}
 			
 		findingMajorBranchDone:
@@ -237,6 +237,15 @@ func bindRepositoryController(ctx *RouterContext) {
 				return
 			}
 			
+			var forkList []*model.Repository = nil
+			if rc.Config.IsInForgeMode() {
+				forkList, err = visibleForkList(rc, s)
+				if err != nil {
+					rc.ReportInternalError(fmt.Sprintf("Failed to retrieve fork list: %s", err), w, r)
+					return
+				}
+			}
+
 			LogTemplateError(rc.LoadTemplate("repository").Execute(w, templates.RepositoryModel{
 				Config: rc.Config,
 				Repository: s,
@@ -249,13 +258,16 @@ func bindRepositoryController(ctx *RouterContext) {
 				TreeFileList: treeFileList,
 				CommitInfo: commitInfo,
 				ComparisonInfo: compareInfo,
+				ForkList: forkList,
 			}))
 		},
 	))
 
 	if ctx.Config.IsInForgeMode() {
 		bindRepositoryForkController(ctx)
+		bindRepositorySyncController(ctx)
 		bindRepositoryPullRequestController(ctx)
+		bindRepositoryPullRequestResolveController(ctx)
 	}
 }
 
@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/drain"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/receipt"
 	. "github.com/GitusCodeForge/Gitus/routes"
@@ -81,7 +82,7 @@ func bindResetPasswordController(ctx *RouterContext) {
 				)
 				return
 			}
-			go func() {
+			drain.Go(func() {
 				rc.Mailer.SendPlainTextMail(
 					targetEmail,
 					fmt.Sprintf("Reset password instructions from %s", rc.Config.DepotName),
@@ -99,7 +100,7 @@ If this isn't you, you can simply ignore this message.`,
 						iid,
 					),
 				)
-			}()
+			})
 		}
 		rc.ReportRedirect("/", 0, "Request Recieved", "Your request of password reset has been received. If the info matches, an email would be sent to your email address; please proceed from there.", w, r)
 		},
@@ -215,6 +216,7 @@ If this isn't you, you can simply ignore this message.`,
 			}
 			rc.ReceiptSystem.CancelReceipt(rid)
 			rc.SessionInterface.RevokeAllSession(targetUserName)
+			rc.AuditAs(targetUserName, model.AUDIT_SESSION_REVOKE_ALL, AuditUserTarget(targetUserName), map[string]any{"reason": "password-reset"}, w, r)
 			rc.ReportRedirect("/login", 3, "Password Updated", "Your password has been updated.", w, r)
 		},
 	))
@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	. "github.com/GitusCodeForge/Gitus/routes"
+	"github.com/GitusCodeForge/Gitus/templates"
+)
+
+// the choices of token lifetime, in days; 0 means never expire.
+var accessTokenLifetimeList = []int{30, 90, 365, 0}
+
+// `newToken` is the token just created, which is only shown once.
+func renderAccessTokenSetting(rc *RouterContext, w http.ResponseWriter, r *http.Request, newToken string, errMsg string) {
+	tokenList, err := rc.DatabaseInterface.GetAllAccessTokenByUsername(rc.LoginInfo.UserName)
+	if err != nil {
+		rc.ReportInternalError(fmt.Sprintf("Failed to retrieve access tokens: %s", err), w, r)
+		return
+	}
+	LogTemplateError(rc.LoadTemplate("setting/access-token").Execute(w, &templates.SettingAccessTokenTemplateModel{
+		Config: rc.Config,
+		LoginInfo: rc.LoginInfo,
+		TokenList: tokenList,
+		LifetimeList: accessTokenLifetimeList,
+		NewToken: newToken,
+		ErrorMsg: errMsg,
+		Now: time.Now().Unix(),
+	}))
+}
+
+func bindSettingAccessTokenController(ctx *RouterContext) {
+	http.HandleFunc("GET /setting/access-token", UseMiddleware(
+		[]Middleware{Logged, LoginRequired, GlobalVisibility, ErrorGuard}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			renderAccessTokenSetting(rc, w, r, "", "")
+		},
+	))
+
+	http.HandleFunc("POST /setting/access-token", UseMiddleware(
+		[]Middleware{Logged, ValidPOSTRequestRequired,
+			LoginRequired, CSRFCheck, GlobalVisibility, ErrorGuard,
+		}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			un := rc.LoginInfo.UserName
+			name := strings.TrimSpace(r.Form.Get("name"))
+			switch r.Form.Get("type") {
+			case "create":
+				if len(name) <= 0 {
+					renderAccessTokenSetting(rc, w, r, "", "The name of the token cannot be empty.")
+					return
+				}
+				lifetime, err := strconv.Atoi(r.Form.Get("lifetime"))
+				if err != nil || lifetime < 0 {
+					renderAccessTokenSetting(rc, w, r, "", "Invalid expiration.")
+					return
+				}
+				tokenList, err := rc.DatabaseInterface.GetAllAccessTokenByUsername(un)
+				if err != nil {
+					rc.ReportInternalError(err.Error(), w, r)
+					return
+				}
+				for _, k := range tokenList {
+					if k.Name == name {
+						renderAccessTokenSetting(rc, w, r, "", "A token with the same name already exists.")
+						return
+					}
+				}
+				tokenString, err := model.NewAccessTokenString()
+				if err != nil {
+					rc.ReportInternalError(fmt.Sprintf("Failed to generate token: %s", err), w, r)
+					return
+				}
+				now := time.Now()
+				var expireTime int64 = 0
+				if lifetime > 0 { expireTime = now.AddDate(0, 0, lifetime).Unix() }
+				err = rc.DatabaseInterface.RegisterAccessToken(&model.AccessToken{
+					UserName: un,
+					Name: name,
+					TokenHash: model.HashAccessToken(tokenString),
+					RegisterTime: now.Unix(),
+					LastUsedTime: 0,
+					ExpireTime: expireTime,
+				})
+				if err != nil {
+					rc.ReportInternalError(fmt.Sprintf("Failed to save access token: %s", err), w, r)
+					return
+				}
+				rc.Audit(model.AUDIT_ACCESS_TOKEN_ADD, AuditUserTarget(un), map[string]any{"token": name, "expireTime": expireTime}, w, r)
+				renderAccessTokenSetting(rc, w, r, tokenString, "")
+				return
+			case "remove":
+				err := rc.DatabaseInterface.RemoveAccessToken(un, name)
+				if err != nil {
+					rc.ReportInternalError(err.Error(), w, r)
+					return
+				}
+				rc.Audit(model.AUDIT_ACCESS_TOKEN_REMOVE, AuditUserTarget(un), map[string]any{"token": name}, w, r)
+				rc.ReportRedirect("/setting/access-token", 3, "Access Token Removed", "Your access token has been removed.", w, r)
+				return
+			default:
+				rc.ReportNormalError("Invalid request", w, r)
+				return
+			}
+		},
+	))
+}
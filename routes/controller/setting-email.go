@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/drain"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/receipt"
 	. "github.com/GitusCodeForge/Gitus/routes"
 	"github.com/GitusCodeForge/Gitus/templates"
@@ -102,9 +103,9 @@ We wish you all the best in your future endeavours.
 
 %s
 `, rc.LoginInfo.UserName, rc.Config.DepotName, rc.Config.ProperHTTPHostName(), rid, rc.Config.DepotName)
-			go func() {
+			drain.Go(func() {
 				rc.Mailer.SendPlainTextMail(email, title, body)
-			}()
+			})
 			rc.ReportRedirect("/setting/email", 3, "Verification Email Sent", "Please follow the instruction in the email to verify this email.", w, r)
 		},
 	))
@@ -72,6 +72,7 @@ func bindSettingGPGController(ctx *RouterContext) {
 				rc.ReportInternalError(err.Error(), w, r)
 				return
 			}
+			rc.Audit(model.AUDIT_GPG_KEY_ADD, AuditUserTarget(un), map[string]any{"key": keyName}, w, r)
 			FoundAt(w, "/setting/gpg")
 		},
 	))
@@ -108,6 +109,7 @@ func bindSettingGPGController(ctx *RouterContext) {
 				rc.ReportInternalError(err.Error(), w, r)
 				return
 			}
+			rc.Audit(model.AUDIT_GPG_KEY_REMOVE, AuditUserTarget(un), map[string]any{"key": r.PathValue("keyName")}, w, r)
 			FoundAt(w, "/setting/gpg")
 		},
 	))
@@ -164,6 +166,7 @@ func bindSettingGPGController(ctx *RouterContext) {
 				rc.ReportInternalError(err.Error(), w, r)
 				return
 			}
+			rc.Audit(model.AUDIT_GPG_KEY_UPDATE, AuditUserTarget(un), map[string]any{"key": keyName}, w, r)
 			rc.ReportRedirect(fmt.Sprintf("/setting/gpg/%s/edit", keyName), 3, "Updated", "Updated.", w, r)
 		},
 	))
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	. "github.com/GitusCodeForge/Gitus/routes"
+	"github.com/GitusCodeForge/Gitus/templates"
+)
+
+func bindSettingLinkedAccountController(ctx *RouterContext) {
+	http.HandleFunc("GET /setting/linked-account", UseMiddleware(
+		[]Middleware{Logged, LoginRequired, GlobalVisibility, ErrorGuard}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			user, err := rc.DatabaseInterface.GetUserByName(rc.LoginInfo.UserName)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed while retrieving user: %s\n", err), w, r)
+				return
+			}
+			linkList, err := rc.DatabaseInterface.GetAllOIDCLinkOfUser(user.Name)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed while retrieving linked accounts: %s\n", err), w, r)
+				return
+			}
+			linked := make(map[string]*model.OIDCLink)
+			for _, k := range linkList { linked[k.ProviderId] = k }
+			LogTemplateError(rc.LoadTemplate("setting/linked-account").Execute(w, &templates.SettingLinkedAccountTemplateModel{
+				Config: rc.Config,
+				LoginInfo: rc.LoginInfo,
+				User: user,
+				LinkedAccount: linked,
+			}))
+		},
+	))
+
+	http.HandleFunc("POST /setting/linked-account", UseMiddleware(
+		[]Middleware{Logged, ValidPOSTRequestRequired,
+			LoginRequired, CSRFCheck, GlobalVisibility, ErrorGuard,
+		}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			switch r.Form.Get("type") {
+			case "unlink":
+				providerId := strings.TrimSpace(r.Form.Get("provider"))
+				err := rc.DatabaseInterface.RemoveOIDCLink(rc.LoginInfo.UserName, providerId)
+				if err != nil {
+					rc.ReportInternalError(err.Error(), w, r)
+					return
+				}
+				rc.ReportRedirect("/setting/linked-account", 3, "Account Unlinked", "Your account has been unlinked.", w, r)
+				return
+			default:
+				rc.ReportNormalError("Invalid request", w, r)
+				return
+			}
+		},
+	))
+}
@@ -5,10 +5,40 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	"github.com/GitusCodeForge/Gitus/pkg/qrcode"
+	"github.com/GitusCodeForge/Gitus/pkg/totp"
 	. "github.com/GitusCodeForge/Gitus/routes"
 	"github.com/GitusCodeForge/Gitus/templates"
 )
 
+func renderPrivacySetting(rc *RouterContext, w http.ResponseWriter, user *model.GitusUser, errType string, errMsg string, newRecoveryCodeList []string) {
+	keyURI := ""
+	if !user.TFAConfig.TOTP.Enable && user.TFAConfig.TOTP.Secret != "" {
+		keyURI = totp.KeyURI(rc.Config.DepotName, user.Name, user.TFAConfig.TOTP.Secret)
+	}
+	LogTemplateError(rc.LoadTemplate("setting/privacy").Execute(w, &templates.SettingPrivacyTemplateModel{
+		Config: rc.Config,
+		User: user,
+		LoginInfo: rc.LoginInfo,
+		ErrorMsg: struct{Type string; Message string}{
+			Type: errType,
+			Message: errMsg,
+		},
+		TOTPKeyURI: keyURI,
+		NewRecoveryCodeList: newRecoveryCodeList,
+	}))
+}
+
+// returns a non-empty message if the user is not allowed to turn off
+// 2fa because of the namespaces they are a member of.
+func check2FARequirement(rc *RouterContext, user *model.GitusUser) (string, error) {
+	if user.TFAConfig.IsEnabled() { return "", nil }
+	nsList, err := namespaceRequiring2FA(rc, user.Name)
+	if err != nil { return "", err }
+	if len(nsList) <= 0 { return "", nil }
+	return fmt.Sprintf("The following namespace(s) require their members to have two-factor authentication enabled: %s.", strings.Join(nsList, ", ")), nil
+}
 
 func bindSettingPrivacyController(ctx *RouterContext) {
 	http.HandleFunc("GET /setting/privacy", UseMiddleware(
@@ -19,14 +49,35 @@ func bindSettingPrivacyController(ctx *RouterContext) {
 				rc.ReportInternalError(fmt.Sprintf("Failed while retrieving user: %s\n", err), w, r)
 				return
 			}
-			LogTemplateError(rc.LoadTemplate("setting/privacy").Execute(w, &templates.SettingPrivacyTemplateModel{
-				Config: rc.Config,
-				User: user,
-				LoginInfo: rc.LoginInfo,
-			}))
+			renderPrivacySetting(rc, w, user, "", "", nil)
+		},
+	))
+
+	// the qr code is only available during enrollment, i.e. when the
+	// secret is generated but not confirmed yet.
+	http.HandleFunc("GET /setting/privacy/totp-qr", UseMiddleware(
+		[]Middleware{Logged, LoginRequired, ErrorGuard}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			user, err := rc.DatabaseInterface.GetUserByName(rc.LoginInfo.UserName)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed while retrieving user: %s\n", err), w, r)
+				return
+			}
+			if user.TFAConfig.TOTP.Enable || user.TFAConfig.TOTP.Secret == "" {
+				rc.ReportNotFound("totp-qr", "File", "Depot", w, r)
+				return
+			}
+			qr, err := qrcode.Encode([]byte(totp.KeyURI(rc.Config.DepotName, user.Name, user.TFAConfig.TOTP.Secret)))
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to generate QR code: %s", err), w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "image/png")
+			w.Header().Set("Cache-Control", "no-store")
+			qr.WritePNG(w, 4)
 		},
 	))
-	
+
 	http.HandleFunc("POST /setting/privacy", UseMiddleware(
 		[]Middleware{Logged, ValidPOSTRequestRequired,
 			LoginRequired, CSRFCheck, ErrorGuard,
@@ -43,6 +94,147 @@ func bindSettingPrivacyController(ctx *RouterContext) {
 				case "email":
 					enable := len(strings.TrimSpace(r.Form.Get("email-enable"))) > 0
 					user.TFAConfig.Email.Enable = enable
+					msg, err := check2FARequirement(rc, user)
+					if err != nil {
+						rc.ReportInternalError(err.Error(), w, r)
+						return
+					}
+					if len(msg) > 0 {
+						user.TFAConfig.Email.Enable = true
+						renderPrivacySetting(rc, w, user, "email", msg, nil)
+						return
+					}
+					err = rc.DatabaseInterface.UpdateUserInfo(rc.LoginInfo.UserName, user)
+					if err != nil {
+						rc.ReportInternalError(err.Error(), w, r)
+						return
+					}
+					rc.ReportRedirect("/setting/privacy", 5, "Setting Updated", "Your configuration about two-factor authentication has been updated.", w, r)
+					return
+				case "totp-begin":
+					if user.TFAConfig.TOTP.Enable {
+						renderPrivacySetting(rc, w, user, "totp", "Authenticator app is already set up.", nil)
+						return
+					}
+					secret, err := totp.NewSecret()
+					if err != nil {
+						rc.ReportInternalError(fmt.Sprintf("Failed to generate secret: %s", err), w, r)
+						return
+					}
+					user.TFAConfig.TOTP.Secret = secret
+					user.TFAConfig.TOTP.LastUsedStep = 0
+					err = rc.DatabaseInterface.UpdateUserInfo(rc.LoginInfo.UserName, user)
+					if err != nil {
+						rc.ReportInternalError(err.Error(), w, r)
+						return
+					}
+					FoundAt(w, "/setting/privacy")
+					return
+				case "totp-cancel":
+					if !user.TFAConfig.TOTP.Enable {
+						user.TFAConfig.TOTP.Secret = ""
+						err = rc.DatabaseInterface.UpdateUserInfo(rc.LoginInfo.UserName, user)
+						if err != nil {
+							rc.ReportInternalError(err.Error(), w, r)
+							return
+						}
+					}
+					FoundAt(w, "/setting/privacy")
+					return
+				case "totp-confirm":
+					if user.TFAConfig.TOTP.Enable || user.TFAConfig.TOTP.Secret == "" {
+						rc.ReportNormalError("Invalid request", w, r)
+						return
+					}
+					ok, err := useTOTPCode(user, r.Form.Get("code"))
+					if err != nil {
+						rc.ReportInternalError(err.Error(), w, r)
+						return
+					}
+					if !ok {
+						renderPrivacySetting(rc, w, user, "totp", "Invalid code. Please check the time setting of your device and try again.", nil)
+						return
+					}
+					user.TFAConfig.TOTP.Enable = true
+					var codeList []string = nil
+					if len(user.TFAConfig.RecoveryCode) <= 0 {
+						var hashList []string
+						codeList, hashList, err = newRecoveryCodeList(rc)
+						if err != nil {
+							rc.ReportInternalError(fmt.Sprintf("Failed to generate recovery codes: %s", err), w, r)
+							return
+						}
+						user.TFAConfig.RecoveryCode = hashList
+					}
+					err = rc.DatabaseInterface.UpdateUserInfo(rc.LoginInfo.UserName, user)
+					if err != nil {
+						rc.ReportInternalError(err.Error(), w, r)
+						return
+					}
+					renderPrivacySetting(rc, w, user, "totp", "Authenticator app has been set up.", codeList)
+					return
+				case "totp-disable":
+					if !user.TFAConfig.TOTP.Enable {
+						rc.ReportNormalError("Invalid request", w, r)
+						return
+					}
+					code := strings.TrimSpace(r.Form.Get("code"))
+					ok, err := useTOTPCode(user, code)
+					if err != nil {
+						rc.ReportInternalError(err.Error(), w, r)
+						return
+					}
+					if !ok { ok = useRecoveryCode(user, code) }
+					if !ok {
+						renderPrivacySetting(rc, w, user, "totp", "Invalid code.", nil)
+						return
+					}
+					user.TFAConfig.TOTP.Enable = false
+					msg, err := check2FARequirement(rc, user)
+					if err != nil {
+						rc.ReportInternalError(err.Error(), w, r)
+						return
+					}
+					if len(msg) > 0 {
+						user.TFAConfig.TOTP.Enable = true
+						renderPrivacySetting(rc, w, user, "totp", msg, nil)
+						return
+					}
+					user.TFAConfig.TOTP.Secret = ""
+					user.TFAConfig.TOTP.LastUsedStep = 0
+					user.TFAConfig.RequireForSSHKey = false
+					err = rc.DatabaseInterface.UpdateUserInfo(rc.LoginInfo.UserName, user)
+					if err != nil {
+						rc.ReportInternalError(err.Error(), w, r)
+						return
+					}
+					rc.ReportRedirect("/setting/privacy", 5, "Setting Updated", "Authenticator app has been removed from your account.", w, r)
+					return
+				case "recovery-generate":
+					if !user.TFAConfig.IsEnabled() {
+						renderPrivacySetting(rc, w, user, "recovery", "Please enable two-factor authentication first.", nil)
+						return
+					}
+					codeList, hashList, err := newRecoveryCodeList(rc)
+					if err != nil {
+						rc.ReportInternalError(fmt.Sprintf("Failed to generate recovery codes: %s", err), w, r)
+						return
+					}
+					user.TFAConfig.RecoveryCode = hashList
+					err = rc.DatabaseInterface.UpdateUserInfo(rc.LoginInfo.UserName, user)
+					if err != nil {
+						rc.ReportInternalError(err.Error(), w, r)
+						return
+					}
+					renderPrivacySetting(rc, w, user, "recovery", "New recovery codes have been generated. The old ones are no longer valid.", codeList)
+					return
+				case "ssh-key":
+					enable := len(strings.TrimSpace(r.Form.Get("ssh-key-require"))) > 0
+					if enable && !user.TFAConfig.TOTP.Enable {
+						renderPrivacySetting(rc, w, user, "ssh-key", "Please set up an authenticator app first.", nil)
+						return
+					}
+					user.TFAConfig.RequireForSSHKey = enable
 					err = rc.DatabaseInterface.UpdateUserInfo(rc.LoginInfo.UserName, user)
 					if err != nil {
 						rc.ReportInternalError(err.Error(), w, r)
@@ -13,6 +13,10 @@ import (
 )
 
 
+func requireSecondFactorForSSHKey(u *model.GitusUser) bool {
+	return u.TFAConfig.RequireForSSHKey && u.TFAConfig.TOTP.Enable
+}
+
 func bindSettingSSHController(ctx *RouterContext) {
 	http.HandleFunc("GET /setting/ssh", UseMiddleware(
 		[]Middleware{Logged, LoginRequired, GlobalVisibility, ErrorGuard}, ctx,
@@ -27,10 +31,16 @@ func bindSettingSSHController(ctx *RouterContext) {
 			rc.ReportInternalError(fmt.Sprintf("Failed to retrieve authentication key: %s", err), w, r)
 			return
 		}
+		u, err := rc.DatabaseInterface.GetUserByName(un)
+		if err != nil {
+			rc.ReportInternalError(fmt.Sprintf("Failed to get user: %s", err), w, r)
+			return
+		}
 		LogTemplateError(rc.LoadTemplate("setting/ssh-key").Execute(w, templates.SettingSSHKeyTemplateModel{
 			Config: rc.Config,
 			LoginInfo: rc.LoginInfo,
 			KeyList: s,
+			Require2FA: requireSecondFactorForSSHKey(u),
 		}))
 		},
 	))
@@ -63,6 +73,7 @@ func bindSettingSSHController(ctx *RouterContext) {
 					Config: rc.Config,
 					LoginInfo: rc.LoginInfo,
 					KeyList: keyList,
+					Require2FA: requireSecondFactorForSSHKey(u),
 					ErrorMsg: struct{Type string; Message string}{
 						Type: "",
 						Message: "Invalid confirmation password",
@@ -70,12 +81,33 @@ func bindSettingSSHController(ctx *RouterContext) {
 				}))
 				return
 			}
+			if requireSecondFactorForSSHKey(u) {
+				ok, err := checkSecondFactor(rc, u, r.Form.Get("2fa-code"))
+				if err != nil {
+					rc.ReportInternalError(err.Error(), w, r)
+					return
+				}
+				if !ok {
+					LogTemplateError(rc.LoadTemplate("setting/ssh-key").Execute(w, templates.SettingSSHKeyTemplateModel{
+						Config: rc.Config,
+						LoginInfo: rc.LoginInfo,
+						KeyList: keyList,
+						Require2FA: true,
+						ErrorMsg: struct{Type string; Message string}{
+							Type: "",
+							Message: "Invalid two-factor authentication code",
+						},
+					}))
+					return
+				}
+			}
 			keyText := strings.TrimSpace(r.Form.Get("key-text"))
 			if len(strings.TrimSpace(keyText)) <= 0 {
 				LogTemplateError(rc.LoadTemplate("setting/ssh-key").Execute(w, templates.SettingSSHKeyTemplateModel{
 					Config: rc.Config,
 					LoginInfo: rc.LoginInfo,
 					KeyList: keyList,
+					Require2FA: requireSecondFactorForSSHKey(u),
 					ErrorMsg: struct{Type string; Message string}{
 						Type: "",
 						Message: "Invalid key text",
@@ -96,6 +128,7 @@ func bindSettingSSHController(ctx *RouterContext) {
 				rc.ReportInternalError(err.Error(), w, r)
 				return
 			}
+			rc.Audit(model.AUDIT_SSH_KEY_ADD, AuditUserTarget(un), AuditSSHKeyDiff(keyName, keyText), w, r)
 			rc.SSHKeyManagingContext.AddAuthorizedKey(un, keyName, keyText)
 			err = rc.SSHKeyManagingContext.Sync()
 			if err != nil {
@@ -137,6 +170,7 @@ func bindSettingSSHController(ctx *RouterContext) {
 				rc.ReportInternalError(err.Error(), w, r)
 				return
 			}
+			rc.Audit(model.AUDIT_SSH_KEY_REMOVE, AuditUserTarget(un), map[string]any{"key": keyName}, w, r)
 			rc.SSHKeyManagingContext.RemoveAuthorizedKey(un, keyName)
 			err = rc.SSHKeyManagingContext.Sync()
 			if err != nil {
@@ -162,10 +196,16 @@ func bindSettingSSHController(ctx *RouterContext) {
 				rc.ReportInternalError(err.Error(), w, r)
 				return
 			}
+			u, err := rc.DatabaseInterface.GetUserByName(un)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to get user: %s", err), w, r)
+				return
+			}
 			LogTemplateError(rc.LoadTemplate("setting/edit-ssh-key").Execute(w, &templates.SettingEditSSHKeyTemplateModel{
 				Config: rc.Config,
 				LoginInfo: rc.LoginInfo,
 				Key: k,
+				Require2FA: requireSecondFactorForSSHKey(u),
 			}))
 		},
 	))
@@ -190,12 +230,29 @@ func bindSettingSSHController(ctx *RouterContext) {
 				rc.ReportRedirect(fmt.Sprintf("/setting/ssh/%s/edit", keyName), 3, "Password Mismatch", "The password you've provided does not match. Please try again.", w, r)
 				return
 			}
+			u, err := rc.DatabaseInterface.GetUserByName(un)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed to get user: %s", err), w, r)
+				return
+			}
+			if requireSecondFactorForSSHKey(u) {
+				ok, err := checkSecondFactor(rc, u, r.Form.Get("2fa-code"))
+				if err != nil {
+					rc.ReportInternalError(err.Error(), w, r)
+					return
+				}
+				if !ok {
+					rc.ReportRedirect(fmt.Sprintf("/setting/ssh/%s/edit", keyName), 3, "Invalid Code", "The two-factor authentication code you've provided is invalid. Please try again.", w, r)
+					return
+				}
+			}
 			keyText := r.Form.Get("key-text")
 			err = rc.DatabaseInterface.UpdateAuthKey(un, keyName, keyText)
 			if err != nil {
 				rc.ReportInternalError(err.Error(), w, r)
 				return
 			}
+			rc.Audit(model.AUDIT_SSH_KEY_UPDATE, AuditUserTarget(un), AuditSSHKeyDiff(keyName, keyText), w, r)
 			rc.ReportRedirect(fmt.Sprintf("/setting/ssh/%s/edit", keyName), 3, "Updated", "Updated.", w, r)
 		},
 	))
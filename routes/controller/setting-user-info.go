@@ -145,6 +145,7 @@ func bindSettingController(ctx *RouterContext) {
 				ctx.DatabaseInterface.UpdateUserPassword(targetUsername, string(newpwh))
 			}
 			ctx.SessionInterface.RevokeAllSession(targetUsername)
+			rc.Audit(model.AUDIT_SESSION_REVOKE_ALL, AuditUserTarget(targetUsername), map[string]any{"reason": r.Form.Get("type")}, w, r)
 			LogTemplateError(ctx.LoadTemplate("setting/user-info").Execute(w, templates.SettingUserInfoTemplateModel{
 				User: user,
 				Config: ctx.Config,
@@ -0,0 +1,200 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	"github.com/GitusCodeForge/Gitus/pkg/webauthn"
+	. "github.com/GitusCodeForge/Gitus/routes"
+	"github.com/GitusCodeForge/Gitus/templates"
+)
+
+func webAuthnRegistrationKey(username string) string {
+	return "webauthn-reg:" + username
+}
+
+func renderWebAuthnSetting(rc *RouterContext, w http.ResponseWriter, r *http.Request, user *model.GitusUser, errMsg string) {
+	credList, err := rc.DatabaseInterface.GetAllWebAuthnCredentialByUsername(user.Name)
+	if err != nil {
+		rc.ReportInternalError(fmt.Sprintf("Failed to retrieve security keys: %s", err), w, r)
+		return
+	}
+	m := &templates.SettingWebAuthnTemplateModel{
+		Config: rc.Config,
+		LoginInfo: rc.LoginInfo,
+		User: user,
+		CredentialList: credList,
+		UseJavascript: user.WebsitePreference.UseJavascript,
+		ErrorMsg: errMsg,
+	}
+	// a new challenge is issued every time the page is rendered; only
+	// the latest one is accepted.
+	rp, err := webAuthnRelyingParty(rc)
+	if err == nil && m.UseJavascript && rc.ConfirmCodeManager != nil {
+		challenge, err := webauthn.NewChallenge()
+		if err != nil {
+			rc.ReportInternalError(fmt.Sprintf("Failed to generate challenge: %s", err), w, r)
+			return
+		}
+		rc.ConfirmCodeManager.Register(webAuthnRegistrationKey(user.Name), challenge, 10 * time.Minute)
+		m.Available = true
+		m.Challenge = challenge
+		m.RPID = rp.ID
+		m.UserHandle = webAuthnUserHandle(user.Name)
+		idList := make([]string, 0, len(credList))
+		for _, k := range credList { idList = append(idList, k.CredentialId) }
+		m.ExcludeList = strings.Join(idList, ",")
+	}
+	LogTemplateError(rc.LoadTemplate("setting/webauthn").Execute(w, m))
+}
+
+func bindSettingWebAuthnController(ctx *RouterContext) {
+	http.HandleFunc("GET /setting/webauthn", UseMiddleware(
+		[]Middleware{Logged, LoginRequired, GlobalVisibility, ErrorGuard}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			user, err := rc.DatabaseInterface.GetUserByName(rc.LoginInfo.UserName)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed while retrieving user: %s\n", err), w, r)
+				return
+			}
+			renderWebAuthnSetting(rc, w, r, user, "")
+		},
+	))
+
+	http.HandleFunc("POST /setting/webauthn", UseMiddleware(
+		[]Middleware{Logged, ValidPOSTRequestRequired,
+			LoginRequired, CSRFCheck, GlobalVisibility, ErrorGuard,
+		}, ctx,
+		func(rc *RouterContext, w http.ResponseWriter, r *http.Request) {
+			user, err := rc.DatabaseInterface.GetUserByName(rc.LoginInfo.UserName)
+			if err != nil {
+				rc.ReportInternalError(fmt.Sprintf("Failed while retrieving user: %s\n", err), w, r)
+				return
+			}
+			switch r.Form.Get("type") {
+			case "register":
+				rp, err := webAuthnRelyingParty(rc)
+				if err != nil {
+					renderWebAuthnSetting(rc, w, r, user, err.Error())
+					return
+				}
+				if rc.ConfirmCodeManager == nil {
+					rc.ReportInternalError("Confirm code manager not initialized. Please contact site owner to fix this problem... ", w, r)
+					return
+				}
+				challenge, ok := rc.ConfirmCodeManager.Get(webAuthnRegistrationKey(user.Name))
+				if !ok || len(challenge) <= 0 {
+					renderWebAuthnSetting(rc, w, r, user, "The registration has expired. Please try again.")
+					return
+				}
+				rc.ConfirmCodeManager.Register(webAuthnRegistrationKey(user.Name), "", time.Second)
+				name := strings.TrimSpace(r.Form.Get("name"))
+				if len(name) <= 0 { name = "Security key" }
+				passkey := len(r.Form.Get("passkey")) > 0
+				clientData, err := webauthn.DecodeField(r.Form.Get("client-data"))
+				if err != nil {
+					renderWebAuthnSetting(rc, w, r, user, "Invalid request. JavaScript is required for registering security keys.")
+					return
+				}
+				attestationObject, err := webauthn.DecodeField(r.Form.Get("attestation-object"))
+				if err != nil {
+					renderWebAuthnSetting(rc, w, r, user, "Invalid request. JavaScript is required for registering security keys.")
+					return
+				}
+				// passkeys replace both the password & the second
+				// factor, so user verification (pin, biometrics) is a
+				// must for them.
+				newCred, err := rp.VerifyRegistration(challenge, clientData, attestationObject, passkey)
+				if err != nil {
+					renderWebAuthnSetting(rc, w, r, user, fmt.Sprintf("Failed to verify security key: %s", err))
+					return
+				}
+				credId := webauthn.Encoding.EncodeToString(newCred.Id)
+				_, err = rc.DatabaseInterface.GetWebAuthnCredential(credId)
+				if err == nil {
+					renderWebAuthnSetting(rc, w, r, user, "This security key has already been registered.")
+					return
+				}
+				if err != db.ErrEntityNotFound {
+					rc.ReportInternalError(err.Error(), w, r)
+					return
+				}
+				now := time.Now().Unix()
+				err = rc.DatabaseInterface.RegisterWebAuthnCredential(&model.WebAuthnCredential{
+					UserName: user.Name,
+					CredentialId: credId,
+					Name: name,
+					PublicKey: newCred.PublicKey,
+					SignCount: int64(newCred.SignCount),
+					Passkey: passkey,
+					RegisterTime: now,
+					LastUsedTime: 0,
+				})
+				if err != nil {
+					rc.ReportInternalError(fmt.Sprintf("Failed to save security key: %s", err), w, r)
+					return
+				}
+				if !user.TFAConfig.WebAuthn.Enable {
+					user.TFAConfig.WebAuthn.Enable = true
+					err = rc.DatabaseInterface.UpdateUserInfo(user.Name, user)
+					if err != nil {
+						rc.ReportInternalError(err.Error(), w, r)
+						return
+					}
+				}
+				rc.ReportRedirect("/setting/webauthn", 3, "Security Key Added", "Your security key has been registered.", w, r)
+				return
+			case "remove":
+				credId := strings.TrimSpace(r.Form.Get("credential-id"))
+				credList, err := rc.DatabaseInterface.GetAllWebAuthnCredentialByUsername(user.Name)
+				if err != nil {
+					rc.ReportInternalError(err.Error(), w, r)
+					return
+				}
+				found := false
+				for _, k := range credList {
+					if k.CredentialId == credId { found = true; break }
+				}
+				if !found {
+					rc.ReportNotFound(credId, "Security Key", "Depot", w, r)
+					return
+				}
+				if len(credList) == 1 {
+					user.TFAConfig.WebAuthn.Enable = false
+					msg, err := check2FARequirement(rc, user)
+					if err != nil {
+						rc.ReportInternalError(err.Error(), w, r)
+						return
+					}
+					if len(msg) > 0 {
+						user.TFAConfig.WebAuthn.Enable = true
+						renderWebAuthnSetting(rc, w, r, user, msg)
+						return
+					}
+				}
+				err = rc.DatabaseInterface.RemoveWebAuthnCredential(user.Name, credId)
+				if err != nil {
+					rc.ReportInternalError(err.Error(), w, r)
+					return
+				}
+				if len(credList) == 1 {
+					err = rc.DatabaseInterface.UpdateUserInfo(user.Name, user)
+					if err != nil {
+						rc.ReportInternalError(err.Error(), w, r)
+						return
+					}
+				}
+				rc.ReportRedirect("/setting/webauthn", 3, "Security Key Removed", "Your security key has been removed.", w, r)
+				return
+			default:
+				rc.ReportNormalError("Invalid request", w, r)
+				return
+			}
+		},
+	))
+}
+
@@ -215,6 +215,7 @@ func bindSnippetController(ctx *RouterContext) {
 			}
 			filename := r.Form.Get("filename")
 			content := r.Form.Get("content")
+			if !rc.CheckQuota("", username, &model.QuotaUsage{ SnippetSize: int64(len(content)) }, w, r) { return }
 			sn, err := rc.DatabaseInterface.GetSnippet(username, name)
 			if err != nil {
 				rc.ReportInternalError(fmt.Sprintf("Failed to get snippet: %s", err), w, r)
@@ -284,6 +285,7 @@ func bindSnippetController(ctx *RouterContext) {
 				return
 			}
 			content := r.Form.Get("content")
+			if !rc.CheckQuota("", username, &model.QuotaUsage{ SnippetSize: int64(len(content)) }, w, r) { return }
 			sn.SetFile(filePath, content)
 			err = sn.SyncFile(rc.Config.SnippetRoot, filePath)
 			if err != nil {
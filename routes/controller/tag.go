@@ -234,6 +234,8 @@ func bindTagController(ctx *RouterContext) {
 				if len(permaLink) <= 0 {
 					permaLink = fmt.Sprintf("/repo/%s/blob/%s", rfn, bobj.Id)
 				}
+				lfsPointer := resolveLFSPointer(rc, repo, bobj.Data, "")
+				if lfsPointer != nil { templateType = "file-text" }
 				str := string(bobj.Data)
 				coloredStr, err := colorSyntax("", str)
 				if err == nil { str = coloredStr }
@@ -248,6 +250,7 @@ func bindTagController(ctx *RouterContext) {
 						FileLineCount: strings.Count(str, "\n"),
 						FileContent: str,
 					},
+					LFSPointer: lfsPointer,
 					PermaLink: permaLink,
 					TreePath: nil,
 					CommitInfo: commitInfo,
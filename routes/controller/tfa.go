@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	"github.com/GitusCodeForge/Gitus/pkg/totp"
+	"github.com/GitusCodeForge/Gitus/pkg/webauthn"
+	. "github.com/GitusCodeForge/Gitus/routes"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// helpers for two-factor authentication. see docs/2fa.org.
+
+const recoveryCodeCount = 10
+const recoveryCodeLength = 10
+
+// the characters that are easily confused with each other (0/O, 1/I/L)
+// are left out.
+const recoveryCodeCharset = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+// returns the codes shown to the user (formatted as XXXXX-XXXXX) and
+// their hashes to be stored.
+func newRecoveryCodeList(rc *RouterContext) ([]string, []string, error) {
+	codeList := make([]string, 0, recoveryCodeCount)
+	hashList := make([]string, 0, recoveryCodeCount)
+	rmax := big.NewInt(int64(len(recoveryCodeCharset)))
+	for range recoveryCodeCount {
+		b := make([]byte, 0, recoveryCodeLength)
+		for range recoveryCodeLength {
+			n, err := rand.Int(rand.Reader, rmax)
+			if err != nil { return nil, nil, err }
+			b = append(b, recoveryCodeCharset[n.Int64()])
+		}
+		h, err := bcrypt.GenerateFromPassword(b, rc.Config.PasswordHashStrength)
+		if err != nil { return nil, nil, err }
+		half := recoveryCodeLength / 2
+		codeList = append(codeList, string(b[:half]) + "-" + string(b[half:]))
+		hashList = append(hashList, string(h))
+	}
+	return codeList, hashList, nil
+}
+
+func normalizeRecoveryCode(s string) string {
+	s = strings.ToUpper(s)
+	s = strings.ReplaceAll(s, "-", "")
+	s = strings.ReplaceAll(s, " ", "")
+	return s
+}
+
+// checks `code` against the user's unused recovery codes. the matched
+// one is removed from `user`; the caller needs to save the user.
+func useRecoveryCode(user *model.GitusUser, code string) bool {
+	code = normalizeRecoveryCode(code)
+	if len(code) != recoveryCodeLength { return false }
+	for i, h := range user.TFAConfig.RecoveryCode {
+		if bcrypt.CompareHashAndPassword([]byte(h), []byte(code)) == nil {
+			user.TFAConfig.RecoveryCode = slices.Delete(user.TFAConfig.RecoveryCode, i, i+1)
+			return true
+		}
+	}
+	return false
+}
+
+// checks `code` against the user's totp secret. the time step of the
+// code is recorded in `user` to prevent replay; the caller needs to
+// save the user.
+func useTOTPCode(user *model.GitusUser, code string) (bool, error) {
+	if user.TFAConfig.TOTP.Secret == "" { return false, nil }
+	step, err := totp.Validate(user.TFAConfig.TOTP.Secret, code, time.Now(), user.TFAConfig.TOTP.LastUsedStep)
+	if err != nil { return false, err }
+	if step < 0 { return false, nil }
+	user.TFAConfig.TOTP.LastUsedStep = step
+	return true, nil
+}
+
+// accepts either a totp code (if totp is enabled) or a recovery
+// code. the user is saved when the code is accepted.
+func checkSecondFactor(rc *RouterContext, user *model.GitusUser, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if len(code) <= 0 { return false, nil }
+	ok := false
+	if user.TFAConfig.TOTP.Enable {
+		var err error
+		ok, err = useTOTPCode(user, code)
+		if err != nil { return false, err }
+	}
+	if !ok { ok = useRecoveryCode(user, code) }
+	if !ok { return false, nil }
+	err := rc.DatabaseInterface.UpdateUserInfo(user.Name, user)
+	if err != nil { return false, err }
+	return true, nil
+}
+
+// returns the names of the namespaces that require 2fa and that the
+// user is a member of.
+func namespaceRequiring2FA(rc *RouterContext, username string) ([]string, error) {
+	nsList, err := rc.DatabaseInterface.GetAllComprisingNamespace(username)
+	if err != nil { return nil, err }
+	res := make([]string, 0)
+	for k, ns := range nsList {
+		if ns.Owner == username { continue }
+		if !ns.ACL.Requires2FA() { continue }
+		if ns.ACL.GetUserPrivilege(username) == nil { continue }
+		res = append(res, k)
+	}
+	slices.Sort(res)
+	return res, nil
+}
+
+var ErrWebAuthnNotAvailable = errors.New("WebAuthn requires the host name of this site to be configured")
+
+// the relying party id is the host name of the site, and the origin
+// is the configured http host.
+func webAuthnRelyingParty(rc *RouterContext) (*webauthn.RelyingParty, error) {
+	origin := rc.Config.ProperHTTPHostName()
+	if len(origin) <= 0 { return nil, ErrWebAuthnNotAvailable }
+	u, err := url.Parse(origin)
+	if err != nil || len(u.Hostname()) <= 0 { return nil, ErrWebAuthnNotAvailable }
+	return &webauthn.RelyingParty{
+		ID: u.Hostname(),
+		Origin: u.Scheme + "://" + u.Host,
+	}, nil
+}
+
+// the user handle of a user is their username. user handles are
+// returned by passkeys so that we can double-check the owner of the
+// credential.
+func webAuthnUserHandle(username string) string {
+	return webauthn.Encoding.EncodeToString([]byte(username))
+}
+
+// verifies an assertion (i.e. the response of
+// navigator.credentials.get()) posted by webauthn.js against the
+// stored credential & updates its usage. `username` is checked
+// against the owner of the credential when it's not empty.
+func verifyWebAuthnAssertion(rc *RouterContext, r *http.Request, challenge string, username string, requireUserVerification bool) (*model.WebAuthnCredential, error) {
+	rp, err := webAuthnRelyingParty(rc)
+	if err != nil { return nil, err }
+	credentialId := strings.TrimSpace(r.Form.Get("credential-id"))
+	cred, err := rc.DatabaseInterface.GetWebAuthnCredential(credentialId)
+	if err != nil { return nil, err }
+	if len(username) > 0 && cred.UserName != username { return nil, db.ErrEntityNotFound }
+	userHandle := strings.TrimSpace(r.Form.Get("user-handle"))
+	if len(userHandle) > 0 && userHandle != webAuthnUserHandle(cred.UserName) {
+		return nil, db.ErrEntityNotFound
+	}
+	clientData, err := webauthn.DecodeField(r.Form.Get("client-data"))
+	if err != nil { return nil, err }
+	authData, err := webauthn.DecodeField(r.Form.Get("authenticator-data"))
+	if err != nil { return nil, err }
+	sig, err := webauthn.DecodeField(r.Form.Get("signature"))
+	if err != nil { return nil, err }
+	signCount, err := rp.VerifyAssertion(challenge, cred.PublicKey, uint32(cred.SignCount), clientData, authData, sig, requireUserVerification)
+	if err != nil { return nil, err }
+	cred.SignCount = int64(signCount)
+	cred.LastUsedTime = time.Now().Unix()
+	err = rc.DatabaseInterface.UpdateWebAuthnCredentialUsage(cred.CredentialId, cred.SignCount, cred.LastUsedTime)
+	if err != nil { return nil, err }
+	return cred, nil
+}
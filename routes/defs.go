@@ -4,5 +4,6 @@ const (
 	COOKIE_KEY_USERNAME = "username"
 	COOKIE_KEY_SESSION = "session"
 	COOKIE_KEY_TEMP_KEY = "temp_key"
+	COOKIE_KEY_OIDC_STATE = "oidc_state"
 )
 
@@ -0,0 +1,134 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	"github.com/GitusCodeForge/Gitus/templates"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// authentication of git over http. the credentials are sent with
+// basic auth & are either the password or a personal access token of
+// the user. see docs/http-clone.org.
+
+var ErrHTTPAuthFailed = errors.New("HTTP_AUTH_FAILED: Invalid username, password or token.")
+var ErrHTTPAuthRateLimited = errors.New("HTTP_AUTH_RATE_LIMITED: Too many requests.")
+
+// the last used time of a token is only updated once in this many
+// seconds, since a dumb clone could send hundreds of requests.
+const ACCESS_TOKEN_USAGE_UPDATE_INTERVAL = 60
+
+// returns the user authenticated by the basic auth credentials of the
+// request, or nil if there isn't any. only available in forge mode;
+// in the other modes the credentials are ignored.
+func ResolveHTTPAuthUser(ctx *RouterContext, w http.ResponseWriter, r *http.Request) (*model.GitusUser, error) {
+	un, pw, ok := r.BasicAuth()
+	if !ok || !ctx.Config.IsInForgeMode() { return nil, nil }
+	isToken := model.IsAccessTokenString(pw)
+	method := "http-password"
+	if isToken { method = "http-token" }
+	// failed attempts count as requests to the rate limiter & no
+	// more attempts are accepted while the limit is reached. the
+	// successful ones don't count since a clone or fetch sends several
+	// requests in a row.
+	ip := ResolveMostPossibleIP(w, r)
+	if ctx.RateLimiter != nil && ctx.RateLimiter.IsIPLimited(ip) {
+		return nil, ErrHTTPAuthRateLimited
+	}
+	fail := func(reason string) (*model.GitusUser, error) {
+		if ctx.RateLimiter != nil { ctx.RateLimiter.IsIPAllowed(ip) }
+		ctx.AuditAs(un, model.AUDIT_LOGIN_FAILURE, AuditUserTarget(un), map[string]any{"method": method, "reason": reason}, w, r)
+		return nil, ErrHTTPAuthFailed
+	}
+	u, err := ctx.DatabaseInterface.GetUserByName(un)
+	if err == db.ErrEntityNotFound { return fail("no such user") }
+	if err != nil { return nil, err }
+	switch u.Status {
+	case model.BANNED: return fail("user suspended")
+	case model.NORMAL_USER_APPROVAL_NEEDED: return fail("user waiting for approval")
+	case model.NORMAL_USER_CONFIRM_NEEDED: return fail("confirmation needed")
+	}
+	if isToken {
+		t, err := ctx.DatabaseInterface.GetAccessTokenByHash(model.HashAccessToken(pw))
+		if err == db.ErrEntityNotFound { return fail("no such token") }
+		if err != nil { return nil, err }
+		if t.UserName != u.Name { return fail("no such token") }
+		now := time.Now().Unix()
+		if t.IsExpired(now) { return fail("token expired") }
+		if now - t.LastUsedTime >= ACCESS_TOKEN_USAGE_UPDATE_INTERVAL {
+			LogIfError(ctx.DatabaseInterface.UpdateAccessTokenUsage(t.TokenHash, now))
+		}
+		return u, nil
+	}
+	// there's no way of asking for the second factor through git, so
+	// users with 2fa enabled must use tokens.
+	if u.TFAConfig.IsEnabled() { return fail("password not accepted with 2fa enabled") }
+	err = bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(pw))
+	if err == bcrypt.ErrMismatchedHashAndPassword { return fail("wrong password") }
+	if err != nil { return nil, err }
+	return u, nil
+}
+
+// the login info of the user authenticated by ResolveHTTPAuthUser, so
+// that the checks made for the web ui can be used. `u` is nil for
+// anonymous requests.
+func HTTPAuthLoginInfo(u *model.GitusUser) *templates.LoginInfoModel {
+	if u == nil { return &templates.LoginInfoModel{ LoggedIn: false } }
+	return &templates.LoginInfoModel{
+		LoggedIn: true,
+		UserName: u.Name,
+		UserFullName: u.Title,
+		UserEmail: u.Email,
+		IsAdmin: u.Status == model.ADMIN || u.Status == model.SUPER_ADMIN,
+		IsSuperAdmin: u.Status == model.SUPER_ADMIN,
+	}
+}
+
+// checks whether the user (not logged in for anonymous users) can read
+// the repository, following the same rules as the web ui:
+//
+// + the global visibility (incl. the full-access users in shutdown
+//   mode) is checked first.
+// + internal namespaces & repositories are readable by all the
+//   logged-in users.
+// + private namespaces are readable by their members, the members of
+//   the repositories in them & admins.
+// + limited & private repositories are readable by the members of
+//   the repository & the namespace, and admins.
+//
+// in browse-only mode the visibility is controlled by the "ignored
+// namespace" / "ignored repository" config, which is already
+// accounted for by the .Resolve* methods.
+func CheckRepositoryReadable(ctx *RouterContext, loginInfo *templates.LoginInfoModel, ns *model.Namespace, repo *model.Repository) bool {
+	if ctx.Config.IsInBrowseOnlyMode() { return true }
+	if !CheckGlobalVisibleToUser(ctx, loginInfo) { return false }
+	if !ctx.Config.IsInForgeMode() {
+		return ns.Status == model.NAMESPACE_NORMAL_PUBLIC &&
+			(repo.Status == model.REPO_NORMAL_PUBLIC || repo.Status == model.REPO_ARCHIVED)
+	}
+	loggedIn := loginInfo != nil && loginInfo.LoggedIn
+	un := ""
+	if loggedIn { un = loginInfo.UserName }
+	isNSMember := loggedIn && (ns.Owner == un || ns.ACL.GetUserPrivilege(un) != nil)
+	isRepoMember := loggedIn && (repo.Owner == un || repo.AccessControlList.GetUserPrivilege(un) != nil)
+	switch ns.Status {
+	case model.NAMESPACE_INTERNAL:
+		if !loggedIn { return false }
+	case model.NAMESPACE_NORMAL_PRIVATE:
+		if !(loggedIn && loginInfo.IsAdmin) && !isNSMember && !isRepoMember { return false }
+	}
+	switch repo.Status {
+	case model.REPO_NORMAL_PUBLIC, model.REPO_ARCHIVED:
+		return true
+	case model.REPO_INTERNAL:
+		return loggedIn
+	case model.REPO_LIMITED, model.REPO_NORMAL_PRIVATE:
+		return (loggedIn && loginInfo.IsAdmin) || isNSMember || isRepoMember
+	default:
+		return false
+	}
+}
@@ -1,12 +1,19 @@
 package routes
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"slices"
+	"strconv"
+	"time"
 
 	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/db"
+	gitlog "github.com/GitusCodeForge/Gitus/pkg/gitus/log"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/metrics"
 	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
 	"github.com/GitusCodeForge/Gitus/templates"
 )
@@ -26,6 +33,13 @@ func UseMiddleware(w []Middleware, ctx *RouterContext, f HandlerFunc) http.Handl
 	i := len(w)-2
 	for i >= 0 { res = w[i](res); i -= 1; }
 	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sr := &statusRecorder{ ResponseWriter: w, status: 200 }
+		w = sr
+		defer func() {
+			metrics.HTTPRequestTotal.Inc(r.Pattern, r.Method, strconv.Itoa(sr.status))
+			metrics.HTTPRequestDuration.Observe(time.Since(start).Seconds(), r.Pattern, r.Method)
+		}()
 		rc := ctx.NewLocal()
 		// security headers...
 		w.Header().Set("X-Content-Type-Options", "nosniff")
@@ -39,13 +53,62 @@ func UseMiddleware(w []Middleware, ctx *RouterContext, f HandlerFunc) http.Handl
 	}
 }
 
+// records the status code for the metrics. see docs/metrics.org.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	wroteHeader bool
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	if !sr.wroteHeader {
+		sr.status = code
+		sr.wroteHeader = true
+	}
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	sr.wroteHeader = true
+	return sr.ResponseWriter.Write(b)
+}
+
+// so that http.ResponseController can still reach the original
+// writer.
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// assigns the request id (or takes the one set by the reverse
+// proxy in `X-Request-Id`), which is then attached to all the logs
+// of this request - including the database errors. see
+// docs/logging.org.
 var Logged Middleware = func(f HandlerFunc) HandlerFunc {
 	return func(ctx *RouterContext, w http.ResponseWriter, r *http.Request) {
-		log.Printf(" %s %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+		id := r.Header.Get("X-Request-Id")
+		if !gitlog.IsValidRequestID(id) { id = gitlog.NewRequestID() }
+		w.Header().Set("X-Request-Id", id)
+		r = r.WithContext(gitlog.WithRequestID(r.Context(), id))
+		slog.InfoContext(r.Context(), "request", "remote", r.RemoteAddr, "method", r.Method, "path", r.URL.Path)
+		if ctx.DatabaseInterface != nil {
+			ctx.DatabaseInterface = withDatabaseErrorLog(r.Context(), ctx.DatabaseInterface)
+		}
 		f(ctx, w, r)
 	}
 }
 
+// "not found" & "already exists" are part of the normal flow and
+// are not logged.
+func withDatabaseErrorLog(rctx context.Context, dbif db.GitusDatabaseInterface) db.GitusDatabaseInterface {
+	return &db.ErrorHookedGitusDatabaseInterface{
+		GitusDatabaseInterface: dbif,
+		Hook: func(method string, err error) {
+			if errors.Is(err, db.ErrEntityNotFound) || errors.Is(err, db.ErrEntityAlreadyExists) { return }
+			slog.WarnContext(rctx, "database error", "method", method, "error", err)
+		},
+	}
+}
+
 var ValidPOSTRequestRequired Middleware = func(f HandlerFunc) HandlerFunc {
 	return func(ctx *RouterContext, w http.ResponseWriter, r *http.Request) {
 		err := r.ParseForm()
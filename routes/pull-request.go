@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/pullrequest"
+)
+
+// records the updates on the provider branches of the open pull
+// requests after `ns:name` is changed through the web, like pushing
+// over ssh does. see docs/pull-request.org. failing to do so is only
+// logged; the update would be recorded on the next push.
+func (ctx *RouterContext) SyncPullRequestProviderBranch(ns string, name string, r *http.Request) {
+	if ctx.DatabaseInterface == nil || !ctx.Config.IsInForgeMode() { return }
+	author := ""
+	if ctx.LoginInfo != nil { author = ctx.LoginInfo.UserName }
+	err := pullrequest.SyncProviderBranch(r.Context(), ctx.Config, ctx.DatabaseInterface, ns, name, author)
+	if err != nil {
+		slog.WarnContext(r.Context(), "pull request: failed to record provider branch update", "namespace", ns, "repository", name, "error", err)
+	}
+}
+
+// records the provider head of the newly created pull request `id` of
+// `ns:name`. failing to do so is only logged; the head would be
+// recorded on the next push w/o an event.
+func (ctx *RouterContext) TrackPullRequestProviderHead(ns string, name string, id int64, r *http.Request) {
+	pr, err := ctx.DatabaseInterface.GetPullRequest(ns, name, id)
+	if err == nil { err = pullrequest.TrackProviderHead(ctx.Config, ctx.DatabaseInterface, pr) }
+	if err != nil {
+		slog.WarnContext(r.Context(), "pull request: failed to record provider head", "namespace", ns, "repository", name, "id", id, "error", err)
+	}
+}
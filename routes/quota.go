@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/quota"
+)
+
+// the storage quotas. see docs/quota.org.
+
+// checks whether adding `delta` to the namespace `ns` & the user
+// `owner` is within their quotas & the site's. reports the error &
+// returns false if it isn't.
+func (ctx *RouterContext) CheckQuota(ns string, owner string, delta *model.QuotaUsage, w http.ResponseWriter, r *http.Request) bool {
+	err := quota.Check(ctx.Config, ctx.DatabaseInterface, ns, owner, delta)
+	if err == nil { return true }
+	var qe *quota.ErrQuotaExceeded
+	if errors.As(err, &qe) {
+		ctx.ReportNormalError(err.Error(), w, r)
+		return false
+	}
+	ctx.ReportInternalError(fmt.Sprintf("Failed to check storage quota: %s", err.Error()), w, r)
+	return false
+}
+
+// measures the repository after it's changed through the web. failing
+// to do so only makes the recorded size outdated until the next push
+// or maintenance run, so it's only logged.
+func (ctx *RouterContext) RefreshRepositorySize(ns string, name string, r *http.Request) {
+	if ctx.DatabaseInterface == nil || !ctx.Config.IsInForgeMode() { return }
+	_, err := quota.RefreshRepositorySize(ctx.Config, ctx.DatabaseInterface, ns, name)
+	if err != nil {
+		slog.WarnContext(r.Context(), "quota: failed to measure repository", "namespace", ns, "repository", name, "error", err)
+	}
+}
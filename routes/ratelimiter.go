@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/metrics"
 	"golang.org/x/time/rate"
 )
 
@@ -47,6 +48,25 @@ func ResolveMostPossibleIP(w http.ResponseWriter, r *http.Request) string {
 	return h
 }
 
+// changes the limit of all the ips. see docs/config-reload.org.
+func (rl *RateLimiter) SetLimit(limit float64) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	rl.limit = rate.Limit(limit)
+	for _, r := range rl.limiter {
+		r.SetLimit(rl.limit)
+	}
+}
+
+// same as IsIPAllowed but doesn't count as a request.
+func (rl *RateLimiter) IsIPLimited(s string) bool {
+	rl.mutex.RLock()
+	r, ok := rl.limiter[s]
+	rl.mutex.RUnlock()
+	if !ok { return false }
+	return r.Tokens() < 1
+}
+
 func (rl *RateLimiter) IsIPAllowed(s string) bool {
 	var r *rate.Limiter
 	var ok bool
@@ -57,6 +77,10 @@ func (rl *RateLimiter) IsIPAllowed(s string) bool {
 		r = rl.limiter[s]
 		rl.mutex.Unlock()
 	}
-	return r.Allow()
+	if !r.Allow() {
+		metrics.RateLimitRejectedTotal.Inc()
+		return false
+	}
+	return true
 }
 
@@ -0,0 +1,244 @@
+package routes
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/GitusCodeForge/Gitus/pkg/gitus"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/confirm_code"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/mail"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/metrics"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/receipt"
+	rsinit "github.com/GitusCodeForge/Gitus/pkg/gitus/receipt/init"
+	"github.com/GitusCodeForge/Gitus/pkg/gitus/session"
+	ssinit "github.com/GitusCodeForge/Gitus/pkg/gitus/session/init"
+)
+
+// reloading the config file while gitus is running. see
+// docs/config-reload.org.
+
+// guards the parts of the shared RouterContext that are swapped when
+// reloading; the per-request copies are made by NewLocal under it.
+var contextLock sync.RWMutex
+
+// the replaced session store & receipt system are disposed after
+// this so that the requests still using them can finish. the web
+// server's WriteTimeout is shorter than this.
+const RETIRED_BACKEND_DISPOSE_DELAY = 2 * time.Minute
+
+const CONFIG_WATCH_INTERVAL = 5 * time.Second
+
+type ConfigReloadResult struct {
+	Time time.Time
+	// the parts re-created with the new settings, e.g. "mailer".
+	Applied []string
+	// the changed settings (by their json names) that would only take
+	// effect after a restart.
+	RestartRequired []string
+	// the changed config fields as `[old, new]`; see ConfigSnapshotDiff.
+	Diff map[string]any
+	Error error
+}
+
+type ConfigReloader struct {
+	lock sync.Mutex
+	ctx *RouterContext
+	// the settings the running parts are created from, which could
+	// be different from ctx.Config after editing the config on the
+	// admin pages.
+	applied *gitus.GitusConfig
+	lastResult *ConfigReloadResult
+	lastModTime time.Time
+	lastSize int64
+}
+
+// `ctx` should be the RouterContext shared by all the routes.
+func NewConfigReloader(ctx *RouterContext) (*ConfigReloader, error) {
+	applied, err := ctx.Config.Clone()
+	if err != nil { return nil, err }
+	cr := &ConfigReloader{ ctx: ctx, applied: applied }
+	if st, err := os.Stat(ctx.Config.FilePath); err == nil {
+		cr.lastModTime = st.ModTime()
+		cr.lastSize = st.Size()
+	}
+	return cr, nil
+}
+
+func (cr *ConfigReloader) LastResult() *ConfigReloadResult {
+	cr.lock.Lock()
+	defer cr.lock.Unlock()
+	return cr.lastResult
+}
+
+// returns the settings (by their json names) that are saved in
+// ctx.Config but are not in effect yet.
+func (cr *ConfigReloader) PendingSetting() (reloadable []string, restartRequired []string) {
+	cr.lock.Lock()
+	defer cr.lock.Unlock()
+	return gitus.ChangedSetting(cr.applied, cr.ctx.Config, reloadableSetting), gitus.RestartRequiredSettingChange(cr.applied, cr.ctx.Config.Saved())
+}
+
+// the settings that need re-creating some parts of gitus.
+var reloadableSetting = []string{"session", "receiptSystem", "mailer", "confirmCode", "maxRequestInSecond"}
+
+// a session store or receipt system switched to could be a new one,
+// in which case the tables are created.
+func ensureUsable(usable func() (bool, error), install func() error, name string) error {
+	b, err := usable()
+	if err == nil && b { return nil }
+	if err = install(); err != nil { return fmt.Errorf("failed to install %s: %w", name, err) }
+	b, err = usable()
+	if err != nil { return err }
+	if !b { return fmt.Errorf("%s not usable", name) }
+	return nil
+}
+
+// re-reads the config file & applies it. when an error is returned
+// nothing is changed.
+func (cr *ConfigReloader) Reload() *ConfigReloadResult {
+	cr.lock.Lock()
+	defer cr.lock.Unlock()
+	res := cr.reload()
+	res.Time = time.Now()
+	cr.lastResult = res
+	if st, err := os.Stat(cr.ctx.Config.FilePath); err == nil {
+		cr.lastModTime = st.ModTime()
+		cr.lastSize = st.Size()
+	}
+	if res.Error != nil {
+		slog.Error("failed to reload config", "error", res.Error)
+	} else {
+		slog.Info("config reloaded", "applied", res.Applied, "restartRequired", res.RestartRequired)
+	}
+	return res
+}
+
+func (cr *ConfigReloader) reload() *ConfigReloadResult {
+	res := &ConfigReloadResult{
+		Applied: make([]string, 0),
+		RestartRequired: make([]string, 0),
+	}
+	ctx := cr.ctx
+	newCfg, err := gitus.LoadConfigFile(ctx.Config.FilePath)
+	if err != nil { res.Error = err; return res }
+	if err = newCfg.Validate(); err != nil { res.Error = err; return res }
+	res.RestartRequired = gitus.RestartRequiredSettingChange(cr.applied, newCfg)
+	changed := gitus.ChangedSetting(cr.applied, newCfg, reloadableSetting)
+	isChanged := func(name string) bool {
+		for _, k := range changed {
+			if k == name { return true }
+		}
+		return false
+	}
+
+	// everything is created before swapping so that a failure
+	// leaves everything as it was.
+	// the backends only exist in forge mode, & the operation mode
+	// can only be changed by restarting.
+	forge := cr.applied.IsInForgeMode()
+	var ssif session.GitusSessionStore
+	var rs receipt.GitusReceiptSystemInterface
+	var ml mail.GitusMailerInterface
+	var ccm confirm_code.GitusConfirmCodeManager
+	fail := func(err error) *ConfigReloadResult {
+		if ssif != nil { ssif.Dispose() }
+		if rs != nil { rs.Dispose() }
+		res.Error = err
+		return res
+	}
+	swapSession := forge && isChanged("session")
+	if swapSession {
+		ssif, err = ssinit.InitializeDatabase(newCfg)
+		if err != nil { return fail(err) }
+		if err = ensureUsable(ssif.IsSessionStoreUsable, ssif.Install, "session store"); err != nil { return fail(err) }
+		if cr.applied.Metrics.Enable { ssif = metrics.HookSessionStore(ssif) }
+	}
+	swapReceipt := forge && isChanged("receiptSystem")
+	if swapReceipt {
+		rs, err = rsinit.InitializeReceiptSystem(newCfg)
+		if err != nil { return fail(err) }
+		if err = ensureUsable(rs.IsReceiptSystemUsable, rs.Install, "receipt system"); err != nil { return fail(err) }
+	}
+	swapMailer := forge && isChanged("mailer")
+	if swapMailer && newCfg.Mailer.Type != "" {
+		ml, err = mail.InitializeMailer(newCfg)
+		if err != nil { return fail(err) }
+	}
+	swapConfirmCode := forge && isChanged("confirmCode")
+	if swapConfirmCode {
+		ccm, err = confirm_code.InitializeConfirmCodeManager(newCfg)
+		if err != nil { return fail(err) }
+	}
+
+	before := ConfigSnapshot(ctx.Config)
+	contextLock.Lock()
+	ctx.Config.LockForSync()
+	err = ctx.Config.AssignReloadable(newCfg, cr.applied)
+	ctx.Config.Unlock()
+	if err != nil {
+		contextLock.Unlock()
+		return fail(err)
+	}
+	var oldSession session.GitusSessionStore
+	var oldReceipt receipt.GitusReceiptSystemInterface
+	if swapSession {
+		oldSession = ctx.SessionInterface
+		ctx.SessionInterface = ssif
+		cr.applied.Session = newCfg.Session
+		res.Applied = append(res.Applied, "session")
+	}
+	if swapReceipt {
+		oldReceipt = ctx.ReceiptSystem
+		ctx.ReceiptSystem = rs
+		cr.applied.ReceiptSystem = newCfg.ReceiptSystem
+		res.Applied = append(res.Applied, "receiptSystem")
+	}
+	if swapMailer {
+		ctx.Mailer = ml
+		cr.applied.Mailer = newCfg.Mailer
+		res.Applied = append(res.Applied, "mailer")
+	}
+	if swapConfirmCode {
+		ctx.ConfirmCodeManager = ccm
+		cr.applied.ConfirmCodeManager = newCfg.ConfirmCodeManager
+		res.Applied = append(res.Applied, "confirmCode")
+	}
+	if isChanged("maxRequestInSecond") && ctx.RateLimiter != nil {
+		ctx.RateLimiter.SetLimit(newCfg.MaxRequestInSecond)
+		cr.applied.MaxRequestInSecond = newCfg.MaxRequestInSecond
+		res.Applied = append(res.Applied, "maxRequestInSecond")
+	}
+	contextLock.Unlock()
+
+	if oldSession != nil || oldReceipt != nil {
+		time.AfterFunc(RETIRED_BACKEND_DISPOSE_DELAY, func() {
+			if oldSession != nil { LogIfError(oldSession.Dispose()) }
+			if oldReceipt != nil { LogIfError(oldReceipt.Dispose()) }
+		})
+	}
+	res.Diff = ConfigSnapshotDiff(before, ConfigSnapshot(ctx.Config))
+	return res
+}
+
+// reloads the config when the config file is changed, if
+// `watchConfigFile` is enabled. the file is polled since there's
+// no portable way of watching files in the stdlib. never returns.
+func (cr *ConfigReloader) Watch() {
+	ticker := time.NewTicker(CONFIG_WATCH_INTERVAL)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !cr.ctx.Config.WatchConfigFile { continue }
+		st, err := os.Stat(cr.ctx.Config.FilePath)
+		if err != nil { continue }
+		cr.lock.Lock()
+		changed := !st.ModTime().Equal(cr.lastModTime) || st.Size() != cr.lastSize
+		cr.lock.Unlock()
+		if changed {
+			slog.Info("config file changed; reloading", "path", cr.ctx.Config.FilePath)
+			cr.Reload()
+		}
+	}
+}
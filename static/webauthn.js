@@ -0,0 +1,120 @@
+// the webauthn shim. this is only loaded on the pages that deal with
+// security keys & passkeys and only when the user has opted in for
+// javascript (or has explicitly chosen to log in with a passkey);
+// see docs/webauthn.org.
+//
+// all the forms handled here carry the parameters as data-*
+// attributes and are hidden by default; when javascript or webauthn
+// isn't available, they stay hidden and the "fallback" message is
+// shown instead.
+
+(function () {
+	"use strict";
+
+	function b64urlToBytes(s) {
+		s = s.replace(/-/g, "+").replace(/_/g, "/");
+		while (s.length % 4 !== 0) { s += "="; }
+		var bin = atob(s);
+		var res = new Uint8Array(bin.length);
+		for (var i = 0; i < bin.length; i++) { res[i] = bin.charCodeAt(i); }
+		return res;
+	}
+
+	function bytesToB64url(buf) {
+		var b = new Uint8Array(buf);
+		var bin = "";
+		for (var i = 0; i < b.length; i++) { bin += String.fromCharCode(b[i]); }
+		return btoa(bin).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+	}
+
+	function idList(s) {
+		if (!s) { return []; }
+		return s.split(",").filter(function (x) { return x.length > 0; }).map(function (x) {
+			return { type: "public-key", id: b64urlToBytes(x) };
+		});
+	}
+
+	function setField(form, name, value) {
+		var e = form.querySelector("input[name=\"" + name + "\"]");
+		if (e) { e.value = value; }
+	}
+
+	function showError(form, msg) {
+		var e = form.querySelector(".webauthn-error");
+		if (e) { e.textContent = msg; e.hidden = false; }
+	}
+
+	function setupRegisterForm(form) {
+		form.addEventListener("submit", function (ev) {
+			ev.preventDefault();
+			var passkeyBox = form.querySelector("input[name=\"passkey\"]");
+			var passkey = passkeyBox && passkeyBox.checked;
+			navigator.credentials.create({
+				publicKey: {
+					challenge: b64urlToBytes(form.dataset.challenge),
+					rp: { id: form.dataset.rpId, name: form.dataset.rpName },
+					user: {
+						id: b64urlToBytes(form.dataset.userId),
+						name: form.dataset.userName,
+						displayName: form.dataset.userName
+					},
+					pubKeyCredParams: [
+						{ type: "public-key", alg: -7 },
+						{ type: "public-key", alg: -8 },
+						{ type: "public-key", alg: -257 }
+					],
+					authenticatorSelection: {
+						residentKey: passkey ? "required" : "discouraged",
+						requireResidentKey: !!passkey,
+						userVerification: passkey ? "required" : "discouraged"
+					},
+					excludeCredentials: idList(form.dataset.exclude),
+					attestation: "none",
+					timeout: 120000
+				}
+			}).then(function (cred) {
+				setField(form, "client-data", bytesToB64url(cred.response.clientDataJSON));
+				setField(form, "attestation-object", bytesToB64url(cred.response.attestationObject));
+				form.submit();
+			}).catch(function (err) {
+				showError(form, "Failed to register security key: " + err.message);
+			});
+		});
+	}
+
+	function setupLoginForm(form) {
+		form.addEventListener("submit", function (ev) {
+			ev.preventDefault();
+			navigator.credentials.get({
+				publicKey: {
+					challenge: b64urlToBytes(form.dataset.challenge),
+					rpId: form.dataset.rpId,
+					allowCredentials: idList(form.dataset.allow),
+					userVerification: form.dataset.userVerification || "discouraged",
+					timeout: 120000
+				}
+			}).then(function (cred) {
+				setField(form, "credential-id", bytesToB64url(cred.rawId));
+				setField(form, "client-data", bytesToB64url(cred.response.clientDataJSON));
+				setField(form, "authenticator-data", bytesToB64url(cred.response.authenticatorData));
+				setField(form, "signature", bytesToB64url(cred.response.signature));
+				if (cred.response.userHandle) {
+					setField(form, "user-handle", bytesToB64url(cred.response.userHandle));
+				}
+				form.submit();
+			}).catch(function (err) {
+				showError(form, "Failed to use security key: " + err.message);
+			});
+		});
+	}
+
+	document.addEventListener("DOMContentLoaded", function () {
+		if (!window.PublicKeyCredential || !navigator.credentials) { return; }
+		var fallback = document.querySelectorAll(".webauthn-fallback");
+		for (var i = 0; i < fallback.length; i++) { fallback[i].hidden = true; }
+		var reg = document.querySelectorAll("form.webauthn-register-form");
+		for (var i = 0; i < reg.length; i++) { setupRegisterForm(reg[i]); reg[i].hidden = false; }
+		var login = document.querySelectorAll("form.webauthn-login-form");
+		for (var i = 0; i < login.length; i++) { setupLoginForm(login[i]); login[i].hidden = false; }
+	});
+})();
@@ -0,0 +1,11 @@
+//go:build ignore
+package templates
+
+// a git lfs pointer file shown in place of its content.
+type LFSPointerTemplateModel struct {
+	OID string
+	Size int64
+	// empty if the object can't be downloaded from this instance,
+	// e.g. when git lfs is not enabled or the object is not uploaded.
+	DownloadLink string
+}
@@ -3,9 +3,15 @@ package templates
 
 import "fmt"
 
-func(a... int) int {
-	var x int = 0
-	for _, item := range a { x = x + item }
+// takes both int (e.g. loop indices) & int64 (e.g. page numbers).
+func(a... any) int64 {
+	var x int64 = 0
+	for _, item := range a {
+		switch v := item.(type) {
+		case int: x = x + int64(v)
+		case int64: x = x + v
+		default: panic(fmt.Sprintf("Cannot add value of type %T", item))
+		}
+	}
 	return x
 }
-
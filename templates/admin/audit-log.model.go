@@ -0,0 +1,24 @@
+//go:build ignore
+
+package templates
+
+import "github.com/GitusCodeForge/Gitus/pkg/gitus"
+import "github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+
+type AdminAuditLogTemplateModel struct {
+	Config *gitus.GitusConfig
+	LoginInfo *LoginInfoModel
+	ErrorMsg string
+	EntryList []*model.AuditLogEntry
+	ActionList []string
+	PageInfo *PageInfoModel
+	// the filter as it's given in the query string, so that it can be
+	// carried over to the other pages & the export.
+	Actor string
+	Action string
+	Target string
+	IP string
+	Since string
+	Until string
+}
+
@@ -0,0 +1,30 @@
+//go:build ignore
+
+package templates
+
+import "github.com/GitusCodeForge/Gitus/pkg/gitus"
+import "github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+
+type AdminQuotaTemplateModel struct {
+	Config *gitus.GitusConfig
+	LoginInfo *LoginInfoModel
+	// model.QUOTA_SUBJECT_USER or model.QUOTA_SUBJECT_NAMESPACE.
+	SubjectType string
+	SubjectName string
+	// the admin page of the user or the namespace.
+	EditPath string
+	HasOverride bool
+	Form *AdminQuotaFormValue
+	// the limits in effect, i.e. the override merged w/ the defaults.
+	Quota *model.Quota
+	Usage *model.QuotaUsage
+}
+
+// the override as shown on the form; the sizes are in MiB & blank
+// means the default is used.
+type AdminQuotaFormValue struct {
+	RepositorySize string
+	LFSSize string
+	SnippetSize string
+	RepositoryCount string
+}
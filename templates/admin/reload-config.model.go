@@ -0,0 +1,19 @@
+//go:build ignore
+
+package templates
+
+import "github.com/GitusCodeForge/Gitus/pkg/gitus"
+
+type AdminReloadConfigTemplateModel struct {
+	Config *gitus.GitusConfig
+	LoginInfo *LoginInfoModel
+	// settings saved on the admin pages but not in effect yet.
+	PendingReloadable []string
+	PendingRestartRequired []string
+	// empty if there hasn't been a reload since gitus started.
+	LastReloadTime string
+	LastReloadError string
+	LastApplied []string
+	LastRestartRequired []string
+}
+
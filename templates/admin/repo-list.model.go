@@ -12,5 +12,28 @@ type AdminRepositoryListTemplateModel struct {
 	RepositoryList []*model.Repository
 	PageInfo *PageInfoModel
 	Query string
+	// keyed by the full name of the repository. repositories that
+	// haven't been maintained yet are not included.
+	MaintenanceStatus map[string]*AdminRepositoryMaintenanceStatus
+	// the size of each repository (incl. git lfs objects) as last
+	// measured, keyed by the full name of the repository.
+	RepositorySize map[string]int64
+	// the storage used by all repositories. see docs/quota.org.
+	SiteUsage *model.QuotaUsage
+}
+
+type AdminRepositoryMaintenanceStatus struct {
+	LastRunTime int64
+	// whether the latest run of any of the tasks has failed.
+	Failed bool
+}
+
+type AdminRepositoryMaintenanceTemplateModel struct {
+	Config *gitus.GitusConfig
+	LoginInfo *LoginInfoModel
+	Repository *model.Repository
+	RepoFullName string
+	Running bool
+	RunList []*model.MaintenanceRun
 }
 
@@ -13,5 +13,8 @@ type SettingEditSSHKeyTemplateModel struct {
 		Type string
 		Message string
 	}
+	// whether a second factor is required; see
+	// GitusUser2FAConfig.RequireForSSHKey.
+	Require2FA bool
 }
 
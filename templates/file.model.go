@@ -10,6 +10,8 @@ type FileTemplateModel struct {
 	Repository *model.Repository
 	RepoHeaderInfo RepoHeaderTemplateModel
 	File BlobTextTemplateModel
+	// set when the file is a git lfs pointer.
+	LFSPointer *LFSPointerTemplateModel
 	PermaLink string
 
 	ComparisonInfo *gitlib.BranchComparisonInfo
@@ -2,13 +2,20 @@
 
 package templates
 
-import "github.com/bctnry/gitus/pkg/gitus"
+import "github.com/GitusCodeForge/Gitus/pkg/gitus"
+import "github.com/GitusCodeForge/Gitus/pkg/gitlib"
 
 type GitBlameTemplateModel struct {
 	Config *gitus.GitusConfig
 	Repository *model.Repository
 	RepoHeaderInfo RepoHeaderTemplateModel
 	Blame *gitlib.PorcelainBlame
+	BlameOption *gitlib.BlameOption
+	// true if the repository has a `.git-blame-ignore-revs` file
+	// (whether it's used or not is decided by .BlameOption).
+	HasIgnoreRevs bool
+	// query string (w/o the leading `?`) that reproduces .BlameOption.
+	BlameQuery string
 
 	TreeFileList *TreeFileListTemplateModel
 	TreePath *TreePathTemplateModel
@@ -18,4 +25,3 @@ type GitBlameTemplateModel struct {
 	
 	LoginInfo *LoginInfoModel
 }
-
@@ -0,0 +1,10 @@
+//go:build ignore
+package templates
+
+import "github.com/GitusCodeForge/Gitus/pkg/gitlib"
+
+func(ci *gitlib.BranchComparisonInfo) bool {
+	if ci == nil { return false }
+	return len(ci.ARevList) > 0 && len(ci.BRevList) > 0
+}
+
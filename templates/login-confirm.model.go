@@ -9,5 +9,15 @@ type LoginConfirmTemplateModel struct {
 	ErrorMsg string
 	LoginInfo *LoginInfoModel
 	Username string
+	// "totp", "email", "recovery" or "webauthn"; see
+	// routes/controller/login.go.
+	Method string
+	// whether totp codes are accepted as well (when the method is
+	// "webauthn").
+	AcceptTOTP bool
+	// the following are only set when the method is "webauthn".
+	Challenge string
+	RPID string
+	// comma-separated ids of the user's credentials.
+	AllowList string
 }
-
@@ -0,0 +1,16 @@
+//go:build ignore
+
+package templates
+
+import "github.com/GitusCodeForge/Gitus/pkg/gitus"
+
+type LoginPasskeyTemplateModel struct {
+	Config *gitus.GitusConfig
+	ErrorMsg string
+	LoginInfo *LoginInfoModel
+	Callback string
+	// false when the host name of the site isn't configured.
+	Available bool
+	Challenge string
+	RPID string
+}
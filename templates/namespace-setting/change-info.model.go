@@ -9,6 +9,10 @@ type NamespaceSettingTemplateModel struct {
 	Config *gitus.GitusConfig
 	Namespace *model.Namespace
 	LoginInfo *LoginInfoModel
+	// the storage used by the repositories under the namespace & the
+	// limits; the latter is nil when quotas are disabled.
+	QuotaUsage *model.QuotaUsage
+	Quota *model.Quota
 	ErrorMsg struct {
 		Type string
 		Message string
@@ -12,5 +12,6 @@ type NamespaceSettingMemberListTemplateModel struct {
 	ErrorMsg string
 	ACL map[string]*model.ACLTuple
 	PageInfo *PageInfoModel
+	Require2FA bool
 }
 
@@ -4,6 +4,7 @@ package templates
 
 import "github.com/GitusCodeForge/Gitus/pkg/gitus"
 import "github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+import "github.com/GitusCodeForge/Gitus/pkg/gitlib"
 
 type RepositoryNewPullRequestTemplateModel struct {
 	Config *gitus.GitusConfig
@@ -11,10 +12,15 @@ type RepositoryNewPullRequestTemplateModel struct {
 	RepoHeaderInfo *RepoHeaderTemplateModel
 	LoginInfo *LoginInfoModel
 	ErrorMsg string
+	// "repo", "branch" or "compare".
 	Stage string
 	ReceiverBranch string
 	ProviderRepository []*model.Repository
 	ChosenProviderRepository *model.Repository
 	ProviderBranchList []string
+	// only when Stage is "compare".
+	ProviderBranch string
+	Comparison *gitlib.MergeTargetComparison
+	// the open pull request between the same branches, if any.
+	ExistingPullRequest *model.PullRequest
 }
-
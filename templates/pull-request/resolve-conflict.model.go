@@ -0,0 +1,43 @@
+//go:build ignore
+
+package templates
+
+import "github.com/GitusCodeForge/Gitus/pkg/gitus"
+import "github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+import "github.com/GitusCodeForge/Gitus/pkg/gitlib"
+
+// a part of a conflicted file. the text of a clean hunk is in `Text`.
+type PullRequestConflictHunk struct {
+	Conflict bool
+	Text string
+	Base string
+	Ours string
+	Theirs string
+}
+
+type PullRequestConflictFile struct {
+	Path string
+	Mergeable bool
+	// only when not Mergeable.
+	OursExists bool
+	TheirsExists bool
+	// only when Mergeable.
+	HunkList []*PullRequestConflictHunk
+	// the file w/ conflict markers, used as the initial content of
+	// the edit area.
+	MarkedText string
+}
+
+type RepositoryPullRequestResolveTemplateModel struct {
+	Config *gitus.GitusConfig
+	Repository *model.Repository
+	RepoHeaderInfo *RepoHeaderTemplateModel
+	LoginInfo *LoginInfoModel
+	PullRequest *model.PullRequest
+	OursId string
+	TheirsId string
+	OursLabel string
+	TheirsLabel string
+	Message []gitlib.MergeCheckInformationalMessage
+	FileList []*PullRequestConflictFile
+}
@@ -14,5 +14,10 @@ type RepositorySinglePullRequestTemplateModel struct {
 	PullRequest *model.PullRequest
 	PullRequestEventList []*model.PullRequestEvent
 	PageNum int64
+	// whether the current user can resolve the conflicts on the web.
+	CanResolveConflict bool
+	// whether the current user can choose to delete the provider
+	// branch when merging.
+	CanDeleteProviderBranch bool
 }
 
@@ -0,0 +1,18 @@
+//go:build ignore
+
+package templates
+
+import "github.com/GitusCodeForge/Gitus/pkg/gitus"
+import "github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+
+type RepositoryInsightModel struct {
+	Config *gitus.GitusConfig
+	Repository *model.Repository
+	RepoHeaderInfo RepoHeaderTemplateModel
+	LoginInfo *LoginInfoModel
+	// true if the repository doesn't have any branch.
+	IsEmpty bool
+	// nil when the insight is still being computed.
+	Insight *model.RepositoryInsight
+	EmailUserMapping map[string]string
+}
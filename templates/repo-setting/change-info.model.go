@@ -12,5 +12,7 @@ type RepositorySettingTemplateModel struct {
 	RepoFullName string
 	LoginInfo *LoginInfoModel
 	ErrorMsg string
+	// nil when git lfs is not enabled.
+	LFSUsage *model.LFSStorageUsage
 }
 
@@ -0,0 +1,22 @@
+//go:build ignore
+
+package templates
+
+import "github.com/GitusCodeForge/Gitus/pkg/gitus"
+import "github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+import "github.com/GitusCodeForge/Gitus/pkg/gitlib"
+
+type RepositorySyncTemplateModel struct {
+	Config *gitus.GitusConfig
+	LoginInfo *LoginInfoModel
+	Repository *model.Repository
+	RepoHeaderInfo *RepoHeaderTemplateModel
+	BranchName string
+	// whether the upstream has a branch w/ the same name.
+	HasUpstreamBranch bool
+	// nil when the branch isn't on the upstream.
+	ComparisonInfo *gitlib.BranchComparisonInfo
+	// only set when the branch has diverged from the upstream.
+	MergeCheckResult *gitlib.MergeCheckResult
+	AutoSync bool
+}
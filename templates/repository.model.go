@@ -17,4 +17,6 @@ type RepositoryModel struct{
 	TreeFileList *TreeFileListTemplateModel
 	CommitInfo *CommitInfoTemplateModel
 	ComparisonInfo *gitlib.BranchComparisonInfo
+	// the forks visible to the current user. forge mode only.
+	ForkList []*model.Repository
 }
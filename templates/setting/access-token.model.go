@@ -0,0 +1,19 @@
+//go:build ignore
+
+package templates
+
+import "github.com/GitusCodeForge/Gitus/pkg/gitus"
+import "github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+
+type SettingAccessTokenTemplateModel struct {
+	Config *gitus.GitusConfig
+	LoginInfo *LoginInfoModel
+	TokenList []*model.AccessToken
+	// in days; 0 means never expire.
+	LifetimeList []int
+	// the token just created; only shown once.
+	NewToken string
+	ErrorMsg string
+	Now int64
+}
+
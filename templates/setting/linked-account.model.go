@@ -0,0 +1,15 @@
+//go:build ignore
+
+package templates
+
+import "github.com/GitusCodeForge/Gitus/pkg/gitus"
+import "github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+
+type SettingLinkedAccountTemplateModel struct {
+	Config *gitus.GitusConfig
+	User *model.GitusUser
+	LoginInfo *LoginInfoModel
+	// provider id -> link.
+	LinkedAccount map[string]*model.OIDCLink
+}
+
@@ -13,6 +13,10 @@ type SettingPrivacyTemplateModel struct {
 		Type string
 		Message string
 	}
-		
+	// the "otpauth://" uri during totp enrollment; empty otherwise.
+	TOTPKeyURI string
+	// plain text of newly generated recovery codes. these are only
+	// shown once.
+	NewRecoveryCodeList []string
 }
 
@@ -0,0 +1,24 @@
+//go:build ignore
+
+package templates
+
+import "github.com/GitusCodeForge/Gitus/pkg/gitus"
+import "github.com/GitusCodeForge/Gitus/pkg/gitus/model"
+
+type SettingWebAuthnTemplateModel struct {
+	Config *gitus.GitusConfig
+	User *model.GitusUser
+	LoginInfo *LoginInfoModel
+	CredentialList []*model.WebAuthnCredential
+	UseJavascript bool
+	ErrorMsg string
+	// true when a registration challenge is issued, i.e. when the
+	// host name is configured & the user has opted in for javascript.
+	Available bool
+	Challenge string
+	RPID string
+	UserHandle string
+	// comma-separated ids of the already-registered credentials.
+	ExcludeList string
+}
+
@@ -0,0 +1,16 @@
+//go:build ignore
+package templates
+
+import "fmt"
+
+// formats a size in bytes with binary units, e.g. 1.5 MiB.
+
+func(n int64) string {
+	if n < 1024 { return fmt.Sprintf("%d B", n) }
+	f := float64(n)
+	for _, unit := range []string{"KiB", "MiB", "GiB", "TiB"} {
+		f /= 1024
+		if f < 1024 || unit == "TiB" { return fmt.Sprintf("%.1f %s", f, unit) }
+	}
+	return ""
+}